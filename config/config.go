@@ -5,17 +5,108 @@ import (
 	"io"
 	"log"
 
+	"github.com/pkg/errors"
 	"github.com/sarulabs/di"
 	"github.com/spf13/viper"
 )
 
-const defaultAddr = "127.0.0.1:8080"
+const (
+	defaultAddr                      = "127.0.0.1:8080"
+	defaultStorage                   = postgresStorage
+	defaultStoragePath               = "./data"
+	defaultCapturePartition          = "month"
+	defaultRetentionInterval         = 60 * 60
+	defaultRetentionArchivePath      = "./archive"
+	defaultRollupInterval            = 60 * 60
+	defaultJWTRefreshExpirationDelta = 30 * 24 * 60 * 60
+	defaultJWTSigningMethod          = "HS256"
+	defaultAppURL                    = "http://127.0.0.1:8080"
+	defaultMailFrom                  = "no-reply@capture.local"
+	defaultRateLimitBackend          = memoryRateLimit
+	defaultRateLimitAuth             = 20
+	defaultRateLimitCapturesWrite    = 600
+	defaultRateLimitListings         = 120
+	defaultWebhookWorkers            = 8
+	defaultWebhookQueueSize          = 10000
+	defaultImportMaxBytes            = 10 << 20
+)
+
+const (
+	postgresStorage = "postgres"
+	memoryStorage   = "memory"
+	fileStorage     = "file"
+)
+
+const (
+	memoryRateLimit   = "memory"
+	postgresRateLimit = "postgres"
+)
 
 type Constants struct {
-	ADDR               string
-	PG                 string
-	JWTSigningKey      string
-	JWTExpirationDelta int
+	ADDR string
+	// Storage could be postgres, using the PG database, memory, keeping the data in the
+	// instance until it stops, or file, keeping the users, repositories and captures in
+	// journals at StoragePath and the rest in memory. memory and file run without a database.
+	Storage     string
+	StoragePath string
+	PG          string
+	// PostGIS keeps the capture locations as geography points with a spatial index. It needs
+	// the postgis extension available in the PG database.
+	PostGIS bool
+	// CapturePartition could be day, week, month or year, the period of each partition of
+	// the PG captures table by timestamp. When empty the table isn't partitioned.
+	CapturePartition string
+	// RetentionInterval is the seconds between runs of the repositories retention
+	// policies, 0 disables them. The archived captures are kept at RetentionArchivePath.
+	RetentionInterval    int
+	RetentionArchivePath string
+	// RollupInterval is the seconds between runs of the repositories rollup rules, 0
+	// disables them.
+	RollupInterval            int
+	JWTSigningKey             string
+	JWTExpirationDelta        int
+	JWTRefreshExpirationDelta int
+	// JWTSigningMethod could be HS256 signing with JWTSigningKey, or RS256/ES256.
+	JWTSigningMethod string
+	// JWTPrivateKeys are the PEM files of the RS256/ES256 keys, the last one signs.
+	// When empty the keys are generated on start and rotated every JWTKeyRotation seconds.
+	JWTPrivateKeys []string
+	JWTKeyRotation int
+	// ShareSigningKey signs the repository share links. When empty JWTSigningKey is used.
+	ShareSigningKey string
+	// AppURL is the base of the links sent by email.
+	AppURL   string
+	MailFrom string
+	// SMTPAddr is the host:port of the mail server. When empty the emails are kept in memory.
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	// RateLimitBackend could be memory, limiting each instance, or postgres, sharing the
+	// limits between instances. The limits are kept in memory without the postgres Storage.
+	RateLimitBackend string
+	// RateLimitAuth, RateLimitCapturesWrite and RateLimitListings are the requests per
+	// minute allowed to each client in the route group, 0 disables the limit.
+	RateLimitAuth          int
+	RateLimitCapturesWrite int
+	RateLimitListings      int
+	// QuotaRepoCaptures and QuotaRepoBytes are the captures and approximate payload bytes
+	// allowed in each repository, QuotaUserCaptures and QuotaUserBytes in all the
+	// repositories of an user. 0 is unlimited.
+	QuotaRepoCaptures int64
+	QuotaRepoBytes    int64
+	QuotaUserCaptures int64
+	QuotaUserBytes    int64
+	// ImportMaxBytes is the size allowed of the GPX and KML documents imported, 0 is unlimited.
+	ImportMaxBytes int64
+	// WebhookWorkers deliver the webhooks queued up to WebhookQueueSize, the ones beyond
+	// are dropped. The webhooks only reach public addresses and the WebhookAllowedNetworks,
+	// a list of CIDRs or ips, as a local stand-in.
+	WebhookWorkers         int
+	WebhookQueueSize       int
+	WebhookAllowedNetworks []string
+	// AdminEmails are the emails of the administrators. Their users become administrators
+	// when they log in with the email verified.
+	AdminEmails []string
 }
 
 // Source set the configuration source in case you aren't allowed to read a file.
@@ -48,19 +139,61 @@ func New(opts ...func(*configOpts)) (*Config, error) {
 	if err != nil {
 		return &cfg, err
 	}
+	if err := constants.validate(); err != nil {
+		return &cfg, err
+	}
 	cfg.Resources = getResources(&cfg)
 
 	return &cfg, err
 }
 
+// validate checks the backends selected, an unknown one would fall back to other.
+func (c Constants) validate() error {
+	switch c.Storage {
+	case postgresStorage, memoryStorage, fileStorage:
+	default:
+		return errors.Errorf("unknown Storage %q, it could be postgres, memory or file", c.Storage)
+	}
+	switch c.RateLimitBackend {
+	case memoryRateLimit, postgresRateLimit:
+	default:
+		return errors.Errorf("unknown RateLimitBackend %q, it could be memory or postgres", c.RateLimitBackend)
+	}
+	return nil
+}
+
 // OnShutdown is executed as graceful shutdown.
 func (cfg *Config) OnShutdown() {
 	log.Printf("[finalizer:resources] deleting resources")
 	cfg.Resources.Delete()
 }
 
+// StartJobs starts the background jobs, they run until the resources are deleted.
+func (cfg *Config) StartJobs() {
+	for _, name := range []string{"retention-scheduler", "rollup-scheduler"} {
+		cfg.Resources.Get(name)
+	}
+}
+
 func initViper(cfg *configOpts) (Constants, error) {
 	viper.SetDefault("ADDR", defaultAddr)
+	viper.SetDefault("Storage", defaultStorage)
+	viper.SetDefault("StoragePath", defaultStoragePath)
+	viper.SetDefault("CapturePartition", defaultCapturePartition)
+	viper.SetDefault("RetentionInterval", defaultRetentionInterval)
+	viper.SetDefault("RetentionArchivePath", defaultRetentionArchivePath)
+	viper.SetDefault("RollupInterval", defaultRollupInterval)
+	viper.SetDefault("JWTRefreshExpirationDelta", defaultJWTRefreshExpirationDelta)
+	viper.SetDefault("JWTSigningMethod", defaultJWTSigningMethod)
+	viper.SetDefault("AppURL", defaultAppURL)
+	viper.SetDefault("MailFrom", defaultMailFrom)
+	viper.SetDefault("RateLimitBackend", defaultRateLimitBackend)
+	viper.SetDefault("RateLimitAuth", defaultRateLimitAuth)
+	viper.SetDefault("RateLimitCapturesWrite", defaultRateLimitCapturesWrite)
+	viper.SetDefault("RateLimitListings", defaultRateLimitListings)
+	viper.SetDefault("WebhookWorkers", defaultWebhookWorkers)
+	viper.SetDefault("WebhookQueueSize", defaultWebhookQueueSize)
+	viper.SetDefault("ImportMaxBytes", defaultImportMaxBytes)
 
 	var err error
 	if cfg.source != nil {
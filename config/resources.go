@@ -1,25 +1,78 @@
 package config
 
 import (
+	"io"
+	"path/filepath"
 	"time"
 
 	"github.com/go-pg/pg"
 	"github.com/pkg/errors"
 	"github.com/sarulabs/di"
 
+	"github.com/ifreddyrondon/capture/pkg"
 	"github.com/ifreddyrondon/capture/pkg/adding"
+	"github.com/ifreddyrondon/capture/pkg/administering"
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
+	"github.com/ifreddyrondon/capture/pkg/auditing"
 	"github.com/ifreddyrondon/capture/pkg/authenticating"
 	"github.com/ifreddyrondon/capture/pkg/authorizing"
+	"github.com/ifreddyrondon/capture/pkg/collaborating"
 	"github.com/ifreddyrondon/capture/pkg/creating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/exporting"
+	"github.com/ifreddyrondon/capture/pkg/geofencing"
 	"github.com/ifreddyrondon/capture/pkg/getting"
+	"github.com/ifreddyrondon/capture/pkg/importing"
+	"github.com/ifreddyrondon/capture/pkg/limiting"
 	"github.com/ifreddyrondon/capture/pkg/listing"
+	"github.com/ifreddyrondon/capture/pkg/mailing"
+	"github.com/ifreddyrondon/capture/pkg/organizing"
+	"github.com/ifreddyrondon/capture/pkg/quotas"
 	"github.com/ifreddyrondon/capture/pkg/removing"
+	"github.com/ifreddyrondon/capture/pkg/retention"
+	"github.com/ifreddyrondon/capture/pkg/rollups"
+	"github.com/ifreddyrondon/capture/pkg/sharing"
 	"github.com/ifreddyrondon/capture/pkg/signup"
+	filecapture "github.com/ifreddyrondon/capture/pkg/storage/file/capture"
+	filerepo "github.com/ifreddyrondon/capture/pkg/storage/file/repo"
+	fileuser "github.com/ifreddyrondon/capture/pkg/storage/file/user"
+	memapikey "github.com/ifreddyrondon/capture/pkg/storage/memory/apikey"
+	memaudit "github.com/ifreddyrondon/capture/pkg/storage/memory/audit"
+	memcapture "github.com/ifreddyrondon/capture/pkg/storage/memory/capture"
+	memcollaborator "github.com/ifreddyrondon/capture/pkg/storage/memory/collaborator"
+	memgeofence "github.com/ifreddyrondon/capture/pkg/storage/memory/geofence"
+	memoauth "github.com/ifreddyrondon/capture/pkg/storage/memory/oauth"
+	memorganization "github.com/ifreddyrondon/capture/pkg/storage/memory/organization"
+	memrepo "github.com/ifreddyrondon/capture/pkg/storage/memory/repo"
+	memretention "github.com/ifreddyrondon/capture/pkg/storage/memory/retention"
+	memrollup "github.com/ifreddyrondon/capture/pkg/storage/memory/rollup"
+	memsession "github.com/ifreddyrondon/capture/pkg/storage/memory/session"
+	memsharelink "github.com/ifreddyrondon/capture/pkg/storage/memory/sharelink"
+	memusage "github.com/ifreddyrondon/capture/pkg/storage/memory/usage"
+	memuser "github.com/ifreddyrondon/capture/pkg/storage/memory/user"
+	memwebhook "github.com/ifreddyrondon/capture/pkg/storage/memory/webhook"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/apikey"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/audit"
 	"github.com/ifreddyrondon/capture/pkg/storage/postgres/capture"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/collaborator"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/geofence"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/oauth"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/organization"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/ratelimit"
 	"github.com/ifreddyrondon/capture/pkg/storage/postgres/repo"
+	pgretention "github.com/ifreddyrondon/capture/pkg/storage/postgres/retention"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/rollup"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/session"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/sharelink"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/usage"
 	"github.com/ifreddyrondon/capture/pkg/storage/postgres/user"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/webhook"
+	"github.com/ifreddyrondon/capture/pkg/streaming"
 	"github.com/ifreddyrondon/capture/pkg/token"
+	"github.com/ifreddyrondon/capture/pkg/twofactor"
 	"github.com/ifreddyrondon/capture/pkg/updating"
+	"github.com/ifreddyrondon/capture/pkg/verifying"
+	"github.com/ifreddyrondon/capture/pkg/webhooks"
 )
 
 func getResources(cfg *Config) di.Container {
@@ -45,6 +98,12 @@ func getResources(cfg *Config) di.Container {
 		{
 			Name: "user-storage",
 			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == fileStorage {
+					return fileuser.NewFileStorage(filepath.Join(cfg.StoragePath, "users.journal"))
+				}
+				if cfg.Storage == memoryStorage {
+					return memuser.NewMemStorage(), nil
+				}
 				database := cfg.Resources.Get("database").(*pg.DB)
 				s := user.NewPGStorage(database)
 				if err := s.Drop(); err != nil {
@@ -55,19 +114,114 @@ func getResources(cfg *Config) di.Container {
 				}
 				return s, nil
 			},
+			Close: func(obj interface{}) error {
+				if c, ok := obj.(io.Closer); ok {
+					return c.Close()
+				}
+				return nil
+			},
 		},
 		{
 			Name: "sign_up-service",
 			Build: func(ctn di.Container) (interface{}, error) {
 				store := cfg.Resources.Get("user-storage").(signup.Store)
-				return signup.NewService(store), nil
+				verifier := cfg.Resources.Get("verifying-service").(signup.Verifier)
+				return signup.NewService(store, verifier), nil
+			},
+		},
+		{
+			Name: "updating-user-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("user-storage").(updating.UserStore)
+				sessions := cfg.Resources.Get("session-storage").(updating.SessionStore)
+				verifier := cfg.Resources.Get("verifying-service").(updating.EmailVerifier)
+				return updating.NewUserService(store, sessions, verifier), nil
+			},
+		},
+		{
+			Name: "removing-user-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("user-storage").(removing.UserStore)
+				repoStore := cfg.Resources.Get("repository-storage").(removing.UserRepoStore)
+				sessions := cfg.Resources.Get("session-storage").(removing.SessionStore)
+				return removing.NewUserService(store, repoStore, sessions), nil
+			},
+		},
+		{
+			Name: "mailer",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.SMTPAddr == "" {
+					return mailing.NewMemoryMailer(), nil
+				}
+				return mailing.NewSMTPMailer(cfg.SMTPAddr, cfg.MailFrom, cfg.SMTPUsername, cfg.SMTPPassword), nil
+			},
+		},
+		{
+			Name: "verifying-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				userStore := cfg.Resources.Get("user-storage").(verifying.UserStore)
+				tokenStore := cfg.Resources.Get("session-storage").(verifying.TokenStore)
+				mailer := cfg.Resources.Get("mailer").(mailing.Mailer)
+				return verifying.NewService(userStore, tokenStore, mailer, cfg.AppURL), nil
+			},
+		},
+		{
+			Name: "session-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memsession.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := session.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for session-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for session-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "jwt-keys",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.JWTSigningMethod == defaultJWTSigningMethod {
+					return token.NewKeySet(token.NewHMACKey("", []byte(cfg.JWTSigningKey)))
+				}
+				if len(cfg.JWTPrivateKeys) > 0 {
+					keys, err := token.LoadKeySet(cfg.JWTPrivateKeys...)
+					if err != nil {
+						return nil, errors.Wrap(err, "di loading jwt-keys")
+					}
+					return keys, nil
+				}
+				keys, err := token.GenerateKeySet(cfg.JWTSigningMethod)
+				if err != nil {
+					return nil, errors.Wrap(err, "di generating jwt-keys")
+				}
+				if cfg.JWTKeyRotation > 0 {
+					// retired keys are kept while the tokens they signed are valid.
+					interval := time.Duration(cfg.JWTKeyRotation) * time.Second
+					retention := time.Duration(cfg.JWTExpirationDelta) * time.Second
+					if retention == 0 {
+						retention = token.DefaultJWTExpirationDelta
+					}
+					keys.StartRotation(cfg.JWTSigningMethod, interval, retention)
+				}
+				return keys, nil
+			},
+			Close: func(obj interface{}) error {
+				obj.(*token.KeySet).Stop()
+				return nil
 			},
 		},
 		{
 			Name: "jwt-service",
 			Build: func(ctn di.Container) (interface{}, error) {
 				duration := time.Duration(cfg.JWTExpirationDelta) * time.Second
-				return token.NewJWTService(cfg.JWTSigningKey, duration), nil
+				keys := cfg.Resources.Get("jwt-keys").(*token.KeySet)
+				denylist := cfg.Resources.Get("session-storage").(token.Denylist)
+				return token.NewJWTServiceWithKeys(keys, duration, denylist), nil
 			},
 		},
 		{
@@ -75,7 +229,87 @@ func getResources(cfg *Config) di.Container {
 			Build: func(ctn di.Container) (interface{}, error) {
 				tokenService := cfg.Resources.Get("jwt-service").(authenticating.TokenService)
 				store := cfg.Resources.Get("user-storage").(authenticating.Store)
-				return authenticating.NewService(tokenService, store), nil
+				throttles := cfg.Resources.Get("session-storage").(authenticating.ThrottleStore)
+				challenges := cfg.Resources.Get("session-storage").(authenticating.ChallengeStore)
+				secondFactor := cfg.Resources.Get("two_factor-service").(authenticating.SecondFactor)
+				return authenticating.NewService(tokenService, store, throttles, challenges, secondFactor, cfg.AdminEmails...), nil
+			},
+		},
+		{
+			Name: "two_factor-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				userStore := cfg.Resources.Get("user-storage").(twofactor.UserStore)
+				recoveryStore := cfg.Resources.Get("session-storage").(twofactor.RecoveryStore)
+				return twofactor.NewService(userStore, recoveryStore), nil
+			},
+		},
+		{
+			Name: "oauth-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memoauth.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := oauth.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for oauth-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for oauth-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "oauth-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				tokenService := cfg.Resources.Get("jwt-service").(authenticating.ClientTokenService)
+				store := cfg.Resources.Get("oauth-storage").(authenticating.OAuthStore)
+				return authenticating.NewOAuthService(tokenService, store), nil
+			},
+		},
+		{
+			Name: "rate-limits",
+			Build: func(ctn di.Container) (interface{}, error) {
+				return limiting.Limits{
+					Auth:          domain.RateLimit{Requests: cfg.RateLimitAuth, Period: time.Minute},
+					CapturesWrite: domain.RateLimit{Requests: cfg.RateLimitCapturesWrite, Period: time.Minute},
+					Listings:      domain.RateLimit{Requests: cfg.RateLimitListings, Period: time.Minute},
+				}, nil
+			},
+		},
+		{
+			Name: "ratelimit-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.RateLimitBackend != postgresRateLimit || cfg.Storage != postgresStorage {
+					return limiting.NewMemoryStore(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := ratelimit.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for ratelimit-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for ratelimit-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "limiting-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("ratelimit-storage").(limiting.Store)
+				limits := cfg.Resources.Get("rate-limits").(limiting.Limits)
+				return limiting.NewService(store, limits.MaxPeriod()), nil
+			},
+		},
+		{
+			Name: "refresh-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				tokenService := cfg.Resources.Get("jwt-service").(authenticating.RefreshTokenService)
+				store := cfg.Resources.Get("session-storage").(authenticating.RefreshStore)
+				duration := time.Duration(cfg.JWTRefreshExpirationDelta) * time.Second
+				return authenticating.NewRefreshService(tokenService, store, duration), nil
 			},
 		},
 		{
@@ -83,12 +317,20 @@ func getResources(cfg *Config) di.Container {
 			Build: func(ctn di.Container) (interface{}, error) {
 				tokenService := cfg.Resources.Get("jwt-service").(authorizing.TokenService)
 				store := cfg.Resources.Get("user-storage").(authorizing.Store)
-				return authorizing.NewService(tokenService, store), nil
+				keyStore := cfg.Resources.Get("apikey-storage").(authorizing.KeyStore)
+				return authorizing.NewService(tokenService, store, keyStore), nil
 			},
 		},
 		{
 			Name: "repository-storage",
 			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == fileStorage {
+					usages := cfg.Resources.Get("usage-storage").(*memusage.MemStorage)
+					return filerepo.NewFileStorage(filepath.Join(cfg.StoragePath, "repositories.journal"), usages)
+				}
+				if cfg.Storage == memoryStorage {
+					return memrepo.NewMemStorage(cfg.Resources.Get("usage-storage").(*memusage.MemStorage)), nil
+				}
 				database := cfg.Resources.Get("database").(*pg.DB)
 				s := repo.NewPGStorage(database)
 				if err := s.Drop(); err != nil {
@@ -99,6 +341,12 @@ func getResources(cfg *Config) di.Container {
 				}
 				return s, nil
 			},
+			Close: func(obj interface{}) error {
+				if c, ok := obj.(io.Closer); ok {
+					return c.Close()
+				}
+				return nil
+			},
 		},
 		{
 			Name: "creating-repo-service",
@@ -121,11 +369,32 @@ func getResources(cfg *Config) di.Container {
 				return getting.NewRepoService(store), nil
 			},
 		},
+		{
+			Name: "updating-repo-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("repository-storage").(updating.RepoStore)
+				publisher := cfg.Resources.Get("event-publisher").(updating.Publisher)
+				return updating.NewRepoService(store, publisher), nil
+			},
+		},
 		{
 			Name: "capture-storage",
 			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == fileStorage {
+					return filecapture.NewFileStorage(filepath.Join(cfg.StoragePath, "captures.journal"))
+				}
+				if cfg.Storage == memoryStorage {
+					return memcapture.NewMemStorage(), nil
+				}
 				database := cfg.Resources.Get("database").(*pg.DB)
-				s := capture.NewPGStorage(database)
+				var opts []capture.Option
+				if cfg.PostGIS {
+					opts = append(opts, capture.PostGIS())
+				}
+				if cfg.CapturePartition != "" {
+					opts = append(opts, capture.Partitioned(capture.Partition(cfg.CapturePartition)))
+				}
+				s := capture.NewPGStorage(database, opts...)
 				if err := s.Drop(); err != nil {
 					return nil, errors.Wrap(err, "di dropping schema for capture-storage")
 				}
@@ -134,19 +403,369 @@ func getResources(cfg *Config) di.Container {
 				}
 				return s, nil
 			},
+			Close: func(obj interface{}) error {
+				if c, ok := obj.(io.Closer); ok {
+					return c.Close()
+				}
+				return nil
+			},
+		},
+		{
+			Name: "usage-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == fileStorage {
+					// the usage is rebuilt from the captures, the repository storage gives it
+					// to their owners when it's opened.
+					s := memusage.NewMemStorage()
+					s.LoadUsage(cfg.Resources.Get("capture-storage").(*filecapture.FileStorage).Usages()...)
+					return s, nil
+				}
+				if cfg.Storage == memoryStorage {
+					return memusage.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := usage.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for usage-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for usage-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "quotas-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("usage-storage").(quotas.Store)
+				q := quotas.Quotas{
+					Repository: domain.Quota{Captures: cfg.QuotaRepoCaptures, Bytes: cfg.QuotaRepoBytes},
+					User:       domain.Quota{Captures: cfg.QuotaUserCaptures, Bytes: cfg.QuotaUserBytes},
+				}
+				return quotas.NewService(store, q), nil
+			},
+		},
+		{
+			Name: "geofence-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memgeofence.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := geofence.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for geofence-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for geofence-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "geofencing-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("geofence-storage").(geofencing.Store)
+				return geofencing.NewService(store), nil
+			},
+		},
+		{
+			Name: "geofence-matcher",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("geofence-storage").(geofencing.MatcherStore)
+				return geofencing.NewMatcher(store), nil
+			},
+		},
+		{
+			Name: "streaming-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				return streaming.NewBroker(streaming.DefaultHistorySize), nil
+			},
+		},
+		{
+			Name: "retention-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memretention.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := pgretention.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for retention-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for retention-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "retention-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("retention-storage").(retention.Store)
+				captureStore := cfg.Resources.Get("capture-storage").(retention.CaptureStore)
+				archiver := retention.NewFileArchiver(cfg.RetentionArchivePath)
+				quota := cfg.Resources.Get("quotas-service").(retention.Quota)
+				return retention.NewService(store, captureStore, archiver, quota), nil
+			},
+		},
+		{
+			Name: "retention-scheduler",
+			Build: func(ctn di.Container) (interface{}, error) {
+				service := cfg.Resources.Get("retention-service").(retention.Service)
+				scheduler := retention.NewScheduler(service)
+				if cfg.RetentionInterval > 0 {
+					scheduler.Start(time.Duration(cfg.RetentionInterval) * time.Second)
+				}
+				return scheduler, nil
+			},
+			Close: func(obj interface{}) error {
+				obj.(*retention.Scheduler).Stop()
+				return nil
+			},
+		},
+		{
+			Name: "rollup-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memrollup.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := rollup.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for rollup-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for rollup-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "rollups-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("rollup-storage").(rollups.Store)
+				captureStore := cfg.Resources.Get("capture-storage").(rollups.CaptureStore)
+				repoStore := cfg.Resources.Get("repository-storage").(rollups.RepoStore)
+				quota := cfg.Resources.Get("quotas-service").(rollups.Quota)
+				return rollups.NewService(store, captureStore, repoStore, quota), nil
+			},
+		},
+		{
+			Name: "rollup-scheduler",
+			Build: func(ctn di.Container) (interface{}, error) {
+				service := cfg.Resources.Get("rollups-service").(rollups.Service)
+				scheduler := rollups.NewScheduler(service)
+				if cfg.RollupInterval > 0 {
+					scheduler.Start(time.Duration(cfg.RollupInterval) * time.Second)
+				}
+				return scheduler, nil
+			},
+			Close: func(obj interface{}) error {
+				obj.(*rollups.Scheduler).Stop()
+				return nil
+			},
+		},
+		{
+			Name: "webhook-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memwebhook.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := webhook.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for webhook-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for webhook-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "webhooks-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("webhook-storage").(webhooks.Store)
+				return webhooks.NewService(store), nil
+			},
+		},
+		{
+			Name: "apikey-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memapikey.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := apikey.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for apikey-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for apikey-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "apikeys-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("apikey-storage").(apikeys.Store)
+				return apikeys.NewService(store), nil
+			},
+		},
+		{
+			Name: "sharelink-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memsharelink.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := sharelink.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for sharelink-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for sharelink-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "sharing-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("sharelink-storage").(sharing.Store)
+				secret := cfg.ShareSigningKey
+				if secret == "" {
+					secret = cfg.JWTSigningKey
+				}
+				return sharing.NewService(store, []byte(secret), cfg.AppURL), nil
+			},
+		},
+		{
+			Name: "collaborator-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memcollaborator.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := collaborator.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for collaborator-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for collaborator-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "collaborating-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				userStore := cfg.Resources.Get("user-storage").(collaborating.UserStore)
+				store := cfg.Resources.Get("collaborator-storage").(collaborating.Store)
+				return collaborating.NewService(userStore, store), nil
+			},
+		},
+		{
+			Name: "organization-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memorganization.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := organization.NewPGStorage(database)
+				if err := s.Drop(); err != nil {
+					return nil, errors.Wrap(err, "di dropping schema for organization-storage")
+				}
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for organization-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "organizing-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				userStore := cfg.Resources.Get("user-storage").(organizing.UserStore)
+				store := cfg.Resources.Get("organization-storage").(organizing.Store)
+				return organizing.NewService(userStore, store), nil
+			},
+		},
+		{
+			Name: "audit-storage",
+			Build: func(ctn di.Container) (interface{}, error) {
+				if cfg.Storage == memoryStorage || cfg.Storage == fileStorage {
+					return memaudit.NewMemStorage(), nil
+				}
+				database := cfg.Resources.Get("database").(*pg.DB)
+				s := audit.NewPGStorage(database)
+				// the audit trail is kept across restarts.
+				if err := s.CreateSchema(); err != nil {
+					return nil, errors.Wrap(err, "di creating schema for audit-storage")
+				}
+				return s, nil
+			},
+		},
+		{
+			Name: "administering-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				userStore := cfg.Resources.Get("user-storage").(administering.UserStore)
+				repoStore := cfg.Resources.Get("repository-storage").(administering.RepoStore)
+				auditStore := cfg.Resources.Get("audit-storage").(administering.AuditStore)
+				tokenService := cfg.Resources.Get("jwt-service").(administering.TokenService)
+				return administering.NewService(userStore, repoStore, auditStore, tokenService), nil
+			},
+		},
+		{
+			Name: "auditing-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("audit-storage").(auditing.Store)
+				return auditing.NewService(store), nil
+			},
+		},
+		{
+			Name: "webhook-dispatcher",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("webhook-storage").(webhooks.DispatcherStore)
+				allowed, err := webhooks.ParseNetworks(cfg.WebhookAllowedNetworks...)
+				if err != nil {
+					return nil, errors.Wrap(err, "di parsing webhook allowed networks")
+				}
+				d := webhooks.NewDispatcher(store, cfg.WebhookWorkers, cfg.WebhookQueueSize)
+				d.Client = webhooks.NewClient(allowed...)
+				return d, nil
+			},
+			Close: func(obj interface{}) error {
+				return obj.(*webhooks.Dispatcher).Close()
+			},
+		},
+		{
+			Name: "event-publisher",
+			Build: func(ctn di.Container) (interface{}, error) {
+				broker := cfg.Resources.Get("streaming-service").(pkg.Publisher)
+				dispatcher := cfg.Resources.Get("webhook-dispatcher").(pkg.Publisher)
+				return pkg.Publishers{broker, dispatcher}, nil
+			},
 		},
 		{
 			Name: "adding-capture-service",
 			Build: func(ctn di.Container) (interface{}, error) {
 				store := cfg.Resources.Get("capture-storage").(adding.CaptureStore)
-				return adding.NewCaptureService(store), nil
+				geofencer := cfg.Resources.Get("geofence-matcher").(adding.Geofencer)
+				publisher := cfg.Resources.Get("event-publisher").(adding.Publisher)
+				quota := cfg.Resources.Get("quotas-service").(adding.Quota)
+				return adding.NewCaptureService(store, geofencer, publisher, quota), nil
 			},
 		},
 		{
 			Name: "adding-multi-capture-service",
 			Build: func(ctn di.Container) (interface{}, error) {
 				store := cfg.Resources.Get("capture-storage").(adding.MultiCaptureStore)
-				return adding.NewMultiCaptureService(store), nil
+				geofencer := cfg.Resources.Get("geofence-matcher").(adding.Geofencer)
+				publisher := cfg.Resources.Get("event-publisher").(adding.Publisher)
+				quota := cfg.Resources.Get("quotas-service").(adding.Quota)
+				return adding.NewMultiCaptureService(store, geofencer, publisher, quota), nil
 			},
 		},
 		{
@@ -167,14 +786,38 @@ func getResources(cfg *Config) di.Container {
 			Name: "removing-capture-service",
 			Build: func(ctn di.Container) (interface{}, error) {
 				store := cfg.Resources.Get("capture-storage").(removing.CaptureStore)
-				return removing.NewCaptureService(store), nil
+				publisher := cfg.Resources.Get("event-publisher").(removing.Publisher)
+				quota := cfg.Resources.Get("quotas-service").(removing.Quota)
+				return removing.NewCaptureService(store, publisher, quota), nil
 			},
 		},
 		{
 			Name: "updating-capture-service",
 			Build: func(ctn di.Container) (interface{}, error) {
 				store := cfg.Resources.Get("capture-storage").(updating.CaptureStore)
-				return updating.NewCaptureService(store), nil
+				publisher := cfg.Resources.Get("event-publisher").(updating.Publisher)
+				quota := cfg.Resources.Get("quotas-service").(updating.Quota)
+				return updating.NewCaptureService(store, publisher, quota), nil
+			},
+		},
+		{
+			Name: "import-max-bytes",
+			Build: func(ctn di.Container) (interface{}, error) {
+				return cfg.ImportMaxBytes, nil
+			},
+		},
+		{
+			Name: "importing-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				service := cfg.Resources.Get("adding-multi-capture-service").(adding.MultiCaptureService)
+				return importing.NewService(service), nil
+			},
+		},
+		{
+			Name: "exporting-service",
+			Build: func(ctn di.Container) (interface{}, error) {
+				store := cfg.Resources.Get("capture-storage").(exporting.CaptureStore)
+				return exporting.NewService(store), nil
 			},
 		},
 	}
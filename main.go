@@ -19,6 +19,7 @@ func main() {
 
 	app := bastion.New()
 	app.Mount("/", rest.Router(cfg.Resources))
+	cfg.StartJobs()
 	app.RegisterOnShutdown(cfg.OnShutdown)
 	fmt.Fprintln(os.Stderr, app.Serve(cfg.ADDR))
 }
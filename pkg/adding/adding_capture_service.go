@@ -1,6 +1,8 @@
 package adding
 
 import (
+	"fmt"
+	"os"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,6 +17,28 @@ type CaptureStore interface {
 	CreateCapture(*domain.Capture) error
 }
 
+// Geofencer evaluates new captures against the repository geofences.
+type Geofencer interface {
+	// Match tags the captures located inside a geofence and returns the enter and exit events.
+	Match(*domain.Repository, ...*domain.Capture) ([]domain.GeofenceEvent, error)
+	// Record stores the enter and exit events once the captures were added.
+	Record(...domain.GeofenceEvent) error
+}
+
+// Publisher notifies the changes of the repositories captures.
+type Publisher interface {
+	// Publish sends the events to the interested subscribers.
+	Publish(...domain.Event)
+}
+
+// Quota accounts the new captures against the storage quotas.
+type Quota interface {
+	// Reserve accounts new captures of a repository, or returns a quota exceeded error.
+	Reserve(*domain.Repository, ...domain.Capture) error
+	// Release frees the usage of reserved captures not stored.
+	Release(...domain.Capture) error
+}
+
 // CaptureService provides adding operations.
 type CaptureService interface {
 	// AddCapture add a new capture to a repository
@@ -23,19 +47,37 @@ type CaptureService interface {
 
 type captureService struct {
 	s     CaptureStore
+	g     Geofencer
+	p     Publisher
+	q     Quota
 	clock *pkg.Clock
 }
 
 // NewCaptureService creates an adding service with the necessary dependencies
-func NewCaptureService(s CaptureStore) CaptureService {
-	return &captureService{s: s}
+func NewCaptureService(s CaptureStore, g Geofencer, p Publisher, q Quota) CaptureService {
+	return &captureService{s: s, g: g, p: p, q: q}
 }
 
 func (s *captureService) AddCapture(r *domain.Repository, c Capture) (*domain.Capture, error) {
 	capt := getDomainCapture(s.clock, r, c)
+	events, err := s.g.Match(r, capt)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not match capture geofences")
+	}
+	if err := s.q.Reserve(r, *capt); err != nil {
+		return nil, err
+	}
 	if err := s.s.CreateCapture(capt); err != nil {
+		if releaseErr := s.q.Release(*capt); releaseErr != nil {
+			fmt.Fprintln(os.Stderr, releaseErr)
+		}
 		return nil, errors.Wrap(err, "could not add capture")
 	}
+	// the capture is already stored, so a failure is only logged to keep retries from duplicating it.
+	if err := s.g.Record(events...); err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrap(err, "could not record capture geofence events"))
+	}
+	s.p.Publish(domain.NewCaptureEvent(domain.CaptureCreated, *capt))
 	return capt, nil
 }
 
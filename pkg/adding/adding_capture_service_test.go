@@ -25,6 +25,53 @@ type mockCaptureStore struct {
 
 func (m *mockCaptureStore) CreateCapture(*domain.Capture) error { return m.err }
 
+type mockGeofencer struct {
+	tag       string
+	events    []domain.GeofenceEvent
+	recorded  []domain.GeofenceEvent
+	matchErr  error
+	recordErr error
+}
+
+func (m *mockGeofencer) Match(r *domain.Repository, captures ...*domain.Capture) ([]domain.GeofenceEvent, error) {
+	if m.tag != "" {
+		for _, c := range captures {
+			c.Tags = append(c.Tags, m.tag)
+		}
+	}
+	return m.events, m.matchErr
+}
+
+func (m *mockGeofencer) Record(events ...domain.GeofenceEvent) error {
+	m.recorded = events
+	return m.recordErr
+}
+
+type mockPublisher struct {
+	published []domain.Event
+}
+
+func (m *mockPublisher) Publish(events ...domain.Event) { m.published = append(m.published, events...) }
+
+type mockQuota struct {
+	reserved   []domain.Capture
+	released   []domain.Capture
+	reserveErr error
+}
+
+func (m *mockQuota) Reserve(_ *domain.Repository, captures ...domain.Capture) error {
+	if m.reserveErr != nil {
+		return m.reserveErr
+	}
+	m.reserved = append(m.reserved, captures...)
+	return nil
+}
+
+func (m *mockQuota) Release(captures ...domain.Capture) error {
+	m.released = append(m.released, captures...)
+	return nil
+}
+
 func TestServiceAddCaptureOKWithDefaultTimestamp(t *testing.T) {
 	t.Parallel()
 
@@ -89,7 +136,7 @@ func TestServiceAddCaptureOKWithDefaultTimestamp(t *testing.T) {
 	}
 
 	repo := &domain.Repository{ID: kallax.NewULID()}
-	s := adding.NewCaptureService(&mockCaptureStore{})
+	s := adding.NewCaptureService(&mockCaptureStore{}, &mockGeofencer{}, &mockPublisher{}, &mockQuota{})
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
@@ -146,7 +193,7 @@ func TestServiceAddCaptureOKWithTimestamp(t *testing.T) {
 	}
 
 	repo := &domain.Repository{ID: kallax.NewULID()}
-	s := adding.NewCaptureService(&mockCaptureStore{})
+	s := adding.NewCaptureService(&mockCaptureStore{}, &mockGeofencer{}, &mockPublisher{}, &mockQuota{})
 
 	capt, err := s.AddCapture(repo, payl)
 	assert.Nil(t, err)
@@ -163,7 +210,123 @@ func TestServiceAddCaptureOKWithTimestamp(t *testing.T) {
 
 func TestServiceAddCaptureErrWhenSaving(t *testing.T) {
 	t.Parallel()
-	s := adding.NewCaptureService(&mockCaptureStore{err: errors.New("test")})
+	s := adding.NewCaptureService(&mockCaptureStore{err: errors.New("test")}, &mockGeofencer{}, &mockPublisher{}, &mockQuota{})
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.Capture{
+		Payload: validator.Payload{
+			Payload: []domain.Metric{
+				{Name: "power", Value: 10.0},
+			},
+		},
+	}
+
+	_, err := s.AddCapture(repo, payl)
+	assert.EqualError(t, err, "could not add capture: test")
+}
+
+func TestServiceAddCaptureWithGeofences(t *testing.T) {
+	t.Parallel()
+
+	events := []domain.GeofenceEvent{{ID: kallax.NewULID(), Type: domain.Enter}}
+	g := &mockGeofencer{tag: "restricted", events: events}
+	s := adding.NewCaptureService(&mockCaptureStore{}, g, &mockPublisher{}, &mockQuota{})
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.Capture{
+		Payload: validator.Payload{
+			Payload: []domain.Metric{
+				{Name: "power", Value: 10.0},
+			},
+		},
+		Location: &validator.GeoLocation{LAT: f2P(1), LNG: f2P(1)},
+	}
+
+	capt, err := s.AddCapture(repo, payl)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"restricted"}, capt.Tags)
+	assert.Equal(t, events, g.recorded)
+}
+
+func TestServiceAddCaptureWhenRecordingGeofenceEventsFails(t *testing.T) {
+	t.Parallel()
+
+	p := &mockPublisher{}
+	g := &mockGeofencer{recordErr: errors.New("test")}
+	s := adding.NewCaptureService(&mockCaptureStore{}, g, p, &mockQuota{})
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.Capture{
+		Payload: validator.Payload{
+			Payload: []domain.Metric{
+				{Name: "power", Value: 10.0},
+			},
+		},
+	}
+
+	capt, err := s.AddCapture(repo, payl)
+	assert.Nil(t, err)
+	assert.NotNil(t, capt)
+	assert.Len(t, p.published, 1)
+}
+
+func TestServiceAddCaptureErrWithGeofences(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		g    *mockGeofencer
+		err  string
+	}{
+		{"when matching", &mockGeofencer{matchErr: errors.New("test")}, "could not match capture geofences: test"},
+	}
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.Capture{
+		Payload: validator.Payload{
+			Payload: []domain.Metric{
+				{Name: "power", Value: 10.0},
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := adding.NewCaptureService(&mockCaptureStore{}, tc.g, &mockPublisher{}, &mockQuota{})
+			_, err := s.AddCapture(repo, payl)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestServiceAddCapturePublishEvent(t *testing.T) {
+	t.Parallel()
+
+	p := &mockPublisher{}
+	s := adding.NewCaptureService(&mockCaptureStore{}, &mockGeofencer{}, p, &mockQuota{})
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.Capture{
+		Payload: validator.Payload{
+			Payload: []domain.Metric{
+				{Name: "power", Value: 10.0},
+			},
+		},
+	}
+
+	capt, err := s.AddCapture(repo, payl)
+	assert.Nil(t, err)
+	assert.Len(t, p.published, 1)
+	assert.Equal(t, domain.CaptureCreated, p.published[0].Type)
+	assert.Equal(t, repo.ID, p.published[0].RepositoryID)
+	assert.Equal(t, capt.ID, p.published[0].Capture.ID)
+}
+
+func TestServiceAddCaptureNotPublishWhenErr(t *testing.T) {
+	t.Parallel()
+
+	p := &mockPublisher{}
+	s := adding.NewCaptureService(&mockCaptureStore{err: errors.New("test")}, &mockGeofencer{}, p, &mockQuota{})
 
 	repo := &domain.Repository{ID: kallax.NewULID()}
 	payl := adding.Capture{
@@ -175,5 +338,37 @@ func TestServiceAddCaptureErrWhenSaving(t *testing.T) {
 	}
 
 	_, err := s.AddCapture(repo, payl)
+	assert.NotNil(t, err)
+	assert.Len(t, p.published, 0)
+}
+
+func TestServiceAddCaptureQuota(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.Capture{
+		Payload: validator.Payload{
+			Payload: []domain.Metric{{Name: "power", Value: 10.0}},
+		},
+	}
+
+	q := &mockQuota{}
+	s := adding.NewCaptureService(&mockCaptureStore{}, &mockGeofencer{}, &mockPublisher{}, q)
+	capt, err := s.AddCapture(repo, payl)
+	assert.Nil(t, err)
+	assert.Len(t, q.reserved, 1)
+	assert.Equal(t, capt.ID, q.reserved[0].ID)
+	assert.Empty(t, q.released)
+
+	p := &mockPublisher{}
+	s = adding.NewCaptureService(&mockCaptureStore{}, &mockGeofencer{}, p, &mockQuota{reserveErr: errors.New("quota exceeded")})
+	_, err = s.AddCapture(repo, payl)
+	assert.EqualError(t, err, "quota exceeded")
+	assert.Empty(t, p.published)
+
+	q = &mockQuota{}
+	s = adding.NewCaptureService(&mockCaptureStore{err: errors.New("test")}, &mockGeofencer{}, &mockPublisher{}, q)
+	_, err = s.AddCapture(repo, payl)
 	assert.EqualError(t, err, "could not add capture: test")
+	assert.Len(t, q.released, 1)
 }
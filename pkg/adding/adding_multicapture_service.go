@@ -1,6 +1,9 @@
 package adding
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/pkg/errors"
 
 	"github.com/ifreddyrondon/capture/pkg"
@@ -20,19 +23,45 @@ type MultiCaptureService interface {
 
 type multiCaptureService struct {
 	s     MultiCaptureStore
+	g     Geofencer
+	p     Publisher
+	q     Quota
 	clock *pkg.Clock
 }
 
 // NewMultiCaptureService creates an adding service with the necessary dependencies to add captures.
-func NewMultiCaptureService(s MultiCaptureStore) MultiCaptureService {
-	return &multiCaptureService{s: s}
+func NewMultiCaptureService(s MultiCaptureStore, g Geofencer, p Publisher, q Quota) MultiCaptureService {
+	return &multiCaptureService{s: s, g: g, p: p, q: q}
 }
 
 func (s *multiCaptureService) AddCaptures(r *domain.Repository, multiCapture MultiCapture) ([]domain.Capture, error) {
 	captures := getDomainCaptures(s.clock, r, multiCapture.CapturesOK)
+	refs := make([]*domain.Capture, len(captures))
+	for i := range captures {
+		refs[i] = &captures[i]
+	}
+	fenceEvents, err := s.g.Match(r, refs...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not match captures geofences")
+	}
+	if err := s.q.Reserve(r, captures...); err != nil {
+		return nil, err
+	}
 	if err := s.s.CreateCaptures(captures...); err != nil {
+		if releaseErr := s.q.Release(captures...); releaseErr != nil {
+			fmt.Fprintln(os.Stderr, releaseErr)
+		}
 		return nil, errors.Wrap(err, "could not add captures")
 	}
+	// the captures are already stored, so a failure is only logged to keep retries from duplicating them.
+	if err := s.g.Record(fenceEvents...); err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrap(err, "could not record captures geofence events"))
+	}
+	events := make([]domain.Event, len(captures))
+	for i, c := range captures {
+		events[i] = domain.NewCaptureEvent(domain.CaptureCreated, c)
+	}
+	s.p.Publish(events...)
 	return captures, nil
 }
 
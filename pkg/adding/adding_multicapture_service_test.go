@@ -63,7 +63,7 @@ func TestServiceAddMultiCaptureOK(t *testing.T) {
 	}
 
 	repo := &domain.Repository{ID: kallax.NewULID()}
-	s := adding.NewMultiCaptureService(&mockMultiCaptureStore{})
+	s := adding.NewMultiCaptureService(&mockMultiCaptureStore{}, &mockGeofencer{}, &mockPublisher{}, &mockQuota{})
 
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
@@ -88,7 +88,7 @@ func TestServiceAddMultiCaptureOK(t *testing.T) {
 
 func TestServiceAddMultiCaptureErrWhenSaving(t *testing.T) {
 	t.Parallel()
-	s := adding.NewMultiCaptureService(&mockMultiCaptureStore{err: errors.New("test")})
+	s := adding.NewMultiCaptureService(&mockMultiCaptureStore{err: errors.New("test")}, &mockGeofencer{}, &mockPublisher{}, &mockQuota{})
 
 	repo := &domain.Repository{ID: kallax.NewULID()}
 	payl := adding.MultiCapture{
@@ -104,3 +104,124 @@ func TestServiceAddMultiCaptureErrWhenSaving(t *testing.T) {
 	_, err := s.AddCaptures(repo, payl)
 	assert.EqualError(t, err, "could not add captures: test")
 }
+
+func TestServiceAddMultiCaptureWithGeofences(t *testing.T) {
+	t.Parallel()
+
+	events := []domain.GeofenceEvent{{ID: kallax.NewULID(), Type: domain.Enter}}
+	g := &mockGeofencer{tag: "restricted", events: events}
+	s := adding.NewMultiCaptureService(&mockMultiCaptureStore{}, g, &mockPublisher{}, &mockQuota{})
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.MultiCapture{
+		CapturesOK: []adding.Capture{
+			{Payload: validator.Payload{Payload: []domain.Metric{{Name: "power", Value: 10.0}}}},
+			{Payload: validator.Payload{Payload: []domain.Metric{{Name: "power", Value: 11.0}}}},
+		},
+	}
+
+	captures, err := s.AddCaptures(repo, payl)
+	assert.Nil(t, err)
+	for _, capt := range captures {
+		assert.Equal(t, []string{"restricted"}, capt.Tags)
+	}
+	assert.Equal(t, events, g.recorded)
+}
+
+func TestServiceAddMultiCaptureWhenRecordingGeofenceEventsFails(t *testing.T) {
+	t.Parallel()
+
+	p := &mockPublisher{}
+	g := &mockGeofencer{recordErr: errors.New("test")}
+	s := adding.NewMultiCaptureService(&mockMultiCaptureStore{}, g, p, &mockQuota{})
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.MultiCapture{
+		CapturesOK: []adding.Capture{
+			{Payload: validator.Payload{Payload: []domain.Metric{{Name: "power", Value: 10.0}}}},
+		},
+	}
+
+	captures, err := s.AddCaptures(repo, payl)
+	assert.Nil(t, err)
+	assert.Len(t, captures, 1)
+	assert.Len(t, p.published, 1)
+}
+
+func TestServiceAddMultiCaptureErrWithGeofences(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		g    *mockGeofencer
+		err  string
+	}{
+		{"when matching", &mockGeofencer{matchErr: errors.New("test")}, "could not match captures geofences: test"},
+	}
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.MultiCapture{
+		CapturesOK: []adding.Capture{
+			{Payload: validator.Payload{Payload: []domain.Metric{{Name: "power", Value: 10.0}}}},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := adding.NewMultiCaptureService(&mockMultiCaptureStore{}, tc.g, &mockPublisher{}, &mockQuota{})
+			_, err := s.AddCaptures(repo, payl)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestServiceAddMultiCapturePublishEvents(t *testing.T) {
+	t.Parallel()
+
+	p := &mockPublisher{}
+	s := adding.NewMultiCaptureService(&mockMultiCaptureStore{}, &mockGeofencer{}, p, &mockQuota{})
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.MultiCapture{
+		CapturesOK: []adding.Capture{
+			{Payload: validator.Payload{Payload: []domain.Metric{{Name: "power", Value: 10.0}}}},
+			{Payload: validator.Payload{Payload: []domain.Metric{{Name: "power", Value: 11.0}}}},
+		},
+	}
+
+	captures, err := s.AddCaptures(repo, payl)
+	assert.Nil(t, err)
+	assert.Len(t, p.published, 2)
+	for i, e := range p.published {
+		assert.Equal(t, domain.CaptureCreated, e.Type)
+		assert.Equal(t, captures[i].ID, e.Capture.ID)
+	}
+}
+
+func TestServiceAddMultiCaptureQuota(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	payl := adding.MultiCapture{
+		CapturesOK: []adding.Capture{
+			{Payload: validator.Payload{Payload: []domain.Metric{{Name: "power", Value: 10.0}}}},
+			{Payload: validator.Payload{Payload: []domain.Metric{{Name: "power", Value: 30.0}}}},
+		},
+	}
+
+	q := &mockQuota{}
+	s := adding.NewMultiCaptureService(&mockMultiCaptureStore{}, &mockGeofencer{}, &mockPublisher{}, q)
+	_, err := s.AddCaptures(repo, payl)
+	assert.Nil(t, err)
+	assert.Len(t, q.reserved, 2)
+
+	s = adding.NewMultiCaptureService(&mockMultiCaptureStore{}, &mockGeofencer{}, &mockPublisher{}, &mockQuota{reserveErr: errors.New("quota exceeded")})
+	_, err = s.AddCaptures(repo, payl)
+	assert.EqualError(t, err, "quota exceeded")
+
+	q = &mockQuota{}
+	s = adding.NewMultiCaptureService(&mockMultiCaptureStore{err: errors.New("test")}, &mockGeofencer{}, &mockPublisher{}, q)
+	_, err = s.AddCaptures(repo, payl)
+	assert.EqualError(t, err, "could not add captures: test")
+	assert.Len(t, q.released, 2)
+}
@@ -0,0 +1,29 @@
+package administering
+
+import (
+	"github.com/asaskevich/govalidator"
+	"github.com/gobuffalo/validate"
+)
+
+const (
+	errEmailRequired = "email must not be blank"
+	errInvalidEmail  = "invalid email"
+)
+
+// TransferPayload represents the new owner of a repository.
+type TransferPayload struct {
+	Email *string `json:"email"`
+}
+
+func (p *TransferPayload) Validate() error {
+	e := validate.NewErrors()
+	if p.Email == nil {
+		e.Add("email", errEmailRequired)
+	} else if !govalidator.IsEmail(*p.Email) {
+		e.Add("email", errInvalidEmail)
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,263 @@
+package administering
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	errDisableSelf         invalidErr = "administrators can't disable their own account"
+	errImpersonateAdmin    invalidErr = "administrators can't be impersonated"
+	errImpersonateDisabled invalidErr = "disabled accounts can't be impersonated"
+	errSameOwner           invalidErr = "the user already owns the repository"
+)
+
+// statusFilter is the listing filter of the users by the state of their account.
+const statusFilter = "status"
+
+type invalidErr string
+
+func (i invalidErr) Error() string   { return string(i) }
+func (i invalidErr) IsInvalid() bool { return true }
+
+// UserStore provides access to the user storage.
+type UserStore interface {
+	// ListUsers retrieve users with domain.Listing attrs.
+	ListUsers(*domain.Listing) ([]domain.User, int64, error)
+	// GetUserByID get a user by id.
+	GetUserByID(kallax.ULID) (*domain.User, error)
+	// GetUserByEmail get a user by email.
+	GetUserByEmail(string) (*domain.User, error)
+	// UpdateUser saves the changes of a user.
+	UpdateUser(*domain.User) error
+}
+
+// RepoStore provides access to the repository storage.
+type RepoStore interface {
+	// Get retrieve a repository regardless of its visibility.
+	Get(kallax.ULID) (*domain.Repository, error)
+	// TransferRepo saves the owner of a repository, moving its usage to the new owner.
+	TransferRepo(*domain.Repository) error
+}
+
+// AuditStore provides access to the audit trail storage.
+type AuditStore interface {
+	// CreateAuditEntry appends an entry to the audit trail.
+	CreateAuditEntry(*domain.AuditEntry) error
+}
+
+// TokenService provides utils to issue the impersonation tokens.
+type TokenService interface {
+	// GenerateImpersonationToken creates a token to act as the user on behalf of the actor.
+	GenerateImpersonationToken(userID, actorID string) (string, error)
+}
+
+// Impersonation represents a token to act as an user for support.
+type Impersonation struct {
+	Token string       `json:"token"`
+	User  *domain.User `json:"user"`
+}
+
+// Service provides administering operations. Every operation is recorded in the audit
+// trail with the administrator performing it.
+type Service interface {
+	// ListUsers list the users whose email or name contains the search.
+	ListUsers(domain.Actor, *listing.Listing, string) (*ListUserResponse, error)
+	// DisableUser disables the account of an user.
+	DisableUser(domain.Actor, kallax.ULID) (*domain.User, error)
+	// EnableUser enables the account of a disabled user.
+	EnableUser(domain.Actor, kallax.ULID) (*domain.User, error)
+	// GetRepo retrieve any repository regardless of its visibility.
+	GetRepo(domain.Actor, kallax.ULID) (*domain.Repository, error)
+	// TransferRepo makes an user the owner of a repository. Repositories of an
+	// organization are moved out of it.
+	TransferRepo(domain.Actor, kallax.ULID, TransferPayload) (*domain.Repository, error)
+	// Impersonate issues a token to act as an user. Administrators and disabled
+	// accounts can't be impersonated.
+	Impersonate(domain.Actor, kallax.ULID) (*Impersonation, error)
+}
+
+type service struct {
+	us UserStore
+	rs RepoStore
+	as AuditStore
+	ts TokenService
+}
+
+// NewService creates an administering service with the necessary dependencies
+func NewService(us UserStore, rs RepoStore, as AuditStore, ts TokenService) Service {
+	return &service{us: us, rs: rs, as: as, ts: ts}
+}
+
+func (s *service) ListUsers(a domain.Actor, l *listing.Listing, search string) (*ListUserResponse, error) {
+	lusers := domain.NewListing(*l)
+	if search != "" {
+		lusers.Search = &search
+	}
+	lusers.Disabled = disabledFilter(l)
+	users, total, err := s.us.ListUsers(lusers)
+	if err != nil {
+		return nil, errors.Wrap(err, "err getting users")
+	}
+	l.Paging.Total = total
+
+	e := domain.NewAuditEntry(a, domain.AdminUsersListed, domain.UserTarget, "")
+	if search != "" {
+		e.Details = map[string]interface{}{"search": search}
+	}
+	if err := s.record(e); err != nil {
+		return nil, err
+	}
+	return newListUserResponse(users, l), nil
+}
+
+func disabledFilter(l *listing.Listing) *bool {
+	if l.Filtering == nil {
+		return nil
+	}
+	for _, f := range l.Filtering.Filters {
+		if f.ID == statusFilter && len(f.Values) > 0 {
+			disabled := f.Values[0].ID == "disabled"
+			return &disabled
+		}
+	}
+	return nil
+}
+
+func (s *service) DisableUser(a domain.Actor, id kallax.ULID) (*domain.User, error) {
+	if a.User != nil && a.User.ID == id {
+		return nil, errors.WithStack(errDisableSelf)
+	}
+	u, err := s.us.GetUserByID(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get user")
+	}
+	if !u.Disabled() {
+		now := time.Now()
+		u.DisabledAt = &now
+		u.UpdatedAt = now
+		if err := s.us.UpdateUser(u); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("could not disable user %v", u.ID))
+		}
+	}
+	if err := s.record(domain.NewAuditEntry(a, domain.AdminUserDisabled, domain.UserTarget, u.ID.String())); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *service) EnableUser(a domain.Actor, id kallax.ULID) (*domain.User, error) {
+	u, err := s.us.GetUserByID(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get user")
+	}
+	if u.Disabled() {
+		u.DisabledAt = nil
+		u.UpdatedAt = time.Now()
+		if err := s.us.UpdateUser(u); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("could not enable user %v", u.ID))
+		}
+	}
+	if err := s.record(domain.NewAuditEntry(a, domain.AdminUserEnabled, domain.UserTarget, u.ID.String())); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *service) GetRepo(a domain.Actor, id kallax.ULID) (*domain.Repository, error) {
+	r, err := s.rs.Get(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get repository")
+	}
+	e := domain.NewAuditEntry(a, domain.AdminRepoViewed, domain.RepositoryTarget, r.ID.String())
+	e.RepositoryID = &r.ID
+	if err := s.record(e); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *service) TransferRepo(a domain.Actor, id kallax.ULID, p TransferPayload) (*domain.Repository, error) {
+	r, err := s.rs.Get(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get repository")
+	}
+	u, err := s.us.GetUserByEmail(*p.Email)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get new owner")
+	}
+	if r.UserID == u.ID && r.OrganizationID == nil {
+		return nil, errors.WithStack(errSameOwner)
+	}
+
+	details := map[string]interface{}{"from": r.UserID.String(), "to": u.ID.String()}
+	if r.OrganizationID != nil {
+		details["organization"] = r.OrganizationID.String()
+	}
+	r.UserID = u.ID
+	r.OrganizationID = nil
+	r.UpdatedAt = time.Now()
+	if err := s.rs.TransferRepo(r); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("could not transfer repository %v", r.ID))
+	}
+
+	e := domain.NewAuditEntry(a, domain.AdminRepoTransferred, domain.RepositoryTarget, r.ID.String())
+	e.RepositoryID = &r.ID
+	e.Details = details
+	if err := s.record(e); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (s *service) Impersonate(a domain.Actor, id kallax.ULID) (*Impersonation, error) {
+	u, err := s.us.GetUserByID(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get user")
+	}
+	if u.Admin {
+		return nil, errors.WithStack(errImpersonateAdmin)
+	}
+	if u.Disabled() {
+		return nil, errors.WithStack(errImpersonateDisabled)
+	}
+
+	var actorID string
+	if a.User != nil {
+		actorID = a.User.ID.String()
+	}
+	t, err := s.ts.GenerateImpersonationToken(u.ID.String(), actorID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate impersonation token")
+	}
+	// the entry is recorded before handing out the token, an impersonation is never untracked.
+	if err := s.record(domain.NewAuditEntry(a, domain.AdminUserImpersonated, domain.UserTarget, u.ID.String())); err != nil {
+		return nil, err
+	}
+	return &Impersonation{Token: t, User: u}, nil
+}
+
+func (s *service) record(e *domain.AuditEntry) error {
+	if err := s.as.CreateAuditEntry(e); err != nil {
+		return errors.Wrap(err, "could not record audit entry")
+	}
+	return nil
+}
+
+type ListUserResponse struct {
+	Results []domain.User    `json:"results"`
+	Listing *listing.Listing `json:"listing"`
+}
+
+func newListUserResponse(users []domain.User, l *listing.Listing) *ListUserResponse {
+	if users == nil {
+		users = make([]domain.User, 0)
+	}
+	return &ListUserResponse{Results: users, Listing: l}
+}
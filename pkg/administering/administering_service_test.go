@@ -0,0 +1,274 @@
+package administering_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/ifreddyrondon/bastion/middleware/listing/filtering"
+	"github.com/ifreddyrondon/bastion/middleware/listing/paging"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/administering"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type invalidErr interface{ IsInvalid() bool }
+
+type mockUserStore struct {
+	users   []domain.User
+	usr     *domain.User
+	updated *domain.User
+	listing *domain.Listing
+	err     error
+}
+
+func (m *mockUserStore) ListUsers(l *domain.Listing) ([]domain.User, int64, error) {
+	m.listing = l
+	return m.users, int64(len(m.users)), m.err
+}
+func (m *mockUserStore) GetUserByID(kallax.ULID) (*domain.User, error) { return m.usr, m.err }
+func (m *mockUserStore) GetUserByEmail(string) (*domain.User, error)   { return m.usr, m.err }
+func (m *mockUserStore) UpdateUser(u *domain.User) error {
+	m.updated = u
+	return m.err
+}
+
+type mockRepoStore struct {
+	repo        *domain.Repository
+	transferred *domain.Repository
+	err         error
+}
+
+func (m *mockRepoStore) Get(kallax.ULID) (*domain.Repository, error) { return m.repo, m.err }
+func (m *mockRepoStore) TransferRepo(r *domain.Repository) error {
+	m.transferred = r
+	return m.err
+}
+
+type mockAuditStore struct {
+	entries []domain.AuditEntry
+	err     error
+}
+
+func (m *mockAuditStore) CreateAuditEntry(e *domain.AuditEntry) error {
+	m.entries = append(m.entries, *e)
+	return m.err
+}
+
+type mockTokenService struct {
+	userID, actorID string
+	err             error
+}
+
+func (m *mockTokenService) GenerateImpersonationToken(userID, actorID string) (string, error) {
+	m.userID, m.actorID = userID, actorID
+	return "token", m.err
+}
+
+var admin = domain.Actor{User: &domain.User{ID: kallax.NewULID(), Admin: true}, IP: "127.0.0.1", UserAgent: "test"}
+
+func TestServiceListUsers(t *testing.T) {
+	t.Parallel()
+
+	us := &mockUserStore{users: []domain.User{{Email: "a@example.com"}, {Email: "b@example.com"}}}
+	as := &mockAuditStore{}
+	s := administering.NewService(us, &mockRepoStore{}, as, &mockTokenService{})
+
+	l := &listing.Listing{
+		Paging: paging.Paging{Limit: 50},
+		Filtering: &filtering.Filtering{
+			Filters: []filtering.Filter{{ID: "status", Values: []filtering.Value{filtering.NewValue("disabled", "disabled")}}},
+		},
+	}
+	res, err := s.ListUsers(admin, l, "example")
+	assert.Nil(t, err)
+	assert.Len(t, res.Results, 2)
+	assert.Equal(t, int64(2), res.Listing.Paging.Total)
+	assert.Equal(t, "example", *us.listing.Search)
+	assert.True(t, *us.listing.Disabled)
+
+	assert.Len(t, as.entries, 1)
+	assert.Equal(t, domain.AdminUsersListed, as.entries[0].Action)
+	assert.Equal(t, admin.User.ID, *as.entries[0].ActorID)
+	assert.Equal(t, map[string]interface{}{"search": "example"}, as.entries[0].Details)
+
+	us.users = nil
+	res, err = s.ListUsers(admin, &listing.Listing{}, "")
+	assert.Nil(t, err)
+	assert.NotNil(t, res.Results)
+	assert.Nil(t, us.listing.Search)
+	assert.Nil(t, us.listing.Disabled)
+}
+
+func TestServiceDisableAndEnableUser(t *testing.T) {
+	t.Parallel()
+
+	u := &domain.User{ID: kallax.NewULID()}
+	us := &mockUserStore{usr: u}
+	as := &mockAuditStore{}
+	s := administering.NewService(us, &mockRepoStore{}, as, &mockTokenService{})
+
+	result, err := s.DisableUser(admin, u.ID)
+	assert.Nil(t, err)
+	assert.True(t, result.Disabled())
+	assert.Equal(t, u, us.updated)
+
+	result, err = s.EnableUser(admin, u.ID)
+	assert.Nil(t, err)
+	assert.False(t, result.Disabled())
+
+	assert.Len(t, as.entries, 2)
+	assert.Equal(t, domain.AdminUserDisabled, as.entries[0].Action)
+	assert.Equal(t, domain.AdminUserEnabled, as.entries[1].Action)
+	assert.Equal(t, domain.UserTarget, as.entries[1].TargetType)
+	assert.Equal(t, u.ID.String(), as.entries[1].TargetID)
+}
+
+func TestServiceDisableUserFails(t *testing.T) {
+	t.Parallel()
+
+	u := &domain.User{ID: kallax.NewULID()}
+
+	tt := []struct {
+		name    string
+		id      kallax.ULID
+		us      *mockUserStore
+		as      *mockAuditStore
+		err     string
+		invalid bool
+	}{
+		{"own account", admin.User.ID, &mockUserStore{usr: admin.User}, &mockAuditStore{}, "administrators can't disable their own account", true},
+		{"store err", u.ID, &mockUserStore{err: errors.New("test")}, &mockAuditStore{}, "could not get user: test", false},
+		{"audit err", u.ID, &mockUserStore{usr: u}, &mockAuditStore{err: errors.New("test")}, "could not record audit entry: test", false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := administering.NewService(tc.us, &mockRepoStore{}, tc.as, &mockTokenService{})
+			_, err := s.DisableUser(admin, tc.id)
+			assert.EqualError(t, err, tc.err)
+			_, invalid := errors.Cause(err).(invalidErr)
+			assert.Equal(t, tc.invalid, invalid)
+		})
+	}
+}
+
+func TestServiceGetRepo(t *testing.T) {
+	t.Parallel()
+
+	r := &domain.Repository{ID: kallax.NewULID(), Visibility: domain.Private}
+	as := &mockAuditStore{}
+	s := administering.NewService(&mockUserStore{}, &mockRepoStore{repo: r}, as, &mockTokenService{})
+
+	result, err := s.GetRepo(admin, r.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, r, result)
+	assert.Len(t, as.entries, 1)
+	assert.Equal(t, domain.AdminRepoViewed, as.entries[0].Action)
+	assert.Equal(t, r.ID.String(), as.entries[0].TargetID)
+
+	s = administering.NewService(&mockUserStore{}, &mockRepoStore{err: errors.New("test")}, as, &mockTokenService{})
+	_, err = s.GetRepo(admin, r.ID)
+	assert.EqualError(t, err, "could not get repository: test")
+}
+
+func TestServiceTransferRepo(t *testing.T) {
+	t.Parallel()
+
+	from, orgID := kallax.NewULID(), kallax.NewULID()
+	r := &domain.Repository{ID: kallax.NewULID(), UserID: from, OrganizationID: &orgID, UpdatedAt: time.Now().Add(-time.Hour)}
+	u := &domain.User{ID: kallax.NewULID()}
+	rs := &mockRepoStore{repo: r}
+	as := &mockAuditStore{}
+	s := administering.NewService(&mockUserStore{usr: u}, rs, as, &mockTokenService{})
+
+	email := "new@example.com"
+	result, err := s.TransferRepo(admin, r.ID, administering.TransferPayload{Email: &email})
+	assert.Nil(t, err)
+	assert.Equal(t, u.ID, result.UserID)
+	assert.Nil(t, result.OrganizationID)
+	assert.Equal(t, r, rs.transferred)
+
+	assert.Len(t, as.entries, 1)
+	assert.Equal(t, domain.AdminRepoTransferred, as.entries[0].Action)
+	expected := map[string]interface{}{"from": from.String(), "to": u.ID.String(), "organization": orgID.String()}
+	assert.Equal(t, expected, as.entries[0].Details)
+
+	_, err = s.TransferRepo(admin, r.ID, administering.TransferPayload{Email: &email})
+	assert.EqualError(t, err, "the user already owns the repository")
+}
+
+func TestServiceTransferRepoFails(t *testing.T) {
+	t.Parallel()
+
+	email := "new@example.com"
+	r := &domain.Repository{ID: kallax.NewULID()}
+
+	tt := []struct {
+		name string
+		us   *mockUserStore
+		rs   *mockRepoStore
+		err  string
+	}{
+		{"repo err", &mockUserStore{usr: &domain.User{ID: kallax.NewULID()}}, &mockRepoStore{err: errors.New("test")}, "could not get repository: test"},
+		{"user err", &mockUserStore{err: errors.New("test")}, &mockRepoStore{repo: r}, "could not get new owner: test"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := administering.NewService(tc.us, tc.rs, &mockAuditStore{}, &mockTokenService{})
+			_, err := s.TransferRepo(admin, r.ID, administering.TransferPayload{Email: &email})
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestServiceImpersonate(t *testing.T) {
+	t.Parallel()
+
+	u := &domain.User{ID: kallax.NewULID()}
+	as := &mockAuditStore{}
+	ts := &mockTokenService{}
+	s := administering.NewService(&mockUserStore{usr: u}, &mockRepoStore{}, as, ts)
+
+	result, err := s.Impersonate(admin, u.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, "token", result.Token)
+	assert.Equal(t, u, result.User)
+	assert.Equal(t, u.ID.String(), ts.userID)
+	assert.Equal(t, admin.User.ID.String(), ts.actorID)
+	assert.Len(t, as.entries, 1)
+	assert.Equal(t, domain.AdminUserImpersonated, as.entries[0].Action)
+	assert.Equal(t, "127.0.0.1", as.entries[0].IP)
+}
+
+func TestServiceImpersonateFails(t *testing.T) {
+	t.Parallel()
+
+	disabledAt := time.Now()
+
+	tt := []struct {
+		name string
+		us   *mockUserStore
+		ts   *mockTokenService
+		as   *mockAuditStore
+		err  string
+	}{
+		{"admin", &mockUserStore{usr: &domain.User{Admin: true}}, &mockTokenService{}, &mockAuditStore{}, "administrators can't be impersonated"},
+		{"disabled", &mockUserStore{usr: &domain.User{DisabledAt: &disabledAt}}, &mockTokenService{}, &mockAuditStore{}, "disabled accounts can't be impersonated"},
+		{"token err", &mockUserStore{usr: &domain.User{}}, &mockTokenService{err: errors.New("test")}, &mockAuditStore{}, "could not generate impersonation token: test"},
+		{"audit err", &mockUserStore{usr: &domain.User{}}, &mockTokenService{}, &mockAuditStore{err: errors.New("test")}, "could not record audit entry: test"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := administering.NewService(tc.us, &mockRepoStore{}, tc.as, tc.ts)
+			result, err := s.Impersonate(admin, kallax.NewULID())
+			assert.EqualError(t, err, tc.err)
+			assert.Nil(t, result)
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package administering_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/administering"
+)
+
+func TestTransferPayloadValidate(t *testing.T) {
+	t.Parallel()
+
+	valid, invalid := "test@example.com", "test"
+	assert.Nil(t, (&administering.TransferPayload{Email: &valid}).Validate())
+	assert.EqualError(t, (&administering.TransferPayload{}).Validate(), "email must not be blank")
+	assert.EqualError(t, (&administering.TransferPayload{Email: &invalid}).Validate(), "invalid email")
+}
@@ -0,0 +1,94 @@
+package apikeys
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/gobuffalo/validate"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	errNameRequired   = "name must not be blank"
+	errScopesRequired = "scopes must contain at least one scope"
+
+	keyPrefix  = "cap"
+	prefixSize = 6
+	secretSize = 32
+	hashCost   = 10
+)
+
+// Payload represents the data to create an API key.
+type Payload struct {
+	Name   *string  `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+func (p *Payload) Validate() error {
+	e := validate.NewErrors()
+	if p.Name == nil || len(strings.TrimSpace(*p.Name)) == 0 {
+		e.Add("name", errNameRequired)
+	}
+
+	if len(p.Scopes) == 0 {
+		e.Add("scopes", errScopesRequired)
+	}
+	for _, s := range p.Scopes {
+		if !allowedScope(s) {
+			e.Add("scopes", fmt.Sprintf("not allowed scope %v. it could be one of %v", s, domain.APIKeyScopes))
+		}
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+func allowedScope(scope string) bool {
+	for _, s := range domain.APIKeyScopes {
+		if string(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Generate creates a new API key with the format cap_<prefix>_<secret>, returning
+// the key, its prefix and the hash of its secret.
+func Generate() (string, string, []byte, error) {
+	rawPrefix := make([]byte, prefixSize)
+	if _, err := rand.Read(rawPrefix); err != nil {
+		return "", "", nil, err
+	}
+	rawSecret := make([]byte, secretSize)
+	if _, err := rand.Read(rawSecret); err != nil {
+		return "", "", nil, err
+	}
+	prefix := hex.EncodeToString(rawPrefix)
+	secret := base64.RawURLEncoding.EncodeToString(rawSecret)
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), hashCost)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return strings.Join([]string{keyPrefix, prefix, secret}, "_"), prefix, hash, nil
+}
+
+// Parse splits an API key into its prefix and secret.
+func Parse(key string) (string, string, bool) {
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != keyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", "", false
+	}
+	return parts[1], parts[2], true
+}
+
+// Check compares the secret of an API key with its stored hash.
+func Check(hash []byte, secret string) bool {
+	return bcrypt.CompareHashAndPassword(hash, []byte(secret)) == nil
+}
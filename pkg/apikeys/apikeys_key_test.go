@@ -0,0 +1,63 @@
+package apikeys_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
+)
+
+func s2P(v string) *string {
+	return &v
+}
+
+func TestValidatePayloadOK(t *testing.T) {
+	t.Parallel()
+
+	p := apikeys.Payload{Name: s2P("tracker"), Scopes: []string{"captures:write", "captures:read"}}
+	assert.Nil(t, p.Validate())
+}
+
+func TestValidatePayloadFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		payload apikeys.Payload
+		errs    []string
+	}{
+		{"missing name and scopes", apikeys.Payload{}, []string{"name must not be blank", "scopes must contain at least one scope"}},
+		{"blank name", apikeys.Payload{Name: s2P(" "), Scopes: []string{"captures:read"}}, []string{"name must not be blank"}},
+		{"unknown scope", apikeys.Payload{Name: s2P("tracker"), Scopes: []string{"repos:admin"}}, []string{"not allowed scope repos:admin"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.payload.Validate()
+			for _, e := range tc.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
+
+func TestGenerateAndParse(t *testing.T) {
+	t.Parallel()
+
+	key, prefix, hash, err := apikeys.Generate()
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(key, "cap_"+prefix+"_"))
+
+	p, secret, ok := apikeys.Parse(key)
+	assert.True(t, ok)
+	assert.Equal(t, prefix, p)
+	assert.True(t, apikeys.Check(hash, secret))
+	assert.False(t, apikeys.Check(hash, "other"))
+
+	for _, invalid := range []string{"", "abc", "cap_abc", "key_abc_def", "cap__def"} {
+		_, _, ok := apikeys.Parse(invalid)
+		assert.False(t, ok, invalid)
+	}
+}
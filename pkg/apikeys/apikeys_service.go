@@ -0,0 +1,105 @@
+package apikeys
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// Store provides access to the API key storage.
+type Store interface {
+	// CreateAPIKey stores a new API key.
+	CreateAPIKey(*domain.APIKey) error
+	// ListAPIKeys retrieve all the API keys of a repository.
+	ListAPIKeys(repoID kallax.ULID) ([]domain.APIKey, error)
+	// GetAPIKey retrieve an API key of a repository.
+	GetAPIKey(keyID, repoID kallax.ULID) (*domain.APIKey, error)
+	// SaveAPIKey the API key state into the storage.
+	SaveAPIKey(*domain.APIKey) error
+}
+
+// Key represents a just created API key. The key is only visible at creation time.
+type Key struct {
+	domain.APIKey
+	Key string `json:"key"`
+}
+
+// Service provides API keys operations.
+type Service interface {
+	// CreateKey creates a new API key for a repository.
+	CreateKey(*domain.User, *domain.Repository, Payload) (*Key, error)
+	// ListKeys list the repo API keys.
+	ListKeys(*domain.Repository) ([]domain.APIKey, error)
+	// GetKey retrieve a repo API key.
+	GetKey(kallax.ULID, *domain.Repository) (*domain.APIKey, error)
+	// RevokeKey revokes an API key.
+	RevokeKey(*domain.APIKey) error
+}
+
+type service struct {
+	s Store
+}
+
+// NewService creates an API keys service with the necessary dependencies
+func NewService(s Store) Service {
+	return &service{s: s}
+}
+
+func (s *service) CreateKey(u *domain.User, r *domain.Repository, p Payload) (*Key, error) {
+	key, prefix, hash, err := Generate()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate api key")
+	}
+	k := domain.APIKey{
+		ID:           kallax.NewULID(),
+		Name:         *p.Name,
+		Prefix:       prefix,
+		Hash:         hash,
+		Scopes:       make([]domain.APIKeyScope, len(p.Scopes)),
+		CreatedAt:    time.Now(),
+		RepositoryID: r.ID,
+		UserID:       u.ID,
+	}
+	for i, scope := range p.Scopes {
+		k.Scopes[i] = domain.APIKeyScope(scope)
+	}
+	if err := s.s.CreateAPIKey(&k); err != nil {
+		return nil, errors.Wrap(err, "could not create api key")
+	}
+	return &Key{APIKey: k, Key: key}, nil
+}
+
+func (s *service) ListKeys(r *domain.Repository) ([]domain.APIKey, error) {
+	keys, err := s.s.ListAPIKeys(r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list api keys")
+	}
+	if keys == nil {
+		keys = make([]domain.APIKey, 0)
+	}
+	return keys, nil
+}
+
+func (s *service) GetKey(id kallax.ULID, r *domain.Repository) (*domain.APIKey, error) {
+	k, err := s.s.GetAPIKey(id, r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get api key")
+	}
+	return k, nil
+}
+
+func (s *service) RevokeKey(k *domain.APIKey) error {
+	if k.RevokedAt != nil {
+		return nil
+	}
+	t := time.Now()
+	k.RevokedAt = &t
+	if err := s.s.SaveAPIKey(k); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not revoke api key %v", k.ID))
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package apikeys_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type mockStore struct {
+	key  *domain.APIKey
+	keys []domain.APIKey
+	err  error
+}
+
+func (m *mockStore) CreateAPIKey(k *domain.APIKey) error {
+	m.key = k
+	return m.err
+}
+func (m *mockStore) ListAPIKeys(kallax.ULID) ([]domain.APIKey, error) { return m.keys, m.err }
+func (m *mockStore) GetAPIKey(kallax.ULID, kallax.ULID) (*domain.APIKey, error) {
+	return m.key, m.err
+}
+func (m *mockStore) SaveAPIKey(*domain.APIKey) error { return m.err }
+
+func TestServiceCreateKey(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{}
+	s := apikeys.NewService(store)
+	u := &domain.User{ID: kallax.NewULID()}
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	k, err := s.CreateKey(u, repo, apikeys.Payload{Name: s2P("tracker"), Scopes: []string{"captures:write"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "tracker", k.Name)
+	assert.Equal(t, repo.ID, k.RepositoryID)
+	assert.Equal(t, u.ID, k.UserID)
+	assert.Equal(t, []domain.APIKeyScope{domain.CapturesWrite}, k.Scopes)
+	assert.Nil(t, k.LastUsedAt)
+
+	prefix, secret, ok := apikeys.Parse(k.Key)
+	assert.True(t, ok)
+	assert.Equal(t, store.key.Prefix, prefix)
+	assert.NotContains(t, string(store.key.Hash), secret)
+	assert.True(t, apikeys.Check(store.key.Hash, secret))
+}
+
+func TestServiceListKeys(t *testing.T) {
+	t.Parallel()
+
+	s := apikeys.NewService(&mockStore{})
+	keys, err := s.ListKeys(&domain.Repository{ID: kallax.NewULID()})
+	assert.Nil(t, err)
+	assert.NotNil(t, keys)
+	assert.Len(t, keys, 0)
+}
+
+func TestServiceRevokeKey(t *testing.T) {
+	t.Parallel()
+
+	s := apikeys.NewService(&mockStore{})
+	k := &domain.APIKey{ID: kallax.NewULID()}
+	assert.Nil(t, s.RevokeKey(k))
+	assert.NotNil(t, k.RevokedAt)
+
+	revokedAt := *k.RevokedAt
+	time.Sleep(time.Millisecond)
+	assert.Nil(t, s.RevokeKey(k))
+	assert.Equal(t, revokedAt, *k.RevokedAt)
+}
+
+func TestServiceAPIKeysErrors(t *testing.T) {
+	t.Parallel()
+
+	s := apikeys.NewService(&mockStore{err: errors.New("test")})
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	k := &domain.APIKey{ID: kallax.NewULID()}
+
+	_, err := s.CreateKey(&domain.User{}, repo, apikeys.Payload{Name: s2P("tracker"), Scopes: []string{"captures:read"}})
+	assert.EqualError(t, err, "could not create api key: test")
+	_, err = s.ListKeys(repo)
+	assert.EqualError(t, err, "could not list api keys: test")
+	_, err = s.GetKey(k.ID, repo)
+	assert.EqualError(t, err, "could not get api key: test")
+	err = s.RevokeKey(k)
+	assert.EqualError(t, err, "could not revoke api key "+k.ID.String()+": test")
+}
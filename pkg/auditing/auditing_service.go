@@ -0,0 +1,103 @@
+package auditing
+
+import (
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// Listing filters of the entries by action and by target.
+const (
+	actionFilter = "action"
+	targetFilter = "target"
+)
+
+// Store provides access to the audit trail storage.
+type Store interface {
+	// CreateAuditEntry appends an entry to the audit trail.
+	CreateAuditEntry(*domain.AuditEntry) error
+	// ListAuditEntries retrieve the audit trail entries with domain.Listing attrs.
+	ListAuditEntries(*domain.Listing) ([]domain.AuditEntry, int64, error)
+}
+
+// Service provides audit trail operations.
+type Service interface {
+	// Record appends an entry to the audit trail.
+	Record(*domain.AuditEntry) error
+	// ListEntries list the entries of the whole audit trail, filtered by actor when given.
+	ListEntries(*listing.Listing, *kallax.ULID) (*ListEntryResponse, error)
+	// ListRepoEntries list the entries of the actions within a repository, filtered by
+	// actor when given.
+	ListRepoEntries(*domain.Repository, *listing.Listing, *kallax.ULID) (*ListEntryResponse, error)
+}
+
+type service struct {
+	s Store
+}
+
+// NewService creates an auditing service with the necessary dependencies
+func NewService(s Store) Service {
+	return &service{s: s}
+}
+
+func (s *service) Record(e *domain.AuditEntry) error {
+	if err := s.s.CreateAuditEntry(e); err != nil {
+		return errors.Wrap(err, "could not record audit entry")
+	}
+	return nil
+}
+
+func (s *service) ListEntries(l *listing.Listing, actor *kallax.ULID) (*ListEntryResponse, error) {
+	return s.list(newEntriesListing(l, actor), l)
+}
+
+func (s *service) ListRepoEntries(r *domain.Repository, l *listing.Listing, actor *kallax.ULID) (*ListEntryResponse, error) {
+	lentries := newEntriesListing(l, actor)
+	lentries.Repository = &r.ID
+	return s.list(lentries, l)
+}
+
+func (s *service) list(lentries *domain.Listing, l *listing.Listing) (*ListEntryResponse, error) {
+	entries, total, err := s.s.ListAuditEntries(lentries)
+	if err != nil {
+		return nil, errors.Wrap(err, "err getting audit entries")
+	}
+	l.Paging.Total = total
+	return newListEntryResponse(entries, l), nil
+}
+
+func newEntriesListing(l *listing.Listing, actor *kallax.ULID) *domain.Listing {
+	lentries := domain.NewListing(*l)
+	lentries.Actor = actor
+	if l.Filtering == nil {
+		return lentries
+	}
+	for _, f := range l.Filtering.Filters {
+		if len(f.Values) == 0 {
+			continue
+		}
+		switch f.ID {
+		case actionFilter:
+			action := domain.AuditAction(f.Values[0].ID)
+			lentries.Action = &action
+		case targetFilter:
+			target := f.Values[0].ID
+			lentries.TargetType = &target
+		}
+	}
+	return lentries
+}
+
+type ListEntryResponse struct {
+	Results []domain.AuditEntry `json:"results"`
+	Listing *listing.Listing    `json:"listing"`
+}
+
+func newListEntryResponse(entries []domain.AuditEntry, l *listing.Listing) *ListEntryResponse {
+	if entries == nil {
+		entries = make([]domain.AuditEntry, 0)
+	}
+	return &ListEntryResponse{Results: entries, Listing: l}
+}
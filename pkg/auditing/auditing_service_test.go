@@ -0,0 +1,96 @@
+package auditing_test
+
+import (
+	"testing"
+
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/ifreddyrondon/bastion/middleware/listing/filtering"
+	"github.com/ifreddyrondon/bastion/middleware/listing/paging"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/auditing"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type mockStore struct {
+	entries []domain.AuditEntry
+	entry   *domain.AuditEntry
+	listing *domain.Listing
+	err     error
+}
+
+func (m *mockStore) CreateAuditEntry(e *domain.AuditEntry) error {
+	m.entry = e
+	return m.err
+}
+func (m *mockStore) ListAuditEntries(l *domain.Listing) ([]domain.AuditEntry, int64, error) {
+	m.listing = l
+	return m.entries, int64(len(m.entries)), m.err
+}
+
+func TestServiceRecord(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{}
+	s := auditing.NewService(store)
+	e := domain.NewAuditEntry(domain.Actor{}, domain.LoginFailed, domain.UserTarget, "test@example.com")
+	assert.Nil(t, s.Record(e))
+	assert.Equal(t, e, store.entry)
+}
+
+func TestServiceRecordErr(t *testing.T) {
+	t.Parallel()
+
+	s := auditing.NewService(&mockStore{err: errors.New("test")})
+	err := s.Record(domain.NewAuditEntry(domain.Actor{}, domain.LoginFailed, domain.UserTarget, "test@example.com"))
+	assert.EqualError(t, err, "could not record audit entry: test")
+}
+
+func TestServiceListEntries(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{entries: []domain.AuditEntry{{ID: kallax.NewULID()}}}
+	s := auditing.NewService(store)
+	l := &listing.Listing{
+		Paging: paging.Paging{Limit: 50},
+		Filtering: &filtering.Filtering{Filters: []filtering.Filter{
+			{ID: "action", Values: []filtering.Value{{ID: "login.failed"}}},
+			{ID: "target", Values: []filtering.Value{{ID: "user"}}},
+		}},
+	}
+	actor := kallax.NewULID()
+	res, err := s.ListEntries(l, &actor)
+	assert.Nil(t, err)
+	assert.Len(t, res.Results, 1)
+	assert.Equal(t, int64(1), res.Listing.Paging.Total)
+	assert.Nil(t, store.listing.Repository)
+	assert.Equal(t, actor, *store.listing.Actor)
+	assert.Equal(t, domain.LoginFailed, *store.listing.Action)
+	assert.Equal(t, domain.UserTarget, *store.listing.TargetType)
+}
+
+func TestServiceListRepoEntries(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{}
+	s := auditing.NewService(store)
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	res, err := s.ListRepoEntries(repo, &listing.Listing{Paging: paging.Paging{Limit: 50}}, nil)
+	assert.Nil(t, err)
+	assert.NotNil(t, res.Results)
+	assert.Len(t, res.Results, 0)
+	assert.Equal(t, repo.ID, *store.listing.Repository)
+	assert.Nil(t, store.listing.Actor)
+	assert.Nil(t, store.listing.Action)
+	assert.Nil(t, store.listing.TargetType)
+}
+
+func TestServiceListEntriesErr(t *testing.T) {
+	t.Parallel()
+
+	s := auditing.NewService(&mockStore{err: errors.New("test")})
+	_, err := s.ListEntries(&listing.Listing{Paging: paging.Paging{Limit: 50}}, nil)
+	assert.EqualError(t, err, "err getting audit entries: test")
+}
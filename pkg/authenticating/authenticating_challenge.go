@@ -0,0 +1,51 @@
+package authenticating
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/validate"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	// ChallengeExpiration is the time an user has to complete the second factor of a login.
+	ChallengeExpiration = 5 * time.Minute
+
+	errChallengeRequired = "challenge must not be blank"
+	errCodeRequired      = "code must not be blank"
+)
+
+// Login represents an authenticated user. When the user has the two-factor authentication
+// enabled the login is pending until its challenge is completed with a valid code.
+type Login struct {
+	User      *domain.User
+	Challenge string
+}
+
+// Pending reports whether the login waits for the second factor.
+func (l Login) Pending() bool {
+	return l.Challenge != ""
+}
+
+// ChallengePayload represents the second factor of a pending login.
+type ChallengePayload struct {
+	Challenge *string `json:"challenge"`
+	Code      *string `json:"code"`
+}
+
+func (p *ChallengePayload) Validate() error {
+	e := validate.NewErrors()
+	if p.Challenge == nil || *p.Challenge == "" {
+		e.Add("challenge", errChallengeRequired)
+	}
+	if p.Code == nil || len(strings.TrimSpace(*p.Code)) == 0 {
+		e.Add("code", errCodeRequired)
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package authenticating_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/authenticating"
+)
+
+func TestValidateChallengePayload(t *testing.T) {
+	t.Parallel()
+
+	challenge, code, blank := "challenge", "123456", " "
+	assert.Nil(t, (&authenticating.ChallengePayload{Challenge: &challenge, Code: &code}).Validate())
+
+	tt := []struct {
+		name    string
+		payload authenticating.ChallengePayload
+		errs    []string
+	}{
+		{"missing both", authenticating.ChallengePayload{}, []string{"challenge must not be blank", "code must not be blank"}},
+		{"blank code", authenticating.ChallengePayload{Challenge: &challenge, Code: &blank}, []string{"code must not be blank"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.payload.Validate()
+			for _, e := range tc.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package authenticating
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gobuffalo/validate"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	// CodeResponseType is the only response type supported by the authorization endpoint.
+	CodeResponseType = "code"
+	// S256ChallengeMethod is the only PKCE code challenge method supported.
+	S256ChallengeMethod = "S256"
+	// AuthorizationCodeGrant exchanges an authorization code for a token.
+	AuthorizationCodeGrant = "authorization_code"
+	// ClientCredentialsGrant issues a token to a confidential client acting on behalf of its owner.
+	ClientCredentialsGrant = "client_credentials"
+
+	errNameRequired          = "name must not be blank"
+	errRedirectURIsRequired  = "redirectUris must contain at least one uri"
+	errScopesRequired        = "scopes must contain at least one scope"
+	errResponseType          = "response_type must be code"
+	errClientIDRequired      = "client_id must not be blank"
+	errRedirectURIRequired   = "redirect_uri must not be blank"
+	errCodeChallengeRequired = "code_challenge must not be blank"
+	errChallengeMethod       = "code_challenge_method must be S256"
+	errApprovedRequired      = "approved must not be blank"
+)
+
+// verifierRegex matches the PKCE code verifiers. Referenced at https://tools.ietf.org/html/rfc7636#section-4.1
+var verifierRegex = regexp.MustCompile(`^[A-Za-z0-9\-._~]{43,128}$`)
+
+// ClientPayload represents the registration of an OAuth2 client.
+type ClientPayload struct {
+	Name         *string  `json:"name"`
+	RedirectURIs []string `json:"redirectUris"`
+	Scopes       []string `json:"scopes"`
+	Confidential bool     `json:"confidential"`
+}
+
+func (p *ClientPayload) Validate() error {
+	e := validate.NewErrors()
+	if p.Name == nil || len(strings.TrimSpace(*p.Name)) == 0 {
+		e.Add("name", errNameRequired)
+	}
+
+	if len(p.RedirectURIs) == 0 {
+		e.Add("redirectUris", errRedirectURIsRequired)
+	}
+	for _, uri := range p.RedirectURIs {
+		if !validRedirectURI(uri) {
+			e.Add("redirectUris", fmt.Sprintf("invalid redirect uri %v. it must be an absolute https url without fragment", uri))
+		}
+	}
+
+	if len(p.Scopes) == 0 {
+		e.Add("scopes", errScopesRequired)
+	}
+	for _, s := range p.Scopes {
+		if !allowedScope(s) {
+			e.Add("scopes", fmt.Sprintf("not allowed scope %v. it could be one of %v", s, domain.OAuthScopes))
+		}
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// validRedirectURI accepts absolute https urls and http urls of the loopback interface
+// used by native apps. Referenced at https://tools.ietf.org/html/rfc8252#section-7.3
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		host := u.Hostname()
+		return host == "localhost" || host == "127.0.0.1" || host == "::1"
+	}
+	return false
+}
+
+func allowedScope(scope string) bool {
+	for _, s := range domain.OAuthScopes {
+		if string(s) == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizePayload represents the query of an authorization request. PKCE is required
+// for every client. Referenced at https://tools.ietf.org/html/rfc6749#section-4.1.1
+type AuthorizePayload struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// NewAuthorizePayload reads an authorization request from the query of an url.
+func NewAuthorizePayload(q url.Values) AuthorizePayload {
+	return AuthorizePayload{
+		ResponseType:        q.Get("response_type"),
+		ClientID:            q.Get("client_id"),
+		RedirectURI:         q.Get("redirect_uri"),
+		Scope:               q.Get("scope"),
+		State:               q.Get("state"),
+		CodeChallenge:       q.Get("code_challenge"),
+		CodeChallengeMethod: q.Get("code_challenge_method"),
+	}
+}
+
+func (p *AuthorizePayload) Validate() error {
+	e := validate.NewErrors()
+	if p.ResponseType != CodeResponseType {
+		e.Add("response_type", errResponseType)
+	}
+	if p.ClientID == "" {
+		e.Add("client_id", errClientIDRequired)
+	}
+	if p.RedirectURI == "" {
+		e.Add("redirect_uri", errRedirectURIRequired)
+	}
+	if p.CodeChallenge == "" {
+		e.Add("code_challenge", errCodeChallengeRequired)
+	}
+	if p.CodeChallengeMethod != S256ChallengeMethod {
+		e.Add("code_challenge_method", errChallengeMethod)
+	}
+	for _, s := range strings.Fields(p.Scope) {
+		if !allowedScope(s) {
+			e.Add("scope", fmt.Sprintf("not allowed scope %v. it could be one of %v", s, domain.OAuthScopes))
+		}
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// ConsentPayload represents the decision of an user over an authorization request.
+type ConsentPayload struct {
+	Approved *bool `json:"approved"`
+}
+
+func (p *ConsentPayload) Validate() error {
+	e := validate.NewErrors()
+	if p.Approved == nil {
+		e.Add("approved", errApprovedRequired)
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// TokenPayload represents the form of a token request. The client credentials could be
+// sent in the form or with HTTP basic authentication.
+type TokenPayload struct {
+	GrantType    string
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	Scope        string
+	ClientID     string
+	ClientSecret string
+}
+
+// NewTokenPayload reads a token request from a form.
+func NewTokenPayload(form url.Values) TokenPayload {
+	return TokenPayload{
+		GrantType:    form.Get("grant_type"),
+		Code:         form.Get("code"),
+		RedirectURI:  form.Get("redirect_uri"),
+		CodeVerifier: form.Get("code_verifier"),
+		Scope:        form.Get("scope"),
+		ClientID:     form.Get("client_id"),
+		ClientSecret: form.Get("client_secret"),
+	}
+}
+
+// OAuthToken represents a successful token response. Referenced at https://tools.ietf.org/html/rfc6749#section-5.1
+type OAuthToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// S256Challenge returns the PKCE code challenge of a verifier.
+func S256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// checkVerifier compares a PKCE code verifier with the challenge of the authorization request.
+func checkVerifier(challenge, verifier string) bool {
+	return verifierRegex.MatchString(verifier) && S256Challenge(verifier) == challenge
+}
+
+// randomToken returns an url safe random token.
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// parseScopes converts a space-delimited scope to scopes.
+func parseScopes(scope string) []domain.APIKeyScope {
+	var scopes []domain.APIKeyScope
+	for _, s := range strings.Fields(scope) {
+		scopes = append(scopes, domain.APIKeyScope(s))
+	}
+	return scopes
+}
+
+// formatScopes converts scopes to a space-delimited scope.
+func formatScopes(scopes []domain.APIKeyScope) []string {
+	formatted := make([]string, len(scopes))
+	for i, s := range scopes {
+		formatted[i] = string(s)
+	}
+	return formatted
+}
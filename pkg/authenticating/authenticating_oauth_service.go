@@ -0,0 +1,395 @@
+package authenticating
+
+import (
+	"crypto/subtle"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// CodeExpiration is the lifetime of the authorization codes.
+const CodeExpiration = 10 * time.Minute
+
+// OAuth2 error codes. Referenced at https://tools.ietf.org/html/rfc6749#section-5.2
+const (
+	InvalidRequest       = "invalid_request"
+	InvalidClient        = "invalid_client"
+	InvalidGrant         = "invalid_grant"
+	InvalidScope         = "invalid_scope"
+	UnauthorizedClient   = "unauthorized_client"
+	UnsupportedGrantType = "unsupported_grant_type"
+	AccessDenied         = "access_denied"
+)
+
+type oauthErr struct {
+	code        string
+	description string
+}
+
+func (e oauthErr) Error() string      { return e.description }
+func (e oauthErr) OAuthError() string { return e.code }
+
+var (
+	errUnknownClient     = oauthErr{InvalidClient, "unknown client or invalid client credentials"}
+	errInvalidAuthCode   = oauthErr{InvalidGrant, "invalid or expired authorization code"}
+	errInvalidVerifier   = oauthErr{InvalidGrant, "code_verifier doesn't match the code_challenge"}
+	errPublicCredentials = oauthErr{UnauthorizedClient, "public clients can't use the client credentials grant"}
+)
+
+type invalidAuthorizeErr string
+
+func (i invalidAuthorizeErr) Error() string   { return string(i) }
+func (i invalidAuthorizeErr) IsInvalid() bool { return true }
+
+const (
+	errAuthorizeClient   invalidAuthorizeErr = "unknown client"
+	errAuthorizeRedirect invalidAuthorizeErr = "redirect_uri is not registered for the client"
+	errAuthorizeScope    invalidAuthorizeErr = "scope is not allowed for the client"
+)
+
+type clientNotFoundErr string
+
+func (e clientNotFoundErr) Error() string  { return string(e) }
+func (e clientNotFoundErr) NotFound() bool { return true }
+
+const errClientNotFound clientNotFoundErr = "not found oauth client"
+
+// OAuthStore provides access to the OAuth2 clients, authorization codes and consents storage.
+type OAuthStore interface {
+	// CreateOAuthClient stores a new client.
+	CreateOAuthClient(*domain.OAuthClient) error
+	// ListOAuthClients retrieve the clients registered by an user.
+	ListOAuthClients(kallax.ULID) ([]domain.OAuthClient, error)
+	// GetOAuthClient retrieve a client by its id.
+	GetOAuthClient(kallax.ULID) (*domain.OAuthClient, error)
+	// RemoveOAuthClient removes a client with its codes and consents.
+	RemoveOAuthClient(*domain.OAuthClient) error
+	// CreateOAuthCode stores a new authorization code.
+	CreateOAuthCode(*domain.OAuthCode) error
+	// ConsumeOAuthCode marks as used the unused and unexpired code with the given hash,
+	// returning it. Only one caller can consume a code.
+	ConsumeOAuthCode(string, time.Time) (*domain.OAuthCode, error)
+	// GetOAuthConsent retrieve the consent of an user to a client.
+	GetOAuthConsent(userID, clientID kallax.ULID) (*domain.OAuthConsent, error)
+	// SaveOAuthConsent creates or updates the consent of an user to a client.
+	SaveOAuthConsent(*domain.OAuthConsent) error
+	// ListOAuthConsents retrieve the consents of an user.
+	ListOAuthConsents(kallax.ULID) ([]domain.OAuthConsent, error)
+	// RemoveOAuthConsent removes the consent of an user to a client.
+	RemoveOAuthConsent(userID, clientID kallax.ULID) error
+}
+
+// ClientTokenService provides utils to handle the tokens of the OAuth2 clients.
+type ClientTokenService interface {
+	// GenerateClientToken creates a token for a client acting on behalf of an user.
+	GenerateClientToken(userID, clientID string, scopes []string) (string, error)
+	// ExpirationDelta returns the lifetime of the generated tokens.
+	ExpirationDelta() time.Duration
+}
+
+// RegisteredClient represents a just registered client. The secret is only visible at
+// registration time and only confidential clients get one.
+type RegisteredClient struct {
+	domain.OAuthClient
+	Secret string `json:"clientSecret,omitempty"`
+}
+
+// ConsentPrompt represents the data shown to an user to consent an authorization request.
+type ConsentPrompt struct {
+	Client  domain.OAuthClient   `json:"client"`
+	Scopes  []domain.APIKeyScope `json:"scopes"`
+	Granted bool                 `json:"granted"`
+}
+
+// ConsentRedirect represents the redirect to the client with the result of a consent.
+type ConsentRedirect struct {
+	RedirectURI string `json:"redirectUri"`
+}
+
+// OAuthService provides OAuth2 authorization server operations.
+type OAuthService interface {
+	// RegisterClient creates a new client owned by the user.
+	RegisterClient(*domain.User, ClientPayload) (*RegisteredClient, error)
+	// ListClients list the clients registered by the user.
+	ListClients(*domain.User) ([]domain.OAuthClient, error)
+	// RemoveClient removes a client registered by the user.
+	RemoveClient(*domain.User, kallax.ULID) error
+	// Prompt validates an authorization request returning what the user must consent.
+	Prompt(*domain.User, AuthorizePayload) (*ConsentPrompt, error)
+	// Consent records the decision of the user over an authorization request, returning the
+	// redirect to the client with an authorization code when approved.
+	Consent(*domain.User, AuthorizePayload, ConsentPayload) (*ConsentRedirect, error)
+	// Exchange issues a token for the authorization code or the client credentials grants.
+	Exchange(TokenPayload) (*OAuthToken, error)
+	// ListConsents list the clients the user consented.
+	ListConsents(*domain.User) ([]domain.OAuthConsent, error)
+	// RevokeConsent removes the consent of the user to a client.
+	RevokeConsent(*domain.User, kallax.ULID) error
+}
+
+type oauthService struct {
+	s  OAuthStore
+	ts ClientTokenService
+}
+
+// NewOAuthService creates an OAuth2 service with the necessary dependencies.
+func NewOAuthService(ts ClientTokenService, s OAuthStore) OAuthService {
+	return &oauthService{ts: ts, s: s}
+}
+
+func (s *oauthService) RegisterClient(u *domain.User, p ClientPayload) (*RegisteredClient, error) {
+	c := domain.OAuthClient{
+		ID:           kallax.NewULID(),
+		Name:         strings.TrimSpace(*p.Name),
+		RedirectURIs: p.RedirectURIs,
+		Scopes:       parseScopes(strings.Join(p.Scopes, " ")),
+		Confidential: p.Confidential,
+		CreatedAt:    time.Now(),
+		UserID:       u.ID,
+	}
+	var secret string
+	if c.Confidential {
+		var err error
+		if secret, err = randomToken(); err != nil {
+			return nil, errors.Wrap(err, "could not generate client secret")
+		}
+		c.SecretHash = hashToken(secret)
+	}
+	if err := s.s.CreateOAuthClient(&c); err != nil {
+		return nil, errors.Wrap(err, "could not register oauth client")
+	}
+	return &RegisteredClient{OAuthClient: c, Secret: secret}, nil
+}
+
+func (s *oauthService) ListClients(u *domain.User) ([]domain.OAuthClient, error) {
+	clients, err := s.s.ListOAuthClients(u.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list oauth clients")
+	}
+	if clients == nil {
+		clients = make([]domain.OAuthClient, 0)
+	}
+	return clients, nil
+}
+
+func (s *oauthService) RemoveClient(u *domain.User, id kallax.ULID) error {
+	c, err := s.s.GetOAuthClient(id)
+	if err != nil {
+		return errors.Wrap(err, "could not get oauth client")
+	}
+	if c.UserID != u.ID {
+		return errors.WithStack(errClientNotFound)
+	}
+	if err := s.s.RemoveOAuthClient(c); err != nil {
+		return errors.Wrap(err, "could not remove oauth client")
+	}
+	return nil
+}
+
+func (s *oauthService) Prompt(u *domain.User, p AuthorizePayload) (*ConsentPrompt, error) {
+	c, scopes, err := s.authorizeRequest(p)
+	if err != nil {
+		return nil, err
+	}
+	prompt := &ConsentPrompt{Client: *c, Scopes: scopes}
+	consent, err := s.s.GetOAuthConsent(u.ID, c.ID)
+	if err != nil {
+		if isNotFound(err) {
+			return prompt, nil
+		}
+		return nil, errors.Wrap(err, "could not get oauth consent")
+	}
+	prompt.Granted = consent.Covers(scopes...)
+	return prompt, nil
+}
+
+func (s *oauthService) Consent(u *domain.User, p AuthorizePayload, cp ConsentPayload) (*ConsentRedirect, error) {
+	c, scopes, err := s.authorizeRequest(p)
+	if err != nil {
+		return nil, err
+	}
+	q := url.Values{}
+	if p.State != "" {
+		q.Set("state", p.State)
+	}
+	if !*cp.Approved {
+		q.Set("error", AccessDenied)
+		return &ConsentRedirect{RedirectURI: withQuery(p.RedirectURI, q)}, nil
+	}
+
+	now := time.Now()
+	consent := &domain.OAuthConsent{UserID: u.ID, ClientID: c.ID, Scopes: scopes, CreatedAt: now, UpdatedAt: now}
+	if err := s.s.SaveOAuthConsent(consent); err != nil {
+		return nil, errors.Wrap(err, "could not save oauth consent")
+	}
+
+	code, err := randomToken()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate authorization code")
+	}
+	ac := &domain.OAuthCode{
+		ID:            kallax.NewULID(),
+		Hash:          hashToken(code),
+		RedirectURI:   p.RedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: p.CodeChallenge,
+		ExpiresAt:     now.Add(CodeExpiration),
+		CreatedAt:     now,
+		ClientID:      c.ID,
+		UserID:        u.ID,
+	}
+	if err := s.s.CreateOAuthCode(ac); err != nil {
+		return nil, errors.Wrap(err, "could not create authorization code")
+	}
+	q.Set("code", code)
+	return &ConsentRedirect{RedirectURI: withQuery(p.RedirectURI, q)}, nil
+}
+
+// authorizeRequest returns the client of an authorization request and the requested scopes,
+// defaulting to every scope of the client.
+func (s *oauthService) authorizeRequest(p AuthorizePayload) (*domain.OAuthClient, []domain.APIKeyScope, error) {
+	c, err := s.client(p.ClientID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil, errors.WithStack(errAuthorizeClient)
+		}
+		return nil, nil, err
+	}
+	if !c.AllowsRedirect(p.RedirectURI) {
+		return nil, nil, errors.WithStack(errAuthorizeRedirect)
+	}
+	scopes, ok := resolveScopes(c, p.Scope)
+	if !ok {
+		return nil, nil, errors.WithStack(errAuthorizeScope)
+	}
+	return c, scopes, nil
+}
+
+func (s *oauthService) Exchange(p TokenPayload) (*OAuthToken, error) {
+	c, err := s.client(p.ClientID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(errUnknownClient)
+		}
+		return nil, err
+	}
+	if c.Confidential && subtle.ConstantTimeCompare([]byte(c.SecretHash), []byte(hashToken(p.ClientSecret))) != 1 {
+		return nil, errors.WithStack(errUnknownClient)
+	}
+
+	switch p.GrantType {
+	case AuthorizationCodeGrant:
+		return s.exchangeCode(c, p)
+	case ClientCredentialsGrant:
+		if !c.Confidential {
+			return nil, errors.WithStack(errPublicCredentials)
+		}
+		scopes, ok := resolveScopes(c, p.Scope)
+		if !ok {
+			return nil, errors.WithStack(oauthErr{InvalidScope, "scope is not allowed for the client"})
+		}
+		return s.issue(c.UserID, c, scopes)
+	case "":
+		return nil, errors.WithStack(oauthErr{InvalidRequest, "grant_type must not be blank"})
+	}
+	return nil, errors.WithStack(oauthErr{UnsupportedGrantType, "grant_type must be authorization_code or client_credentials"})
+}
+
+func (s *oauthService) exchangeCode(c *domain.OAuthClient, p TokenPayload) (*OAuthToken, error) {
+	if p.Code == "" || p.RedirectURI == "" || p.CodeVerifier == "" {
+		return nil, errors.WithStack(oauthErr{InvalidRequest, "code, redirect_uri and code_verifier must not be blank"})
+	}
+	ac, err := s.s.ConsumeOAuthCode(hashToken(p.Code), time.Now())
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(errInvalidAuthCode)
+		}
+		return nil, errors.Wrap(err, "could not consume authorization code")
+	}
+	if ac.ClientID != c.ID || ac.RedirectURI != p.RedirectURI {
+		return nil, errors.WithStack(errInvalidAuthCode)
+	}
+	if !checkVerifier(ac.CodeChallenge, p.CodeVerifier) {
+		return nil, errors.WithStack(errInvalidVerifier)
+	}
+	return s.issue(ac.UserID, c, ac.Scopes)
+}
+
+func (s *oauthService) issue(userID kallax.ULID, c *domain.OAuthClient, scopes []domain.APIKeyScope) (*OAuthToken, error) {
+	formatted := formatScopes(scopes)
+	t, err := s.ts.GenerateClientToken(userID.String(), c.ID.String(), formatted)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate oauth token")
+	}
+	return &OAuthToken{
+		AccessToken: t,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(s.ts.ExpirationDelta().Seconds()),
+		Scope:       strings.Join(formatted, " "),
+	}, nil
+}
+
+// client retrieve a client by the text of its id.
+func (s *oauthService) client(clientID string) (*domain.OAuthClient, error) {
+	id, err := kallax.NewULIDFromText(clientID)
+	if err != nil {
+		return nil, errors.WithStack(errClientNotFound)
+	}
+	c, err := s.s.GetOAuthClient(id)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(errClientNotFound)
+		}
+		return nil, errors.Wrap(err, "could not get oauth client")
+	}
+	return c, nil
+}
+
+func (s *oauthService) ListConsents(u *domain.User) ([]domain.OAuthConsent, error) {
+	consents, err := s.s.ListOAuthConsents(u.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list oauth consents")
+	}
+	if consents == nil {
+		consents = make([]domain.OAuthConsent, 0)
+	}
+	return consents, nil
+}
+
+func (s *oauthService) RevokeConsent(u *domain.User, clientID kallax.ULID) error {
+	if err := s.s.RemoveOAuthConsent(u.ID, clientID); err != nil {
+		return errors.Wrap(err, "could not revoke oauth consent")
+	}
+	return nil
+}
+
+// resolveScopes returns the requested scopes when the client allows them, or every scope
+// of the client when none is requested.
+func resolveScopes(c *domain.OAuthClient, scope string) ([]domain.APIKeyScope, bool) {
+	requested := parseScopes(scope)
+	if len(requested) == 0 {
+		return c.Scopes, true
+	}
+	if !(domain.AccessGrant{Scopes: c.Scopes}).Includes(requested...) {
+		return nil, false
+	}
+	return requested, true
+}
+
+func withQuery(uri string, q url.Values) string {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+	values := u.Query()
+	for k := range q {
+		values.Set(k, q.Get(k))
+	}
+	u.RawQuery = values.Encode()
+	return u.String()
+}
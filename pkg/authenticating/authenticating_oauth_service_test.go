@@ -0,0 +1,311 @@
+package authenticating_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/authenticating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	testVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	testChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	testRedirect  = "https://app.example.com/callback"
+)
+
+type mockOAuthStore struct {
+	client     *domain.OAuthClient
+	clients    []domain.OAuthClient
+	removed    bool
+	code       *domain.OAuthCode
+	codeErr    error
+	consent    *domain.OAuthConsent
+	consentErr error
+	saved      *domain.OAuthConsent
+	err        error
+}
+
+func (m *mockOAuthStore) CreateOAuthClient(c *domain.OAuthClient) error {
+	m.client = c
+	return m.err
+}
+func (m *mockOAuthStore) ListOAuthClients(kallax.ULID) ([]domain.OAuthClient, error) {
+	return m.clients, m.err
+}
+func (m *mockOAuthStore) GetOAuthClient(kallax.ULID) (*domain.OAuthClient, error) {
+	return m.client, m.err
+}
+func (m *mockOAuthStore) RemoveOAuthClient(*domain.OAuthClient) error {
+	m.removed = true
+	return m.err
+}
+func (m *mockOAuthStore) CreateOAuthCode(c *domain.OAuthCode) error {
+	m.code = c
+	return m.codeErr
+}
+func (m *mockOAuthStore) ConsumeOAuthCode(string, time.Time) (*domain.OAuthCode, error) {
+	return m.code, m.codeErr
+}
+func (m *mockOAuthStore) GetOAuthConsent(kallax.ULID, kallax.ULID) (*domain.OAuthConsent, error) {
+	return m.consent, m.consentErr
+}
+func (m *mockOAuthStore) SaveOAuthConsent(c *domain.OAuthConsent) error {
+	m.saved = c
+	return m.consentErr
+}
+func (m *mockOAuthStore) ListOAuthConsents(kallax.ULID) ([]domain.OAuthConsent, error) {
+	return nil, m.err
+}
+func (m *mockOAuthStore) RemoveOAuthConsent(kallax.ULID, kallax.ULID) error { return m.err }
+
+type mockClientTokenService struct {
+	userID   string
+	clientID string
+	scopes   []string
+	err      error
+}
+
+func (m *mockClientTokenService) GenerateClientToken(userID, clientID string, scopes []string) (string, error) {
+	m.userID, m.clientID, m.scopes = userID, clientID, scopes
+	return "token", m.err
+}
+func (m *mockClientTokenService) ExpirationDelta() time.Duration { return time.Hour }
+
+type oauthErr interface{ OAuthError() string }
+
+func testClient(confidential bool) *domain.OAuthClient {
+	return &domain.OAuthClient{
+		ID:           kallax.NewULID(),
+		RedirectURIs: []string{testRedirect},
+		Scopes:       []domain.APIKeyScope{domain.ReposRead, domain.CapturesRead},
+		Confidential: confidential,
+		UserID:       kallax.NewULID(),
+	}
+}
+
+func authorizePayload(c *domain.OAuthClient, scope string) authenticating.AuthorizePayload {
+	return authenticating.AuthorizePayload{
+		ResponseType:        "code",
+		ClientID:            c.ID.String(),
+		RedirectURI:         testRedirect,
+		Scope:               scope,
+		State:               "xyz",
+		CodeChallenge:       testChallenge,
+		CodeChallengeMethod: "S256",
+	}
+}
+
+func TestOAuthServiceRegisterClient(t *testing.T) {
+	t.Parallel()
+
+	store := &mockOAuthStore{}
+	s := authenticating.NewOAuthService(&mockClientTokenService{}, store)
+	u := &domain.User{ID: kallax.NewULID()}
+	name := " partner "
+	p := authenticating.ClientPayload{Name: &name, RedirectURIs: []string{testRedirect}, Scopes: []string{"repos:read"}, Confidential: true}
+	c, err := s.RegisterClient(u, p)
+	assert.Nil(t, err)
+	assert.Equal(t, "partner", c.Name)
+	assert.Equal(t, u.ID, c.UserID)
+	assert.Equal(t, []domain.APIKeyScope{domain.ReposRead}, c.Scopes)
+	assert.NotEmpty(t, c.Secret)
+	assert.NotEqual(t, c.Secret, store.client.SecretHash)
+
+	p.Confidential = false
+	c, err = s.RegisterClient(u, p)
+	assert.Nil(t, err)
+	assert.Empty(t, c.Secret)
+	assert.Empty(t, store.client.SecretHash)
+}
+
+func TestOAuthServiceListAndRemoveClients(t *testing.T) {
+	t.Parallel()
+
+	u := &domain.User{ID: kallax.NewULID()}
+	c := testClient(true)
+	store := &mockOAuthStore{client: c}
+	s := authenticating.NewOAuthService(&mockClientTokenService{}, store)
+
+	clients, err := s.ListClients(u)
+	assert.Nil(t, err)
+	assert.NotNil(t, clients)
+
+	err = s.RemoveClient(u, c.ID)
+	assert.EqualError(t, err, "not found oauth client")
+	assert.False(t, store.removed)
+
+	c.UserID = u.ID
+	assert.Nil(t, s.RemoveClient(u, c.ID))
+	assert.True(t, store.removed)
+}
+
+func TestOAuthServicePrompt(t *testing.T) {
+	t.Parallel()
+
+	c := testClient(false)
+	u := &domain.User{ID: kallax.NewULID()}
+	s := authenticating.NewOAuthService(&mockClientTokenService{}, &mockOAuthStore{client: c, consentErr: userNotFoundMock("")})
+	prompt, err := s.Prompt(u, authorizePayload(c, ""))
+	assert.Nil(t, err)
+	assert.Equal(t, c.Scopes, prompt.Scopes)
+	assert.False(t, prompt.Granted)
+
+	consent := &domain.OAuthConsent{Scopes: []domain.APIKeyScope{domain.ReposRead}}
+	s = authenticating.NewOAuthService(&mockClientTokenService{}, &mockOAuthStore{client: c, consent: consent})
+	prompt, err = s.Prompt(u, authorizePayload(c, "repos:read"))
+	assert.Nil(t, err)
+	assert.True(t, prompt.Granted)
+}
+
+func TestOAuthServicePromptFails(t *testing.T) {
+	t.Parallel()
+
+	c := testClient(false)
+	otherRedirect := authorizePayload(c, "")
+	otherRedirect.RedirectURI = "https://evil.example.com"
+
+	tt := []struct {
+		name    string
+		store   *mockOAuthStore
+		payload authenticating.AuthorizePayload
+		err     string
+	}{
+		{"unknown client", &mockOAuthStore{err: userNotFoundMock("")}, authorizePayload(c, ""), "unknown client"},
+		{"invalid client id", &mockOAuthStore{client: c}, authenticating.AuthorizePayload{ClientID: "abc"}, "unknown client"},
+		{"unregistered redirect", &mockOAuthStore{client: c}, otherRedirect, "redirect_uri is not registered for the client"},
+		{"scope not allowed", &mockOAuthStore{client: c}, authorizePayload(c, "captures:write"), "scope is not allowed for the client"},
+		{"store err", &mockOAuthStore{err: errors.New("test")}, authorizePayload(c, ""), "could not get oauth client: test"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := authenticating.NewOAuthService(&mockClientTokenService{}, tc.store)
+			_, err := s.Prompt(&domain.User{}, tc.payload)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestOAuthServiceConsent(t *testing.T) {
+	t.Parallel()
+
+	c := testClient(false)
+	u := &domain.User{ID: kallax.NewULID()}
+	store := &mockOAuthStore{client: c}
+	s := authenticating.NewOAuthService(&mockClientTokenService{}, store)
+
+	approved := true
+	res, err := s.Consent(u, authorizePayload(c, "repos:read"), authenticating.ConsentPayload{Approved: &approved})
+	assert.Nil(t, err)
+	redirect, _ := url.Parse(res.RedirectURI)
+	assert.Equal(t, "app.example.com", redirect.Host)
+	assert.Equal(t, "xyz", redirect.Query().Get("state"))
+	code := redirect.Query().Get("code")
+	assert.NotEmpty(t, code)
+	assert.NotEqual(t, code, store.code.Hash)
+	assert.Equal(t, testChallenge, store.code.CodeChallenge)
+	assert.Equal(t, []domain.APIKeyScope{domain.ReposRead}, store.code.Scopes)
+	assert.Equal(t, u.ID, store.code.UserID)
+	assert.Equal(t, []domain.APIKeyScope{domain.ReposRead}, store.saved.Scopes)
+
+	denied := false
+	res, err = s.Consent(u, authorizePayload(c, ""), authenticating.ConsentPayload{Approved: &denied})
+	assert.Nil(t, err)
+	redirect, _ = url.Parse(res.RedirectURI)
+	assert.Equal(t, "access_denied", redirect.Query().Get("error"))
+	assert.Empty(t, redirect.Query().Get("code"))
+}
+
+func TestOAuthServiceExchangeCode(t *testing.T) {
+	t.Parallel()
+
+	c := testClient(false)
+	userID := kallax.NewULID()
+	code := &domain.OAuthCode{ClientID: c.ID, UserID: userID, RedirectURI: testRedirect, CodeChallenge: testChallenge, Scopes: []domain.APIKeyScope{domain.ReposRead}}
+	ts := &mockClientTokenService{}
+	s := authenticating.NewOAuthService(ts, &mockOAuthStore{client: c, code: code})
+	p := authenticating.TokenPayload{GrantType: "authorization_code", ClientID: c.ID.String(), Code: "code", RedirectURI: testRedirect, CodeVerifier: testVerifier}
+	tok, err := s.Exchange(p)
+	assert.Nil(t, err)
+	assert.Equal(t, "token", tok.AccessToken)
+	assert.Equal(t, "Bearer", tok.TokenType)
+	assert.Equal(t, 3600, tok.ExpiresIn)
+	assert.Equal(t, "repos:read", tok.Scope)
+	assert.Equal(t, userID.String(), ts.userID)
+	assert.Equal(t, c.ID.String(), ts.clientID)
+}
+
+func TestOAuthServiceExchangeClientCredentials(t *testing.T) {
+	t.Parallel()
+
+	store := &mockOAuthStore{}
+	s := authenticating.NewOAuthService(&mockClientTokenService{}, store)
+	name := "partner"
+	registered, err := s.RegisterClient(&domain.User{ID: kallax.NewULID()}, authenticating.ClientPayload{Name: &name, RedirectURIs: []string{testRedirect}, Scopes: []string{"repos:read", "captures:read"}, Confidential: true})
+	assert.Nil(t, err)
+
+	ts := &mockClientTokenService{}
+	s = authenticating.NewOAuthService(ts, store)
+	p := authenticating.TokenPayload{GrantType: "client_credentials", ClientID: registered.ID.String(), ClientSecret: registered.Secret, Scope: "captures:read"}
+	tok, err := s.Exchange(p)
+	assert.Nil(t, err)
+	assert.Equal(t, "captures:read", tok.Scope)
+	assert.Equal(t, registered.UserID.String(), ts.userID)
+}
+
+func TestOAuthServiceExchangeFails(t *testing.T) {
+	t.Parallel()
+
+	public := testClient(false)
+	confidential := testClient(true)
+	confidential.SecretHash = "hash"
+	otherClientCode := &domain.OAuthCode{ClientID: kallax.NewULID(), RedirectURI: testRedirect, CodeChallenge: testChallenge}
+	otherChallengeCode := &domain.OAuthCode{ClientID: public.ID, RedirectURI: testRedirect, CodeChallenge: "other"}
+	codePayload := authenticating.TokenPayload{GrantType: "authorization_code", ClientID: public.ID.String(), Code: "code", RedirectURI: testRedirect, CodeVerifier: testVerifier}
+
+	tt := []struct {
+		name    string
+		store   *mockOAuthStore
+		payload authenticating.TokenPayload
+		code    string
+	}{
+		{"unknown client", &mockOAuthStore{err: userNotFoundMock("")}, codePayload, "invalid_client"},
+		{"invalid secret", &mockOAuthStore{client: confidential}, authenticating.TokenPayload{GrantType: "client_credentials", ClientID: confidential.ID.String(), ClientSecret: "secret"}, "invalid_client"},
+		{"missing grant type", &mockOAuthStore{client: public}, authenticating.TokenPayload{ClientID: public.ID.String()}, "invalid_request"},
+		{"unsupported grant type", &mockOAuthStore{client: public}, authenticating.TokenPayload{GrantType: "password", ClientID: public.ID.String()}, "unsupported_grant_type"},
+		{"public client credentials", &mockOAuthStore{client: public}, authenticating.TokenPayload{GrantType: "client_credentials", ClientID: public.ID.String()}, "unauthorized_client"},
+		{"missing verifier", &mockOAuthStore{client: public}, authenticating.TokenPayload{GrantType: "authorization_code", ClientID: public.ID.String(), Code: "code", RedirectURI: testRedirect}, "invalid_request"},
+		{"used code", &mockOAuthStore{client: public, codeErr: userNotFoundMock("")}, codePayload, "invalid_grant"},
+		{"code of other client", &mockOAuthStore{client: public, code: otherClientCode}, codePayload, "invalid_grant"},
+		{"invalid verifier", &mockOAuthStore{client: public, code: otherChallengeCode}, codePayload, "invalid_grant"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := authenticating.NewOAuthService(&mockClientTokenService{}, tc.store)
+			_, err := s.Exchange(tc.payload)
+			e, ok := errors.Cause(err).(oauthErr)
+			assert.True(t, ok)
+			assert.Equal(t, tc.code, e.OAuthError())
+		})
+	}
+}
+
+func TestOAuthServiceConsents(t *testing.T) {
+	t.Parallel()
+
+	s := authenticating.NewOAuthService(&mockClientTokenService{}, &mockOAuthStore{})
+	consents, err := s.ListConsents(&domain.User{})
+	assert.Nil(t, err)
+	assert.NotNil(t, consents)
+	assert.Nil(t, s.RevokeConsent(&domain.User{}, kallax.NewULID()))
+
+	s = authenticating.NewOAuthService(&mockClientTokenService{}, &mockOAuthStore{err: errors.New("test")})
+	assert.EqualError(t, s.RevokeConsent(&domain.User{}, kallax.NewULID()), "could not revoke oauth consent: test")
+}
@@ -0,0 +1,87 @@
+package authenticating_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/authenticating"
+)
+
+func TestValidateClientPayloadOK(t *testing.T) {
+	t.Parallel()
+
+	name := "partner"
+	p := authenticating.ClientPayload{
+		Name:         &name,
+		RedirectURIs: []string{"https://app.example.com/callback", "http://127.0.0.1:8000/callback"},
+		Scopes:       []string{"repos:read", "captures:write"},
+	}
+	assert.Nil(t, p.Validate())
+}
+
+func TestValidateClientPayloadFails(t *testing.T) {
+	t.Parallel()
+
+	name, blank := "partner", " "
+	tt := []struct {
+		name    string
+		payload authenticating.ClientPayload
+		errs    []string
+	}{
+		{"missing all", authenticating.ClientPayload{}, []string{"name must not be blank", "redirectUris must contain at least one uri", "scopes must contain at least one scope"}},
+		{"blank name", authenticating.ClientPayload{Name: &blank, RedirectURIs: []string{"https://app.example.com"}, Scopes: []string{"repos:read"}}, []string{"name must not be blank"}},
+		{"http redirect", authenticating.ClientPayload{Name: &name, RedirectURIs: []string{"http://app.example.com/callback"}, Scopes: []string{"repos:read"}}, []string{"invalid redirect uri http://app.example.com/callback"}},
+		{"relative redirect", authenticating.ClientPayload{Name: &name, RedirectURIs: []string{"/callback"}, Scopes: []string{"repos:read"}}, []string{"invalid redirect uri /callback"}},
+		{"fragment redirect", authenticating.ClientPayload{Name: &name, RedirectURIs: []string{"https://app.example.com/#cb"}, Scopes: []string{"repos:read"}}, []string{"invalid redirect uri https://app.example.com/#cb"}},
+		{"invalid scope", authenticating.ClientPayload{Name: &name, RedirectURIs: []string{"https://app.example.com"}, Scopes: []string{"admin"}}, []string{"not allowed scope admin"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.payload.Validate()
+			for _, e := range tc.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
+
+func TestValidateAuthorizePayload(t *testing.T) {
+	t.Parallel()
+
+	q, _ := url.ParseQuery("response_type=code&client_id=abc&redirect_uri=https%3A%2F%2Fapp.example.com&scope=repos%3Aread&state=xyz&code_challenge=abc&code_challenge_method=S256")
+	p := authenticating.NewAuthorizePayload(q)
+	assert.Nil(t, p.Validate())
+	assert.Equal(t, "https://app.example.com", p.RedirectURI)
+	assert.Equal(t, "xyz", p.State)
+
+	invalid := authenticating.AuthorizePayload{ResponseType: "token", CodeChallengeMethod: "plain", Scope: "repos:read admin"}
+	err := invalid.Validate()
+	for _, e := range []string{
+		"response_type must be code",
+		"client_id must not be blank",
+		"redirect_uri must not be blank",
+		"code_challenge must not be blank",
+		"code_challenge_method must be S256",
+		"not allowed scope admin",
+	} {
+		assert.Contains(t, err.Error(), e)
+	}
+}
+
+func TestValidateConsentPayload(t *testing.T) {
+	t.Parallel()
+
+	approved := true
+	assert.Nil(t, (&authenticating.ConsentPayload{Approved: &approved}).Validate())
+	assert.EqualError(t, (&authenticating.ConsentPayload{}).Validate(), "approved must not be blank")
+}
+
+func TestS256Challenge(t *testing.T) {
+	t.Parallel()
+
+	// Referenced at https://tools.ietf.org/html/rfc7636#appendix-B
+	assert.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", authenticating.S256Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+}
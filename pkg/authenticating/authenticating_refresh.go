@@ -0,0 +1,29 @@
+package authenticating
+
+import "github.com/gobuffalo/validate"
+
+const errRefreshTokenRequired = "refresh token must not be blank"
+
+// RefreshPayload represents the request body to renew the access token.
+type RefreshPayload struct {
+	RefreshToken *string `json:"refreshToken"`
+}
+
+// OK implementation of validator.OK
+func (p *RefreshPayload) Validate() error {
+	e := validate.NewErrors()
+	if p.RefreshToken == nil || *p.RefreshToken == "" {
+		e.Add("refreshToken", errRefreshTokenRequired)
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// LogoutPayload represents the request body to close a session. When All is
+// true every refresh token of the user is revoked.
+type LogoutPayload struct {
+	RefreshToken *string `json:"refreshToken"`
+	All          bool    `json:"all"`
+}
@@ -0,0 +1,187 @@
+package authenticating
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	errInvalidRefreshToken invalidCredentialErr = "invalid refresh token"
+	errReusedRefreshToken  invalidCredentialErr = "refresh token reused"
+)
+
+// RefreshStore provides access to the refresh token storage.
+type RefreshStore interface {
+	// CreateRefreshToken stores a new refresh token.
+	CreateRefreshToken(*domain.RefreshToken) error
+	// GetRefreshToken get a refresh token by its hash.
+	GetRefreshToken(string) (*domain.RefreshToken, error)
+	// RevokeRefreshToken revokes the refresh token with the hash, replaced by another one when
+	// given, only if it wasn't revoked yet. It returns false when it was already revoked, so
+	// concurrent uses of a token revoke it only once.
+	RevokeRefreshToken(hash string, at time.Time, replacedBy *kallax.ULID) (bool, error)
+	// RevokeUserRefreshTokens revokes every active refresh token of an user.
+	RevokeUserRefreshTokens(kallax.ULID, time.Time) error
+}
+
+// RefreshTokenService provides utils to issue and revoke access tokens.
+type RefreshTokenService interface {
+	// GenerateToken an authorization token.
+	GenerateToken(string) (string, error)
+	// RevokeRequestToken denies the authorization token of a request.
+	RevokeRequestToken(*http.Request) error
+}
+
+// Tokens represents a pair of access and refresh tokens.
+type Tokens struct {
+	Token        string      `json:"token"`
+	RefreshToken string      `json:"refreshToken"`
+	UserID       kallax.ULID `json:"-"`
+}
+
+// RefreshService provides session operations over refresh tokens.
+type RefreshService interface {
+	// IssueRefreshToken creates a new refresh token for an user.
+	IssueRefreshToken(kallax.ULID) (string, error)
+	// Refresh exchanges a refresh token for a new pair of tokens, rotating the refresh token.
+	Refresh(RefreshPayload) (*Tokens, error)
+	// Logout revokes the access token of the request and the given refresh tokens.
+	Logout(*http.Request, *domain.User, LogoutPayload) error
+}
+
+type refreshService struct {
+	ts    RefreshTokenService
+	s     RefreshStore
+	delta time.Duration
+}
+
+// NewRefreshService creates a refresh service with the necessary dependencies
+func NewRefreshService(ts RefreshTokenService, s RefreshStore, delta time.Duration) RefreshService {
+	return &refreshService{ts: ts, s: s, delta: delta}
+}
+
+func (s *refreshService) IssueRefreshToken(userID kallax.ULID) (string, error) {
+	t, rt, err := newRefreshToken(userID, s.delta)
+	if err != nil {
+		return "", err
+	}
+	if err := s.s.CreateRefreshToken(rt); err != nil {
+		return "", errors.Wrap(err, "could not create refresh token")
+	}
+	return t, nil
+}
+
+func newRefreshToken(userID kallax.ULID, delta time.Duration) (string, *domain.RefreshToken, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, errors.Wrap(err, "could not generate refresh token")
+	}
+	t := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	rt := &domain.RefreshToken{
+		ID:        kallax.NewULID(),
+		Hash:      hashToken(t),
+		ExpiresAt: now.Add(delta),
+		CreatedAt: now,
+		UserID:    userID,
+	}
+	return t, rt, nil
+}
+
+func (s *refreshService) Refresh(p RefreshPayload) (*Tokens, error) {
+	if p.RefreshToken == nil {
+		return nil, errors.WithStack(errInvalidRefreshToken)
+	}
+	old, err := s.s.GetRefreshToken(hashToken(*p.RefreshToken))
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(errInvalidRefreshToken)
+		}
+		return nil, errors.Wrap(err, "could not get refresh token")
+	}
+
+	now := time.Now()
+	if old.RevokedAt != nil {
+		return nil, s.reused(old, now)
+	}
+	if !old.Active(now) {
+		return nil, errors.WithStack(errInvalidRefreshToken)
+	}
+
+	refresh, rt, err := newRefreshToken(old.UserID, s.delta)
+	if err != nil {
+		return nil, err
+	}
+	// the token is consumed before issuing the new one, only the first of concurrent uses succeeds.
+	consumed, err := s.s.RevokeRefreshToken(old.Hash, now, &rt.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not rotate refresh token")
+	}
+	if !consumed {
+		return nil, s.reused(old, now)
+	}
+	if err := s.s.CreateRefreshToken(rt); err != nil {
+		return nil, errors.Wrap(err, "could not create refresh token")
+	}
+
+	access, err := s.ts.GenerateToken(old.UserID.String())
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate token")
+	}
+	return &Tokens{Token: access, RefreshToken: refresh, UserID: old.UserID}, nil
+}
+
+func (s *refreshService) Logout(r *http.Request, u *domain.User, p LogoutPayload) error {
+	if err := s.ts.RevokeRequestToken(r); err != nil {
+		return errors.Wrap(err, "could not revoke token")
+	}
+
+	now := time.Now()
+	if p.All {
+		if err := s.s.RevokeUserRefreshTokens(u.ID, now); err != nil {
+			return errors.Wrap(err, "could not revoke refresh tokens")
+		}
+		return nil
+	}
+	if p.RefreshToken == nil {
+		return nil
+	}
+
+	rt, err := s.s.GetRefreshToken(hashToken(*p.RefreshToken))
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "could not get refresh token")
+	}
+	if rt.UserID != u.ID {
+		return nil
+	}
+	if _, err := s.s.RevokeRefreshToken(rt.Hash, now, nil); err != nil {
+		return errors.Wrap(err, "could not revoke refresh token")
+	}
+	return nil
+}
+
+// reused closes the whole session family of a revoked token presented again, as it leaked.
+func (s *refreshService) reused(rt *domain.RefreshToken, now time.Time) error {
+	if err := s.s.RevokeUserRefreshTokens(rt.UserID, now); err != nil {
+		return errors.Wrap(err, "could not revoke refresh tokens")
+	}
+	return errors.WithStack(errReusedRefreshToken)
+}
+
+func hashToken(t string) string {
+	sum := sha256.Sum256([]byte(t))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,231 @@
+package authenticating_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/authenticating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type mockRefreshStore struct {
+	tokens     map[string]*domain.RefreshToken
+	revokedFor *kallax.ULID
+	// afterGet runs once a token is read, as a concurrent request.
+	afterGet func(hash string)
+	err      error
+}
+
+func newMockRefreshStore() *mockRefreshStore {
+	return &mockRefreshStore{tokens: make(map[string]*domain.RefreshToken)}
+}
+
+func (m *mockRefreshStore) CreateRefreshToken(t *domain.RefreshToken) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.tokens[t.Hash] = t
+	return nil
+}
+func (m *mockRefreshStore) GetRefreshToken(hash string) (*domain.RefreshToken, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	t, ok := m.tokens[hash]
+	if !ok {
+		return nil, userNotFoundMock("test")
+	}
+	read := *t
+	if m.afterGet != nil {
+		m.afterGet(hash)
+	}
+	return &read, nil
+}
+func (m *mockRefreshStore) RevokeRefreshToken(hash string, at time.Time, replacedBy *kallax.ULID) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	t, ok := m.tokens[hash]
+	if !ok || t.RevokedAt != nil {
+		return false, nil
+	}
+	t.RevokedAt, t.ReplacedBy = &at, replacedBy
+	return true, nil
+}
+func (m *mockRefreshStore) RevokeUserRefreshTokens(userID kallax.ULID, at time.Time) error {
+	m.revokedFor = &userID
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			t.RevokedAt = &at
+		}
+	}
+	return m.err
+}
+
+type mockRefreshTokenService struct {
+	token   string
+	revoked bool
+	err     error
+}
+
+func (m *mockRefreshTokenService) GenerateToken(string) (string, error) { return m.token, m.err }
+func (m *mockRefreshTokenService) RevokeRequestToken(*http.Request) error {
+	m.revoked = true
+	return m.err
+}
+
+func s2P(s string) *string { return &s }
+
+func TestRefreshServiceIssueRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	store := newMockRefreshStore()
+	s := authenticating.NewRefreshService(&mockRefreshTokenService{}, store, time.Hour)
+	userID := kallax.NewULID()
+	tok, err := s.IssueRefreshToken(userID)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, tok)
+	assert.Len(t, store.tokens, 1)
+	for hash, rt := range store.tokens {
+		assert.NotEqual(t, tok, hash)
+		assert.Equal(t, userID, rt.UserID)
+		assert.True(t, rt.Active(time.Now()))
+	}
+}
+
+func TestRefreshServiceRefreshRotatesToken(t *testing.T) {
+	t.Parallel()
+
+	store := newMockRefreshStore()
+	s := authenticating.NewRefreshService(&mockRefreshTokenService{token: "access"}, store, time.Hour)
+	first, err := s.IssueRefreshToken(kallax.NewULID())
+	assert.Nil(t, err)
+
+	tokens, err := s.Refresh(authenticating.RefreshPayload{RefreshToken: s2P(first)})
+	assert.Nil(t, err)
+	assert.Equal(t, "access", tokens.Token)
+	assert.NotEqual(t, first, tokens.RefreshToken)
+	assert.Len(t, store.tokens, 2)
+
+	_, err = s.Refresh(authenticating.RefreshPayload{RefreshToken: s2P(tokens.RefreshToken)})
+	assert.Nil(t, err)
+}
+
+func TestRefreshServiceRefreshReuseRevokesAll(t *testing.T) {
+	t.Parallel()
+
+	store := newMockRefreshStore()
+	s := authenticating.NewRefreshService(&mockRefreshTokenService{token: "access"}, store, time.Hour)
+	userID := kallax.NewULID()
+	first, _ := s.IssueRefreshToken(userID)
+	tokens, err := s.Refresh(authenticating.RefreshPayload{RefreshToken: s2P(first)})
+	assert.Nil(t, err)
+
+	_, err = s.Refresh(authenticating.RefreshPayload{RefreshToken: s2P(first)})
+	assert.EqualError(t, err, "refresh token reused")
+	authErr, ok := errors.Cause(err).(authenticatingErr)
+	assert.True(t, ok)
+	assert.True(t, authErr.InvalidCredentials())
+	assert.Equal(t, userID, *store.revokedFor)
+
+	_, err = s.Refresh(authenticating.RefreshPayload{RefreshToken: s2P(tokens.RefreshToken)})
+	assert.EqualError(t, err, "refresh token reused")
+}
+
+func TestRefreshServiceRefreshConcurrentUse(t *testing.T) {
+	t.Parallel()
+
+	store := newMockRefreshStore()
+	s := authenticating.NewRefreshService(&mockRefreshTokenService{token: "access"}, store, time.Hour)
+	userID := kallax.NewULID()
+	first, _ := s.IssueRefreshToken(userID)
+	store.afterGet = func(hash string) {
+		now := time.Now()
+		store.tokens[hash].RevokedAt = &now
+	}
+
+	_, err := s.Refresh(authenticating.RefreshPayload{RefreshToken: s2P(first)})
+	assert.EqualError(t, err, "refresh token reused")
+	assert.Equal(t, userID, *store.revokedFor)
+	assert.Len(t, store.tokens, 1)
+}
+
+func TestRefreshServiceRefreshFailsInvalidToken(t *testing.T) {
+	t.Parallel()
+
+	store := newMockRefreshStore()
+	s := authenticating.NewRefreshService(&mockRefreshTokenService{}, store, -time.Hour)
+	expired, _ := s.IssueRefreshToken(kallax.NewULID())
+
+	tt := []struct {
+		name    string
+		payload authenticating.RefreshPayload
+	}{
+		{"missing", authenticating.RefreshPayload{}},
+		{"unknown", authenticating.RefreshPayload{RefreshToken: s2P("abc")}},
+		{"expired", authenticating.RefreshPayload{RefreshToken: s2P(expired)}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.Refresh(tc.payload)
+			assert.EqualError(t, err, "invalid refresh token")
+			authErr, ok := errors.Cause(err).(authenticatingErr)
+			assert.True(t, ok)
+			assert.True(t, authErr.InvalidCredentials())
+		})
+	}
+}
+
+func TestRefreshServiceErrors(t *testing.T) {
+	t.Parallel()
+
+	store := newMockRefreshStore()
+	store.err = errors.New("test")
+	s := authenticating.NewRefreshService(&mockRefreshTokenService{}, store, time.Hour)
+
+	_, err := s.IssueRefreshToken(kallax.NewULID())
+	assert.EqualError(t, err, "could not create refresh token: test")
+	_, err = s.Refresh(authenticating.RefreshPayload{RefreshToken: s2P("abc")})
+	assert.EqualError(t, err, "could not get refresh token: test")
+}
+
+func TestRefreshServiceLogout(t *testing.T) {
+	t.Parallel()
+
+	u := &domain.User{ID: kallax.NewULID()}
+	req, _ := http.NewRequest("POST", "/", nil)
+
+	store := newMockRefreshStore()
+	ts := &mockRefreshTokenService{}
+	s := authenticating.NewRefreshService(ts, store, time.Hour)
+	tok, _ := s.IssueRefreshToken(u.ID)
+	other, _ := s.IssueRefreshToken(kallax.NewULID())
+
+	assert.Nil(t, s.Logout(req, u, authenticating.LogoutPayload{RefreshToken: s2P(other)}))
+	assert.True(t, ts.revoked)
+	for _, rt := range store.tokens {
+		assert.Nil(t, rt.RevokedAt)
+	}
+
+	assert.Nil(t, s.Logout(req, u, authenticating.LogoutPayload{RefreshToken: s2P(tok)}))
+	_, err := s.Refresh(authenticating.RefreshPayload{RefreshToken: s2P(tok)})
+	assert.EqualError(t, err, "refresh token reused")
+
+	assert.Nil(t, s.Logout(req, u, authenticating.LogoutPayload{All: true}))
+	assert.Equal(t, u.ID, *store.revokedFor)
+}
+
+func TestRefreshServiceLogoutFailsRevokingToken(t *testing.T) {
+	t.Parallel()
+
+	s := authenticating.NewRefreshService(&mockRefreshTokenService{err: errors.New("test")}, newMockRefreshStore(), time.Hour)
+	req, _ := http.NewRequest("POST", "/", nil)
+	err := s.Logout(req, &domain.User{}, authenticating.LogoutPayload{})
+	assert.EqualError(t, err, "could not revoke token: test")
+}
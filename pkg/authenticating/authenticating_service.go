@@ -2,6 +2,8 @@ package authenticating
 
 import (
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"golang.org/x/crypto/bcrypt"
@@ -27,12 +29,67 @@ type invalidCredentialErr string
 func (i invalidCredentialErr) Error() string            { return fmt.Sprintf(string(i)) }
 func (i invalidCredentialErr) InvalidCredentials() bool { return true }
 
-const errInvalidPassword invalidCredentialErr = "invalid password"
+const (
+	errInvalidPassword  invalidCredentialErr = "invalid password"
+	errInvalidChallenge invalidCredentialErr = "invalid or expired challenge"
+	errInvalidCode      invalidCredentialErr = "invalid two-factor code"
+	errDisabledAccount  invalidCredentialErr = "account disabled"
+)
+
+type throttledErr struct {
+	msg        string
+	retryAfter time.Duration
+}
+
+func (e throttledErr) Error() string             { return e.msg }
+func (e throttledErr) RetryAfter() time.Duration { return e.retryAfter }
+
+func newThrottledErr(retryAfter time.Duration, locked bool) throttledErr {
+	if locked {
+		return throttledErr{msg: "account temporarily locked by too many failed login attempts", retryAfter: retryAfter}
+	}
+	return throttledErr{msg: "too many failed login attempts", retryAfter: retryAfter}
+}
 
 // Store provides access to the user storage.
 type Store interface {
 	// GetUserByEmail get user by email.
 	GetUserByEmail(string) (*domain.User, error)
+	// GetUserByID get user by id.
+	GetUserByID(kallax.ULID) (*domain.User, error)
+	// UpdateUser saves the changes of a user.
+	UpdateUser(*domain.User) error
+}
+
+// ThrottleStore provides access to the failed login attempts storage.
+type ThrottleStore interface {
+	// GetLoginThrottles get the failed attempts of the given keys.
+	GetLoginThrottles(...string) ([]domain.LoginThrottle, error)
+	// FailLoginThrottle atomically records a failed attempt of a key at the given time,
+	// restarting the count when the last failure was before the window start or when its
+	// lock expired, and returns the updated attempts.
+	FailLoginThrottle(key string, at, windowStart time.Time) (*domain.LoginThrottle, error)
+	// LockLoginThrottle locks the attempts of a key until the given time.
+	LockLoginThrottle(key string, until time.Time) error
+	// RemoveLoginThrottles forgets the failed attempts of the given keys.
+	RemoveLoginThrottles(...string) error
+}
+
+// ChallengeStore provides access to the pending logins storage.
+type ChallengeStore interface {
+	// CreateUserToken stores a new user token.
+	CreateUserToken(*domain.UserToken) error
+	// GetActiveUserToken get the unused and unexpired token with the given hash and purpose.
+	GetActiveUserToken(string, domain.UserTokenPurpose, time.Time) (*domain.UserToken, error)
+	// ConsumeUserToken marks as used the unused and unexpired token with the given
+	// hash and purpose, returning it. Only one caller can consume a token.
+	ConsumeUserToken(string, domain.UserTokenPurpose, time.Time) (*domain.UserToken, error)
+}
+
+// SecondFactor checks the two-factor codes of the users.
+type SecondFactor interface {
+	// CheckCode reports whether a TOTP or a recovery code is valid for the user.
+	CheckCode(*domain.User, string) (bool, error)
 }
 
 // TokenService provides utils to handle authentication token.
@@ -43,19 +100,36 @@ type TokenService interface {
 
 // Service provides authenticating operations.
 type Service interface {
-	// AuthenticateUser compare the given credentials with the stored ones.
-	AuthenticateUser(BasicCredential) (*domain.User, error)
+	// AuthenticateUser compare the given credentials with the stored ones. The failed
+	// attempts of the email and of the client ip are delayed and locked out. Users with
+	// the two-factor authentication enabled get a pending login with a challenge.
+	AuthenticateUser(BasicCredential, string) (*Login, error)
+	// CompleteChallenge checks the second factor of a pending login, returning its user.
+	// The failed attempts are throttled like the credentials ones.
+	CompleteChallenge(ChallengePayload, string) (*domain.User, error)
 	GetUserToken(kallax.ULID) (string, error)
+	// Unlock forgets the failed login attempts of an email or a client ip.
+	Unlock(UnlockPayload) error
 }
 
 type service struct {
-	s  Store
-	ts TokenService
+	s      Store
+	ts     TokenService
+	ls     ThrottleStore
+	cs     ChallengeStore
+	sf     SecondFactor
+	policy ThrottlePolicy
+	admins map[string]bool
 }
 
-// NewService creates an authenticating service with the necessary dependencies
-func NewService(ts TokenService, s Store) Service {
-	return &service{ts: ts, s: s}
+// NewService creates an authenticating service with the necessary dependencies. The
+// users logging in with one of the admins emails, once verified, become administrators.
+func NewService(ts TokenService, s Store, ls ThrottleStore, cs ChallengeStore, sf SecondFactor, admins ...string) Service {
+	emails := make(map[string]bool, len(admins))
+	for _, email := range admins {
+		emails[strings.ToLower(strings.TrimSpace(email))] = true
+	}
+	return &service{ts: ts, s: s, ls: ls, cs: cs, sf: sf, policy: DefaultThrottlePolicy, admins: emails}
 }
 
 // GenerateToken creates a new token
@@ -67,22 +141,193 @@ func (s *service) GetUserToken(userID kallax.ULID) (string, error) {
 	return t, nil
 }
 
-func (s *service) AuthenticateUser(credential BasicCredential) (*domain.User, error) {
+func (s *service) AuthenticateUser(credential BasicCredential, ip string) (*Login, error) {
+	now := time.Now()
+	keys := throttleKeys(credential.Email, ip)
+	throttles, err := s.throttles(keys, now)
+	if err != nil {
+		return nil, err
+	}
+
 	u, err := s.s.GetUserByEmail(credential.Email)
 	if err != nil {
 		if isNotFound(err) {
-			return nil, errors.WithStack(invalidCredentialErr(err.Error()))
+			return nil, s.fail(keys, now, invalidCredentialErr(err.Error()))
 		}
 		return nil, err
 	}
 
 	if !checkPassword(u.Password, []byte(credential.Password)) {
-		return nil, errors.WithStack(errInvalidPassword)
+		return nil, s.fail(keys, now, errInvalidPassword)
+	}
+	if u.Disabled() {
+		return nil, errors.WithStack(errDisabledAccount)
+	}
+
+	if u.TwoFactorEnabled() {
+		challenge, err := s.challenge(u, now)
+		if err != nil {
+			return nil, err
+		}
+		return &Login{User: u, Challenge: challenge}, nil
+	}
+
+	if err := s.reset(keys[0], throttles); err != nil {
+		return nil, err
+	}
+	if err := s.promote(u); err != nil {
+		return nil, err
+	}
+	return &Login{User: u}, nil
+}
+
+func (s *service) CompleteChallenge(p ChallengePayload, ip string) (*domain.User, error) {
+	now := time.Now()
+	hash := hashToken(*p.Challenge)
+	ut, err := s.cs.GetActiveUserToken(hash, domain.TwoFactorPurpose, now)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(errInvalidChallenge)
+		}
+		return nil, errors.Wrap(err, "could not get challenge")
+	}
+	u, err := s.s.GetUserByID(ut.UserID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(errInvalidChallenge)
+		}
+		return nil, errors.Wrap(err, "could not get user")
+	}
+	if u.Disabled() {
+		return nil, errors.WithStack(errDisabledAccount)
+	}
+
+	keys := throttleKeys(u.Email, ip)
+	throttles, err := s.throttles(keys, now)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.sf.CheckCode(u, *p.Code)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not check two-factor code")
+	}
+	if !ok {
+		return nil, s.fail(keys, now, errInvalidCode)
 	}
 
+	if _, err := s.cs.ConsumeUserToken(hash, domain.TwoFactorPurpose, now); err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(errInvalidChallenge)
+		}
+		return nil, errors.Wrap(err, "could not consume challenge")
+	}
+	if err := s.reset(keys[0], throttles); err != nil {
+		return nil, err
+	}
+	if err := s.promote(u); err != nil {
+		return nil, err
+	}
 	return u, nil
 }
 
+func throttleKeys(email, ip string) []string {
+	keys := []string{emailThrottleKey(email)}
+	if ip != "" {
+		keys = append(keys, ipThrottleKey(ip))
+	}
+	return keys
+}
+
+// throttles returns the failed attempts of the keys, failing when any of them must wait.
+func (s *service) throttles(keys []string, now time.Time) ([]domain.LoginThrottle, error) {
+	throttles, err := s.ls.GetLoginThrottles(keys...)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get login attempts")
+	}
+	for _, t := range throttles {
+		if wait, locked := s.policy.retryAfter(t, now); wait > 0 {
+			return nil, errors.WithStack(newThrottledErr(wait, locked))
+		}
+	}
+	return throttles, nil
+}
+
+// reset forgets the failed attempts of key after a successful login.
+func (s *service) reset(key string, throttles []domain.LoginThrottle) error {
+	for _, t := range throttles {
+		if t.Key == key {
+			if err := s.ls.RemoveLoginThrottles(t.Key); err != nil {
+				return errors.Wrap(err, "could not reset login attempts")
+			}
+		}
+	}
+	return nil
+}
+
+// promote makes an administrator of the user logging in with a verified admin email.
+func (s *service) promote(u *domain.User) error {
+	if u.Admin || !u.EmailVerified() || !s.admins[strings.ToLower(u.Email)] {
+		return nil
+	}
+	u.Admin = true
+	if err := s.s.UpdateUser(u); err != nil {
+		u.Admin = false
+		return errors.Wrap(err, "could not promote user to admin")
+	}
+	return nil
+}
+
+// challenge issues the token identifying a login waiting for its second factor.
+func (s *service) challenge(u *domain.User, now time.Time) (string, error) {
+	t, err := randomToken()
+	if err != nil {
+		return "", errors.Wrap(err, "could not generate challenge")
+	}
+	ut := &domain.UserToken{
+		ID:        kallax.NewULID(),
+		Hash:      hashToken(t),
+		Purpose:   domain.TwoFactorPurpose,
+		ExpiresAt: now.Add(ChallengeExpiration),
+		CreatedAt: now,
+		UserID:    u.ID,
+	}
+	if err := s.cs.CreateUserToken(ut); err != nil {
+		return "", errors.Wrap(err, "could not create challenge")
+	}
+	return t, nil
+}
+
+// fail records a failed attempt for every key, locking the keys reaching their lockout,
+// and returns the authentication error.
+func (s *service) fail(keys []string, now time.Time, authErr error) error {
+	for _, key := range keys {
+		t, err := s.ls.FailLoginThrottle(key, now, now.Add(-s.policy.Window))
+		if err != nil {
+			return errors.Wrap(err, "could not save login attempt")
+		}
+		if until := s.policy.lockedUntil(*t, now); until != nil {
+			if err := s.ls.LockLoginThrottle(key, *until); err != nil {
+				return errors.Wrap(err, "could not lock login attempts")
+			}
+		}
+	}
+	return errors.WithStack(authErr)
+}
+
+func (s *service) Unlock(p UnlockPayload) error {
+	var keys []string
+	if p.Email != nil {
+		keys = append(keys, emailThrottleKey(*p.Email))
+	}
+	if p.IP != nil {
+		keys = append(keys, ipThrottleKey(*p.IP))
+	}
+	if err := s.ls.RemoveLoginThrottles(keys...); err != nil {
+		return errors.Wrap(err, "could not unlock login attempts")
+	}
+	return nil
+}
+
 func checkPassword(hashed, pass []byte) bool {
 	if err := bcrypt.CompareHashAndPassword(hashed, pass); err != nil {
 		return false
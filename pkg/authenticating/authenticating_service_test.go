@@ -3,6 +3,7 @@ package authenticating_test
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
@@ -20,11 +21,21 @@ func (u userNotFoundMock) Error() string  { return fmt.Sprint("test") }
 func (u userNotFoundMock) NotFound() bool { return true }
 
 type mockStore struct {
-	usr *domain.User
-	err error
+	usr       *domain.User
+	err       error
+	updated   *domain.User
+	updateErr error
 }
 
-func (m *mockStore) GetUserByEmail(string) (*domain.User, error) { return m.usr, m.err }
+func (m *mockStore) GetUserByEmail(string) (*domain.User, error)   { return m.usr, m.err }
+func (m *mockStore) GetUserByID(kallax.ULID) (*domain.User, error) { return m.usr, m.err }
+func (m *mockStore) UpdateUser(u *domain.User) error {
+	if m.updateErr != nil {
+		return m.updateErr
+	}
+	m.updated = u
+	return nil
+}
 
 type mockTokenService struct {
 	token string
@@ -33,10 +44,48 @@ type mockTokenService struct {
 
 func (m *mockTokenService) GenerateToken(string) (string, error) { return m.token, m.err }
 
+type mockThrottleStore struct {
+	throttles []domain.LoginThrottle
+	saved     []domain.LoginThrottle
+	removed   []string
+	err       error
+}
+
+func (m *mockThrottleStore) GetLoginThrottles(...string) ([]domain.LoginThrottle, error) {
+	return m.throttles, m.err
+}
+func (m *mockThrottleStore) FailLoginThrottle(key string, at, windowStart time.Time) (*domain.LoginThrottle, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	t := domain.LoginThrottle{Key: key}
+	for _, stored := range m.throttles {
+		if stored.Key == key && !stored.LastFailureAt.Before(windowStart) && (stored.LockedUntil == nil || stored.Locked(at)) {
+			t = stored
+		}
+	}
+	t.Failures++
+	t.LastFailureAt = at
+	m.saved = append(m.saved, t)
+	return &t, nil
+}
+func (m *mockThrottleStore) LockLoginThrottle(key string, until time.Time) error {
+	for i := range m.saved {
+		if m.saved[i].Key == key {
+			m.saved[i].LockedUntil = &until
+		}
+	}
+	return m.err
+}
+func (m *mockThrottleStore) RemoveLoginThrottles(keys ...string) error {
+	m.removed = append(m.removed, keys...)
+	return m.err
+}
+
 func TestAuthenticatingServiceGenerateToken(t *testing.T) {
 	t.Parallel()
 
-	s := authenticating.NewService(&mockTokenService{token: "0162eb39-a65e-04a1-7ad9-d663bb49a396"}, &mockStore{})
+	s := authenticating.NewService(&mockTokenService{token: "0162eb39-a65e-04a1-7ad9-d663bb49a396"}, &mockStore{}, &mockThrottleStore{}, &mockChallengeStore{}, &mockSecondFactor{})
 	result, err := s.GetUserToken(kallax.NewULID())
 	assert.Nil(t, err)
 	assert.Equal(t, "0162eb39-a65e-04a1-7ad9-d663bb49a396", result)
@@ -45,7 +94,7 @@ func TestAuthenticatingServiceGenerateToken(t *testing.T) {
 func TestAuthenticatingServiceGenerateTokenErr(t *testing.T) {
 	t.Parallel()
 
-	s := authenticating.NewService(&mockTokenService{token: "", err: errors.New("test")}, &mockStore{})
+	s := authenticating.NewService(&mockTokenService{token: "", err: errors.New("test")}, &mockStore{}, &mockThrottleStore{}, &mockChallengeStore{}, &mockSecondFactor{})
 	_, err := s.GetUserToken(kallax.NewULID())
 	assert.Error(t, err)
 }
@@ -55,16 +104,59 @@ func TestAuthenticatingService(t *testing.T) {
 
 	credential := authenticating.BasicCredential{Password: "secret"}
 	mockUser := &domain.User{Password: []byte("$2a$14$ajq8Q7fbtFRQvXpdCq7Jcuy.Rx1h/L4J60Otx.gyNLbAYctGMJ9tK")}
-	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: mockUser})
-	result, err := s.AuthenticateUser(credential)
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: mockUser}, &mockThrottleStore{}, &mockChallengeStore{}, &mockSecondFactor{})
+	result, err := s.AuthenticateUser(credential, "")
 	assert.Nil(t, err)
-	assert.Equal(t, mockUser, result)
+	assert.Equal(t, mockUser, result.User)
+	assert.False(t, result.Pending())
+}
+
+func TestAuthenticatingServicePromoteAdmin(t *testing.T) {
+	t.Parallel()
+
+	credential := authenticating.BasicCredential{Password: "secret"}
+	hash := []byte("$2a$14$ajq8Q7fbtFRQvXpdCq7Jcuy.Rx1h/L4J60Otx.gyNLbAYctGMJ9tK")
+	verifiedAt := time.Now()
+
+	tt := []struct {
+		name     string
+		user     *domain.User
+		promoted bool
+	}{
+		{"verified admin email", &domain.User{Email: "Admin@example.com", Password: hash, EmailVerifiedAt: &verifiedAt}, true},
+		{"unverified admin email", &domain.User{Email: "admin@example.com", Password: hash}, false},
+		{"other email", &domain.User{Email: "test@example.com", Password: hash, EmailVerifiedAt: &verifiedAt}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &mockStore{usr: tc.user}
+			s := authenticating.NewService(&mockTokenService{}, store, &mockThrottleStore{}, &mockChallengeStore{}, &mockSecondFactor{}, "admin@example.com")
+			result, err := s.AuthenticateUser(credential, "")
+			assert.Nil(t, err)
+			assert.Equal(t, tc.promoted, result.User.Admin)
+			assert.Equal(t, tc.promoted, store.updated != nil)
+		})
+	}
+}
+
+func TestAuthenticatingServicePromoteAdminFails(t *testing.T) {
+	t.Parallel()
+
+	credential := authenticating.BasicCredential{Password: "secret"}
+	verifiedAt := time.Now()
+	u := &domain.User{Email: "admin@example.com", Password: []byte("$2a$14$ajq8Q7fbtFRQvXpdCq7Jcuy.Rx1h/L4J60Otx.gyNLbAYctGMJ9tK"), EmailVerifiedAt: &verifiedAt}
+	store := &mockStore{usr: u, updateErr: errors.New("test")}
+	s := authenticating.NewService(&mockTokenService{}, store, &mockThrottleStore{}, &mockChallengeStore{}, &mockSecondFactor{}, "admin@example.com")
+	_, err := s.AuthenticateUser(credential, "")
+	assert.EqualError(t, err, "could not promote user to admin: test")
+	assert.False(t, u.Admin)
 }
 
 func TestAuthenticatingServiceFailWhenUserNotFound(t *testing.T) {
 	t.Parallel()
-	s := authenticating.NewService(&mockTokenService{}, &mockStore{err: userNotFoundMock("")})
-	_, err := s.AuthenticateUser(authenticating.BasicCredential{})
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{err: userNotFoundMock("")}, &mockThrottleStore{}, &mockChallengeStore{}, &mockSecondFactor{})
+	_, err := s.AuthenticateUser(authenticating.BasicCredential{}, "")
 	assert.EqualError(t, err, "test")
 	authErr, ok := errors.Cause(err).(authenticatingErr)
 	assert.True(t, ok)
@@ -74,8 +166,8 @@ func TestAuthenticatingServiceFailWhenUserNotFound(t *testing.T) {
 
 func TestAuthenticatingServiceFailError(t *testing.T) {
 	t.Parallel()
-	s := authenticating.NewService(&mockTokenService{}, &mockStore{err: errors.New("test")})
-	_, err := s.AuthenticateUser(authenticating.BasicCredential{})
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{err: errors.New("test")}, &mockThrottleStore{}, &mockChallengeStore{}, &mockSecondFactor{})
+	_, err := s.AuthenticateUser(authenticating.BasicCredential{}, "")
 	assert.EqualError(t, err, "test")
 }
 
@@ -84,10 +176,281 @@ func TestAuthenticatingServiceFailInvalidPassword(t *testing.T) {
 
 	credential := authenticating.BasicCredential{Password: "secret2"}
 	mockUser := &domain.User{Password: []byte("$2a$14$ajq8Q7fbtFRQvXpdCq7Jcuy.Rx1h/L4J60Otx.gyNLbAYctGMJ9tK")}
-	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: mockUser})
-	_, err := s.AuthenticateUser(credential)
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: mockUser}, &mockThrottleStore{}, &mockChallengeStore{}, &mockSecondFactor{})
+	_, err := s.AuthenticateUser(credential, "")
 	assert.EqualError(t, err, "invalid password")
 	authErr, ok := errors.Cause(err).(authenticatingErr)
 	assert.True(t, ok)
 	assert.True(t, authErr.InvalidCredentials())
 }
+
+func TestAuthenticatingServiceFailDisabledAccount(t *testing.T) {
+	t.Parallel()
+
+	disabledAt := time.Now()
+	credential := authenticating.BasicCredential{Password: "secret"}
+	mockUser := &domain.User{Password: []byte("$2a$14$ajq8Q7fbtFRQvXpdCq7Jcuy.Rx1h/L4J60Otx.gyNLbAYctGMJ9tK"), DisabledAt: &disabledAt}
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: mockUser}, &mockThrottleStore{}, &mockChallengeStore{}, &mockSecondFactor{})
+	_, err := s.AuthenticateUser(credential, "")
+	assert.EqualError(t, err, "account disabled")
+	authErr, ok := errors.Cause(err).(authenticatingErr)
+	assert.True(t, ok)
+	assert.True(t, authErr.InvalidCredentials())
+}
+
+type throttledErr interface{ RetryAfter() time.Duration }
+
+const hashedSecret = "$2a$14$ajq8Q7fbtFRQvXpdCq7Jcuy.Rx1h/L4J60Otx.gyNLbAYctGMJ9tK"
+
+func TestAuthenticatingServiceThrottled(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	lockedUntil := now.Add(10 * time.Minute)
+	tt := []struct {
+		name      string
+		throttles []domain.LoginThrottle
+		err       string
+		wait      time.Duration
+	}{
+		{
+			name:      "delayed email",
+			throttles: []domain.LoginThrottle{{Key: "email:test@example.com", Failures: 5, LastFailureAt: now}},
+			err:       "too many failed login attempts",
+			wait:      2 * time.Second,
+		},
+		{
+			name:      "locked ip",
+			throttles: []domain.LoginThrottle{{Key: "ip:127.0.0.1", Failures: 50, LastFailureAt: now, LockedUntil: &lockedUntil}},
+			err:       "account temporarily locked by too many failed login attempts",
+			wait:      10 * time.Minute,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			credential := authenticating.BasicCredential{Email: "test@example.com", Password: "secret"}
+			s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: &domain.User{Password: []byte(hashedSecret)}}, &mockThrottleStore{throttles: tc.throttles}, &mockChallengeStore{}, &mockSecondFactor{})
+			_, err := s.AuthenticateUser(credential, "127.0.0.1")
+			assert.EqualError(t, err, tc.err)
+			throttled, ok := errors.Cause(err).(throttledErr)
+			assert.True(t, ok)
+			assert.InDelta(t, tc.wait.Seconds(), throttled.RetryAfter().Seconds(), 1)
+		})
+	}
+}
+
+func TestAuthenticatingServiceAllowedAfterDelay(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	expiredLock := now.Add(-time.Minute)
+	throttles := []domain.LoginThrottle{
+		{Key: "email:test@example.com", Failures: 4, LastFailureAt: now.Add(-2 * time.Second)},
+		{Key: "ip:127.0.0.1", Failures: 50, LastFailureAt: now.Add(-20 * time.Minute), LockedUntil: &expiredLock},
+	}
+	store := &mockThrottleStore{throttles: throttles}
+	credential := authenticating.BasicCredential{Email: "test@example.com", Password: "secret"}
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: &domain.User{Password: []byte(hashedSecret)}}, store, &mockChallengeStore{}, &mockSecondFactor{})
+	_, err := s.AuthenticateUser(credential, "127.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"email:test@example.com"}, store.removed)
+}
+
+func TestAuthenticatingServiceRecordsFailures(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	store := &mockThrottleStore{throttles: []domain.LoginThrottle{
+		{Key: "email:test@example.com", Failures: 9, LastFailureAt: now.Add(-time.Hour + time.Minute)},
+	}}
+	credential := authenticating.BasicCredential{Email: "Test@example.com", Password: "secret2"}
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: &domain.User{Password: []byte(hashedSecret)}}, store, &mockChallengeStore{}, &mockSecondFactor{})
+	_, err := s.AuthenticateUser(credential, "127.0.0.1")
+	assert.EqualError(t, err, "invalid password")
+	assert.Len(t, store.saved, 2)
+
+	email, ip := store.saved[0], store.saved[1]
+	assert.Equal(t, "email:test@example.com", email.Key)
+	assert.Equal(t, 10, email.Failures)
+	assert.NotNil(t, email.LockedUntil)
+	assert.Equal(t, "ip:127.0.0.1", ip.Key)
+	assert.Equal(t, 1, ip.Failures)
+	assert.Nil(t, ip.LockedUntil)
+	assert.Empty(t, store.removed)
+}
+
+func TestAuthenticatingServiceForgetsOldFailures(t *testing.T) {
+	t.Parallel()
+
+	store := &mockThrottleStore{throttles: []domain.LoginThrottle{
+		{Key: "email:test@example.com", Failures: 9, LastFailureAt: time.Now().Add(-2 * time.Hour)},
+	}}
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{err: userNotFoundMock("")}, store, &mockChallengeStore{}, &mockSecondFactor{})
+	_, err := s.AuthenticateUser(authenticating.BasicCredential{Email: "test@example.com"}, "")
+	assert.EqualError(t, err, "test")
+	assert.Len(t, store.saved, 1)
+	assert.Equal(t, 1, store.saved[0].Failures)
+	assert.Nil(t, store.saved[0].LockedUntil)
+}
+
+func TestAuthenticatingServiceThrottleStoreErr(t *testing.T) {
+	t.Parallel()
+
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{}, &mockThrottleStore{err: errors.New("test")}, &mockChallengeStore{}, &mockSecondFactor{})
+	_, err := s.AuthenticateUser(authenticating.BasicCredential{}, "")
+	assert.EqualError(t, err, "could not get login attempts: test")
+}
+
+func TestAuthenticatingServiceUnlock(t *testing.T) {
+	t.Parallel()
+
+	email, ip := "Test@example.com", "127.0.0.1"
+	store := &mockThrottleStore{}
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{}, store, &mockChallengeStore{}, &mockSecondFactor{})
+	assert.Nil(t, s.Unlock(authenticating.UnlockPayload{Email: &email, IP: &ip}))
+	assert.Equal(t, []string{"email:test@example.com", "ip:127.0.0.1"}, store.removed)
+
+	s = authenticating.NewService(&mockTokenService{}, &mockStore{}, &mockThrottleStore{err: errors.New("test")}, &mockChallengeStore{}, &mockSecondFactor{})
+	assert.EqualError(t, s.Unlock(authenticating.UnlockPayload{Email: &email}), "could not unlock login attempts: test")
+}
+
+type mockChallengeStore struct {
+	created  *domain.UserToken
+	token    *domain.UserToken
+	consumed bool
+	err      error
+}
+
+func (m *mockChallengeStore) CreateUserToken(t *domain.UserToken) error {
+	m.created = t
+	return m.err
+}
+func (m *mockChallengeStore) GetActiveUserToken(string, domain.UserTokenPurpose, time.Time) (*domain.UserToken, error) {
+	return m.token, m.err
+}
+func (m *mockChallengeStore) ConsumeUserToken(string, domain.UserTokenPurpose, time.Time) (*domain.UserToken, error) {
+	m.consumed = true
+	return m.token, m.err
+}
+
+type mockSecondFactor struct {
+	ok  bool
+	err error
+}
+
+func (m *mockSecondFactor) CheckCode(*domain.User, string) (bool, error) { return m.ok, m.err }
+
+func twoFactorUser() *domain.User {
+	enabled := time.Now()
+	return &domain.User{ID: kallax.NewULID(), Email: "test@example.com", Password: []byte(hashedSecret), TwoFactorEnabledAt: &enabled}
+}
+
+func TestAuthenticatingServiceTwoFactorChallenge(t *testing.T) {
+	t.Parallel()
+
+	u := twoFactorUser()
+	cs := &mockChallengeStore{}
+	throttles := &mockThrottleStore{throttles: []domain.LoginThrottle{{Key: "email:test@example.com", Failures: 1, LastFailureAt: time.Now().Add(-time.Minute)}}}
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: u}, throttles, cs, &mockSecondFactor{})
+	before := time.Now()
+	login, err := s.AuthenticateUser(authenticating.BasicCredential{Email: u.Email, Password: "secret"}, "")
+	assert.Nil(t, err)
+	assert.True(t, login.Pending())
+	assert.Equal(t, u, login.User)
+	assert.Equal(t, domain.TwoFactorPurpose, cs.created.Purpose)
+	assert.Equal(t, u.ID, cs.created.UserID)
+	assert.NotEqual(t, login.Challenge, cs.created.Hash)
+	assert.True(t, cs.created.ExpiresAt.After(before.Add(authenticating.ChallengeExpiration)))
+	// the failed attempts are kept until the second factor is completed.
+	assert.Empty(t, throttles.removed)
+}
+
+func TestAuthenticatingServiceTwoFactorChallengeErr(t *testing.T) {
+	t.Parallel()
+
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: twoFactorUser()}, &mockThrottleStore{}, &mockChallengeStore{err: errors.New("test")}, &mockSecondFactor{})
+	_, err := s.AuthenticateUser(authenticating.BasicCredential{Password: "secret"}, "")
+	assert.EqualError(t, err, "could not create challenge: test")
+}
+
+func TestAuthenticatingServiceCompleteChallenge(t *testing.T) {
+	t.Parallel()
+
+	u := twoFactorUser()
+	cs := &mockChallengeStore{token: &domain.UserToken{UserID: u.ID}}
+	throttles := &mockThrottleStore{throttles: []domain.LoginThrottle{{Key: "email:test@example.com", Failures: 1, LastFailureAt: time.Now().Add(-time.Minute)}}}
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: u}, throttles, cs, &mockSecondFactor{ok: true})
+	challenge, code := "challenge", "123456"
+	result, err := s.CompleteChallenge(authenticating.ChallengePayload{Challenge: &challenge, Code: &code}, "127.0.0.1")
+	assert.Nil(t, err)
+	assert.Equal(t, u, result)
+	assert.True(t, cs.consumed)
+	assert.Equal(t, []string{"email:test@example.com"}, throttles.removed)
+}
+
+func TestAuthenticatingServiceCompleteChallengePromoteAdmin(t *testing.T) {
+	t.Parallel()
+
+	u := twoFactorUser()
+	verifiedAt := time.Now()
+	u.EmailVerifiedAt = &verifiedAt
+	store := &mockStore{usr: u}
+	cs := &mockChallengeStore{token: &domain.UserToken{UserID: u.ID}}
+	s := authenticating.NewService(&mockTokenService{}, store, &mockThrottleStore{}, cs, &mockSecondFactor{ok: true}, u.Email)
+	challenge, code := "challenge", "123456"
+	result, err := s.CompleteChallenge(authenticating.ChallengePayload{Challenge: &challenge, Code: &code}, "127.0.0.1")
+	assert.Nil(t, err)
+	assert.True(t, result.Admin)
+	assert.Equal(t, u, store.updated)
+}
+
+func TestAuthenticatingServiceCompleteChallengeFails(t *testing.T) {
+	t.Parallel()
+
+	u := twoFactorUser()
+	token := &domain.UserToken{UserID: u.ID}
+	disabled := twoFactorUser()
+	disabledAt := time.Now()
+	disabled.DisabledAt = &disabledAt
+
+	tt := []struct {
+		name      string
+		store     *mockStore
+		throttles *mockThrottleStore
+		cs        *mockChallengeStore
+		sf        *mockSecondFactor
+		err       string
+	}{
+		{"challenge not found", &mockStore{usr: u}, &mockThrottleStore{}, &mockChallengeStore{err: userNotFoundMock("")}, &mockSecondFactor{ok: true}, "invalid or expired challenge"},
+		{"challenge store err", &mockStore{usr: u}, &mockThrottleStore{}, &mockChallengeStore{err: errors.New("test")}, &mockSecondFactor{ok: true}, "could not get challenge: test"},
+		{"user not found", &mockStore{err: userNotFoundMock("")}, &mockThrottleStore{}, &mockChallengeStore{token: token}, &mockSecondFactor{ok: true}, "invalid or expired challenge"},
+		{"disabled account", &mockStore{usr: disabled}, &mockThrottleStore{}, &mockChallengeStore{token: token}, &mockSecondFactor{ok: true}, "account disabled"},
+		{"invalid code", &mockStore{usr: u}, &mockThrottleStore{}, &mockChallengeStore{token: token}, &mockSecondFactor{}, "invalid two-factor code"},
+		{"check code err", &mockStore{usr: u}, &mockThrottleStore{}, &mockChallengeStore{token: token}, &mockSecondFactor{err: errors.New("test")}, "could not check two-factor code: test"},
+		{"throttled", &mockStore{usr: u}, &mockThrottleStore{throttles: []domain.LoginThrottle{{Key: "email:test@example.com", Failures: 10, LastFailureAt: time.Now()}}}, &mockChallengeStore{token: token}, &mockSecondFactor{ok: true}, "too many failed login attempts"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := authenticating.NewService(&mockTokenService{}, tc.store, tc.throttles, tc.cs, tc.sf)
+			challenge, code := "challenge", "123456"
+			_, err := s.CompleteChallenge(authenticating.ChallengePayload{Challenge: &challenge, Code: &code}, "127.0.0.1")
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestAuthenticatingServiceCompleteChallengeRecordsFailures(t *testing.T) {
+	t.Parallel()
+
+	u := twoFactorUser()
+	throttles := &mockThrottleStore{}
+	s := authenticating.NewService(&mockTokenService{}, &mockStore{usr: u}, throttles, &mockChallengeStore{token: &domain.UserToken{UserID: u.ID}}, &mockSecondFactor{})
+	challenge, code := "challenge", "000000"
+	_, err := s.CompleteChallenge(authenticating.ChallengePayload{Challenge: &challenge, Code: &code}, "127.0.0.1")
+	authErr, ok := errors.Cause(err).(authenticatingErr)
+	assert.True(t, ok)
+	assert.True(t, authErr.InvalidCredentials())
+	assert.Len(t, throttles.saved, 2)
+}
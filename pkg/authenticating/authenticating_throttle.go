@@ -0,0 +1,123 @@
+package authenticating
+
+import (
+	"net"
+	"strings"
+	"time"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gobuffalo/validate"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	errUnlockRequired = "email or ip must not be blank"
+	errInvalidIP      = "invalid ip"
+)
+
+// UnlockPayload represents the email or the client ip to unlock.
+type UnlockPayload struct {
+	Email *string `json:"email"`
+	IP    *string `json:"ip"`
+}
+
+func (p *UnlockPayload) Validate() error {
+	e := validate.NewErrors()
+	if p.Email == nil && p.IP == nil {
+		e.Add("email", errUnlockRequired)
+	}
+	if p.Email != nil && !govalidator.IsEmail(*p.Email) {
+		e.Add("email", errInvalidEmail)
+	}
+	if p.IP != nil && net.ParseIP(*p.IP) == nil {
+		e.Add("ip", errInvalidIP)
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// ThrottlePolicy configures the progressive delays and the temporary lockouts applied
+// to the failed login attempts of an email and of a client IP.
+type ThrottlePolicy struct {
+	// FreeAttempts are the failed attempts allowed before delaying the next one.
+	FreeAttempts int
+	// BaseDelay is the delay after the first delayed attempt. It doubles on every
+	// failure up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// EmailLockout and IPLockout are the failed attempts locking an email or an IP
+	// during LockoutDuration.
+	EmailLockout    int
+	IPLockout       int
+	LockoutDuration time.Duration
+	// Window is the time after the last failure when the attempts are forgotten.
+	Window time.Duration
+}
+
+// DefaultThrottlePolicy is the policy used by the authenticating service.
+var DefaultThrottlePolicy = ThrottlePolicy{
+	FreeAttempts:    3,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	EmailLockout:    10,
+	IPLockout:       50,
+	LockoutDuration: 15 * time.Minute,
+	Window:          time.Hour,
+}
+
+const (
+	emailThrottlePrefix = "email:"
+	ipThrottlePrefix    = "ip:"
+)
+
+func emailThrottleKey(email string) string {
+	return emailThrottlePrefix + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(ip string) string {
+	return ipThrottlePrefix + ip
+}
+
+func (p ThrottlePolicy) expired(t domain.LoginThrottle, now time.Time) bool {
+	return now.Sub(t.LastFailureAt) > p.Window || (t.LockedUntil != nil && !t.Locked(now))
+}
+
+// retryAfter returns how long the attempts must wait before trying again and whether
+// they are locked. Zero means the attempt is allowed.
+func (p ThrottlePolicy) retryAfter(t domain.LoginThrottle, now time.Time) (time.Duration, bool) {
+	if t.Locked(now) {
+		return t.LockedUntil.Sub(now), true
+	}
+	if p.expired(t, now) || t.Failures <= p.FreeAttempts {
+		return 0, false
+	}
+	delay := p.BaseDelay << uint(t.Failures-p.FreeAttempts-1)
+	if delay > p.MaxDelay || delay <= 0 {
+		delay = p.MaxDelay
+	}
+	if next := t.LastFailureAt.Add(delay); now.Before(next) {
+		return next.Sub(now), false
+	}
+	return 0, false
+}
+
+// lockedUntil returns until when the recorded attempts must be locked, or nil when they
+// didn't reach the lockout of its key or they are already locked.
+func (p ThrottlePolicy) lockedUntil(t domain.LoginThrottle, now time.Time) *time.Time {
+	if t.Locked(now) || t.Failures < p.lockout(t.Key) {
+		return nil
+	}
+	until := now.Add(p.LockoutDuration)
+	return &until
+}
+
+func (p ThrottlePolicy) lockout(key string) int {
+	if strings.HasPrefix(key, ipThrottlePrefix) {
+		return p.IPLockout
+	}
+	return p.EmailLockout
+}
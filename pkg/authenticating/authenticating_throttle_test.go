@@ -0,0 +1,47 @@
+package authenticating_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/authenticating"
+)
+
+func TestValidateUnlockPayloadOK(t *testing.T) {
+	t.Parallel()
+
+	for _, body := range []string{`{"email":"test@example.com"}`, `{"ip":"127.0.0.1"}`, `{"email":"test@example.com","ip":"::1"}`} {
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		var p authenticating.UnlockPayload
+		assert.Nil(t, binder.JSON.FromReq(r, &p), body)
+	}
+}
+
+func TestValidateUnlockPayloadError(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		body string
+		errs []string
+	}{
+		{"missing email and ip", `{}`, []string{"email or ip must not be blank"}},
+		{"invalid email", `{"email":"test"}`, []string{"invalid email"}},
+		{"invalid ip", `{"ip":"abc"}`, []string{"invalid ip"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+			var p authenticating.UnlockPayload
+			err := binder.JSON.FromReq(r, &p)
+			for _, e := range tc.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
@@ -9,14 +9,48 @@ import (
 type Permission interface {
 	IsOwner(kallax.ULID) bool
 	IsOwnerOrPublic(kallax.ULID) bool
+	// HasRole reports whether the user has at least the access level of the role.
+	HasRole(kallax.ULID, domain.Role) bool
 }
 
 type RepoPermission domain.Repository
 
+// IsOwner reports whether the user owns the repository. The repositories of an
+// organization are owned by the organization, not by the user who created them.
 func (c RepoPermission) IsOwner(ownerID kallax.ULID) bool {
-	return ownerID == c.UserID
+	return ownerID == c.UserID && c.OrganizationID == nil
 }
 
 func (c RepoPermission) IsOwnerOrPublic(ownerID kallax.ULID) bool {
-	return ownerID == c.UserID || c.Visibility == domain.Public
+	return c.IsOwner(ownerID) || c.Visibility == domain.Public
+}
+
+// HasRole grants every role to the owner and the read role to anyone when the repository is public.
+func (c RepoPermission) HasRole(userID kallax.ULID, role domain.Role) bool {
+	return c.IsOwner(userID) || (c.Visibility == domain.Public && domain.ReadRole.Includes(role))
+}
+
+// CollaboratorPermission extends the permission of a repository with the role of
+// the collaborator performing the request, given directly or through an organization.
+type CollaboratorPermission struct {
+	RepoPermission
+	Role domain.Role
+}
+
+// NewCollaboratorPermission returns the permission over repo of a collaborator with role.
+// An empty role means the user isn't a collaborator.
+func NewCollaboratorPermission(repo domain.Repository, role domain.Role) CollaboratorPermission {
+	return CollaboratorPermission{RepoPermission: RepoPermission(repo), Role: role}
+}
+
+func (c CollaboratorPermission) IsOwner(userID kallax.ULID) bool {
+	return c.RepoPermission.IsOwner(userID) || c.Role == domain.OwnerRole
+}
+
+func (c CollaboratorPermission) IsOwnerOrPublic(userID kallax.ULID) bool {
+	return c.RepoPermission.IsOwnerOrPublic(userID) || c.Role.Includes(domain.ReadRole)
+}
+
+func (c CollaboratorPermission) HasRole(userID kallax.ULID, role domain.Role) bool {
+	return c.RepoPermission.HasRole(userID, role) || c.Role.Includes(role)
 }
@@ -58,3 +58,65 @@ func TestRepoPermission(t *testing.T) {
 		})
 	}
 }
+
+func TestRepoPermissionHasRole(t *testing.T) {
+	t.Parallel()
+	ownerID := kallax.NewULID()
+
+	private := authorizing.RepoPermission(domain.Repository{UserID: ownerID, Visibility: domain.Private})
+	public := authorizing.RepoPermission(domain.Repository{UserID: ownerID, Visibility: domain.Public})
+	for _, role := range domain.Roles {
+		assert.True(t, private.HasRole(ownerID, role))
+		assert.False(t, private.HasRole(kallax.NewULID(), role))
+	}
+	assert.True(t, public.HasRole(kallax.NewULID(), domain.ReadRole))
+	assert.False(t, public.HasRole(kallax.NewULID(), domain.WriteRole))
+}
+
+func TestCollaboratorPermission(t *testing.T) {
+	t.Parallel()
+	ownerID, userID := kallax.NewULID(), kallax.NewULID()
+	repo := domain.Repository{UserID: ownerID, Visibility: domain.Private}
+
+	tt := []struct {
+		name                    string
+		role                    domain.Role
+		expectedIsOwner         bool
+		expectedIsOwnerOrPublic bool
+		expectedRead            bool
+		expectedWrite           bool
+		expectedAdmin           bool
+	}{
+		{"when user isn't a collaborator", "", false, false, false, false, false},
+		{"when user is a reader", domain.ReadRole, false, true, true, false, false},
+		{"when user is a writer", domain.WriteRole, false, true, true, true, false},
+		{"when user is an admin", domain.AdminRole, false, true, true, true, true},
+		{"when user is an owner of the organization", domain.OwnerRole, true, true, true, true, true},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			p := authorizing.NewCollaboratorPermission(repo, tc.role)
+			assert.Equal(t, tc.expectedIsOwner, p.IsOwner(userID))
+			assert.Equal(t, tc.expectedIsOwnerOrPublic, p.IsOwnerOrPublic(userID))
+			assert.Equal(t, tc.expectedRead, p.HasRole(userID, domain.ReadRole))
+			assert.Equal(t, tc.expectedWrite, p.HasRole(userID, domain.WriteRole))
+			assert.Equal(t, tc.expectedAdmin, p.HasRole(userID, domain.AdminRole))
+		})
+	}
+}
+
+func TestRepoPermissionOrganizationRepo(t *testing.T) {
+	t.Parallel()
+	creatorID, orgID := kallax.NewULID(), kallax.NewULID()
+	repo := domain.Repository{UserID: creatorID, OrganizationID: &orgID, Visibility: domain.Private}
+
+	p := authorizing.RepoPermission(repo)
+	assert.False(t, p.IsOwner(creatorID))
+	assert.False(t, p.IsOwnerOrPublic(creatorID))
+	assert.False(t, p.HasRole(creatorID, domain.ReadRole))
+
+	cp := authorizing.NewCollaboratorPermission(repo, domain.WriteRole)
+	assert.False(t, cp.IsOwner(creatorID))
+	assert.True(t, cp.HasRole(creatorID, domain.WriteRole))
+}
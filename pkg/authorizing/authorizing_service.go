@@ -3,10 +3,12 @@ package authorizing
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/pkg/errors"
 	"gopkg.in/src-d/go-kallax.v1"
 
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
 	"github.com/ifreddyrondon/capture/pkg/domain"
 )
 
@@ -20,6 +22,12 @@ type invalidCredentialErr string
 func (i invalidCredentialErr) Error() string         { return fmt.Sprintf(string(i)) }
 func (i invalidCredentialErr) IsNotAuthorized() bool { return true }
 
+const (
+	errInvalidKey  invalidCredentialErr = "invalid api key"
+	errClientToken invalidCredentialErr = "token issued to an oauth client"
+	errDisabled    invalidCredentialErr = "account disabled"
+)
+
 type notFoundErr interface {
 	NotFound() bool
 }
@@ -37,42 +45,131 @@ type Store interface {
 	GetUserByID(kallax.ULID) (*domain.User, error)
 }
 
+// KeyStore provides access to the API key storage.
+type KeyStore interface {
+	// GetAPIKeyByPrefix get an API key by its prefix.
+	GetAPIKeyByPrefix(string) (*domain.APIKey, error)
+	// TouchAPIKey updates the last used timestamp of an API key.
+	TouchAPIKey(*domain.APIKey) error
+}
+
 // TokenService provides utils to handle authorizing token.
 type TokenService interface {
-	// IsRequestAuthorized validates if a request if authorized
-	IsRequestAuthorized(*http.Request) (string, error)
+	// RequestGrant validates if a request is authorized, returning the subject of its token
+	// and the access granted to the OAuth2 client the token was issued to, if any.
+	RequestGrant(*http.Request) (string, *domain.AccessGrant, error)
+	// RequestImpersonator returns the administrator acting as the subject of the token of a
+	// request, empty when the token isn't an impersonation one.
+	RequestImpersonator(*http.Request) (string, error)
 }
 
 // Service provides authorizing operations.
 type Service interface {
+	// AuthorizeRequest validates the token of an user. Tokens issued to OAuth2 clients are denied.
 	AuthorizeRequest(*http.Request) (*domain.User, error)
+	// AuthorizeGrant validates the token of an user or of an OAuth2 client acting on
+	// behalf of an user, returning the access granted to the client.
+	AuthorizeGrant(*http.Request) (*domain.User, *domain.AccessGrant, error)
+	// AuthorizeKey validates an API key returning it with the user who created it.
+	AuthorizeKey(string) (*domain.User, *domain.APIKey, error)
+	// AuthorizeImpersonator returns the id of the administrator impersonating the user of
+	// an authorized request, nil when the user isn't impersonated.
+	AuthorizeImpersonator(*http.Request) (*kallax.ULID, error)
 }
 
 type service struct {
 	s  Store
+	ks KeyStore
 	ts TokenService
 }
 
 // NewService creates an authenticating service with the necessary dependencies
-func NewService(ts TokenService, s Store) Service {
-	return &service{ts: ts, s: s}
+func NewService(ts TokenService, s Store, ks KeyStore) Service {
+	return &service{ts: ts, s: s, ks: ks}
 }
 
 func (s *service) AuthorizeRequest(r *http.Request) (*domain.User, error) {
-	subjectID, err := s.ts.IsRequestAuthorized(r)
+	u, grant, err := s.AuthorizeGrant(r)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not authorized request")
+		return nil, err
+	}
+	if grant != nil {
+		return nil, errors.WithStack(errClientToken)
+	}
+	return u, nil
+}
+
+func (s *service) AuthorizeGrant(r *http.Request) (*domain.User, *domain.AccessGrant, error) {
+	subjectID, grant, err := s.ts.RequestGrant(r)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not authorized request")
 	}
 	id, err := kallax.NewULIDFromText(subjectID)
 	if err != nil {
-		return nil, invalidIDErr(fmt.Sprintf("%v is not a valid ULID", subjectID))
+		return nil, nil, invalidIDErr(fmt.Sprintf("%v is not a valid ULID", subjectID))
 	}
 	u, err := s.s.GetUserByID(id)
 	if err != nil {
 		if isNotFound(err) {
-			return nil, errors.WithStack(invalidCredentialErr(err.Error()))
+			return nil, nil, errors.WithStack(invalidCredentialErr(err.Error()))
 		}
-		return nil, errors.Wrap(err, "error when get user by id in AuthorizeRequest")
+		return nil, nil, errors.Wrap(err, "error when get user by id in AuthorizeRequest")
 	}
-	return u, nil
+	if u.Disabled() {
+		return nil, nil, errors.WithStack(errDisabled)
+	}
+	return u, grant, nil
+}
+
+func (s *service) AuthorizeImpersonator(r *http.Request) (*kallax.ULID, error) {
+	actorID, err := s.ts.RequestImpersonator(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not authorized request")
+	}
+	if actorID == "" {
+		return nil, nil
+	}
+	id, err := kallax.NewULIDFromText(actorID)
+	if err != nil {
+		return nil, invalidIDErr(fmt.Sprintf("%v is not a valid ULID", actorID))
+	}
+	return &id, nil
+}
+
+func (s *service) AuthorizeKey(key string) (*domain.User, *domain.APIKey, error) {
+	prefix, secret, ok := apikeys.Parse(key)
+	if !ok {
+		return nil, nil, errors.WithStack(errInvalidKey)
+	}
+	k, err := s.ks.GetAPIKeyByPrefix(prefix)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil, errors.WithStack(errInvalidKey)
+		}
+		return nil, nil, errors.Wrap(err, "error when get api key in AuthorizeKey")
+	}
+	if k.RevokedAt != nil || !apikeys.Check(k.Hash, secret) {
+		return nil, nil, errors.WithStack(errInvalidKey)
+	}
+
+	u, err := s.s.GetUserByID(k.UserID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil, errors.WithStack(invalidCredentialErr(err.Error()))
+		}
+		return nil, nil, errors.Wrap(err, "error when get user by id in AuthorizeKey")
+	}
+	if u.Disabled() {
+		return nil, nil, errors.WithStack(errDisabled)
+	}
+
+	// the last use is tracked with minute resolution to avoid a write per request.
+	now := time.Now()
+	if k.LastUsedAt == nil || now.Sub(*k.LastUsedAt) >= time.Minute {
+		k.LastUsedAt = &now
+		if err := s.ks.TouchAPIKey(k); err != nil {
+			return nil, nil, errors.Wrap(err, "error when touch api key in AuthorizeKey")
+		}
+	}
+	return u, k, nil
 }
@@ -3,22 +3,29 @@ package authorizing_test
 import (
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/src-d/go-kallax.v1"
 
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
 	"github.com/ifreddyrondon/capture/pkg/authorizing"
 	"github.com/ifreddyrondon/capture/pkg/domain"
 )
 
 type mockTokenService struct {
-	subjectID string
-	err       error
+	subjectID    string
+	grant        *domain.AccessGrant
+	impersonator string
+	err          error
 }
 
-func (m *mockTokenService) IsRequestAuthorized(*http.Request) (string, error) {
-	return m.subjectID, m.err
+func (m *mockTokenService) RequestGrant(*http.Request) (string, *domain.AccessGrant, error) {
+	return m.subjectID, m.grant, m.err
+}
+func (m *mockTokenService) RequestImpersonator(*http.Request) (string, error) {
+	return m.impersonator, m.err
 }
 
 type mockStore struct {
@@ -28,6 +35,19 @@ type mockStore struct {
 
 func (m *mockStore) GetUserByID(kallax.ULID) (*domain.User, error) { return m.usr, m.err }
 
+type mockKeyStore struct {
+	key      *domain.APIKey
+	err      error
+	touchErr error
+	touched  bool
+}
+
+func (m *mockKeyStore) GetAPIKeyByPrefix(string) (*domain.APIKey, error) { return m.key, m.err }
+func (m *mockKeyStore) TouchAPIKey(*domain.APIKey) error {
+	m.touched = true
+	return m.touchErr
+}
+
 func TestServiceAuthorizeRequest(t *testing.T) {
 	t.Parallel()
 
@@ -36,7 +56,7 @@ func TestServiceAuthorizeRequest(t *testing.T) {
 	assert.Nil(t, err)
 	u := &domain.User{ID: userID}
 
-	s := authorizing.NewService(&mockTokenService{subjectID: userIDTxt}, &mockStore{usr: u})
+	s := authorizing.NewService(&mockTokenService{subjectID: userIDTxt}, &mockStore{usr: u}, &mockKeyStore{})
 	req, _ := http.NewRequest("GET", "/", nil)
 
 	req.Header.Set("Authorization", "Bearer test")
@@ -48,7 +68,7 @@ func TestServiceAuthorizeRequest(t *testing.T) {
 func TestServiceAuthorizeRequestGetTokenFails(t *testing.T) {
 	t.Parallel()
 
-	s := authorizing.NewService(&mockTokenService{err: errors.New("test")}, &mockStore{})
+	s := authorizing.NewService(&mockTokenService{err: errors.New("test")}, &mockStore{}, &mockKeyStore{})
 	req, _ := http.NewRequest("GET", "/", nil)
 
 	req.Header.Set("Authorization", "Bearer test")
@@ -56,12 +76,47 @@ func TestServiceAuthorizeRequestGetTokenFails(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestServiceAuthorizeRequestDeniesClientToken(t *testing.T) {
+	t.Parallel()
+
+	userIDTxt := "0162eb39-a65e-04a1-7ad9-d663bb49a396"
+	grant := &domain.AccessGrant{ClientID: "client", Scopes: []domain.APIKeyScope{domain.ReposRead}}
+	s := authorizing.NewService(&mockTokenService{subjectID: userIDTxt, grant: grant}, &mockStore{usr: &domain.User{}}, &mockKeyStore{})
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	_, err := s.AuthorizeRequest(req)
+	assert.EqualError(t, err, "token issued to an oauth client")
+	authErr, ok := errors.Cause(err).(authorizationErr)
+	assert.True(t, ok)
+	assert.True(t, authErr.IsNotAuthorized())
+}
+
+func TestServiceAuthorizeGrant(t *testing.T) {
+	t.Parallel()
+
+	userIDTxt := "0162eb39-a65e-04a1-7ad9-d663bb49a396"
+	u := &domain.User{}
+	grant := &domain.AccessGrant{ClientID: "client", Scopes: []domain.APIKeyScope{domain.ReposRead}}
+	s := authorizing.NewService(&mockTokenService{subjectID: userIDTxt, grant: grant}, &mockStore{usr: u}, &mockKeyStore{})
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	result, resultGrant, err := s.AuthorizeGrant(req)
+	assert.Nil(t, err)
+	assert.Equal(t, u, result)
+	assert.Equal(t, grant, resultGrant)
+
+	s = authorizing.NewService(&mockTokenService{subjectID: userIDTxt}, &mockStore{usr: u}, &mockKeyStore{})
+	_, resultGrant, err = s.AuthorizeGrant(req)
+	assert.Nil(t, err)
+	assert.Nil(t, resultGrant)
+}
+
 type invalidErr interface{ IsInvalid() bool }
 
 func TestServiceAuthorizeRequestInvalidSubjectID(t *testing.T) {
 	t.Parallel()
 
-	s := authorizing.NewService(&mockTokenService{subjectID: "a"}, &mockStore{})
+	s := authorizing.NewService(&mockTokenService{subjectID: "a"}, &mockStore{}, &mockKeyStore{})
 	req, _ := http.NewRequest("GET", "/", nil)
 
 	req.Header.Set("Authorization", "Bearer test")
@@ -83,7 +138,7 @@ func TestServiceAuthorizeRequestNotFoundUser(t *testing.T) {
 	t.Parallel()
 
 	ts := &mockTokenService{subjectID: "0162eb39-a65e-04a1-7ad9-d663bb49a396"}
-	s := authorizing.NewService(ts, &mockStore{err: userNotFound("test")})
+	s := authorizing.NewService(ts, &mockStore{err: userNotFound("test")}, &mockKeyStore{})
 	req, _ := http.NewRequest("GET", "/", nil)
 
 	req.Header.Set("Authorization", "Bearer test")
@@ -98,10 +153,128 @@ func TestServiceAuthorizeRequestInvalid(t *testing.T) {
 	t.Parallel()
 
 	ts := &mockTokenService{subjectID: "0162eb39-a65e-04a1-7ad9-d663bb49a396"}
-	s := authorizing.NewService(ts, &mockStore{err: errors.New("test")})
+	s := authorizing.NewService(ts, &mockStore{err: errors.New("test")}, &mockKeyStore{})
 	req, _ := http.NewRequest("GET", "/", nil)
 
 	req.Header.Set("Authorization", "Bearer test")
 	_, err := s.AuthorizeRequest(req)
 	assert.EqualError(t, err, "error when get user by id in AuthorizeRequest: test")
 }
+
+func TestServiceAuthorizeDisabledUser(t *testing.T) {
+	t.Parallel()
+
+	disabledAt := time.Now()
+	u := &domain.User{ID: kallax.NewULID(), DisabledAt: &disabledAt}
+	key, prefix, hash, _ := apikeys.Generate()
+	ks := &mockKeyStore{key: &domain.APIKey{Prefix: prefix, Hash: hash, UserID: u.ID}}
+	s := authorizing.NewService(&mockTokenService{subjectID: u.ID.String()}, &mockStore{usr: u}, ks)
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer test")
+	_, err := s.AuthorizeRequest(req)
+	assert.EqualError(t, err, "account disabled")
+	authErr, ok := errors.Cause(err).(authorizationErr)
+	assert.True(t, ok)
+	assert.True(t, authErr.IsNotAuthorized())
+
+	_, _, err = s.AuthorizeKey(key)
+	assert.EqualError(t, err, "account disabled")
+	assert.False(t, ks.touched)
+}
+
+func TestServiceAuthorizeKey(t *testing.T) {
+	t.Parallel()
+
+	key, prefix, hash, err := apikeys.Generate()
+	assert.Nil(t, err)
+	u := &domain.User{ID: kallax.NewULID()}
+	k := &domain.APIKey{Prefix: prefix, Hash: hash, UserID: u.ID}
+	ks := &mockKeyStore{key: k}
+
+	s := authorizing.NewService(&mockTokenService{}, &mockStore{usr: u}, ks)
+	usr, result, err := s.AuthorizeKey(key)
+	assert.Nil(t, err)
+	assert.Equal(t, u, usr)
+	assert.Equal(t, k, result)
+	assert.True(t, ks.touched)
+	assert.NotNil(t, k.LastUsedAt)
+
+	ks.touched = false
+	_, _, err = s.AuthorizeKey(key)
+	assert.Nil(t, err)
+	assert.False(t, ks.touched)
+}
+
+func TestServiceAuthorizeKeyInvalid(t *testing.T) {
+	t.Parallel()
+
+	key, prefix, hash, _ := apikeys.Generate()
+	other, _, _, _ := apikeys.Generate()
+	revokedAt := time.Now()
+
+	tt := []struct {
+		name string
+		key  string
+		ks   *mockKeyStore
+	}{
+		{"malformed", "abc", &mockKeyStore{}},
+		{"not found", key, &mockKeyStore{err: userNotFound("test")}},
+		{"wrong secret", other, &mockKeyStore{key: &domain.APIKey{Prefix: prefix, Hash: hash}}},
+		{"revoked", key, &mockKeyStore{key: &domain.APIKey{Prefix: prefix, Hash: hash, RevokedAt: &revokedAt}}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := authorizing.NewService(&mockTokenService{}, &mockStore{usr: &domain.User{}}, tc.ks)
+			_, _, err := s.AuthorizeKey(tc.key)
+			assert.EqualError(t, err, "invalid api key")
+			authErr, ok := errors.Cause(err).(authorizationErr)
+			assert.True(t, ok)
+			assert.True(t, authErr.IsNotAuthorized())
+		})
+	}
+}
+
+func TestServiceAuthorizeKeyFails(t *testing.T) {
+	t.Parallel()
+
+	key, prefix, hash, _ := apikeys.Generate()
+	k := func() *domain.APIKey { return &domain.APIKey{Prefix: prefix, Hash: hash} }
+
+	s := authorizing.NewService(&mockTokenService{}, &mockStore{}, &mockKeyStore{err: errors.New("test")})
+	_, _, err := s.AuthorizeKey(key)
+	assert.EqualError(t, err, "error when get api key in AuthorizeKey: test")
+
+	s = authorizing.NewService(&mockTokenService{}, &mockStore{err: errors.New("test")}, &mockKeyStore{key: k()})
+	_, _, err = s.AuthorizeKey(key)
+	assert.EqualError(t, err, "error when get user by id in AuthorizeKey: test")
+
+	s = authorizing.NewService(&mockTokenService{}, &mockStore{usr: &domain.User{}}, &mockKeyStore{key: k(), touchErr: errors.New("test")})
+	_, _, err = s.AuthorizeKey(key)
+	assert.EqualError(t, err, "error when touch api key in AuthorizeKey: test")
+}
+
+func TestServiceAuthorizeImpersonator(t *testing.T) {
+	t.Parallel()
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	actorID := kallax.NewULID()
+	s := authorizing.NewService(&mockTokenService{impersonator: actorID.String()}, &mockStore{}, &mockKeyStore{})
+	id, err := s.AuthorizeImpersonator(req)
+	assert.Nil(t, err)
+	assert.Equal(t, actorID, *id)
+
+	s = authorizing.NewService(&mockTokenService{}, &mockStore{}, &mockKeyStore{})
+	id, err = s.AuthorizeImpersonator(req)
+	assert.Nil(t, err)
+	assert.Nil(t, id)
+
+	s = authorizing.NewService(&mockTokenService{impersonator: "test"}, &mockStore{}, &mockKeyStore{})
+	_, err = s.AuthorizeImpersonator(req)
+	assert.EqualError(t, err, "test is not a valid ULID")
+
+	s = authorizing.NewService(&mockTokenService{err: errors.New("test")}, &mockStore{}, &mockKeyStore{})
+	_, err = s.AuthorizeImpersonator(req)
+	assert.EqualError(t, err, "could not authorized request: test")
+}
@@ -0,0 +1,40 @@
+package collaborating
+
+import (
+	"fmt"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gobuffalo/validate"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	errEmailRequired = "email must not be blank"
+	errInvalidEmail  = "invalid email"
+	errRoleRequired  = "role must not be blank"
+)
+
+// Payload represents the data to invite an user to a repository.
+type Payload struct {
+	Email *string `json:"email"`
+	Role  *string `json:"role"`
+}
+
+func (p *Payload) Validate() error {
+	e := validate.NewErrors()
+	if p.Email == nil {
+		e.Add("email", errEmailRequired)
+	} else if !govalidator.IsEmail(*p.Email) {
+		e.Add("email", errInvalidEmail)
+	}
+	if p.Role == nil {
+		e.Add("role", errRoleRequired)
+	} else if !domain.AllowedRole(*p.Role) {
+		e.Add("role", fmt.Sprintf("not allowed role %v. it could be one of %v", *p.Role, domain.Roles))
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
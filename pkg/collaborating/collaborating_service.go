@@ -0,0 +1,224 @@
+package collaborating
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const errInviteOwner invalidErr = "the owner of the repository can't be invited"
+
+type notFoundErr interface {
+	// NotFound returns true when a resource is not found.
+	NotFound() bool
+}
+
+func isNotFound(err error) bool {
+	if e, ok := errors.Cause(err).(notFoundErr); ok {
+		return e.NotFound()
+	}
+	return false
+}
+
+type constraintErr interface {
+	UniqueConstraint() bool
+}
+
+func isConstraintErr(err error) bool {
+	if e, ok := errors.Cause(err).(constraintErr); ok {
+		return e.UniqueConstraint()
+	}
+	return false
+}
+
+type conflictErr string
+
+func (e conflictErr) Error() string  { return string(e) }
+func (e conflictErr) Conflict() bool { return true }
+
+type invalidErr string
+
+func (i invalidErr) Error() string   { return string(i) }
+func (i invalidErr) IsInvalid() bool { return true }
+
+type notFound string
+
+func (n notFound) Error() string  { return string(n) }
+func (n notFound) NotFound() bool { return true }
+
+// UserStore provides access to the user storage.
+type UserStore interface {
+	// GetUserByEmail get a user by email.
+	GetUserByEmail(string) (*domain.User, error)
+}
+
+// Store provides access to the collaborator storage.
+type Store interface {
+	// CreateCollaborator stores a new collaborator.
+	CreateCollaborator(*domain.Collaborator) error
+	// ListCollaborators retrieve the collaborators of a repository, accepted or not.
+	ListCollaborators(repoID kallax.ULID) ([]domain.Collaborator, error)
+	// GetCollaborator retrieve a collaborator of a repository.
+	GetCollaborator(id, repoID kallax.ULID) (*domain.Collaborator, error)
+	// GetRepoCollaborator retrieve the collaborator of a repository for an user.
+	GetRepoCollaborator(repoID, userID kallax.ULID) (*domain.Collaborator, error)
+	// ListInvitations retrieve the invitations not accepted yet of an user.
+	ListInvitations(userID kallax.ULID) ([]domain.Collaborator, error)
+	// SaveCollaborator the collaborator state into the storage.
+	SaveCollaborator(*domain.Collaborator) error
+	// RemoveCollaborator deletes a collaborator.
+	RemoveCollaborator(*domain.Collaborator) error
+}
+
+// Service provides repository collaborators operations.
+type Service interface {
+	// Invite adds an user as a pending collaborator of a repository.
+	Invite(*domain.User, *domain.Repository, Payload) (*domain.Collaborator, error)
+	// ListCollaborators list the repo collaborators.
+	ListCollaborators(*domain.Repository) ([]domain.Collaborator, error)
+	// GetCollaborator retrieve a repo collaborator.
+	GetCollaborator(kallax.ULID, *domain.Repository) (*domain.Collaborator, error)
+	// RemoveCollaborator revokes the access of a collaborator or cancels its invitation.
+	RemoveCollaborator(*domain.Collaborator) error
+	// ListInvitations list the pending invitations of an user.
+	ListInvitations(*domain.User) ([]domain.Collaborator, error)
+	// AcceptInvitation grants the user the role of the invitation.
+	AcceptInvitation(kallax.ULID, *domain.User) (*domain.Collaborator, error)
+	// DeclineInvitation removes a pending invitation of an user.
+	DeclineInvitation(kallax.ULID, *domain.User) error
+	// GetRole returns the role of an user over a repository, empty when the user
+	// isn't an accepted collaborator.
+	GetRole(*domain.Repository, *domain.User) (domain.Role, error)
+}
+
+type service struct {
+	us UserStore
+	s  Store
+}
+
+// NewService creates a collaborating service with the necessary dependencies
+func NewService(us UserStore, s Store) Service {
+	return &service{us: us, s: s}
+}
+
+func (s *service) Invite(inviter *domain.User, r *domain.Repository, p Payload) (*domain.Collaborator, error) {
+	u, err := s.us.GetUserByEmail(*p.Email)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(notFound(fmt.Sprintf("user with email %v not found", *p.Email)))
+		}
+		return nil, errors.Wrap(err, "could not get user by email")
+	}
+	if u.ID == r.UserID && r.OrganizationID == nil {
+		return nil, errors.WithStack(errInviteOwner)
+	}
+
+	now := time.Now()
+	c := &domain.Collaborator{
+		ID:           kallax.NewULID(),
+		Role:         domain.Role(*p.Role),
+		Email:        u.Email,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		RepositoryID: r.ID,
+		UserID:       u.ID,
+		InvitedBy:    inviter.ID,
+	}
+	if err := s.s.CreateCollaborator(c); err != nil {
+		if isConstraintErr(err) {
+			return nil, errors.WithStack(conflictErr(fmt.Sprintf("%v is already a collaborator of the repository", u.Email)))
+		}
+		return nil, errors.Wrap(err, "could not create collaborator")
+	}
+	return c, nil
+}
+
+func (s *service) ListCollaborators(r *domain.Repository) ([]domain.Collaborator, error) {
+	collaborators, err := s.s.ListCollaborators(r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list collaborators")
+	}
+	if collaborators == nil {
+		collaborators = make([]domain.Collaborator, 0)
+	}
+	return collaborators, nil
+}
+
+func (s *service) GetCollaborator(id kallax.ULID, r *domain.Repository) (*domain.Collaborator, error) {
+	c, err := s.s.GetCollaborator(id, r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get collaborator")
+	}
+	return c, nil
+}
+
+func (s *service) RemoveCollaborator(c *domain.Collaborator) error {
+	if err := s.s.RemoveCollaborator(c); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not remove collaborator %v", c.ID))
+	}
+	return nil
+}
+
+func (s *service) ListInvitations(u *domain.User) ([]domain.Collaborator, error) {
+	invitations, err := s.s.ListInvitations(u.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list invitations")
+	}
+	if invitations == nil {
+		invitations = make([]domain.Collaborator, 0)
+	}
+	return invitations, nil
+}
+
+func (s *service) getInvitation(id kallax.ULID, u *domain.User) (*domain.Collaborator, error) {
+	invitations, err := s.ListInvitations(u)
+	if err != nil {
+		return nil, err
+	}
+	for i := range invitations {
+		if invitations[i].ID == id {
+			return &invitations[i], nil
+		}
+	}
+	return nil, errors.WithStack(notFound(fmt.Sprintf("invitation %v not found", id)))
+}
+
+func (s *service) AcceptInvitation(id kallax.ULID, u *domain.User) (*domain.Collaborator, error) {
+	c, err := s.getInvitation(id, u)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	c.AcceptedAt = &now
+	c.UpdatedAt = now
+	if err := s.s.SaveCollaborator(c); err != nil {
+		return nil, errors.Wrap(err, fmt.Sprintf("could not accept invitation %v", c.ID))
+	}
+	return c, nil
+}
+
+func (s *service) DeclineInvitation(id kallax.ULID, u *domain.User) error {
+	c, err := s.getInvitation(id, u)
+	if err != nil {
+		return err
+	}
+	return s.RemoveCollaborator(c)
+}
+
+func (s *service) GetRole(r *domain.Repository, u *domain.User) (domain.Role, error) {
+	c, err := s.s.GetRepoCollaborator(r.ID, u.ID)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "could not get collaborator")
+	}
+	if !c.Accepted() {
+		return "", nil
+	}
+	return c.Role, nil
+}
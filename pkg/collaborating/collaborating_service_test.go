@@ -0,0 +1,199 @@
+package collaborating_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/collaborating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type notFoundErr string
+
+func (n notFoundErr) Error() string  { return string(n) }
+func (n notFoundErr) NotFound() bool { return true }
+
+type uniqueConstraintErr string
+
+func (u uniqueConstraintErr) Error() string          { return string(u) }
+func (u uniqueConstraintErr) UniqueConstraint() bool { return true }
+
+type mockUserStore struct {
+	usr *domain.User
+	err error
+}
+
+func (m *mockUserStore) GetUserByEmail(string) (*domain.User, error) {
+	if m.usr == nil && m.err == nil {
+		return nil, notFoundErr("not found")
+	}
+	return m.usr, m.err
+}
+
+type mockStore struct {
+	collaborators []domain.Collaborator
+	saved         *domain.Collaborator
+	removed       *domain.Collaborator
+	err           error
+}
+
+func (m *mockStore) CreateCollaborator(c *domain.Collaborator) error { return m.err }
+func (m *mockStore) ListCollaborators(kallax.ULID) ([]domain.Collaborator, error) {
+	return m.collaborators, m.err
+}
+func (m *mockStore) GetCollaborator(kallax.ULID, kallax.ULID) (*domain.Collaborator, error) {
+	if len(m.collaborators) == 0 {
+		return nil, notFoundErr("not found")
+	}
+	return &m.collaborators[0], m.err
+}
+func (m *mockStore) GetRepoCollaborator(kallax.ULID, kallax.ULID) (*domain.Collaborator, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	if len(m.collaborators) == 0 {
+		return nil, notFoundErr("not found")
+	}
+	return &m.collaborators[0], nil
+}
+func (m *mockStore) ListInvitations(kallax.ULID) ([]domain.Collaborator, error) {
+	return m.collaborators, m.err
+}
+func (m *mockStore) SaveCollaborator(c *domain.Collaborator) error {
+	m.saved = c
+	return m.err
+}
+func (m *mockStore) RemoveCollaborator(c *domain.Collaborator) error {
+	m.removed = c
+	return m.err
+}
+
+var (
+	owner   = &domain.User{ID: kallax.NewULID(), Email: "owner@example.com"}
+	invitee = &domain.User{ID: kallax.NewULID(), Email: "invitee@example.com"}
+	repo    = &domain.Repository{ID: kallax.NewULID(), UserID: owner.ID}
+)
+
+func s2P(s string) *string { return &s }
+
+func TestServiceInviteOK(t *testing.T) {
+	t.Parallel()
+
+	s := collaborating.NewService(&mockUserStore{usr: invitee}, &mockStore{})
+	c, err := s.Invite(owner, repo, collaborating.Payload{Email: s2P(invitee.Email), Role: s2P("write")})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.WriteRole, c.Role)
+	assert.Equal(t, invitee.ID, c.UserID)
+	assert.Equal(t, repo.ID, c.RepositoryID)
+	assert.Equal(t, owner.ID, c.InvitedBy)
+	assert.False(t, c.Accepted())
+}
+
+func TestServiceInviteFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name  string
+		us    *mockUserStore
+		store *mockStore
+		err   string
+	}{
+		{"unknown user", &mockUserStore{}, &mockStore{}, "user with email invitee@example.com not found"},
+		{"get user err", &mockUserStore{err: errors.New("test")}, &mockStore{}, "could not get user by email: test"},
+		{"owner", &mockUserStore{usr: owner}, &mockStore{}, "the owner of the repository can't be invited"},
+		{"duplicated", &mockUserStore{usr: invitee}, &mockStore{err: uniqueConstraintErr("test")}, "invitee@example.com is already a collaborator of the repository"},
+		{"create err", &mockUserStore{usr: invitee}, &mockStore{err: errors.New("test")}, "could not create collaborator: test"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := collaborating.NewService(tc.us, tc.store)
+			_, err := s.Invite(owner, repo, collaborating.Payload{Email: s2P("invitee@example.com"), Role: s2P("read")})
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestServiceListCollaborators(t *testing.T) {
+	t.Parallel()
+
+	s := collaborating.NewService(&mockUserStore{}, &mockStore{})
+	collaborators, err := s.ListCollaborators(repo)
+	assert.Nil(t, err)
+	assert.NotNil(t, collaborators)
+	assert.Len(t, collaborators, 0)
+
+	s = collaborating.NewService(&mockUserStore{}, &mockStore{err: errors.New("test")})
+	_, err = s.ListCollaborators(repo)
+	assert.EqualError(t, err, "could not list collaborators: test")
+}
+
+func TestServiceAcceptInvitation(t *testing.T) {
+	t.Parallel()
+
+	id := kallax.NewULID()
+	store := &mockStore{collaborators: []domain.Collaborator{{ID: id, Role: domain.ReadRole, UserID: invitee.ID}}}
+	s := collaborating.NewService(&mockUserStore{}, store)
+
+	c, err := s.AcceptInvitation(id, invitee)
+	assert.Nil(t, err)
+	assert.True(t, c.Accepted())
+	assert.Equal(t, id, store.saved.ID)
+
+	other := kallax.NewULID()
+	_, err = s.AcceptInvitation(other, invitee)
+	assert.EqualError(t, err, fmt.Sprintf("invitation %v not found", other))
+}
+
+func TestServiceDeclineInvitation(t *testing.T) {
+	t.Parallel()
+
+	id := kallax.NewULID()
+	store := &mockStore{collaborators: []domain.Collaborator{{ID: id, UserID: invitee.ID}}}
+	s := collaborating.NewService(&mockUserStore{}, store)
+
+	assert.Nil(t, s.DeclineInvitation(id, invitee))
+	assert.Equal(t, id, store.removed.ID)
+}
+
+func TestServiceRemoveCollaboratorErr(t *testing.T) {
+	t.Parallel()
+
+	c := &domain.Collaborator{ID: kallax.NewULID()}
+	s := collaborating.NewService(&mockUserStore{}, &mockStore{err: errors.New("test")})
+	err := s.RemoveCollaborator(c)
+	assert.EqualError(t, err, fmt.Sprintf("could not remove collaborator %v: test", c.ID))
+}
+
+func TestServiceGetRole(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tt := []struct {
+		name     string
+		store    *mockStore
+		expected domain.Role
+	}{
+		{"not a collaborator", &mockStore{}, ""},
+		{"pending invitation", &mockStore{collaborators: []domain.Collaborator{{Role: domain.AdminRole}}}, ""},
+		{"accepted invitation", &mockStore{collaborators: []domain.Collaborator{{Role: domain.WriteRole, AcceptedAt: &now}}}, domain.WriteRole},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := collaborating.NewService(&mockUserStore{}, tc.store)
+			role, err := s.GetRole(repo, invitee)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, role)
+		})
+	}
+
+	s := collaborating.NewService(&mockUserStore{}, &mockStore{err: errors.New("test")})
+	_, err := s.GetRole(repo, invitee)
+	assert.EqualError(t, err, "could not get collaborator: test")
+}
@@ -0,0 +1,47 @@
+package collaborating_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/collaborating"
+)
+
+func TestValidatePayloadOK(t *testing.T) {
+	t.Parallel()
+
+	r, _ := http.NewRequest("POST", "/", strings.NewReader(`{"email":"test@example.com","role":"write"}`))
+	var p collaborating.Payload
+	err := binder.JSON.FromReq(r, &p)
+	assert.Nil(t, err)
+	assert.Equal(t, "test@example.com", *p.Email)
+	assert.Equal(t, "write", *p.Role)
+}
+
+func TestValidatePayloadError(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"missing email", `{"role":"read"}`, "email must not be blank"},
+		{"invalid email", `{"email":"test","role":"read"}`, "invalid email"},
+		{"missing role", `{"email":"test@example.com"}`, "role must not be blank"},
+		{"invalid role", `{"email":"test@example.com","role":"owner"}`, "not allowed role owner. it could be one of [read write admin]"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+			var p collaborating.Payload
+			err := binder.JSON.FromReq(r, &p)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
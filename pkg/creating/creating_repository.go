@@ -59,9 +59,10 @@ func (p Payload) Validate() error {
 }
 
 type Repository struct {
-	ID         string    `json:"id"`
-	Name       string    `json:"name"`
-	Visibility string    `json:"visibility"`
-	CreatedAt  time.Time `json:"createdAt" `
-	UpdatedAt  time.Time `json:"updatedAt" `
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Visibility   string    `json:"visibility"`
+	Organization string    `json:"organization,omitempty"`
+	CreatedAt    time.Time `json:"createdAt" `
+	UpdatedAt    time.Time `json:"updatedAt" `
 }
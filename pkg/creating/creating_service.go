@@ -20,6 +20,8 @@ type Store interface {
 type Service interface {
 	// CreateRepo creates a new repository to an user
 	CreateRepo(*domain.User, Payload) (*Repository, error)
+	// CreateOrganizationRepo creates a new repository owned by an organization
+	CreateOrganizationRepo(*domain.User, *domain.Organization, Payload) (*Repository, error)
 }
 
 type service struct {
@@ -39,6 +41,15 @@ func (s *service) CreateRepo(owner *domain.User, p Payload) (*Repository, error)
 	return getRepo(*r), nil
 }
 
+func (s *service) CreateOrganizationRepo(creator *domain.User, org *domain.Organization, p Payload) (*Repository, error) {
+	r := getDomainRepository(creator, p)
+	r.OrganizationID = &org.ID
+	if err := s.s.SaveRepo(r); err != nil {
+		return nil, errors.Wrap(err, "could not save repo")
+	}
+	return getRepo(*r), nil
+}
+
 func getDomainRepository(owner *domain.User, r Payload) *domain.Repository {
 	now := time.Now()
 	repo := &domain.Repository{
@@ -59,11 +70,15 @@ func getDomainRepository(owner *domain.User, r Payload) *domain.Repository {
 }
 
 func getRepo(r domain.Repository) *Repository {
-	return &Repository{
+	repo := &Repository{
 		ID:         r.ID.String(),
 		Name:       r.Name,
 		Visibility: string(r.Visibility),
 		CreatedAt:  r.CreatedAt,
 		UpdatedAt:  r.UpdatedAt,
 	}
+	if r.OrganizationID != nil {
+		repo.Organization = r.OrganizationID.String()
+	}
+	return repo
 }
@@ -12,10 +12,14 @@ import (
 )
 
 type mockStore struct {
-	err error
+	saved *domain.Repository
+	err   error
 }
 
-func (m *mockStore) SaveRepo(repo *domain.Repository) error { return m.err }
+func (m *mockStore) SaveRepo(repo *domain.Repository) error {
+	m.saved = repo
+	return m.err
+}
 
 func string2pointer(v string) *string { return &v }
 
@@ -65,3 +69,22 @@ func TestServiceCreateRepoErrWhenSaving(t *testing.T) {
 	_, err := s.CreateRepo(owner, payl)
 	assert.EqualError(t, err, "could not save repo: test")
 }
+
+func TestServiceCreateOrganizationRepo(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{}
+	s := creating.NewService(store)
+	creator := &domain.User{ID: kallax.NewULID()}
+	org := &domain.Organization{ID: kallax.NewULID()}
+
+	repo, err := s.CreateOrganizationRepo(creator, org, creating.Payload{Name: string2pointer("test")})
+	assert.Nil(t, err)
+	assert.Equal(t, org.ID.String(), repo.Organization)
+	assert.Equal(t, creator.ID, store.saved.UserID)
+	assert.Equal(t, org.ID, *store.saved.OrganizationID)
+
+	s = creating.NewService(&mockStore{err: errors.New("test")})
+	_, err = s.CreateOrganizationRepo(creator, org, creating.Payload{Name: string2pointer("test")})
+	assert.EqualError(t, err, "could not save repo: test")
+}
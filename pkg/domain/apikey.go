@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// APIKeyScope represents an operation allowed to an API key.
+type APIKeyScope string
+
+const (
+	// CapturesRead allows to list, get and stream the captures of a repository.
+	CapturesRead APIKeyScope = "captures:read"
+	// CapturesWrite allows to add, update and remove the captures of a repository.
+	CapturesWrite APIKeyScope = "captures:write"
+	// ReposRead allows to get a repository and its geofences. It's only granted to OAuth2 clients.
+	ReposRead APIKeyScope = "repos:read"
+)
+
+// APIKeyScopes contains all the allowed API key scopes.
+var APIKeyScopes = []APIKeyScope{CapturesRead, CapturesWrite}
+
+// APIKey represents a credential for a device restricted to a single repository.
+// Only the hash of the secret is stored, the prefix identifies the key.
+type APIKey struct {
+	ID           kallax.ULID   `json:"id" sql:"type:uuid,pk"`
+	Name         string        `json:"name" sql:",notnull"`
+	Prefix       string        `json:"prefix" sql:",notnull,unique"`
+	Hash         []byte        `json:"-" sql:",notnull"`
+	Scopes       []APIKeyScope `json:"scopes" sql:",array,notnull"`
+	LastUsedAt   *time.Time    `json:"lastUsedAt"`
+	CreatedAt    time.Time     `json:"createdAt" sql:",notnull"`
+	RevokedAt    *time.Time    `json:"revokedAt,omitempty"`
+	RepositoryID kallax.ULID   `json:"repoId" sql:"type:uuid,notnull"`
+	UserID       kallax.ULID   `json:"-" sql:"type:uuid,notnull"`
+}
+
+// Allows reports whether the key grants any of the scopes over a repository.
+func (k APIKey) Allows(repoID kallax.ULID, scopes ...APIKeyScope) bool {
+	if k.RevokedAt != nil || k.RepositoryID != repoID {
+		return false
+	}
+	for _, s := range scopes {
+		for _, ks := range k.Scopes {
+			if s == ks {
+				return true
+			}
+		}
+	}
+	return false
+}
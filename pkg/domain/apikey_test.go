@@ -0,0 +1,27 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+func TestAPIKeyAllows(t *testing.T) {
+	t.Parallel()
+
+	repoID := kallax.NewULID()
+	revokedAt := time.Now()
+	k := domain.APIKey{RepositoryID: repoID, Scopes: []domain.APIKeyScope{domain.CapturesRead}}
+	revoked := domain.APIKey{RepositoryID: repoID, Scopes: []domain.APIKeyScope{domain.CapturesRead}, RevokedAt: &revokedAt}
+
+	assert.True(t, k.Allows(repoID, domain.CapturesRead))
+	assert.True(t, k.Allows(repoID, domain.CapturesWrite, domain.CapturesRead))
+	assert.False(t, k.Allows(repoID, domain.CapturesWrite))
+	assert.False(t, k.Allows(repoID))
+	assert.False(t, k.Allows(kallax.NewULID(), domain.CapturesRead))
+	assert.False(t, revoked.Allows(repoID, domain.CapturesRead))
+}
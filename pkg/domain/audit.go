@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// AuditAction represents an action recorded in the audit trail.
+type AuditAction string
+
+// Actions of the administrators.
+const (
+	AdminUsersListed      AuditAction = "admin.users.listed"
+	AdminUserDisabled     AuditAction = "admin.user.disabled"
+	AdminUserEnabled      AuditAction = "admin.user.enabled"
+	AdminUserImpersonated AuditAction = "admin.user.impersonated"
+	AdminRepoViewed       AuditAction = "admin.repository.viewed"
+	AdminRepoTransferred  AuditAction = "admin.repository.transferred"
+)
+
+// Security relevant actions of the users.
+const (
+	LoginSucceeded        AuditAction = "login.succeeded"
+	LoginFailed           AuditAction = "login.failed"
+	TokenRefreshed        AuditAction = "token.refreshed"
+	OAuthTokenIssued      AuditAction = "oauth.token.issued"
+	APIKeyCreated         AuditAction = "apikey.created"
+	APIKeyRevoked         AuditAction = "apikey.revoked"
+	ShareLinkCreated      AuditAction = "sharelink.created"
+	ShareLinkRevoked      AuditAction = "sharelink.revoked"
+	RepoCreated           AuditAction = "repository.created"
+	RepoVisibilityChanged AuditAction = "repository.visibility.changed"
+	CaptureAdded          AuditAction = "capture.added"
+	CaptureChanged        AuditAction = "capture.changed"
+	CaptureDeleted        AuditAction = "capture.deleted"
+	CollaboratorInvited   AuditAction = "collaborator.invited"
+	CollaboratorRemoved   AuditAction = "collaborator.removed"
+	InvitationAccepted    AuditAction = "invitation.accepted"
+	MemberAdded           AuditAction = "organization.member.added"
+	MemberRemoved         AuditAction = "organization.member.removed"
+	TeamMemberAdded       AuditAction = "team.member.added"
+	TeamMemberRemoved     AuditAction = "team.member.removed"
+)
+
+// AuditActions holds all the kinds of audited actions.
+var AuditActions = []AuditAction{
+	AdminUsersListed, AdminUserDisabled, AdminUserEnabled, AdminUserImpersonated, AdminRepoViewed, AdminRepoTransferred,
+	LoginSucceeded, LoginFailed, TokenRefreshed, OAuthTokenIssued, APIKeyCreated, APIKeyRevoked, ShareLinkCreated,
+	ShareLinkRevoked, RepoCreated, RepoVisibilityChanged, CaptureAdded, CaptureChanged, CaptureDeleted,
+	CollaboratorInvited, CollaboratorRemoved, InvitationAccepted, MemberAdded, MemberRemoved, TeamMemberAdded,
+	TeamMemberRemoved,
+}
+
+// Targets of the audited actions.
+const (
+	UserTarget         = "user"
+	RepositoryTarget   = "repository"
+	CaptureTarget      = "capture"
+	OAuthClientTarget  = "oauth_client"
+	APIKeyTarget       = "apikey"
+	ShareLinkTarget    = "sharelink"
+	CollaboratorTarget = "collaborator"
+	InvitationTarget   = "invitation"
+	OrganizationTarget = "organization"
+	TeamTarget         = "team"
+)
+
+// AuditTargets holds all the kinds of targets of the audited actions.
+var AuditTargets = []string{
+	UserTarget, RepositoryTarget, CaptureTarget, OAuthClientTarget, APIKeyTarget, ShareLinkTarget,
+	CollaboratorTarget, InvitationTarget, OrganizationTarget, TeamTarget,
+}
+
+// Actor represents who performs an action and from where. Impersonator is the
+// administrator acting as the user, if any.
+type Actor struct {
+	User         *User
+	Impersonator *kallax.ULID
+	IP           string
+	UserAgent    string
+}
+
+// AuditEntry represents an action performed by an actor on a target. The entries are
+// only appended, never updated. RepositoryID is the repository the target belongs
+// to, so its owner could review the entries. ImpersonatorID is the administrator
+// who performed the action as the actor.
+type AuditEntry struct {
+	ID             kallax.ULID            `json:"id" sql:"type:uuid,pk"`
+	Action         AuditAction            `json:"action" sql:",notnull"`
+	ActorID        *kallax.ULID           `json:"actor,omitempty" sql:"type:uuid"`
+	ImpersonatorID *kallax.ULID           `json:"impersonator,omitempty" sql:"type:uuid"`
+	IP             string                 `json:"ip,omitempty"`
+	UserAgent      string                 `json:"userAgent,omitempty"`
+	TargetType     string                 `json:"targetType" sql:",notnull"`
+	TargetID       string                 `json:"targetId" sql:",notnull"`
+	RepositoryID   *kallax.ULID           `json:"repoId,omitempty" sql:"type:uuid"`
+	Details        map[string]interface{} `json:"details,omitempty"`
+	CreatedAt      time.Time              `json:"createdAt" sql:",notnull"`
+}
+
+// NewAuditEntry returns an entry of an action of the actor on a target.
+func NewAuditEntry(a Actor, action AuditAction, targetType, targetID string) *AuditEntry {
+	e := &AuditEntry{
+		ID:             kallax.NewULID(),
+		Action:         action,
+		ImpersonatorID: a.Impersonator,
+		IP:             a.IP,
+		UserAgent:      a.UserAgent,
+		TargetType:     targetType,
+		TargetID:       targetID,
+		CreatedAt:      time.Now(),
+	}
+	if a.User != nil {
+		e.ActorID = &a.User.ID
+	}
+	return e
+}
+
+// Detail adds a detail of the action to the entry.
+func (e *AuditEntry) Detail(key string, value interface{}) {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+}
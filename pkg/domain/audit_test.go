@@ -0,0 +1,40 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+func TestNewAuditEntry(t *testing.T) {
+	t.Parallel()
+
+	u := &domain.User{ID: kallax.NewULID()}
+	a := domain.Actor{User: u, IP: "127.0.0.1", UserAgent: "test"}
+	e := domain.NewAuditEntry(a, domain.AdminUserDisabled, domain.UserTarget, "123")
+
+	assert.False(t, e.ID.IsEmpty())
+	assert.Equal(t, domain.AdminUserDisabled, e.Action)
+	assert.Equal(t, u.ID, *e.ActorID)
+	assert.Equal(t, "127.0.0.1", e.IP)
+	assert.Equal(t, "test", e.UserAgent)
+	assert.Equal(t, domain.UserTarget, e.TargetType)
+	assert.Equal(t, "123", e.TargetID)
+	assert.False(t, e.CreatedAt.IsZero())
+
+	anonymous := domain.NewAuditEntry(domain.Actor{IP: "127.0.0.1"}, domain.AdminUserDisabled, domain.UserTarget, "123")
+	assert.Nil(t, anonymous.ActorID)
+}
+
+func TestAuditEntryDetail(t *testing.T) {
+	t.Parallel()
+
+	e := domain.NewAuditEntry(domain.Actor{}, domain.RepoVisibilityChanged, domain.RepositoryTarget, "123")
+	e.Detail("from", "private")
+	e.Detail("to", "public")
+
+	assert.Equal(t, map[string]interface{}{"from": "private", "to": "public"}, e.Details)
+}
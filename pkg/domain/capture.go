@@ -1,6 +1,7 @@
 package domain
 
 import (
+	"math"
 	"time"
 
 	"gopkg.in/src-d/go-kallax.v1"
@@ -22,6 +23,23 @@ type Point struct {
 	Elevation *float64 `json:"elevation,omitempty"`
 }
 
+// EarthRadius is the mean radius of the Earth in meters.
+const EarthRadius = 6371008.8
+
+// Within returns true when the point is at most radius meters away from the center. It uses
+// the haversine formula over a spherical Earth.
+func (p *Point) Within(center Point, radius float64) bool {
+	if p == nil || p.LAT == nil || p.LNG == nil || center.LAT == nil || center.LNG == nil {
+		return false
+	}
+	lat1, lat2 := toRadians(*center.LAT), toRadians(*p.LAT)
+	dLat, dLng := lat2-lat1, toRadians(*p.LNG-*center.LNG)
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLng/2), 2)
+	return 2*EarthRadius*math.Asin(math.Sqrt(h)) <= radius
+}
+
+func toRadians(degrees float64) float64 { return degrees * math.Pi / 180 }
+
 type Capture struct {
 	ID           kallax.ULID `json:"id" sql:"type:uuid,pk"`
 	Payload      Payload     `json:"payload" sql:"type:jsonb,notnull"`
@@ -32,4 +50,6 @@ type Capture struct {
 	UpdatedAt    time.Time   `json:"updatedAt" sql:",notnull"`
 	DeletedAt    *time.Time  `json:"-" pg:",soft_delete"`
 	RepositoryID kallax.ULID `json:"repoId" sql:"type:uuid"`
+	// Rollup is set when the capture aggregates older captures.
+	Rollup *Rollup `json:"rollup,omitempty" sql:"type:jsonb"`
 }
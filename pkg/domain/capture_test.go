@@ -116,3 +116,29 @@ func TestCaptureMarshalJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestPointWithin(t *testing.T) {
+	t.Parallel()
+
+	// one degree of latitude is about 111.2 km.
+	center := domain.Point{LAT: f2P(0), LNG: f2P(0)}
+	tt := []struct {
+		name     string
+		point    *domain.Point
+		radius   float64
+		expected bool
+	}{
+		{"same point", &domain.Point{LAT: f2P(0), LNG: f2P(0)}, 0, true},
+		{"inside radius", &domain.Point{LAT: f2P(1), LNG: f2P(0)}, 112000, true},
+		{"outside radius", &domain.Point{LAT: f2P(1), LNG: f2P(0)}, 111000, false},
+		{"opposite side", &domain.Point{LAT: f2P(0), LNG: f2P(180)}, 112000, false},
+		{"nil point", nil, 1000, false},
+		{"point without lng", &domain.Point{LAT: f2P(0)}, 1000, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.point.Within(center, tc.radius))
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// Role represents the access level of a collaborator over a repository.
+type Role string
+
+const (
+	// ReadRole allows to get the repository and list its captures.
+	ReadRole Role = "read"
+	// WriteRole allows ReadRole plus adding, updating and removing captures.
+	WriteRole Role = "write"
+	// AdminRole allows WriteRole plus managing the repository settings and collaborators.
+	AdminRole Role = "admin"
+	// OwnerRole allows AdminRole plus the operations reserved to the owner of the repository.
+	// It's granted to the owners of an organization over its repositories and it can't be
+	// given to collaborators or teams.
+	OwnerRole Role = "owner"
+)
+
+// Roles contains all the allowed roles from the lowest access level to the highest.
+var Roles = []Role{ReadRole, WriteRole, AdminRole}
+
+// AllowedRole reports whether test is a role that could be given to collaborators and teams.
+func AllowedRole(test string) bool {
+	return Role(test) != OwnerRole && Role(test).level() > 0
+}
+
+func (r Role) level() int {
+	if r == OwnerRole {
+		return len(Roles) + 1
+	}
+	for i, role := range Roles {
+		if role == r {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// Includes reports whether the role grants the access of other.
+func (r Role) Includes(other Role) bool {
+	return r.level() > 0 && r.level() >= other.level()
+}
+
+// Collaborator represents an user invited to a repository with a role. The
+// collaborator gets access once the invitation is accepted.
+type Collaborator struct {
+	ID           kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	Role         Role        `json:"role" sql:",notnull"`
+	Email        string      `json:"email" sql:",notnull"`
+	AcceptedAt   *time.Time  `json:"acceptedAt"`
+	CreatedAt    time.Time   `json:"createdAt" sql:",notnull"`
+	UpdatedAt    time.Time   `json:"updatedAt" sql:",notnull"`
+	RepositoryID kallax.ULID `json:"repoId" sql:"type:uuid,notnull,unique:repo_user"`
+	UserID       kallax.ULID `json:"userId" sql:"type:uuid,notnull,unique:repo_user"`
+	InvitedBy    kallax.ULID `json:"invitedBy" sql:"type:uuid,notnull"`
+}
+
+// Accepted reports whether the collaborator accepted the invitation.
+func (c Collaborator) Accepted() bool {
+	return c.AcceptedAt != nil
+}
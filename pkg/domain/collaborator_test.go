@@ -0,0 +1,44 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+func TestAllowedRole(t *testing.T) {
+	t.Parallel()
+
+	for _, r := range []string{"read", "write", "admin"} {
+		assert.True(t, domain.AllowedRole(r))
+	}
+	for _, r := range []string{"", "owner", "READ"} {
+		assert.False(t, domain.AllowedRole(r))
+	}
+}
+
+func TestRoleIncludes(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		role     domain.Role
+		other    domain.Role
+		expected bool
+	}{
+		{domain.ReadRole, domain.ReadRole, true},
+		{domain.ReadRole, domain.WriteRole, false},
+		{domain.WriteRole, domain.ReadRole, true},
+		{domain.WriteRole, domain.AdminRole, false},
+		{domain.AdminRole, domain.WriteRole, true},
+		{domain.AdminRole, domain.OwnerRole, false},
+		{domain.OwnerRole, domain.AdminRole, true},
+		{domain.Role(""), domain.ReadRole, false},
+		{domain.Role(""), domain.Role(""), false},
+	}
+
+	for _, tc := range tt {
+		assert.Equal(t, tc.expected, tc.role.Includes(tc.other), "%v includes %v", tc.role, tc.other)
+	}
+}
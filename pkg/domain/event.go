@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// EventType is the kind of change that happened within a repository.
+type EventType string
+
+var (
+	CaptureCreated EventType = "capture.created"
+	CaptureUpdated EventType = "capture.updated"
+	CaptureRemoved EventType = "capture.removed"
+	RepoUpdated    EventType = "repo.updated"
+)
+
+// EventTypes holds all the kinds of events.
+var EventTypes = []EventType{CaptureCreated, CaptureUpdated, CaptureRemoved, RepoUpdated}
+
+// Event represents a change within a repository.
+type Event struct {
+	ID           kallax.ULID `json:"id"`
+	Type         EventType   `json:"type"`
+	Capture      *Capture    `json:"capture,omitempty"`
+	Repository   *Repository `json:"repository,omitempty"`
+	Timestamp    time.Time   `json:"timestamp"`
+	RepositoryID kallax.ULID `json:"repoId"`
+}
+
+// NewCaptureEvent returns a new event of a change in a capture.
+func NewCaptureEvent(t EventType, c Capture) Event {
+	return Event{
+		ID:           kallax.NewULID(),
+		Type:         t,
+		Capture:      &c,
+		Timestamp:    time.Now(),
+		RepositoryID: c.RepositoryID,
+	}
+}
+
+// NewRepoEvent returns a new event of a change in a repository.
+func NewRepoEvent(t EventType, r Repository) Event {
+	return Event{
+		ID:           kallax.NewULID(),
+		Type:         t,
+		Repository:   &r,
+		Timestamp:    time.Now(),
+		RepositoryID: r.ID,
+	}
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// Polygon represents a closed area delimited by a list of vertices. The last vertex is joined with the first one.
+type Polygon []Point
+
+// Contains returns true when the point is inside the polygon. It uses the ray casting algorithm
+// over the lat/lng plane, so it's not meant for areas crossing the antimeridian.
+func (p Polygon) Contains(point *Point) bool {
+	if point == nil || point.LAT == nil || point.LNG == nil || len(p) < 3 {
+		return false
+	}
+	lat, lng := *point.LAT, *point.LNG
+	inside := false
+	for i, j := 0, len(p)-1; i < len(p); j, i = i, i+1 {
+		latI, lngI := *p[i].LAT, *p[i].LNG
+		latJ, lngJ := *p[j].LAT, *p[j].LNG
+		if (latI > lat) != (latJ > lat) && lng < (lngJ-lngI)*(lat-latI)/(latJ-latI)+lngI {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// Geofence is a named area within a repository. The captures located inside are tagged with its name.
+type Geofence struct {
+	ID           kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	Name         string      `json:"name" sql:",notnull"`
+	Polygon      Polygon     `json:"polygon" sql:"type:jsonb,notnull"`
+	Inside       bool        `json:"inside" sql:",notnull"`
+	CreatedAt    time.Time   `json:"createdAt" sql:",notnull"`
+	UpdatedAt    time.Time   `json:"updatedAt" sql:",notnull"`
+	DeletedAt    *time.Time  `json:"-" pg:",soft_delete"`
+	RepositoryID kallax.ULID `json:"repoId" sql:"type:uuid"`
+}
+
+// GeofenceEventType is the kind of crossing of a geofence boundary.
+type GeofenceEventType string
+
+var (
+	Enter GeofenceEventType = "enter"
+	Exit  GeofenceEventType = "exit"
+)
+
+// GeofenceEvent records when the captures of a repository entered or exited a geofence.
+type GeofenceEvent struct {
+	ID           kallax.ULID       `json:"id" sql:"type:uuid,pk"`
+	Type         GeofenceEventType `json:"type" sql:",notnull"`
+	Timestamp    time.Time         `json:"timestamp" sql:",notnull"`
+	CreatedAt    time.Time         `json:"createdAt" sql:",notnull"`
+	GeofenceID   kallax.ULID       `json:"geofenceId" sql:"type:uuid"`
+	CaptureID    kallax.ULID       `json:"captureId" sql:"type:uuid"`
+	RepositoryID kallax.ULID       `json:"repoId" sql:"type:uuid"`
+}
@@ -0,0 +1,43 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+func f2P(v float64) *float64 {
+	return &v
+}
+
+func TestPolygonContains(t *testing.T) {
+	t.Parallel()
+
+	square := domain.Polygon{
+		{LAT: f2P(0), LNG: f2P(0)},
+		{LAT: f2P(0), LNG: f2P(10)},
+		{LAT: f2P(10), LNG: f2P(10)},
+		{LAT: f2P(10), LNG: f2P(0)},
+	}
+
+	tt := []struct {
+		name     string
+		polygon  domain.Polygon
+		point    *domain.Point
+		expected bool
+	}{
+		{"inside", square, &domain.Point{LAT: f2P(5), LNG: f2P(5)}, true},
+		{"outside", square, &domain.Point{LAT: f2P(5), LNG: f2P(11)}, false},
+		{"nil point", square, nil, false},
+		{"point without lat", square, &domain.Point{LNG: f2P(5)}, false},
+		{"not a polygon", square[:2], &domain.Point{LAT: f2P(5), LNG: f2P(5)}, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.polygon.Contains(tc.point))
+		})
+	}
+}
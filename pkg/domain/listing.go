@@ -7,11 +7,26 @@ import (
 
 // Listing allows to sort and filter search results between services and storage.
 type Listing struct {
-	SortKey    string
-	Offset     int64
-	Limit      int
-	Owner      *kallax.ULID
-	Visibility *Visibility
+	SortKey      string
+	Offset       int64
+	Limit        int
+	Owner        *kallax.ULID
+	Organization *kallax.ULID
+	Visibility   *Visibility
+	// Search matches the results containing the text, Disabled filters the users by
+	// the state of their account.
+	Search   *string
+	Disabled *bool
+	// Repository, Actor, Action and TargetType filter the audit trail entries.
+	Repository *kallax.ULID
+	Actor      *kallax.ULID
+	Action     *AuditAction
+	TargetType *string
+	// Area keeps the captures located inside the polygon, Near the captures located at
+	// most Radius meters away from the point.
+	Area   Polygon
+	Near   *Point
+	Radius float64
 }
 
 // NewListing returns a new Listing instance with offset and limit from listing.Listing.
@@ -0,0 +1,98 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// OAuthScopes contains the scopes that could be granted to OAuth2 clients.
+var OAuthScopes = []APIKeyScope{ReposRead, CapturesRead, CapturesWrite}
+
+// OAuthClient represents a third-party app allowed to access the repositories of the users
+// who consent it. Public clients can't keep a secret, so they must use PKCE and can't use
+// the client credentials grant. Only the hash of the secret is stored.
+type OAuthClient struct {
+	ID           kallax.ULID   `json:"clientId" sql:"type:uuid,pk"`
+	Name         string        `json:"name" sql:",notnull"`
+	SecretHash   string        `json:"-"`
+	RedirectURIs []string      `json:"redirectUris" sql:",array,notnull"`
+	Scopes       []APIKeyScope `json:"scopes" sql:",array,notnull"`
+	Confidential bool          `json:"confidential" sql:",notnull"`
+	CreatedAt    time.Time     `json:"createdAt" sql:",notnull"`
+	UserID       kallax.ULID   `json:"-" sql:"type:uuid,notnull"`
+}
+
+// AllowsRedirect reports whether uri is one of the registered redirect uris.
+func (c OAuthClient) AllowsRedirect(uri string) bool {
+	for _, r := range c.RedirectURIs {
+		if r == uri {
+			return true
+		}
+	}
+	return false
+}
+
+// OAuthCode represents a single-use authorization code issued to a client after the
+// consent of an user. Only the hash of the code is stored.
+type OAuthCode struct {
+	ID            kallax.ULID   `sql:"type:uuid,pk"`
+	Hash          string        `sql:",notnull,unique"`
+	RedirectURI   string        `sql:",notnull"`
+	Scopes        []APIKeyScope `sql:",array,notnull"`
+	CodeChallenge string        `sql:",notnull"`
+	ExpiresAt     time.Time     `sql:",notnull"`
+	UsedAt        *time.Time    `sql:""`
+	CreatedAt     time.Time     `sql:",notnull"`
+	ClientID      kallax.ULID   `sql:"type:uuid,notnull"`
+	UserID        kallax.ULID   `sql:"type:uuid,notnull"`
+}
+
+// OAuthConsent represents the scopes an user granted to a client.
+type OAuthConsent struct {
+	UserID    kallax.ULID   `json:"-" sql:"type:uuid,pk"`
+	ClientID  kallax.ULID   `json:"clientId" sql:"type:uuid,pk"`
+	Scopes    []APIKeyScope `json:"scopes" sql:",array,notnull"`
+	CreatedAt time.Time     `json:"createdAt" sql:",notnull"`
+	UpdatedAt time.Time     `json:"updatedAt" sql:",notnull"`
+}
+
+// Covers reports whether the consent includes every one of the scopes.
+func (c OAuthConsent) Covers(scopes ...APIKeyScope) bool {
+	return AccessGrant{Scopes: c.Scopes}.Includes(scopes...)
+}
+
+// AccessGrant represents the access delegated by an user to an OAuth2 client through a token.
+type AccessGrant struct {
+	ClientID string
+	Scopes   []APIKeyScope
+}
+
+// Allows reports whether the grant includes any of the scopes.
+func (g AccessGrant) Allows(scopes ...APIKeyScope) bool {
+	for _, s := range scopes {
+		if g.has(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// Includes reports whether the grant includes every one of the scopes.
+func (g AccessGrant) Includes(scopes ...APIKeyScope) bool {
+	for _, s := range scopes {
+		if !g.has(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g AccessGrant) has(scope APIKeyScope) bool {
+	for _, s := range g.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+func TestOAuthClientAllowsRedirect(t *testing.T) {
+	t.Parallel()
+
+	c := domain.OAuthClient{RedirectURIs: []string{"https://app.example.com/callback"}}
+	assert.True(t, c.AllowsRedirect("https://app.example.com/callback"))
+	assert.False(t, c.AllowsRedirect("https://app.example.com/callback/"))
+	assert.False(t, c.AllowsRedirect(""))
+}
+
+func TestAccessGrant(t *testing.T) {
+	t.Parallel()
+
+	g := domain.AccessGrant{Scopes: []domain.APIKeyScope{domain.ReposRead, domain.CapturesRead}}
+	assert.True(t, g.Allows(domain.CapturesWrite, domain.CapturesRead))
+	assert.False(t, g.Allows(domain.CapturesWrite))
+	assert.False(t, g.Allows())
+	assert.True(t, g.Includes(domain.ReposRead, domain.CapturesRead))
+	assert.False(t, g.Includes(domain.ReposRead, domain.CapturesWrite))
+}
+
+func TestOAuthConsentCovers(t *testing.T) {
+	t.Parallel()
+
+	c := domain.OAuthConsent{Scopes: []domain.APIKeyScope{domain.ReposRead}}
+	assert.True(t, c.Covers(domain.ReposRead))
+	assert.False(t, c.Covers(domain.ReposRead, domain.CapturesRead))
+}
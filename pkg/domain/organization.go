@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// MemberRole represents the access level of a member over an organization.
+type MemberRole string
+
+const (
+	// OwnerMember allows to manage the organization, its members and teams, and gets
+	// the OwnerRole over the repositories of the organization.
+	OwnerMember MemberRole = "owner"
+	// RegularMember gets access to the repositories of the organization through its teams.
+	RegularMember MemberRole = "member"
+)
+
+// MemberRoles contains all the allowed member roles.
+var MemberRoles = []MemberRole{OwnerMember, RegularMember}
+
+// AllowedMemberRole reports whether test is a valid member role.
+func AllowedMemberRole(test string) bool {
+	for _, r := range MemberRoles {
+		if MemberRole(test) == r {
+			return true
+		}
+	}
+	return false
+}
+
+// Organization represents a group of users sharing repositories.
+type Organization struct {
+	ID        kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	Name      string      `json:"name" sql:",notnull,unique"`
+	CreatedAt time.Time   `json:"createdAt" sql:",notnull"`
+	UpdatedAt time.Time   `json:"updatedAt" sql:",notnull"`
+}
+
+// Member represents an user belonging to an organization.
+type Member struct {
+	ID             kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	Role           MemberRole  `json:"role" sql:",notnull"`
+	Email          string      `json:"email" sql:",notnull"`
+	CreatedAt      time.Time   `json:"createdAt" sql:",notnull"`
+	OrganizationID kallax.ULID `json:"organizationId" sql:"type:uuid,notnull,unique:org_user"`
+	UserID         kallax.ULID `json:"userId" sql:"type:uuid,notnull,unique:org_user"`
+}
+
+// Team represents a group of members of an organization. The members of a team get
+// its role over every repository of the organization.
+type Team struct {
+	ID             kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	Name           string      `json:"name" sql:",notnull,unique:org_team"`
+	Role           Role        `json:"role" sql:",notnull"`
+	CreatedAt      time.Time   `json:"createdAt" sql:",notnull"`
+	UpdatedAt      time.Time   `json:"updatedAt" sql:",notnull"`
+	OrganizationID kallax.ULID `json:"organizationId" sql:"type:uuid,notnull,unique:org_team"`
+}
+
+// TeamMember represents the membership of an user to a team.
+type TeamMember struct {
+	ID        kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	CreatedAt time.Time   `json:"createdAt" sql:",notnull"`
+	TeamID    kallax.ULID `json:"teamId" sql:"type:uuid,notnull,unique:team_user"`
+	UserID    kallax.ULID `json:"userId" sql:"type:uuid,notnull,unique:team_user"`
+}
@@ -0,0 +1,18 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+func TestAllowedMemberRole(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, domain.AllowedMemberRole("owner"))
+	assert.True(t, domain.AllowedMemberRole("member"))
+	assert.False(t, domain.AllowedMemberRole("admin"))
+	assert.False(t, domain.AllowedMemberRole(""))
+}
@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"math"
+	"time"
+)
+
+// RateLimit represents the requests allowed in a period. It's enforced with a token bucket
+// holding up to Requests tokens and refilled at Requests per Period, so short bursts are
+// allowed while the average rate is kept.
+type RateLimit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// rate returns the tokens refilled per second.
+func (l RateLimit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// RateBucket represents the token bucket of a client, identified by its key.
+type RateBucket struct {
+	Key       string    `sql:",pk"`
+	Tokens    float64   `sql:",notnull"`
+	UpdatedAt time.Time `sql:",notnull"`
+}
+
+// NewRateBucket returns a full bucket for the limit.
+func NewRateBucket(key string, l RateLimit, now time.Time) *RateBucket {
+	return &RateBucket{Key: key, Tokens: float64(l.Requests), UpdatedAt: now}
+}
+
+// Take refills the bucket up to the given time and takes a token from it, reporting
+// whether there was one available.
+func (b *RateBucket) Take(l RateLimit, now time.Time) bool {
+	if elapsed := now.Sub(b.UpdatedAt).Seconds(); elapsed > 0 {
+		b.Tokens = math.Min(float64(l.Requests), b.Tokens+elapsed*l.rate())
+		b.UpdatedAt = now
+	}
+	if b.Tokens < 1 {
+		return false
+	}
+	b.Tokens--
+	return true
+}
+
+// Remaining returns the whole tokens left in the bucket.
+func (b RateBucket) Remaining() int {
+	return int(math.Floor(b.Tokens))
+}
+
+// Reset returns the time until the bucket is full again.
+func (b RateBucket) Reset(l RateLimit) time.Duration {
+	return secondsToDuration((float64(l.Requests) - b.Tokens) / l.rate())
+}
+
+// RetryAfter returns the time until a token is available.
+func (b RateBucket) RetryAfter(l RateLimit) time.Duration {
+	if b.Tokens >= 1 {
+		return 0
+	}
+	return secondsToDuration((1 - b.Tokens) / l.rate())
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
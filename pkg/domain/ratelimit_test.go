@@ -0,0 +1,41 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+func TestRateBucketTake(t *testing.T) {
+	t.Parallel()
+
+	l := domain.RateLimit{Requests: 2, Period: 2 * time.Second}
+	now := time.Now()
+	b := domain.NewRateBucket("test", l, now)
+
+	assert.True(t, b.Take(l, now))
+	assert.True(t, b.Take(l, now))
+	assert.Equal(t, 0, b.Remaining())
+	assert.False(t, b.Take(l, now))
+	assert.Equal(t, time.Second, b.RetryAfter(l))
+	assert.Equal(t, 2*time.Second, b.Reset(l))
+
+	// half a token is refilled after half a second
+	later := now.Add(500 * time.Millisecond)
+	assert.False(t, b.Take(l, later))
+	assert.Equal(t, 500*time.Millisecond, b.RetryAfter(l))
+
+	later = now.Add(time.Second)
+	assert.True(t, b.Take(l, later))
+	assert.Equal(t, 0, b.Remaining())
+
+	// the bucket never holds more tokens than the limit
+	later = later.Add(time.Hour)
+	assert.True(t, b.Take(l, later))
+	assert.Equal(t, 1, b.Remaining())
+	assert.Equal(t, time.Duration(0), b.RetryAfter(l))
+	assert.Equal(t, time.Second, b.Reset(l))
+}
@@ -0,0 +1,50 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// RetentionAction is what happens to the captures expired by a retention policy.
+type RetentionAction string
+
+const (
+	// DropCaptures removes permanently the expired captures.
+	DropCaptures RetentionAction = "drop"
+	// ArchiveCaptures writes the expired captures to an archive before removing them.
+	ArchiveCaptures RetentionAction = "archive"
+)
+
+// RetentionActions are the allowed retention actions.
+var RetentionActions = []RetentionAction{DropCaptures, ArchiveCaptures}
+
+// RetentionPolicy represents how long the captures of a repository are kept.
+type RetentionPolicy struct {
+	RepositoryID kallax.ULID     `json:"repoId" sql:"type:uuid,pk"`
+	Days         int             `json:"days" sql:",notnull"`
+	Action       RetentionAction `json:"action" sql:",notnull"`
+	CreatedAt    time.Time       `json:"createdAt" sql:",notnull"`
+	UpdatedAt    time.Time       `json:"updatedAt" sql:",notnull"`
+}
+
+// Cutoff returns the time before which the captures are expired at now.
+func (p RetentionPolicy) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -p.Days)
+}
+
+// RetentionReport represents what a run of a retention policy removed from a repository.
+type RetentionReport struct {
+	ID     kallax.ULID     `json:"id" sql:"type:uuid,pk"`
+	Action RetentionAction `json:"action" sql:",notnull"`
+	// Before is the cutoff, the captures taken before it were removed.
+	Before   time.Time `json:"before" sql:",notnull"`
+	Captures int64     `json:"captures" sql:",notnull"`
+	// Archive is the location of the archived captures.
+	Archive      string      `json:"archive,omitempty"`
+	Error        string      `json:"error,omitempty"`
+	StartedAt    time.Time   `json:"startedAt" sql:",notnull"`
+	FinishedAt   time.Time   `json:"finishedAt" sql:",notnull"`
+	CreatedAt    time.Time   `json:"createdAt" sql:",notnull"`
+	RepositoryID kallax.ULID `json:"repoId" sql:"type:uuid"`
+}
@@ -0,0 +1,70 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// Aggregate is how the values of a metric are combined in a rollup.
+type Aggregate string
+
+const (
+	AvgAggregate   Aggregate = "avg"
+	MinAggregate   Aggregate = "min"
+	MaxAggregate   Aggregate = "max"
+	SumAggregate   Aggregate = "sum"
+	CountAggregate Aggregate = "count"
+)
+
+// Aggregates are the allowed rollup aggregates.
+var Aggregates = []Aggregate{AvgAggregate, MinAggregate, MaxAggregate, SumAggregate, CountAggregate}
+
+// RollupRule represents how the captures of a repository older than AfterDays are
+// aggregated by Resolution seconds, metric and tag set.
+type RollupRule struct {
+	ID           kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	AfterDays    int         `json:"afterDays" sql:",notnull"`
+	Resolution   int64       `json:"resolution" sql:",notnull"`
+	Aggregate    Aggregate   `json:"aggregate" sql:",notnull"`
+	CreatedAt    time.Time   `json:"createdAt" sql:",notnull"`
+	UpdatedAt    time.Time   `json:"updatedAt" sql:",notnull"`
+	DeletedAt    *time.Time  `json:"-" pg:",soft_delete"`
+	RepositoryID kallax.ULID `json:"repoId" sql:"type:uuid"`
+}
+
+// Window returns the duration of the buckets of the rule.
+func (r RollupRule) Window() time.Duration {
+	return time.Duration(r.Resolution) * time.Second
+}
+
+// Cutoff returns the time before which the captures are rolled up at now. It's aligned to
+// the window so every bucket before it is complete.
+func (r RollupRule) Cutoff(now time.Time) time.Time {
+	return now.AddDate(0, 0, -r.AfterDays).Truncate(r.Window())
+}
+
+// Rollup represents the aggregation of the captures of a metric and tag set in a bucket
+// of Resolution seconds starting at the capture timestamp.
+type Rollup struct {
+	Resolution int64     `json:"resolution"`
+	Aggregate  Aggregate `json:"aggregate"`
+	// Samples is the number of captures aggregated.
+	Samples int64 `json:"samples"`
+}
+
+// Resolution returns the seconds aggregated by the capture, 0 for the raw ones.
+func (c Capture) Resolution() int64 {
+	if c.Rollup == nil {
+		return 0
+	}
+	return c.Rollup.Resolution
+}
+
+// Samples returns the number of captures aggregated by the capture, 1 for the raw ones.
+func (c Capture) Samples() int64 {
+	if c.Rollup == nil {
+		return 1
+	}
+	return c.Rollup.Samples
+}
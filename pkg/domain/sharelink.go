@@ -0,0 +1,24 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// ShareLink represents a read-only access to a repository given to someone without
+// an account. The link is presented as a signed token carrying its id and expiration.
+type ShareLink struct {
+	ID           kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	Name         string      `json:"name" sql:",notnull"`
+	ExpiresAt    time.Time   `json:"expiresAt" sql:",notnull"`
+	CreatedAt    time.Time   `json:"createdAt" sql:",notnull"`
+	RevokedAt    *time.Time  `json:"revokedAt,omitempty"`
+	RepositoryID kallax.ULID `json:"repoId" sql:"type:uuid,notnull"`
+	UserID       kallax.ULID `json:"-" sql:"type:uuid,notnull"`
+}
+
+// Allows reports whether the link grants read access over a repository at the given time.
+func (l ShareLink) Allows(repoID kallax.ULID, now time.Time) bool {
+	return l.RevokedAt == nil && l.RepositoryID == repoID && now.Before(l.ExpiresAt)
+}
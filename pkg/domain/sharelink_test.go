@@ -0,0 +1,25 @@
+package domain_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+func TestShareLinkAllows(t *testing.T) {
+	t.Parallel()
+
+	repoID := kallax.NewULID()
+	now := time.Now()
+	l := domain.ShareLink{RepositoryID: repoID, ExpiresAt: now.Add(time.Hour)}
+	revoked := domain.ShareLink{RepositoryID: repoID, ExpiresAt: now.Add(time.Hour), RevokedAt: &now}
+
+	assert.True(t, l.Allows(repoID, now))
+	assert.False(t, l.Allows(repoID, now.Add(2*time.Hour)))
+	assert.False(t, l.Allows(kallax.NewULID(), now))
+	assert.False(t, revoked.Allows(repoID, now))
+}
@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// LoginThrottle represents the failed login attempts of an email or a client IP,
+// identified by its key.
+type LoginThrottle struct {
+	Key           string     `sql:",pk"`
+	Failures      int        `sql:",notnull"`
+	LastFailureAt time.Time  `sql:",notnull"`
+	LockedUntil   *time.Time `sql:""`
+}
+
+// Locked reports whether the attempts are locked at the given time.
+func (t LoginThrottle) Locked(now time.Time) bool {
+	return t.LockedUntil != nil && now.Before(*t.LockedUntil)
+}
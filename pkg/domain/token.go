@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// RefreshToken represents a long-lived credential used to renew the access token of an user.
+// Only the hash of the token is stored.
+type RefreshToken struct {
+	ID         kallax.ULID  `sql:"type:uuid,pk"`
+	Hash       string       `sql:",notnull,unique"`
+	ExpiresAt  time.Time    `sql:",notnull"`
+	CreatedAt  time.Time    `sql:",notnull"`
+	RevokedAt  *time.Time   `sql:""`
+	ReplacedBy *kallax.ULID `sql:"type:uuid"`
+	UserID     kallax.ULID  `sql:"type:uuid,notnull"`
+}
+
+// Active reports whether the token can still be used.
+func (t RefreshToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// RevokedToken represents an access token denied before its expiration.
+type RevokedToken struct {
+	JTI       string    `sql:",pk"`
+	ExpiresAt time.Time `sql:",notnull"`
+}
+
+// UserTokenPurpose identifies the flow a user token belongs to.
+type UserTokenPurpose string
+
+const (
+	// VerifyEmailPurpose tokens confirm the email of an user.
+	VerifyEmailPurpose UserTokenPurpose = "verify_email"
+	// ResetPasswordPurpose tokens allow an user to choose a new password.
+	ResetPasswordPurpose UserTokenPurpose = "reset_password"
+	// ChangeEmailPurpose tokens confirm the new email of an user.
+	ChangeEmailPurpose UserTokenPurpose = "change_email"
+	// TwoFactorPurpose tokens identify a login waiting for its second factor.
+	TwoFactorPurpose UserTokenPurpose = "two_factor"
+)
+
+// UserToken represents a single-use token sent to the email of an user.
+// Only the hash of the token is stored.
+type UserToken struct {
+	ID        kallax.ULID      `sql:"type:uuid,pk"`
+	Hash      string           `sql:",notnull,unique"`
+	Purpose   UserTokenPurpose `sql:",notnull"`
+	ExpiresAt time.Time        `sql:",notnull"`
+	UsedAt    *time.Time       `sql:""`
+	CreatedAt time.Time        `sql:",notnull"`
+	UserID    kallax.ULID      `sql:"type:uuid,notnull"`
+}
+
+// RecoveryCode represents a single-use code to complete a two-factor login without the
+// TOTP device. Only the hash of the code is stored.
+type RecoveryCode struct {
+	ID        kallax.ULID `sql:"type:uuid,pk"`
+	Hash      string      `sql:",notnull,unique"`
+	UsedAt    *time.Time  `sql:""`
+	CreatedAt time.Time   `sql:",notnull"`
+	UserID    kallax.ULID `sql:"type:uuid,notnull"`
+}
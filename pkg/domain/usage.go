@@ -0,0 +1,63 @@
+package domain
+
+import (
+	"encoding/json"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// Usage represents the captures stored in a repository and their approximate size in
+// bytes. The usage of an user is the sum of the usages of its repositories.
+type Usage struct {
+	RepositoryID kallax.ULID `json:"-" sql:"type:uuid,pk"`
+	UserID       kallax.ULID `json:"-" sql:"type:uuid,notnull"`
+	Captures     int64       `json:"captures" sql:",notnull"`
+	Bytes        int64       `json:"bytes" sql:",notnull"`
+}
+
+// Add returns the usage plus the captures and bytes of the delta.
+func (u Usage) Add(delta Usage) Usage {
+	u.Captures += delta.Captures
+	u.Bytes += delta.Bytes
+	return u
+}
+
+// NewUsage returns the usage of captures of a repository.
+func NewUsage(r *Repository, captures ...Capture) Usage {
+	u := Usage{RepositoryID: r.ID, UserID: r.UserID}
+	for _, c := range captures {
+		u.Captures++
+		u.Bytes += CaptureSize(c)
+	}
+	return u
+}
+
+// CaptureSize returns the approximate size of a capture, the length of the JSON encoding
+// of the data sent by the client.
+func CaptureSize(c Capture) int64 {
+	b, err := json.Marshal(struct {
+		Payload  Payload  `json:"payload"`
+		Location *Point   `json:"location"`
+		Tags     []string `json:"tags"`
+	}{c.Payload, c.Location, c.Tags})
+	if err != nil {
+		return 0
+	}
+	return int64(len(b))
+}
+
+// Quota represents the maximum captures and bytes allowed. Zero values are unlimited.
+type Quota struct {
+	Captures int64 `json:"captures,omitempty"`
+	Bytes    int64 `json:"bytes,omitempty"`
+}
+
+// AllowsCaptures reports whether the captures are within the quota.
+func (q Quota) AllowsCaptures(u Usage) bool {
+	return q.Captures <= 0 || u.Captures <= q.Captures
+}
+
+// AllowsBytes reports whether the bytes are within the quota.
+func (q Quota) AllowsBytes(u Usage) bool {
+	return q.Bytes <= 0 || u.Bytes <= q.Bytes
+}
@@ -0,0 +1,47 @@
+package domain_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+func TestNewUsage(t *testing.T) {
+	t.Parallel()
+
+	r := &domain.Repository{ID: kallax.NewULID(), UserID: kallax.NewULID()}
+	c := domain.Capture{Tags: []string{"a"}}
+	u := domain.NewUsage(r, c, c)
+
+	assert.Equal(t, r.ID, u.RepositoryID)
+	assert.Equal(t, r.UserID, u.UserID)
+	assert.Equal(t, int64(2), u.Captures)
+	assert.Equal(t, 2*domain.CaptureSize(c), u.Bytes)
+}
+
+func TestCaptureSize(t *testing.T) {
+	t.Parallel()
+
+	small := domain.CaptureSize(domain.Capture{})
+	big := domain.CaptureSize(domain.Capture{Tags: []string{"tag"}})
+	assert.True(t, small > 0)
+	assert.Equal(t, small+int64(len(`["tag"]`)-len(`null`)), big)
+}
+
+func TestQuotaAllows(t *testing.T) {
+	t.Parallel()
+
+	u := domain.Usage{Captures: 10, Bytes: 100}.Add(domain.Usage{Captures: 1, Bytes: 10})
+	assert.Equal(t, domain.Usage{Captures: 11, Bytes: 110}, u)
+
+	unlimited := domain.Quota{}
+	assert.True(t, unlimited.AllowsCaptures(u))
+	assert.True(t, unlimited.AllowsBytes(u))
+
+	q := domain.Quota{Captures: 11, Bytes: 100}
+	assert.True(t, q.AllowsCaptures(u))
+	assert.False(t, q.AllowsBytes(u))
+}
@@ -6,12 +6,37 @@ import (
 	"gopkg.in/src-d/go-kallax.v1"
 )
 
-// User represents a user account.
+// User represents a user account. The TOTP secret of the two-factor authentication is
+// pending until its confirmation, and the time step of the last accepted code is kept so
+// the codes can't be replayed. Disabled accounts can't login nor use their tokens.
 type User struct {
-	ID        kallax.ULID `sql:"type:uuid,pk"`
-	Email     string      `sql:",notnull,unique"`
-	Password  []byte      `sql:",notnull"`
-	CreatedAt time.Time   `sql:",notnull"`
-	UpdatedAt time.Time   `sql:",notnull"`
-	DeletedAt *time.Time  `pg:",soft_delete"`
+	ID                 kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	Email              string      `json:"email" sql:",notnull,unique"`
+	Name               string      `json:"name"`
+	PendingEmail       *string     `json:"pendingEmail,omitempty"`
+	Password           []byte      `json:"-" sql:",notnull"`
+	EmailVerifiedAt    *time.Time  `json:"emailVerifiedAt,omitempty" sql:""`
+	Admin              bool        `json:"admin,omitempty" sql:",notnull"`
+	TwoFactorSecret    string      `json:"-"`
+	TwoFactorEnabledAt *time.Time  `json:"twoFactorEnabledAt,omitempty" sql:""`
+	TwoFactorLastStep  int64       `json:"-" sql:",notnull"`
+	DisabledAt         *time.Time  `json:"disabledAt,omitempty" sql:""`
+	CreatedAt          time.Time   `json:"createdAt" sql:",notnull"`
+	UpdatedAt          time.Time   `json:"updatedAt" sql:",notnull"`
+	DeletedAt          *time.Time  `json:"-" pg:",soft_delete"`
+}
+
+// EmailVerified reports whether the user verified the email.
+func (u User) EmailVerified() bool {
+	return u.EmailVerifiedAt != nil
+}
+
+// TwoFactorEnabled reports whether the user confirmed the two-factor authentication.
+func (u User) TwoFactorEnabled() bool {
+	return u.TwoFactorEnabledAt != nil
+}
+
+// Disabled reports whether an administrator disabled the account.
+func (u User) Disabled() bool {
+	return u.DisabledAt != nil
 }
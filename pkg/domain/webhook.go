@@ -0,0 +1,47 @@
+package domain
+
+import (
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// Webhook represents an URL notified when the events happen within a repository.
+type Webhook struct {
+	ID           kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	URL          string      `json:"url" sql:",notnull"`
+	Secret       string      `json:"secret" sql:",notnull"`
+	Events       []EventType `json:"events" sql:",array,notnull"`
+	CreatedAt    time.Time   `json:"createdAt" sql:",notnull"`
+	UpdatedAt    time.Time   `json:"updatedAt" sql:",notnull"`
+	DeletedAt    *time.Time  `json:"-" pg:",soft_delete"`
+	RepositoryID kallax.ULID `json:"repoId" sql:"type:uuid"`
+}
+
+// Subscribed reports whether the webhook should be notified of the event type.
+func (w Webhook) Subscribed(t EventType) bool {
+	for _, e := range w.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery represents an attempt to notify an event to a webhook.
+type WebhookDelivery struct {
+	ID         kallax.ULID `json:"id" sql:"type:uuid,pk"`
+	EventID    kallax.ULID `json:"eventId" sql:"type:uuid,notnull"`
+	EventType  EventType   `json:"eventType" sql:",notnull"`
+	Attempt    int         `json:"attempt" sql:",notnull"`
+	StatusCode int         `json:"statusCode"`
+	Error      string      `json:"error,omitempty"`
+	Duration   int64       `json:"durationMs"`
+	CreatedAt  time.Time   `json:"createdAt" sql:",notnull"`
+	WebhookID  kallax.ULID `json:"webhookId" sql:"type:uuid"`
+}
+
+// Succeeded reports whether the webhook accepted the event.
+func (d WebhookDelivery) Succeeded() bool {
+	return d.Error == "" && d.StatusCode >= 200 && d.StatusCode < 300
+}
@@ -0,0 +1,35 @@
+package exporting
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+func located(c domain.Capture) bool {
+	return c.Location != nil && c.Location.LAT != nil && c.Location.LNG != nil
+}
+
+// description writes a line with the form `name: value` for every metric.
+func description(p domain.Payload) string {
+	lines := make([]string, len(p))
+	for i, m := range p {
+		lines[i] = fmt.Sprintf("%v: %v", m.Name, m.Value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func encodeXML(w io.Writer, v interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	return enc.Flush()
+}
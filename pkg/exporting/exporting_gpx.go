@@ -0,0 +1,60 @@
+package exporting
+
+import (
+	"encoding/xml"
+	"io"
+	"time"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const gpxNamespace = "http://www.topografix.com/GPX/1/1"
+
+type gpxDoc struct {
+	XMLName xml.Name `xml:"gpx"`
+	XMLNS   string   `xml:"xmlns,attr"`
+	Version string   `xml:"version,attr"`
+	Creator string   `xml:"creator,attr"`
+	Track   gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Name    string     `xml:"name"`
+	Segment gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat       float64  `xml:"lat,attr"`
+	Lon       float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele,omitempty"`
+	Time      string   `xml:"time"`
+	Desc      string   `xml:"desc,omitempty"`
+}
+
+// EncodeGPX writes the located captures of a repository as a GPX track.
+// The metrics of every capture are written as the track point description.
+func EncodeGPX(w io.Writer, r *domain.Repository, captures []domain.Capture) error {
+	doc := gpxDoc{
+		XMLNS:   gpxNamespace,
+		Version: "1.1",
+		Creator: "capture",
+		Track:   gpxTrack{Name: r.Name},
+	}
+	for _, c := range captures {
+		if !located(c) {
+			continue
+		}
+		doc.Track.Segment.Points = append(doc.Track.Segment.Points, gpxPoint{
+			Lat:       *c.Location.LAT,
+			Lon:       *c.Location.LNG,
+			Elevation: c.Location.Elevation,
+			Time:      c.Timestamp.UTC().Format(time.RFC3339Nano),
+			Desc:      description(c.Payload),
+		})
+	}
+	return encodeXML(w, doc)
+}
@@ -0,0 +1,57 @@
+package exporting
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const kmlNamespace = "http://www.opengis.net/kml/2.2"
+
+type kmlDoc struct {
+	XMLName  xml.Name    `xml:"kml"`
+	XMLNS    string      `xml:"xmlns,attr"`
+	Document kmlDocument `xml:"Document"`
+}
+
+type kmlDocument struct {
+	Name       string         `xml:"name"`
+	Placemarks []kmlPlacemark `xml:"Placemark"`
+}
+
+type kmlPlacemark struct {
+	Name        string       `xml:"name,omitempty"`
+	Description string       `xml:"description,omitempty"`
+	When        string       `xml:"TimeStamp>when"`
+	Point       kmlPointElem `xml:"Point"`
+}
+
+type kmlPointElem struct {
+	Coordinates string `xml:"coordinates"`
+}
+
+// EncodeKML writes the located captures of a repository as KML placemarks.
+// The metrics of every capture are written as the placemark description.
+func EncodeKML(w io.Writer, r *domain.Repository, captures []domain.Capture) error {
+	doc := kmlDoc{XMLNS: kmlNamespace, Document: kmlDocument{Name: r.Name}}
+	for _, c := range captures {
+		if !located(c) {
+			continue
+		}
+		coord := fmt.Sprintf("%v,%v", *c.Location.LNG, *c.Location.LAT)
+		if c.Location.Elevation != nil {
+			coord = fmt.Sprintf("%v,%v", coord, *c.Location.Elevation)
+		}
+		doc.Document.Placemarks = append(doc.Document.Placemarks, kmlPlacemark{
+			Name:        strings.Join(c.Tags, ", "),
+			Description: description(c.Payload),
+			When:        c.Timestamp.UTC().Format(time.RFC3339Nano),
+			Point:       kmlPointElem{Coordinates: coord},
+		})
+	}
+	return encodeXML(w, doc)
+}
@@ -0,0 +1,96 @@
+package exporting
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	pageSize     = 100
+	timestampASC = "timestamp ASC"
+)
+
+type invalidFormatErr string
+
+func (i invalidFormatErr) Error() string   { return string(i) }
+func (i invalidFormatErr) IsInvalid() bool { return true }
+
+// Format is the type of document to export.
+type Format string
+
+var (
+	GPX Format = "gpx"
+	KML Format = "kml"
+)
+
+var encoders = map[Format]func(io.Writer, *domain.Repository, []domain.Capture) error{
+	GPX: EncodeGPX,
+	KML: EncodeKML,
+}
+
+// ContentType returns the media type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case GPX:
+		return "application/gpx+xml"
+	case KML:
+		return "application/vnd.google-earth.kml+xml"
+	}
+	return "application/octet-stream"
+}
+
+// CaptureStore provides access to the captures storage.
+type CaptureStore interface {
+	// List retrieve captures with domain.Listing attrs.
+	List(*domain.Listing) ([]domain.Capture, int64, error)
+}
+
+// Service provides exporting operations.
+type Service interface {
+	// ExportCaptures writes all the captures of a repository ordered by timestamp in the given format.
+	ExportCaptures(io.Writer, *domain.Repository, Format) error
+}
+
+type service struct {
+	s CaptureStore
+}
+
+// NewService creates an exporting service with the necessary dependencies
+func NewService(s CaptureStore) Service {
+	return &service{s: s}
+}
+
+func (s *service) ExportCaptures(w io.Writer, r *domain.Repository, f Format) error {
+	encode, ok := encoders[f]
+	if !ok {
+		return errors.WithStack(invalidFormatErr(fmt.Sprintf("not supported format %v", f)))
+	}
+	captures, err := s.repoCaptures(r)
+	if err != nil {
+		return errors.Wrap(err, "could not get repo captures")
+	}
+	if err := encode(w, r, captures); err != nil {
+		return errors.Wrapf(err, "could not encode captures as %v", f)
+	}
+	return nil
+}
+
+func (s *service) repoCaptures(r *domain.Repository) ([]domain.Capture, error) {
+	var result []domain.Capture
+	l := &domain.Listing{Owner: &r.ID, SortKey: timestampASC, Limit: pageSize}
+	for {
+		captures, total, err := s.s.List(l)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, captures...)
+		l.Offset += int64(len(captures))
+		if len(captures) == 0 || l.Offset >= total {
+			return result, nil
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package exporting_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/exporting"
+)
+
+func f2P(v float64) *float64 {
+	return &v
+}
+
+func s2t(date string) time.Time {
+	v, _ := time.Parse(time.RFC3339, date)
+	return v
+}
+
+type mockCaptureStore struct {
+	captures []domain.Capture
+	err      error
+}
+
+func (m *mockCaptureStore) List(l *domain.Listing) ([]domain.Capture, int64, error) {
+	if m.err != nil {
+		return nil, 0, m.err
+	}
+	total := int64(len(m.captures))
+	if l.Offset >= total {
+		return nil, total, nil
+	}
+	end := l.Offset + int64(l.Limit)
+	if end > total {
+		end = total
+	}
+	return m.captures[l.Offset:end], total, nil
+}
+
+var (
+	repo     = &domain.Repository{ID: kallax.NewULID(), Name: "morning"}
+	captures = []domain.Capture{
+		{
+			Payload:   domain.Payload{{Name: "power", Value: 10.0}},
+			Location:  &domain.Point{LAT: f2P(1), LNG: f2P(2), Elevation: f2P(3)},
+			Tags:      []string{"at night"},
+			Timestamp: s2t("1989-12-26T06:01:00Z"),
+		},
+		{
+			Payload:   domain.Payload{{Name: "power", Value: 11.0}},
+			Timestamp: s2t("1989-12-26T06:02:00Z"),
+		},
+	}
+)
+
+func TestServiceExportGPX(t *testing.T) {
+	t.Parallel()
+
+	s := exporting.NewService(&mockCaptureStore{captures: captures})
+	var buf bytes.Buffer
+	err := s.ExportCaptures(&buf, repo, exporting.GPX)
+	assert.Nil(t, err)
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<gpx xmlns="http://www.topografix.com/GPX/1/1" version="1.1" creator="capture">
+  <trk>
+    <name>morning</name>
+    <trkseg>
+      <trkpt lat="1" lon="2">
+        <ele>3</ele>
+        <time>1989-12-26T06:01:00Z</time>
+        <desc>power: 10</desc>
+      </trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestServiceExportKML(t *testing.T) {
+	t.Parallel()
+
+	s := exporting.NewService(&mockCaptureStore{captures: captures})
+	var buf bytes.Buffer
+	err := s.ExportCaptures(&buf, repo, exporting.KML)
+	assert.Nil(t, err)
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2">
+  <Document>
+    <name>morning</name>
+    <Placemark>
+      <name>at night</name>
+      <description>power: 10</description>
+      <TimeStamp>
+        <when>1989-12-26T06:01:00Z</when>
+      </TimeStamp>
+      <Point>
+        <coordinates>2,1,3</coordinates>
+      </Point>
+    </Placemark>
+  </Document>
+</kml>`
+	assert.Equal(t, expected, buf.String())
+}
+
+func TestServiceExportPagesThroughCaptures(t *testing.T) {
+	t.Parallel()
+
+	many := make([]domain.Capture, 250)
+	for i := range many {
+		many[i] = captures[0]
+	}
+	s := exporting.NewService(&mockCaptureStore{captures: many})
+	var buf bytes.Buffer
+	err := s.ExportCaptures(&buf, repo, exporting.GPX)
+	assert.Nil(t, err)
+	assert.Equal(t, 250, bytes.Count(buf.Bytes(), []byte("<trkpt ")))
+}
+
+func TestServiceExportFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name   string
+		store  *mockCaptureStore
+		format exporting.Format
+		err    string
+	}{
+		{"not supported format", &mockCaptureStore{}, "csv", "not supported format csv"},
+		{"store err", &mockCaptureStore{err: errors.New("test")}, exporting.KML, "could not get repo captures: test"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := exporting.NewService(tc.store)
+			err := s.ExportCaptures(&bytes.Buffer{}, repo, tc.format)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package geofencing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gobuffalo/validate"
+
+	"github.com/ifreddyrondon/capture/pkg/validator"
+)
+
+const (
+	errNameRequired    = "name must not be blank"
+	errPolygonVertices = "polygon must have at least three vertices"
+	errVertexRequired  = "latitude and longitude must not be blank"
+	minPolygonVertices = 3
+)
+
+// Payload represents the data to create a geofence.
+type Payload struct {
+	Name    *string                 `json:"name"`
+	Polygon []validator.GeoLocation `json:"polygon"`
+}
+
+func (p *Payload) Validate() error {
+	e := validate.NewErrors()
+	if p.Name == nil || len(strings.TrimSpace(*p.Name)) == 0 {
+		e.Add("name", errNameRequired)
+	}
+
+	if len(p.Polygon) < minPolygonVertices {
+		e.Add("polygon", errPolygonVertices)
+	}
+	for i := range p.Polygon {
+		key := fmt.Sprintf("vertex %v", i)
+		if err := p.Polygon[i].Validate(); err != nil {
+			e.Add(key, fmt.Sprintf("%v: %v", key, err))
+			continue
+		}
+		if p.Polygon[i].LAT == nil || p.Polygon[i].LNG == nil {
+			e.Add(key, fmt.Sprintf("%v: %v", key, errVertexRequired))
+		}
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
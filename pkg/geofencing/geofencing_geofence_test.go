@@ -0,0 +1,77 @@
+package geofencing_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/geofencing"
+	"github.com/ifreddyrondon/capture/pkg/validator"
+)
+
+func f2P(v float64) *float64 {
+	return &v
+}
+
+func s2P(v string) *string {
+	return &v
+}
+
+var square = []validator.GeoLocation{
+	{LAT: f2P(0), LNG: f2P(0)},
+	{LAT: f2P(0), LNG: f2P(10)},
+	{LAT: f2P(10), LNG: f2P(10)},
+	{LAT: f2P(10), LNG: f2P(0)},
+}
+
+func TestValidatePayloadOK(t *testing.T) {
+	t.Parallel()
+
+	p := geofencing.Payload{Name: s2P("restricted"), Polygon: square}
+	assert.Nil(t, p.Validate())
+}
+
+func TestValidatePayloadFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		payload geofencing.Payload
+		errs    []string
+	}{
+		{
+			"missing name",
+			geofencing.Payload{Polygon: square},
+			[]string{"name must not be blank"},
+		},
+		{
+			"blank name",
+			geofencing.Payload{Name: s2P("  "), Polygon: square},
+			[]string{"name must not be blank"},
+		},
+		{
+			"not enough vertices",
+			geofencing.Payload{Name: s2P("restricted"), Polygon: square[:2]},
+			[]string{"polygon must have at least three vertices"},
+		},
+		{
+			"vertex without lat and lng",
+			geofencing.Payload{Name: s2P("restricted"), Polygon: append(square[:3:3], validator.GeoLocation{})},
+			[]string{"vertex 3: latitude and longitude must not be blank"},
+		},
+		{
+			"vertex out of boundaries",
+			geofencing.Payload{Name: s2P("restricted"), Polygon: append(square[:3:3], validator.GeoLocation{LAT: f2P(100), LNG: f2P(1)})},
+			[]string{"vertex 3: latitude out of boundaries, may range from -90.0 to 90.0"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.payload.Validate()
+			for _, e := range tc.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package geofencing
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// MatcherStore provides access to the geofence storage to evaluate new captures.
+type MatcherStore interface {
+	// ListGeofences retrieve all the geofences of a repository.
+	ListGeofences(repoID kallax.ULID) ([]domain.Geofence, error)
+	// RecordGeofenceEvent stores an enter or exit event and moves its geofence to that side in
+	// a single conditional update, returning false without storing it when the geofence already
+	// was on that side.
+	RecordGeofenceEvent(domain.GeofenceEvent) (bool, error)
+}
+
+// Matcher evaluates new captures against the geofences of their repository.
+type Matcher struct {
+	s MatcherStore
+}
+
+// NewMatcher creates a geofence matcher with the necessary dependencies
+func NewMatcher(s MatcherStore) *Matcher {
+	return &Matcher{s: s}
+}
+
+// Match tags the captures located inside a geofence with its name and returns the events produced
+// when the captures, in the given order, cross the boundary of a geofence. The events must be
+// stored with Record once the captures were added.
+func (m *Matcher) Match(r *domain.Repository, captures ...*domain.Capture) ([]domain.GeofenceEvent, error) {
+	geofences, err := m.s.ListGeofences(r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get repo geofences")
+	}
+
+	var events []domain.GeofenceEvent
+	now := time.Now()
+	for i := range geofences {
+		g := &geofences[i]
+		for _, c := range captures {
+			inside := g.Polygon.Contains(c.Location)
+			if inside {
+				c.Tags = appendTag(c.Tags, g.Name)
+			}
+			if c.Location == nil || inside == g.Inside {
+				continue
+			}
+			g.Inside = inside
+			e := domain.GeofenceEvent{
+				ID:           kallax.NewULID(),
+				Type:         domain.Exit,
+				Timestamp:    c.Timestamp,
+				CreatedAt:    now,
+				GeofenceID:   g.ID,
+				CaptureID:    c.ID,
+				RepositoryID: r.ID,
+			}
+			if inside {
+				e.Type = domain.Enter
+			}
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+// Record stores the events and the resulting state of their geofences. An event whose geofence was
+// already moved to its side by a concurrent ingest is discarded, so the stored events of a geofence
+// always alternate between enter and exit.
+func (m *Matcher) Record(events ...domain.GeofenceEvent) error {
+	for _, e := range events {
+		if _, err := m.s.RecordGeofenceEvent(e); err != nil {
+			return errors.Wrapf(err, "could not record geofence %v events", e.GeofenceID)
+		}
+	}
+	return nil
+}
+
+func appendTag(tags []string, tag string) []string {
+	for _, t := range tags {
+		if t == tag {
+			return tags
+		}
+	}
+	return append(tags, tag)
+}
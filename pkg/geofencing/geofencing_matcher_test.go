@@ -0,0 +1,141 @@
+package geofencing_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/geofencing"
+)
+
+type mockMatcherStore struct {
+	geofences []domain.Geofence
+	inside    map[kallax.ULID]bool
+	events    []domain.GeofenceEvent
+	err       error
+}
+
+func (m *mockMatcherStore) ListGeofences(kallax.ULID) ([]domain.Geofence, error) {
+	return m.geofences, m.err
+}
+func (m *mockMatcherStore) RecordGeofenceEvent(e domain.GeofenceEvent) (bool, error) {
+	if m.err != nil {
+		return false, m.err
+	}
+	if m.inside == nil {
+		m.inside = make(map[kallax.ULID]bool)
+	}
+	inside := e.Type == domain.Enter
+	if m.inside[e.GeofenceID] == inside {
+		return false, nil
+	}
+	m.inside[e.GeofenceID] = inside
+	m.events = append(m.events, e)
+	return true, nil
+}
+
+func domainSquare() domain.Polygon {
+	polygon := make(domain.Polygon, len(square))
+	for i, v := range square {
+		polygon[i] = domain.Point{LAT: v.LAT, LNG: v.LNG}
+	}
+	return polygon
+}
+
+func captureAt(lat, lng float64) *domain.Capture {
+	return &domain.Capture{ID: kallax.NewULID(), Location: &domain.Point{LAT: &lat, LNG: &lng}, Tags: []string{}}
+}
+
+func TestMatcherMatch(t *testing.T) {
+	t.Parallel()
+
+	g := domain.Geofence{ID: kallax.NewULID(), Name: "restricted", Polygon: domainSquare()}
+	m := geofencing.NewMatcher(&mockMatcherStore{geofences: []domain.Geofence{g}})
+	repo := &domain.Repository{ID: kallax.NewULID()}
+
+	outside, enter, noLocation, stay, exit := captureAt(20, 20), captureAt(5, 5), &domain.Capture{}, captureAt(6, 6), captureAt(-1, -1)
+	events, err := m.Match(repo, outside, enter, noLocation, stay, exit)
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{}, outside.Tags)
+	assert.Equal(t, []string{"restricted"}, enter.Tags)
+	assert.Nil(t, noLocation.Tags)
+	assert.Equal(t, []string{"restricted"}, stay.Tags)
+	assert.Equal(t, []string{}, exit.Tags)
+
+	assert.Len(t, events, 2)
+	assert.Equal(t, domain.Enter, events[0].Type)
+	assert.Equal(t, enter.ID, events[0].CaptureID)
+	assert.Equal(t, domain.Exit, events[1].Type)
+	assert.Equal(t, exit.ID, events[1].CaptureID)
+	assert.Equal(t, g.ID, events[1].GeofenceID)
+	assert.Equal(t, repo.ID, events[1].RepositoryID)
+}
+
+func TestMatcherMatchWhenAlreadyInside(t *testing.T) {
+	t.Parallel()
+
+	g := domain.Geofence{ID: kallax.NewULID(), Name: "restricted", Polygon: domainSquare(), Inside: true}
+	m := geofencing.NewMatcher(&mockMatcherStore{geofences: []domain.Geofence{g}})
+
+	events, err := m.Match(&domain.Repository{}, captureAt(5, 5))
+	assert.Nil(t, err)
+	assert.Len(t, events, 0)
+}
+
+func TestMatcherMatchErr(t *testing.T) {
+	t.Parallel()
+
+	m := geofencing.NewMatcher(&mockMatcherStore{err: errors.New("test")})
+	_, err := m.Match(&domain.Repository{}, captureAt(5, 5))
+	assert.EqualError(t, err, "could not get repo geofences: test")
+}
+
+func TestMatcherRecord(t *testing.T) {
+	t.Parallel()
+
+	id1, id2 := kallax.NewULID(), kallax.NewULID()
+	events := []domain.GeofenceEvent{
+		{GeofenceID: id1, Type: domain.Enter},
+		{GeofenceID: id2, Type: domain.Enter},
+		{GeofenceID: id2, Type: domain.Exit},
+	}
+	store := &mockMatcherStore{}
+	m := geofencing.NewMatcher(store)
+	assert.Nil(t, m.Record(events...))
+	assert.Equal(t, events, store.events)
+	assert.Equal(t, map[kallax.ULID]bool{id1: true, id2: false}, store.inside)
+}
+
+func TestMatcherRecordDiscardsConcurrentTransitions(t *testing.T) {
+	t.Parallel()
+
+	id := kallax.NewULID()
+	store := &mockMatcherStore{}
+	m := geofencing.NewMatcher(store)
+	first := domain.GeofenceEvent{ID: kallax.NewULID(), GeofenceID: id, Type: domain.Enter}
+	second := domain.GeofenceEvent{ID: kallax.NewULID(), GeofenceID: id, Type: domain.Enter}
+	assert.Nil(t, m.Record(first))
+	assert.Nil(t, m.Record(second))
+	assert.Equal(t, []domain.GeofenceEvent{first}, store.events)
+}
+
+func TestMatcherRecordWithoutEvents(t *testing.T) {
+	t.Parallel()
+
+	m := geofencing.NewMatcher(&mockMatcherStore{err: errors.New("test")})
+	assert.Nil(t, m.Record())
+}
+
+func TestMatcherRecordErr(t *testing.T) {
+	t.Parallel()
+
+	m := geofencing.NewMatcher(&mockMatcherStore{err: errors.New("test")})
+	id := kallax.NewULID()
+	err := m.Record(domain.GeofenceEvent{GeofenceID: id, Type: domain.Enter})
+	assert.EqualError(t, err, fmt.Sprintf("could not record geofence %v events: test", id))
+}
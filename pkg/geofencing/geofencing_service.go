@@ -0,0 +1,124 @@
+package geofencing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// Store provides access to the geofence storage.
+type Store interface {
+	// CreateGeofence stores a new geofence.
+	CreateGeofence(*domain.Geofence) error
+	// ListGeofences retrieve all the geofences of a repository.
+	ListGeofences(repoID kallax.ULID) ([]domain.Geofence, error)
+	// GetGeofence retrieve a geofence of a repository.
+	GetGeofence(geofenceID, repoID kallax.ULID) (*domain.Geofence, error)
+	// SaveGeofence the geofence state into the storage.
+	SaveGeofence(*domain.Geofence) error
+	// ListGeofenceEvents retrieve the events of a geofence with domain.Listing attrs.
+	ListGeofenceEvents(*domain.Listing) ([]domain.GeofenceEvent, int64, error)
+}
+
+// Service provides geofencing operations.
+type Service interface {
+	// CreateGeofence creates a new geofence in a repository.
+	CreateGeofence(*domain.Repository, Payload) (*domain.Geofence, error)
+	// ListGeofences list the repo geofences.
+	ListGeofences(*domain.Repository) ([]domain.Geofence, error)
+	// GetGeofence retrieve a repo geofence.
+	GetGeofence(kallax.ULID, *domain.Repository) (*domain.Geofence, error)
+	// RemoveGeofence removes a geofence from a repo.
+	RemoveGeofence(*domain.Geofence) error
+	// ListEvents list the enter and exit events of a geofence.
+	ListEvents(*domain.Geofence, *listing.Listing) (*ListEventResponse, error)
+}
+
+type service struct {
+	s Store
+}
+
+// NewService creates a geofencing service with the necessary dependencies
+func NewService(s Store) Service {
+	return &service{s: s}
+}
+
+func (s *service) CreateGeofence(r *domain.Repository, p Payload) (*domain.Geofence, error) {
+	g := getDomainGeofence(r, p)
+	if err := s.s.CreateGeofence(g); err != nil {
+		return nil, errors.Wrap(err, "could not create geofence")
+	}
+	return g, nil
+}
+
+func (s *service) ListGeofences(r *domain.Repository) ([]domain.Geofence, error) {
+	geofences, err := s.s.ListGeofences(r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list geofences")
+	}
+	if geofences == nil {
+		geofences = make([]domain.Geofence, 0)
+	}
+	return geofences, nil
+}
+
+func (s *service) GetGeofence(id kallax.ULID, r *domain.Repository) (*domain.Geofence, error) {
+	g, err := s.s.GetGeofence(id, r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get geofence")
+	}
+	return g, nil
+}
+
+func (s *service) RemoveGeofence(g *domain.Geofence) error {
+	t := time.Now()
+	g.DeletedAt = &t
+	if err := s.s.SaveGeofence(g); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not remove geofence %v", g.ID))
+	}
+	return nil
+}
+
+func (s *service) ListEvents(g *domain.Geofence, l *listing.Listing) (*ListEventResponse, error) {
+	levents := domain.NewListing(*l)
+	levents.Owner = &g.ID
+	events, total, err := s.s.ListGeofenceEvents(levents)
+	if err != nil {
+		return nil, errors.Wrap(err, "err getting geofence events")
+	}
+	l.Paging.Total = total
+	return newListEventResponse(events, l), nil
+}
+
+func getDomainGeofence(r *domain.Repository, p Payload) *domain.Geofence {
+	now := time.Now()
+	polygon := make(domain.Polygon, len(p.Polygon))
+	for i, v := range p.Polygon {
+		polygon[i] = domain.Point{LAT: v.LAT, LNG: v.LNG}
+	}
+	return &domain.Geofence{
+		ID:           kallax.NewULID(),
+		Name:         *p.Name,
+		Polygon:      polygon,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		RepositoryID: r.ID,
+	}
+}
+
+type ListEventResponse struct {
+	Results []domain.GeofenceEvent `json:"results"`
+	Listing *listing.Listing       `json:"listing"`
+}
+
+func newListEventResponse(events []domain.GeofenceEvent, l *listing.Listing) *ListEventResponse {
+	if events == nil {
+		events = make([]domain.GeofenceEvent, 0)
+	}
+	return &ListEventResponse{Results: events, Listing: l}
+}
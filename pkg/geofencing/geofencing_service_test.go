@@ -0,0 +1,100 @@
+package geofencing_test
+
+import (
+	"testing"
+
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/ifreddyrondon/bastion/middleware/listing/paging"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/geofencing"
+)
+
+type mockStore struct {
+	geofence  *domain.Geofence
+	geofences []domain.Geofence
+	events    []domain.GeofenceEvent
+	listing   *domain.Listing
+	err       error
+}
+
+func (m *mockStore) CreateGeofence(*domain.Geofence) error { return m.err }
+func (m *mockStore) ListGeofences(kallax.ULID) ([]domain.Geofence, error) {
+	return m.geofences, m.err
+}
+func (m *mockStore) GetGeofence(kallax.ULID, kallax.ULID) (*domain.Geofence, error) {
+	return m.geofence, m.err
+}
+func (m *mockStore) SaveGeofence(*domain.Geofence) error { return m.err }
+func (m *mockStore) ListGeofenceEvents(l *domain.Listing) ([]domain.GeofenceEvent, int64, error) {
+	m.listing = l
+	return m.events, int64(len(m.events)), m.err
+}
+
+func TestServiceCreateGeofence(t *testing.T) {
+	t.Parallel()
+
+	s := geofencing.NewService(&mockStore{})
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	g, err := s.CreateGeofence(repo, geofencing.Payload{Name: s2P("restricted"), Polygon: square})
+	assert.Nil(t, err)
+	assert.Equal(t, "restricted", g.Name)
+	assert.Equal(t, repo.ID, g.RepositoryID)
+	assert.Len(t, g.Polygon, 4)
+	assert.False(t, g.Inside)
+}
+
+func TestServiceListGeofences(t *testing.T) {
+	t.Parallel()
+
+	s := geofencing.NewService(&mockStore{})
+	geofences, err := s.ListGeofences(&domain.Repository{ID: kallax.NewULID()})
+	assert.Nil(t, err)
+	assert.NotNil(t, geofences)
+	assert.Len(t, geofences, 0)
+}
+
+func TestServiceRemoveGeofence(t *testing.T) {
+	t.Parallel()
+
+	s := geofencing.NewService(&mockStore{})
+	g := &domain.Geofence{ID: kallax.NewULID()}
+	assert.Nil(t, s.RemoveGeofence(g))
+	assert.NotNil(t, g.DeletedAt)
+}
+
+func TestServiceListEvents(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{events: []domain.GeofenceEvent{{Type: domain.Enter}, {Type: domain.Exit}}}
+	s := geofencing.NewService(store)
+	g := &domain.Geofence{ID: kallax.NewULID()}
+	l := &listing.Listing{Paging: paging.Paging{Limit: 10}}
+	res, err := s.ListEvents(g, l)
+	assert.Nil(t, err)
+	assert.Len(t, res.Results, 2)
+	assert.Equal(t, int64(2), res.Listing.Paging.Total)
+	assert.Equal(t, g.ID, *store.listing.Owner)
+}
+
+func TestServiceGeofencingErrors(t *testing.T) {
+	t.Parallel()
+
+	s := geofencing.NewService(&mockStore{err: errors.New("test")})
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	g := &domain.Geofence{ID: kallax.NewULID()}
+
+	_, err := s.CreateGeofence(repo, geofencing.Payload{Name: s2P("restricted"), Polygon: square})
+	assert.EqualError(t, err, "could not create geofence: test")
+	_, err = s.ListGeofences(repo)
+	assert.EqualError(t, err, "could not list geofences: test")
+	_, err = s.GetGeofence(g.ID, repo)
+	assert.EqualError(t, err, "could not get geofence: test")
+	err = s.RemoveGeofence(g)
+	assert.EqualError(t, err, "could not remove geofence "+g.ID.String()+": test")
+	_, err = s.ListEvents(g, &listing.Listing{})
+	assert.EqualError(t, err, "err getting geofence events: test")
+}
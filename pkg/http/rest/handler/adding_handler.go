@@ -7,11 +7,33 @@ import (
 
 	"github.com/ifreddyrondon/bastion/binder"
 	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
 
 	"github.com/ifreddyrondon/capture/pkg/adding"
+	"github.com/ifreddyrondon/capture/pkg/domain"
 	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
 )
 
+type quotaExceededErr interface {
+	QuotaExceeded() bool
+}
+
+func isQuotaExceeded(err error) bool {
+	if e, ok := errors.Cause(err).(quotaExceededErr); ok {
+		return e.QuotaExceeded()
+	}
+	return false
+}
+
+func quotaExceeded(w http.ResponseWriter, message string) {
+	httpErr := render.HTTPError{
+		Status:  http.StatusForbidden,
+		Error:   http.StatusText(http.StatusForbidden),
+		Message: message,
+	}
+	render.JSON.Response(w, http.StatusForbidden, httpErr)
+}
+
 // AddingCapture returns a configured http.Handler with adding capture resources.
 func AddingCapture(service adding.CaptureService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -30,11 +52,16 @@ func AddingCapture(service adding.CaptureService) http.HandlerFunc {
 
 		capt, err := service.AddCapture(repo, payload)
 		if err != nil {
+			if isQuotaExceeded(err) {
+				quotaExceeded(w, errors.Cause(err).Error())
+				return
+			}
 			fmt.Fprintln(os.Stderr, err)
 			render.JSON.InternalServerError(w, err)
 			return
 		}
 
+		middleware.SetAuditTarget(r.Context(), domain.CaptureTarget, capt.ID.String())
 		render.JSON.Created(w, capt)
 	}
 }
@@ -57,11 +84,16 @@ func AddingMultiCapture(service adding.MultiCaptureService) http.HandlerFunc {
 
 		captures, err := service.AddCaptures(repo, multi)
 		if err != nil {
+			if isQuotaExceeded(err) {
+				quotaExceeded(w, errors.Cause(err).Error())
+				return
+			}
 			fmt.Fprintln(os.Stderr, err)
 			render.JSON.InternalServerError(w, err)
 			return
 		}
 
+		middleware.AddAuditDetail(r.Context(), "count", len(captures))
 		render.JSON.Created(w, captures)
 	}
 }
@@ -22,6 +22,11 @@ func (m *mockAddingCaptureService) AddCapture(r *domain.Repository, c adding.Cap
 	return m.capt, m.err
 }
 
+type quotaErr string
+
+func (e quotaErr) Error() string       { return string(e) }
+func (e quotaErr) QuotaExceeded() bool { return true }
+
 func setupAddingCaptureHandler(s adding.CaptureService, m func(http.Handler) http.Handler) *bastion.Bastion {
 	app := bastion.New()
 	app.Use(m)
@@ -217,6 +222,29 @@ func TestAddingCaptureInternalServer(t *testing.T) {
 		JSON().Object().Equal(response)
 }
 
+func TestAddingCaptureQuotaExceeded(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAddingCaptureService{err: quotaErr("repository quota exceeded, it allows up to 10 captures")}
+	app := setupAddingCaptureHandler(s, withRepoMiddle(defaultRepo))
+
+	payload := map[string]interface{}{
+		"payload": []map[string]interface{}{{"name": "power", "value": 10.0}},
+	}
+	response := map[string]interface{}{
+		"status":  403.0,
+		"error":   "Forbidden",
+		"message": "repository quota exceeded, it allows up to 10 captures",
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(payload).
+		Expect().
+		Status(http.StatusForbidden).
+		JSON().Object().Equal(response)
+}
+
 type mockAddingMultiCaptureService struct {
 	captures []domain.Capture
 	err      error
@@ -391,3 +419,23 @@ func TestAddingMultiCaptureInternalServer(t *testing.T) {
 		Status(http.StatusInternalServerError).
 		JSON().Object().Equal(response)
 }
+
+func TestAddingMultiCaptureQuotaExceeded(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAddingMultiCaptureService{err: quotaErr("user quota exceeded, it allows up to 1024 bytes")}
+	app := setupAddingMultiCaptureHandler(s, withRepoMiddle(defaultRepo))
+
+	payload := map[string]interface{}{
+		"captures": []map[string]interface{}{
+			{"payload": []map[string]interface{}{{"name": "power", "value": []interface{}{10.0}}}},
+		},
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(payload).
+		Expect().
+		Status(http.StatusForbidden).
+		JSON().Object().ValueEqual("message", "user quota exceeded, it allows up to 1024 bytes")
+}
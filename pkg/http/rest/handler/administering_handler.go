@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/binder"
+	bastionMiddleware "github.com/ifreddyrondon/bastion/middleware"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/administering"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+var errInvalidRepoID = errors.New("invalid repository id")
+
+// getActor returns the authenticated user of a request with the administrator
+// impersonating it, the client ip and user agent.
+func getActor(r *http.Request) (domain.Actor, error) {
+	u, err := middleware.GetUser(r.Context())
+	if err != nil {
+		return domain.Actor{}, err
+	}
+	a := domain.Actor{User: u, IP: middleware.ClientIP(r), UserAgent: r.UserAgent()}
+	if id, err := middleware.GetImpersonator(r.Context()); err == nil {
+		a.Impersonator = id
+	}
+	return a, nil
+}
+
+// renderAdministeringErr renders the errors of the administering service.
+func renderAdministeringErr(w http.ResponseWriter, err error) {
+	if isNotFound(err) {
+		render.JSON.NotFound(w, errors.Cause(err))
+		return
+	}
+	if isInvalidErr(err) {
+		render.JSON.BadRequest(w, errors.Cause(err))
+		return
+	}
+	fmt.Fprintln(os.Stderr, err)
+	render.JSON.InternalServerError(w, err)
+}
+
+// ListingUsers returns a configured http.Handler with admin resources to list and search
+// the users. The q query param searches the users by email or name.
+func ListingUsers(service administering.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, err := bastionMiddleware.GetListing(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		a, err := getActor(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		res, err := service.ListUsers(a, l, r.URL.Query().Get("q"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, res)
+	}
+}
+
+// DisablingUser returns a configured http.Handler with admin resources to disable an account.
+func DisablingUser(service administering.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "userId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidUserID)
+			return
+		}
+
+		a, err := getActor(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		u, err := service.DisableUser(a, id)
+		if err != nil {
+			renderAdministeringErr(w, err)
+			return
+		}
+
+		render.JSON.Send(w, u)
+	}
+}
+
+// EnablingUser returns a configured http.Handler with admin resources to enable a disabled account.
+func EnablingUser(service administering.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "userId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidUserID)
+			return
+		}
+
+		a, err := getActor(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		u, err := service.EnableUser(a, id)
+		if err != nil {
+			renderAdministeringErr(w, err)
+			return
+		}
+
+		render.JSON.Send(w, u)
+	}
+}
+
+// ImpersonatingUser returns a configured http.Handler with admin resources to get a short
+// lived token acting as an user for support.
+func ImpersonatingUser(service administering.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "userId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidUserID)
+			return
+		}
+
+		a, err := getActor(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		imp, err := service.Impersonate(a, id)
+		if err != nil {
+			renderAdministeringErr(w, err)
+			return
+		}
+
+		render.JSON.Send(w, imp)
+	}
+}
+
+// GettingAnyRepo returns a configured http.Handler with admin resources to get a repository
+// regardless of its visibility.
+func GettingAnyRepo(service administering.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "id"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidRepoID)
+			return
+		}
+
+		a, err := getActor(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		repo, err := service.GetRepo(a, id)
+		if err != nil {
+			renderAdministeringErr(w, err)
+			return
+		}
+
+		render.JSON.Send(w, repo)
+	}
+}
+
+// TransferringRepo returns a configured http.Handler with admin resources to transfer the
+// ownership of a repository to an user.
+func TransferringRepo(service administering.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload administering.TransferPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "id"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidRepoID)
+			return
+		}
+
+		a, err := getActor(r)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		repo, err := service.TransferRepo(a, id, payload)
+		if err != nil {
+			renderAdministeringErr(w, err)
+			return
+		}
+
+		render.JSON.Send(w, repo)
+	}
+}
@@ -0,0 +1,151 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	bastionMiddleware "github.com/ifreddyrondon/bastion/middleware"
+	listingBastionMiddleware "github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/administering"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+)
+
+type mockAdministeringService struct {
+	users  []domain.User
+	usr    *domain.User
+	repo   *domain.Repository
+	imp    *administering.Impersonation
+	actor  domain.Actor
+	search string
+	err    error
+}
+
+func (m *mockAdministeringService) ListUsers(a domain.Actor, l *listingBastionMiddleware.Listing, search string) (*administering.ListUserResponse, error) {
+	m.actor, m.search = a, search
+	return &administering.ListUserResponse{Listing: l, Results: m.users}, m.err
+}
+func (m *mockAdministeringService) DisableUser(domain.Actor, kallax.ULID) (*domain.User, error) {
+	return m.usr, m.err
+}
+func (m *mockAdministeringService) EnableUser(domain.Actor, kallax.ULID) (*domain.User, error) {
+	return m.usr, m.err
+}
+func (m *mockAdministeringService) GetRepo(domain.Actor, kallax.ULID) (*domain.Repository, error) {
+	return m.repo, m.err
+}
+func (m *mockAdministeringService) TransferRepo(domain.Actor, kallax.ULID, administering.TransferPayload) (*domain.Repository, error) {
+	return m.repo, m.err
+}
+func (m *mockAdministeringService) Impersonate(domain.Actor, kallax.ULID) (*administering.Impersonation, error) {
+	return m.imp, m.err
+}
+
+func listingUsersMiddle(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l := getBaseListing()
+		ctx := context.WithValue(r.Context(), bastionMiddleware.ListingCtxKey, &l)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func setupAdministeringHandlers(s administering.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.With(listingUsersMiddle).Get("/users/", handler.ListingUsers(s))
+	app.Post("/users/{userId}/disable", handler.DisablingUser(s))
+	app.Post("/users/{userId}/enable", handler.EnablingUser(s))
+	app.Post("/users/{userId}/impersonate", handler.ImpersonatingUser(s))
+	app.Get("/repositories/{id}", handler.GettingAnyRepo(s))
+	app.Post("/repositories/{id}/transfer", handler.TransferringRepo(s))
+	return app
+}
+
+func TestAdministeringHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAdministeringService{
+		users: []domain.User{*defaultUser},
+		usr:   defaultUser,
+		repo:  defaultRepo,
+		imp:   &administering.Impersonation{Token: "token", User: defaultUser},
+	}
+	app := setupAdministeringHandlers(s, withUserMiddle(defaultUser))
+	userPath := "/users/" + defaultUser.ID.String()
+	repoPath := "/repositories/" + defaultRepo.ID.String()
+
+	e := bastion.Tester(t, app)
+	e.GET("/users/").WithQuery("q", "test").
+		WithHeader("User-Agent", "support-tool").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("results").Array().Length().Equal(1)
+	assert.Equal(t, "test", s.search)
+	assert.Equal(t, defaultUser, s.actor.User)
+	assert.Equal(t, "support-tool", s.actor.UserAgent)
+
+	e.POST(userPath+"/disable").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("email", "test@example.com")
+	e.POST(userPath+"/enable").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("email", "test@example.com")
+	e.POST(userPath+"/impersonate").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("token", "token").ContainsKey("user")
+	e.GET(repoPath).Expect().Status(http.StatusOK).JSON().Object().ValueEqual("name", "test public")
+	e.POST(repoPath+"/transfer").
+		WithJSON(map[string]interface{}{"email": "new@example.com"}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().ValueEqual("name", "test public")
+}
+
+func TestAdministeringHandlersFail(t *testing.T) {
+	t.Parallel()
+
+	withUser := []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}
+	userPath := "/users/" + defaultUser.ID.String()
+	repoPath := "/repositories/" + defaultRepo.ID.String()
+	transfer := map[string]interface{}{"email": "new@example.com"}
+
+	tt := []struct {
+		name        string
+		method      string
+		path        string
+		payload     map[string]interface{}
+		service     *mockAdministeringService
+		middlewares []func(http.Handler) http.Handler
+		status      int
+		message     string
+	}{
+		{"listing missing user", "GET", "/users/", nil, &mockAdministeringService{}, nil, http.StatusInternalServerError, "looks like something went wrong"},
+		{"listing err", "GET", "/users/", nil, &mockAdministeringService{err: errors.New("test")}, withUser, http.StatusInternalServerError, "looks like something went wrong"},
+		{"disabling invalid id", "POST", "/users/test/disable", nil, &mockAdministeringService{}, withUser, http.StatusBadRequest, "invalid user id"},
+		{"disabling not found", "POST", userPath + "/disable", nil, &mockAdministeringService{err: notFoundErr("user not found")}, withUser, http.StatusNotFound, "user not found"},
+		{"disabling own account", "POST", userPath + "/disable", nil, &mockAdministeringService{err: invalidErr("administrators can't disable their own account")}, withUser, http.StatusBadRequest, "administrators can't disable their own account"},
+		{"enabling err", "POST", userPath + "/enable", nil, &mockAdministeringService{err: errors.New("test")}, withUser, http.StatusInternalServerError, "looks like something went wrong"},
+		{"impersonating invalid id", "POST", "/users/test/impersonate", nil, &mockAdministeringService{}, withUser, http.StatusBadRequest, "invalid user id"},
+		{"impersonating admin", "POST", userPath + "/impersonate", nil, &mockAdministeringService{err: invalidErr("administrators can't be impersonated")}, withUser, http.StatusBadRequest, "administrators can't be impersonated"},
+		{"getting invalid id", "GET", "/repositories/test", nil, &mockAdministeringService{}, withUser, http.StatusBadRequest, "invalid repository id"},
+		{"getting not found", "GET", repoPath, nil, &mockAdministeringService{err: notFoundErr("repo not found")}, withUser, http.StatusNotFound, "repo not found"},
+		{"transferring invalid payload", "POST", repoPath + "/transfer", map[string]interface{}{}, &mockAdministeringService{}, withUser, http.StatusBadRequest, "email must not be blank"},
+		{"transferring invalid id", "POST", "/repositories/test/transfer", transfer, &mockAdministeringService{}, withUser, http.StatusBadRequest, "invalid repository id"},
+		{"transferring same owner", "POST", repoPath + "/transfer", transfer, &mockAdministeringService{err: invalidErr("the user already owns the repository")}, withUser, http.StatusBadRequest, "the user already owns the repository"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupAdministeringHandlers(tc.service, tc.middlewares...))
+			req := e.Request(tc.method, tc.path)
+			if tc.payload != nil {
+				req = req.WithJSON(tc.payload)
+			}
+			req.Expect().
+				Status(tc.status).
+				JSON().Object().ValueEqual("message", tc.message)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/ifreddyrondon/bastion/render"
+
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+// CreatingAPIKey returns a configured http.Handler with creating api key resources.
+func CreatingAPIKey(service apikeys.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload apikeys.Payload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		k, err := service.CreateKey(u, repo, payload)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		middleware.SetAuditTarget(r.Context(), domain.APIKeyTarget, k.ID.String())
+		render.JSON.Created(w, k)
+	}
+}
+
+// ListingAPIKeys returns a configured http.Handler with api key resources to get the repo keys.
+func ListingAPIKeys(service apikeys.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		keys, err := service.ListKeys(repo)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, keys)
+	}
+}
+
+// GettingAPIKey returns a configured http.Handler with getting api key resources.
+func GettingAPIKey() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		k, err := middleware.GetAPIKey(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, k)
+	}
+}
+
+// RevokingAPIKey returns a configured http.Handler with revoking api key resources.
+func RevokingAPIKey(service apikeys.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		k, err := middleware.GetAPIKey(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err := service.RevokeKey(k); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, k)
+	}
+}
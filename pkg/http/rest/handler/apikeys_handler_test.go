@@ -0,0 +1,164 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+var defaultAPIKey = &domain.APIKey{
+	ID:     kallax.NewULID(),
+	Name:   "tracker",
+	Prefix: "abc",
+	Hash:   []byte("hash"),
+	Scopes: []domain.APIKeyScope{domain.CapturesWrite},
+}
+
+type mockAPIKeysService struct {
+	key  *domain.APIKey
+	keys []domain.APIKey
+	err  error
+}
+
+func (m *mockAPIKeysService) CreateKey(*domain.User, *domain.Repository, apikeys.Payload) (*apikeys.Key, error) {
+	if m.key == nil {
+		return nil, m.err
+	}
+	return &apikeys.Key{APIKey: *m.key, Key: "cap_abc_secret"}, m.err
+}
+func (m *mockAPIKeysService) ListKeys(*domain.Repository) ([]domain.APIKey, error) {
+	return m.keys, m.err
+}
+func (m *mockAPIKeysService) GetKey(kallax.ULID, *domain.Repository) (*domain.APIKey, error) {
+	return m.key, m.err
+}
+func (m *mockAPIKeysService) RevokeKey(*domain.APIKey) error { return m.err }
+
+func withAPIKeyMiddle(k *domain.APIKey) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if k != nil {
+				ctx = context.WithValue(ctx, middleware.APIKeyCtxKey, k)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func setupAPIKeysHandlers(s apikeys.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Post("/", handler.CreatingAPIKey(s))
+	app.Get("/", handler.ListingAPIKeys(s))
+	app.Get("/key", handler.GettingAPIKey())
+	app.Delete("/key", handler.RevokingAPIKey(s))
+	return app
+}
+
+func TestCreatingAPIKeySuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAPIKeysService{key: defaultAPIKey}
+	app := setupAPIKeysHandlers(s, withUserMiddle(defaultUser), withRepoMiddle(defaultRepo))
+
+	payload := map[string]interface{}{
+		"name":   "tracker",
+		"scopes": []string{"captures:write"},
+	}
+
+	e := bastion.Tester(t, app)
+	obj := e.POST("/").
+		WithJSON(payload).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object()
+	obj.ValueEqual("name", "tracker")
+	obj.ValueEqual("key", "cap_abc_secret")
+	obj.NotContainsKey("hash")
+}
+
+func TestCreatingAPIKeyFailBadRequest(t *testing.T) {
+	t.Parallel()
+
+	app := setupAPIKeysHandlers(&mockAPIKeysService{}, withUserMiddle(defaultUser), withRepoMiddle(defaultRepo))
+
+	response := map[string]interface{}{
+		"status":  400.0,
+		"error":   "Bad Request",
+		"message": "scopes must contain at least one scope",
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(map[string]interface{}{"name": "tracker"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().Equal(response)
+}
+
+func TestAPIKeysHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAPIKeysService{keys: []domain.APIKey{*defaultAPIKey}}
+	app := setupAPIKeysHandlers(s, withRepoMiddle(defaultRepo), withAPIKeyMiddle(defaultAPIKey))
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.GET("/key").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("name", "tracker").NotContainsKey("key")
+	e.DELETE("/key").Expect().Status(http.StatusOK).JSON().Object().ContainsKey("id")
+}
+
+func TestAPIKeysHandlersFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name        string
+		method      string
+		path        string
+		service     *mockAPIKeysService
+		middlewares []func(http.Handler) http.Handler
+	}{
+		{"creating missing user", "POST", "/", &mockAPIKeysService{}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"creating missing repo", "POST", "/", &mockAPIKeysService{}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}},
+		{"creating err", "POST", "/", &mockAPIKeysService{err: errors.New("test")}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser), withRepoMiddle(defaultRepo)}},
+		{"listing missing repo", "GET", "/", &mockAPIKeysService{}, nil},
+		{"listing err", "GET", "/", &mockAPIKeysService{err: errors.New("test")}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"getting missing key", "GET", "/key", &mockAPIKeysService{}, nil},
+		{"revoking missing key", "DELETE", "/key", &mockAPIKeysService{}, nil},
+		{"revoking err", "DELETE", "/key", &mockAPIKeysService{err: errors.New("test")}, []func(http.Handler) http.Handler{withAPIKeyMiddle(defaultAPIKey)}},
+	}
+
+	payload := map[string]interface{}{
+		"name":   "tracker",
+		"scopes": []string{"captures:write"},
+	}
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupAPIKeysHandlers(tc.service, tc.middlewares...))
+			e.Request(tc.method, tc.path).
+				WithJSON(payload).
+				Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
+}
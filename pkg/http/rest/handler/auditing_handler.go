@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	bastionMiddleware "github.com/ifreddyrondon/bastion/middleware"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/auditing"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+var errInvalidActorID = errors.New("invalid actor id")
+
+// actorParam returns the actor id of the actor query param, if any.
+func actorParam(r *http.Request) (*kallax.ULID, error) {
+	param := r.URL.Query().Get("actor")
+	if param == "" {
+		return nil, nil
+	}
+	id, err := kallax.NewULIDFromText(param)
+	if err != nil {
+		return nil, errInvalidActorID
+	}
+	return &id, nil
+}
+
+// ListingAuditEntries returns a configured http.Handler with admin resources to list the
+// whole audit trail. The actor query param filters the entries by the user performing them.
+func ListingAuditEntries(service auditing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor, err := actorParam(r)
+		if err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		l, err := bastionMiddleware.GetListing(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		res, err := service.ListEntries(l, actor)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, res)
+	}
+}
+
+// ListingRepoAuditEntries returns a configured http.Handler with audit resources to list
+// the entries within a repository. The actor query param filters the entries by the user
+// performing them.
+func ListingRepoAuditEntries(service auditing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		actor, err := actorParam(r)
+		if err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		l, err := bastionMiddleware.GetListing(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		res, err := service.ListRepoEntries(repo, l, actor)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, res)
+	}
+}
@@ -0,0 +1,166 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	listingBastionMiddleware "github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/auditing"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+type mockAuditingService struct {
+	entries  []domain.AuditEntry
+	recorded []*domain.AuditEntry
+	repo     *domain.Repository
+	actor    *kallax.ULID
+	err      error
+}
+
+func (m *mockAuditingService) Record(e *domain.AuditEntry) error {
+	m.recorded = append(m.recorded, e)
+	return m.err
+}
+func (m *mockAuditingService) ListEntries(l *listingBastionMiddleware.Listing, actor *kallax.ULID) (*auditing.ListEntryResponse, error) {
+	m.actor = actor
+	return &auditing.ListEntryResponse{Listing: l, Results: m.entries}, m.err
+}
+func (m *mockAuditingService) ListRepoEntries(r *domain.Repository, l *listingBastionMiddleware.Listing, actor *kallax.ULID) (*auditing.ListEntryResponse, error) {
+	m.repo, m.actor = r, actor
+	return &auditing.ListEntryResponse{Listing: l, Results: m.entries}, m.err
+}
+
+func setupAuditingHandlers(s auditing.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.With(listingUsersMiddle).Get("/audit/", handler.ListingAuditEntries(s))
+	app.With(listingUsersMiddle).Get("/repositories/audit/", handler.ListingRepoAuditEntries(s))
+	return app
+}
+
+func TestListingAuditEntriesSuccess(t *testing.T) {
+	t.Parallel()
+
+	entry := domain.NewAuditEntry(domain.Actor{User: defaultUser}, domain.RepoCreated, domain.RepositoryTarget, defaultRepo.ID.String())
+	s := &mockAuditingService{entries: []domain.AuditEntry{*entry}}
+	e := bastion.Tester(t, setupAuditingHandlers(s, withRepoMiddle(defaultRepo)))
+
+	e.GET("/audit/").WithQuery("actor", defaultUser.ID.String()).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("results").Array().Length().Equal(1)
+	assert.Equal(t, defaultUser.ID, *s.actor)
+
+	e.GET("/repositories/audit/").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("results").Array().First().Object().
+		ValueEqual("action", "repository.created").
+		ValueEqual("targetId", defaultRepo.ID.String())
+	assert.Equal(t, defaultRepo, s.repo)
+	assert.Nil(t, s.actor)
+}
+
+func TestListingAuditEntriesFail(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name        string
+		path        string
+		actor       string
+		service     *mockAuditingService
+		middlewares []func(http.Handler) http.Handler
+		status      int
+		message     string
+	}{
+		{"invalid actor", "/audit/", "test", &mockAuditingService{}, nil, http.StatusBadRequest, "invalid actor id"},
+		{"listing err", "/audit/", "", &mockAuditingService{err: errors.New("test")}, nil, http.StatusInternalServerError, "looks like something went wrong"},
+		{"repo invalid actor", "/repositories/audit/", "test", &mockAuditingService{}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}, http.StatusBadRequest, "invalid actor id"},
+		{"missing repo", "/repositories/audit/", "", &mockAuditingService{}, nil, http.StatusInternalServerError, "looks like something went wrong"},
+		{"repo listing err", "/repositories/audit/", "", &mockAuditingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}, http.StatusInternalServerError, "looks like something went wrong"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupAuditingHandlers(tc.service, tc.middlewares...))
+			req := e.GET(tc.path)
+			if tc.actor != "" {
+				req = req.WithQuery("actor", tc.actor)
+			}
+			req.Expect().
+				Status(tc.status).
+				JSON().Object().ValueEqual("message", tc.message)
+		})
+	}
+}
+
+func TestAuthenticatingAudit(t *testing.T) {
+	t.Parallel()
+
+	usr := &domain.User{ID: kallax.NewULID()}
+	tt := []struct {
+		name     string
+		service  *mockAuthenticatingService
+		action   domain.AuditAction
+		targetID string
+		actor    *kallax.ULID
+	}{
+		{"succeeded", &mockAuthenticatingService{usr: usr, token: "token"}, domain.LoginSucceeded, usr.ID.String(), &usr.ID},
+		{"failed", &mockAuthenticatingService{err: invalidCredentialErr("invalid email or password")}, domain.LoginFailed, "bla@example.com", nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &mockAuditingService{}
+			app := bastion.New()
+			app.With(middleware.AuditAttempt(s, domain.LoginSucceeded, domain.LoginFailed)).
+				Post("/", handler.Authenticating(tc.service, &mockRefreshService{refreshToken: "refresh"}))
+
+			e := bastion.Tester(t, app)
+			e.POST("/").WithJSON(map[string]interface{}{"email": "bla@example.com", "password": "123"}).Expect()
+
+			assert.Len(t, s.recorded, 1)
+			assert.Equal(t, tc.action, s.recorded[0].Action)
+			assert.Equal(t, domain.UserTarget, s.recorded[0].TargetType)
+			assert.Equal(t, tc.targetID, s.recorded[0].TargetID)
+			assert.Equal(t, tc.actor, s.recorded[0].ActorID)
+		})
+	}
+}
+
+func TestUpdatingRepoAuditVisibility(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name       string
+		visibility string
+		recorded   int
+	}{
+		{"changed", "public", 1},
+		{"unchanged", "private", 0},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &mockAuditingService{}
+			repo := &domain.Repository{ID: kallax.NewULID(), Visibility: domain.Private}
+			app := bastion.New()
+			app.Use(withRepoMiddle(repo))
+			app.With(middleware.Audit(s, domain.RepoVisibilityChanged)).
+				Put("/", handler.UpdatingRepo(&mockUpdatingRepoService{visibility: domain.Visibility(tc.visibility)}))
+
+			e := bastion.Tester(t, app)
+			e.PUT("/").WithJSON(map[string]interface{}{"visibility": tc.visibility}).Expect().Status(http.StatusOK)
+			assert.Len(t, s.recorded, tc.recorded)
+		})
+	}
+}
@@ -2,14 +2,19 @@ package handler
 
 import (
 	"fmt"
+	"math"
 	"net/http"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/ifreddyrondon/bastion/binder"
 	"github.com/ifreddyrondon/bastion/render"
 	"github.com/pkg/errors"
 
 	"github.com/ifreddyrondon/capture/pkg/authenticating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
 )
 
 type authenticatingErr interface {
@@ -24,28 +29,154 @@ func isInvalidCredential(err error) bool {
 	return false
 }
 
+type throttledErr interface {
+	// RetryAfter returns how long the client must wait before trying again.
+	RetryAfter() time.Duration
+}
+
+func retryAfter(err error) (time.Duration, bool) {
+	if e, ok := errors.Cause(err).(throttledErr); ok {
+		return e.RetryAfter(), true
+	}
+	return 0, false
+}
+
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, message string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	httpErr := render.HTTPError{
+		Status:  http.StatusTooManyRequests,
+		Error:   http.StatusText(http.StatusTooManyRequests),
+		Message: message,
+	}
+	render.JSON.Response(w, http.StatusTooManyRequests, httpErr)
+}
+
 type tokenJSON struct {
-	Token string `json:"token,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refreshToken,omitempty"`
+}
+
+type challengeJSON struct {
+	Challenge string `json:"challenge"`
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	httpErr := render.HTTPError{
+		Status:  http.StatusUnauthorized,
+		Error:   http.StatusText(http.StatusUnauthorized),
+		Message: message,
+	}
+	render.JSON.Response(w, http.StatusUnauthorized, httpErr)
 }
 
 // AuthenticatingRoutes returns a configured http.Handler with capture resources.
-func Authenticating(service authenticating.Service) http.HandlerFunc {
+func Authenticating(service authenticating.Service, refreshService authenticating.RefreshService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var credential authenticating.BasicCredential
 		if err := binder.JSON.FromReq(r, &credential); err != nil {
+			middleware.SkipAudit(r.Context())
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		// failed logins are audited by the email, there may be no user behind it.
+		middleware.SetAuditTarget(r.Context(), domain.UserTarget, credential.Email)
+		login, err := service.AuthenticateUser(credential, middleware.ClientIP(r))
+		if err != nil {
+			if isInvalidCredential(err) {
+				unauthorized(w, "invalid email or password")
+				return
+			}
+			if wait, ok := retryAfter(err); ok {
+				tooManyRequests(w, wait, errors.Cause(err).Error())
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if login.Pending() {
+			// the login is audited once the challenge is completed.
+			middleware.SkipAudit(r.Context())
+			render.JSON.Send(w, challengeJSON{Challenge: login.Challenge})
+			return
+		}
+
+		auditLogin(r, login.User)
+		sendTokens(w, service, refreshService, login.User)
+	}
+}
+
+// CompletingChallenge exchanges a login challenge and a two-factor code for a pair of tokens.
+func CompletingChallenge(service authenticating.Service, refreshService authenticating.RefreshService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload authenticating.ChallengePayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			middleware.SkipAudit(r.Context())
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		middleware.SetAuditTarget(r.Context(), domain.UserTarget, "")
+		middleware.AddAuditDetail(r.Context(), "twoFactor", true)
+		u, err := service.CompleteChallenge(payload, middleware.ClientIP(r))
+		if err != nil {
+			if isInvalidCredential(err) {
+				unauthorized(w, errors.Cause(err).Error())
+				return
+			}
+			if wait, ok := retryAfter(err); ok {
+				tooManyRequests(w, wait, errors.Cause(err).Error())
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		auditLogin(r, u)
+		sendTokens(w, service, refreshService, u)
+	}
+}
+
+// auditLogin sets the logged in user as actor and target of the audit entry of a login.
+func auditLogin(r *http.Request, u *domain.User) {
+	middleware.SetAuditTarget(r.Context(), domain.UserTarget, u.ID.String())
+	middleware.SetAuditActor(r.Context(), u.ID)
+}
+
+func sendTokens(w http.ResponseWriter, service authenticating.Service, refreshService authenticating.RefreshService, u *domain.User) {
+	t, err := service.GetUserToken(u.ID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		render.JSON.InternalServerError(w, err)
+		return
+	}
+
+	refresh, err := refreshService.IssueRefreshToken(u.ID)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		render.JSON.InternalServerError(w, err)
+		return
+	}
+
+	render.JSON.Send(w, tokenJSON{Token: t, RefreshToken: refresh})
+}
+
+// RefreshingToken exchanges a refresh token for a new pair of tokens.
+func RefreshingToken(service authenticating.RefreshService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload authenticating.RefreshPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
 			render.JSON.BadRequest(w, err)
 			return
 		}
 
-		u, err := service.AuthenticateUser(credential)
+		tokens, err := service.Refresh(payload)
 		if err != nil {
 			if isInvalidCredential(err) {
-				httpErr := render.HTTPError{
-					Status:  http.StatusUnauthorized,
-					Error:   http.StatusText(http.StatusUnauthorized),
-					Message: "invalid email or password",
-				}
-				render.JSON.Response(w, http.StatusUnauthorized, httpErr)
+				unauthorized(w, "invalid refresh token")
 				return
 			}
 			fmt.Fprintln(os.Stderr, err)
@@ -53,13 +184,55 @@ func Authenticating(service authenticating.Service) http.HandlerFunc {
 			return
 		}
 
-		t, err := service.GetUserToken(u.ID)
+		middleware.SetAuditTarget(r.Context(), domain.UserTarget, tokens.UserID.String())
+		middleware.SetAuditActor(r.Context(), tokens.UserID)
+		render.JSON.Send(w, tokens)
+	}
+}
+
+// LoggingOut revokes the access token of the request and optionally the refresh tokens of the user.
+func LoggingOut(service authenticating.RefreshService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			render.JSON.InternalServerError(w, err)
 			return
 		}
 
-		render.JSON.Send(w, tokenJSON{Token: t})
+		var payload authenticating.LogoutPayload
+		if r.ContentLength != 0 {
+			if err := binder.JSON.FromReq(r, &payload); err != nil {
+				render.JSON.BadRequest(w, err)
+				return
+			}
+		}
+
+		if err := service.Logout(r, u, payload); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// UnlockingLogin forgets the failed login attempts of an email or a client ip.
+func UnlockingLogin(service authenticating.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload authenticating.UnlockPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		if err := service.Unlock(payload); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
 	}
 }
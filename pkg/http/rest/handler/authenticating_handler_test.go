@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
 
 	"github.com/ifreddyrondon/bastion"
+	"github.com/stretchr/testify/assert"
 	"gopkg.in/src-d/go-kallax.v1"
 
 	"github.com/ifreddyrondon/capture/pkg/authenticating"
@@ -16,18 +18,48 @@ import (
 )
 
 type mockAuthenticatingService struct {
-	usr      *domain.User
-	token    string
-	err      error
-	tokenErr error
+	usr       *domain.User
+	challenge string
+	token     string
+	err       error
+	tokenErr  error
+	unlock    *authenticating.UnlockPayload
 }
 
-func (s *mockAuthenticatingService) AuthenticateUser(credential authenticating.BasicCredential) (*domain.User, error) {
+func (s *mockAuthenticatingService) AuthenticateUser(authenticating.BasicCredential, string) (*authenticating.Login, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &authenticating.Login{User: s.usr, Challenge: s.challenge}, nil
+}
+func (s *mockAuthenticatingService) CompleteChallenge(authenticating.ChallengePayload, string) (*domain.User, error) {
 	return s.usr, s.err
 }
 func (s *mockAuthenticatingService) GetUserToken(kallax.ULID) (string, error) {
 	return s.token, s.tokenErr
 }
+func (s *mockAuthenticatingService) Unlock(p authenticating.UnlockPayload) error {
+	s.unlock = &p
+	return s.err
+}
+
+type mockRefreshService struct {
+	refreshToken string
+	tokens       *authenticating.Tokens
+	err          error
+	logout       *authenticating.LogoutPayload
+}
+
+func (m *mockRefreshService) IssueRefreshToken(kallax.ULID) (string, error) {
+	return m.refreshToken, m.err
+}
+func (m *mockRefreshService) Refresh(authenticating.RefreshPayload) (*authenticating.Tokens, error) {
+	return m.tokens, m.err
+}
+func (m *mockRefreshService) Logout(_ *http.Request, _ *domain.User, p authenticating.LogoutPayload) error {
+	m.logout = &p
+	return m.err
+}
 
 type invalidCredentialErr string
 
@@ -42,9 +74,9 @@ func TestAuthenticateSuccess(t *testing.T) {
 		token: "token*test",
 	}
 	app := bastion.New()
-	app.Post("/", handler.Authenticating(s))
+	app.Post("/", handler.Authenticating(s, &mockRefreshService{refreshToken: "refresh*test"}))
 
-	response := map[string]interface{}{"token": "token*test"}
+	response := map[string]interface{}{"token": "token*test", "refreshToken": "refresh*test"}
 	e := bastion.Tester(t, app)
 	payload := map[string]interface{}{"email": "bla@example.com", "password": "123"}
 	e.POST("/").WithJSON(payload).
@@ -58,7 +90,7 @@ func TestAuthenticateFailBadRequest(t *testing.T) {
 
 	s := &mockAuthenticatingService{usr: &domain.User{}}
 	app := bastion.New()
-	app.Post("/", handler.Authenticating(s))
+	app.Post("/", handler.Authenticating(s, &mockRefreshService{}))
 
 	response := map[string]interface{}{
 		"status":  400.0,
@@ -78,7 +110,7 @@ func TestAuthenticateFailUnauthorized(t *testing.T) {
 
 	s := &mockAuthenticatingService{err: invalidCredentialErr("invalid email or password")}
 	app := bastion.New()
-	app.Post("/", handler.Authenticating(s))
+	app.Post("/", handler.Authenticating(s, &mockRefreshService{}))
 
 	response := map[string]interface{}{
 		"status":  401.0,
@@ -93,12 +125,36 @@ func TestAuthenticateFailUnauthorized(t *testing.T) {
 		JSON().Object().Equal(response)
 }
 
+type throttledErr string
+
+func (e throttledErr) Error() string             { return string(e) }
+func (e throttledErr) RetryAfter() time.Duration { return 1500 * time.Millisecond }
+
+func TestAuthenticateFailTooManyRequests(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuthenticatingService{err: throttledErr("too many failed login attempts")}
+	app := bastion.New()
+	app.Post("/", handler.Authenticating(s, &mockRefreshService{}))
+
+	response := map[string]interface{}{
+		"status":  429.0,
+		"error":   "Too Many Requests",
+		"message": "too many failed login attempts",
+	}
+	e := bastion.Tester(t, app)
+	payload := map[string]interface{}{"email": "bla@example.com", "password": "123"}
+	res := e.POST("/").WithJSON(payload).Expect()
+	res.Status(http.StatusTooManyRequests).JSON().Object().Equal(response)
+	res.Header("Retry-After").Equal("2")
+}
+
 func TestAuthenticateFailInternalServerErrorWhenAuthenticateUser(t *testing.T) {
 	t.Parallel()
 
 	s := &mockAuthenticatingService{err: errors.New("test")}
 	app := bastion.New()
-	app.Post("/", handler.Authenticating(s))
+	app.Post("/", handler.Authenticating(s, &mockRefreshService{}))
 
 	response := map[string]interface{}{
 		"status":  500.0,
@@ -118,7 +174,7 @@ func TestAuthenticateFailInternalServerErrorWhenGetUserToken(t *testing.T) {
 
 	s := &mockAuthenticatingService{usr: &domain.User{}, tokenErr: errors.New("test")}
 	app := bastion.New()
-	app.Post("/", handler.Authenticating(s))
+	app.Post("/", handler.Authenticating(s, &mockRefreshService{}))
 
 	response := map[string]interface{}{
 		"status":  500.0,
@@ -132,3 +188,222 @@ func TestAuthenticateFailInternalServerErrorWhenGetUserToken(t *testing.T) {
 		Status(http.StatusInternalServerError).
 		JSON().Object().Equal(response)
 }
+
+func TestAuthenticateFailInternalServerErrorWhenIssueRefreshToken(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuthenticatingService{usr: &domain.User{}, token: "token*test"}
+	app := bastion.New()
+	app.Post("/", handler.Authenticating(s, &mockRefreshService{err: errors.New("test")}))
+
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+	e := bastion.Tester(t, app)
+	payload := map[string]interface{}{"email": "bla@example.com", "password": "123"}
+	e.POST("/").WithJSON(payload).
+		Expect().
+		Status(http.StatusInternalServerError).
+		JSON().Object().Equal(response)
+}
+
+func TestRefreshingTokenSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockRefreshService{tokens: &authenticating.Tokens{Token: "token*test", RefreshToken: "refresh*test"}}
+	app := bastion.New()
+	app.Post("/", handler.RefreshingToken(s))
+
+	response := map[string]interface{}{"token": "token*test", "refreshToken": "refresh*test"}
+	e := bastion.Tester(t, app)
+	e.POST("/").WithJSON(map[string]interface{}{"refreshToken": "abc"}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Equal(response)
+}
+
+func TestRefreshingTokenFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name     string
+		service  *mockRefreshService
+		payload  map[string]interface{}
+		status   int
+		response map[string]interface{}
+	}{
+		{
+			name:    "missing refresh token",
+			service: &mockRefreshService{},
+			payload: map[string]interface{}{},
+			status:  http.StatusBadRequest,
+			response: map[string]interface{}{
+				"status":  400.0,
+				"error":   "Bad Request",
+				"message": "refresh token must not be blank",
+			},
+		},
+		{
+			name:    "invalid refresh token",
+			service: &mockRefreshService{err: invalidCredentialErr("invalid refresh token")},
+			payload: map[string]interface{}{"refreshToken": "abc"},
+			status:  http.StatusUnauthorized,
+			response: map[string]interface{}{
+				"status":  401.0,
+				"error":   "Unauthorized",
+				"message": "invalid refresh token",
+			},
+		},
+		{
+			name:    "service err",
+			service: &mockRefreshService{err: errors.New("test")},
+			payload: map[string]interface{}{"refreshToken": "abc"},
+			status:  http.StatusInternalServerError,
+			response: map[string]interface{}{
+				"status":  500.0,
+				"error":   "Internal Server Error",
+				"message": "looks like something went wrong",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := bastion.New()
+			app.Post("/", handler.RefreshingToken(tc.service))
+			e := bastion.Tester(t, app)
+			e.POST("/").WithJSON(tc.payload).
+				Expect().
+				Status(tc.status).
+				JSON().Object().Equal(tc.response)
+		})
+	}
+}
+
+func TestLoggingOutSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockRefreshService{}
+	app := bastion.New()
+	app.With(withUserMiddle(defaultUser)).Post("/", handler.LoggingOut(s))
+
+	e := bastion.Tester(t, app)
+	e.POST("/").Expect().Status(http.StatusNoContent)
+	assert.False(t, s.logout.All)
+
+	e.POST("/").WithJSON(map[string]interface{}{"all": true}).Expect().Status(http.StatusNoContent)
+	assert.True(t, s.logout.All)
+}
+
+func TestLoggingOutFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	app := bastion.New()
+	app.With(withUserMiddle(nil)).Post("/", handler.LoggingOut(&mockRefreshService{}))
+	e := bastion.Tester(t, app)
+	e.POST("/").Expect().Status(http.StatusInternalServerError).JSON().Object().Equal(response)
+
+	app = bastion.New()
+	app.With(withUserMiddle(defaultUser)).Post("/", handler.LoggingOut(&mockRefreshService{err: errors.New("test")}))
+	e = bastion.Tester(t, app)
+	e.POST("/").Expect().Status(http.StatusInternalServerError).JSON().Object().Equal(response)
+}
+
+func TestUnlockingLoginSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuthenticatingService{}
+	app := bastion.New()
+	app.Post("/", handler.UnlockingLogin(s))
+
+	e := bastion.Tester(t, app)
+	e.POST("/").WithJSON(map[string]interface{}{"email": "bla@example.com"}).Expect().Status(http.StatusNoContent)
+	assert.Equal(t, "bla@example.com", *s.unlock.Email)
+}
+
+func TestUnlockingLoginFails(t *testing.T) {
+	t.Parallel()
+
+	app := bastion.New()
+	app.Post("/", handler.UnlockingLogin(&mockAuthenticatingService{}))
+	e := bastion.Tester(t, app)
+	e.POST("/").WithJSON(map[string]interface{}{}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().ValueEqual("message", "email or ip must not be blank")
+
+	app = bastion.New()
+	app.Post("/", handler.UnlockingLogin(&mockAuthenticatingService{err: errors.New("test")}))
+	e = bastion.Tester(t, app)
+	e.POST("/").WithJSON(map[string]interface{}{"ip": "127.0.0.1"}).
+		Expect().
+		Status(http.StatusInternalServerError)
+}
+
+func TestAuthenticatePendingChallenge(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuthenticatingService{usr: &domain.User{ID: kallax.NewULID()}, challenge: "challenge*test"}
+	app := bastion.New()
+	app.Post("/", handler.Authenticating(s, &mockRefreshService{refreshToken: "refresh*test"}))
+
+	e := bastion.Tester(t, app)
+	payload := map[string]interface{}{"email": "bla@example.com", "password": "123"}
+	e.POST("/").WithJSON(payload).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Equal(map[string]interface{}{"challenge": "challenge*test"})
+}
+
+func TestCompletingChallengeSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuthenticatingService{usr: &domain.User{ID: kallax.NewULID()}, token: "token*test"}
+	app := bastion.New()
+	app.Post("/", handler.CompletingChallenge(s, &mockRefreshService{refreshToken: "refresh*test"}))
+
+	response := map[string]interface{}{"token": "token*test", "refreshToken": "refresh*test"}
+	e := bastion.Tester(t, app)
+	payload := map[string]interface{}{"challenge": "challenge*test", "code": "123456"}
+	e.POST("/").WithJSON(payload).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Equal(response)
+}
+
+func TestCompletingChallengeFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		service *mockAuthenticatingService
+		payload map[string]interface{}
+		status  int
+		message string
+	}{
+		{"missing code", &mockAuthenticatingService{}, map[string]interface{}{"challenge": "challenge*test"}, http.StatusBadRequest, "code must not be blank"},
+		{"invalid code", &mockAuthenticatingService{err: invalidCredentialErr("invalid two-factor code")}, map[string]interface{}{"challenge": "challenge*test", "code": "123456"}, http.StatusUnauthorized, "invalid two-factor code"},
+		{"throttled", &mockAuthenticatingService{err: throttledErr("too many failed login attempts")}, map[string]interface{}{"challenge": "challenge*test", "code": "123456"}, http.StatusTooManyRequests, "too many failed login attempts"},
+		{"service err", &mockAuthenticatingService{err: errors.New("test")}, map[string]interface{}{"challenge": "challenge*test", "code": "123456"}, http.StatusInternalServerError, "looks like something went wrong"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := bastion.New()
+			app.Post("/", handler.CompletingChallenge(tc.service, &mockRefreshService{}))
+			e := bastion.Tester(t, app)
+			e.POST("/").WithJSON(tc.payload).
+				Expect().
+				Status(tc.status).
+				JSON().Object().ValueEqual("message", tc.message)
+		})
+	}
+}
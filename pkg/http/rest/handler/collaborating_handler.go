@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/collaborating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+var errInvalidInvitationID = errors.New("invalid invitation id")
+
+type notFoundErr interface {
+	NotFound() bool
+}
+
+func isNotFound(err error) bool {
+	if e, ok := errors.Cause(err).(notFoundErr); ok {
+		return e.NotFound()
+	}
+	return false
+}
+
+// InvitingCollaborator returns a configured http.Handler with inviting collaborator resources.
+func InvitingCollaborator(service collaborating.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload collaborating.Payload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		c, err := service.Invite(u, repo, payload)
+		if err != nil {
+			if isNotFound(err) {
+				render.JSON.NotFound(w, err)
+				return
+			}
+			if isInvalidErr(err) {
+				render.JSON.BadRequest(w, err)
+				return
+			}
+			if isConflictErr(err) {
+				conflict(w, err.Error())
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		middleware.SetAuditTarget(r.Context(), domain.CollaboratorTarget, c.ID.String())
+		middleware.AddAuditDetail(r.Context(), "email", c.Email)
+		middleware.AddAuditDetail(r.Context(), "role", c.Role)
+		render.JSON.Created(w, c)
+	}
+}
+
+// ListingCollaborators returns a configured http.Handler with collaborator resources to get the repo collaborators.
+func ListingCollaborators(service collaborating.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		collaborators, err := service.ListCollaborators(repo)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, collaborators)
+	}
+}
+
+// GettingCollaborator returns a configured http.Handler with getting collaborator resources.
+func GettingCollaborator() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := middleware.GetCollaborator(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, c)
+	}
+}
+
+// RemovingCollaborator returns a configured http.Handler with removing collaborator resources.
+func RemovingCollaborator(service collaborating.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := middleware.GetCollaborator(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err := service.RemoveCollaborator(c); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, c)
+	}
+}
+
+// ListingInvitations returns a configured http.Handler with collaborator resources to get the user pending invitations.
+func ListingInvitations(service collaborating.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		invitations, err := service.ListInvitations(u)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, invitations)
+	}
+}
+
+// AcceptingInvitation returns a configured http.Handler with collaborator resources to accept an invitation.
+func AcceptingInvitation(service collaborating.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "invitationId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidInvitationID)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		c, err := service.AcceptInvitation(id, u)
+		if err != nil {
+			if isNotFound(err) {
+				render.JSON.NotFound(w, err)
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		middleware.SetAuditTarget(r.Context(), domain.CollaboratorTarget, c.ID.String())
+		middleware.SetAuditRepo(r.Context(), c.RepositoryID)
+		middleware.AddAuditDetail(r.Context(), "role", c.Role)
+		render.JSON.Send(w, c)
+	}
+}
+
+// DecliningInvitation returns a configured http.Handler with collaborator resources to decline an invitation.
+func DecliningInvitation(service collaborating.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "invitationId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidInvitationID)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err := service.DeclineInvitation(id, u); err != nil {
+			if isNotFound(err) {
+				render.JSON.NotFound(w, err)
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
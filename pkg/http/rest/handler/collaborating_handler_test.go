@@ -0,0 +1,241 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/collaborating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+var defaultCollaborator = &domain.Collaborator{ID: kallax.NewULID(), Role: domain.WriteRole, Email: "bob@example.com"}
+
+type mockCollaboratingService struct {
+	collaborator  *domain.Collaborator
+	collaborators []domain.Collaborator
+	err           error
+}
+
+func (m *mockCollaboratingService) Invite(*domain.User, *domain.Repository, collaborating.Payload) (*domain.Collaborator, error) {
+	return m.collaborator, m.err
+}
+func (m *mockCollaboratingService) ListCollaborators(*domain.Repository) ([]domain.Collaborator, error) {
+	return m.collaborators, m.err
+}
+func (m *mockCollaboratingService) GetCollaborator(kallax.ULID, *domain.Repository) (*domain.Collaborator, error) {
+	return m.collaborator, m.err
+}
+func (m *mockCollaboratingService) RemoveCollaborator(*domain.Collaborator) error { return m.err }
+func (m *mockCollaboratingService) ListInvitations(*domain.User) ([]domain.Collaborator, error) {
+	return m.collaborators, m.err
+}
+func (m *mockCollaboratingService) AcceptInvitation(kallax.ULID, *domain.User) (*domain.Collaborator, error) {
+	return m.collaborator, m.err
+}
+func (m *mockCollaboratingService) DeclineInvitation(kallax.ULID, *domain.User) error { return m.err }
+func (m *mockCollaboratingService) GetRole(*domain.Repository, *domain.User) (domain.Role, error) {
+	return "", m.err
+}
+
+type notFoundErr string
+
+func (n notFoundErr) Error() string  { return string(n) }
+func (n notFoundErr) NotFound() bool { return true }
+
+type invalidErr string
+
+func (i invalidErr) Error() string   { return string(i) }
+func (i invalidErr) IsInvalid() bool { return true }
+
+func withCollaboratorMiddle(c *domain.Collaborator) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if c != nil {
+				ctx = context.WithValue(ctx, middleware.CollaboratorCtxKey, c)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func setupCollaboratingHandlers(s collaborating.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Post("/", handler.InvitingCollaborator(s))
+	app.Get("/", handler.ListingCollaborators(s))
+	app.Get("/collaborator", handler.GettingCollaborator())
+	app.Delete("/collaborator", handler.RemovingCollaborator(s))
+	app.Get("/invitations", handler.ListingInvitations(s))
+	app.Post("/invitations/{invitationId}/accept", handler.AcceptingInvitation(s))
+	app.Delete("/invitations/{invitationId}", handler.DecliningInvitation(s))
+	return app
+}
+
+func TestInvitingCollaboratorSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockCollaboratingService{collaborator: defaultCollaborator}
+	app := setupCollaboratingHandlers(s, withUserMiddle(defaultUser), withRepoMiddle(defaultRepo))
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(map[string]interface{}{"email": "bob@example.com", "role": "write"}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("role", "write").
+		ValueEqual("email", "bob@example.com")
+}
+
+func TestInvitingCollaboratorFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name     string
+		service  *mockCollaboratingService
+		payload  map[string]interface{}
+		response map[string]interface{}
+	}{
+		{
+			"invalid payload",
+			&mockCollaboratingService{},
+			map[string]interface{}{"email": "bob@example.com"},
+			map[string]interface{}{"status": 400.0, "error": "Bad Request", "message": "role must not be blank"},
+		},
+		{
+			"invalid invitation",
+			&mockCollaboratingService{err: invalidErr("the owner of the repository can't be invited")},
+			map[string]interface{}{"email": "bob@example.com", "role": "read"},
+			map[string]interface{}{"status": 400.0, "error": "Bad Request", "message": "the owner of the repository can't be invited"},
+		},
+		{
+			"user not found",
+			&mockCollaboratingService{err: notFoundErr("user with email bob@example.com not found")},
+			map[string]interface{}{"email": "bob@example.com", "role": "read"},
+			map[string]interface{}{"status": 404.0, "error": "Not Found", "message": "user with email bob@example.com not found"},
+		},
+		{
+			"already collaborator",
+			&mockCollaboratingService{err: conflictErr("bob@example.com is already a collaborator of the repository")},
+			map[string]interface{}{"email": "bob@example.com", "role": "read"},
+			map[string]interface{}{"status": 409.0, "error": "Conflict", "message": "bob@example.com is already a collaborator of the repository"},
+		},
+		{
+			"service err",
+			&mockCollaboratingService{err: errors.New("test")},
+			map[string]interface{}{"email": "bob@example.com", "role": "read"},
+			map[string]interface{}{"status": 500.0, "error": "Internal Server Error", "message": "looks like something went wrong"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := setupCollaboratingHandlers(tc.service, withUserMiddle(defaultUser), withRepoMiddle(defaultRepo))
+			e := bastion.Tester(t, app)
+			e.POST("/").
+				WithJSON(tc.payload).
+				Expect().
+				Status(int(tc.response["status"].(float64))).
+				JSON().Object().Equal(tc.response)
+		})
+	}
+}
+
+func TestCollaboratingHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockCollaboratingService{collaborator: defaultCollaborator, collaborators: []domain.Collaborator{*defaultCollaborator}}
+	app := setupCollaboratingHandlers(s, withUserMiddle(defaultUser), withRepoMiddle(defaultRepo), withCollaboratorMiddle(defaultCollaborator))
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.GET("/collaborator").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("role", "write")
+	e.DELETE("/collaborator").Expect().Status(http.StatusOK).JSON().Object().ContainsKey("id")
+	e.GET("/invitations").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.POST("/invitations/"+defaultCollaborator.ID.String()+"/accept").
+		Expect().Status(http.StatusOK).JSON().Object().ValueEqual("email", "bob@example.com")
+	e.DELETE("/invitations/" + defaultCollaborator.ID.String()).Expect().Status(http.StatusNoContent)
+}
+
+func TestCollaboratingInvitationsFails(t *testing.T) {
+	t.Parallel()
+
+	id := kallax.NewULID().String()
+	tt := []struct {
+		name    string
+		method  string
+		path    string
+		service *mockCollaboratingService
+		status  int
+		message string
+	}{
+		{"accepting invalid id", "POST", "/invitations/abc/accept", &mockCollaboratingService{}, http.StatusBadRequest, "invalid invitation id"},
+		{"accepting not found", "POST", "/invitations/" + id + "/accept", &mockCollaboratingService{err: notFoundErr("invitation not found")}, http.StatusNotFound, "invitation not found"},
+		{"accepting err", "POST", "/invitations/" + id + "/accept", &mockCollaboratingService{err: errors.New("test")}, http.StatusInternalServerError, "looks like something went wrong"},
+		{"declining invalid id", "DELETE", "/invitations/abc", &mockCollaboratingService{}, http.StatusBadRequest, "invalid invitation id"},
+		{"declining not found", "DELETE", "/invitations/" + id, &mockCollaboratingService{err: notFoundErr("invitation not found")}, http.StatusNotFound, "invitation not found"},
+		{"declining err", "DELETE", "/invitations/" + id, &mockCollaboratingService{err: errors.New("test")}, http.StatusInternalServerError, "looks like something went wrong"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupCollaboratingHandlers(tc.service, withUserMiddle(defaultUser)))
+			e.Request(tc.method, tc.path).
+				Expect().
+				Status(tc.status).
+				JSON().Object().ValueEqual("message", tc.message)
+		})
+	}
+}
+
+func TestCollaboratingHandlersFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name        string
+		method      string
+		path        string
+		service     *mockCollaboratingService
+		middlewares []func(http.Handler) http.Handler
+	}{
+		{"inviting missing user", "POST", "/", &mockCollaboratingService{}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"inviting missing repo", "POST", "/", &mockCollaboratingService{}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}},
+		{"listing missing repo", "GET", "/", &mockCollaboratingService{}, nil},
+		{"listing err", "GET", "/", &mockCollaboratingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"getting missing collaborator", "GET", "/collaborator", &mockCollaboratingService{}, nil},
+		{"removing missing collaborator", "DELETE", "/collaborator", &mockCollaboratingService{}, nil},
+		{"removing err", "DELETE", "/collaborator", &mockCollaboratingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withCollaboratorMiddle(defaultCollaborator)}},
+		{"invitations missing user", "GET", "/invitations", &mockCollaboratingService{}, nil},
+		{"invitations err", "GET", "/invitations", &mockCollaboratingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}},
+		{"accepting missing user", "POST", "/invitations/" + defaultCollaborator.ID.String() + "/accept", &mockCollaboratingService{}, nil},
+		{"declining missing user", "DELETE", "/invitations/" + defaultCollaborator.ID.String(), &mockCollaboratingService{}, nil},
+	}
+
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupCollaboratingHandlers(tc.service, tc.middlewares...))
+			e.Request(tc.method, tc.path).
+				WithJSON(map[string]interface{}{"email": "bob@example.com", "role": "read"}).
+				Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
+}
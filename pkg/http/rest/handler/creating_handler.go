@@ -9,6 +9,7 @@ import (
 	"github.com/ifreddyrondon/bastion/render"
 
 	"github.com/ifreddyrondon/capture/pkg/creating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
 	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
 )
 
@@ -35,6 +36,44 @@ func Creating(service creating.Service) http.HandlerFunc {
 			return
 		}
 
+		middleware.SetAuditTarget(r.Context(), domain.RepositoryTarget, repo.ID)
+		middleware.AddAuditDetail(r.Context(), "visibility", repo.Visibility)
+		render.JSON.Created(w, repo)
+	}
+}
+
+// CreatingOrganizationRepo returns a configured http.Handler with creating resources for organization repos.
+func CreatingOrganizationRepo(service creating.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload creating.Payload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		repo, err := service.CreateOrganizationRepo(u, org, payload)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		middleware.SetAuditTarget(r.Context(), domain.RepositoryTarget, repo.ID)
+		middleware.AddAuditDetail(r.Context(), "visibility", repo.Visibility)
 		render.JSON.Created(w, repo)
 	}
 }
@@ -22,6 +22,9 @@ type mockCreatingService struct {
 func (m *mockCreatingService) CreateRepo(*domain.User, creating.Payload) (*creating.Repository, error) {
 	return m.repo, m.err
 }
+func (m *mockCreatingService) CreateOrganizationRepo(*domain.User, *domain.Organization, creating.Payload) (*creating.Repository, error) {
+	return m.repo, m.err
+}
 
 func setupCreateHandler(s creating.Service, m func(http.Handler) http.Handler) *bastion.Bastion {
 	app := bastion.New()
@@ -139,3 +142,31 @@ func TestCreateRepoFailInternalErrorCreatingRepo(t *testing.T) {
 		Status(http.StatusInternalServerError).
 		JSON().Object().Equal(response)
 }
+
+func TestCreateOrganizationRepo(t *testing.T) {
+	t.Parallel()
+
+	repo := &creating.Repository{ID: "01679604-d8f6-29ce-2fe2-5d66dfa2d194", Name: "test", Organization: defaultOrganization.ID.String()}
+	tt := []struct {
+		name        string
+		service     *mockCreatingService
+		middlewares []func(http.Handler) http.Handler
+		payload     map[string]interface{}
+		status      int
+	}{
+		{"success", &mockCreatingService{repo: repo}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser), withOrganizationMiddle(defaultOrganization)}, map[string]interface{}{"name": "test"}, http.StatusCreated},
+		{"bad request", &mockCreatingService{}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser), withOrganizationMiddle(defaultOrganization)}, map[string]interface{}{}, http.StatusBadRequest},
+		{"missing user", &mockCreatingService{}, []func(http.Handler) http.Handler{withOrganizationMiddle(defaultOrganization)}, map[string]interface{}{"name": "test"}, http.StatusInternalServerError},
+		{"missing organization", &mockCreatingService{}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, map[string]interface{}{"name": "test"}, http.StatusInternalServerError},
+		{"service err", &mockCreatingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser), withOrganizationMiddle(defaultOrganization)}, map[string]interface{}{"name": "test"}, http.StatusInternalServerError},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := bastion.New()
+			app.With(tc.middlewares...).Post("/", handler.CreatingOrganizationRepo(tc.service))
+			e := bastion.Tester(t, app)
+			e.POST("/").WithJSON(tc.payload).Expect().Status(tc.status)
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ifreddyrondon/bastion/render"
+
+	"github.com/ifreddyrondon/capture/pkg/exporting"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+// ExportingCaptures returns a configured http.Handler with exporting captures resources.
+func ExportingCaptures(service exporting.Service, f exporting.Format) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := service.ExportCaptures(&buf, repo, f); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", f.ContentType())
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%v.%v\"", repo.ID, f))
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf.Bytes())
+	}
+}
@@ -0,0 +1,76 @@
+package handler_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/exporting"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+)
+
+type mockExportingService struct {
+	doc string
+	err error
+}
+
+func (m *mockExportingService) ExportCaptures(w io.Writer, r *domain.Repository, f exporting.Format) error {
+	if m.err != nil {
+		return m.err
+	}
+	_, err := io.WriteString(w, m.doc)
+	return err
+}
+
+func setupExportingHandler(s exporting.Service, m func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Use(m)
+	app.Get("/", handler.ExportingCaptures(s, exporting.KML))
+	return app
+}
+
+func TestExportingCapturesSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockExportingService{doc: "<kml></kml>"}
+	app := setupExportingHandler(s, withRepoMiddle(defaultRepo))
+
+	e := bastion.Tester(t, app)
+	res := e.GET("/").Expect().Status(http.StatusOK)
+	res.Header("Content-Type").Equal("application/vnd.google-earth.kml+xml")
+	res.Header("Content-Disposition").Contains(".kml")
+	res.Body().Equal("<kml></kml>")
+}
+
+func TestExportingCapturesFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		service *mockExportingService
+		middle  func(http.Handler) http.Handler
+	}{
+		{"missing repo", &mockExportingService{}, withRepoMiddle(nil)},
+		{"service err", &mockExportingService{err: errors.New("test")}, withRepoMiddle(defaultRepo)},
+	}
+
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupExportingHandler(tc.service, tc.middle))
+			e.GET("/").
+				Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
+}
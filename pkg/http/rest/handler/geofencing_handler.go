@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	bastionMiddleware "github.com/ifreddyrondon/bastion/middleware"
+	"github.com/ifreddyrondon/bastion/render"
+
+	"github.com/ifreddyrondon/capture/pkg/geofencing"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+// CreatingGeofence returns a configured http.Handler with creating geofence resources.
+func CreatingGeofence(service geofencing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload geofencing.Payload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		g, err := service.CreateGeofence(repo, payload)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Created(w, g)
+	}
+}
+
+// ListingGeofences returns a configured http.Handler with geofence resources to get the repo geofences.
+func ListingGeofences(service geofencing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		geofences, err := service.ListGeofences(repo)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, geofences)
+	}
+}
+
+// GettingGeofence returns a configured http.Handler with getting geofence resources.
+func GettingGeofence() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := middleware.GetGeofence(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, g)
+	}
+}
+
+// RemovingGeofence returns a configured http.Handler with removing geofence resources.
+func RemovingGeofence(service geofencing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		g, err := middleware.GetGeofence(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err := service.RemoveGeofence(g); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, g)
+	}
+}
+
+// ListingGeofenceEvents returns a configured http.Handler with geofence resources to get its enter and exit events.
+func ListingGeofenceEvents(service geofencing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, err := bastionMiddleware.GetListing(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		g, err := middleware.GetGeofence(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		res, err := service.ListEvents(g, l)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, res)
+	}
+}
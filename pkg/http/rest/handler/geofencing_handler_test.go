@@ -0,0 +1,169 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	bastionMiddleware "github.com/ifreddyrondon/bastion/middleware"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/geofencing"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+var defaultGeofence = &domain.Geofence{ID: kallax.NewULID(), Name: "restricted"}
+
+type mockGeofencingService struct {
+	geofence  *domain.Geofence
+	geofences []domain.Geofence
+	err       error
+}
+
+func (m *mockGeofencingService) CreateGeofence(*domain.Repository, geofencing.Payload) (*domain.Geofence, error) {
+	return m.geofence, m.err
+}
+func (m *mockGeofencingService) ListGeofences(*domain.Repository) ([]domain.Geofence, error) {
+	return m.geofences, m.err
+}
+func (m *mockGeofencingService) GetGeofence(kallax.ULID, *domain.Repository) (*domain.Geofence, error) {
+	return m.geofence, m.err
+}
+func (m *mockGeofencingService) RemoveGeofence(*domain.Geofence) error { return m.err }
+func (m *mockGeofencingService) ListEvents(g *domain.Geofence, l *listing.Listing) (*geofencing.ListEventResponse, error) {
+	return &geofencing.ListEventResponse{Results: []domain.GeofenceEvent{}, Listing: l}, m.err
+}
+
+func withGeofenceMiddle(g *domain.Geofence) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if g != nil {
+				ctx = context.WithValue(ctx, middleware.GeofenceCtxKey, g)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func setupGeofencingHandlers(s geofencing.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Post("/", handler.CreatingGeofence(s))
+	app.Get("/", handler.ListingGeofences(s))
+	app.Get("/geofence", handler.GettingGeofence())
+	app.Delete("/geofence", handler.RemovingGeofence(s))
+	app.With(bastionMiddleware.Listing()).Get("/geofence/events", handler.ListingGeofenceEvents(s))
+	return app
+}
+
+func TestCreatingGeofenceSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockGeofencingService{geofence: defaultGeofence}
+	app := setupGeofencingHandlers(s, withRepoMiddle(defaultRepo))
+
+	payload := map[string]interface{}{
+		"name": "restricted",
+		"polygon": []map[string]float64{
+			{"lat": 0, "lng": 0},
+			{"lat": 0, "lng": 10},
+			{"lat": 10, "lng": 10},
+		},
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(payload).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("name", "restricted")
+}
+
+func TestCreatingGeofenceFailBadRequest(t *testing.T) {
+	t.Parallel()
+
+	app := setupGeofencingHandlers(&mockGeofencingService{}, withRepoMiddle(defaultRepo))
+
+	response := map[string]interface{}{
+		"status":  400.0,
+		"error":   "Bad Request",
+		"message": "polygon must have at least three vertices",
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(map[string]interface{}{"name": "restricted"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().Equal(response)
+}
+
+func TestGeofencingHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockGeofencingService{geofences: []domain.Geofence{*defaultGeofence}}
+	app := setupGeofencingHandlers(s, withRepoMiddle(defaultRepo), withGeofenceMiddle(defaultGeofence))
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.GET("/geofence").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("name", "restricted")
+	e.DELETE("/geofence").Expect().Status(http.StatusOK).JSON().Object().ContainsKey("id")
+	e.GET("/geofence/events").Expect().Status(http.StatusOK).JSON().Object().ContainsKey("results").ContainsKey("listing")
+}
+
+func TestGeofencingHandlersFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name        string
+		method      string
+		path        string
+		service     *mockGeofencingService
+		middlewares []func(http.Handler) http.Handler
+	}{
+		{"creating missing repo", "POST", "/", &mockGeofencingService{}, nil},
+		{"listing missing repo", "GET", "/", &mockGeofencingService{}, nil},
+		{"listing err", "GET", "/", &mockGeofencingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"getting missing geofence", "GET", "/geofence", &mockGeofencingService{}, nil},
+		{"removing missing geofence", "DELETE", "/geofence", &mockGeofencingService{}, nil},
+		{"removing err", "DELETE", "/geofence", &mockGeofencingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withGeofenceMiddle(defaultGeofence)}},
+		{"events missing geofence", "GET", "/geofence/events", &mockGeofencingService{}, nil},
+		{"events err", "GET", "/geofence/events", &mockGeofencingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withGeofenceMiddle(defaultGeofence)}},
+	}
+
+	payload := map[string]interface{}{
+		"name": "restricted",
+		"polygon": []map[string]float64{
+			{"lat": 0, "lng": 0},
+			{"lat": 0, "lng": 10},
+			{"lat": 10, "lng": 10},
+		},
+	}
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupGeofencingHandlers(tc.service, tc.middlewares...))
+			e.Request(tc.method, tc.path).
+				WithJSON(payload).
+				Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
+}
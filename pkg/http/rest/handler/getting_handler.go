@@ -7,11 +7,24 @@ import (
 
 	"github.com/ifreddyrondon/bastion/render"
 
+	"github.com/ifreddyrondon/capture/pkg/domain"
 	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/quotas"
 )
 
+type repoJSON struct {
+	*domain.Repository
+	Usage *quotas.Usage `json:"usage"`
+}
+
+type userJSON struct {
+	*domain.User
+	Usage *quotas.Usage `json:"usage"`
+}
+
 // GettingRepo returns a configured http.Handler with getting repo resources.
-func GettingRepo() http.HandlerFunc {
+// The repository is sent with its current usage.
+func GettingRepo(service quotas.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		repo, err := middleware.GetRepo(r.Context())
 		if err != nil {
@@ -20,7 +33,14 @@ func GettingRepo() http.HandlerFunc {
 			return
 		}
 
-		render.JSON.Send(w, repo)
+		usage, err := service.RepoUsage(repo)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, repoJSON{Repository: repo, Usage: usage})
 	}
 }
 
@@ -37,3 +57,25 @@ func GettingCapture() http.HandlerFunc {
 		render.JSON.Send(w, capt)
 	}
 }
+
+// GettingUser returns a configured http.Handler with the profile of the authenticated user
+// and its current usage.
+func GettingUser(service quotas.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		usage, err := service.UserUsage(u)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, userJSON{User: u, Usage: usage})
+	}
+}
@@ -5,34 +5,68 @@ import (
 	"testing"
 
 	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
 
+	"github.com/ifreddyrondon/capture/pkg/domain"
 	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/quotas"
 )
 
-func setupGettingRepoHandler(m func(http.Handler) http.Handler) *bastion.Bastion {
+type mockQuotasService struct {
+	usage *quotas.Usage
+	err   error
+}
+
+func (m *mockQuotasService) Reserve(*domain.Repository, ...domain.Capture) error { return m.err }
+func (m *mockQuotasService) Release(...domain.Capture) error                     { return m.err }
+func (m *mockQuotasService) Resize(*domain.Repository, domain.Capture, domain.Capture) error {
+	return m.err
+}
+func (m *mockQuotasService) RepoUsage(*domain.Repository) (*quotas.Usage, error) {
+	return m.usage, m.err
+}
+func (m *mockQuotasService) UserUsage(*domain.User) (*quotas.Usage, error) {
+	return m.usage, m.err
+}
+
+var defaultUsage = &quotas.Usage{
+	Usage: domain.Usage{Captures: 2, Bytes: 120},
+	Quota: domain.Quota{Captures: 10},
+}
+
+func setupGettingRepoHandler(s quotas.Service, m func(http.Handler) http.Handler) *bastion.Bastion {
 	app := bastion.New()
 	app.Use(m)
-	app.Get("/{id}", handler.GettingRepo())
+	app.Get("/{id}", handler.GettingRepo(s))
 	return app
 }
 
 func TestGettingRepoSuccess(t *testing.T) {
 	t.Parallel()
 
-	app := setupGettingRepoHandler(withRepoMiddle(defaultRepo))
+	app := setupGettingRepoHandler(&mockQuotasService{usage: defaultUsage}, withRepoMiddle(defaultRepo))
 
+	usage := map[string]interface{}{"captures": 2.0, "bytes": 120.0, "quota": map[string]interface{}{"captures": 10.0}}
 	e := bastion.Tester(t, app)
 	e.GET("/0167c8a5-d308-8692-809d-b1ad4a2d9562").
 		Expect().
 		JSON().Object().
 		ContainsKey("name").ValueEqual("name", "test public").
-		ContainsKey("visibility").ValueEqual("visibility", "public")
+		ContainsKey("visibility").ValueEqual("visibility", "public").
+		ValueEqual("usage", usage)
 }
 
 func TestGettingRepoInternalServer(t *testing.T) {
 	t.Parallel()
 
-	app := setupGettingRepoHandler(withRepoMiddle(nil))
+	tt := []struct {
+		name    string
+		service *mockQuotasService
+		middle  func(http.Handler) http.Handler
+	}{
+		{"missing repo", &mockQuotasService{usage: defaultUsage}, withRepoMiddle(nil)},
+		{"usage err", &mockQuotasService{err: errors.New("test")}, withRepoMiddle(defaultRepo)},
+	}
 
 	response := map[string]interface{}{
 		"status":  500.0,
@@ -40,10 +74,14 @@ func TestGettingRepoInternalServer(t *testing.T) {
 		"message": "looks like something went wrong",
 	}
 
-	e := bastion.Tester(t, app)
-	e.GET("/0167c8a5-d308-8692-809d-b1ad4a2d9562").Expect().
-		Status(http.StatusInternalServerError).
-		JSON().Object().Equal(response)
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupGettingRepoHandler(tc.service, tc.middle))
+			e.GET("/0167c8a5-d308-8692-809d-b1ad4a2d9562").Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
 }
 
 func setupGettingCaptureHandler(m func(http.Handler) http.Handler) *bastion.Bastion {
@@ -81,3 +119,24 @@ func TestGettingCaptureInternalServer(t *testing.T) {
 		Status(http.StatusInternalServerError).
 		JSON().Object().Equal(response)
 }
+
+func TestGettingUser(t *testing.T) {
+	t.Parallel()
+
+	app := bastion.New()
+	app.With(withUserMiddle(defaultUser)).Get("/user", handler.GettingUser(&mockQuotasService{usage: defaultUsage}))
+	app.With(withUserMiddle(defaultUser)).Get("/failing", handler.GettingUser(&mockQuotasService{err: errors.New("test")}))
+	app.Get("/missing", handler.GettingUser(&mockQuotasService{usage: defaultUsage}))
+
+	e := bastion.Tester(t, app)
+	e.GET("/user").
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("email", "test@example.com").
+		ContainsKey("id").
+		NotContainsKey("password").
+		Value("usage").Object().ValueEqual("captures", 2).ValueEqual("bytes", 120)
+	e.GET("/failing").Expect().Status(http.StatusInternalServerError)
+	e.GET("/missing").Expect().Status(http.StatusInternalServerError)
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/importing"
+)
+
+type invalidErr interface {
+	IsInvalid() bool
+}
+
+func isInvalidErr(err error) bool {
+	if e, ok := errors.Cause(err).(invalidErr); ok {
+		return e.IsInvalid()
+	}
+	return false
+}
+
+// limitedBody is a request body limited by http.MaxBytesReader that remembers when the
+// limit was exceeded, the parsers replace the read errors with their own.
+type limitedBody struct {
+	io.ReadCloser
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if _, ok := err.(*http.MaxBytesError); ok {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+func documentTooLarge(w http.ResponseWriter, maxBytes int64) {
+	httpErr := render.HTTPError{
+		Status:  http.StatusRequestEntityTooLarge,
+		Error:   http.StatusText(http.StatusRequestEntityTooLarge),
+		Message: fmt.Sprintf("document too large, it allows up to %d bytes", maxBytes),
+	}
+	render.JSON.Response(w, http.StatusRequestEntityTooLarge, httpErr)
+}
+
+// ImportingCaptures returns a configured http.Handler with importing captures resources.
+// The request body is a document in the given format of at most maxBytes, 0 is unlimited.
+func ImportingCaptures(service importing.Service, f importing.Format, maxBytes int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		body := &limitedBody{ReadCloser: r.Body}
+		if maxBytes > 0 {
+			body.ReadCloser = http.MaxBytesReader(w, r.Body, maxBytes)
+		}
+		ignoreErrors, _ := strconv.ParseBool(r.URL.Query().Get("ignore_errors"))
+		doc := importing.Document{Format: f, Data: body, IgnoreErrors: ignoreErrors}
+		captures, err := service.ImportCaptures(repo, doc)
+		if err != nil {
+			if body.exceeded {
+				documentTooLarge(w, maxBytes)
+				return
+			}
+			if isInvalidErr(err) {
+				render.JSON.BadRequest(w, errors.Cause(err))
+				return
+			}
+			if isQuotaExceeded(err) {
+				quotaExceeded(w, errors.Cause(err).Error())
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		middleware.AddAuditDetail(r.Context(), "count", len(captures))
+		middleware.AddAuditDetail(r.Context(), "format", f)
+		render.JSON.Created(w, captures)
+	}
+}
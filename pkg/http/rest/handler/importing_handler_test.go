@@ -0,0 +1,146 @@
+package handler_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/importing"
+)
+
+type invalidDocMock string
+
+func (i invalidDocMock) Error() string   { return string(i) }
+func (i invalidDocMock) IsInvalid() bool { return true }
+
+type mockImportingService struct {
+	captures []domain.Capture
+	doc      importing.Document
+	err      error
+}
+
+// ImportCaptures reads the document like the parsers, which fail with an invalid document
+// when it can't be read.
+func (m *mockImportingService) ImportCaptures(r *domain.Repository, doc importing.Document) ([]domain.Capture, error) {
+	m.doc = doc
+	if _, err := ioutil.ReadAll(doc.Data); err != nil {
+		return nil, errors.WithStack(invalidDocMock("cannot unmarshal gpx document"))
+	}
+	return m.captures, m.err
+}
+
+func setupImportingHandler(s importing.Service, m func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Use(m)
+	app.Post("/", handler.ImportingCaptures(s, importing.GPX, 64))
+	return app
+}
+
+func TestImportingCapturesSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockImportingService{captures: []domain.Capture{*defaultCapture}}
+	app := setupImportingHandler(s, withRepoMiddle(defaultRepo))
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithQuery("ignore_errors", "true").
+		WithBytes([]byte("<gpx></gpx>")).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Array().Length().Equal(1)
+
+	assert.Equal(t, importing.GPX, s.doc.Format)
+	assert.True(t, s.doc.IgnoreErrors)
+}
+
+func TestImportingCapturesFailBadRequest(t *testing.T) {
+	t.Parallel()
+
+	s := &mockImportingService{err: errors.Wrap(invalidDocMock("cannot unmarshal gpx document"), "could not parse document")}
+	app := setupImportingHandler(s, withRepoMiddle(defaultRepo))
+
+	response := map[string]interface{}{
+		"status":  400.0,
+		"error":   "Bad Request",
+		"message": "cannot unmarshal gpx document",
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithBytes([]byte("{}")).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().Equal(response)
+}
+
+func TestImportingCapturesFailTooLarge(t *testing.T) {
+	t.Parallel()
+
+	s := &mockImportingService{captures: []domain.Capture{*defaultCapture}}
+	app := setupImportingHandler(s, withRepoMiddle(defaultRepo))
+
+	response := map[string]interface{}{
+		"status":  413.0,
+		"error":   "Request Entity Too Large",
+		"message": "document too large, it allows up to 64 bytes",
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithBytes([]byte("<gpx>" + strings.Repeat("<trk></trk>", 10) + "</gpx>")).
+		Expect().
+		Status(http.StatusRequestEntityTooLarge).
+		JSON().Object().Equal(response)
+}
+
+func TestImportingCapturesQuotaExceeded(t *testing.T) {
+	t.Parallel()
+
+	s := &mockImportingService{err: errors.Wrap(quotaErr("repository quota exceeded, it allows up to 10 captures"), "could not add captures")}
+	app := setupImportingHandler(s, withRepoMiddle(defaultRepo))
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithBytes([]byte("<gpx></gpx>")).
+		Expect().
+		Status(http.StatusForbidden).
+		JSON().Object().ValueEqual("message", "repository quota exceeded, it allows up to 10 captures")
+}
+
+func TestImportingCapturesFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		service *mockImportingService
+		middle  func(http.Handler) http.Handler
+	}{
+		{"missing repo", &mockImportingService{}, withRepoMiddle(nil)},
+		{"service err", &mockImportingService{err: errors.New("test")}, withRepoMiddle(defaultRepo)},
+	}
+
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupImportingHandler(tc.service, tc.middle))
+			e.POST("/").
+				WithBytes([]byte("<gpx></gpx>")).
+				Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gobuffalo/validate"
+	"github.com/gorilla/websocket"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/adding"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+var (
+	// IngestPingInterval is the time between the pings sent to keep idle ingesting connections open.
+	IngestPingInterval = 30 * time.Second
+	// IngestIdleTimeout is how long an ingesting connection could go without messages nor pongs
+	// before it's closed.
+	IngestIdleTimeout = 60 * time.Second
+	// IngestAuthInterval is the time between the checks of the authorization of the ingesting
+	// connections. A connection is closed once its request is no longer authorized.
+	IngestAuthInterval = time.Minute
+)
+
+const (
+	ingestWriteWait      = 10 * time.Second
+	ingestMaxMessageSize = 1 << 20
+)
+
+var ingestUpgrader = websocket.Upgrader{
+	Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+		if status >= http.StatusInternalServerError {
+			fmt.Fprintln(os.Stderr, reason)
+			render.JSON.InternalServerError(w, reason)
+			return
+		}
+		httpErr := render.HTTPError{
+			Status:  status,
+			Error:   http.StatusText(status),
+			Message: reason.Error(),
+		}
+		render.JSON.Response(w, status, httpErr)
+	},
+}
+
+// ingestAck is the reply to every message received by IngestingCaptures.
+type ingestAck struct {
+	Seq    int64               `json:"seq"`
+	ID     *kallax.ULID        `json:"id,omitempty"`
+	Errors map[string][]string `json:"errors,omitempty"`
+}
+
+// IngestingCaptures returns a configured http.Handler with a WebSocket resource to add captures.
+// Every text message is a capture and is replied with an ack carrying its sequence number and
+// the id of the created capture or the validation errors. The messages are handled one at a
+// time, so when the storage falls behind the device is slowed down by the connection itself.
+// The request is authorized again with the authorization middleware every IngestAuthInterval,
+// closing the connection once its credentials were revoked or expired. Every capture is added
+// through the message middleware with the request of the connection, so the rate limits and
+// the audit trail apply per message and a limited message is replied with the error in its ack.
+func IngestingCaptures(service adding.CaptureService, authorization, message func(http.Handler) http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		// the upgrader replies the errors of the handshake.
+		conn, err := ingestUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetReadLimit(ingestMaxMessageSize)
+		conn.SetReadDeadline(time.Now().Add(IngestIdleTimeout))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(IngestIdleTimeout))
+		})
+
+		done := make(chan struct{})
+		defer close(done)
+		go keepIngesting(conn, r, authorization, done)
+
+		var seq int64
+		for {
+			typ, msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.SetReadDeadline(time.Now().Add(IngestIdleTimeout))
+			seq++
+			ack := ingestAck{Seq: seq}
+			if typ != websocket.TextMessage {
+				ack.Errors = map[string][]string{"message": {"messages must be JSON text"}}
+				if err := writeAck(conn, ack); err != nil {
+					return
+				}
+				continue
+			}
+
+			var payload adding.Capture
+			if err := decodeCapture(msg, &payload); err != nil {
+				ack.Errors = validationErrors(err)
+				if err := writeAck(conn, ack); err != nil {
+					return
+				}
+				continue
+			}
+
+			add := func() (*domain.Capture, error) { return service.AddCapture(repo, payload) }
+			capt, retryAfter, err := ingestCapture(message, r, add)
+			if retryAfter != "" {
+				msg := fmt.Sprintf("rate limit exceeded, retry in %s seconds", retryAfter)
+				ack.Errors = map[string][]string{"rate": {msg}}
+				if err := writeAck(conn, ack); err != nil {
+					return
+				}
+				continue
+			}
+			if err != nil {
+				if isQuotaExceeded(err) {
+					ack.Errors = map[string][]string{"quota": {errors.Cause(err).Error()}}
+					if err := writeAck(conn, ack); err != nil {
+						return
+					}
+					continue
+				}
+				fmt.Fprintln(os.Stderr, err)
+				closeIngesting(conn, websocket.CloseInternalServerErr, "could not add capture")
+				return
+			}
+			ack.ID = &capt.ID
+			if err := writeAck(conn, ack); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// ingestCapture adds the capture of a message running the message middleware for the request
+// of the connection. When the middleware rejects the message as rate limited it returns the
+// seconds to wait before retrying.
+func ingestCapture(message func(http.Handler) http.Handler, r *http.Request, add func() (*domain.Capture, error)) (capt *domain.Capture, retryAfter string, err error) {
+	var added bool
+	h := message(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		added = true
+		capt, err = add()
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		middleware.SetAuditTarget(r.Context(), domain.CaptureTarget, capt.ID.String())
+		w.WriteHeader(http.StatusCreated)
+	}))
+	dw := &discardWriter{header: make(http.Header)}
+	h.ServeHTTP(dw, r)
+	if !added && dw.status == http.StatusTooManyRequests {
+		return nil, dw.header.Get("Retry-After"), nil
+	}
+	if !added {
+		return nil, "", errors.Errorf("capture rejected by the message middleware with status %v", dw.status)
+	}
+	return capt, "", err
+}
+
+// keepIngesting pings the connection to keep it open and closes it once its request is no
+// longer authorized, until done.
+func keepIngesting(conn *websocket.Conn, r *http.Request, authorization func(http.Handler) http.Handler, done <-chan struct{}) {
+	ping := time.NewTicker(IngestPingInterval)
+	defer ping.Stop()
+	auth := time.NewTicker(IngestAuthInterval)
+	defer auth.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ingestWriteWait)); err != nil {
+				return
+			}
+		case <-auth.C:
+			if !stillAuthorized(authorization, r) {
+				closeIngesting(conn, websocket.ClosePolicyViolation, "authorization revoked")
+				return
+			}
+		}
+	}
+}
+
+// stillAuthorized runs the authorization middleware again for the request, discarding its responses.
+func stillAuthorized(authorization func(http.Handler) http.Handler, r *http.Request) bool {
+	var authorized bool
+	h := authorization(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { authorized = true }))
+	h.ServeHTTP(&discardWriter{header: make(http.Header)}, r)
+	return authorized
+}
+
+// discardWriter drops the responses written for the request of a connection, keeping its status.
+type discardWriter struct {
+	header http.Header
+	status int
+}
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteHeader(status int)      { w.status = status }
+
+// closeIngesting sends a close message with the reason and closes the connection.
+func closeIngesting(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(ingestWriteWait)); err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrap(err, "could not send websocket close message"))
+	}
+	conn.Close()
+}
+
+func decodeCapture(msg []byte, c *adding.Capture) error {
+	if err := json.Unmarshal(msg, c); err != nil {
+		return err
+	}
+	return c.Validate()
+}
+
+func validationErrors(err error) map[string][]string {
+	if e, ok := err.(*validate.Errors); ok {
+		return e.Errors
+	}
+	return map[string][]string{"message": {err.Error()}}
+}
+
+func writeAck(conn *websocket.Conn, ack ingestAck) error {
+	data, err := json.Marshal(ack)
+	if err != nil {
+		return err
+	}
+	conn.SetWriteDeadline(time.Now().Add(ingestWriteWait))
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
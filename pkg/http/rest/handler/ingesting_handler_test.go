@@ -0,0 +1,283 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/ifreddyrondon/bastion"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+func init() {
+	// shorten the keepalive of the ingesting connections to test it.
+	handler.IngestPingInterval = 50 * time.Millisecond
+	handler.IngestIdleTimeout = 500 * time.Millisecond
+	handler.IngestAuthInterval = 50 * time.Millisecond
+}
+
+// revocable authorizes the requests until it's revoked.
+type revocable struct{ revoked int32 }
+
+func (a *revocable) revoke() { atomic.StoreInt32(&a.revoked, 1) }
+
+func (a *revocable) middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&a.revoked) == 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+// limited allows the given number of messages and then rejects them as rate limited.
+type limited struct{ remaining int32 }
+
+func (l *limited) middleware(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&l.remaining, -1) < 0 {
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	}
+	return http.HandlerFunc(fn)
+}
+
+func setupIngestingHandler(s *mockAddingCaptureService, auth *revocable, limit *limited, m func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Use(m)
+	app.Get("/", handler.IngestingCaptures(s, auth.middleware, limit.middleware))
+	return app
+}
+
+func dialIngesting(t *testing.T, s *mockAddingCaptureService, auth *revocable) (*websocket.Conn, func()) {
+	return dialLimitedIngesting(t, s, auth, &limited{remaining: 1000})
+}
+
+func dialLimitedIngesting(t *testing.T, s *mockAddingCaptureService, auth *revocable, limit *limited) (*websocket.Conn, func()) {
+	server := httptest.NewServer(setupIngestingHandler(s, auth, limit, withRepoMiddle(defaultRepo)))
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		server.Close()
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		server.Close()
+	}
+}
+
+func readAck(t *testing.T, conn *websocket.Conn) map[string]interface{} {
+	typ, msg, err := conn.ReadMessage()
+	assert.Nil(t, err)
+	assert.Equal(t, websocket.TextMessage, typ)
+	var ack map[string]interface{}
+	assert.Nil(t, json.Unmarshal(msg, &ack))
+	return ack
+}
+
+func TestIngestingCapturesAck(t *testing.T) {
+	t.Parallel()
+
+	capt := &domain.Capture{ID: kallax.NewULID()}
+	conn, teardown := dialIngesting(t, &mockAddingCaptureService{capt: capt}, &revocable{})
+	defer teardown()
+
+	valid := `{"payload":[{"name":"power","value":10}]}`
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(valid)))
+	assert.Equal(t, map[string]interface{}{"seq": 1.0, "id": capt.ID.String()}, readAck(t, conn))
+
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"payload":[]}`)))
+	ack := readAck(t, conn)
+	assert.Equal(t, 2.0, ack["seq"])
+	assert.Nil(t, ack["id"])
+	assert.Contains(t, ack["errors"], "payload")
+
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(`{`)))
+	ack = readAck(t, conn)
+	assert.Equal(t, 3.0, ack["seq"])
+	assert.Contains(t, ack["errors"], "message")
+
+	assert.Nil(t, conn.WriteMessage(websocket.BinaryMessage, []byte(valid)))
+	expected := map[string]interface{}{
+		"seq":    4.0,
+		"errors": map[string]interface{}{"message": []interface{}{"messages must be JSON text"}},
+	}
+	assert.Equal(t, expected, readAck(t, conn))
+
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(valid)))
+	assert.Equal(t, 5.0, readAck(t, conn)["seq"])
+}
+
+func TestIngestingCapturesCloseWhenServiceFails(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := dialIngesting(t, &mockAddingCaptureService{err: errors.New("test")}, &revocable{})
+	defer teardown()
+
+	valid := `{"payload":[{"name":"power","value":10}]}`
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(valid)))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseInternalServerErr))
+}
+
+func TestIngestingCapturesAckQuotaExceeded(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAddingCaptureService{err: quotaErr("repository quota exceeded, it allows up to 10 captures")}
+	conn, teardown := dialIngesting(t, s, &revocable{})
+	defer teardown()
+
+	valid := `{"payload":[{"name":"power","value":10}]}`
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(valid)))
+	expected := map[string]interface{}{
+		"seq":    1.0,
+		"errors": map[string]interface{}{"quota": []interface{}{"repository quota exceeded, it allows up to 10 captures"}},
+	}
+	assert.Equal(t, expected, readAck(t, conn))
+}
+
+func TestIngestingCapturesAckRateLimited(t *testing.T) {
+	t.Parallel()
+
+	capt := &domain.Capture{ID: kallax.NewULID()}
+	conn, teardown := dialLimitedIngesting(t, &mockAddingCaptureService{capt: capt}, &revocable{}, &limited{remaining: 1})
+	defer teardown()
+
+	valid := `{"payload":[{"name":"power","value":10}]}`
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(valid)))
+	assert.Equal(t, map[string]interface{}{"seq": 1.0, "id": capt.ID.String()}, readAck(t, conn))
+
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(valid)))
+	expected := map[string]interface{}{
+		"seq":    2.0,
+		"errors": map[string]interface{}{"rate": []interface{}{"rate limit exceeded, retry in 3 seconds"}},
+	}
+	assert.Equal(t, expected, readAck(t, conn))
+}
+
+func TestIngestingCapturesAuditEveryCapture(t *testing.T) {
+	t.Parallel()
+
+	capt := &domain.Capture{ID: kallax.NewULID()}
+	auditing := &mockAuditingService{}
+	audited := make(chan struct{}, 2)
+	message := func(next http.Handler) http.Handler {
+		audit := middleware.Audit(auditing, domain.CaptureAdded)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			audit.ServeHTTP(w, r)
+			audited <- struct{}{}
+		})
+	}
+	app := bastion.New()
+	app.Use(withRepoMiddle(defaultRepo))
+	app.Get("/", handler.IngestingCaptures(&mockAddingCaptureService{capt: capt}, (&revocable{}).middleware, message))
+	server := httptest.NewServer(app)
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	assert.Nil(t, err)
+	defer conn.Close()
+
+	valid := `{"payload":[{"name":"power","value":10}]}`
+	for seq := 1.0; seq <= 2; seq++ {
+		assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(valid)))
+		assert.Equal(t, seq, readAck(t, conn)["seq"])
+		<-audited
+	}
+	assert.Len(t, auditing.recorded, 2)
+	for _, e := range auditing.recorded {
+		assert.Equal(t, domain.CaptureAdded, e.Action)
+		assert.Equal(t, domain.CaptureTarget, e.TargetType)
+		assert.Equal(t, capt.ID.String(), e.TargetID)
+		assert.Equal(t, defaultRepo.ID, *e.RepositoryID)
+	}
+}
+
+func TestIngestingCapturesCloseWhenAuthorizationRevoked(t *testing.T) {
+	t.Parallel()
+
+	capt := &domain.Capture{ID: kallax.NewULID()}
+	auth := &revocable{}
+	conn, teardown := dialIngesting(t, &mockAddingCaptureService{capt: capt}, auth)
+	defer teardown()
+
+	valid := `{"payload":[{"name":"power","value":10}]}`
+	assert.Nil(t, conn.WriteMessage(websocket.TextMessage, []byte(valid)))
+	assert.Equal(t, 1.0, readAck(t, conn)["seq"])
+
+	auth.revoke()
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err := conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.ClosePolicyViolation))
+}
+
+func TestIngestingCapturesKeepAlive(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := dialIngesting(t, &mockAddingCaptureService{}, &revocable{})
+	defer teardown()
+
+	// reading answers the pings, so the connection stays open while idle.
+	conn.SetReadDeadline(time.Now().Add(2 * handler.IngestIdleTimeout))
+	_, _, err := conn.ReadMessage()
+	netErr, ok := err.(net.Error)
+	assert.True(t, ok)
+	assert.True(t, netErr.Timeout())
+}
+
+func TestIngestingCapturesCloseWhenIdle(t *testing.T) {
+	t.Parallel()
+
+	conn, teardown := dialIngesting(t, &mockAddingCaptureService{}, &revocable{})
+	defer teardown()
+
+	conn.SetPingHandler(func(string) error { return nil })
+	conn.SetReadDeadline(time.Now().Add(4 * handler.IngestIdleTimeout))
+	_, _, err := conn.ReadMessage()
+	netErr, ok := err.(net.Error)
+	assert.False(t, ok && netErr.Timeout(), "expected the server to close the idle connection")
+}
+
+func TestIngestingCapturesBadRequestWithoutUpgrade(t *testing.T) {
+	t.Parallel()
+
+	e := bastion.Tester(t, setupIngestingHandler(&mockAddingCaptureService{}, &revocable{}, &limited{}, withRepoMiddle(defaultRepo)))
+	e.GET("/").
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().Equal(map[string]interface{}{
+		"status":  400.0,
+		"error":   "Bad Request",
+		"message": "websocket: not a websocket handshake: 'upgrade' token not found in 'Connection' header",
+	})
+}
+
+func TestIngestingCapturesFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	e := bastion.Tester(t, setupIngestingHandler(&mockAddingCaptureService{}, &revocable{}, &limited{}, withRepoMiddle(nil)))
+	e.GET("/").
+		Expect().
+		Status(http.StatusInternalServerError).
+		JSON().Object().Equal(map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	})
+}
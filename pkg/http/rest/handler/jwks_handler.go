@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/ifreddyrondon/bastion/render"
+
+	"github.com/ifreddyrondon/capture/pkg/token"
+)
+
+// JWKSMaxAge is how long the clients may cache the key set. It must be shorter
+// than the key rotation interval so the pending keys are fetched before they sign.
+var JWKSMaxAge = 300
+
+// GettingJWKS returns a configured http.Handler with the public keys to verify the tokens.
+func GettingJWKS(service token.KeyService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", JWKSMaxAge))
+		render.JSON.Send(w, service.JWKS())
+	}
+}
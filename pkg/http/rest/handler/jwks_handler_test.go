@@ -0,0 +1,35 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/token"
+)
+
+type mockKeyService struct {
+	jwks token.JWKS
+}
+
+func (m *mockKeyService) JWKS() token.JWKS { return m.jwks }
+
+func TestGettingJWKS(t *testing.T) {
+	t.Parallel()
+
+	s := &mockKeyService{jwks: token.JWKS{Keys: []token.JWK{{Kty: "EC", Kid: "abc", Use: "sig", Alg: "ES256", Crv: "P-256", X: "x", Y: "y"}}}}
+	app := bastion.New()
+	app.Get("/", handler.GettingJWKS(s))
+
+	response := map[string]interface{}{
+		"keys": []interface{}{
+			map[string]interface{}{"kty": "EC", "kid": "abc", "use": "sig", "alg": "ES256", "crv": "P-256", "x": "x", "y": "y"},
+		},
+	}
+	e := bastion.Tester(t, app)
+	res := e.GET("/").Expect().Status(http.StatusOK)
+	res.Header("Cache-Control").Equal("public, max-age=300")
+	res.JSON().Object().Equal(response)
+}
@@ -4,14 +4,61 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 
 	bastionMiddleware "github.com/ifreddyrondon/bastion/middleware"
 	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
 
+	"github.com/ifreddyrondon/capture/pkg/domain"
 	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
 	"github.com/ifreddyrondon/capture/pkg/listing"
+	"github.com/ifreddyrondon/capture/pkg/streaming"
 )
 
+var (
+	errInvalidNear   = errors.New("near must have the form lat,lng")
+	errInvalidRadius = errors.New("radius must be a positive number of meters along with near")
+)
+
+// locationParams returns the location filter of the area, near and radius query params.
+func locationParams(r *http.Request) (listing.LocationFilter, error) {
+	q := r.URL.Query()
+	var f listing.LocationFilter
+	if area := q.Get("area"); area != "" {
+		polygon, err := streaming.ParseArea(area)
+		if err != nil {
+			return f, errors.Cause(err)
+		}
+		f.Area = polygon
+	}
+
+	near := q.Get("near")
+	if near == "" {
+		if q.Get("radius") != "" {
+			return f, errInvalidRadius
+		}
+		return f, nil
+	}
+	parts := strings.Split(near, ",")
+	if len(parts) != 2 {
+		return f, errInvalidNear
+	}
+	lat, errLat := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lng, errLng := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if errLat != nil || errLng != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return f, errInvalidNear
+	}
+	radius, err := strconv.ParseFloat(q.Get("radius"), 64)
+	if err != nil || radius <= 0 {
+		return f, errInvalidRadius
+	}
+	f.Near = &domain.Point{LAT: &lat, LNG: &lng}
+	f.Radius = radius
+	return f, nil
+}
+
 // ListingUserRepos returns a configured http.Handler with user repos resources to get user's repos.
 func ListingUserRepos(service listing.RepoService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -62,8 +109,16 @@ func ListingPublicRepos(service listing.RepoService) http.HandlerFunc {
 }
 
 // ListingRepoCaptures returns a configured http.Handler with capture resources to get list of captures.
+// The area query param, minLat,minLng,maxLat,maxLng, keeps the captures inside the bounding box and
+// near, lat,lng, with radius in meters the captures around the point.
 func ListingRepoCaptures(service listing.CaptureService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		location, err := locationParams(r)
+		if err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
 		l, err := bastionMiddleware.GetListing(r.Context())
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
@@ -78,7 +133,35 @@ func ListingRepoCaptures(service listing.CaptureService) http.HandlerFunc {
 			return
 		}
 
-		res, err := service.ListRepoCaptures(repo, l)
+		res, err := service.ListRepoCaptures(repo, l, location)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, res)
+	}
+}
+
+// ListingOrganizationRepos returns a configured http.Handler with organization repos resources to get its repos.
+func ListingOrganizationRepos(service listing.RepoService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		l, err := bastionMiddleware.GetListing(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		res, err := service.GetOrganizationRepos(org, middleware.GetRole(r.Context()), l)
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
 			render.JSON.InternalServerError(w, err)
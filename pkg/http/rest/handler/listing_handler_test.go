@@ -14,6 +14,7 @@ import (
 	"github.com/ifreddyrondon/bastion/middleware/listing/filtering"
 	"github.com/ifreddyrondon/bastion/middleware/listing/paging"
 	"github.com/ifreddyrondon/bastion/middleware/listing/sorting"
+	"github.com/stretchr/testify/assert"
 	"gopkg.in/src-d/go-kallax.v1"
 
 	"github.com/ifreddyrondon/capture/pkg/domain"
@@ -82,6 +83,10 @@ func (m *mockListingRepoService) GetPublicRepos(l *listingBastionMiddleware.List
 	return &listing.ListRepositoryResponse{Listing: l, Results: m.repos}, m.err
 }
 
+func (m *mockListingRepoService) GetOrganizationRepos(o *domain.Organization, r domain.Role, l *listingBastionMiddleware.Listing) (*listing.ListRepositoryResponse, error) {
+	return &listing.ListRepositoryResponse{Listing: l, Results: m.repos}, m.err
+}
+
 func setupListingPublicReposHandler(s listing.RepoService, listMiddle func(http.Handler) http.Handler) *bastion.Bastion {
 	app := bastion.New()
 	app.Use(listMiddle)
@@ -231,6 +236,32 @@ func TestListingUserReposInternalServerGettingRepos(t *testing.T) {
 		JSON().Object().Equal(response)
 }
 
+func TestListingOrganizationRepos(t *testing.T) {
+	t.Parallel()
+
+	repos := []domain.Repository{{Name: "test public", Visibility: "public"}}
+	tt := []struct {
+		name        string
+		service     *mockListingRepoService
+		middlewares []func(http.Handler) http.Handler
+		status      int
+	}{
+		{"success", &mockListingRepoService{repos: repos}, []func(http.Handler) http.Handler{listingRepoMiddlewareOK, withOrganizationMiddle(defaultOrganization)}, http.StatusOK},
+		{"bad listing", &mockListingRepoService{}, []func(http.Handler) http.Handler{listingMiddlewareBAD, withOrganizationMiddle(defaultOrganization)}, http.StatusInternalServerError},
+		{"missing organization", &mockListingRepoService{}, []func(http.Handler) http.Handler{listingRepoMiddlewareOK}, http.StatusInternalServerError},
+		{"service err", &mockListingRepoService{err: errors.New("test")}, []func(http.Handler) http.Handler{listingRepoMiddlewareOK, withOrganizationMiddle(defaultOrganization)}, http.StatusInternalServerError},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := bastion.New()
+			app.With(tc.middlewares...).Get("/", handler.ListingOrganizationRepos(tc.service))
+			e := bastion.Tester(t, app)
+			e.GET("/").Expect().Status(tc.status)
+		})
+	}
+}
+
 func listingCaptureMiddlewareOK(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		l := getBaseListing()
@@ -242,9 +273,11 @@ func listingCaptureMiddlewareOK(next http.Handler) http.Handler {
 type mockListingCaptureService struct {
 	captures []domain.Capture
 	err      error
+	location listing.LocationFilter
 }
 
-func (m *mockListingCaptureService) ListRepoCaptures(r *domain.Repository, l *listingBastionMiddleware.Listing) (*listing.ListCaptureResponse, error) {
+func (m *mockListingCaptureService) ListRepoCaptures(r *domain.Repository, l *listingBastionMiddleware.Listing, f listing.LocationFilter) (*listing.ListCaptureResponse, error) {
+	m.location = f
 	return &listing.ListCaptureResponse{Listing: l, Results: m.captures}, m.err
 }
 
@@ -326,3 +359,62 @@ func TestListingUserReposInternalServerGettingCaptures(t *testing.T) {
 		Status(http.StatusInternalServerError).
 		JSON().Object().Equal(response)
 }
+
+func TestListingRepoCapturesByLocation(t *testing.T) {
+	t.Parallel()
+
+	s := &mockListingCaptureService{}
+	app := setupListingRepoCapturesHandler(s, listingCaptureMiddlewareOK, withUserMiddle(defaultUser), withRepoMiddle(defaultRepo))
+
+	e := bastion.Tester(t, app)
+	e.GET("/").
+		WithQuery("area", "0,0,10,10").
+		WithQuery("near", "5.5,-3").
+		WithQuery("radius", "250").
+		Expect().
+		Status(http.StatusOK)
+
+	assert.Len(t, s.location.Area, 4)
+	assert.Equal(t, 5.5, *s.location.Near.LAT)
+	assert.Equal(t, -3.0, *s.location.Near.LNG)
+	assert.Equal(t, 250.0, s.location.Radius)
+}
+
+func TestListingRepoCapturesBadLocation(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		query   map[string]string
+		message string
+	}{
+		{"invalid area", map[string]string{"area": "0,0,10"}, "area must have the form minLat,minLng,maxLat,maxLng"},
+		{"invalid near", map[string]string{"near": "5.5", "radius": "10"}, "near must have the form lat,lng"},
+		{"near out of bounds", map[string]string{"near": "95,0", "radius": "10"}, "near must have the form lat,lng"},
+		{"near without radius", map[string]string{"near": "5.5,-3"}, "radius must be a positive number of meters along with near"},
+		{"negative radius", map[string]string{"near": "5.5,-3", "radius": "-1"}, "radius must be a positive number of meters along with near"},
+		{"radius without near", map[string]string{"radius": "10"}, "radius must be a positive number of meters along with near"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &mockListingCaptureService{}
+			app := setupListingRepoCapturesHandler(s, listingCaptureMiddlewareOK, withUserMiddle(defaultUser), withRepoMiddle(defaultRepo))
+
+			response := map[string]interface{}{
+				"status":  400.0,
+				"error":   "Bad Request",
+				"message": tc.message,
+			}
+
+			e := bastion.Tester(t, app)
+			req := e.GET("/")
+			for k, v := range tc.query {
+				req = req.WithQuery(k, v)
+			}
+			req.Expect().
+				Status(http.StatusBadRequest).
+				JSON().Object().Equal(response)
+		})
+	}
+}
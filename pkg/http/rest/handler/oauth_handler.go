@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/authenticating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+var errInvalidClientID = errors.New("invalid client id")
+
+type oauthError interface {
+	OAuthError() string
+}
+
+// oauthErrorJSON represents an error response of the token endpoint. Referenced at
+// https://tools.ietf.org/html/rfc6749#section-5.2
+type oauthErrorJSON struct {
+	Error       string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+// renderOAuthErr renders the errors of the token endpoint with the format of the spec.
+// Invalid clients are unauthorized, the rest of the oauth errors are bad requests.
+func renderOAuthErr(w http.ResponseWriter, err error) {
+	e, ok := errors.Cause(err).(oauthError)
+	if !ok {
+		fmt.Fprintln(os.Stderr, err)
+		render.JSON.InternalServerError(w, err)
+		return
+	}
+	status := http.StatusBadRequest
+	if e.OAuthError() == authenticating.InvalidClient {
+		status = http.StatusUnauthorized
+	}
+	render.JSON.Response(w, status, oauthErrorJSON{Error: e.OAuthError(), Description: errors.Cause(err).Error()})
+}
+
+// RegisteringOAuthClient returns a configured http.Handler with oauth client resources to register a client.
+func RegisteringOAuthClient(service authenticating.OAuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload authenticating.ClientPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		c, err := service.RegisterClient(u, payload)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Created(w, c)
+	}
+}
+
+// ListingOAuthClients returns a configured http.Handler with oauth client resources to list the user clients.
+func ListingOAuthClients(service authenticating.OAuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		clients, err := service.ListClients(u)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, clients)
+	}
+}
+
+// RemovingOAuthClient returns a configured http.Handler with oauth client resources to remove a client.
+func RemovingOAuthClient(service authenticating.OAuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "clientId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidClientID)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err := service.RemoveClient(u, id); err != nil {
+			if isNotFound(err) {
+				render.JSON.NotFound(w, errors.Cause(err))
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// PromptingConsent returns a configured http.Handler with oauth resources to describe the
+// access requested by a client, so the user could approve or deny it.
+func PromptingConsent(service authenticating.OAuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload := authenticating.NewAuthorizePayload(r.URL.Query())
+		if err := payload.Validate(); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		prompt, err := service.Prompt(u, payload)
+		if err != nil {
+			if isInvalidErr(err) {
+				render.JSON.BadRequest(w, errors.Cause(err))
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, prompt)
+	}
+}
+
+// ConsentingAuthorization returns a configured http.Handler with oauth resources to approve
+// or deny the access requested by a client. It responds with the uri to redirect the user.
+func ConsentingAuthorization(service authenticating.OAuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload := authenticating.NewAuthorizePayload(r.URL.Query())
+		if err := payload.Validate(); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		var consent authenticating.ConsentPayload
+		if err := binder.JSON.FromReq(r, &consent); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		redirect, err := service.Consent(u, payload, consent)
+		if err != nil {
+			if isInvalidErr(err) {
+				render.JSON.BadRequest(w, errors.Cause(err))
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, redirect)
+	}
+}
+
+// IssuingOAuthToken returns a configured http.Handler with the oauth token endpoint. The
+// client credentials could be sent in the form or with HTTP basic authentication.
+func IssuingOAuthToken(service authenticating.OAuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("Pragma", "no-cache")
+		if err := r.ParseForm(); err != nil {
+			render.JSON.Response(w, http.StatusBadRequest, oauthErrorJSON{Error: authenticating.InvalidRequest, Description: err.Error()})
+			return
+		}
+
+		payload := authenticating.NewTokenPayload(r.PostForm)
+		if id, secret, ok := r.BasicAuth(); ok {
+			payload.ClientID, payload.ClientSecret = id, secret
+		}
+
+		t, err := service.Exchange(payload)
+		if err != nil {
+			renderOAuthErr(w, err)
+			return
+		}
+
+		middleware.SetAuditTarget(r.Context(), domain.OAuthClientTarget, payload.ClientID)
+		middleware.AddAuditDetail(r.Context(), "grantType", payload.GrantType)
+		middleware.AddAuditDetail(r.Context(), "scope", t.Scope)
+
+		render.JSON.Send(w, t)
+	}
+}
+
+// ListingOAuthConsents returns a configured http.Handler with oauth resources to list the
+// clients authorized by the user.
+func ListingOAuthConsents(service authenticating.OAuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		consents, err := service.ListConsents(u)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, consents)
+	}
+}
+
+// RevokingOAuthConsent returns a configured http.Handler with oauth resources to revoke the
+// access granted by the user to a client.
+func RevokingOAuthConsent(service authenticating.OAuthService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "clientId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidClientID)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err := service.RevokeConsent(u, id); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,229 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/authenticating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+)
+
+var defaultOAuthClient = domain.OAuthClient{
+	ID:           kallax.NewULID(),
+	Name:         "test",
+	RedirectURIs: []string{"https://example.com/callback"},
+	Scopes:       []domain.APIKeyScope{domain.ReposRead},
+}
+
+type mockOAuthService struct {
+	client   *authenticating.RegisteredClient
+	clients  []domain.OAuthClient
+	prompt   *authenticating.ConsentPrompt
+	redirect *authenticating.ConsentRedirect
+	token    *authenticating.OAuthToken
+	consents []domain.OAuthConsent
+	payload  authenticating.TokenPayload
+	err      error
+}
+
+func (m *mockOAuthService) RegisterClient(*domain.User, authenticating.ClientPayload) (*authenticating.RegisteredClient, error) {
+	return m.client, m.err
+}
+func (m *mockOAuthService) ListClients(*domain.User) ([]domain.OAuthClient, error) {
+	return m.clients, m.err
+}
+func (m *mockOAuthService) RemoveClient(*domain.User, kallax.ULID) error { return m.err }
+func (m *mockOAuthService) Prompt(*domain.User, authenticating.AuthorizePayload) (*authenticating.ConsentPrompt, error) {
+	return m.prompt, m.err
+}
+func (m *mockOAuthService) Consent(*domain.User, authenticating.AuthorizePayload, authenticating.ConsentPayload) (*authenticating.ConsentRedirect, error) {
+	return m.redirect, m.err
+}
+func (m *mockOAuthService) Exchange(p authenticating.TokenPayload) (*authenticating.OAuthToken, error) {
+	m.payload = p
+	return m.token, m.err
+}
+func (m *mockOAuthService) ListConsents(*domain.User) ([]domain.OAuthConsent, error) {
+	return m.consents, m.err
+}
+func (m *mockOAuthService) RevokeConsent(*domain.User, kallax.ULID) error { return m.err }
+
+type oauthErr struct {
+	code string
+}
+
+func (e oauthErr) Error() string      { return "test" }
+func (e oauthErr) OAuthError() string { return e.code }
+
+func setupOAuthHandlers(s authenticating.OAuthService, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Post("/token", handler.IssuingOAuthToken(s))
+	app.Get("/authorize", handler.PromptingConsent(s))
+	app.Post("/authorize", handler.ConsentingAuthorization(s))
+	app.Post("/clients/", handler.RegisteringOAuthClient(s))
+	app.Get("/clients/", handler.ListingOAuthClients(s))
+	app.Delete("/clients/{clientId}", handler.RemovingOAuthClient(s))
+	app.Get("/consents/", handler.ListingOAuthConsents(s))
+	app.Delete("/consents/{clientId}", handler.RevokingOAuthConsent(s))
+	return app
+}
+
+func authorizeQuery() map[string]interface{} {
+	return map[string]interface{}{
+		"response_type":         "code",
+		"client_id":             defaultOAuthClient.ID.String(),
+		"redirect_uri":          "https://example.com/callback",
+		"code_challenge":        authenticating.S256Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"),
+		"code_challenge_method": "S256",
+		"state":                 "xyz",
+	}
+}
+
+func TestOAuthHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockOAuthService{
+		client:   &authenticating.RegisteredClient{OAuthClient: defaultOAuthClient, Secret: "secret"},
+		clients:  []domain.OAuthClient{defaultOAuthClient},
+		prompt:   &authenticating.ConsentPrompt{Client: defaultOAuthClient, Scopes: defaultOAuthClient.Scopes},
+		redirect: &authenticating.ConsentRedirect{RedirectURI: "https://example.com/callback?code=abc&state=xyz"},
+		consents: []domain.OAuthConsent{{ClientID: defaultOAuthClient.ID}},
+	}
+	app := setupOAuthHandlers(s, withUserMiddle(defaultUser))
+	clientPath := "/clients/" + defaultOAuthClient.ID.String()
+	consentPath := "/consents/" + defaultOAuthClient.ID.String()
+
+	e := bastion.Tester(t, app)
+	e.POST("/clients/").
+		WithJSON(map[string]interface{}{"name": "test", "redirectUris": []string{"https://example.com/callback"}, "scopes": []string{"repos:read"}}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().ValueEqual("clientSecret", "secret").ValueEqual("name", "test")
+	e.GET("/clients/").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.DELETE(clientPath).Expect().Status(http.StatusNoContent)
+	e.GET("/authorize").WithQueryObject(authorizeQuery()).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().ValueEqual("granted", false).ContainsKey("client")
+	e.POST("/authorize").WithQueryObject(authorizeQuery()).WithJSON(map[string]interface{}{"approved": true}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().ValueEqual("redirectUri", "https://example.com/callback?code=abc&state=xyz")
+	e.GET("/consents/").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.DELETE(consentPath).Expect().Status(http.StatusNoContent)
+}
+
+func TestOAuthHandlersFail(t *testing.T) {
+	t.Parallel()
+
+	withUser := []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}
+	clientPath := "/clients/" + defaultOAuthClient.ID.String()
+	invalidQuery := authorizeQuery()
+	invalidQuery["code_challenge_method"] = "plain"
+
+	tt := []struct {
+		name        string
+		method      string
+		path        string
+		query       map[string]interface{}
+		payload     map[string]interface{}
+		service     *mockOAuthService
+		middlewares []func(http.Handler) http.Handler
+		status      int
+		message     string
+	}{
+		{"registering invalid payload", "POST", "/clients/", nil, map[string]interface{}{"name": "test", "scopes": []string{"repos:read"}}, &mockOAuthService{}, withUser, http.StatusBadRequest, "redirectUris must contain at least one uri"},
+		{"registering missing user", "POST", "/clients/", nil, map[string]interface{}{"name": "test", "redirectUris": []string{"https://example.com"}, "scopes": []string{"repos:read"}}, &mockOAuthService{}, nil, http.StatusInternalServerError, "looks like something went wrong"},
+		{"listing clients err", "GET", "/clients/", nil, nil, &mockOAuthService{err: errors.New("test")}, withUser, http.StatusInternalServerError, "looks like something went wrong"},
+		{"removing invalid id", "DELETE", "/clients/test", nil, nil, &mockOAuthService{}, withUser, http.StatusBadRequest, "invalid client id"},
+		{"removing not found", "DELETE", clientPath, nil, nil, &mockOAuthService{err: notFoundErr("not found oauth client")}, withUser, http.StatusNotFound, "not found oauth client"},
+		{"prompting invalid query", "GET", "/authorize", invalidQuery, nil, &mockOAuthService{}, withUser, http.StatusBadRequest, "code_challenge_method must be S256"},
+		{"prompting unknown client", "GET", "/authorize", authorizeQuery(), nil, &mockOAuthService{err: invalidErr("unknown client")}, withUser, http.StatusBadRequest, "unknown client"},
+		{"prompting err", "GET", "/authorize", authorizeQuery(), nil, &mockOAuthService{err: errors.New("test")}, withUser, http.StatusInternalServerError, "looks like something went wrong"},
+		{"consenting missing approved", "POST", "/authorize", authorizeQuery(), map[string]interface{}{}, &mockOAuthService{}, withUser, http.StatusBadRequest, "approved must not be blank"},
+		{"consenting invalid redirect", "POST", "/authorize", authorizeQuery(), map[string]interface{}{"approved": true}, &mockOAuthService{err: invalidErr("redirect_uri is not registered for the client")}, withUser, http.StatusBadRequest, "redirect_uri is not registered for the client"},
+		{"listing consents missing user", "GET", "/consents/", nil, nil, &mockOAuthService{}, nil, http.StatusInternalServerError, "looks like something went wrong"},
+		{"revoking invalid id", "DELETE", "/consents/test", nil, nil, &mockOAuthService{}, withUser, http.StatusBadRequest, "invalid client id"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupOAuthHandlers(tc.service, tc.middlewares...))
+			req := e.Request(tc.method, tc.path)
+			if tc.query != nil {
+				req = req.WithQueryObject(tc.query)
+			}
+			if tc.payload != nil {
+				req = req.WithJSON(tc.payload)
+			}
+			req.Expect().
+				Status(tc.status).
+				JSON().Object().ValueEqual("message", tc.message)
+		})
+	}
+}
+
+func TestIssuingOAuthTokenSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockOAuthService{token: &authenticating.OAuthToken{AccessToken: "token", TokenType: "Bearer", ExpiresIn: 3600, Scope: "repos:read"}}
+	e := bastion.Tester(t, setupOAuthHandlers(s))
+	resp := e.POST("/token").
+		WithBasicAuth("client", "secret").
+		WithFormField("grant_type", "client_credentials").
+		Expect().
+		Status(http.StatusOK)
+	resp.Header("Cache-Control").Equal("no-store")
+	resp.JSON().Object().
+		ValueEqual("access_token", "token").
+		ValueEqual("token_type", "Bearer").
+		ValueEqual("expires_in", 3600).
+		ValueEqual("scope", "repos:read")
+
+	if s.payload.ClientID != "client" || s.payload.ClientSecret != "secret" || s.payload.GrantType != "client_credentials" {
+		t.Errorf("unexpected token payload %+v", s.payload)
+	}
+}
+
+func TestIssuingOAuthTokenFail(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"invalid client", oauthErr{authenticating.InvalidClient}, http.StatusUnauthorized, "invalid_client"},
+		{"invalid grant", errors.WithStack(oauthErr{authenticating.InvalidGrant}), http.StatusBadRequest, "invalid_grant"},
+		{"unsupported grant type", oauthErr{authenticating.UnsupportedGrantType}, http.StatusBadRequest, "unsupported_grant_type"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupOAuthHandlers(&mockOAuthService{err: tc.err}))
+			e.POST("/token").
+				WithFormField("grant_type", "authorization_code").
+				Expect().
+				Status(tc.status).
+				JSON().Object().
+				ValueEqual("error", tc.code).
+				ValueEqual("error_description", "test")
+		})
+	}
+
+	e := bastion.Tester(t, setupOAuthHandlers(&mockOAuthService{err: errors.New("test")}))
+	e.POST("/token").
+		WithFormField("grant_type", "authorization_code").
+		Expect().
+		Status(http.StatusInternalServerError).
+		JSON().Object().ValueEqual("message", "looks like something went wrong")
+}
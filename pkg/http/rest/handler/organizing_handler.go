@@ -0,0 +1,318 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/organizing"
+)
+
+var (
+	errInvalidMemberID = errors.New("invalid member id")
+	errInvalidTeamID   = errors.New("invalid team id")
+	errInvalidUserID   = errors.New("invalid user id")
+)
+
+// renderOrganizingErr renders the not found, invalid and conflict errors of the
+// organizing service and falls back to an internal server error.
+func renderOrganizingErr(w http.ResponseWriter, err error) {
+	switch {
+	case isNotFound(err):
+		render.JSON.NotFound(w, err)
+	case isInvalidErr(err):
+		render.JSON.BadRequest(w, err)
+	case isConflictErr(err):
+		conflict(w, err.Error())
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		render.JSON.InternalServerError(w, err)
+	}
+}
+
+// CreatingOrganization returns a configured http.Handler with creating organization resources.
+func CreatingOrganization(service organizing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload organizing.Payload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		org, err := service.CreateOrganization(u, payload)
+		if err != nil {
+			renderOrganizingErr(w, err)
+			return
+		}
+
+		render.JSON.Created(w, org)
+	}
+}
+
+// ListingOrganizations returns a configured http.Handler with organization resources to get the user organizations.
+func ListingOrganizations(service organizing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		orgs, err := service.ListOrganizations(u)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, orgs)
+	}
+}
+
+// GettingOrganization returns a configured http.Handler with getting organization resources.
+func GettingOrganization() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, org)
+	}
+}
+
+// AddingMember returns a configured http.Handler with member resources to add an user to an organization.
+func AddingMember(service organizing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload organizing.MemberPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		m, err := service.AddMember(org, payload)
+		if err != nil {
+			renderOrganizingErr(w, err)
+			return
+		}
+
+		middleware.AddAuditDetail(r.Context(), "member", m.UserID.String())
+		middleware.AddAuditDetail(r.Context(), "role", m.Role)
+
+		render.JSON.Created(w, m)
+	}
+}
+
+// ListingMembers returns a configured http.Handler with member resources to get the organization members.
+func ListingMembers(service organizing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		members, err := service.ListMembers(org)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, members)
+	}
+}
+
+// RemovingMember returns a configured http.Handler with member resources to remove a member of an organization.
+func RemovingMember(service organizing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "memberId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidMemberID)
+			return
+		}
+
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err := service.RemoveMember(id, org); err != nil {
+			renderOrganizingErr(w, err)
+			return
+		}
+
+		middleware.AddAuditDetail(r.Context(), "member", id.String())
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// CreatingTeam returns a configured http.Handler with team resources to create a team in an organization.
+func CreatingTeam(service organizing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload organizing.TeamPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		t, err := service.CreateTeam(org, payload)
+		if err != nil {
+			renderOrganizingErr(w, err)
+			return
+		}
+
+		render.JSON.Created(w, t)
+	}
+}
+
+// ListingTeams returns a configured http.Handler with team resources to get the organization teams.
+func ListingTeams(service organizing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		teams, err := service.ListTeams(org)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, teams)
+	}
+}
+
+// RemovingTeam returns a configured http.Handler with team resources to remove a team of an organization.
+func RemovingTeam(service organizing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "teamId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidTeamID)
+			return
+		}
+
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err := service.RemoveTeam(id, org); err != nil {
+			renderOrganizingErr(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// AddingTeamMember returns a configured http.Handler with team resources to add a member to a team.
+func AddingTeamMember(service organizing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload organizing.TeamMemberPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		id, err := kallax.NewULIDFromText(chi.URLParam(r, "teamId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidTeamID)
+			return
+		}
+
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		tm, err := service.AddTeamMember(id, org, payload)
+		if err != nil {
+			renderOrganizingErr(w, err)
+			return
+		}
+
+		middleware.SetAuditTarget(r.Context(), domain.TeamTarget, id.String())
+		middleware.AddAuditDetail(r.Context(), "member", tm.UserID.String())
+
+		render.JSON.Created(w, tm)
+	}
+}
+
+// RemovingTeamMember returns a configured http.Handler with team resources to remove a member from a team.
+func RemovingTeamMember(service organizing.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		teamID, err := kallax.NewULIDFromText(chi.URLParam(r, "teamId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidTeamID)
+			return
+		}
+
+		userID, err := kallax.NewULIDFromText(chi.URLParam(r, "userId"))
+		if err != nil {
+			render.JSON.BadRequest(w, errInvalidUserID)
+			return
+		}
+
+		org, err := middleware.GetOrganization(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err := service.RemoveTeamMember(teamID, userID, org); err != nil {
+			renderOrganizingErr(w, err)
+			return
+		}
+
+		middleware.SetAuditTarget(r.Context(), domain.TeamTarget, teamID.String())
+		middleware.AddAuditDetail(r.Context(), "member", userID.String())
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
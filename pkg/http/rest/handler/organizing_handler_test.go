@@ -0,0 +1,203 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/organizing"
+)
+
+var (
+	defaultOrganization = &domain.Organization{ID: kallax.NewULID(), Name: "acme"}
+	defaultMember       = &domain.Member{ID: kallax.NewULID(), Role: domain.RegularMember, Email: "bob@example.com"}
+	defaultTeam         = &domain.Team{ID: kallax.NewULID(), Name: "devs", Role: domain.WriteRole}
+)
+
+type mockOrganizingService struct {
+	err error
+}
+
+func (m *mockOrganizingService) CreateOrganization(*domain.User, organizing.Payload) (*domain.Organization, error) {
+	return defaultOrganization, m.err
+}
+func (m *mockOrganizingService) ListOrganizations(*domain.User) ([]domain.Organization, error) {
+	return []domain.Organization{*defaultOrganization}, m.err
+}
+func (m *mockOrganizingService) GetOrganization(kallax.ULID, *domain.User) (*domain.Organization, error) {
+	return defaultOrganization, m.err
+}
+func (m *mockOrganizingService) GetOrganizationRole(*domain.Organization, *domain.User) (domain.Role, error) {
+	return "", m.err
+}
+func (m *mockOrganizingService) AddMember(*domain.Organization, organizing.MemberPayload) (*domain.Member, error) {
+	return defaultMember, m.err
+}
+func (m *mockOrganizingService) ListMembers(*domain.Organization) ([]domain.Member, error) {
+	return []domain.Member{*defaultMember}, m.err
+}
+func (m *mockOrganizingService) RemoveMember(kallax.ULID, *domain.Organization) error { return m.err }
+func (m *mockOrganizingService) CreateTeam(*domain.Organization, organizing.TeamPayload) (*domain.Team, error) {
+	return defaultTeam, m.err
+}
+func (m *mockOrganizingService) ListTeams(*domain.Organization) ([]domain.Team, error) {
+	return []domain.Team{*defaultTeam}, m.err
+}
+func (m *mockOrganizingService) RemoveTeam(kallax.ULID, *domain.Organization) error { return m.err }
+func (m *mockOrganizingService) AddTeamMember(kallax.ULID, *domain.Organization, organizing.TeamMemberPayload) (*domain.TeamMember, error) {
+	return &domain.TeamMember{TeamID: defaultTeam.ID}, m.err
+}
+func (m *mockOrganizingService) RemoveTeamMember(kallax.ULID, kallax.ULID, *domain.Organization) error {
+	return m.err
+}
+func (m *mockOrganizingService) GetRole(*domain.Repository, *domain.User) (domain.Role, error) {
+	return "", m.err
+}
+
+func withOrganizationMiddle(org *domain.Organization) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if org != nil {
+				ctx = context.WithValue(ctx, middleware.OrganizationCtxKey, org)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func setupOrganizingHandlers(s organizing.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Post("/", handler.CreatingOrganization(s))
+	app.Get("/", handler.ListingOrganizations(s))
+	app.Get("/org", handler.GettingOrganization())
+	app.Post("/org/members", handler.AddingMember(s))
+	app.Get("/org/members", handler.ListingMembers(s))
+	app.Delete("/org/members/{memberId}", handler.RemovingMember(s))
+	app.Post("/org/teams", handler.CreatingTeam(s))
+	app.Get("/org/teams", handler.ListingTeams(s))
+	app.Delete("/org/teams/{teamId}", handler.RemovingTeam(s))
+	app.Post("/org/teams/{teamId}/members", handler.AddingTeamMember(s))
+	app.Delete("/org/teams/{teamId}/members/{userId}", handler.RemovingTeamMember(s))
+	return app
+}
+
+func TestOrganizingHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	app := setupOrganizingHandlers(&mockOrganizingService{}, withUserMiddle(defaultUser), withOrganizationMiddle(defaultOrganization))
+	teamPath := "/org/teams/" + defaultTeam.ID.String()
+
+	e := bastion.Tester(t, app)
+	e.POST("/").WithJSON(map[string]interface{}{"name": "acme"}).
+		Expect().Status(http.StatusCreated).JSON().Object().ValueEqual("name", "acme")
+	e.GET("/").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.GET("/org").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("name", "acme")
+	e.POST("/org/members").WithJSON(map[string]interface{}{"email": "bob@example.com"}).
+		Expect().Status(http.StatusCreated).JSON().Object().ValueEqual("role", "member")
+	e.GET("/org/members").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.DELETE("/org/members/" + defaultMember.ID.String()).Expect().Status(http.StatusNoContent)
+	e.POST("/org/teams").WithJSON(map[string]interface{}{"name": "devs", "role": "write"}).
+		Expect().Status(http.StatusCreated).JSON().Object().ValueEqual("role", "write")
+	e.GET("/org/teams").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.DELETE(teamPath).Expect().Status(http.StatusNoContent)
+	e.POST(teamPath+"/members").WithJSON(map[string]interface{}{"email": "bob@example.com"}).
+		Expect().Status(http.StatusCreated).JSON().Object().ValueEqual("teamId", defaultTeam.ID.String())
+	e.DELETE(teamPath + "/members/" + defaultUser.ID.String()).Expect().Status(http.StatusNoContent)
+}
+
+func TestOrganizingHandlersFails(t *testing.T) {
+	t.Parallel()
+
+	teamPath := "/org/teams/" + defaultTeam.ID.String()
+	memberPayload := map[string]interface{}{"email": "bob@example.com"}
+	teamPayload := map[string]interface{}{"name": "devs", "role": "write"}
+	tt := []struct {
+		name    string
+		method  string
+		path    string
+		payload map[string]interface{}
+		err     error
+		status  int
+		message string
+	}{
+		{"creating bad request", "POST", "/", map[string]interface{}{}, nil, http.StatusBadRequest, "name must not be blank"},
+		{"creating conflict", "POST", "/", map[string]interface{}{"name": "acme"}, conflictErr("organization acme already exist"), http.StatusConflict, "organization acme already exist"},
+		{"adding member bad request", "POST", "/org/members", map[string]interface{}{}, nil, http.StatusBadRequest, "email must not be blank"},
+		{"adding member not found", "POST", "/org/members", memberPayload, notFoundErr("user with email bob@example.com not found"), http.StatusNotFound, "user with email bob@example.com not found"},
+		{"adding member err", "POST", "/org/members", memberPayload, errors.New("test"), http.StatusInternalServerError, "looks like something went wrong"},
+		{"removing member invalid id", "DELETE", "/org/members/abc", nil, nil, http.StatusBadRequest, "invalid member id"},
+		{"removing last owner", "DELETE", "/org/members/" + defaultMember.ID.String(), nil, invalidErr("the organization must have at least one owner"), http.StatusBadRequest, "the organization must have at least one owner"},
+		{"creating team bad request", "POST", "/org/teams", map[string]interface{}{"name": "devs"}, nil, http.StatusBadRequest, "role must not be blank"},
+		{"creating team conflict", "POST", "/org/teams", teamPayload, conflictErr("team devs already exist"), http.StatusConflict, "team devs already exist"},
+		{"removing team invalid id", "DELETE", "/org/teams/abc", nil, nil, http.StatusBadRequest, "invalid team id"},
+		{"removing team not found", "DELETE", teamPath, nil, notFoundErr("team not found"), http.StatusNotFound, "team not found"},
+		{"adding team member invalid id", "POST", "/org/teams/abc/members", memberPayload, nil, http.StatusBadRequest, "invalid team id"},
+		{"adding team member not in organization", "POST", teamPath + "/members", memberPayload, invalidErr("bob@example.com is not a member of the organization"), http.StatusBadRequest, "bob@example.com is not a member of the organization"},
+		{"removing team member invalid user id", "DELETE", teamPath + "/members/abc", nil, nil, http.StatusBadRequest, "invalid user id"},
+		{"removing team member not found", "DELETE", teamPath + "/members/" + defaultUser.ID.String(), nil, notFoundErr("not a member"), http.StatusNotFound, "not a member"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := setupOrganizingHandlers(&mockOrganizingService{err: tc.err}, withUserMiddle(defaultUser), withOrganizationMiddle(defaultOrganization))
+			e := bastion.Tester(t, app)
+			e.Request(tc.method, tc.path).
+				WithJSON(tc.payload).
+				Expect().
+				Status(tc.status).
+				JSON().Object().ValueEqual("message", tc.message)
+		})
+	}
+}
+
+func TestOrganizingHandlersFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	teamPath := "/org/teams/" + defaultTeam.ID.String()
+	tt := []struct {
+		name    string
+		method  string
+		path    string
+		payload map[string]interface{}
+	}{
+		{"creating", "POST", "/", map[string]interface{}{"name": "acme"}},
+		{"listing", "GET", "/", nil},
+		{"getting", "GET", "/org", nil},
+		{"adding member", "POST", "/org/members", map[string]interface{}{"email": "bob@example.com"}},
+		{"listing members", "GET", "/org/members", nil},
+		{"removing member", "DELETE", "/org/members/" + defaultMember.ID.String(), nil},
+		{"creating team", "POST", "/org/teams", map[string]interface{}{"name": "devs", "role": "read"}},
+		{"listing teams", "GET", "/org/teams", nil},
+		{"removing team", "DELETE", teamPath, nil},
+		{"adding team member", "POST", teamPath + "/members", map[string]interface{}{"email": "bob@example.com"}},
+		{"removing team member", "DELETE", teamPath + "/members/" + defaultUser.ID.String(), nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupOrganizingHandlers(&mockOrganizingService{}))
+			e.Request(tc.method, tc.path).
+				WithJSON(tc.payload).
+				Expect().
+				Status(http.StatusInternalServerError)
+		})
+	}
+
+	app := setupOrganizingHandlers(&mockOrganizingService{err: errors.New("test")}, withUserMiddle(defaultUser), withOrganizationMiddle(defaultOrganization))
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusInternalServerError)
+	e.GET("/org/members").Expect().Status(http.StatusInternalServerError)
+	e.GET("/org/teams").Expect().Status(http.StatusInternalServerError)
+}
@@ -31,3 +31,23 @@ func RemovingCapture(service removing.CaptureService) http.HandlerFunc {
 		render.JSON.Send(w, capt)
 	}
 }
+
+// RemovingUser returns a configured http.Handler that deletes the account of the user.
+func RemovingUser(service removing.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err = service.Remove(u); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
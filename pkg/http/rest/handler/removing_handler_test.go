@@ -71,3 +71,33 @@ func TestRemovingCaptureFailsRemoving(t *testing.T) {
 		Status(http.StatusInternalServerError).
 		JSON().Object().Equal(response)
 }
+
+type removingUserServiceMock struct {
+	err error
+}
+
+func (m *removingUserServiceMock) Remove(*domain.User) error { return m.err }
+
+func TestRemovingUser(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		service *removingUserServiceMock
+		user    *domain.User
+		status  int
+	}{
+		{"removed", &removingUserServiceMock{}, defaultUser, http.StatusNoContent},
+		{"missing user", &removingUserServiceMock{}, nil, http.StatusInternalServerError},
+		{"remove err", &removingUserServiceMock{err: errors.New("test")}, defaultUser, http.StatusInternalServerError},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := bastion.New()
+			app.Use(withUserMiddle(tc.user))
+			app.Delete("/", handler.RemovingUser(tc.service))
+			bastion.Tester(t, app).DELETE("/").Expect().Status(tc.status)
+		})
+	}
+}
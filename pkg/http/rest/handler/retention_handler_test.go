@@ -0,0 +1,123 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	bastionMiddleware "github.com/ifreddyrondon/bastion/middleware"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/retention"
+)
+
+type policyNotFound string
+
+func (e policyNotFound) Error() string  { return string(e) }
+func (e policyNotFound) NotFound() bool { return true }
+
+var defaultRetentionPolicy = &domain.RetentionPolicy{
+	RepositoryID: kallax.NewULID(),
+	Days:         30,
+	Action:       domain.DropCaptures,
+}
+
+type mockRetentionService struct {
+	policy  *domain.RetentionPolicy
+	reports []domain.RetentionReport
+	err     error
+}
+
+func (m *mockRetentionService) GetPolicy(*domain.Repository) (*domain.RetentionPolicy, error) {
+	return m.policy, m.err
+}
+func (m *mockRetentionService) SetPolicy(*domain.Repository, retention.Payload) (*domain.RetentionPolicy, error) {
+	return m.policy, m.err
+}
+func (m *mockRetentionService) RemovePolicy(*domain.Repository) error { return m.err }
+func (m *mockRetentionService) ListReports(r *domain.Repository, l *listing.Listing) (*retention.ListReportResponse, error) {
+	return &retention.ListReportResponse{Results: m.reports, Listing: l}, m.err
+}
+func (m *mockRetentionService) Run(time.Time) ([]domain.RetentionReport, error) {
+	return m.reports, m.err
+}
+
+func setupRetentionHandlers(s retention.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Get("/", handler.GettingRetentionPolicy(s))
+	app.Put("/", handler.SettingRetentionPolicy(s))
+	app.Delete("/", handler.RemovingRetentionPolicy(s))
+	app.With(bastionMiddleware.Listing()).Get("/reports", handler.ListingRetentionReports(s))
+	return app
+}
+
+func TestRetentionHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockRetentionService{
+		policy:  defaultRetentionPolicy,
+		reports: []domain.RetentionReport{{ID: kallax.NewULID(), Action: domain.DropCaptures, Captures: 10}},
+	}
+	app := setupRetentionHandlers(s, withRepoMiddle(defaultRepo))
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("days", 30)
+	e.PUT("/").
+		WithJSON(map[string]interface{}{"days": 30}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().ValueEqual("action", "drop")
+	e.DELETE("/").Expect().Status(http.StatusNoContent)
+	e.GET("/reports").Expect().Status(http.StatusOK).
+		JSON().Object().Value("results").Array().First().Object().ValueEqual("captures", 10)
+}
+
+func TestSettingRetentionPolicyFailBadRequest(t *testing.T) {
+	t.Parallel()
+
+	app := setupRetentionHandlers(&mockRetentionService{}, withRepoMiddle(defaultRepo))
+
+	response := map[string]interface{}{
+		"status":  400.0,
+		"error":   "Bad Request",
+		"message": "days must be a positive number of days",
+	}
+
+	e := bastion.Tester(t, app)
+	e.PUT("/").
+		WithJSON(map[string]interface{}{"days": 0}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().Equal(response)
+}
+
+func TestGettingRetentionPolicyFailNotFound(t *testing.T) {
+	t.Parallel()
+
+	s := &mockRetentionService{err: errors.WithStack(policyNotFound("retention policy not found"))}
+	app := setupRetentionHandlers(s, withRepoMiddle(defaultRepo))
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusNotFound)
+}
+
+func TestRetentionHandlersFailInternalServerError(t *testing.T) {
+	t.Parallel()
+
+	app := setupRetentionHandlers(&mockRetentionService{err: errors.New("test")}, withRepoMiddle(defaultRepo))
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusInternalServerError)
+	e.PUT("/").WithJSON(map[string]interface{}{"days": 30}).Expect().Status(http.StatusInternalServerError)
+	e.DELETE("/").Expect().Status(http.StatusInternalServerError)
+	e.GET("/reports").Expect().Status(http.StatusInternalServerError)
+}
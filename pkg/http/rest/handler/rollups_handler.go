@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/ifreddyrondon/bastion/render"
+
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/rollups"
+)
+
+// CreatingRollupRule returns a configured http.Handler with creating rollup rule resources.
+func CreatingRollupRule(service rollups.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload rollups.Payload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		rule, err := service.CreateRule(repo, payload)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Created(w, rule)
+	}
+}
+
+// ListingRollupRules returns a configured http.Handler with rollup resources to get the repo rollup rules.
+func ListingRollupRules(service rollups.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		rules, err := service.ListRules(repo)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, rules)
+	}
+}
+
+// GettingRollupRule returns a configured http.Handler with getting rollup rule resources.
+func GettingRollupRule() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, err := middleware.GetRollupRule(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, rule)
+	}
+}
+
+// RemovingRollupRule returns a configured http.Handler with removing rollup rule resources.
+func RemovingRollupRule(service rollups.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rule, err := middleware.GetRollupRule(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		if err := service.RemoveRule(rule); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, rule)
+	}
+}
@@ -0,0 +1,152 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/rollups"
+)
+
+var defaultRollupRule = &domain.RollupRule{
+	ID:         kallax.NewULID(),
+	AfterDays:  30,
+	Resolution: 60,
+	Aggregate:  domain.AvgAggregate,
+}
+
+type mockRollupsService struct {
+	rule  *domain.RollupRule
+	rules []domain.RollupRule
+	err   error
+}
+
+func (m *mockRollupsService) CreateRule(*domain.Repository, rollups.Payload) (*domain.RollupRule, error) {
+	return m.rule, m.err
+}
+func (m *mockRollupsService) ListRules(*domain.Repository) ([]domain.RollupRule, error) {
+	return m.rules, m.err
+}
+func (m *mockRollupsService) GetRule(kallax.ULID, *domain.Repository) (*domain.RollupRule, error) {
+	return m.rule, m.err
+}
+func (m *mockRollupsService) RemoveRule(*domain.RollupRule) error     { return m.err }
+func (m *mockRollupsService) Run(time.Time) ([]rollups.Report, error) { return nil, m.err }
+
+func withRollupRuleMiddle(rule *domain.RollupRule) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if rule != nil {
+				ctx = context.WithValue(ctx, middleware.RollupRuleCtxKey, rule)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func setupRollupsHandlers(s rollups.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Post("/", handler.CreatingRollupRule(s))
+	app.Get("/", handler.ListingRollupRules(s))
+	app.Get("/rule", handler.GettingRollupRule())
+	app.Delete("/rule", handler.RemovingRollupRule(s))
+	return app
+}
+
+func TestCreatingRollupRuleSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockRollupsService{rule: defaultRollupRule}
+	app := setupRollupsHandlers(s, withRepoMiddle(defaultRepo))
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(map[string]interface{}{"afterDays": 30, "resolution": 60}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("resolution", 60).
+		ValueEqual("aggregate", "avg")
+}
+
+func TestCreatingRollupRuleFailBadRequest(t *testing.T) {
+	t.Parallel()
+
+	app := setupRollupsHandlers(&mockRollupsService{}, withRepoMiddle(defaultRepo))
+
+	response := map[string]interface{}{
+		"status":  400.0,
+		"error":   "Bad Request",
+		"message": "resolution must be a positive number of seconds",
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(map[string]interface{}{"afterDays": 30}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().Equal(response)
+}
+
+func TestRollupsHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockRollupsService{rules: []domain.RollupRule{*defaultRollupRule}}
+	app := setupRollupsHandlers(s, withRepoMiddle(defaultRepo), withRollupRuleMiddle(defaultRollupRule))
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.GET("/rule").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("afterDays", 30)
+	e.DELETE("/rule").Expect().Status(http.StatusOK).JSON().Object().ContainsKey("id")
+}
+
+func TestRollupsHandlersFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name        string
+		method      string
+		path        string
+		service     *mockRollupsService
+		middlewares []func(http.Handler) http.Handler
+	}{
+		{"creating missing repo", "POST", "/", &mockRollupsService{}, nil},
+		{"creating err", "POST", "/", &mockRollupsService{err: errors.New("test")}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"listing missing repo", "GET", "/", &mockRollupsService{}, nil},
+		{"listing err", "GET", "/", &mockRollupsService{err: errors.New("test")}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"getting missing rule", "GET", "/rule", &mockRollupsService{}, nil},
+		{"removing missing rule", "DELETE", "/rule", &mockRollupsService{}, nil},
+		{"removing err", "DELETE", "/rule", &mockRollupsService{err: errors.New("test")}, []func(http.Handler) http.Handler{withRollupRuleMiddle(defaultRollupRule)}},
+	}
+
+	payload := map[string]interface{}{"afterDays": 30, "resolution": 60}
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupRollupsHandlers(tc.service, tc.middlewares...))
+			e.Request(tc.method, tc.path).
+				WithJSON(payload).
+				Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
+}
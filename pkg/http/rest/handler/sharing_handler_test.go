@@ -0,0 +1,158 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/sharing"
+)
+
+var defaultShareLink = &domain.ShareLink{
+	ID:        kallax.NewULID(),
+	Name:      "partner",
+	ExpiresAt: time.Now().Add(time.Hour),
+}
+
+type mockSharingService struct {
+	link  *domain.ShareLink
+	links []domain.ShareLink
+	err   error
+}
+
+func (m *mockSharingService) CreateLink(*domain.User, *domain.Repository, sharing.Payload) (*sharing.Link, error) {
+	if m.link == nil {
+		return nil, m.err
+	}
+	return &sharing.Link{ShareLink: *m.link, Token: "shr_abc.def", URL: "http://localhost/repositories/1?share=shr_abc.def"}, m.err
+}
+func (m *mockSharingService) ListLinks(*domain.Repository) ([]domain.ShareLink, error) {
+	return m.links, m.err
+}
+func (m *mockSharingService) GetLink(kallax.ULID, *domain.Repository) (*domain.ShareLink, error) {
+	return m.link, m.err
+}
+func (m *mockSharingService) RevokeLink(*domain.ShareLink) error { return m.err }
+func (m *mockSharingService) AuthorizeLink(string) (*domain.ShareLink, error) {
+	return m.link, m.err
+}
+
+func withShareLinkMiddle(l *domain.ShareLink) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if l != nil {
+				ctx = context.WithValue(ctx, middleware.ShareLinkCtxKey, l)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func setupSharingHandlers(s sharing.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Post("/", handler.CreatingShareLink(s))
+	app.Get("/", handler.ListingShareLinks(s))
+	app.Get("/link", handler.GettingShareLink())
+	app.Delete("/link", handler.RevokingShareLink(s))
+	return app
+}
+
+func TestCreatingShareLinkSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockSharingService{link: defaultShareLink}
+	app := setupSharingHandlers(s, withUserMiddle(defaultUser), withRepoMiddle(defaultRepo))
+
+	e := bastion.Tester(t, app)
+	obj := e.POST("/").
+		WithJSON(map[string]interface{}{"name": "partner", "expiresIn": 3600}).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object()
+	obj.ValueEqual("name", "partner")
+	obj.ValueEqual("token", "shr_abc.def")
+	obj.ContainsKey("url")
+	obj.ContainsKey("expiresAt")
+}
+
+func TestCreatingShareLinkFailBadRequest(t *testing.T) {
+	t.Parallel()
+
+	app := setupSharingHandlers(&mockSharingService{}, withUserMiddle(defaultUser), withRepoMiddle(defaultRepo))
+
+	response := map[string]interface{}{
+		"status":  400.0,
+		"error":   "Bad Request",
+		"message": "expiresIn must be greater than zero",
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(map[string]interface{}{"name": "partner", "expiresIn": -1}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().Equal(response)
+}
+
+func TestSharingHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockSharingService{links: []domain.ShareLink{*defaultShareLink}}
+	app := setupSharingHandlers(s, withRepoMiddle(defaultRepo), withShareLinkMiddle(defaultShareLink))
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.GET("/link").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("name", "partner").NotContainsKey("token")
+	e.DELETE("/link").Expect().Status(http.StatusOK).JSON().Object().ContainsKey("id")
+}
+
+func TestSharingHandlersFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name        string
+		method      string
+		path        string
+		service     *mockSharingService
+		middlewares []func(http.Handler) http.Handler
+	}{
+		{"creating missing user", "POST", "/", &mockSharingService{}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"creating missing repo", "POST", "/", &mockSharingService{}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}},
+		{"creating err", "POST", "/", &mockSharingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser), withRepoMiddle(defaultRepo)}},
+		{"listing missing repo", "GET", "/", &mockSharingService{}, nil},
+		{"listing err", "GET", "/", &mockSharingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"getting missing link", "GET", "/link", &mockSharingService{}, nil},
+		{"revoking missing link", "DELETE", "/link", &mockSharingService{}, nil},
+		{"revoking err", "DELETE", "/link", &mockSharingService{err: errors.New("test")}, []func(http.Handler) http.Handler{withShareLinkMiddle(defaultShareLink)}},
+	}
+
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupSharingHandlers(tc.service, tc.middlewares...))
+			e.Request(tc.method, tc.path).
+				WithJSON(map[string]interface{}{"name": "partner"}).
+				Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
+}
@@ -23,6 +23,15 @@ func isConflictErr(err error) bool {
 	return false
 }
 
+func conflict(w http.ResponseWriter, message string) {
+	httpErr := render.HTTPError{
+		Status:  http.StatusConflict,
+		Error:   http.StatusText(http.StatusConflict),
+		Message: message,
+	}
+	render.JSON.Response(w, http.StatusConflict, httpErr)
+}
+
 // SignUp returns a configured http.Handler with sign-up resources.
 func SignUp(service signup.Service) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -35,12 +44,7 @@ func SignUp(service signup.Service) http.HandlerFunc {
 		u, err := service.EnrollUser(payload)
 		if err != nil {
 			if isConflictErr(err) {
-				httpErr := render.HTTPError{
-					Status:  http.StatusConflict,
-					Error:   http.StatusText(http.StatusConflict),
-					Message: fmt.Sprintf("email '%v' already exists", *payload.Email),
-				}
-				render.JSON.Response(w, http.StatusConflict, httpErr)
+				conflict(w, fmt.Sprintf("email '%v' already exists", *payload.Email))
 				return
 			}
 
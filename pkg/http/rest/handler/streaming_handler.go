@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/streaming"
+)
+
+// HeartbeatInterval is the time between the comments sent to keep idle streams open.
+var HeartbeatInterval = 15 * time.Second
+
+// StreamingCaptures returns a configured http.Handler with streaming captures resources.
+// The events are sent as Server-Sent Events and can be resumed with the Last-Event-ID
+// header or the lastEventId query param. The request is authorized again with the
+// authorization middleware on every heartbeat, closing the stream once its credentials
+// were revoked or expired.
+func StreamingCaptures(service streaming.Service, authorization func(http.Handler) http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			err := errors.New("streaming unsupported by the response writer")
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		filter, lastEventID, err := streamingParams(r)
+		if err != nil {
+			render.JSON.BadRequest(w, errors.Cause(err))
+			return
+		}
+
+		sub := service.Subscribe(repo, filter, lastEventID)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+		for _, e := range sub.Replay {
+			if err := writeEvent(w, e); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(HeartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if !stillAuthorized(authorization, r) {
+					return
+				}
+				if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+					return
+				}
+			case e, ok := <-sub.Events():
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, e); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func streamingParams(r *http.Request) (streaming.Filter, *kallax.ULID, error) {
+	q := r.URL.Query()
+	filter := streaming.Filter{Tags: q["tag"]}
+	if area := q.Get("area"); area != "" {
+		polygon, err := streaming.ParseArea(area)
+		if err != nil {
+			return filter, nil, err
+		}
+		filter.Area = polygon
+	}
+
+	last := r.Header.Get("Last-Event-ID")
+	if last == "" {
+		last = q.Get("lastEventId")
+	}
+	if last == "" {
+		return filter, nil, nil
+	}
+	id, err := kallax.NewULIDFromText(last)
+	if err != nil {
+		return filter, nil, errors.New("invalid last event id")
+	}
+	return filter, &id, nil
+}
+
+func writeEvent(w http.ResponseWriter, e domain.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %v\nevent: %v\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
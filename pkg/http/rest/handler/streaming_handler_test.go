@@ -0,0 +1,168 @@
+package handler_test
+
+import (
+	"bufio"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/streaming"
+)
+
+func init() {
+	// shorten the heartbeat of the streams to test the authorization.
+	handler.HeartbeatInterval = 50 * time.Millisecond
+}
+
+func setupStreamingHandler(s streaming.Service, auth *revocable, m func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Use(m)
+	app.Get("/", handler.StreamingCaptures(s, auth.middleware))
+	return app
+}
+
+// readFrame reads the next event of the stream, skipping the heartbeats.
+func readFrame(t *testing.T, r *bufio.Reader) []string {
+	var lines []string
+	for {
+		line, err := r.ReadString('\n')
+		assert.Nil(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		if line == ": ping" {
+			continue
+		}
+		if line == "" && len(lines) > 0 {
+			return lines
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+}
+
+func TestStreamingCapturesSuccess(t *testing.T) {
+	t.Parallel()
+
+	broker := streaming.NewBroker(10)
+	e1 := domain.NewCaptureEvent(domain.CaptureCreated, domain.Capture{ID: kallax.NewULID(), RepositoryID: defaultRepo.ID})
+	e2 := domain.NewCaptureEvent(domain.CaptureUpdated, domain.Capture{ID: kallax.NewULID(), RepositoryID: defaultRepo.ID})
+	broker.Publish(e1, e2)
+
+	server := httptest.NewServer(setupStreamingHandler(broker, &revocable{}, withRepoMiddle(defaultRepo)))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequest("GET", server.URL, nil)
+	req.Header.Set("Last-Event-ID", e1.ID.String())
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	body := bufio.NewReader(res.Body)
+	frame := readFrame(t, body)
+	assert.Equal(t, "id: "+e2.ID.String(), frame[0])
+	assert.Equal(t, "event: capture.updated", frame[1])
+	assert.Contains(t, frame[2], "data: {")
+
+	e3 := domain.NewCaptureEvent(domain.CaptureRemoved, domain.Capture{ID: kallax.NewULID(), RepositoryID: defaultRepo.ID})
+	broker.Publish(e3)
+	frame = readFrame(t, body)
+	assert.Equal(t, "id: "+e3.ID.String(), frame[0])
+	assert.Equal(t, "event: capture.removed", frame[1])
+}
+
+func TestStreamingCapturesCloseWhenAuthorizationRevoked(t *testing.T) {
+	t.Parallel()
+
+	auth := &revocable{}
+	server := httptest.NewServer(setupStreamingHandler(streaming.NewBroker(0), auth, withRepoMiddle(defaultRepo)))
+	defer server.Close()
+
+	client := &http.Client{Timeout: time.Second}
+	res, err := client.Get(server.URL)
+	assert.Nil(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	body := bufio.NewReader(res.Body)
+	line, err := body.ReadString('\n')
+	assert.Nil(t, err)
+	assert.Equal(t, ": ping\n", line, "the stream is kept open while authorized")
+
+	auth.revoke()
+	_, err = ioutil.ReadAll(body)
+	assert.Nil(t, err, "the stream is closed instead of timing out")
+}
+
+func TestStreamingCapturesBadRequest(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name     string
+		query    string
+		header   string
+		response map[string]interface{}
+	}{
+		{
+			"invalid area",
+			"area=1,2,3",
+			"",
+			map[string]interface{}{
+				"status":  400.0,
+				"error":   "Bad Request",
+				"message": "area must have the form minLat,minLng,maxLat,maxLng",
+			},
+		},
+		{
+			"invalid last event id",
+			"",
+			"abc",
+			map[string]interface{}{
+				"status":  400.0,
+				"error":   "Bad Request",
+				"message": "invalid last event id",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupStreamingHandler(streaming.NewBroker(0), &revocable{}, withRepoMiddle(defaultRepo)))
+			e.GET("/").
+				WithQueryString(tc.query).
+				WithHeader("Last-Event-ID", tc.header).
+				Expect().
+				Status(http.StatusBadRequest).
+				JSON().Object().Equal(tc.response)
+		})
+	}
+}
+
+func TestStreamingCapturesFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	e := bastion.Tester(t, setupStreamingHandler(streaming.NewBroker(0), &revocable{}, withRepoMiddle(nil)))
+	e.GET("/").
+		Expect().
+		Status(http.StatusInternalServerError).
+		JSON().Object().Equal(response)
+}
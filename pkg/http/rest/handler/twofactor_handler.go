@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/ifreddyrondon/bastion/render"
+
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/twofactor"
+)
+
+// renderTwoFactorErr renders the invalid and conflict errors of the two-factor
+// service and falls back to an internal server error.
+func renderTwoFactorErr(w http.ResponseWriter, err error) {
+	switch {
+	case isInvalidErr(err):
+		render.JSON.BadRequest(w, err)
+	case isConflictErr(err):
+		conflict(w, err.Error())
+	default:
+		fmt.Fprintln(os.Stderr, err)
+		render.JSON.InternalServerError(w, err)
+	}
+}
+
+// EnrollingTwoFactor returns a configured http.Handler with two-factor enrollment resources.
+func EnrollingTwoFactor(service twofactor.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		enrollment, err := service.Enroll(u)
+		if err != nil {
+			renderTwoFactorErr(w, err)
+			return
+		}
+
+		render.JSON.Created(w, enrollment)
+	}
+}
+
+// ConfirmingTwoFactor returns a configured http.Handler to enable the two-factor authentication.
+func ConfirmingTwoFactor(service twofactor.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		var payload twofactor.CodePayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		codes, err := service.Confirm(u, payload)
+		if err != nil {
+			renderTwoFactorErr(w, err)
+			return
+		}
+
+		render.JSON.Send(w, codes)
+	}
+}
+
+// DisablingTwoFactor returns a configured http.Handler to disable the two-factor authentication.
+func DisablingTwoFactor(service twofactor.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		var payload twofactor.CodePayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		if err := service.Disable(u, payload); err != nil {
+			renderTwoFactorErr(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// RegeneratingRecoveryCodes returns a configured http.Handler to replace the recovery codes.
+func RegeneratingRecoveryCodes(service twofactor.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		var payload twofactor.CodePayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		codes, err := service.RegenerateRecoveryCodes(u, payload)
+		if err != nil {
+			renderTwoFactorErr(w, err)
+			return
+		}
+
+		render.JSON.Send(w, codes)
+	}
+}
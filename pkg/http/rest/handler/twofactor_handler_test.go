@@ -0,0 +1,95 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/twofactor"
+)
+
+type mockTwoFactorService struct {
+	enrollment *twofactor.Enrollment
+	codes      *twofactor.RecoveryCodesResponse
+	err        error
+}
+
+func (m *mockTwoFactorService) Enroll(*domain.User) (*twofactor.Enrollment, error) {
+	return m.enrollment, m.err
+}
+func (m *mockTwoFactorService) Confirm(*domain.User, twofactor.CodePayload) (*twofactor.RecoveryCodesResponse, error) {
+	return m.codes, m.err
+}
+func (m *mockTwoFactorService) Disable(*domain.User, twofactor.CodePayload) error { return m.err }
+func (m *mockTwoFactorService) RegenerateRecoveryCodes(*domain.User, twofactor.CodePayload) (*twofactor.RecoveryCodesResponse, error) {
+	return m.codes, m.err
+}
+func (m *mockTwoFactorService) CheckCode(*domain.User, string) (bool, error) { return true, m.err }
+
+func setupTwoFactorHandlers(s twofactor.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Post("/", handler.EnrollingTwoFactor(s))
+	app.Post("/confirm", handler.ConfirmingTwoFactor(s))
+	app.Delete("/", handler.DisablingTwoFactor(s))
+	app.Post("/recovery-codes", handler.RegeneratingRecoveryCodes(s))
+	return app
+}
+
+func TestTwoFactorHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockTwoFactorService{
+		enrollment: &twofactor.Enrollment{Secret: "secret", URI: "otpauth://totp/Capture:bob@example.com?secret=secret"},
+		codes:      &twofactor.RecoveryCodesResponse{RecoveryCodes: []string{"aaaaa-bbbbb"}},
+	}
+	app := setupTwoFactorHandlers(s, withUserMiddle(defaultUser))
+	payload := map[string]interface{}{"code": "123456"}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").Expect().Status(http.StatusCreated).JSON().Object().ValueEqual("secret", "secret").ContainsKey("uri")
+	e.POST("/confirm").WithJSON(payload).Expect().Status(http.StatusOK).JSON().Object().Value("recoveryCodes").Array().Length().Equal(1)
+	e.DELETE("/").WithJSON(payload).Expect().Status(http.StatusNoContent)
+	e.POST("/recovery-codes").WithJSON(payload).Expect().Status(http.StatusOK).JSON().Object().ContainsKey("recoveryCodes")
+}
+
+func TestTwoFactorHandlersFail(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name        string
+		method      string
+		path        string
+		payload     map[string]interface{}
+		service     *mockTwoFactorService
+		middlewares []func(http.Handler) http.Handler
+		status      int
+		message     string
+	}{
+		{"enrolling missing user", "POST", "/", nil, &mockTwoFactorService{}, nil, http.StatusInternalServerError, "looks like something went wrong"},
+		{"enrolling already enabled", "POST", "/", nil, &mockTwoFactorService{err: conflictErr("two-factor authentication already enabled")}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, http.StatusConflict, "two-factor authentication already enabled"},
+		{"confirming missing code", "POST", "/confirm", map[string]interface{}{}, &mockTwoFactorService{}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, http.StatusBadRequest, "code must not be blank"},
+		{"confirming invalid code", "POST", "/confirm", map[string]interface{}{"code": "123456"}, &mockTwoFactorService{err: invalidErr("invalid two-factor code")}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, http.StatusBadRequest, "invalid two-factor code"},
+		{"disabling not enabled", "DELETE", "/", map[string]interface{}{"code": "123456"}, &mockTwoFactorService{err: conflictErr("two-factor authentication not enabled")}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, http.StatusConflict, "two-factor authentication not enabled"},
+		{"regenerating err", "POST", "/recovery-codes", map[string]interface{}{"code": "123456"}, &mockTwoFactorService{err: errors.New("test")}, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, http.StatusInternalServerError, "looks like something went wrong"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupTwoFactorHandlers(tc.service, tc.middlewares...))
+			req := e.Request(tc.method, tc.path)
+			if tc.payload != nil {
+				req = req.WithJSON(tc.payload)
+			}
+			req.Expect().
+				Status(tc.status).
+				JSON().Object().ValueEqual("message", tc.message)
+		})
+	}
+}
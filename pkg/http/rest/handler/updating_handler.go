@@ -7,7 +7,9 @@ import (
 
 	"github.com/ifreddyrondon/bastion/binder"
 	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
 
+	"github.com/ifreddyrondon/capture/pkg/domain"
 	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
 	"github.com/ifreddyrondon/capture/pkg/updating"
 )
@@ -15,6 +17,12 @@ import (
 // UpdatingCapture returns a configured http.Handler with updating capture resources.
 func UpdatingCapture(service updating.CaptureService) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
 		capt, err := middleware.GetCapture(r.Context())
 		if err != nil {
 			fmt.Fprintln(os.Stderr, err)
@@ -28,8 +36,12 @@ func UpdatingCapture(service updating.CaptureService) http.HandlerFunc {
 			return
 		}
 
-		err = service.Update(data, capt)
+		err = service.Update(repo, data, capt)
 		if err != nil {
+			if isQuotaExceeded(err) {
+				quotaExceeded(w, errors.Cause(err).Error())
+				return
+			}
 			fmt.Fprintln(os.Stderr, err)
 			render.JSON.InternalServerError(w, err)
 			return
@@ -38,3 +50,102 @@ func UpdatingCapture(service updating.CaptureService) http.HandlerFunc {
 		render.JSON.Send(w, capt)
 	}
 }
+
+// UpdatingRepo returns a configured http.Handler with updating repo resources.
+func UpdatingRepo(service updating.RepoService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		repo, err := middleware.GetRepo(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		var data updating.Repo
+		if err = binder.JSON.FromReq(r, &data); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		visibility := repo.Visibility
+		if err = service.Update(data, repo); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		auditVisibility(r, visibility, repo.Visibility)
+		render.JSON.Send(w, repo)
+	}
+}
+
+// UpdatingUser returns a configured http.Handler with updating user profile resources.
+func UpdatingUser(service updating.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		var data updating.User
+		if err = binder.JSON.FromReq(r, &data); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		if err = service.Update(data, u); err != nil {
+			if isConflictErr(err) {
+				conflict(w, fmt.Sprintf("email '%v' already exists", *data.Email))
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		render.JSON.Send(w, u)
+	}
+}
+
+// ChangingPassword returns a configured http.Handler to change the password of the user.
+func ChangingPassword(service updating.UserService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		u, err := middleware.GetUser(r.Context())
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		var data updating.Password
+		if err = binder.JSON.FromReq(r, &data); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		if err = service.ChangePassword(data, u); err != nil {
+			if isInvalidErr(err) {
+				render.JSON.BadRequest(w, err)
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// auditVisibility completes the audit entry of a repository update with the visibility
+// change, updates keeping the visibility are not audited.
+func auditVisibility(r *http.Request, from, to domain.Visibility) {
+	if from == to {
+		middleware.SkipAudit(r.Context())
+		return
+	}
+	middleware.AddAuditDetail(r.Context(), "from", from)
+	middleware.AddAuditDetail(r.Context(), "to", to)
+}
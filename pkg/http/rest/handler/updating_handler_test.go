@@ -17,12 +17,13 @@ type mockUpdatingCaptureService struct {
 	err error
 }
 
-func (m *mockUpdatingCaptureService) Update(updating.Capture, *domain.Capture) error {
+func (m *mockUpdatingCaptureService) Update(*domain.Repository, updating.Capture, *domain.Capture) error {
 	return m.err
 }
 
 func setupUpdatingCaptureHandler(s updating.CaptureService, m func(http.Handler) http.Handler) *bastion.Bastion {
 	app := bastion.New()
+	app.Use(withRepoMiddle(defaultRepo))
 	app.Use(m)
 	app.Put("/", handler.UpdatingCapture(s))
 	return app
@@ -115,6 +116,37 @@ func TestUpdatingCaptureFailsUpdating(t *testing.T) {
 		JSON().Object().Equal(response)
 }
 
+func TestUpdatingCaptureQuotaExceeded(t *testing.T) {
+	t.Parallel()
+	s := &mockUpdatingCaptureService{err: quotaErr("repository quota exceeded, it allows up to 10 bytes")}
+	app := setupUpdatingCaptureHandler(s, withCaptureMiddle(defaultCapture))
+
+	body := map[string]interface{}{
+		"location": map[string]float64{"lat": 10, "lng": 1, "elevation": 1},
+	}
+	response := map[string]interface{}{
+		"status":  403.0,
+		"error":   "Forbidden",
+		"message": "repository quota exceeded, it allows up to 10 bytes",
+	}
+
+	e := bastion.Tester(t, app)
+	e.PUT("/").WithJSON(body).Expect().
+		Status(http.StatusForbidden).
+		JSON().Object().Equal(response)
+}
+
+func TestUpdatingCaptureFailsMissingRepo(t *testing.T) {
+	t.Parallel()
+	app := bastion.New()
+	app.Use(withRepoMiddle(nil), withCaptureMiddle(defaultCapture))
+	app.Put("/", handler.UpdatingCapture(&mockUpdatingCaptureService{}))
+
+	e := bastion.Tester(t, app)
+	e.PUT("/").WithJSON(map[string]interface{}{}).Expect().
+		Status(http.StatusInternalServerError)
+}
+
 func TestUpdatingCaptureFailBadRequest(t *testing.T) {
 	t.Parallel()
 	tt := []struct {
@@ -159,3 +191,177 @@ func TestUpdatingCaptureFailBadRequest(t *testing.T) {
 		})
 	}
 }
+
+type mockUpdatingRepoService struct {
+	visibility domain.Visibility
+	err        error
+}
+
+func (m *mockUpdatingRepoService) Update(_ updating.Repo, r *domain.Repository) error {
+	if m.visibility != "" {
+		r.Visibility = m.visibility
+	}
+	return m.err
+}
+
+func setupUpdatingRepoHandler(s updating.RepoService, m func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Use(m)
+	app.Put("/", handler.UpdatingRepo(s))
+	return app
+}
+
+func TestUpdatingRepoSuccess(t *testing.T) {
+	t.Parallel()
+
+	app := setupUpdatingRepoHandler(&mockUpdatingRepoService{}, withRepoMiddle(defaultRepo))
+	e := bastion.Tester(t, app)
+
+	e.PUT("/").WithJSON(map[string]interface{}{"visibility": "public"}).Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ContainsKey("name").ValueEqual("name", defaultRepo.Name).
+		ContainsKey("visibility").ValueEqual("visibility", "public")
+}
+
+func TestUpdatingRepoFailBadRequest(t *testing.T) {
+	t.Parallel()
+
+	response := map[string]interface{}{
+		"status":  400.0,
+		"error":   "Bad Request",
+		"message": "name must not be blank",
+	}
+
+	app := setupUpdatingRepoHandler(&mockUpdatingRepoService{}, withRepoMiddle(defaultRepo))
+	e := bastion.Tester(t, app)
+	e.PUT("/").WithJSON(map[string]interface{}{"name": ""}).Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().Equal(response)
+}
+
+func TestUpdatingRepoFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		service *mockUpdatingRepoService
+		middle  func(http.Handler) http.Handler
+	}{
+		{"missing repo", &mockUpdatingRepoService{}, withRepoMiddle(nil)},
+		{"service err", &mockUpdatingRepoService{err: errors.New("test")}, withRepoMiddle(defaultRepo)},
+	}
+
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupUpdatingRepoHandler(tc.service, tc.middle))
+			e.PUT("/").WithJSON(map[string]interface{}{}).Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
+}
+
+type mockUpdatingUserService struct {
+	err error
+}
+
+func (m *mockUpdatingUserService) Update(updating.User, *domain.User) error { return m.err }
+func (m *mockUpdatingUserService) ChangePassword(updating.Password, *domain.User) error {
+	return m.err
+}
+
+type invalidPasswordErr string
+
+func (i invalidPasswordErr) Error() string   { return string(i) }
+func (i invalidPasswordErr) IsInvalid() bool { return true }
+
+func setupUpdatingUserHandlers(s updating.UserService, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Patch("/", handler.UpdatingUser(s))
+	app.Put("/password", handler.ChangingPassword(s))
+	return app
+}
+
+func TestUpdatingUserSuccess(t *testing.T) {
+	t.Parallel()
+
+	app := setupUpdatingUserHandlers(&mockUpdatingUserService{}, withUserMiddle(defaultUser))
+	e := bastion.Tester(t, app)
+	e.PATCH("/").
+		WithJSON(map[string]interface{}{"name": "test"}).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().
+		ValueEqual("email", "test@example.com").
+		NotContainsKey("password")
+	e.PUT("/password").
+		WithJSON(map[string]interface{}{"currentPassword": "1234", "newPassword": "abcd"}).
+		Expect().
+		Status(http.StatusNoContent)
+}
+
+func TestUpdatingUserFailures(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name     string
+		method   string
+		path     string
+		service  *mockUpdatingUserService
+		payload  map[string]interface{}
+		status   int
+		response map[string]interface{}
+	}{
+		{
+			"invalid email", "PATCH", "/", &mockUpdatingUserService{}, map[string]interface{}{"email": "test"},
+			http.StatusBadRequest, map[string]interface{}{"status": 400.0, "error": "Bad Request", "message": "invalid email"},
+		},
+		{
+			"email already taken", "PATCH", "/", &mockUpdatingUserService{err: conflictErr("test")}, map[string]interface{}{"email": "taken@example.com"},
+			http.StatusConflict, map[string]interface{}{"status": 409.0, "error": "Conflict", "message": "email 'taken@example.com' already exists"},
+		},
+		{
+			"invalid current password", "PUT", "/password", &mockUpdatingUserService{err: invalidPasswordErr("invalid current password")},
+			map[string]interface{}{"currentPassword": "1234", "newPassword": "abcd"},
+			http.StatusBadRequest, map[string]interface{}{"status": 400.0, "error": "Bad Request", "message": "invalid current password"},
+		},
+		{
+			"update err", "PATCH", "/", &mockUpdatingUserService{err: errors.New("test")}, map[string]interface{}{"name": "test"},
+			http.StatusInternalServerError, map[string]interface{}{"status": 500.0, "error": "Internal Server Error", "message": "looks like something went wrong"},
+		},
+		{
+			"change password err", "PUT", "/password", &mockUpdatingUserService{err: errors.New("test")},
+			map[string]interface{}{"currentPassword": "1234", "newPassword": "abcd"},
+			http.StatusInternalServerError, map[string]interface{}{"status": 500.0, "error": "Internal Server Error", "message": "looks like something went wrong"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupUpdatingUserHandlers(tc.service, withUserMiddle(defaultUser)))
+			e.Request(tc.method, tc.path).
+				WithJSON(tc.payload).
+				Expect().
+				Status(tc.status).
+				JSON().Object().Equal(tc.response)
+		})
+	}
+}
+
+func TestUpdatingUserFailMissingUser(t *testing.T) {
+	t.Parallel()
+
+	e := bastion.Tester(t, setupUpdatingUserHandlers(&mockUpdatingUserService{}))
+	e.PATCH("/").WithJSON(map[string]interface{}{}).Expect().Status(http.StatusInternalServerError)
+	e.PUT("/password").WithJSON(map[string]interface{}{}).Expect().Status(http.StatusInternalServerError)
+}
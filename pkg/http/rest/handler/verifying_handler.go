@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/ifreddyrondon/bastion/render"
+
+	"github.com/ifreddyrondon/capture/pkg/verifying"
+)
+
+// VerifyingEmail marks as verified the email of the owner of a verification token.
+func VerifyingEmail(service verifying.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload verifying.TokenPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		if err := service.VerifyEmail(payload); err != nil {
+			if isInvalidErr(err) {
+				render.JSON.BadRequest(w, err)
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ResendingVerification emails a new verification link. The response is the same
+// whether the email is registered or not.
+func ResendingVerification(service verifying.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload verifying.EmailPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		if err := service.ResendVerification(payload); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ForgettingPassword emails a password reset link. The response is the same
+// whether the email is registered or not.
+func ForgettingPassword(service verifying.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload verifying.EmailPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		if err := service.ForgotPassword(payload); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ResettingPassword sets a new password to the owner of a reset token.
+func ResettingPassword(service verifying.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload verifying.ResetPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		if err := service.ResetPassword(payload); err != nil {
+			if isInvalidErr(err) {
+				render.JSON.BadRequest(w, err)
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ConfirmingEmailChange replaces the email of the owner of a confirmation token with its pending email.
+func ConfirmingEmailChange(service verifying.Service) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var payload verifying.TokenPayload
+		if err := binder.JSON.FromReq(r, &payload); err != nil {
+			render.JSON.BadRequest(w, err)
+			return
+		}
+
+		if err := service.ConfirmEmailChange(payload); err != nil {
+			if isInvalidErr(err) {
+				render.JSON.BadRequest(w, err)
+				return
+			}
+			if isConflictErr(err) {
+				conflict(w, err.Error())
+				return
+			}
+			fmt.Fprintln(os.Stderr, err)
+			render.JSON.InternalServerError(w, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,140 @@
+package handler_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/verifying"
+)
+
+type invalidTokenErr string
+
+func (i invalidTokenErr) Error() string   { return string(i) }
+func (i invalidTokenErr) IsInvalid() bool { return true }
+
+type mockVerifyingService struct {
+	err error
+}
+
+func (m *mockVerifyingService) SendVerification(*domain.User) error             { return m.err }
+func (m *mockVerifyingService) ResendVerification(verifying.EmailPayload) error { return m.err }
+func (m *mockVerifyingService) VerifyEmail(verifying.TokenPayload) error        { return m.err }
+func (m *mockVerifyingService) ForgotPassword(verifying.EmailPayload) error     { return m.err }
+func (m *mockVerifyingService) ResetPassword(verifying.ResetPayload) error      { return m.err }
+func (m *mockVerifyingService) SendEmailChange(*domain.User) error              { return m.err }
+func (m *mockVerifyingService) ConfirmEmailChange(verifying.TokenPayload) error { return m.err }
+
+func setupVerifyingHandlers(s verifying.Service) *bastion.Bastion {
+	app := bastion.New()
+	app.Post("/verify-email", handler.VerifyingEmail(s))
+	app.Post("/verify-email/resend", handler.ResendingVerification(s))
+	app.Post("/forgot-password", handler.ForgettingPassword(s))
+	app.Post("/reset-password", handler.ResettingPassword(s))
+	app.Post("/confirm-email", handler.ConfirmingEmailChange(s))
+	return app
+}
+
+func TestVerifyingHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	e := bastion.Tester(t, setupVerifyingHandlers(&mockVerifyingService{}))
+	e.POST("/verify-email").WithJSON(map[string]interface{}{"token": "abc"}).
+		Expect().Status(http.StatusNoContent)
+	e.POST("/verify-email/resend").WithJSON(map[string]interface{}{"email": "test@example.com"}).
+		Expect().Status(http.StatusNoContent)
+	e.POST("/forgot-password").WithJSON(map[string]interface{}{"email": "test@example.com"}).
+		Expect().Status(http.StatusNoContent)
+	e.POST("/reset-password").WithJSON(map[string]interface{}{"token": "abc", "password": "1234"}).
+		Expect().Status(http.StatusNoContent)
+	e.POST("/confirm-email").WithJSON(map[string]interface{}{"token": "abc"}).
+		Expect().Status(http.StatusNoContent)
+}
+
+func TestVerifyingHandlersFailBadRequest(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		path    string
+		service *mockVerifyingService
+		payload map[string]interface{}
+		message string
+	}{
+		{"verify missing token", "/verify-email", &mockVerifyingService{}, map[string]interface{}{}, "token must not be blank"},
+		{"verify invalid token", "/verify-email", &mockVerifyingService{err: invalidTokenErr("invalid or expired token")}, map[string]interface{}{"token": "abc"}, "invalid or expired token"},
+		{"resend invalid email", "/verify-email/resend", &mockVerifyingService{}, map[string]interface{}{"email": "test"}, "invalid email"},
+		{"forgot missing email", "/forgot-password", &mockVerifyingService{}, map[string]interface{}{}, "email must not be blank"},
+		{"reset short password", "/reset-password", &mockVerifyingService{}, map[string]interface{}{"token": "abc", "password": "1"}, "password must have at least four characters"},
+		{"confirm invalid token", "/confirm-email", &mockVerifyingService{err: invalidTokenErr("invalid or expired token")}, map[string]interface{}{"token": "abc"}, "invalid or expired token"},
+		{"reset invalid token", "/reset-password", &mockVerifyingService{err: invalidTokenErr("invalid or expired token")}, map[string]interface{}{"token": "abc", "password": "1234"}, "invalid or expired token"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			response := map[string]interface{}{
+				"status":  400.0,
+				"error":   "Bad Request",
+				"message": tc.message,
+			}
+			e := bastion.Tester(t, setupVerifyingHandlers(tc.service))
+			e.POST(tc.path).WithJSON(tc.payload).
+				Expect().
+				Status(http.StatusBadRequest).
+				JSON().Object().Equal(response)
+		})
+	}
+}
+
+func TestVerifyingHandlersFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		path    string
+		payload map[string]interface{}
+	}{
+		{"verify", "/verify-email", map[string]interface{}{"token": "abc"}},
+		{"resend", "/verify-email/resend", map[string]interface{}{"email": "test@example.com"}},
+		{"forgot", "/forgot-password", map[string]interface{}{"email": "test@example.com"}},
+		{"reset", "/reset-password", map[string]interface{}{"token": "abc", "password": "1234"}},
+		{"confirm", "/confirm-email", map[string]interface{}{"token": "abc"}},
+	}
+
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupVerifyingHandlers(&mockVerifyingService{err: errors.New("test")}))
+			e.POST(tc.path).WithJSON(tc.payload).
+				Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
+}
+
+func TestConfirmingEmailChangeFailConflict(t *testing.T) {
+	t.Parallel()
+
+	s := &mockVerifyingService{err: conflictErr("email test@example.com already exist")}
+	response := map[string]interface{}{
+		"status":  409.0,
+		"error":   "Conflict",
+		"message": "email test@example.com already exist",
+	}
+
+	e := bastion.Tester(t, setupVerifyingHandlers(s))
+	e.POST("/confirm-email").WithJSON(map[string]interface{}{"token": "abc"}).
+		Expect().
+		Status(http.StatusConflict).
+		JSON().Object().Equal(response)
+}
@@ -0,0 +1,166 @@
+package handler_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	bastionMiddleware "github.com/ifreddyrondon/bastion/middleware"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/webhooks"
+)
+
+var defaultWebhook = &domain.Webhook{
+	ID:     kallax.NewULID(),
+	URL:    "http://localhost/hook",
+	Events: []domain.EventType{domain.CaptureCreated},
+}
+
+type mockWebhooksService struct {
+	webhook  *domain.Webhook
+	webhooks []domain.Webhook
+	err      error
+}
+
+func (m *mockWebhooksService) CreateWebhook(*domain.Repository, webhooks.Payload) (*domain.Webhook, error) {
+	return m.webhook, m.err
+}
+func (m *mockWebhooksService) ListWebhooks(*domain.Repository) ([]domain.Webhook, error) {
+	return m.webhooks, m.err
+}
+func (m *mockWebhooksService) GetWebhook(kallax.ULID, *domain.Repository) (*domain.Webhook, error) {
+	return m.webhook, m.err
+}
+func (m *mockWebhooksService) RemoveWebhook(*domain.Webhook) error { return m.err }
+func (m *mockWebhooksService) ListDeliveries(h *domain.Webhook, l *listing.Listing) (*webhooks.ListDeliveryResponse, error) {
+	return &webhooks.ListDeliveryResponse{Results: []domain.WebhookDelivery{}, Listing: l}, m.err
+}
+
+func withWebhookMiddle(h *domain.Webhook) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if h != nil {
+				ctx = context.WithValue(ctx, middleware.WebhookCtxKey, h)
+			}
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func setupWebhooksHandlers(s webhooks.Service, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	for _, m := range middlewares {
+		app.Use(m)
+	}
+	app.Post("/", handler.CreatingWebhook(s))
+	app.Get("/", handler.ListingWebhooks(s))
+	app.Get("/webhook", handler.GettingWebhook())
+	app.Delete("/webhook", handler.RemovingWebhook(s))
+	app.With(bastionMiddleware.Listing()).Get("/webhook/deliveries", handler.ListingWebhookDeliveries(s))
+	return app
+}
+
+func TestCreatingWebhookSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockWebhooksService{webhook: defaultWebhook}
+	app := setupWebhooksHandlers(s, withRepoMiddle(defaultRepo))
+
+	payload := map[string]interface{}{
+		"url":    "http://localhost/hook",
+		"events": []string{"capture.created"},
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(payload).
+		Expect().
+		Status(http.StatusCreated).
+		JSON().Object().
+		ValueEqual("url", "http://localhost/hook")
+}
+
+func TestCreatingWebhookFailBadRequest(t *testing.T) {
+	t.Parallel()
+
+	app := setupWebhooksHandlers(&mockWebhooksService{}, withRepoMiddle(defaultRepo))
+
+	response := map[string]interface{}{
+		"status":  400.0,
+		"error":   "Bad Request",
+		"message": "events must contain at least one event",
+	}
+
+	e := bastion.Tester(t, app)
+	e.POST("/").
+		WithJSON(map[string]interface{}{"url": "http://localhost/hook"}).
+		Expect().
+		Status(http.StatusBadRequest).
+		JSON().Object().Equal(response)
+}
+
+func TestWebhooksHandlersSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockWebhooksService{webhooks: []domain.Webhook{*defaultWebhook}}
+	app := setupWebhooksHandlers(s, withRepoMiddle(defaultRepo), withWebhookMiddle(defaultWebhook))
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusOK).JSON().Array().Length().Equal(1)
+	e.GET("/webhook").Expect().Status(http.StatusOK).JSON().Object().ValueEqual("url", "http://localhost/hook")
+	e.DELETE("/webhook").Expect().Status(http.StatusOK).JSON().Object().ContainsKey("id")
+	e.GET("/webhook/deliveries").Expect().Status(http.StatusOK).JSON().Object().ContainsKey("results").ContainsKey("listing")
+}
+
+func TestWebhooksHandlersFailInternalServer(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name        string
+		method      string
+		path        string
+		service     *mockWebhooksService
+		middlewares []func(http.Handler) http.Handler
+	}{
+		{"creating missing repo", "POST", "/", &mockWebhooksService{}, nil},
+		{"creating err", "POST", "/", &mockWebhooksService{err: errors.New("test")}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"listing missing repo", "GET", "/", &mockWebhooksService{}, nil},
+		{"listing err", "GET", "/", &mockWebhooksService{err: errors.New("test")}, []func(http.Handler) http.Handler{withRepoMiddle(defaultRepo)}},
+		{"getting missing webhook", "GET", "/webhook", &mockWebhooksService{}, nil},
+		{"removing missing webhook", "DELETE", "/webhook", &mockWebhooksService{}, nil},
+		{"removing err", "DELETE", "/webhook", &mockWebhooksService{err: errors.New("test")}, []func(http.Handler) http.Handler{withWebhookMiddle(defaultWebhook)}},
+		{"deliveries missing webhook", "GET", "/webhook/deliveries", &mockWebhooksService{}, nil},
+		{"deliveries err", "GET", "/webhook/deliveries", &mockWebhooksService{err: errors.New("test")}, []func(http.Handler) http.Handler{withWebhookMiddle(defaultWebhook)}},
+	}
+
+	payload := map[string]interface{}{
+		"url":    "http://localhost/hook",
+		"events": []string{"capture.created"},
+	}
+	response := map[string]interface{}{
+		"status":  500.0,
+		"error":   "Internal Server Error",
+		"message": "looks like something went wrong",
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupWebhooksHandlers(tc.service, tc.middlewares...))
+			e.Request(tc.method, tc.path).
+				WithJSON(payload).
+				Expect().
+				Status(http.StatusInternalServerError).
+				JSON().Object().Equal(response)
+		})
+	}
+}
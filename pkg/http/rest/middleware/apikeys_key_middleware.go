@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+var (
+	// APIKeyCtxKey is the context.Context key to store the API key for a request.
+	APIKeyCtxKey = &contextKey{"APIKey"}
+)
+var (
+	errMissingCtxAPIKey = errors.New("api key not found in context")
+	errWrongAPIKeyValue = errors.New("api key value set incorrectly in context")
+	errMissingAPIKey    = errors.New("not found api key")
+	errInvalidAPIKeyID  = errors.New("invalid api key id")
+)
+
+func withAPIKey(ctx context.Context, k *domain.APIKey) context.Context {
+	return context.WithValue(ctx, APIKeyCtxKey, k)
+}
+
+// GetAPIKey returns the API key assigned to the context, or error if there
+// is any error or there isn't an API key.
+func GetAPIKey(ctx context.Context) (*domain.APIKey, error) {
+	tmp := ctx.Value(APIKeyCtxKey)
+	if tmp == nil {
+		return nil, errMissingCtxAPIKey
+	}
+	k, ok := tmp.(*domain.APIKey)
+	if !ok {
+		return nil, errWrongAPIKeyValue
+	}
+	return k, nil
+}
+
+func APIKeyCtx(service apikeys.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			keyID := chi.URLParam(r, "keyId")
+			repo, err := GetRepo(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			id, err := kallax.NewULIDFromText(keyID)
+			if err != nil {
+				render.JSON.BadRequest(w, errInvalidAPIKeyID)
+				return
+			}
+
+			k, err := service.GetKey(id, repo)
+			if err != nil {
+				if isNotFound(err) {
+					render.JSON.NotFound(w, errMissingAPIKey)
+					return
+				}
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			ctx := withAPIKey(r.Context(), k)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,130 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+type mockAPIKeysService struct {
+	key *domain.APIKey
+	err error
+}
+
+func (m *mockAPIKeysService) CreateKey(*domain.User, *domain.Repository, apikeys.Payload) (*apikeys.Key, error) {
+	return nil, m.err
+}
+func (m *mockAPIKeysService) ListKeys(*domain.Repository) ([]domain.APIKey, error) {
+	return nil, m.err
+}
+func (m *mockAPIKeysService) GetKey(kallax.ULID, *domain.Repository) (*domain.APIKey, error) {
+	return m.key, m.err
+}
+func (m *mockAPIKeysService) RevokeKey(*domain.APIKey) error { return m.err }
+
+func setupAPIKeyCtx(service apikeys.Service, getRepo func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Route("/{keyId}", func(r chi.Router) {
+		r.Use(getRepo)
+		r.Use(middleware.APIKeyCtx(service))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			if _, err := middleware.GetAPIKey(r.Context()); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			handler(w, r)
+		})
+	})
+	return app
+}
+
+func TestAPIKeyCtxSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAPIKeysService{key: &domain.APIKey{}}
+	app := setupAPIKeyCtx(s, withRepoMiddle(defaultRepo))
+	e := bastion.Tester(t, app)
+	e.GET("/0167c8a5-d308-8692-809d-b1ad4a2d9562").
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestAPIKeyCtxFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name     string
+		service  *mockAPIKeysService
+		getRepo  func(http.Handler) http.Handler
+		id       string
+		status   int
+		response map[string]interface{}
+	}{
+		{
+			name:    "missing repo",
+			service: &mockAPIKeysService{},
+			getRepo: withRepoMiddle(nil),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusInternalServerError,
+			response: map[string]interface{}{
+				"status":  500.0,
+				"error":   "Internal Server Error",
+				"message": "looks like something went wrong",
+			},
+		},
+		{
+			name:    "invalid id",
+			service: &mockAPIKeysService{},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "abc",
+			status:  http.StatusBadRequest,
+			response: map[string]interface{}{
+				"status":  400.0,
+				"error":   "Bad Request",
+				"message": "invalid api key id",
+			},
+		},
+		{
+			name:    "not found",
+			service: &mockAPIKeysService{err: notFound("test")},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusNotFound,
+			response: map[string]interface{}{
+				"status":  404.0,
+				"error":   "Not Found",
+				"message": "not found api key",
+			},
+		},
+		{
+			name:    "service err",
+			service: &mockAPIKeysService{err: errors.New("test")},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusInternalServerError,
+			response: map[string]interface{}{
+				"status":  500.0,
+				"error":   "Internal Server Error",
+				"message": "looks like something went wrong",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupAPIKeyCtx(tc.service, tc.getRepo))
+			e.GET("/" + tc.id).
+				Expect().
+				Status(tc.status).
+				JSON().Object().Equal(tc.response)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/auditing"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+var (
+	// AuditCtxKey is the context.Context key to store the audit entry of a request.
+	AuditCtxKey = &contextKey{"AuditEntry"}
+)
+
+// auditRecord holds the entry of an audited request until it's recorded.
+type auditRecord struct {
+	entry *domain.AuditEntry
+	skip  bool
+}
+
+func getAuditRecord(ctx context.Context) (*auditRecord, bool) {
+	rec, ok := ctx.Value(AuditCtxKey).(*auditRecord)
+	return rec, ok
+}
+
+// SetAuditTarget sets the target of the audit entry of a request, as the resources
+// created by the request. Repository targets bind the entry to the repository too. It's
+// a no-op for requests not audited.
+func SetAuditTarget(ctx context.Context, targetType, targetID string) {
+	rec, ok := getAuditRecord(ctx)
+	if !ok {
+		return
+	}
+	rec.entry.TargetType, rec.entry.TargetID = targetType, targetID
+	if targetType != domain.RepositoryTarget {
+		return
+	}
+	if id, err := kallax.NewULIDFromText(targetID); err == nil {
+		rec.entry.RepositoryID = &id
+	}
+}
+
+// SetAuditRepo binds the audit entry of a request to a repository, so its owner could
+// review it. It's a no-op for requests not audited.
+func SetAuditRepo(ctx context.Context, id kallax.ULID) {
+	if rec, ok := getAuditRecord(ctx); ok {
+		rec.entry.RepositoryID = &id
+	}
+}
+
+// SetAuditActor sets the user performing the action of the audit entry of a request,
+// as the user of a login. It's a no-op for requests not audited.
+func SetAuditActor(ctx context.Context, id kallax.ULID) {
+	if rec, ok := getAuditRecord(ctx); ok {
+		rec.entry.ActorID = &id
+	}
+}
+
+// AddAuditDetail adds a detail of the action to the audit entry of a request. It's a
+// no-op for requests not audited.
+func AddAuditDetail(ctx context.Context, key string, value interface{}) {
+	if rec, ok := getAuditRecord(ctx); ok {
+		rec.entry.Detail(key, value)
+	}
+}
+
+// SkipAudit discards the audit entry of a request, when the action didn't happen.
+func SkipAudit(ctx context.Context) {
+	if rec, ok := getAuditRecord(ctx); ok {
+		rec.skip = true
+	}
+}
+
+// auditWriter keeps the status of the response of an audited request.
+type auditWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *auditWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *auditWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// succeeded returns true when the response is not an error.
+func (w *auditWriter) succeeded() bool {
+	return w.status < http.StatusBadRequest
+}
+
+// newAuditEntry returns the entry of an action with the user, impersonator, ip and user
+// agent of the request. The target is the most specific resource found in the context.
+func newAuditEntry(r *http.Request, action domain.AuditAction) *domain.AuditEntry {
+	ctx := r.Context()
+	a := domain.Actor{IP: ClientIP(r), UserAgent: r.UserAgent()}
+	if u, err := GetUser(ctx); err == nil {
+		a.User = u
+	}
+	if id, err := GetImpersonator(ctx); err == nil {
+		a.Impersonator = id
+	}
+	e := domain.NewAuditEntry(a, action, "", "")
+	if org, err := GetOrganization(ctx); err == nil {
+		e.TargetType, e.TargetID = domain.OrganizationTarget, org.ID.String()
+	}
+	if repo, err := GetRepo(ctx); err == nil {
+		e.RepositoryID = &repo.ID
+		e.TargetType, e.TargetID = domain.RepositoryTarget, repo.ID.String()
+	}
+	if c, err := GetCapture(ctx); err == nil {
+		e.TargetType, e.TargetID = domain.CaptureTarget, c.ID.String()
+	}
+	if c, err := GetCollaborator(ctx); err == nil {
+		e.TargetType, e.TargetID = domain.CollaboratorTarget, c.ID.String()
+	}
+	if k, err := GetAPIKey(ctx); err == nil {
+		e.TargetType, e.TargetID = domain.APIKeyTarget, k.ID.String()
+	}
+	if l, err := GetShareLink(ctx); err == nil {
+		e.TargetType, e.TargetID = domain.ShareLinkTarget, l.ID.String()
+	}
+	return e
+}
+
+// Audit records the action of the requests into the audit trail once they succeed. The
+// entry is bound to the user and resources of the context, so it must be used after
+// their middlewares. Handlers could complete it with what is known only after performing
+// the action, as the id of a created resource. The requests don't fail when the entry
+// can't be recorded, the action already happened.
+func Audit(service auditing.Service, action domain.AuditAction) func(next http.Handler) http.Handler {
+	return AuditAttempt(service, action, "")
+}
+
+// AuditAttempt records the action of the requests into the audit trail as Audit, the
+// requests rejected by the client input are recorded with the failure action. Server
+// errors are not recorded.
+func AuditAttempt(service auditing.Service, success, failure domain.AuditAction) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			rec := &auditRecord{entry: newAuditEntry(r, success)}
+			ww := &auditWriter{ResponseWriter: w}
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), AuditCtxKey, rec)))
+
+			if rec.skip {
+				return
+			}
+			if !ww.succeeded() {
+				if failure == "" || ww.status >= http.StatusInternalServerError {
+					return
+				}
+				rec.entry.Action = failure
+			}
+			if err := service.Record(rec.entry); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,157 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion"
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/auditing"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+type mockAuditingService struct {
+	entries []*domain.AuditEntry
+	err     error
+}
+
+func (m *mockAuditingService) Record(e *domain.AuditEntry) error {
+	m.entries = append(m.entries, e)
+	return m.err
+}
+func (m *mockAuditingService) ListEntries(*listing.Listing, *kallax.ULID) (*auditing.ListEntryResponse, error) {
+	return nil, m.err
+}
+func (m *mockAuditingService) ListRepoEntries(*domain.Repository, *listing.Listing, *kallax.ULID) (*auditing.ListEntryResponse, error) {
+	return nil, m.err
+}
+
+func TestAuditSucceeded(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuditingService{}
+	app := bastion.New()
+	app.Use(withUserMiddle(defaultUser))
+	app.Use(withRepoMiddle(defaultRepo))
+	app.With(middleware.Audit(s, domain.RepoVisibilityChanged)).Put("/", func(w http.ResponseWriter, r *http.Request) {
+		middleware.AddAuditDetail(r.Context(), "to", "public")
+		render.JSON.Send(w, "ok")
+	})
+
+	e := bastion.Tester(t, app)
+	e.PUT("/").WithHeader("User-Agent", "test").Expect().Status(http.StatusOK)
+
+	assert.Len(t, s.entries, 1)
+	entry := s.entries[0]
+	assert.Equal(t, domain.RepoVisibilityChanged, entry.Action)
+	assert.Equal(t, defaultUser.ID, *entry.ActorID)
+	assert.Equal(t, "test", entry.UserAgent)
+	assert.Equal(t, domain.RepositoryTarget, entry.TargetType)
+	assert.Equal(t, defaultRepo.ID.String(), entry.TargetID)
+	assert.Equal(t, defaultRepo.ID, *entry.RepositoryID)
+	assert.Equal(t, map[string]interface{}{"to": "public"}, entry.Details)
+}
+
+func TestAuditImpersonated(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuditingService{}
+	actorID := kallax.NewULID()
+	app := bastion.New()
+	app.Use(withUserMiddle(defaultUser))
+	app.Use(withImpersonatorMiddle(actorID))
+	app.With(middleware.Audit(s, domain.RepoCreated)).Post("/", handler)
+
+	e := bastion.Tester(t, app)
+	e.POST("/").Expect().Status(http.StatusOK)
+
+	assert.Len(t, s.entries, 1)
+	assert.Equal(t, defaultUser.ID, *s.entries[0].ActorID)
+	assert.Equal(t, actorID, *s.entries[0].ImpersonatorID)
+}
+
+func TestAuditTargetSetByHandler(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuditingService{}
+	id := kallax.NewULID()
+	app := bastion.New()
+	app.Use(withUserMiddle(defaultUser))
+	app.With(middleware.Audit(s, domain.RepoCreated)).Post("/", func(w http.ResponseWriter, r *http.Request) {
+		middleware.SetAuditTarget(r.Context(), domain.RepositoryTarget, id.String())
+		render.JSON.Created(w, "ok")
+	})
+
+	e := bastion.Tester(t, app)
+	e.POST("/").Expect().Status(http.StatusCreated)
+
+	assert.Len(t, s.entries, 1)
+	assert.Equal(t, domain.RepositoryTarget, s.entries[0].TargetType)
+	assert.Equal(t, id.String(), s.entries[0].TargetID)
+	assert.Equal(t, id, *s.entries[0].RepositoryID)
+}
+
+func TestAuditNotRecorded(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"failed", func(w http.ResponseWriter, r *http.Request) { render.JSON.BadRequest(w, errors.New("test")) }},
+		{"skipped", func(w http.ResponseWriter, r *http.Request) {
+			middleware.SkipAudit(r.Context())
+			render.JSON.Send(w, "ok")
+		}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &mockAuditingService{}
+			app := bastion.New()
+			app.With(middleware.Audit(s, domain.RepoCreated)).Post("/", tc.handler)
+
+			e := bastion.Tester(t, app)
+			e.POST("/").Expect()
+			assert.Len(t, s.entries, 0)
+		})
+	}
+}
+
+func TestAuditAttempt(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuditingService{err: errors.New("test")}
+	app := bastion.New()
+	app.With(middleware.AuditAttempt(s, domain.LoginSucceeded, domain.LoginFailed)).Post("/{status}", func(w http.ResponseWriter, r *http.Request) {
+		middleware.SetAuditTarget(r.Context(), domain.UserTarget, "test@example.com")
+		switch chi.URLParam(r, "status") {
+		case "ok":
+			middleware.SetAuditActor(r.Context(), defaultUserID)
+			render.JSON.Send(w, "ok")
+		case "unauthorized":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			render.JSON.InternalServerError(w, errors.New("test"))
+		}
+	})
+
+	e := bastion.Tester(t, app)
+	e.POST("/ok").Expect().Status(http.StatusOK)
+	e.POST("/unauthorized").Expect().Status(http.StatusUnauthorized)
+	e.POST("/err").Expect().Status(http.StatusInternalServerError)
+
+	assert.Len(t, s.entries, 2)
+	assert.Equal(t, domain.LoginSucceeded, s.entries[0].Action)
+	assert.Equal(t, defaultUserID, *s.entries[0].ActorID)
+	assert.Equal(t, domain.LoginFailed, s.entries[1].Action)
+	assert.Nil(t, s.entries[1].ActorID)
+	assert.Equal(t, "test@example.com", s.entries[1].TargetID)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/ifreddyrondon/bastion/render"
 
@@ -19,6 +20,11 @@ func forbidden(errMsg string) render.HTTPError {
 	}
 }
 
+func forbiddenRepo(w http.ResponseWriter, repo *domain.Repository) {
+	errMsg := fmt.Sprintf("You don't have permission to access repository %s", repo.ID)
+	render.JSON.Response(w, http.StatusForbidden, forbidden(errMsg))
+}
+
 func getUserAndRepo(r *http.Request) (*domain.User, *domain.Repository, error) {
 	u, err := GetUser(r.Context())
 	if err != nil {
@@ -33,41 +39,180 @@ func getUserAndRepo(r *http.Request) (*domain.User, *domain.Repository, error) {
 	return u, repo, nil
 }
 
-func RepoOwnerOrPublic() func(next http.Handler) http.Handler {
+// authKeyAllows reports whether the request was authorized with an API key and if
+// so, whether the key grants any of the scopes over the repository. Keys are created by
+// the owners of the repository, so the creator authorizing the request must still own it.
+func authKeyAllows(r *http.Request, p authorizing.CollaboratorPermission, scopes []domain.APIKeyScope) (isKey, ok bool) {
+	k, err := GetAuthKey(r.Context())
+	if err != nil {
+		return false, false
+	}
+	return true, k.Allows(p.ID, scopes...) && p.IsOwner(k.UserID)
+}
+
+// authGrantAllows reports whether the request was authorized with an OAuth2 client token
+// and if so, whether the user granted any of the scopes to the client.
+func authGrantAllows(r *http.Request, scopes []domain.APIKeyScope) (isGrant, ok bool) {
+	g, err := GetAuthGrant(r.Context())
+	if err != nil {
+		return false, false
+	}
+	return true, g.Allows(scopes...)
+}
+
+// authShareAllows reports whether the request was authorized with a share link and if
+// so, whether the link grants read access over the repository.
+func authShareAllows(r *http.Request, repo *domain.Repository) (isShare, ok bool) {
+	l, err := GetAuthShare(r.Context())
+	if err != nil {
+		return false, false
+	}
+	return true, l.Allows(repo.ID, time.Now())
+}
+
+// RepoOwnerOrPublic allows the owner and the collaborators of the repository, anyone when
+// the repository is public, API keys of the repository with any of the given scopes while
+// their creator still owns it and the active share links of the repository. OAuth2
+// clients also need any of the scopes.
+func RepoOwnerOrPublic(scopes ...domain.APIKeyScope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			repo, err := GetRepo(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+			if isShare, shareAllowed := authShareAllows(r, repo); isShare {
+				if !shareAllowed {
+					forbiddenRepo(w, repo)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			u, err := GetUser(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+			p := authorizing.NewCollaboratorPermission(*repo, GetRole(r.Context()))
+			isKey, keyAllowed := authKeyAllows(r, p, scopes)
+			isGrant, grantAllowed := authGrantAllows(r, scopes)
+			if (isKey && !keyAllowed) || (!isKey && !p.IsOwnerOrPublic(u.ID)) || (isGrant && !grantAllowed) {
+				forbiddenRepo(w, repo)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RepoOwner allows the owner of the repository, the owners of the organization owning it
+// and API keys of the repository with any of the given scopes while their creator still
+// owns it. OAuth2 clients acting for the owner also need any of the scopes. Share links
+// are denied.
+func RepoOwner(scopes ...domain.APIKeyScope) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			repo, err := GetRepo(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+			if isShare, _ := authShareAllows(r, repo); isShare {
+				forbiddenRepo(w, repo)
+				return
+			}
+
+			u, err := GetUser(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+			p := authorizing.NewCollaboratorPermission(*repo, GetRole(r.Context()))
+			isKey, keyAllowed := authKeyAllows(r, p, scopes)
+			isGrant, grantAllowed := authGrantAllows(r, scopes)
+			if (isKey && !keyAllowed) || (!isKey && !p.IsOwner(u.ID)) || (isGrant && !grantAllowed) {
+				forbiddenRepo(w, repo)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// RepoCollaborator allows the owner of the repository, its collaborators with at least
+// the given role, and API keys of the repository with any of the given scopes while their
+// creator still owns it. OAuth2 clients acting for them also need any of the scopes.
+// Share links are denied.
+func RepoCollaborator(role domain.Role, scopes ...domain.APIKeyScope) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			u, repo, err := getUserAndRepo(r)
+			repo, err := GetRepo(r.Context())
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				render.JSON.InternalServerError(w, err)
 				return
 			}
-			p := authorizing.RepoPermission(*repo)
-			if !p.IsOwnerOrPublic(u.ID) {
-				errMsg := fmt.Sprintf("You don't have permission to access repository %s", repo.ID)
-				render.JSON.Response(w, http.StatusForbidden, forbidden(errMsg))
+			if isShare, _ := authShareAllows(r, repo); isShare {
+				forbiddenRepo(w, repo)
 				return
 			}
 
+			u, err := GetUser(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+			p := authorizing.NewCollaboratorPermission(*repo, GetRole(r.Context()))
+			isKey, keyAllowed := authKeyAllows(r, p, scopes)
+			isGrant, grantAllowed := authGrantAllows(r, scopes)
+			if (isKey && !keyAllowed) || (!isKey && !p.HasRole(u.ID, role)) || (isGrant && !grantAllowed) {
+				forbiddenRepo(w, repo)
+				return
+			}
 			next.ServeHTTP(w, r)
 		}
 		return http.HandlerFunc(fn)
 	}
 }
 
-func RepoOwner() func(next http.Handler) http.Handler {
+// Admin allows the administrators.
+func Admin() func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			u, repo, err := getUserAndRepo(r)
+			u, err := GetUser(r.Context())
 			if err != nil {
 				fmt.Fprintln(os.Stderr, err)
 				render.JSON.InternalServerError(w, err)
 				return
 			}
-			p := authorizing.RepoPermission(*repo)
-			if !p.IsOwner(u.ID) {
-				errMsg := fmt.Sprintf("You don't have permission to access repository %s", repo.ID)
-				render.JSON.Response(w, http.StatusForbidden, forbidden(errMsg))
+			if !u.Admin {
+				render.JSON.Response(w, http.StatusForbidden, forbidden("You don't have permission to access this resource"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// NotImpersonated allows the requests of the users themselves, rejecting the ones of
+// an administrator impersonating them. It protects the credentials of the users.
+func NotImpersonated() func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if _, err := GetImpersonator(r.Context()); err == nil {
+				render.JSON.Response(w, http.StatusForbidden, forbidden("You can't access this resource while impersonating the user"))
 				return
 			}
 			next.ServeHTTP(w, r)
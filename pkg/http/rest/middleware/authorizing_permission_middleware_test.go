@@ -1,6 +1,7 @@
 package middleware_test
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"testing"
@@ -170,3 +171,242 @@ func TestRepoOwnerOrPublicFailsBecauseGetRepoMiddleware(t *testing.T) {
 		Status(http.StatusInternalServerError).
 		JSON().Object().Equal(response)
 }
+
+func withAuthKeyMiddle(k *domain.APIKey) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), middleware.UserCtxKey, defaultUser)
+			ctx = context.WithValue(ctx, middleware.AuthKeyCtxKey, k)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func TestRepoPermissionsWithAPIKey(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID(), Visibility: domain.Public, UserID: defaultUserID}
+	readKey := &domain.APIKey{RepositoryID: repo.ID, UserID: defaultUserID, Scopes: []domain.APIKeyScope{domain.CapturesRead}}
+	writeKey := &domain.APIKey{RepositoryID: repo.ID, UserID: defaultUserID, Scopes: []domain.APIKeyScope{domain.CapturesWrite}}
+	otherRepoKey := &domain.APIKey{RepositoryID: kallax.NewULID(), UserID: defaultUserID, Scopes: []domain.APIKeyScope{domain.CapturesRead}}
+
+	tt := []struct {
+		name       string
+		key        *domain.APIKey
+		permission func(...domain.APIKeyScope) func(http.Handler) http.Handler
+		scopes     []domain.APIKeyScope
+		status     int
+	}{
+		{"owner with scope", writeKey, middleware.RepoOwner, []domain.APIKeyScope{domain.CapturesWrite}, http.StatusOK},
+		{"owner without scope", readKey, middleware.RepoOwner, []domain.APIKeyScope{domain.CapturesWrite}, http.StatusForbidden},
+		{"owner not allowing keys", writeKey, middleware.RepoOwner, nil, http.StatusForbidden},
+		{"public with scope", readKey, middleware.RepoOwnerOrPublic, []domain.APIKeyScope{domain.CapturesRead}, http.StatusOK},
+		{"public not allowing keys", readKey, middleware.RepoOwnerOrPublic, nil, http.StatusForbidden},
+		{"public from other repo", otherRepoKey, middleware.RepoOwnerOrPublic, []domain.APIKeyScope{domain.CapturesRead}, http.StatusForbidden},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := bastion.New()
+			app.Route("/", func(r chi.Router) {
+				r.Use(withAuthKeyMiddle(tc.key))
+				r.Use(withRepoMiddle(repo))
+				r.Use(tc.permission(tc.scopes...))
+				r.Get("/", handler)
+			})
+			e := bastion.Tester(t, app)
+			e.GET("/").Expect().Status(tc.status)
+		})
+	}
+}
+
+func TestRepoPermissionsWithAPIKeyOfFormerOwner(t *testing.T) {
+	t.Parallel()
+
+	orgID := kallax.NewULID()
+	transferred := &domain.Repository{ID: kallax.NewULID(), Visibility: domain.Public, UserID: kallax.NewULID()}
+	orgRepo := &domain.Repository{ID: kallax.NewULID(), Visibility: domain.Public, UserID: defaultUserID, OrganizationID: &orgID}
+	scopes := []domain.APIKeyScope{domain.CapturesRead, domain.CapturesWrite}
+
+	tt := []struct {
+		name   string
+		repo   *domain.Repository
+		role   domain.Role
+		status int
+	}{
+		{"repo transferred to other user", transferred, "", http.StatusForbidden},
+		{"creator still owner of the organization", orgRepo, domain.OwnerRole, http.StatusOK},
+		{"creator demoted in the organization", orgRepo, domain.AdminRole, http.StatusForbidden},
+		{"creator removed from the organization", orgRepo, "", http.StatusForbidden},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			key := &domain.APIKey{RepositoryID: tc.repo.ID, UserID: defaultUserID, Scopes: scopes}
+			app := bastion.New()
+			app.Route("/", func(r chi.Router) {
+				r.Use(withAuthKeyMiddle(key))
+				r.Use(withRepoMiddle(tc.repo))
+				r.Use(withRoleMiddle(tc.role))
+				r.With(middleware.RepoOwnerOrPublic(domain.CapturesRead)).Get("/read", handler)
+				r.With(middleware.RepoCollaborator(domain.WriteRole, domain.CapturesWrite)).Get("/write", handler)
+				r.With(middleware.RepoOwner(domain.CapturesWrite)).Get("/owner", handler)
+			})
+			e := bastion.Tester(t, app)
+			e.GET("/read").Expect().Status(tc.status)
+			e.GET("/write").Expect().Status(tc.status)
+			e.GET("/owner").Expect().Status(tc.status)
+		})
+	}
+}
+
+func withAuthGrantMiddle(g *domain.AccessGrant) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), middleware.UserCtxKey, defaultUser)
+			ctx = context.WithValue(ctx, middleware.AuthGrantCtxKey, g)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func TestRepoPermissionsWithAuthGrant(t *testing.T) {
+	t.Parallel()
+
+	owned := &domain.Repository{ID: kallax.NewULID(), Visibility: domain.Private, UserID: defaultUserID}
+	other := &domain.Repository{ID: kallax.NewULID(), Visibility: domain.Private, UserID: kallax.NewULID()}
+	readGrant := &domain.AccessGrant{ClientID: "test", Scopes: []domain.APIKeyScope{domain.ReposRead, domain.CapturesRead}}
+	writeGrant := &domain.AccessGrant{ClientID: "test", Scopes: []domain.APIKeyScope{domain.CapturesWrite}}
+
+	tt := []struct {
+		name       string
+		grant      *domain.AccessGrant
+		repo       *domain.Repository
+		permission func(http.Handler) http.Handler
+		status     int
+	}{
+		{"read with scope", readGrant, owned, middleware.RepoOwnerOrPublic(domain.ReposRead), http.StatusOK},
+		{"read without scope", writeGrant, owned, middleware.RepoOwnerOrPublic(domain.ReposRead), http.StatusForbidden},
+		{"read repo of other user", readGrant, other, middleware.RepoOwnerOrPublic(domain.CapturesRead), http.StatusForbidden},
+		{"write with scope", writeGrant, owned, middleware.RepoCollaborator(domain.WriteRole, domain.CapturesWrite), http.StatusOK},
+		{"write without scope", readGrant, owned, middleware.RepoCollaborator(domain.WriteRole, domain.CapturesWrite), http.StatusForbidden},
+		{"owner not allowing scopes", writeGrant, owned, middleware.RepoOwner(), http.StatusForbidden},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := bastion.New()
+			app.Route("/", func(r chi.Router) {
+				r.Use(withAuthGrantMiddle(tc.grant))
+				r.Use(withRepoMiddle(tc.repo))
+				r.Use(tc.permission)
+				r.Get("/", handler)
+			})
+			e := bastion.Tester(t, app)
+			e.GET("/").Expect().Status(tc.status)
+		})
+	}
+}
+
+func withRoleMiddle(role domain.Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), middleware.RoleCtxKey, role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func TestRepoPermissionsWithRole(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID(), Visibility: domain.Private, UserID: kallax.NewULID()}
+
+	tt := []struct {
+		name       string
+		role       domain.Role
+		permission func(http.Handler) http.Handler
+		status     int
+	}{
+		{"owner or public without role", "", middleware.RepoOwnerOrPublic(), http.StatusForbidden},
+		{"owner or public with reader", domain.ReadRole, middleware.RepoOwnerOrPublic(), http.StatusOK},
+		{"owner with admin", domain.AdminRole, middleware.RepoOwner(), http.StatusForbidden},
+		{"owner with owner", domain.OwnerRole, middleware.RepoOwner(), http.StatusOK},
+		{"writer without role", "", middleware.RepoCollaborator(domain.WriteRole), http.StatusForbidden},
+		{"writer with reader", domain.ReadRole, middleware.RepoCollaborator(domain.WriteRole), http.StatusForbidden},
+		{"writer with writer", domain.WriteRole, middleware.RepoCollaborator(domain.WriteRole), http.StatusOK},
+		{"writer with admin", domain.AdminRole, middleware.RepoCollaborator(domain.WriteRole), http.StatusOK},
+		{"admin with writer", domain.WriteRole, middleware.RepoCollaborator(domain.AdminRole), http.StatusForbidden},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := bastion.New()
+			app.Route("/", func(r chi.Router) {
+				r.Use(withUserMiddle(defaultUser))
+				r.Use(withRepoMiddle(repo))
+				r.Use(withRoleMiddle(tc.role))
+				r.Use(tc.permission)
+				r.Get("/", handler)
+			})
+			e := bastion.Tester(t, app)
+			e.GET("/").Expect().Status(tc.status)
+		})
+	}
+}
+
+func TestRepoCollaboratorOwnerAndKeys(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID(), Visibility: domain.Public, UserID: defaultUserID}
+	writeKey := &domain.APIKey{RepositoryID: repo.ID, UserID: defaultUserID, Scopes: []domain.APIKeyScope{domain.CapturesWrite}}
+
+	app := bastion.New()
+	app.With(withUserMiddle(defaultUser), withRepoMiddle(repo), middleware.RepoCollaborator(domain.AdminRole)).
+		Get("/owner", handler)
+	app.With(withAuthKeyMiddle(writeKey), withRepoMiddle(repo), middleware.RepoCollaborator(domain.WriteRole, domain.CapturesWrite)).
+		Get("/key", handler)
+	app.With(withAuthKeyMiddle(writeKey), withRepoMiddle(repo), middleware.RepoCollaborator(domain.WriteRole)).
+		Get("/key-not-allowed", handler)
+	app.With(withUserMiddle(defaultUser), middleware.RepoCollaborator(domain.WriteRole)).
+		Get("/missing-repo", handler)
+
+	e := bastion.Tester(t, app)
+	e.GET("/owner").Expect().Status(http.StatusOK)
+	e.GET("/key").Expect().Status(http.StatusOK)
+	e.GET("/key-not-allowed").Expect().Status(http.StatusForbidden)
+	e.GET("/missing-repo").Expect().Status(http.StatusInternalServerError)
+}
+
+func TestAdmin(t *testing.T) {
+	t.Parallel()
+
+	admin := &domain.User{ID: kallax.NewULID(), Admin: true}
+
+	app := bastion.New()
+	app.With(withUserMiddle(admin), middleware.Admin()).Get("/admin", handler)
+	app.With(withUserMiddle(defaultUser), middleware.Admin()).Get("/user", handler)
+	app.With(withUserMiddle(nil), middleware.Admin()).Get("/missing-user", handler)
+
+	e := bastion.Tester(t, app)
+	e.GET("/admin").Expect().Status(http.StatusOK)
+	e.GET("/user").Expect().Status(http.StatusForbidden).
+		JSON().Object().ValueEqual("message", "You don't have permission to access this resource")
+	e.GET("/missing-user").Expect().Status(http.StatusInternalServerError)
+}
+
+func TestNotImpersonated(t *testing.T) {
+	t.Parallel()
+
+	app := bastion.New()
+	app.With(withUserMiddle(defaultUser), middleware.NotImpersonated()).Get("/user", handler)
+	app.With(withUserMiddle(defaultUser), withImpersonatorMiddle(kallax.NewULID()), middleware.NotImpersonated()).Get("/impersonated", handler)
+
+	e := bastion.Tester(t, app)
+	e.GET("/user").Expect().Status(http.StatusOK)
+	e.GET("/impersonated").Expect().Status(http.StatusForbidden).
+		JSON().Object().ValueEqual("message", "You can't access this resource while impersonating the user")
+}
@@ -8,19 +8,37 @@ import (
 
 	"github.com/ifreddyrondon/bastion/render"
 	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
 
 	"github.com/ifreddyrondon/capture/pkg/authorizing"
 	"github.com/ifreddyrondon/capture/pkg/domain"
 )
 
+// APIKeyHeader is the request header used to send an API key.
+const APIKeyHeader = "X-API-Key"
+
 var (
-	errInvalidUserID  = errors.New("invalid user id format")
-	errMissingUser    = errors.New("user not found in context")
-	errWrongUserValue = errors.New("user value set incorrectly in context")
+	errInvalidUserID       = errors.New("invalid user id format")
+	errMissingUser         = errors.New("user not found in context")
+	errWrongUserValue      = errors.New("user value set incorrectly in context")
+	errMissingAuthKey      = errors.New("request not authorized with an api key")
+	errWrongAuthKeyValue   = errors.New("authorization api key value set incorrectly in context")
+	errMissingAuthGrant    = errors.New("request not authorized with an oauth client token")
+	errWrongAuthGrantValue = errors.New("authorization grant value set incorrectly in context")
+	errMissingImpersonator = errors.New("request not authorized with an impersonation token")
+	errWrongImpersonator   = errors.New("impersonator value set incorrectly in context")
 )
 var (
 	// RepoCtxKey is the context.Context key to store the Repo for a request.
 	UserCtxKey = &contextKey{"User"}
+	// AuthKeyCtxKey is the context.Context key to store the API key used to authorize a request.
+	AuthKeyCtxKey = &contextKey{"AuthKey"}
+	// AuthGrantCtxKey is the context.Context key to store the access granted to the OAuth2
+	// client authorizing a request.
+	AuthGrantCtxKey = &contextKey{"AuthGrant"}
+	// ImpersonatorCtxKey is the context.Context key to store the id of the administrator
+	// impersonating the user of a request.
+	ImpersonatorCtxKey = &contextKey{"Impersonator"}
 )
 
 func withUser(ctx context.Context, user *domain.User) context.Context {
@@ -41,10 +59,90 @@ func GetUser(ctx context.Context) (*domain.User, error) {
 	return user, nil
 }
 
+func withAuthKey(ctx context.Context, k *domain.APIKey) context.Context {
+	return context.WithValue(ctx, AuthKeyCtxKey, k)
+}
+
+// GetAuthKey returns the API key used to authorize the request, or error if
+// the request wasn't authorized with an API key.
+func GetAuthKey(ctx context.Context) (*domain.APIKey, error) {
+	tmp := ctx.Value(AuthKeyCtxKey)
+	if tmp == nil {
+		return nil, errMissingAuthKey
+	}
+	k, ok := tmp.(*domain.APIKey)
+	if !ok {
+		return nil, errWrongAuthKeyValue
+	}
+	return k, nil
+}
+
+func withAuthGrant(ctx context.Context, g *domain.AccessGrant) context.Context {
+	return context.WithValue(ctx, AuthGrantCtxKey, g)
+}
+
+// GetAuthGrant returns the access granted to the OAuth2 client authorizing the request,
+// or error if the request wasn't authorized with a client token.
+func GetAuthGrant(ctx context.Context) (*domain.AccessGrant, error) {
+	tmp := ctx.Value(AuthGrantCtxKey)
+	if tmp == nil {
+		return nil, errMissingAuthGrant
+	}
+	g, ok := tmp.(*domain.AccessGrant)
+	if !ok {
+		return nil, errWrongAuthGrantValue
+	}
+	return g, nil
+}
+
+func withImpersonator(ctx context.Context, id *kallax.ULID) context.Context {
+	return context.WithValue(ctx, ImpersonatorCtxKey, id)
+}
+
+// GetImpersonator returns the id of the administrator impersonating the user of the
+// request, or error if the request wasn't authorized with an impersonation token.
+func GetImpersonator(ctx context.Context) (*kallax.ULID, error) {
+	tmp := ctx.Value(ImpersonatorCtxKey)
+	if tmp == nil {
+		return nil, errMissingImpersonator
+	}
+	id, ok := tmp.(*kallax.ULID)
+	if !ok {
+		return nil, errWrongImpersonator
+	}
+	return id, nil
+}
+
 func AuthorizeReq(service authorizing.Service) func(next http.Handler) http.Handler {
+	return authorizeReq(service, false)
+}
+
+// AuthorizeReqOrKey is like AuthorizeReq but also accepts a repository API key
+// sent in the X-API-Key header and tokens issued to OAuth2 clients. The key or the
+// grant of the client is kept in the context so the permission middlewares can check
+// its scopes. Both keep the administrator impersonating the user in the context.
+func AuthorizeReqOrKey(service authorizing.Service) func(next http.Handler) http.Handler {
+	return authorizeReq(service, true)
+}
+
+func authorizeReq(service authorizing.Service, acceptKeys bool) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
-			u, err := service.AuthorizeRequest(r)
+			var u *domain.User
+			var k *domain.APIKey
+			var g *domain.AccessGrant
+			var actorID *kallax.ULID
+			var err error
+			if key := r.Header.Get(APIKeyHeader); acceptKeys && key != "" {
+				u, k, err = service.AuthorizeKey(key)
+			} else if acceptKeys {
+				u, g, err = service.AuthorizeGrant(r)
+			} else {
+				u, err = service.AuthorizeRequest(r)
+			}
+			if err == nil && k == nil {
+				actorID, err = service.AuthorizeImpersonator(r)
+			}
 			if err != nil {
 				if isInvalidErr(err) {
 					render.JSON.BadRequest(w, errInvalidUserID)
@@ -69,6 +167,15 @@ func AuthorizeReq(service authorizing.Service) func(next http.Handler) http.Hand
 			}
 
 			ctx := withUser(r.Context(), u)
+			if k != nil {
+				ctx = withAuthKey(ctx, k)
+			}
+			if g != nil {
+				ctx = withAuthGrant(ctx, g)
+			}
+			if actorID != nil {
+				ctx = withImpersonator(ctx, actorID)
+			}
 			next.ServeHTTP(w, r.WithContext(ctx))
 		}
 		return http.HandlerFunc(fn)
@@ -28,13 +28,45 @@ func setupAuthorizing(service authorizing.Service) *bastion.Bastion {
 }
 
 type mockAuthorizingService struct {
-	usr *domain.User
-	err error
+	usr          *domain.User
+	key          *domain.APIKey
+	grant        *domain.AccessGrant
+	impersonator *kallax.ULID
+	err          error
+	keyErr       error
 }
 
 func (m *mockAuthorizingService) AuthorizeRequest(*http.Request) (*domain.User, error) {
 	return m.usr, m.err
 }
+func (m *mockAuthorizingService) AuthorizeGrant(*http.Request) (*domain.User, *domain.AccessGrant, error) {
+	return m.usr, m.grant, m.err
+}
+func (m *mockAuthorizingService) AuthorizeImpersonator(*http.Request) (*kallax.ULID, error) {
+	return m.impersonator, nil
+}
+func (m *mockAuthorizingService) AuthorizeKey(string) (*domain.User, *domain.APIKey, error) {
+	return m.usr, m.key, m.keyErr
+}
+
+func setupAuthorizingOrKey(service authorizing.Service) *bastion.Bastion {
+	app := bastion.New()
+	app.Route("/", func(r chi.Router) {
+		r.Use(middleware.AuthorizeReqOrKey(service))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			if _, err := middleware.GetAuthGrant(r.Context()); err == nil {
+				fmt.Fprint(w, "grant")
+				return
+			}
+			if _, err := middleware.GetAuthKey(r.Context()); err != nil {
+				fmt.Fprint(w, "token")
+				return
+			}
+			fmt.Fprint(w, "key")
+		})
+	})
+	return app
+}
 
 func TestAuthorizingSuccess(t *testing.T) {
 	t.Parallel()
@@ -114,6 +146,85 @@ func TestAuthorizingInternalErr(t *testing.T) {
 		JSON().Object().Equal(response)
 }
 
+func TestAuthorizingOrKeySuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuthorizingService{usr: defaultUser, key: &domain.APIKey{}}
+	e := bastion.Tester(t, setupAuthorizingOrKey(s))
+	e.GET("/").WithHeader("X-API-Key", "cap_abc_def").
+		Expect().
+		Status(http.StatusOK).
+		Body().Equal("key")
+	e.GET("/").WithHeader("Authorization", "Bearer test").
+		Expect().
+		Status(http.StatusOK).
+		Body().Equal("token")
+}
+
+func TestAuthorizingOrKeyWithGrant(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuthorizingService{usr: defaultUser, grant: &domain.AccessGrant{ClientID: "test"}}
+	e := bastion.Tester(t, setupAuthorizingOrKey(s))
+	e.GET("/").WithHeader("Authorization", "Bearer test").
+		Expect().
+		Status(http.StatusOK).
+		Body().Equal("grant")
+}
+
+func TestAuthorizingIgnoresKey(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuthorizingService{err: notAllowedErr("test")}
+	e := bastion.Tester(t, setupAuthorizing(s))
+	e.GET("/").WithHeader("X-API-Key", "cap_abc_def").
+		Expect().
+		Status(http.StatusUnauthorized)
+}
+
+func TestAuthorizingOrKeyNotAuthorized(t *testing.T) {
+	t.Parallel()
+
+	s := &mockAuthorizingService{keyErr: notAllowedErr("invalid api key")}
+	response := map[string]interface{}{
+		"status":  401.0,
+		"error":   "Unauthorized",
+		"message": "authorization required, access is denied due to invalid credentials",
+	}
+
+	e := bastion.Tester(t, setupAuthorizingOrKey(s))
+	e.GET("/").WithHeader("X-API-Key", "cap_abc_def").
+		Expect().
+		Status(http.StatusUnauthorized).
+		JSON().Object().Equal(response)
+}
+
+func TestContextGetAuthKey(t *testing.T) {
+	k := &domain.APIKey{ID: kallax.NewULID()}
+	ctx := context.WithValue(context.Background(), middleware.AuthKeyCtxKey, k)
+	result, err := middleware.GetAuthKey(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, k, result)
+
+	_, err = middleware.GetAuthKey(context.Background())
+	assert.EqualError(t, err, "request not authorized with an api key")
+	_, err = middleware.GetAuthKey(context.WithValue(context.Background(), middleware.AuthKeyCtxKey, "test"))
+	assert.EqualError(t, err, "authorization api key value set incorrectly in context")
+}
+
+func TestContextGetAuthGrant(t *testing.T) {
+	g := &domain.AccessGrant{ClientID: "test"}
+	ctx := context.WithValue(context.Background(), middleware.AuthGrantCtxKey, g)
+	result, err := middleware.GetAuthGrant(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, g, result)
+
+	_, err = middleware.GetAuthGrant(context.Background())
+	assert.EqualError(t, err, "request not authorized with an oauth client token")
+	_, err = middleware.GetAuthGrant(context.WithValue(context.Background(), middleware.AuthGrantCtxKey, "test"))
+	assert.EqualError(t, err, "authorization grant value set incorrectly in context")
+}
+
 func TestContextGetUserOK(t *testing.T) {
 	ctx := context.Background()
 	u := domain.User{ID: kallax.NewULID(), Email: "test@example.com"}
@@ -138,3 +249,37 @@ func TestContextGetUserWhenWrongUserValue(t *testing.T) {
 	_, err := middleware.GetUser(ctx)
 	assert.EqualError(t, err, "user value set incorrectly in context")
 }
+
+func TestAuthorizingWithImpersonator(t *testing.T) {
+	t.Parallel()
+
+	actorID := kallax.NewULID()
+	app := bastion.New()
+	app.Route("/", func(r chi.Router) {
+		r.Use(middleware.AuthorizeReq(&mockAuthorizingService{usr: defaultUser, impersonator: &actorID}))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			id, err := middleware.GetImpersonator(r.Context())
+			assert.Nil(t, err)
+			fmt.Fprint(w, id.String())
+		})
+	})
+
+	e := bastion.Tester(t, app)
+	e.GET("/").WithHeader("Authorization", "Bearer test").
+		Expect().
+		Status(http.StatusOK).
+		Body().Equal(actorID.String())
+}
+
+func TestContextGetImpersonator(t *testing.T) {
+	id := kallax.NewULID()
+	ctx := context.WithValue(context.Background(), middleware.ImpersonatorCtxKey, &id)
+	result, err := middleware.GetImpersonator(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, id, *result)
+
+	_, err = middleware.GetImpersonator(context.Background())
+	assert.EqualError(t, err, "request not authorized with an impersonation token")
+	_, err = middleware.GetImpersonator(context.WithValue(context.Background(), middleware.ImpersonatorCtxKey, "test"))
+	assert.EqualError(t, err, "impersonator value set incorrectly in context")
+}
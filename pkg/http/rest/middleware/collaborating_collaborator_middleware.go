@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/collaborating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+var (
+	// CollaboratorCtxKey is the context.Context key to store the collaborator for a request.
+	CollaboratorCtxKey = &contextKey{"Collaborator"}
+)
+var (
+	errMissingCtxCollaborator = errors.New("collaborator not found in context")
+	errWrongCollaboratorValue = errors.New("collaborator value set incorrectly in context")
+	errMissingCollaborator    = errors.New("not found collaborator")
+	errInvalidCollaboratorID  = errors.New("invalid collaborator id")
+)
+
+func withCollaborator(ctx context.Context, c *domain.Collaborator) context.Context {
+	return context.WithValue(ctx, CollaboratorCtxKey, c)
+}
+
+// GetCollaborator returns the collaborator assigned to the context, or error if there
+// is any error or there isn't a collaborator.
+func GetCollaborator(ctx context.Context) (*domain.Collaborator, error) {
+	tmp := ctx.Value(CollaboratorCtxKey)
+	if tmp == nil {
+		return nil, errMissingCtxCollaborator
+	}
+	c, ok := tmp.(*domain.Collaborator)
+	if !ok {
+		return nil, errWrongCollaboratorValue
+	}
+	return c, nil
+}
+
+func CollaboratorCtx(service collaborating.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			collaboratorID := chi.URLParam(r, "collaboratorId")
+			repo, err := GetRepo(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			id, err := kallax.NewULIDFromText(collaboratorID)
+			if err != nil {
+				render.JSON.BadRequest(w, errInvalidCollaboratorID)
+				return
+			}
+
+			c, err := service.GetCollaborator(id, repo)
+			if err != nil {
+				if isNotFound(err) {
+					render.JSON.NotFound(w, errMissingCollaborator)
+					return
+				}
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			ctx := withCollaborator(r.Context(), c)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,68 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/collaborating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+func setupCollaboratorCtx(service collaborating.Service, getRepo func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Route("/{collaboratorId}", func(r chi.Router) {
+		r.Use(getRepo)
+		r.Use(middleware.CollaboratorCtx(service))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			if _, err := middleware.GetCollaborator(r.Context()); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			handler(w, r)
+		})
+	})
+	return app
+}
+
+func TestCollaboratorCtxSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockCollaboratingService{collaborator: &domain.Collaborator{}}
+	e := bastion.Tester(t, setupCollaboratorCtx(s, withRepoMiddle(defaultRepo)))
+	e.GET("/0167c8a5-d308-8692-809d-b1ad4a2d9562").
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestCollaboratorCtxFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		service *mockCollaboratingService
+		getRepo func(http.Handler) http.Handler
+		id      string
+		status  int
+		message string
+	}{
+		{"missing repo", &mockCollaboratingService{}, withRepoMiddle(nil), "0167c8a5-d308-8692-809d-b1ad4a2d9562", http.StatusInternalServerError, "looks like something went wrong"},
+		{"invalid id", &mockCollaboratingService{}, withRepoMiddle(defaultRepo), "abc", http.StatusBadRequest, "invalid collaborator id"},
+		{"not found", &mockCollaboratingService{err: notFound("test")}, withRepoMiddle(defaultRepo), "0167c8a5-d308-8692-809d-b1ad4a2d9562", http.StatusNotFound, "not found collaborator"},
+		{"service err", &mockCollaboratingService{err: errors.New("test")}, withRepoMiddle(defaultRepo), "0167c8a5-d308-8692-809d-b1ad4a2d9562", http.StatusInternalServerError, "looks like something went wrong"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupCollaboratorCtx(tc.service, tc.getRepo))
+			e.GET("/"+tc.id).
+				Expect().
+				Status(tc.status).
+				JSON().Object().ValueEqual("message", tc.message)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/ifreddyrondon/bastion/render"
+
+	"github.com/ifreddyrondon/capture/pkg/authorizing"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+var (
+	// RoleCtxKey is the context.Context key to store the role of the user over the repository of a request.
+	RoleCtxKey = &contextKey{"Role"}
+)
+
+func withRole(ctx context.Context, role domain.Role) context.Context {
+	return context.WithValue(ctx, RoleCtxKey, role)
+}
+
+// GetRole returns the role of the user over the repository assigned to the
+// context. It's empty when the user isn't a collaborator of the repository.
+func GetRole(ctx context.Context) domain.Role {
+	role, _ := ctx.Value(RoleCtxKey).(domain.Role)
+	return role
+}
+
+// RoleService resolves the role of an user over a repository.
+type RoleService interface {
+	GetRole(*domain.Repository, *domain.User) (domain.Role, error)
+}
+
+// RoleCtx loads the role of the user over the repository of the request, so the
+// repository permissions are granted to its collaborators and to the members of the
+// organization owning it. The highest role resolved by the services is kept. Requests
+// authorized with a share link have no user, so no role is loaded.
+func RoleCtx(services ...RoleService) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			if _, err := GetAuthShare(r.Context()); err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			u, repo, err := getUserAndRepo(r)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+			if authorizing.RepoPermission(*repo).IsOwner(u.ID) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			var role domain.Role
+			for _, s := range services {
+				resolved, err := s.GetRole(repo, u)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, err)
+					render.JSON.InternalServerError(w, err)
+					return
+				}
+				if !role.Includes(resolved) {
+					role = resolved
+				}
+			}
+
+			ctx := withRole(r.Context(), role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
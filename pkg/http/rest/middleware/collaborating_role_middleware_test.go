@@ -0,0 +1,89 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/collaborating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+type mockCollaboratingService struct {
+	collaborator *domain.Collaborator
+	role         domain.Role
+	err          error
+}
+
+func (m *mockCollaboratingService) Invite(*domain.User, *domain.Repository, collaborating.Payload) (*domain.Collaborator, error) {
+	return m.collaborator, m.err
+}
+func (m *mockCollaboratingService) ListCollaborators(*domain.Repository) ([]domain.Collaborator, error) {
+	return nil, m.err
+}
+func (m *mockCollaboratingService) GetCollaborator(kallax.ULID, *domain.Repository) (*domain.Collaborator, error) {
+	return m.collaborator, m.err
+}
+func (m *mockCollaboratingService) RemoveCollaborator(*domain.Collaborator) error { return m.err }
+func (m *mockCollaboratingService) ListInvitations(*domain.User) ([]domain.Collaborator, error) {
+	return nil, m.err
+}
+func (m *mockCollaboratingService) AcceptInvitation(kallax.ULID, *domain.User) (*domain.Collaborator, error) {
+	return m.collaborator, m.err
+}
+func (m *mockCollaboratingService) DeclineInvitation(kallax.ULID, *domain.User) error { return m.err }
+func (m *mockCollaboratingService) GetRole(*domain.Repository, *domain.User) (domain.Role, error) {
+	return m.role, m.err
+}
+
+func setupRoleCtx(service middleware.RoleService, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Route("/", func(r chi.Router) {
+		for _, m := range middlewares {
+			r.Use(m)
+		}
+		r.Use(middleware.RoleCtx(service, &mockCollaboratingService{role: domain.ReadRole}))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(middleware.GetRole(r.Context())))
+		})
+	})
+	return app
+}
+
+func TestRoleCtx(t *testing.T) {
+	t.Parallel()
+
+	othersRepo := &domain.Repository{ID: kallax.NewULID(), UserID: kallax.NewULID()}
+	ownRepo := &domain.Repository{ID: kallax.NewULID(), UserID: defaultUserID}
+
+	e := bastion.Tester(t, setupRoleCtx(&mockCollaboratingService{role: domain.WriteRole}, withUserMiddle(defaultUser), withRepoMiddle(othersRepo)))
+	e.GET("/").Expect().Status(http.StatusOK).Body().Equal("write")
+
+	e = bastion.Tester(t, setupRoleCtx(&mockCollaboratingService{role: domain.WriteRole}, withUserMiddle(defaultUser), withRepoMiddle(ownRepo)))
+	e.GET("/").Expect().Status(http.StatusOK).Body().Equal("")
+
+	e = bastion.Tester(t, setupRoleCtx(&mockCollaboratingService{}, withUserMiddle(defaultUser), withRepoMiddle(othersRepo)))
+	e.GET("/").Expect().Status(http.StatusOK).Body().Equal("read")
+
+	orgID := kallax.NewULID()
+	orgRepo := &domain.Repository{ID: kallax.NewULID(), UserID: defaultUserID, OrganizationID: &orgID}
+	e = bastion.Tester(t, setupRoleCtx(&mockCollaboratingService{role: domain.OwnerRole}, withUserMiddle(defaultUser), withRepoMiddle(orgRepo)))
+	e.GET("/").Expect().Status(http.StatusOK).Body().Equal("owner")
+}
+
+func TestRoleCtxFails(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID(), UserID: kallax.NewULID()}
+
+	e := bastion.Tester(t, setupRoleCtx(&mockCollaboratingService{}, withUserMiddle(defaultUser)))
+	e.GET("/").Expect().Status(http.StatusInternalServerError)
+
+	e = bastion.Tester(t, setupRoleCtx(&mockCollaboratingService{err: errors.New("test")}, withUserMiddle(defaultUser), withRepoMiddle(repo)))
+	e.GET("/").Expect().Status(http.StatusInternalServerError)
+}
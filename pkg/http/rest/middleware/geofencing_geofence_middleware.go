@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/geofencing"
+)
+
+var (
+	// GeofenceCtxKey is the context.Context key to store the Geofence for a request.
+	GeofenceCtxKey = &contextKey{"Geofence"}
+)
+var (
+	errMissingCtxGeofence = errors.New("geofence not found in context")
+	errWrongGeofenceValue = errors.New("geofence value set incorrectly in context")
+	errMissingGeofence    = errors.New("not found geofence")
+	errInvalidGeofenceID  = errors.New("invalid geofence id")
+)
+
+func withGeofence(ctx context.Context, g *domain.Geofence) context.Context {
+	return context.WithValue(ctx, GeofenceCtxKey, g)
+}
+
+// GetGeofence returns the geofence assigned to the context, or error if there
+// is any error or there isn't a geofence.
+func GetGeofence(ctx context.Context) (*domain.Geofence, error) {
+	tmp := ctx.Value(GeofenceCtxKey)
+	if tmp == nil {
+		return nil, errMissingCtxGeofence
+	}
+	g, ok := tmp.(*domain.Geofence)
+	if !ok {
+		return nil, errWrongGeofenceValue
+	}
+	return g, nil
+}
+
+func GeofenceCtx(service geofencing.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			geofenceID := chi.URLParam(r, "geofenceId")
+			repo, err := GetRepo(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			id, err := kallax.NewULIDFromText(geofenceID)
+			if err != nil {
+				render.JSON.BadRequest(w, errInvalidGeofenceID)
+				return
+			}
+
+			g, err := service.GetGeofence(id, repo)
+			if err != nil {
+				if isNotFound(err) {
+					render.JSON.NotFound(w, errMissingGeofence)
+					return
+				}
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			ctx := withGeofence(r.Context(), g)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,134 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion"
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/geofencing"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+type mockGeofencingService struct {
+	geofence *domain.Geofence
+	err      error
+}
+
+func (m *mockGeofencingService) CreateGeofence(*domain.Repository, geofencing.Payload) (*domain.Geofence, error) {
+	return m.geofence, m.err
+}
+func (m *mockGeofencingService) ListGeofences(*domain.Repository) ([]domain.Geofence, error) {
+	return nil, m.err
+}
+func (m *mockGeofencingService) GetGeofence(kallax.ULID, *domain.Repository) (*domain.Geofence, error) {
+	return m.geofence, m.err
+}
+func (m *mockGeofencingService) RemoveGeofence(*domain.Geofence) error { return m.err }
+func (m *mockGeofencingService) ListEvents(*domain.Geofence, *listing.Listing) (*geofencing.ListEventResponse, error) {
+	return nil, m.err
+}
+
+func setupGeofenceCtx(service geofencing.Service, getRepo func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Route("/{geofenceId}", func(r chi.Router) {
+		r.Use(getRepo)
+		r.Use(middleware.GeofenceCtx(service))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			if _, err := middleware.GetGeofence(r.Context()); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			handler(w, r)
+		})
+	})
+	return app
+}
+
+func TestGeofenceCtxSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockGeofencingService{geofence: &domain.Geofence{}}
+	app := setupGeofenceCtx(s, withRepoMiddle(defaultRepo))
+	e := bastion.Tester(t, app)
+	e.GET("/0167c8a5-d308-8692-809d-b1ad4a2d9562").
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestGeofenceCtxFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name     string
+		service  *mockGeofencingService
+		getRepo  func(http.Handler) http.Handler
+		id       string
+		status   int
+		response map[string]interface{}
+	}{
+		{
+			name:    "missing repo",
+			service: &mockGeofencingService{},
+			getRepo: withRepoMiddle(nil),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusInternalServerError,
+			response: map[string]interface{}{
+				"status":  500.0,
+				"error":   "Internal Server Error",
+				"message": "looks like something went wrong",
+			},
+		},
+		{
+			name:    "invalid id",
+			service: &mockGeofencingService{},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "abc",
+			status:  http.StatusBadRequest,
+			response: map[string]interface{}{
+				"status":  400.0,
+				"error":   "Bad Request",
+				"message": "invalid geofence id",
+			},
+		},
+		{
+			name:    "not found",
+			service: &mockGeofencingService{err: notFound("test")},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusNotFound,
+			response: map[string]interface{}{
+				"status":  404.0,
+				"error":   "Not Found",
+				"message": "not found geofence",
+			},
+		},
+		{
+			name:    "service err",
+			service: &mockGeofencingService{err: errors.New("test")},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusInternalServerError,
+			response: map[string]interface{}{
+				"status":  500.0,
+				"error":   "Internal Server Error",
+				"message": "looks like something went wrong",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupGeofenceCtx(tc.service, tc.getRepo))
+			e.GET("/" + tc.id).
+				Expect().
+				Status(tc.status).
+				JSON().Object().Equal(tc.response)
+		})
+	}
+}
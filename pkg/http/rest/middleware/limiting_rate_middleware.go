@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/ifreddyrondon/bastion/render"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/limiting"
+)
+
+// RateLimit headers. Referenced at https://tools.ietf.org/html/draft-ietf-httpapi-ratelimit-headers
+const (
+	RateLimitLimitHeader     = "RateLimit-Limit"
+	RateLimitRemainingHeader = "RateLimit-Remaining"
+	RateLimitResetHeader     = "RateLimit-Reset"
+)
+
+// rateLimitKey identifies the client of a request by its API key, its user or its ip, in order.
+func rateLimitKey(r *http.Request) string {
+	if k, err := GetAuthKey(r.Context()); err == nil {
+		return fmt.Sprintf("key:%s", k.ID)
+	}
+	if u, err := GetUser(r.Context()); err == nil {
+		return fmt.Sprintf("user:%s", u.ID)
+	}
+	return fmt.Sprintf("ip:%s", ClientIP(r))
+}
+
+func seconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
+
+// RateLimit limits the requests of every client to the routes of a group with a token
+// bucket, responding 429 Too Many Requests once it's empty. The clients are identified
+// by API key, user or ip, so it must be used after the authorization middlewares. A
+// limit without requests disables it and the requests are allowed when the limits
+// can't be checked.
+func RateLimit(service limiting.Service, group string, l domain.RateLimit) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if l.Requests <= 0 {
+			return next
+		}
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			result, err := service.Allow(fmt.Sprintf("%s:%s", group, rateLimitKey(r)), l)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			w.Header().Set(RateLimitLimitHeader, strconv.Itoa(result.Limit))
+			w.Header().Set(RateLimitRemainingHeader, strconv.Itoa(result.Remaining))
+			w.Header().Set(RateLimitResetHeader, seconds(result.Reset))
+			if !result.Allowed {
+				w.Header().Set("Retry-After", seconds(result.RetryAfter))
+				httpErr := render.HTTPError{
+					Status:  http.StatusTooManyRequests,
+					Error:   http.StatusText(http.StatusTooManyRequests),
+					Message: fmt.Sprintf("rate limit exceeded, retry in %s seconds", seconds(result.RetryAfter)),
+				}
+				render.JSON.Response(w, http.StatusTooManyRequests, httpErr)
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
@@ -0,0 +1,110 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/limiting"
+)
+
+type mockLimitingService struct {
+	result *limiting.Result
+	err    error
+	keys   []string
+}
+
+func (m *mockLimitingService) Allow(key string, _ domain.RateLimit) (*limiting.Result, error) {
+	m.keys = append(m.keys, key)
+	return m.result, m.err
+}
+
+var defaultRateLimit = domain.RateLimit{Requests: 10, Period: time.Minute}
+
+func TestRateLimitAllowed(t *testing.T) {
+	t.Parallel()
+
+	s := &mockLimitingService{result: &limiting.Result{Allowed: true, Limit: 10, Remaining: 9, Reset: 5500 * time.Millisecond}}
+	app := bastion.New()
+	app.With(middleware.RateLimit(s, "test", defaultRateLimit)).Get("/", handler)
+
+	e := bastion.Tester(t, app)
+	resp := e.GET("/").Expect().Status(http.StatusOK)
+	resp.Header("RateLimit-Limit").Equal("10")
+	resp.Header("RateLimit-Remaining").Equal("9")
+	resp.Header("RateLimit-Reset").Equal("6")
+	resp.Header("Retry-After").Empty()
+}
+
+func TestRateLimitExceeded(t *testing.T) {
+	t.Parallel()
+
+	s := &mockLimitingService{result: &limiting.Result{Limit: 10, Reset: time.Minute, RetryAfter: 6 * time.Second}}
+	app := bastion.New()
+	app.With(middleware.RateLimit(s, "test", defaultRateLimit)).Get("/", handler)
+
+	response := map[string]interface{}{
+		"status":  429.0,
+		"error":   "Too Many Requests",
+		"message": "rate limit exceeded, retry in 6 seconds",
+	}
+
+	e := bastion.Tester(t, app)
+	resp := e.GET("/").Expect().Status(http.StatusTooManyRequests)
+	resp.Header("RateLimit-Remaining").Equal("0")
+	resp.Header("RateLimit-Reset").Equal("60")
+	resp.Header("Retry-After").Equal("6")
+	resp.JSON().Object().Equal(response)
+}
+
+func TestRateLimitKeys(t *testing.T) {
+	t.Parallel()
+
+	s := &mockLimitingService{result: &limiting.Result{Allowed: true}}
+	key := &domain.APIKey{ID: kallax.NewULID()}
+	limit := middleware.RateLimit(s, "test", defaultRateLimit)
+	app := bastion.New()
+	app.With(limit).Get("/ip", handler)
+	app.With(withUserMiddle(defaultUser), limit).Get("/user", handler)
+	app.With(withAuthKeyMiddle(key), limit).Get("/key", handler)
+
+	e := bastion.Tester(t, app)
+	e.GET("/ip").Expect().Status(http.StatusOK)
+	e.GET("/user").Expect().Status(http.StatusOK)
+	e.GET("/key").Expect().Status(http.StatusOK)
+
+	assert.Len(t, s.keys, 3)
+	assert.Regexp(t, "^test:ip:", s.keys[0])
+	assert.Equal(t, "test:user:"+defaultUser.ID.String(), s.keys[1])
+	assert.Equal(t, "test:key:"+key.ID.String(), s.keys[2])
+}
+
+func TestRateLimitAllowsWhenServiceFails(t *testing.T) {
+	t.Parallel()
+
+	s := &mockLimitingService{err: errors.New("test")}
+	app := bastion.New()
+	app.With(middleware.RateLimit(s, "test", defaultRateLimit)).Get("/", handler)
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusOK).Body().Equal("ok")
+}
+
+func TestRateLimitDisabled(t *testing.T) {
+	t.Parallel()
+
+	s := &mockLimitingService{}
+	app := bastion.New()
+	app.With(middleware.RateLimit(s, "test", domain.RateLimit{})).Get("/", handler)
+
+	e := bastion.Tester(t, app)
+	e.GET("/").Expect().Status(http.StatusOK).Header("RateLimit-Limit").Empty()
+	assert.Empty(t, s.keys)
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ifreddyrondon/bastion/middleware"
+	"github.com/ifreddyrondon/bastion/middleware/listing/filtering"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const auditEntriesMaxAllowedLimit = 100
+
+func FilterAuditEntries() func(next http.Handler) http.Handler {
+	actions := make([]filtering.Value, len(domain.AuditActions))
+	for i, a := range domain.AuditActions {
+		actions[i] = filtering.NewValue(string(a), string(a))
+	}
+	actionFilter := filtering.NewText("action", "filters the entries by the action", actions...)
+	targets := make([]filtering.Value, len(domain.AuditTargets))
+	for i, t := range domain.AuditTargets {
+		targets[i] = filtering.NewValue(t, t)
+	}
+	targetFilter := filtering.NewText("target", "filters the entries by the kind of target", targets...)
+
+	return middleware.Listing(
+		middleware.MaxAllowedLimit(auditEntriesMaxAllowedLimit),
+		middleware.Sort(createdDESC, createdASC),
+		middleware.Filter(actionFilter, targetFilter),
+	)
+}
@@ -0,0 +1,38 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/ifreddyrondon/bastion/middleware/listing/paging"
+	"github.com/ifreddyrondon/bastion/middleware/listing/sorting"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+func TestFilterAuditEntries(t *testing.T) {
+	t.Parallel()
+
+	app, result := setupFilterMiddleware(middleware.FilterAuditEntries())
+	e := bastion.Tester(t, app)
+	e.GET("/").
+		WithQuery("action", "login.failed").
+		WithQuery("target", "user").
+		Expect().
+		Status(http.StatusOK)
+
+	assert.Equal(t, paging.Paging{Limit: paging.DefaultLimit, Offset: paging.DefaultOffset, MaxAllowedLimit: 100}, result.Paging)
+	assert.Equal(t, &createdDESC, result.Sorting.Sort)
+	assert.Equal(t, []sorting.Sort{createdDESC, createdASC}, result.Sorting.Available)
+	assert.Len(t, result.Filtering.Available, 2)
+	assert.Len(t, result.Filtering.Available[0].Values, len(domain.AuditActions))
+	assert.Len(t, result.Filtering.Available[1].Values, len(domain.AuditTargets))
+	assert.Len(t, result.Filtering.Filters, 2)
+	assert.Equal(t, "action", result.Filtering.Filters[0].ID)
+	assert.Equal(t, string(domain.LoginFailed), result.Filtering.Filters[0].Values[0].ID)
+	assert.Equal(t, "target", result.Filtering.Filters[1].ID)
+	assert.Equal(t, domain.UserTarget, result.Filtering.Filters[1].Values[0].ID)
+}
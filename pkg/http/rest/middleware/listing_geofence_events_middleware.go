@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ifreddyrondon/bastion/middleware"
+)
+
+const geofenceEventsMaxAllowedLimit = 100
+
+func FilterGeofenceEvents() func(next http.Handler) http.Handler {
+	return middleware.Listing(
+		middleware.MaxAllowedLimit(geofenceEventsMaxAllowedLimit),
+		middleware.Sort(timestampDESC, timestampASC),
+	)
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ifreddyrondon/bastion/middleware"
+)
+
+const retentionReportsMaxAllowedLimit = 100
+
+func FilterRetentionReports() func(next http.Handler) http.Handler {
+	return middleware.Listing(
+		middleware.MaxAllowedLimit(retentionReportsMaxAllowedLimit),
+		middleware.Sort(createdDESC, createdASC),
+	)
+}
@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ifreddyrondon/bastion/middleware"
+	"github.com/ifreddyrondon/bastion/middleware/listing/filtering"
+)
+
+const usersMaxAllowedLimit = 100
+
+func FilterUsers() func(next http.Handler) http.Handler {
+	activeStatus := filtering.NewValue("active", "active accounts")
+	disabledStatus := filtering.NewValue("disabled", "disabled accounts")
+	statusFilter := filtering.NewText("status", "filters the users by the state of their account", activeStatus, disabledStatus)
+
+	return middleware.Listing(
+		middleware.MaxAllowedLimit(usersMaxAllowedLimit),
+		middleware.Sort(createdDESC, createdASC, updatedDESC, updatedASC),
+		middleware.Filter(statusFilter),
+	)
+}
@@ -0,0 +1,52 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion"
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/ifreddyrondon/bastion/middleware/listing/filtering"
+	"github.com/ifreddyrondon/bastion/middleware/listing/paging"
+	"github.com/ifreddyrondon/bastion/middleware/listing/sorting"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+)
+
+func TestFilterUsers(t *testing.T) {
+	t.Parallel()
+
+	l := &listing.Listing{
+		Paging: paging.Paging{
+			Limit:           paging.DefaultLimit,
+			Offset:          paging.DefaultOffset,
+			MaxAllowedLimit: 100,
+		},
+		Sorting: &sorting.Sorting{
+			Sort:      &createdDESC,
+			Available: []sorting.Sort{createdDESC, createdASC, updatedDESC, updatedASC},
+		},
+		Filtering: &filtering.Filtering{
+			Available: []filtering.Filter{
+				{
+					ID:          "status",
+					Description: "filters the users by the state of their account",
+					Type:        "text",
+					Values: []filtering.Value{
+						filtering.NewValue("active", "active accounts"),
+						filtering.NewValue("disabled", "disabled accounts"),
+					},
+				},
+			},
+		},
+	}
+
+	app, resultContainer := setupFilterMiddleware(middleware.FilterUsers())
+	e := bastion.Tester(t, app)
+	e.GET("/").
+		Expect().
+		Status(http.StatusOK)
+
+	assert.Equal(t, l, resultContainer)
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/ifreddyrondon/bastion/middleware"
+)
+
+const webhookDeliveriesMaxAllowedLimit = 100
+
+func FilterWebhookDeliveries() func(next http.Handler) http.Handler {
+	return middleware.Listing(
+		middleware.MaxAllowedLimit(webhookDeliveriesMaxAllowedLimit),
+		middleware.Sort(createdDESC, createdASC),
+	)
+}
@@ -1,6 +1,9 @@
 package middleware
 
 import (
+	"net"
+	"net/http"
+
 	"github.com/ifreddyrondon/bastion/middleware/listing/sorting"
 	"github.com/pkg/errors"
 )
@@ -10,6 +13,9 @@ var (
 	updatedASC  = sorting.NewSort("updated_at_asc", "updated_at ASC", "Updated date ascendant")
 	createdDESC = sorting.NewSort("created_at_desc", "created_at DESC", "Created date descending")
 	createdASC  = sorting.NewSort("created_at_asc", "created_at ASC", "Created date ascendant")
+
+	timestampDESC = sorting.NewSort("timestamp_desc", "timestamp DESC", "Timestamp descending")
+	timestampASC  = sorting.NewSort("timestamp_asc", "timestamp ASC", "Timestamp ascendant")
 )
 
 // contextKey is a value for use with context.WithValue. It's used as
@@ -23,6 +29,15 @@ func (k *contextKey) String() string {
 	return "capture/middleware context value " + k.name
 }
 
+// ClientIP returns the ip of the client of a request from its remote address.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 type invalidErr interface {
 	IsInvalid() bool
 }
@@ -61,6 +61,28 @@ var (
 	defaultRepo   = &domain.Repository{Name: "test", ID: kallax.NewULID()}
 )
 
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:54321"
+	assert.Equal(t, "127.0.0.1", middleware.ClientIP(r))
+	r.RemoteAddr = "[::1]:54321"
+	assert.Equal(t, "::1", middleware.ClientIP(r))
+	r.RemoteAddr = "127.0.0.1"
+	assert.Equal(t, "127.0.0.1", middleware.ClientIP(r))
+}
+
+func withImpersonatorMiddle(id kallax.ULID) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), middleware.ImpersonatorCtxKey, &id)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
 func withUserMiddle(user *domain.User) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		fn := func(w http.ResponseWriter, r *http.Request) {
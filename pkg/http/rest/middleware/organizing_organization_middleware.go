@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/organizing"
+)
+
+var (
+	// OrganizationCtxKey is the context.Context key to store the organization for a request.
+	OrganizationCtxKey = &contextKey{"Organization"}
+)
+var (
+	errMissingCtxOrganization = errors.New("organization not found in context")
+	errWrongOrganizationValue = errors.New("organization value set incorrectly in context")
+	errMissingOrganization    = errors.New("not found organization")
+	errInvalidOrganizationID  = errors.New("invalid organization id")
+)
+
+func withOrganization(ctx context.Context, org *domain.Organization) context.Context {
+	return context.WithValue(ctx, OrganizationCtxKey, org)
+}
+
+// GetOrganization returns the organization assigned to the context, or error if there
+// is any error or there isn't an organization.
+func GetOrganization(ctx context.Context) (*domain.Organization, error) {
+	tmp := ctx.Value(OrganizationCtxKey)
+	if tmp == nil {
+		return nil, errMissingCtxOrganization
+	}
+	org, ok := tmp.(*domain.Organization)
+	if !ok {
+		return nil, errWrongOrganizationValue
+	}
+	return org, nil
+}
+
+// OrganizationCtx loads the organization of the request when the user is one of its
+// members, along with the role of the user over the organization repositories.
+func OrganizationCtx(service organizing.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			u, err := GetUser(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			id, err := kallax.NewULIDFromText(chi.URLParam(r, "orgId"))
+			if err != nil {
+				render.JSON.BadRequest(w, errInvalidOrganizationID)
+				return
+			}
+
+			org, err := service.GetOrganization(id, u)
+			if err != nil {
+				if isNotFound(err) {
+					render.JSON.NotFound(w, errMissingOrganization)
+					return
+				}
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			role, err := service.GetOrganizationRole(org, u)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			ctx := withRole(withOrganization(r.Context(), org), role)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+// OrganizationRole allows the members of the organization with at least the given
+// role over its repositories.
+func OrganizationRole(role domain.Role) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			org, err := GetOrganization(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+			if !GetRole(r.Context()).Includes(role) {
+				errMsg := fmt.Sprintf("You don't have permission to access organization %s", org.ID)
+				render.JSON.Response(w, http.StatusForbidden, forbidden(errMsg))
+				return
+			}
+			next.ServeHTTP(w, r)
+		}
+		return http.HandlerFunc(fn)
+	}
+}
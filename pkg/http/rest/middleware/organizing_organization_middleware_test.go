@@ -0,0 +1,121 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/organizing"
+)
+
+type mockOrganizingService struct {
+	org     *domain.Organization
+	role    domain.Role
+	err     error
+	roleErr error
+}
+
+func (m *mockOrganizingService) CreateOrganization(*domain.User, organizing.Payload) (*domain.Organization, error) {
+	return m.org, m.err
+}
+func (m *mockOrganizingService) ListOrganizations(*domain.User) ([]domain.Organization, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) GetOrganization(kallax.ULID, *domain.User) (*domain.Organization, error) {
+	return m.org, m.err
+}
+func (m *mockOrganizingService) GetOrganizationRole(*domain.Organization, *domain.User) (domain.Role, error) {
+	return m.role, m.roleErr
+}
+func (m *mockOrganizingService) AddMember(*domain.Organization, organizing.MemberPayload) (*domain.Member, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) ListMembers(*domain.Organization) ([]domain.Member, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) RemoveMember(kallax.ULID, *domain.Organization) error { return m.err }
+func (m *mockOrganizingService) CreateTeam(*domain.Organization, organizing.TeamPayload) (*domain.Team, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) ListTeams(*domain.Organization) ([]domain.Team, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) RemoveTeam(kallax.ULID, *domain.Organization) error { return m.err }
+func (m *mockOrganizingService) AddTeamMember(kallax.ULID, *domain.Organization, organizing.TeamMemberPayload) (*domain.TeamMember, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) RemoveTeamMember(kallax.ULID, kallax.ULID, *domain.Organization) error {
+	return m.err
+}
+func (m *mockOrganizingService) GetRole(*domain.Repository, *domain.User) (domain.Role, error) {
+	return m.role, m.roleErr
+}
+
+var defaultOrg = &domain.Organization{ID: kallax.NewULID(), Name: "acme"}
+
+func setupOrganizationCtx(service organizing.Service, role domain.Role, middlewares ...func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Route("/{orgId}", func(r chi.Router) {
+		for _, m := range middlewares {
+			r.Use(m)
+		}
+		r.Use(middleware.OrganizationCtx(service))
+		r.With(middleware.OrganizationRole(role)).Get("/", func(w http.ResponseWriter, r *http.Request) {
+			if _, err := middleware.GetOrganization(r.Context()); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			w.Write([]byte(middleware.GetRole(r.Context())))
+		})
+	})
+	return app
+}
+
+func TestOrganizationCtxSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockOrganizingService{org: defaultOrg, role: domain.OwnerRole}
+	e := bastion.Tester(t, setupOrganizationCtx(s, domain.AdminRole, withUserMiddle(defaultUser)))
+	e.GET("/" + defaultOrg.ID.String()).
+		Expect().
+		Status(http.StatusOK).
+		Body().Equal("owner")
+}
+
+func TestOrganizationCtxFails(t *testing.T) {
+	t.Parallel()
+
+	id := defaultOrg.ID.String()
+	tt := []struct {
+		name        string
+		service     *mockOrganizingService
+		role        domain.Role
+		middlewares []func(http.Handler) http.Handler
+		id          string
+		status      int
+		message     string
+	}{
+		{"missing user", &mockOrganizingService{}, domain.ReadRole, nil, id, http.StatusInternalServerError, "looks like something went wrong"},
+		{"invalid id", &mockOrganizingService{}, domain.ReadRole, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, "abc", http.StatusBadRequest, "invalid organization id"},
+		{"not found", &mockOrganizingService{err: notFound("test")}, domain.ReadRole, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, id, http.StatusNotFound, "not found organization"},
+		{"service err", &mockOrganizingService{err: errors.New("test")}, domain.ReadRole, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, id, http.StatusInternalServerError, "looks like something went wrong"},
+		{"role err", &mockOrganizingService{org: defaultOrg, roleErr: errors.New("test")}, domain.ReadRole, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, id, http.StatusInternalServerError, "looks like something went wrong"},
+		{"without role", &mockOrganizingService{org: defaultOrg, role: domain.WriteRole}, domain.OwnerRole, []func(http.Handler) http.Handler{withUserMiddle(defaultUser)}, id, http.StatusForbidden, "You don't have permission to access organization " + id},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupOrganizationCtx(tc.service, tc.role, tc.middlewares...))
+			e.GET("/"+tc.id).
+				Expect().
+				Status(tc.status).
+				JSON().Object().ValueEqual("message", tc.message)
+		})
+	}
+}
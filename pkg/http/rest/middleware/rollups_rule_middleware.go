@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/rollups"
+)
+
+var (
+	// RollupRuleCtxKey is the context.Context key to store the RollupRule for a request.
+	RollupRuleCtxKey = &contextKey{"RollupRule"}
+)
+var (
+	errMissingCtxRollupRule = errors.New("rollup rule not found in context")
+	errWrongRollupRuleValue = errors.New("rollup rule value set incorrectly in context")
+	errMissingRollupRule    = errors.New("not found rollup rule")
+	errInvalidRollupRuleID  = errors.New("invalid rollup rule id")
+)
+
+func withRollupRule(ctx context.Context, rule *domain.RollupRule) context.Context {
+	return context.WithValue(ctx, RollupRuleCtxKey, rule)
+}
+
+// GetRollupRule returns the rollup rule assigned to the context, or error if there
+// is any error or there isn't a rollup rule.
+func GetRollupRule(ctx context.Context) (*domain.RollupRule, error) {
+	tmp := ctx.Value(RollupRuleCtxKey)
+	if tmp == nil {
+		return nil, errMissingCtxRollupRule
+	}
+	rule, ok := tmp.(*domain.RollupRule)
+	if !ok {
+		return nil, errWrongRollupRuleValue
+	}
+	return rule, nil
+}
+
+func RollupRuleCtx(service rollups.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ruleID := chi.URLParam(r, "ruleId")
+			repo, err := GetRepo(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			id, err := kallax.NewULIDFromText(ruleID)
+			if err != nil {
+				render.JSON.BadRequest(w, errInvalidRollupRuleID)
+				return
+			}
+
+			rule, err := service.GetRule(id, repo)
+			if err != nil {
+				if isNotFound(err) {
+					render.JSON.NotFound(w, errMissingRollupRule)
+					return
+				}
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			ctx := withRollupRule(r.Context(), rule)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
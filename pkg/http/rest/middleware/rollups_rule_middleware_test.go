@@ -0,0 +1,132 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/rollups"
+)
+
+type mockRollupsService struct {
+	rule *domain.RollupRule
+	err  error
+}
+
+func (m *mockRollupsService) CreateRule(*domain.Repository, rollups.Payload) (*domain.RollupRule, error) {
+	return m.rule, m.err
+}
+func (m *mockRollupsService) ListRules(*domain.Repository) ([]domain.RollupRule, error) {
+	return nil, m.err
+}
+func (m *mockRollupsService) GetRule(kallax.ULID, *domain.Repository) (*domain.RollupRule, error) {
+	return m.rule, m.err
+}
+func (m *mockRollupsService) RemoveRule(*domain.RollupRule) error     { return m.err }
+func (m *mockRollupsService) Run(time.Time) ([]rollups.Report, error) { return nil, m.err }
+
+func setupRollupRuleCtx(service rollups.Service, getRepo func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Route("/{ruleId}", func(r chi.Router) {
+		r.Use(getRepo)
+		r.Use(middleware.RollupRuleCtx(service))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			if _, err := middleware.GetRollupRule(r.Context()); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			handler(w, r)
+		})
+	})
+	return app
+}
+
+func TestRollupRuleCtxSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockRollupsService{rule: &domain.RollupRule{}}
+	app := setupRollupRuleCtx(s, withRepoMiddle(defaultRepo))
+	e := bastion.Tester(t, app)
+	e.GET("/0167c8a5-d308-8692-809d-b1ad4a2d9562").
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestRollupRuleCtxFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name     string
+		service  *mockRollupsService
+		getRepo  func(http.Handler) http.Handler
+		id       string
+		status   int
+		response map[string]interface{}
+	}{
+		{
+			name:    "missing repo",
+			service: &mockRollupsService{},
+			getRepo: withRepoMiddle(nil),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusInternalServerError,
+			response: map[string]interface{}{
+				"status":  500.0,
+				"error":   "Internal Server Error",
+				"message": "looks like something went wrong",
+			},
+		},
+		{
+			name:    "invalid id",
+			service: &mockRollupsService{},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "abc",
+			status:  http.StatusBadRequest,
+			response: map[string]interface{}{
+				"status":  400.0,
+				"error":   "Bad Request",
+				"message": "invalid rollup rule id",
+			},
+		},
+		{
+			name:    "not found",
+			service: &mockRollupsService{err: notFound("test")},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusNotFound,
+			response: map[string]interface{}{
+				"status":  404.0,
+				"error":   "Not Found",
+				"message": "not found rollup rule",
+			},
+		},
+		{
+			name:    "service err",
+			service: &mockRollupsService{err: errors.New("test")},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusInternalServerError,
+			response: map[string]interface{}{
+				"status":  500.0,
+				"error":   "Internal Server Error",
+				"message": "looks like something went wrong",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupRollupRuleCtx(tc.service, tc.getRepo))
+			e.GET("/" + tc.id).
+				Expect().
+				Status(tc.status).
+				JSON().Object().Equal(tc.response)
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/sharing"
+)
+
+const (
+	// ShareTokenHeader is the request header used to send a share token.
+	ShareTokenHeader = "X-Share-Token"
+	// ShareTokenParam is the query param used to send a share token.
+	ShareTokenParam = "share"
+)
+
+var (
+	// ShareLinkCtxKey is the context.Context key to store the share link for a request.
+	ShareLinkCtxKey = &contextKey{"ShareLink"}
+	// AuthShareCtxKey is the context.Context key to store the share link used to authorize a request.
+	AuthShareCtxKey = &contextKey{"AuthShare"}
+)
+var (
+	errMissingCtxShareLink = errors.New("share link not found in context")
+	errWrongShareLinkValue = errors.New("share link value set incorrectly in context")
+	errMissingShareLink    = errors.New("not found share link")
+	errInvalidShareLinkID  = errors.New("invalid share link id")
+	errMissingAuthShare    = errors.New("request not authorized with a share link")
+	errWrongAuthShareValue = errors.New("authorization share link value set incorrectly in context")
+)
+
+func withShareLink(ctx context.Context, l *domain.ShareLink) context.Context {
+	return context.WithValue(ctx, ShareLinkCtxKey, l)
+}
+
+// GetShareLink returns the share link assigned to the context, or error if there
+// is any error or there isn't a share link.
+func GetShareLink(ctx context.Context) (*domain.ShareLink, error) {
+	tmp := ctx.Value(ShareLinkCtxKey)
+	if tmp == nil {
+		return nil, errMissingCtxShareLink
+	}
+	l, ok := tmp.(*domain.ShareLink)
+	if !ok {
+		return nil, errWrongShareLinkValue
+	}
+	return l, nil
+}
+
+func withAuthShare(ctx context.Context, l *domain.ShareLink) context.Context {
+	return context.WithValue(ctx, AuthShareCtxKey, l)
+}
+
+// GetAuthShare returns the share link used to authorize the request, or error if
+// the request wasn't authorized with a share link.
+func GetAuthShare(ctx context.Context) (*domain.ShareLink, error) {
+	tmp := ctx.Value(AuthShareCtxKey)
+	if tmp == nil {
+		return nil, errMissingAuthShare
+	}
+	l, ok := tmp.(*domain.ShareLink)
+	if !ok {
+		return nil, errWrongAuthShareValue
+	}
+	return l, nil
+}
+
+func shareToken(r *http.Request) string {
+	if t := r.Header.Get(ShareTokenHeader); t != "" {
+		return t
+	}
+	return r.URL.Query().Get(ShareTokenParam)
+}
+
+// AuthorizeShareOr accepts a share token sent in the X-Share-Token header or the share
+// query param instead of an user. Requests without share token are authorized by auth.
+// The link is kept in the context so the permission middlewares only grant read access
+// over its repository.
+func AuthorizeShareOr(service sharing.Service, auth func(next http.Handler) http.Handler) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		authorized := auth(next)
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			t := shareToken(r)
+			if t == "" {
+				authorized.ServeHTTP(w, r)
+				return
+			}
+
+			l, err := service.AuthorizeLink(t)
+			if err != nil {
+				if isNotAuthorized(err) {
+					httpErr := render.HTTPError{
+						Status:  http.StatusUnauthorized,
+						Error:   http.StatusText(http.StatusUnauthorized),
+						Message: err.Error(),
+					}
+					render.JSON.Response(w, http.StatusUnauthorized, httpErr)
+					return
+				}
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			ctx := withAuthShare(r.Context(), l)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func ShareLinkCtx(service sharing.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			shareID := chi.URLParam(r, "shareId")
+			repo, err := GetRepo(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			id, err := kallax.NewULIDFromText(shareID)
+			if err != nil {
+				render.JSON.BadRequest(w, errInvalidShareLinkID)
+				return
+			}
+
+			l, err := service.GetLink(id, repo)
+			if err != nil {
+				if isNotFound(err) {
+					render.JSON.NotFound(w, errMissingShareLink)
+					return
+				}
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			ctx := withShareLink(r.Context(), l)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
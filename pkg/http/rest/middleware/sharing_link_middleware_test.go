@@ -0,0 +1,230 @@
+package middleware_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/sharing"
+)
+
+type mockSharingService struct {
+	link *domain.ShareLink
+	err  error
+}
+
+func (m *mockSharingService) CreateLink(*domain.User, *domain.Repository, sharing.Payload) (*sharing.Link, error) {
+	return nil, m.err
+}
+func (m *mockSharingService) ListLinks(*domain.Repository) ([]domain.ShareLink, error) {
+	return nil, m.err
+}
+func (m *mockSharingService) GetLink(kallax.ULID, *domain.Repository) (*domain.ShareLink, error) {
+	return m.link, m.err
+}
+func (m *mockSharingService) RevokeLink(*domain.ShareLink) error { return m.err }
+func (m *mockSharingService) AuthorizeLink(string) (*domain.ShareLink, error) {
+	return m.link, m.err
+}
+
+func setupAuthorizeShareOr(service sharing.Service) *bastion.Bastion {
+	app := bastion.New()
+	app.Route("/", func(r chi.Router) {
+		r.Use(middleware.AuthorizeShareOr(service, withUserMiddle(defaultUser)))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			if _, err := middleware.GetAuthShare(r.Context()); err != nil {
+				fmt.Fprint(w, "user")
+				return
+			}
+			if _, err := middleware.GetUser(r.Context()); err == nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			fmt.Fprint(w, "share")
+		})
+	})
+	return app
+}
+
+func TestAuthorizeShareOrSuccess(t *testing.T) {
+	t.Parallel()
+
+	e := bastion.Tester(t, setupAuthorizeShareOr(&mockSharingService{link: &domain.ShareLink{}}))
+	e.GET("/").Expect().Status(http.StatusOK).Body().Equal("user")
+	e.GET("/").WithHeader("X-Share-Token", "shr_abc").Expect().Status(http.StatusOK).Body().Equal("share")
+	e.GET("/").WithQuery("share", "shr_abc").Expect().Status(http.StatusOK).Body().Equal("share")
+}
+
+func TestAuthorizeShareOrFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name     string
+		service  *mockSharingService
+		status   int
+		response map[string]interface{}
+	}{
+		{
+			name:    "invalid link",
+			service: &mockSharingService{err: notAllowedErr("invalid, expired or revoked share link")},
+			status:  http.StatusUnauthorized,
+			response: map[string]interface{}{
+				"status":  401.0,
+				"error":   "Unauthorized",
+				"message": "invalid, expired or revoked share link",
+			},
+		},
+		{
+			name:    "service err",
+			service: &mockSharingService{err: errors.New("test")},
+			status:  http.StatusInternalServerError,
+			response: map[string]interface{}{
+				"status":  500.0,
+				"error":   "Internal Server Error",
+				"message": "looks like something went wrong",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupAuthorizeShareOr(tc.service))
+			e.GET("/").
+				WithQuery("share", "shr_abc").
+				Expect().
+				Status(tc.status).
+				JSON().Object().Equal(tc.response)
+		})
+	}
+}
+
+func TestContextGetAuthShare(t *testing.T) {
+	t.Parallel()
+
+	_, err := middleware.GetAuthShare(context.Background())
+	assert.EqualError(t, err, "request not authorized with a share link")
+
+	ctx := context.WithValue(context.Background(), middleware.AuthShareCtxKey, "test")
+	_, err = middleware.GetAuthShare(ctx)
+	assert.EqualError(t, err, "authorization share link value set incorrectly in context")
+}
+
+func withAuthShareMiddle(l *domain.ShareLink) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), middleware.AuthShareCtxKey, l)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func TestRepoPermissionsWithShareLink(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID(), Visibility: domain.Private, UserID: kallax.NewULID()}
+	expiresAt := time.Now().Add(time.Hour)
+	link := &domain.ShareLink{RepositoryID: repo.ID, ExpiresAt: expiresAt}
+	expired := &domain.ShareLink{RepositoryID: repo.ID, ExpiresAt: time.Now().Add(-time.Hour)}
+	otherRepoLink := &domain.ShareLink{RepositoryID: kallax.NewULID(), ExpiresAt: expiresAt}
+
+	tt := []struct {
+		name       string
+		link       *domain.ShareLink
+		permission func(http.Handler) http.Handler
+		status     int
+	}{
+		{"reader", link, middleware.RepoOwnerOrPublic(), http.StatusOK},
+		{"captures reader", link, middleware.RepoOwnerOrPublic(domain.CapturesRead), http.StatusOK},
+		{"expired reader", expired, middleware.RepoOwnerOrPublic(), http.StatusForbidden},
+		{"reader from other repo", otherRepoLink, middleware.RepoOwnerOrPublic(), http.StatusForbidden},
+		{"writer", link, middleware.RepoCollaborator(domain.WriteRole, domain.CapturesWrite), http.StatusForbidden},
+		{"owner", link, middleware.RepoOwner(), http.StatusForbidden},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			app := bastion.New()
+			app.Route("/", func(r chi.Router) {
+				r.Use(withAuthShareMiddle(tc.link))
+				r.Use(withRepoMiddle(repo))
+				r.Use(tc.permission)
+				r.Get("/", handler)
+			})
+			e := bastion.Tester(t, app)
+			e.GET("/").Expect().Status(tc.status)
+		})
+	}
+}
+
+func TestRoleCtxSkipsShareLink(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID(), UserID: kallax.NewULID()}
+	e := bastion.Tester(t, setupRoleCtx(&mockCollaboratingService{role: domain.WriteRole}, withAuthShareMiddle(&domain.ShareLink{}), withRepoMiddle(repo)))
+	e.GET("/").Expect().Status(http.StatusOK).Body().Equal("")
+}
+
+func setupShareLinkCtx(service sharing.Service, getRepo func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Route("/{shareId}", func(r chi.Router) {
+		r.Use(getRepo)
+		r.Use(middleware.ShareLinkCtx(service))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			if _, err := middleware.GetShareLink(r.Context()); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			handler(w, r)
+		})
+	})
+	return app
+}
+
+func TestShareLinkCtxSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockSharingService{link: &domain.ShareLink{}}
+	e := bastion.Tester(t, setupShareLinkCtx(s, withRepoMiddle(defaultRepo)))
+	e.GET("/0167c8a5-d308-8692-809d-b1ad4a2d9562").
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestShareLinkCtxFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		service *mockSharingService
+		getRepo func(http.Handler) http.Handler
+		id      string
+		status  int
+		message string
+	}{
+		{"missing repo", &mockSharingService{}, withRepoMiddle(nil), "0167c8a5-d308-8692-809d-b1ad4a2d9562", http.StatusInternalServerError, "looks like something went wrong"},
+		{"invalid id", &mockSharingService{}, withRepoMiddle(defaultRepo), "abc", http.StatusBadRequest, "invalid share link id"},
+		{"not found", &mockSharingService{err: notFound("test")}, withRepoMiddle(defaultRepo), "0167c8a5-d308-8692-809d-b1ad4a2d9562", http.StatusNotFound, "not found share link"},
+		{"service err", &mockSharingService{err: errors.New("test")}, withRepoMiddle(defaultRepo), "0167c8a5-d308-8692-809d-b1ad4a2d9562", http.StatusInternalServerError, "looks like something went wrong"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupShareLinkCtx(tc.service, tc.getRepo))
+			e.GET("/"+tc.id).
+				Expect().
+				Status(tc.status).
+				JSON().Object().ValueEqual("message", tc.message)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion/render"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/webhooks"
+)
+
+var (
+	// WebhookCtxKey is the context.Context key to store the Webhook for a request.
+	WebhookCtxKey = &contextKey{"Webhook"}
+)
+var (
+	errMissingCtxWebhook = errors.New("webhook not found in context")
+	errWrongWebhookValue = errors.New("webhook value set incorrectly in context")
+	errMissingWebhook    = errors.New("not found webhook")
+	errInvalidWebhookID  = errors.New("invalid webhook id")
+)
+
+func withWebhook(ctx context.Context, h *domain.Webhook) context.Context {
+	return context.WithValue(ctx, WebhookCtxKey, h)
+}
+
+// GetWebhook returns the webhook assigned to the context, or error if there
+// is any error or there isn't a webhook.
+func GetWebhook(ctx context.Context) (*domain.Webhook, error) {
+	tmp := ctx.Value(WebhookCtxKey)
+	if tmp == nil {
+		return nil, errMissingCtxWebhook
+	}
+	h, ok := tmp.(*domain.Webhook)
+	if !ok {
+		return nil, errWrongWebhookValue
+	}
+	return h, nil
+}
+
+func WebhookCtx(service webhooks.Service) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			webhookID := chi.URLParam(r, "webhookId")
+			repo, err := GetRepo(r.Context())
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			id, err := kallax.NewULIDFromText(webhookID)
+			if err != nil {
+				render.JSON.BadRequest(w, errInvalidWebhookID)
+				return
+			}
+
+			h, err := service.GetWebhook(id, repo)
+			if err != nil {
+				if isNotFound(err) {
+					render.JSON.NotFound(w, errMissingWebhook)
+					return
+				}
+				fmt.Fprintln(os.Stderr, err)
+				render.JSON.InternalServerError(w, err)
+				return
+			}
+
+			ctx := withWebhook(r.Context(), h)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
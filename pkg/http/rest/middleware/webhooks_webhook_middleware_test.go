@@ -0,0 +1,134 @@
+package middleware_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/ifreddyrondon/bastion"
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/webhooks"
+)
+
+type mockWebhooksService struct {
+	webhook *domain.Webhook
+	err     error
+}
+
+func (m *mockWebhooksService) CreateWebhook(*domain.Repository, webhooks.Payload) (*domain.Webhook, error) {
+	return m.webhook, m.err
+}
+func (m *mockWebhooksService) ListWebhooks(*domain.Repository) ([]domain.Webhook, error) {
+	return nil, m.err
+}
+func (m *mockWebhooksService) GetWebhook(kallax.ULID, *domain.Repository) (*domain.Webhook, error) {
+	return m.webhook, m.err
+}
+func (m *mockWebhooksService) RemoveWebhook(*domain.Webhook) error { return m.err }
+func (m *mockWebhooksService) ListDeliveries(*domain.Webhook, *listing.Listing) (*webhooks.ListDeliveryResponse, error) {
+	return nil, m.err
+}
+
+func setupWebhookCtx(service webhooks.Service, getRepo func(http.Handler) http.Handler) *bastion.Bastion {
+	app := bastion.New()
+	app.Route("/{webhookId}", func(r chi.Router) {
+		r.Use(getRepo)
+		r.Use(middleware.WebhookCtx(service))
+		r.Get("/", func(w http.ResponseWriter, r *http.Request) {
+			if _, err := middleware.GetWebhook(r.Context()); err != nil {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			handler(w, r)
+		})
+	})
+	return app
+}
+
+func TestWebhookCtxSuccess(t *testing.T) {
+	t.Parallel()
+
+	s := &mockWebhooksService{webhook: &domain.Webhook{}}
+	app := setupWebhookCtx(s, withRepoMiddle(defaultRepo))
+	e := bastion.Tester(t, app)
+	e.GET("/0167c8a5-d308-8692-809d-b1ad4a2d9562").
+		Expect().
+		Status(http.StatusOK)
+}
+
+func TestWebhookCtxFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name     string
+		service  *mockWebhooksService
+		getRepo  func(http.Handler) http.Handler
+		id       string
+		status   int
+		response map[string]interface{}
+	}{
+		{
+			name:    "missing repo",
+			service: &mockWebhooksService{},
+			getRepo: withRepoMiddle(nil),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusInternalServerError,
+			response: map[string]interface{}{
+				"status":  500.0,
+				"error":   "Internal Server Error",
+				"message": "looks like something went wrong",
+			},
+		},
+		{
+			name:    "invalid id",
+			service: &mockWebhooksService{},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "abc",
+			status:  http.StatusBadRequest,
+			response: map[string]interface{}{
+				"status":  400.0,
+				"error":   "Bad Request",
+				"message": "invalid webhook id",
+			},
+		},
+		{
+			name:    "not found",
+			service: &mockWebhooksService{err: notFound("test")},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusNotFound,
+			response: map[string]interface{}{
+				"status":  404.0,
+				"error":   "Not Found",
+				"message": "not found webhook",
+			},
+		},
+		{
+			name:    "service err",
+			service: &mockWebhooksService{err: errors.New("test")},
+			getRepo: withRepoMiddle(defaultRepo),
+			id:      "0167c8a5-d308-8692-809d-b1ad4a2d9562",
+			status:  http.StatusInternalServerError,
+			response: map[string]interface{}{
+				"status":  500.0,
+				"error":   "Internal Server Error",
+				"message": "looks like something went wrong",
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e := bastion.Tester(t, setupWebhookCtx(tc.service, tc.getRepo))
+			e.GET("/" + tc.id).
+				Expect().
+				Status(tc.status).
+				JSON().Object().Equal(tc.response)
+		})
+	}
+}
@@ -7,47 +7,153 @@ import (
 	"github.com/sarulabs/di"
 
 	"github.com/ifreddyrondon/capture/pkg/adding"
+	"github.com/ifreddyrondon/capture/pkg/administering"
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
+	"github.com/ifreddyrondon/capture/pkg/auditing"
 	"github.com/ifreddyrondon/capture/pkg/authenticating"
 	"github.com/ifreddyrondon/capture/pkg/authorizing"
+	"github.com/ifreddyrondon/capture/pkg/collaborating"
 	"github.com/ifreddyrondon/capture/pkg/creating"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/exporting"
+	"github.com/ifreddyrondon/capture/pkg/geofencing"
 	"github.com/ifreddyrondon/capture/pkg/getting"
 	"github.com/ifreddyrondon/capture/pkg/http/rest/handler"
 	"github.com/ifreddyrondon/capture/pkg/http/rest/middleware"
+	"github.com/ifreddyrondon/capture/pkg/importing"
+	"github.com/ifreddyrondon/capture/pkg/limiting"
 	"github.com/ifreddyrondon/capture/pkg/listing"
+	"github.com/ifreddyrondon/capture/pkg/organizing"
+	"github.com/ifreddyrondon/capture/pkg/quotas"
 	"github.com/ifreddyrondon/capture/pkg/removing"
+	"github.com/ifreddyrondon/capture/pkg/retention"
+	"github.com/ifreddyrondon/capture/pkg/rollups"
+	"github.com/ifreddyrondon/capture/pkg/sharing"
 	"github.com/ifreddyrondon/capture/pkg/signup"
+	"github.com/ifreddyrondon/capture/pkg/streaming"
+	"github.com/ifreddyrondon/capture/pkg/token"
+	"github.com/ifreddyrondon/capture/pkg/twofactor"
 	"github.com/ifreddyrondon/capture/pkg/updating"
+	"github.com/ifreddyrondon/capture/pkg/verifying"
+	"github.com/ifreddyrondon/capture/pkg/webhooks"
 )
 
 // Router returns a configured http.Handler with app resources.
 func Router(resources di.Container) http.Handler {
 	r := chi.NewRouter()
 
+	limitingService := resources.Get("limiting-service").(limiting.Service)
+	limits := resources.Get("rate-limits").(limiting.Limits)
+	authLimitMiddleware := middleware.RateLimit(limitingService, "auth", limits.Auth)
+	capturesWriteLimitMiddleware := middleware.RateLimit(limitingService, "captures-write", limits.CapturesWrite)
+	listingsLimitMiddleware := middleware.RateLimit(limitingService, "listings", limits.Listings)
+
+	auditingService := resources.Get("auditing-service").(auditing.Service)
+	loginAuditMiddleware := middleware.AuditAttempt(auditingService, domain.LoginSucceeded, domain.LoginFailed)
+	tokenRefreshedAuditMiddleware := middleware.Audit(auditingService, domain.TokenRefreshed)
+	oauthTokenIssuedAuditMiddleware := middleware.Audit(auditingService, domain.OAuthTokenIssued)
+	repoCreatedAuditMiddleware := middleware.Audit(auditingService, domain.RepoCreated)
+	repoVisibilityAuditMiddleware := middleware.Audit(auditingService, domain.RepoVisibilityChanged)
+	captureAddedAuditMiddleware := middleware.Audit(auditingService, domain.CaptureAdded)
+	captureChangedAuditMiddleware := middleware.Audit(auditingService, domain.CaptureChanged)
+	captureDeletedAuditMiddleware := middleware.Audit(auditingService, domain.CaptureDeleted)
+	apiKeyCreatedAuditMiddleware := middleware.Audit(auditingService, domain.APIKeyCreated)
+	apiKeyRevokedAuditMiddleware := middleware.Audit(auditingService, domain.APIKeyRevoked)
+	shareLinkCreatedAuditMiddleware := middleware.Audit(auditingService, domain.ShareLinkCreated)
+	shareLinkRevokedAuditMiddleware := middleware.Audit(auditingService, domain.ShareLinkRevoked)
+	collaboratorInvitedAuditMiddleware := middleware.Audit(auditingService, domain.CollaboratorInvited)
+	collaboratorRemovedAuditMiddleware := middleware.Audit(auditingService, domain.CollaboratorRemoved)
+	invitationAcceptedAuditMiddleware := middleware.Audit(auditingService, domain.InvitationAccepted)
+	memberAddedAuditMiddleware := middleware.Audit(auditingService, domain.MemberAdded)
+	memberRemovedAuditMiddleware := middleware.Audit(auditingService, domain.MemberRemoved)
+	teamMemberAddedAuditMiddleware := middleware.Audit(auditingService, domain.TeamMemberAdded)
+	teamMemberRemovedAuditMiddleware := middleware.Audit(auditingService, domain.TeamMemberRemoved)
+	listingAuditEntriesMiddleware := chi.Chain(listingsLimitMiddleware, middleware.FilterAuditEntries()).Handler
+	listingAuditEntriesHandler := handler.ListingAuditEntries(auditingService)
+	listingRepoAuditEntriesHandler := handler.ListingRepoAuditEntries(auditingService)
+
 	signUpService := resources.Get("sign_up-service").(signup.Service)
 	signUpHandler := handler.SignUp(signUpService)
 	authorizeService := resources.Get("authorize-service").(authorizing.Service)
 	authorizeMiddleware := middleware.AuthorizeReq(authorizeService)
+	authorizeOrKeyMiddleware := middleware.AuthorizeReqOrKey(authorizeService)
+	sharingService := resources.Get("sharing-service").(sharing.Service)
+	authorizeOrShareMiddleware := middleware.AuthorizeShareOr(sharingService, authorizeOrKeyMiddleware)
 	authenticatingService := resources.Get("authenticating-service").(authenticating.Service)
-	authenticatingHandler := handler.Authenticating(authenticatingService)
+	refreshService := resources.Get("refresh-service").(authenticating.RefreshService)
+	authenticatingHandler := handler.Authenticating(authenticatingService, refreshService)
+	completingChallengeHandler := handler.CompletingChallenge(authenticatingService, refreshService)
+	refreshingTokenHandler := handler.RefreshingToken(refreshService)
+	loggingOutHandler := handler.LoggingOut(refreshService)
+	unlockingLoginHandler := handler.UnlockingLogin(authenticatingService)
+	adminMiddleware := middleware.Admin()
+	notImpersonatedMiddleware := middleware.NotImpersonated()
+	administeringService := resources.Get("administering-service").(administering.Service)
+	listingUsersMiddleware := chi.Chain(listingsLimitMiddleware, middleware.FilterUsers()).Handler
+	listingUsersHandler := handler.ListingUsers(administeringService)
+	disablingUserHandler := handler.DisablingUser(administeringService)
+	enablingUserHandler := handler.EnablingUser(administeringService)
+	impersonatingUserHandler := handler.ImpersonatingUser(administeringService)
+	gettingAnyRepoHandler := handler.GettingAnyRepo(administeringService)
+	transferringRepoHandler := handler.TransferringRepo(administeringService)
+	verifyingService := resources.Get("verifying-service").(verifying.Service)
+	verifyingEmailHandler := handler.VerifyingEmail(verifyingService)
+	resendingVerificationHandler := handler.ResendingVerification(verifyingService)
+	forgettingPasswordHandler := handler.ForgettingPassword(verifyingService)
+	resettingPasswordHandler := handler.ResettingPassword(verifyingService)
+	confirmingEmailChangeHandler := handler.ConfirmingEmailChange(verifyingService)
+
+	quotasService := resources.Get("quotas-service").(quotas.Service)
+	gettingUserHandler := handler.GettingUser(quotasService)
+	updatingUserService := resources.Get("updating-user-service").(updating.UserService)
+	updatingUserHandler := handler.UpdatingUser(updatingUserService)
+	changingPasswordHandler := handler.ChangingPassword(updatingUserService)
+	removingUserService := resources.Get("removing-user-service").(removing.UserService)
+	removingUserHandler := handler.RemovingUser(removingUserService)
+	twoFactorService := resources.Get("two_factor-service").(twofactor.Service)
+	enrollingTwoFactorHandler := handler.EnrollingTwoFactor(twoFactorService)
+	confirmingTwoFactorHandler := handler.ConfirmingTwoFactor(twoFactorService)
+	disablingTwoFactorHandler := handler.DisablingTwoFactor(twoFactorService)
+	regeneratingRecoveryCodesHandler := handler.RegeneratingRecoveryCodes(twoFactorService)
+	oauthService := resources.Get("oauth-service").(authenticating.OAuthService)
+	issuingOAuthTokenHandler := handler.IssuingOAuthToken(oauthService)
+	promptingConsentHandler := handler.PromptingConsent(oauthService)
+	consentingAuthorizationHandler := handler.ConsentingAuthorization(oauthService)
+	registeringOAuthClientHandler := handler.RegisteringOAuthClient(oauthService)
+	listingOAuthClientsHandler := handler.ListingOAuthClients(oauthService)
+	removingOAuthClientHandler := handler.RemovingOAuthClient(oauthService)
+	listingOAuthConsentsHandler := handler.ListingOAuthConsents(oauthService)
+	revokingOAuthConsentHandler := handler.RevokingOAuthConsent(oauthService)
 
 	creatingRepoService := resources.Get("creating-repo-service").(creating.Service)
 	creatingRepoHandler := handler.Creating(creatingRepoService)
-	listingUserReposMiddleware := middleware.FilterUserRepos()
+	listingUserReposMiddleware := chi.Chain(listingsLimitMiddleware, middleware.FilterUserRepos()).Handler
 	listingRepoService := resources.Get("listing-repo-services").(listing.RepoService)
 	listingUserReposHandler := handler.ListingUserRepos(listingRepoService)
-	listingPublicReposMiddleware := middleware.FilterPublicRepos()
+	listingPublicReposMiddleware := chi.Chain(listingsLimitMiddleware, middleware.FilterPublicRepos()).Handler
 	listingPublicReposHandler := handler.ListingPublicRepos(listingRepoService)
 	gettingRepoService := resources.Get("getting-repo-service").(getting.RepoService)
 	ctxRepoMiddleware := middleware.RepoCtx(gettingRepoService)
-	repoOwnerOrPublicMiddleware := middleware.RepoOwnerOrPublic()
+	collaboratingService := resources.Get("collaborating-service").(collaborating.Service)
+	organizingService := resources.Get("organizing-service").(organizing.Service)
+	ctxRoleMiddleware := middleware.RoleCtx(collaboratingService, organizingService)
+	repoOwnerOrPublicMiddleware := middleware.RepoOwnerOrPublic(domain.ReposRead)
 	repoOwnerMiddleware := middleware.RepoOwner()
-	gettingRepoHandler := handler.GettingRepo()
+	repoAdminMiddleware := middleware.RepoCollaborator(domain.AdminRole)
+	capturesReaderMiddleware := middleware.RepoOwnerOrPublic(domain.CapturesRead)
+	capturesWriterMiddleware := chi.Chain(middleware.RepoCollaborator(domain.WriteRole, domain.CapturesWrite), capturesWriteLimitMiddleware).Handler
+	gettingRepoHandler := handler.GettingRepo(quotasService)
+	updatingRepoService := resources.Get("updating-repo-service").(updating.RepoService)
+	updatingRepoHandler := handler.UpdatingRepo(updatingRepoService)
 
 	addingCaptureService := resources.Get("adding-capture-service").(adding.CaptureService)
 	addingCaptureHandler := handler.AddingCapture(addingCaptureService)
 	addingMultiCaptureService := resources.Get("adding-multi-capture-service").(adding.MultiCaptureService)
 	addingMultiCaptureHandler := handler.AddingMultiCapture(addingMultiCaptureService)
-	listingCapturesMiddleware := middleware.FilterCaptures()
+	ingestAuthorization := chi.Chain(authorizeOrShareMiddleware, ctxRepoMiddleware, ctxRoleMiddleware, middleware.RepoCollaborator(domain.WriteRole, domain.CapturesWrite)).Handler
+	ingestMessage := chi.Chain(capturesWriteLimitMiddleware, captureAddedAuditMiddleware).Handler
+	ingestingCapturesHandler := handler.IngestingCaptures(addingCaptureService, ingestAuthorization, ingestMessage)
+	listingCapturesMiddleware := chi.Chain(listingsLimitMiddleware, middleware.FilterCaptures()).Handler
 	listingCaptureService := resources.Get("listing-capture-services").(listing.CaptureService)
 	listingCapturesHandler := handler.ListingRepoCaptures(listingCaptureService)
 	gettingCaptureService := resources.Get("getting-capture-service").(getting.CaptureService)
@@ -57,35 +163,285 @@ func Router(resources di.Container) http.Handler {
 	removingCaptureHandler := handler.RemovingCapture(removingCaptureService)
 	updatingCaptureService := resources.Get("updating-capture-service").(updating.CaptureService)
 	updatingCaptureHandler := handler.UpdatingCapture(updatingCaptureService)
+	importingService := resources.Get("importing-service").(importing.Service)
+	importMaxBytes := resources.Get("import-max-bytes").(int64)
+	importingGPXHandler := handler.ImportingCaptures(importingService, importing.GPX, importMaxBytes)
+	importingKMLHandler := handler.ImportingCaptures(importingService, importing.KML, importMaxBytes)
+	exportingService := resources.Get("exporting-service").(exporting.Service)
+	exportingGPXHandler := handler.ExportingCaptures(exportingService, exporting.GPX)
+	exportingKMLHandler := handler.ExportingCaptures(exportingService, exporting.KML)
+	streamingService := resources.Get("streaming-service").(streaming.Service)
+	streamAuthorization := chi.Chain(authorizeOrShareMiddleware, ctxRepoMiddleware, ctxRoleMiddleware, capturesReaderMiddleware).Handler
+	streamingCapturesHandler := handler.StreamingCaptures(streamingService, streamAuthorization)
+
+	geofencingService := resources.Get("geofencing-service").(geofencing.Service)
+	creatingGeofenceHandler := handler.CreatingGeofence(geofencingService)
+	listingGeofencesHandler := handler.ListingGeofences(geofencingService)
+	ctxGeofenceMiddleware := middleware.GeofenceCtx(geofencingService)
+	gettingGeofenceHandler := handler.GettingGeofence()
+	removingGeofenceHandler := handler.RemovingGeofence(geofencingService)
+	listingGeofenceEventsMiddleware := chi.Chain(listingsLimitMiddleware, middleware.FilterGeofenceEvents()).Handler
+	listingGeofenceEventsHandler := handler.ListingGeofenceEvents(geofencingService)
+
+	webhooksService := resources.Get("webhooks-service").(webhooks.Service)
+	creatingWebhookHandler := handler.CreatingWebhook(webhooksService)
+	listingWebhooksHandler := handler.ListingWebhooks(webhooksService)
+	ctxWebhookMiddleware := middleware.WebhookCtx(webhooksService)
+	gettingWebhookHandler := handler.GettingWebhook()
+	removingWebhookHandler := handler.RemovingWebhook(webhooksService)
+	listingWebhookDeliveriesMiddleware := chi.Chain(listingsLimitMiddleware, middleware.FilterWebhookDeliveries()).Handler
+	listingWebhookDeliveriesHandler := handler.ListingWebhookDeliveries(webhooksService)
+
+	retentionService := resources.Get("retention-service").(retention.Service)
+	gettingRetentionPolicyHandler := handler.GettingRetentionPolicy(retentionService)
+	settingRetentionPolicyHandler := handler.SettingRetentionPolicy(retentionService)
+	removingRetentionPolicyHandler := handler.RemovingRetentionPolicy(retentionService)
+	listingRetentionReportsMiddleware := chi.Chain(listingsLimitMiddleware, middleware.FilterRetentionReports()).Handler
+	listingRetentionReportsHandler := handler.ListingRetentionReports(retentionService)
+
+	rollupsService := resources.Get("rollups-service").(rollups.Service)
+	creatingRollupRuleHandler := handler.CreatingRollupRule(rollupsService)
+	listingRollupRulesHandler := handler.ListingRollupRules(rollupsService)
+	ctxRollupRuleMiddleware := middleware.RollupRuleCtx(rollupsService)
+	gettingRollupRuleHandler := handler.GettingRollupRule()
+	removingRollupRuleHandler := handler.RemovingRollupRule(rollupsService)
+
+	apikeysService := resources.Get("apikeys-service").(apikeys.Service)
+	creatingAPIKeyHandler := handler.CreatingAPIKey(apikeysService)
+	listingAPIKeysHandler := handler.ListingAPIKeys(apikeysService)
+	ctxAPIKeyMiddleware := middleware.APIKeyCtx(apikeysService)
+	gettingAPIKeyHandler := handler.GettingAPIKey()
+	revokingAPIKeyHandler := handler.RevokingAPIKey(apikeysService)
+
+	creatingShareLinkHandler := handler.CreatingShareLink(sharingService)
+	listingShareLinksHandler := handler.ListingShareLinks(sharingService)
+	ctxShareLinkMiddleware := middleware.ShareLinkCtx(sharingService)
+	gettingShareLinkHandler := handler.GettingShareLink()
+	revokingShareLinkHandler := handler.RevokingShareLink(sharingService)
+
+	invitingCollaboratorHandler := handler.InvitingCollaborator(collaboratingService)
+	listingCollaboratorsHandler := handler.ListingCollaborators(collaboratingService)
+	ctxCollaboratorMiddleware := middleware.CollaboratorCtx(collaboratingService)
+	gettingCollaboratorHandler := handler.GettingCollaborator()
+	removingCollaboratorHandler := handler.RemovingCollaborator(collaboratingService)
+	listingInvitationsHandler := handler.ListingInvitations(collaboratingService)
+	acceptingInvitationHandler := handler.AcceptingInvitation(collaboratingService)
+	decliningInvitationHandler := handler.DecliningInvitation(collaboratingService)
+
+	creatingOrganizationHandler := handler.CreatingOrganization(organizingService)
+	listingOrganizationsHandler := handler.ListingOrganizations(organizingService)
+	ctxOrganizationMiddleware := middleware.OrganizationCtx(organizingService)
+	organizationOwnerMiddleware := middleware.OrganizationRole(domain.OwnerRole)
+	organizationAdminMiddleware := middleware.OrganizationRole(domain.AdminRole)
+	gettingOrganizationHandler := handler.GettingOrganization()
+	addingMemberHandler := handler.AddingMember(organizingService)
+	listingMembersHandler := handler.ListingMembers(organizingService)
+	removingMemberHandler := handler.RemovingMember(organizingService)
+	creatingTeamHandler := handler.CreatingTeam(organizingService)
+	listingTeamsHandler := handler.ListingTeams(organizingService)
+	removingTeamHandler := handler.RemovingTeam(organizingService)
+	addingTeamMemberHandler := handler.AddingTeamMember(organizingService)
+	removingTeamMemberHandler := handler.RemovingTeamMember(organizingService)
+	creatingOrganizationRepoHandler := handler.CreatingOrganizationRepo(creatingRepoService)
+	listingOrganizationReposHandler := handler.ListingOrganizationRepos(listingRepoService)
 
-	r.Post("/sign/", signUpHandler)
+	keyService := resources.Get("jwt-service").(token.KeyService)
+	gettingJWKSHandler := handler.GettingJWKS(keyService)
+
+	r.Get("/.well-known/jwks.json", gettingJWKSHandler)
+	r.With(authLimitMiddleware).Post("/sign/", signUpHandler)
 	r.Route("/auth/", func(r chi.Router) {
-		r.Post("/token-auth", authenticatingHandler)
+		r.Use(authLimitMiddleware)
+		r.With(loginAuditMiddleware).Post("/token-auth", authenticatingHandler)
+		r.With(loginAuditMiddleware).Post("/two-factor", completingChallengeHandler)
+		r.With(tokenRefreshedAuditMiddleware).Post("/refresh", refreshingTokenHandler)
+		r.With(authorizeMiddleware).Post("/logout", loggingOutHandler)
+		r.Post("/verify-email", verifyingEmailHandler)
+		r.Post("/verify-email/resend", resendingVerificationHandler)
+		r.Post("/forgot-password", forgettingPasswordHandler)
+		r.Post("/reset-password", resettingPasswordHandler)
+		r.Post("/confirm-email", confirmingEmailChangeHandler)
+	})
+	r.Route("/oauth/", func(r chi.Router) {
+		r.With(authLimitMiddleware, oauthTokenIssuedAuditMiddleware).Post("/token", issuingOAuthTokenHandler)
+		r.Group(func(r chi.Router) {
+			r.Use(authorizeMiddleware)
+			r.Use(notImpersonatedMiddleware)
+			r.Get("/authorize", promptingConsentHandler)
+			r.Post("/authorize", consentingAuthorizationHandler)
+			r.Route("/clients/", func(r chi.Router) {
+				r.Post("/", registeringOAuthClientHandler)
+				r.Get("/", listingOAuthClientsHandler)
+				r.Delete("/{clientId}", removingOAuthClientHandler)
+			})
+			r.Route("/consents/", func(r chi.Router) {
+				r.Get("/", listingOAuthConsentsHandler)
+				r.Delete("/{clientId}", revokingOAuthConsentHandler)
+			})
+		})
+	})
+	r.Route("/admin/", func(r chi.Router) {
+		r.Use(authorizeMiddleware)
+		r.Use(adminMiddleware)
+		r.Post("/unlock", unlockingLoginHandler)
+		r.With(listingAuditEntriesMiddleware).Get("/audit", listingAuditEntriesHandler)
+		r.Route("/users/", func(r chi.Router) {
+			r.With(listingUsersMiddleware).Get("/", listingUsersHandler)
+			r.Route("/{userId}", func(r chi.Router) {
+				r.Post("/disable", disablingUserHandler)
+				r.Post("/enable", enablingUserHandler)
+				r.Post("/impersonate", impersonatingUserHandler)
+			})
+		})
+		r.Route("/repositories/{id}", func(r chi.Router) {
+			r.Get("/", gettingAnyRepoHandler)
+			r.Post("/transfer", transferringRepoHandler)
+		})
 	})
 	r.Route("/user/", func(r chi.Router) {
 		r.Use(authorizeMiddleware)
+		r.Get("/", gettingUserHandler)
+		r.With(notImpersonatedMiddleware).Patch("/", updatingUserHandler)
+		r.With(notImpersonatedMiddleware).Delete("/", removingUserHandler)
+		r.With(notImpersonatedMiddleware).Put("/password", changingPasswordHandler)
+		r.Route("/two-factor/", func(r chi.Router) {
+			r.Use(notImpersonatedMiddleware)
+			r.Post("/", enrollingTwoFactorHandler)
+			r.Delete("/", disablingTwoFactorHandler)
+			r.Post("/confirm", confirmingTwoFactorHandler)
+			r.Post("/recovery-codes", regeneratingRecoveryCodesHandler)
+		})
 		r.Route("/repos/", func(r chi.Router) {
-			r.Post("/", creatingRepoHandler)
+			r.With(repoCreatedAuditMiddleware).Post("/", creatingRepoHandler)
 			r.With(listingUserReposMiddleware).Get("/", listingUserReposHandler)
 
 		})
+		r.Route("/invitations/", func(r chi.Router) {
+			r.Get("/", listingInvitationsHandler)
+			r.With(invitationAcceptedAuditMiddleware).Post("/{invitationId}/accept", acceptingInvitationHandler)
+			r.Delete("/{invitationId}", decliningInvitationHandler)
+		})
 	})
-	r.Route("/repositories/", func(r chi.Router) {
+	r.Route("/organizations/", func(r chi.Router) {
 		r.Use(authorizeMiddleware)
-		r.With(listingPublicReposMiddleware).
+		r.Post("/", creatingOrganizationHandler)
+		r.Get("/", listingOrganizationsHandler)
+		r.Route("/{orgId}", func(r chi.Router) {
+			r.Use(ctxOrganizationMiddleware)
+			r.Get("/", gettingOrganizationHandler)
+			r.Route("/members/", func(r chi.Router) {
+				r.Get("/", listingMembersHandler)
+				r.With(organizationOwnerMiddleware, memberAddedAuditMiddleware).Post("/", addingMemberHandler)
+				r.With(organizationOwnerMiddleware, memberRemovedAuditMiddleware).Delete("/{memberId}", removingMemberHandler)
+			})
+			r.Route("/teams/", func(r chi.Router) {
+				r.Get("/", listingTeamsHandler)
+				r.With(organizationOwnerMiddleware).Post("/", creatingTeamHandler)
+				r.With(organizationOwnerMiddleware).Delete("/{teamId}", removingTeamHandler)
+				r.With(organizationOwnerMiddleware, teamMemberAddedAuditMiddleware).Post("/{teamId}/members", addingTeamMemberHandler)
+				r.With(organizationOwnerMiddleware, teamMemberRemovedAuditMiddleware).Delete("/{teamId}/members/{userId}", removingTeamMemberHandler)
+			})
+			r.Route("/repos/", func(r chi.Router) {
+				r.With(organizationAdminMiddleware, repoCreatedAuditMiddleware).Post("/", creatingOrganizationRepoHandler)
+				r.With(listingUserReposMiddleware).Get("/", listingOrganizationReposHandler)
+			})
+		})
+	})
+	r.Route("/repositories/", func(r chi.Router) {
+		r.With(authorizeMiddleware).With(listingPublicReposMiddleware).
 			Get("/", listingPublicReposHandler)
 		r.Route("/{id}", func(r chi.Router) {
+			r.Use(authorizeOrShareMiddleware)
 			r.Use(ctxRepoMiddleware)
+			r.Use(ctxRoleMiddleware)
 			r.With(repoOwnerOrPublicMiddleware).Get("/", gettingRepoHandler)
+			r.With(repoAdminMiddleware, repoVisibilityAuditMiddleware).Put("/", updatingRepoHandler)
+			r.With(repoOwnerMiddleware, listingAuditEntriesMiddleware).Get("/audit", listingRepoAuditEntriesHandler)
 			r.Route("/captures/", func(r chi.Router) {
-				r.With(repoOwnerMiddleware).Post("/", addingCaptureHandler)
-				r.With(repoOwnerMiddleware).Post("/multi", addingMultiCaptureHandler)
-				r.With(repoOwnerOrPublicMiddleware).With(listingCapturesMiddleware).Get("/", listingCapturesHandler)
+				r.With(capturesWriterMiddleware, captureAddedAuditMiddleware).Post("/", addingCaptureHandler)
+				r.With(capturesWriterMiddleware, captureAddedAuditMiddleware).Post("/multi", addingMultiCaptureHandler)
+				r.With(capturesWriterMiddleware).Get("/ingest", ingestingCapturesHandler)
+				r.With(capturesReaderMiddleware).With(listingCapturesMiddleware).Get("/", listingCapturesHandler)
+				r.With(capturesWriterMiddleware, captureAddedAuditMiddleware).Post("/gpx", importingGPXHandler)
+				r.With(capturesWriterMiddleware, captureAddedAuditMiddleware).Post("/kml", importingKMLHandler)
+				r.With(capturesReaderMiddleware).Get("/gpx", exportingGPXHandler)
+				r.With(capturesReaderMiddleware).Get("/kml", exportingKMLHandler)
+				r.With(capturesReaderMiddleware).Get("/stream", streamingCapturesHandler)
 				r.Route("/{captureId}", func(r chi.Router) {
 					r.Use(ctxCaptureMiddleware)
-					r.With(repoOwnerOrPublicMiddleware).Get("/", gettingCaptureHandler)
-					r.With(repoOwnerMiddleware).Delete("/", removingCaptureHandler)
-					r.With(repoOwnerMiddleware).Put("/", updatingCaptureHandler)
+					r.With(capturesReaderMiddleware).Get("/", gettingCaptureHandler)
+					r.With(capturesWriterMiddleware, captureDeletedAuditMiddleware).Delete("/", removingCaptureHandler)
+					r.With(capturesWriterMiddleware, captureChangedAuditMiddleware).Put("/", updatingCaptureHandler)
+				})
+			})
+			r.Route("/geofences/", func(r chi.Router) {
+				r.With(repoAdminMiddleware).Post("/", creatingGeofenceHandler)
+				r.With(repoOwnerOrPublicMiddleware).Get("/", listingGeofencesHandler)
+				r.Route("/{geofenceId}", func(r chi.Router) {
+					r.Use(ctxGeofenceMiddleware)
+					r.With(repoOwnerOrPublicMiddleware).Get("/", gettingGeofenceHandler)
+					r.With(repoAdminMiddleware).Delete("/", removingGeofenceHandler)
+					r.With(repoOwnerOrPublicMiddleware).With(listingGeofenceEventsMiddleware).
+						Get("/events", listingGeofenceEventsHandler)
+				})
+			})
+			r.Route("/webhooks/", func(r chi.Router) {
+				r.Use(repoOwnerMiddleware)
+				r.Post("/", creatingWebhookHandler)
+				r.Get("/", listingWebhooksHandler)
+				r.Route("/{webhookId}", func(r chi.Router) {
+					r.Use(ctxWebhookMiddleware)
+					r.Get("/", gettingWebhookHandler)
+					r.Delete("/", removingWebhookHandler)
+					r.With(listingWebhookDeliveriesMiddleware).Get("/deliveries", listingWebhookDeliveriesHandler)
+				})
+			})
+			r.Route("/retention", func(r chi.Router) {
+				r.Use(repoOwnerMiddleware)
+				r.Get("/", gettingRetentionPolicyHandler)
+				r.Put("/", settingRetentionPolicyHandler)
+				r.Delete("/", removingRetentionPolicyHandler)
+				r.With(listingRetentionReportsMiddleware).Get("/reports", listingRetentionReportsHandler)
+			})
+			r.Route("/rollups/", func(r chi.Router) {
+				r.Use(repoOwnerMiddleware)
+				r.Post("/", creatingRollupRuleHandler)
+				r.Get("/", listingRollupRulesHandler)
+				r.Route("/{ruleId}", func(r chi.Router) {
+					r.Use(ctxRollupRuleMiddleware)
+					r.Get("/", gettingRollupRuleHandler)
+					r.Delete("/", removingRollupRuleHandler)
+				})
+			})
+			r.Route("/collaborators/", func(r chi.Router) {
+				r.Use(repoAdminMiddleware)
+				r.With(collaboratorInvitedAuditMiddleware).Post("/", invitingCollaboratorHandler)
+				r.Get("/", listingCollaboratorsHandler)
+				r.Route("/{collaboratorId}", func(r chi.Router) {
+					r.Use(ctxCollaboratorMiddleware)
+					r.Get("/", gettingCollaboratorHandler)
+					r.With(collaboratorRemovedAuditMiddleware).Delete("/", removingCollaboratorHandler)
+				})
+			})
+			r.Route("/keys/", func(r chi.Router) {
+				r.Use(notImpersonatedMiddleware)
+				r.Use(repoOwnerMiddleware)
+				r.With(apiKeyCreatedAuditMiddleware).Post("/", creatingAPIKeyHandler)
+				r.Get("/", listingAPIKeysHandler)
+				r.Route("/{keyId}", func(r chi.Router) {
+					r.Use(ctxAPIKeyMiddleware)
+					r.Get("/", gettingAPIKeyHandler)
+					r.With(apiKeyRevokedAuditMiddleware).Delete("/", revokingAPIKeyHandler)
+				})
+			})
+			r.Route("/shares/", func(r chi.Router) {
+				r.Use(repoOwnerMiddleware)
+				r.With(shareLinkCreatedAuditMiddleware).Post("/", creatingShareLinkHandler)
+				r.Get("/", listingShareLinksHandler)
+				r.Route("/{shareId}", func(r chi.Router) {
+					r.Use(ctxShareLinkMiddleware)
+					r.Get("/", gettingShareLinkHandler)
+					r.With(shareLinkRevokedAuditMiddleware).Delete("/", revokingShareLinkHandler)
 				})
 			})
 		})
@@ -1,8 +1,10 @@
 package rest_test
 
 import (
+	"io"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -17,11 +19,29 @@ import (
 	bastionListing "github.com/ifreddyrondon/bastion/middleware/listing"
 
 	"github.com/ifreddyrondon/capture/pkg/adding"
+	"github.com/ifreddyrondon/capture/pkg/administering"
+	"github.com/ifreddyrondon/capture/pkg/apikeys"
+	"github.com/ifreddyrondon/capture/pkg/auditing"
 	"github.com/ifreddyrondon/capture/pkg/authenticating"
+	"github.com/ifreddyrondon/capture/pkg/collaborating"
 	"github.com/ifreddyrondon/capture/pkg/creating"
+	"github.com/ifreddyrondon/capture/pkg/exporting"
+	"github.com/ifreddyrondon/capture/pkg/geofencing"
+	"github.com/ifreddyrondon/capture/pkg/importing"
+	"github.com/ifreddyrondon/capture/pkg/limiting"
 	"github.com/ifreddyrondon/capture/pkg/listing"
+	"github.com/ifreddyrondon/capture/pkg/organizing"
+	"github.com/ifreddyrondon/capture/pkg/quotas"
+	"github.com/ifreddyrondon/capture/pkg/retention"
+	"github.com/ifreddyrondon/capture/pkg/rollups"
+	"github.com/ifreddyrondon/capture/pkg/sharing"
 	"github.com/ifreddyrondon/capture/pkg/signup"
+	"github.com/ifreddyrondon/capture/pkg/streaming"
+	"github.com/ifreddyrondon/capture/pkg/token"
+	"github.com/ifreddyrondon/capture/pkg/twofactor"
 	"github.com/ifreddyrondon/capture/pkg/updating"
+	"github.com/ifreddyrondon/capture/pkg/verifying"
+	"github.com/ifreddyrondon/capture/pkg/webhooks"
 
 	"github.com/sarulabs/di"
 )
@@ -40,21 +60,64 @@ type mockAuthenticatingService struct {
 	tokenErr error
 }
 
-func (s *mockAuthenticatingService) AuthenticateUser(authenticating.BasicCredential) (*domain.User, error) {
+func (s *mockAuthenticatingService) AuthenticateUser(authenticating.BasicCredential, string) (*authenticating.Login, error) {
+	return &authenticating.Login{User: s.usr}, s.err
+}
+func (s *mockAuthenticatingService) CompleteChallenge(authenticating.ChallengePayload, string) (*domain.User, error) {
 	return s.usr, s.err
 }
 func (s *mockAuthenticatingService) GetUserToken(kallax.ULID) (string, error) {
 	return s.token, s.tokenErr
 }
+func (s *mockAuthenticatingService) Unlock(authenticating.UnlockPayload) error { return s.err }
+
+type mockRefreshService struct {
+	tokens *authenticating.Tokens
+	err    error
+}
+
+func (m *mockRefreshService) IssueRefreshToken(kallax.ULID) (string, error) { return "", m.err }
+func (m *mockRefreshService) Refresh(authenticating.RefreshPayload) (*authenticating.Tokens, error) {
+	return m.tokens, m.err
+}
+func (m *mockRefreshService) Logout(*http.Request, *domain.User, authenticating.LogoutPayload) error {
+	return m.err
+}
+
+type mockVerifyingService struct{ err error }
+
+func (m *mockVerifyingService) SendVerification(*domain.User) error             { return m.err }
+func (m *mockVerifyingService) ResendVerification(verifying.EmailPayload) error { return m.err }
+func (m *mockVerifyingService) VerifyEmail(verifying.TokenPayload) error        { return m.err }
+func (m *mockVerifyingService) ForgotPassword(verifying.EmailPayload) error     { return m.err }
+func (m *mockVerifyingService) ResetPassword(verifying.ResetPayload) error      { return m.err }
+func (m *mockVerifyingService) SendEmailChange(*domain.User) error              { return m.err }
+func (m *mockVerifyingService) ConfirmEmailChange(verifying.TokenPayload) error { return m.err }
 
 type mockAuthorizingService struct {
-	usr *domain.User
-	err error
+	usr          *domain.User
+	impersonator *kallax.ULID
+	err          error
 }
 
 func (m *mockAuthorizingService) AuthorizeRequest(*http.Request) (*domain.User, error) {
 	return m.usr, m.err
 }
+func (m *mockAuthorizingService) AuthorizeGrant(*http.Request) (*domain.User, *domain.AccessGrant, error) {
+	return m.usr, nil, m.err
+}
+func (m *mockAuthorizingService) AuthorizeImpersonator(*http.Request) (*kallax.ULID, error) {
+	return m.impersonator, nil
+}
+func (m *mockAuthorizingService) AuthorizeKey(string) (*domain.User, *domain.APIKey, error) {
+	return m.usr, nil, m.err
+}
+
+type mockUserService struct{ err error }
+
+func (m *mockUserService) Update(updating.User, *domain.User) error             { return m.err }
+func (m *mockUserService) ChangePassword(updating.Password, *domain.User) error { return m.err }
+func (m *mockUserService) Remove(*domain.User) error                            { return m.err }
 
 type mockRepoService struct {
 	repo *domain.Repository
@@ -64,6 +127,9 @@ type mockRepoService struct {
 func (m *mockRepoService) CreateRepo(*domain.User, creating.Payload) (*creating.Repository, error) {
 	return &creating.Repository{}, m.err
 }
+func (m *mockRepoService) CreateOrganizationRepo(*domain.User, *domain.Organization, creating.Payload) (*creating.Repository, error) {
+	return &creating.Repository{}, m.err
+}
 func (m *mockRepoService) GetUserRepos(*domain.User, *bastionListing.Listing) (*listing.ListRepositoryResponse, error) {
 	return &listing.ListRepositoryResponse{}, m.err
 }
@@ -71,9 +137,13 @@ func (m *mockRepoService) GetUserRepos(*domain.User, *bastionListing.Listing) (*
 func (m *mockRepoService) GetPublicRepos(*bastionListing.Listing) (*listing.ListRepositoryResponse, error) {
 	return &listing.ListRepositoryResponse{}, m.err
 }
+func (m *mockRepoService) GetOrganizationRepos(*domain.Organization, domain.Role, *bastionListing.Listing) (*listing.ListRepositoryResponse, error) {
+	return &listing.ListRepositoryResponse{}, m.err
+}
 func (m *mockRepoService) Get(kallax.ULID) (*domain.Repository, error) {
 	return m.repo, m.err
 }
+func (m *mockRepoService) Update(updating.Repo, *domain.Repository) error { return m.err }
 
 type mockCaptureService struct {
 	capt     *domain.Capture
@@ -87,16 +157,284 @@ func (m *mockCaptureService) AddCapture(*domain.Repository, adding.Capture) (*do
 func (m *mockCaptureService) AddCaptures(*domain.Repository, adding.MultiCapture) ([]domain.Capture, error) {
 	return m.captures, m.err
 }
-func (m *mockCaptureService) ListRepoCaptures(*domain.Repository, *bastionListing.Listing) (*listing.ListCaptureResponse, error) {
+func (m *mockCaptureService) ListRepoCaptures(*domain.Repository, *bastionListing.Listing, listing.LocationFilter) (*listing.ListCaptureResponse, error) {
 	return &listing.ListCaptureResponse{}, m.err
 }
 func (m *mockCaptureService) Get(kallax.ULID, *domain.Repository) (*domain.Capture, error) {
 	return m.capt, m.err
 }
-func (m *mockCaptureService) Update(updating.Capture, *domain.Capture) error { return m.err }
-func (m *mockCaptureService) Remove(*domain.Capture) error                   { return m.err }
+func (m *mockCaptureService) Update(*domain.Repository, updating.Capture, *domain.Capture) error {
+	return m.err
+}
+func (m *mockCaptureService) Remove(*domain.Capture) error { return m.err }
+func (m *mockCaptureService) ImportCaptures(*domain.Repository, importing.Document) ([]domain.Capture, error) {
+	return m.captures, m.err
+}
+func (m *mockCaptureService) ExportCaptures(io.Writer, *domain.Repository, exporting.Format) error {
+	return m.err
+}
+
+type mockGeofencingService struct {
+	geofence *domain.Geofence
+	err      error
+}
+
+func (m *mockGeofencingService) CreateGeofence(*domain.Repository, geofencing.Payload) (*domain.Geofence, error) {
+	return m.geofence, m.err
+}
+func (m *mockGeofencingService) ListGeofences(*domain.Repository) ([]domain.Geofence, error) {
+	return nil, m.err
+}
+func (m *mockGeofencingService) GetGeofence(kallax.ULID, *domain.Repository) (*domain.Geofence, error) {
+	return m.geofence, m.err
+}
+func (m *mockGeofencingService) RemoveGeofence(*domain.Geofence) error { return m.err }
+func (m *mockGeofencingService) ListEvents(*domain.Geofence, *bastionListing.Listing) (*geofencing.ListEventResponse, error) {
+	return &geofencing.ListEventResponse{}, m.err
+}
+
+type mockWebhooksService struct {
+	webhook *domain.Webhook
+	err     error
+}
+
+func (m *mockWebhooksService) CreateWebhook(*domain.Repository, webhooks.Payload) (*domain.Webhook, error) {
+	return m.webhook, m.err
+}
+func (m *mockWebhooksService) ListWebhooks(*domain.Repository) ([]domain.Webhook, error) {
+	return nil, m.err
+}
+func (m *mockWebhooksService) GetWebhook(kallax.ULID, *domain.Repository) (*domain.Webhook, error) {
+	return m.webhook, m.err
+}
+func (m *mockWebhooksService) RemoveWebhook(*domain.Webhook) error { return m.err }
+func (m *mockWebhooksService) ListDeliveries(*domain.Webhook, *bastionListing.Listing) (*webhooks.ListDeliveryResponse, error) {
+	return &webhooks.ListDeliveryResponse{}, m.err
+}
+
+type mockRetentionService struct {
+	policy *domain.RetentionPolicy
+	err    error
+}
+
+func (m *mockRetentionService) GetPolicy(*domain.Repository) (*domain.RetentionPolicy, error) {
+	return m.policy, m.err
+}
+func (m *mockRetentionService) SetPolicy(*domain.Repository, retention.Payload) (*domain.RetentionPolicy, error) {
+	return m.policy, m.err
+}
+func (m *mockRetentionService) RemovePolicy(*domain.Repository) error { return m.err }
+func (m *mockRetentionService) ListReports(*domain.Repository, *bastionListing.Listing) (*retention.ListReportResponse, error) {
+	return &retention.ListReportResponse{}, m.err
+}
+func (m *mockRetentionService) Run(time.Time) ([]domain.RetentionReport, error) { return nil, m.err }
+
+type mockRollupsService struct {
+	rule *domain.RollupRule
+	err  error
+}
+
+func (m *mockRollupsService) CreateRule(*domain.Repository, rollups.Payload) (*domain.RollupRule, error) {
+	return m.rule, m.err
+}
+func (m *mockRollupsService) ListRules(*domain.Repository) ([]domain.RollupRule, error) {
+	return nil, m.err
+}
+func (m *mockRollupsService) GetRule(kallax.ULID, *domain.Repository) (*domain.RollupRule, error) {
+	return m.rule, m.err
+}
+func (m *mockRollupsService) RemoveRule(*domain.RollupRule) error     { return m.err }
+func (m *mockRollupsService) Run(time.Time) ([]rollups.Report, error) { return nil, m.err }
+
+type mockAPIKeysService struct {
+	key *domain.APIKey
+	err error
+}
+
+func (m *mockAPIKeysService) CreateKey(*domain.User, *domain.Repository, apikeys.Payload) (*apikeys.Key, error) {
+	return &apikeys.Key{}, m.err
+}
+func (m *mockAPIKeysService) ListKeys(*domain.Repository) ([]domain.APIKey, error) { return nil, m.err }
+func (m *mockAPIKeysService) GetKey(kallax.ULID, *domain.Repository) (*domain.APIKey, error) {
+	return m.key, m.err
+}
+func (m *mockAPIKeysService) RevokeKey(*domain.APIKey) error { return m.err }
+
+type mockSharingService struct {
+	link *domain.ShareLink
+	err  error
+}
+
+func (m *mockSharingService) CreateLink(*domain.User, *domain.Repository, sharing.Payload) (*sharing.Link, error) {
+	return &sharing.Link{}, m.err
+}
+func (m *mockSharingService) ListLinks(*domain.Repository) ([]domain.ShareLink, error) {
+	return nil, m.err
+}
+func (m *mockSharingService) GetLink(kallax.ULID, *domain.Repository) (*domain.ShareLink, error) {
+	return m.link, m.err
+}
+func (m *mockSharingService) RevokeLink(*domain.ShareLink) error { return m.err }
+func (m *mockSharingService) AuthorizeLink(string) (*domain.ShareLink, error) {
+	return m.link, m.err
+}
+
+type mockTwoFactorService struct {
+	err error
+}
+
+func (m *mockTwoFactorService) Enroll(*domain.User) (*twofactor.Enrollment, error) { return nil, m.err }
+func (m *mockTwoFactorService) Confirm(*domain.User, twofactor.CodePayload) (*twofactor.RecoveryCodesResponse, error) {
+	return nil, m.err
+}
+func (m *mockTwoFactorService) Disable(*domain.User, twofactor.CodePayload) error { return m.err }
+func (m *mockTwoFactorService) RegenerateRecoveryCodes(*domain.User, twofactor.CodePayload) (*twofactor.RecoveryCodesResponse, error) {
+	return nil, m.err
+}
+func (m *mockTwoFactorService) CheckCode(*domain.User, string) (bool, error) { return false, m.err }
+
+type mockOAuthService struct {
+	err error
+}
+
+func (m *mockOAuthService) RegisterClient(*domain.User, authenticating.ClientPayload) (*authenticating.RegisteredClient, error) {
+	return nil, m.err
+}
+func (m *mockOAuthService) ListClients(*domain.User) ([]domain.OAuthClient, error) { return nil, m.err }
+func (m *mockOAuthService) RemoveClient(*domain.User, kallax.ULID) error           { return m.err }
+func (m *mockOAuthService) Prompt(*domain.User, authenticating.AuthorizePayload) (*authenticating.ConsentPrompt, error) {
+	return nil, m.err
+}
+func (m *mockOAuthService) Consent(*domain.User, authenticating.AuthorizePayload, authenticating.ConsentPayload) (*authenticating.ConsentRedirect, error) {
+	return nil, m.err
+}
+func (m *mockOAuthService) Exchange(authenticating.TokenPayload) (*authenticating.OAuthToken, error) {
+	return nil, m.err
+}
+func (m *mockOAuthService) ListConsents(*domain.User) ([]domain.OAuthConsent, error) {
+	return nil, m.err
+}
+func (m *mockOAuthService) RevokeConsent(*domain.User, kallax.ULID) error { return m.err }
+
+type mockAuditingService struct{}
+
+func (m *mockAuditingService) Record(*domain.AuditEntry) error { return nil }
+func (m *mockAuditingService) ListEntries(*bastionListing.Listing, *kallax.ULID) (*auditing.ListEntryResponse, error) {
+	return &auditing.ListEntryResponse{}, nil
+}
+func (m *mockAuditingService) ListRepoEntries(*domain.Repository, *bastionListing.Listing, *kallax.ULID) (*auditing.ListEntryResponse, error) {
+	return &auditing.ListEntryResponse{}, nil
+}
+
+type mockAdministeringService struct{}
+
+func (m *mockAdministeringService) ListUsers(domain.Actor, *bastionListing.Listing, string) (*administering.ListUserResponse, error) {
+	return &administering.ListUserResponse{}, nil
+}
+func (m *mockAdministeringService) DisableUser(domain.Actor, kallax.ULID) (*domain.User, error) {
+	return &domain.User{}, nil
+}
+func (m *mockAdministeringService) EnableUser(domain.Actor, kallax.ULID) (*domain.User, error) {
+	return &domain.User{}, nil
+}
+func (m *mockAdministeringService) GetRepo(domain.Actor, kallax.ULID) (*domain.Repository, error) {
+	return &domain.Repository{}, nil
+}
+func (m *mockAdministeringService) TransferRepo(domain.Actor, kallax.ULID, administering.TransferPayload) (*domain.Repository, error) {
+	return &domain.Repository{}, nil
+}
+func (m *mockAdministeringService) Impersonate(domain.Actor, kallax.ULID) (*administering.Impersonation, error) {
+	return &administering.Impersonation{}, nil
+}
+
+type mockLimitingService struct{}
+
+func (m *mockLimitingService) Allow(string, domain.RateLimit) (*limiting.Result, error) {
+	return &limiting.Result{Allowed: true}, nil
+}
+
+type mockQuotasService struct{}
 
-func resources() di.Container {
+func (m *mockQuotasService) Reserve(*domain.Repository, ...domain.Capture) error { return nil }
+func (m *mockQuotasService) Release(...domain.Capture) error                     { return nil }
+func (m *mockQuotasService) Resize(*domain.Repository, domain.Capture, domain.Capture) error {
+	return nil
+}
+func (m *mockQuotasService) RepoUsage(*domain.Repository) (*quotas.Usage, error) {
+	return &quotas.Usage{}, nil
+}
+func (m *mockQuotasService) UserUsage(*domain.User) (*quotas.Usage, error) {
+	return &quotas.Usage{}, nil
+}
+
+type mockCollaboratingService struct {
+	collaborator *domain.Collaborator
+	err          error
+}
+
+func (m *mockCollaboratingService) Invite(*domain.User, *domain.Repository, collaborating.Payload) (*domain.Collaborator, error) {
+	return m.collaborator, m.err
+}
+func (m *mockCollaboratingService) ListCollaborators(*domain.Repository) ([]domain.Collaborator, error) {
+	return nil, m.err
+}
+func (m *mockCollaboratingService) GetCollaborator(kallax.ULID, *domain.Repository) (*domain.Collaborator, error) {
+	return m.collaborator, m.err
+}
+func (m *mockCollaboratingService) RemoveCollaborator(*domain.Collaborator) error { return m.err }
+func (m *mockCollaboratingService) ListInvitations(*domain.User) ([]domain.Collaborator, error) {
+	return nil, m.err
+}
+func (m *mockCollaboratingService) AcceptInvitation(kallax.ULID, *domain.User) (*domain.Collaborator, error) {
+	return m.collaborator, m.err
+}
+func (m *mockCollaboratingService) DeclineInvitation(kallax.ULID, *domain.User) error { return m.err }
+func (m *mockCollaboratingService) GetRole(*domain.Repository, *domain.User) (domain.Role, error) {
+	return "", m.err
+}
+
+type mockOrganizingService struct {
+	org *domain.Organization
+	err error
+}
+
+func (m *mockOrganizingService) CreateOrganization(*domain.User, organizing.Payload) (*domain.Organization, error) {
+	return m.org, m.err
+}
+func (m *mockOrganizingService) ListOrganizations(*domain.User) ([]domain.Organization, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) GetOrganization(kallax.ULID, *domain.User) (*domain.Organization, error) {
+	return m.org, m.err
+}
+func (m *mockOrganizingService) GetOrganizationRole(*domain.Organization, *domain.User) (domain.Role, error) {
+	return "", m.err
+}
+func (m *mockOrganizingService) AddMember(*domain.Organization, organizing.MemberPayload) (*domain.Member, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) ListMembers(*domain.Organization) ([]domain.Member, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) RemoveMember(kallax.ULID, *domain.Organization) error { return m.err }
+func (m *mockOrganizingService) CreateTeam(*domain.Organization, organizing.TeamPayload) (*domain.Team, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) ListTeams(*domain.Organization) ([]domain.Team, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) RemoveTeam(kallax.ULID, *domain.Organization) error { return m.err }
+func (m *mockOrganizingService) AddTeamMember(kallax.ULID, *domain.Organization, organizing.TeamMemberPayload) (*domain.TeamMember, error) {
+	return nil, m.err
+}
+func (m *mockOrganizingService) RemoveTeamMember(kallax.ULID, kallax.ULID, *domain.Organization) error {
+	return m.err
+}
+func (m *mockOrganizingService) GetRole(*domain.Repository, *domain.User) (domain.Role, error) {
+	return "", m.err
+}
+
+func resources(authorizing *mockAuthorizingService) di.Container {
 	builder, _ := di.NewBuilder()
 	definitions := []di.Def{
 		{
@@ -107,9 +445,21 @@ func resources() di.Container {
 			Name:  "authenticating-service",
 			Build: func(ctn di.Container) (interface{}, error) { return &mockAuthenticatingService{}, nil },
 		},
+		{
+			Name:  "refresh-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockRefreshService{}, nil },
+		},
+		{
+			Name:  "verifying-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockVerifyingService{}, nil },
+		},
+		{
+			Name:  "jwt-service",
+			Build: func(ctn di.Container) (interface{}, error) { return token.NewJWTService("test", time.Minute, nil), nil },
+		},
 		{
 			Name:  "authorize-service",
-			Build: func(ctn di.Container) (interface{}, error) { return &mockAuthorizingService{}, nil },
+			Build: func(ctn di.Container) (interface{}, error) { return authorizing, nil },
 		},
 		{
 			Name:  "creating-repo-service",
@@ -147,38 +497,216 @@ func resources() di.Container {
 			Name:  "updating-capture-service",
 			Build: func(ctn di.Container) (interface{}, error) { return &mockCaptureService{}, nil },
 		},
+		{
+			Name:  "importing-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockCaptureService{}, nil },
+		},
+		{
+			Name:  "import-max-bytes",
+			Build: func(ctn di.Container) (interface{}, error) { return int64(1 << 20), nil },
+		},
+		{
+			Name:  "exporting-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockCaptureService{}, nil },
+		},
+		{
+			Name:  "streaming-service",
+			Build: func(ctn di.Container) (interface{}, error) { return streaming.NewBroker(0), nil },
+		},
+		{
+			Name:  "updating-user-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockUserService{}, nil },
+		},
+		{
+			Name:  "removing-user-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockUserService{}, nil },
+		},
+		{
+			Name:  "updating-repo-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockRepoService{}, nil },
+		},
+		{
+			Name:  "webhooks-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockWebhooksService{}, nil },
+		},
+		{
+			Name:  "retention-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockRetentionService{}, nil },
+		},
+		{
+			Name:  "rollups-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockRollupsService{}, nil },
+		},
+		{
+			Name:  "apikeys-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockAPIKeysService{}, nil },
+		},
+		{
+			Name:  "two_factor-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockTwoFactorService{}, nil },
+		},
+		{
+			Name:  "limiting-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockLimitingService{}, nil },
+		},
+		{
+			Name:  "quotas-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockQuotasService{}, nil },
+		},
+		{
+			Name: "rate-limits",
+			Build: func(ctn di.Container) (interface{}, error) {
+				limit := domain.RateLimit{Requests: 10, Period: time.Minute}
+				return limiting.Limits{Auth: limit, CapturesWrite: limit, Listings: limit}, nil
+			},
+		},
+		{
+			Name:  "administering-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockAdministeringService{}, nil },
+		},
+		{
+			Name:  "auditing-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockAuditingService{}, nil },
+		},
+		{
+			Name:  "oauth-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockOAuthService{}, nil },
+		},
+		{
+			Name:  "sharing-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockSharingService{}, nil },
+		},
+		{
+			Name:  "geofencing-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockGeofencingService{}, nil },
+		},
+		{
+			Name:  "collaborating-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockCollaboratingService{}, nil },
+		},
+		{
+			Name:  "organizing-service",
+			Build: func(ctn di.Container) (interface{}, error) { return &mockOrganizingService{}, nil },
+		},
 	}
 
 	builder.Add(definitions...)
 	return builder.Build()
 }
 
-func setup() *bastion.Bastion {
+func setup(authorizing *mockAuthorizingService) *bastion.Bastion {
 	app := bastion.New()
-	app.Mount("/", rest.Router(resources()))
+	app.Mount("/", rest.Router(resources(authorizing)))
 	return app
 }
 
 func TestRouter(t *testing.T) {
 	t.Parallel()
-	e := bastion.Tester(t, setup())
+	e := bastion.Tester(t, setup(&mockAuthorizingService{}))
 
 	tt := []struct {
 		uri    string
 		method string
 	}{
+		{uri: "/.well-known/jwks.json", method: "GET"},
 		{uri: "/sign/", method: "POST"},
 		{uri: "/auth/token-auth", method: "POST"},
+		{uri: "/auth/two-factor", method: "POST"},
+		{uri: "/auth/refresh", method: "POST"},
+		{uri: "/auth/logout", method: "POST"},
+		{uri: "/auth/verify-email", method: "POST"},
+		{uri: "/auth/verify-email/resend", method: "POST"},
+		{uri: "/auth/forgot-password", method: "POST"},
+		{uri: "/auth/reset-password", method: "POST"},
+		{uri: "/auth/confirm-email", method: "POST"},
+		{uri: "/oauth/token", method: "POST"},
+		{uri: "/oauth/authorize", method: "GET"},
+		{uri: "/oauth/authorize", method: "POST"},
+		{uri: "/oauth/clients/", method: "POST"},
+		{uri: "/oauth/clients/", method: "GET"},
+		{uri: "/oauth/clients/abc", method: "DELETE"},
+		{uri: "/oauth/consents/", method: "GET"},
+		{uri: "/oauth/consents/abc", method: "DELETE"},
+		{uri: "/user/", method: "GET"},
+		{uri: "/user/", method: "PATCH"},
+		{uri: "/user/", method: "DELETE"},
+		{uri: "/user/password", method: "PUT"},
+		{uri: "/user/two-factor/", method: "POST"},
+		{uri: "/user/two-factor/", method: "DELETE"},
+		{uri: "/user/two-factor/confirm", method: "POST"},
+		{uri: "/user/two-factor/recovery-codes", method: "POST"},
 		{uri: "/user/repos/", method: "POST"},
 		{uri: "/user/repos/", method: "GET"},
+		{uri: "/user/invitations/", method: "GET"},
+		{uri: "/user/invitations/abc/accept", method: "POST"},
+		{uri: "/user/invitations/abc", method: "DELETE"},
+		{uri: "/organizations/", method: "POST"},
+		{uri: "/organizations/", method: "GET"},
+		{uri: "/organizations/123", method: "GET"},
+		{uri: "/organizations/123/members", method: "GET"},
+		{uri: "/organizations/123/members", method: "POST"},
+		{uri: "/organizations/123/members/abc", method: "DELETE"},
+		{uri: "/organizations/123/teams", method: "GET"},
+		{uri: "/organizations/123/teams", method: "POST"},
+		{uri: "/organizations/123/teams/abc", method: "DELETE"},
+		{uri: "/organizations/123/teams/abc/members", method: "POST"},
+		{uri: "/organizations/123/teams/abc/members/abc", method: "DELETE"},
+		{uri: "/organizations/123/repos", method: "POST"},
+		{uri: "/organizations/123/repos", method: "GET"},
 		{uri: "/repositories/", method: "GET"},
 		{uri: "/repositories/123", method: "GET"},
+		{uri: "/repositories/123", method: "PUT"},
 		{uri: "/repositories/123/captures", method: "POST"},
 		{uri: "/repositories/123/captures/multi", method: "POST"},
+		{uri: "/repositories/123/captures/ingest", method: "GET"},
 		{uri: "/repositories/123/captures", method: "GET"},
+		{uri: "/repositories/123/captures/gpx", method: "POST"},
+		{uri: "/repositories/123/captures/kml", method: "POST"},
+		{uri: "/repositories/123/captures/gpx", method: "GET"},
+		{uri: "/repositories/123/captures/kml", method: "GET"},
+		{uri: "/repositories/123/captures/stream", method: "GET"},
 		{uri: "/repositories/123/captures/abc", method: "GET"},
 		{uri: "/repositories/123/captures/abc", method: "DELETE"},
 		{uri: "/repositories/123/captures/abc", method: "PUT"},
+		{uri: "/repositories/123/geofences", method: "POST"},
+		{uri: "/repositories/123/geofences", method: "GET"},
+		{uri: "/repositories/123/geofences/abc", method: "GET"},
+		{uri: "/repositories/123/geofences/abc", method: "DELETE"},
+		{uri: "/repositories/123/geofences/abc/events", method: "GET"},
+		{uri: "/repositories/123/webhooks", method: "POST"},
+		{uri: "/repositories/123/webhooks", method: "GET"},
+		{uri: "/repositories/123/webhooks/abc", method: "GET"},
+		{uri: "/repositories/123/webhooks/abc", method: "DELETE"},
+		{uri: "/repositories/123/webhooks/abc/deliveries", method: "GET"},
+		{uri: "/repositories/123/retention", method: "GET"},
+		{uri: "/repositories/123/retention", method: "PUT"},
+		{uri: "/repositories/123/retention", method: "DELETE"},
+		{uri: "/repositories/123/retention/reports", method: "GET"},
+		{uri: "/repositories/123/rollups", method: "POST"},
+		{uri: "/repositories/123/rollups", method: "GET"},
+		{uri: "/repositories/123/rollups/abc", method: "GET"},
+		{uri: "/repositories/123/rollups/abc", method: "DELETE"},
+		{uri: "/repositories/123/collaborators", method: "POST"},
+		{uri: "/repositories/123/collaborators", method: "GET"},
+		{uri: "/repositories/123/collaborators/abc", method: "GET"},
+		{uri: "/repositories/123/collaborators/abc", method: "DELETE"},
+		{uri: "/repositories/123/keys", method: "POST"},
+		{uri: "/repositories/123/keys", method: "GET"},
+		{uri: "/repositories/123/keys/abc", method: "GET"},
+		{uri: "/repositories/123/keys/abc", method: "DELETE"},
+		{uri: "/repositories/123/shares", method: "POST"},
+		{uri: "/repositories/123/shares", method: "GET"},
+		{uri: "/repositories/123/shares/abc", method: "GET"},
+		{uri: "/repositories/123/shares/abc", method: "DELETE"},
+		{uri: "/admin/unlock", method: "POST"},
+		{uri: "/admin/users/", method: "GET"},
+		{uri: "/admin/users/abc/disable", method: "POST"},
+		{uri: "/admin/users/abc/enable", method: "POST"},
+		{uri: "/admin/users/abc/impersonate", method: "POST"},
+		{uri: "/admin/repositories/123", method: "GET"},
+		{uri: "/admin/repositories/123/transfer", method: "POST"},
+		{uri: "/admin/audit", method: "GET"},
+		{uri: "/repositories/123/audit", method: "GET"},
 	}
 
 	for _, tc := range tt {
@@ -189,3 +717,30 @@ func TestRouter(t *testing.T) {
 		})
 	}
 }
+
+func TestRouterNotImpersonated(t *testing.T) {
+	t.Parallel()
+	impersonator := kallax.NewULID()
+	usr := &domain.User{ID: kallax.NewULID(), Email: "test@example.com"}
+	e := bastion.Tester(t, setup(&mockAuthorizingService{usr: usr, impersonator: &impersonator}))
+
+	tt := []struct {
+		uri    string
+		method string
+	}{
+		{uri: "/user/", method: "PATCH"},
+		{uri: "/user/", method: "DELETE"},
+		{uri: "/user/password", method: "PUT"},
+		{uri: "/user/two-factor/", method: "POST"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.method+" "+tc.uri, func(t *testing.T) {
+			e.Request(tc.method, tc.uri).
+				WithHeader("Authorization", "Bearer token").
+				WithJSON(map[string]interface{}{"email": "attacker@example.com"}).
+				Expect().
+				Status(http.StatusForbidden)
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package importing
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/adding"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/validator"
+)
+
+type invalidDocErr string
+
+func (i invalidDocErr) Error() string   { return string(i) }
+func (i invalidDocErr) IsInvalid() bool { return true }
+
+// timeLayouts are the xsd:dateTime variations allowed by GPX and KML.
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02", "2006-01"}
+
+// xmlNode is a generic xml element used to walk through extensions and extended data.
+type xmlNode struct {
+	XMLName xml.Name
+	Content string    `xml:",chardata"`
+	Nodes   []xmlNode `xml:",any"`
+}
+
+// metrics returns a metric for every leaf element with content.
+func (n *xmlNode) metrics() []domain.Metric {
+	if n == nil {
+		return nil
+	}
+	var result []domain.Metric
+	for i := range n.Nodes {
+		child := &n.Nodes[i]
+		if len(child.Nodes) > 0 {
+			result = append(result, child.metrics()...)
+			continue
+		}
+		content := strings.TrimSpace(child.Content)
+		if content == "" {
+			continue
+		}
+		result = append(result, domain.Metric{Name: child.XMLName.Local, Value: metricValue(content)})
+	}
+	return result
+}
+
+func newCapture(lat, lng float64, elevation *float64, when string) (adding.Capture, error) {
+	c := adding.Capture{
+		Location: &validator.GeoLocation{LAT: &lat, LNG: &lng, Elevation: elevation},
+	}
+	when = strings.TrimSpace(when)
+	if when == "" {
+		return c, nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, when); err == nil {
+			c.Timestamp.Time = &t
+			return c, nil
+		}
+	}
+	return c, errors.WithStack(invalidDocErr(fmt.Sprintf("invalid time value %v", when)))
+}
+
+// parseDescription reads a metric from every line with the form `name: value`.
+func parseDescription(desc string) []domain.Metric {
+	var result []domain.Metric
+	scanner := bufio.NewScanner(strings.NewReader(desc))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		name, value := strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])
+		if name == "" || value == "" {
+			continue
+		}
+		result = append(result, domain.Metric{Name: name, Value: metricValue(value)})
+	}
+	return result
+}
+
+func metricValue(v string) interface{} {
+	if f, err := strconv.ParseFloat(v, 64); err == nil {
+		return f
+	}
+	return v
+}
@@ -0,0 +1,75 @@
+package importing
+
+import (
+	"encoding/xml"
+	"io"
+
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/adding"
+)
+
+type gpxDoc struct {
+	XMLName   xml.Name   `xml:"gpx"`
+	Waypoints []gpxPoint `xml:"wpt"`
+	Routes    []gpxRoute `xml:"rte"`
+	Tracks    []gpxTrack `xml:"trk"`
+}
+
+type gpxRoute struct {
+	Points []gpxPoint `xml:"rtept"`
+}
+
+type gpxTrack struct {
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxPoint `xml:"trkpt"`
+}
+
+type gpxPoint struct {
+	Lat        float64  `xml:"lat,attr"`
+	Lon        float64  `xml:"lon,attr"`
+	Elevation  *float64 `xml:"ele"`
+	Time       string   `xml:"time"`
+	Name       string   `xml:"name"`
+	Desc       string   `xml:"desc"`
+	Extensions *xmlNode `xml:"extensions"`
+}
+
+// ParseGPX decodes the waypoints, route points and track points of a GPX document into captures.
+// The point time and elevation are kept, the metrics are read from the description
+// lines with the form `name: value` and from the extensions.
+func ParseGPX(r io.Reader) ([]adding.Capture, error) {
+	var doc gpxDoc
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, errors.WithStack(invalidDocErr("cannot unmarshal gpx document"))
+	}
+
+	var points []gpxPoint
+	points = append(points, doc.Waypoints...)
+	for _, rte := range doc.Routes {
+		points = append(points, rte.Points...)
+	}
+	for _, trk := range doc.Tracks {
+		for _, seg := range trk.Segments {
+			points = append(points, seg.Points...)
+		}
+	}
+
+	captures := make([]adding.Capture, len(points))
+	for i, p := range points {
+		c, err := newCapture(p.Lat, p.Lon, p.Elevation, p.Time)
+		if err != nil {
+			return nil, err
+		}
+		c.Payload.Payload = append(parseDescription(p.Desc), p.Extensions.metrics()...)
+		if p.Name != "" {
+			c.Tags = []string{p.Name}
+		}
+		captures[i] = c
+	}
+
+	return captures, nil
+}
@@ -0,0 +1,85 @@
+package importing_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/importing"
+)
+
+func f2P(v float64) *float64 {
+	return &v
+}
+
+const gpxDoc = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1"
+  xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+  <wpt lat="10.5" lon="-66.9">
+    <ele>900</ele>
+    <time>1989-12-26T06:01:00Z</time>
+    <name>station</name>
+    <desc>power: 10
+humidity: high</desc>
+  </wpt>
+  <trk>
+    <name>morning</name>
+    <trkseg>
+      <trkpt lat="1" lon="2">
+        <time>1989-12-26T06:02:00Z</time>
+        <extensions>
+          <gpxtpx:TrackPointExtension>
+            <gpxtpx:hr>120</gpxtpx:hr>
+          </gpxtpx:TrackPointExtension>
+        </extensions>
+      </trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestParseGPX(t *testing.T) {
+	t.Parallel()
+
+	captures, err := importing.ParseGPX(strings.NewReader(gpxDoc))
+	assert.Nil(t, err)
+	assert.Len(t, captures, 2)
+
+	wpt := captures[0]
+	assert.Equal(t, f2P(10.5), wpt.Location.LAT)
+	assert.Equal(t, f2P(-66.9), wpt.Location.LNG)
+	assert.Equal(t, f2P(900), wpt.Location.Elevation)
+	assert.Equal(t, "1989-12-26T06:01:00Z", wpt.Timestamp.Time.Format("2006-01-02T15:04:05Z07:00"))
+	assert.Equal(t, []string{"station"}, wpt.Tags)
+	expectedMetrics := []domain.Metric{{Name: "power", Value: 10.0}, {Name: "humidity", Value: "high"}}
+	assert.Equal(t, expectedMetrics, wpt.Payload.Payload)
+
+	trkpt := captures[1]
+	assert.Equal(t, f2P(1), trkpt.Location.LAT)
+	assert.Equal(t, f2P(2), trkpt.Location.LNG)
+	assert.Nil(t, trkpt.Location.Elevation)
+	assert.Nil(t, trkpt.Tags)
+	assert.Equal(t, []domain.Metric{{Name: "hr", Value: 120.0}}, trkpt.Payload.Payload)
+}
+
+func TestParseGPXFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{"not xml", "{}", "cannot unmarshal gpx document"},
+		{"not gpx", "<kml></kml>", "cannot unmarshal gpx document"},
+		{"invalid time", `<gpx><wpt lat="1" lon="1"><time>a</time></wpt></gpx>`, "invalid time value a"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := importing.ParseGPX(strings.NewReader(tc.doc))
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package importing
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/adding"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type kmlPlacemark struct {
+	Name         string    `xml:"name"`
+	Description  string    `xml:"description"`
+	When         string    `xml:"TimeStamp>when"`
+	ExtendedData []kmlData `xml:"ExtendedData>Data"`
+	Coordinates  string    `xml:"Point>coordinates"`
+	Track        *kmlTrack `xml:"Track"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+// kmlTrack is a gx:Track, a list of when and coord elements of the same length.
+type kmlTrack struct {
+	When  []string `xml:"when"`
+	Coord []string `xml:"coord"`
+}
+
+// ParseKML decodes the placemarks of a KML document into captures.
+// Point placemarks and every coordinate of a gx:Track are taken as captures.
+// The metrics are read from the description lines with the form `name: value`
+// and from the extended data.
+func ParseKML(r io.Reader) ([]adding.Capture, error) {
+	placemarks, err := decodePlacemarks(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var captures []adding.Capture
+	for _, p := range placemarks {
+		metrics := parseDescription(p.Description)
+		for _, d := range p.ExtendedData {
+			v := strings.TrimSpace(d.Value)
+			if d.Name == "" || v == "" {
+				continue
+			}
+			metrics = append(metrics, domain.Metric{Name: d.Name, Value: metricValue(v)})
+		}
+
+		if p.Track != nil {
+			if len(p.Track.When) != len(p.Track.Coord) {
+				return nil, errors.WithStack(invalidDocErr("track when and coord elements must have the same length"))
+			}
+			for i := range p.Track.Coord {
+				c, err := placemarkCapture(p, metrics, p.Track.Coord[i], " ", p.Track.When[i])
+				if err != nil {
+					return nil, err
+				}
+				captures = append(captures, c)
+			}
+			continue
+		}
+
+		if strings.TrimSpace(p.Coordinates) == "" {
+			continue
+		}
+		c, err := placemarkCapture(p, metrics, p.Coordinates, ",", p.When)
+		if err != nil {
+			return nil, err
+		}
+		captures = append(captures, c)
+	}
+
+	return captures, nil
+}
+
+func decodePlacemarks(r io.Reader) ([]kmlPlacemark, error) {
+	var placemarks []kmlPlacemark
+	dec := xml.NewDecoder(r)
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.WithStack(invalidDocErr("cannot unmarshal kml document"))
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok || start.Name.Local != "Placemark" {
+			continue
+		}
+		var p kmlPlacemark
+		if err := dec.DecodeElement(&p, &start); err != nil {
+			return nil, errors.WithStack(invalidDocErr("cannot unmarshal kml placemark"))
+		}
+		placemarks = append(placemarks, p)
+	}
+	return placemarks, nil
+}
+
+func placemarkCapture(p kmlPlacemark, metrics []domain.Metric, coord, sep, when string) (adding.Capture, error) {
+	lng, lat, elevation, err := parseCoordinates(coord, sep)
+	if err != nil {
+		return adding.Capture{}, err
+	}
+	c, err := newCapture(lat, lng, elevation, when)
+	if err != nil {
+		return c, err
+	}
+	c.Payload.Payload = append([]domain.Metric(nil), metrics...)
+	if p.Name != "" {
+		c.Tags = []string{p.Name}
+	}
+	return c, nil
+}
+
+// parseCoordinates reads a KML tuple with the form `lng<sep>lat[<sep>elevation]`.
+func parseCoordinates(coord, sep string) (float64, float64, *float64, error) {
+	invalid := errors.WithStack(invalidDocErr(fmt.Sprintf("invalid coordinates value %v", coord)))
+	parts := strings.Split(strings.TrimSpace(coord), sep)
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, 0, nil, invalid
+	}
+	lng, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return 0, 0, nil, invalid
+	}
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return 0, 0, nil, invalid
+	}
+	if len(parts) == 2 {
+		return lng, lat, nil, nil
+	}
+	elevation, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+	if err != nil {
+		return 0, 0, nil, invalid
+	}
+	return lng, lat, &elevation, nil
+}
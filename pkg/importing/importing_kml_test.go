@@ -0,0 +1,88 @@
+package importing_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/importing"
+)
+
+const kmlDoc = `<?xml version="1.0" encoding="UTF-8"?>
+<kml xmlns="http://www.opengis.net/kml/2.2" xmlns:gx="http://www.google.com/kml/ext/2.2">
+  <Document>
+    <Folder>
+      <Placemark>
+        <name>station</name>
+        <description>power: 10</description>
+        <TimeStamp><when>1989-12-26T06:01:00Z</when></TimeStamp>
+        <ExtendedData>
+          <Data name="humidity"><value>80</value></Data>
+        </ExtendedData>
+        <Point><coordinates>-66.9,10.5,900</coordinates></Point>
+      </Placemark>
+    </Folder>
+    <Placemark>
+      <description>power: 5</description>
+      <gx:Track>
+        <when>1989-12-26T06:02:00Z</when>
+        <when>1989-12-26T06:03:00Z</when>
+        <gx:coord>2 1 3</gx:coord>
+        <gx:coord>2.5 1.5 3</gx:coord>
+      </gx:Track>
+    </Placemark>
+  </Document>
+</kml>`
+
+func TestParseKML(t *testing.T) {
+	t.Parallel()
+
+	captures, err := importing.ParseKML(strings.NewReader(kmlDoc))
+	assert.Nil(t, err)
+	assert.Len(t, captures, 3)
+
+	point := captures[0]
+	assert.Equal(t, f2P(10.5), point.Location.LAT)
+	assert.Equal(t, f2P(-66.9), point.Location.LNG)
+	assert.Equal(t, f2P(900), point.Location.Elevation)
+	assert.Equal(t, "1989-12-26T06:01:00Z", point.Timestamp.Time.Format("2006-01-02T15:04:05Z07:00"))
+	assert.Equal(t, []string{"station"}, point.Tags)
+	expectedMetrics := []domain.Metric{{Name: "power", Value: 10.0}, {Name: "humidity", Value: 80.0}}
+	assert.Equal(t, expectedMetrics, point.Payload.Payload)
+
+	assert.Equal(t, f2P(1.5), captures[2].Location.LAT)
+	assert.Equal(t, f2P(2.5), captures[2].Location.LNG)
+	assert.Equal(t, "1989-12-26T06:03:00Z", captures[2].Timestamp.Time.Format("2006-01-02T15:04:05Z07:00"))
+	assert.Equal(t, []domain.Metric{{Name: "power", Value: 5.0}}, captures[2].Payload.Payload)
+}
+
+func TestParseKMLFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{"not xml", "<kml>", "cannot unmarshal kml document"},
+		{
+			"invalid coordinates",
+			"<kml><Placemark><Point><coordinates>1</coordinates></Point></Placemark></kml>",
+			"invalid coordinates value 1",
+		},
+		{
+			"track with different length",
+			"<kml><Placemark><Track><when>2018-01-01</when></Track></Placemark></kml>",
+			"track when and coord elements must have the same length",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := importing.ParseKML(strings.NewReader(tc.doc))
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
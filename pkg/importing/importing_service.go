@@ -0,0 +1,95 @@
+package importing
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/gobuffalo/validate"
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/adding"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const errMissingPoints = "the document must contain at least one valid point"
+
+// Format is the type of document to import.
+type Format string
+
+var (
+	GPX Format = "gpx"
+	KML Format = "kml"
+)
+
+var parsers = map[Format]func(io.Reader) ([]adding.Capture, error){
+	GPX: ParseGPX,
+	KML: ParseKML,
+}
+
+// Document represent the source of captures to import.
+type Document struct {
+	Format Format
+	Data   io.Reader
+	// IgnoreErrors skip the points that are not valid captures instead of failing.
+	IgnoreErrors bool
+}
+
+// Service provides importing operations.
+type Service interface {
+	// ImportCaptures add the points of a document as captures to a repository.
+	ImportCaptures(*domain.Repository, Document) ([]domain.Capture, error)
+}
+
+type service struct {
+	s adding.MultiCaptureService
+}
+
+// NewService creates an importing service with the necessary dependencies
+func NewService(s adding.MultiCaptureService) Service {
+	return &service{s: s}
+}
+
+func (s *service) ImportCaptures(r *domain.Repository, doc Document) ([]domain.Capture, error) {
+	parse, ok := parsers[doc.Format]
+	if !ok {
+		return nil, errors.WithStack(invalidDocErr(fmt.Sprintf("not supported format %v", doc.Format)))
+	}
+	captures, err := parse(doc.Data)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse document")
+	}
+
+	multi, err := validateCaptures(captures, doc.IgnoreErrors)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.s.AddCaptures(r, multi)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not import captures")
+	}
+	return result, nil
+}
+
+func validateCaptures(captures []adding.Capture, ignoreErrors bool) (adding.MultiCapture, error) {
+	multi := adding.MultiCapture{IgnoreErrors: ignoreErrors, Captures: captures}
+	e := validate.NewErrors()
+	for i, capt := range captures {
+		if err := capt.Validate(); err != nil {
+			if !ignoreErrors {
+				key := fmt.Sprintf("point %v", i)
+				e.Add(key, fmt.Sprintf("%v: %v", key, err))
+			}
+			continue
+		}
+		multi.CapturesOK = append(multi.CapturesOK, capt)
+	}
+
+	if e.HasAny() {
+		return multi, errors.WithStack(invalidDocErr(e.Error()))
+	}
+	if len(multi.CapturesOK) == 0 {
+		return multi, errors.WithStack(invalidDocErr(errMissingPoints))
+	}
+	return multi, nil
+}
@@ -0,0 +1,108 @@
+package importing_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/adding"
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/importing"
+)
+
+type invalidErr interface{ IsInvalid() bool }
+
+type mockMultiCaptureService struct {
+	multi adding.MultiCapture
+	err   error
+}
+
+func (m *mockMultiCaptureService) AddCaptures(r *domain.Repository, multi adding.MultiCapture) ([]domain.Capture, error) {
+	m.multi = multi
+	return make([]domain.Capture, len(multi.CapturesOK)), m.err
+}
+
+func TestServiceImportCaptures(t *testing.T) {
+	t.Parallel()
+
+	adder := &mockMultiCaptureService{}
+	s := importing.NewService(adder)
+	repo := &domain.Repository{ID: kallax.NewULID()}
+
+	doc := importing.Document{Format: importing.GPX, Data: strings.NewReader(gpxDoc)}
+	captures, err := s.ImportCaptures(repo, doc)
+	assert.Nil(t, err)
+	assert.Len(t, captures, 2)
+	assert.Len(t, adder.multi.CapturesOK, 2)
+}
+
+func TestServiceImportCapturesIgnoringErrors(t *testing.T) {
+	t.Parallel()
+
+	adder := &mockMultiCaptureService{}
+	s := importing.NewService(adder)
+	repo := &domain.Repository{ID: kallax.NewULID()}
+
+	data := `<gpx><wpt lat="1" lon="1"><desc>power: 1</desc></wpt><wpt lat="1" lon="1"></wpt></gpx>`
+	doc := importing.Document{Format: importing.GPX, Data: strings.NewReader(data), IgnoreErrors: true}
+	captures, err := s.ImportCaptures(repo, doc)
+	assert.Nil(t, err)
+	assert.Len(t, captures, 1)
+}
+
+func TestServiceImportCapturesFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		doc  importing.Document
+		err  string
+	}{
+		{
+			name: "not supported format",
+			doc:  importing.Document{Format: "csv", Data: strings.NewReader("")},
+			err:  "not supported format csv",
+		},
+		{
+			name: "invalid document",
+			doc:  importing.Document{Format: importing.KML, Data: strings.NewReader("<kml>")},
+			err:  "could not parse document: cannot unmarshal kml document",
+		},
+		{
+			name: "point without metrics",
+			doc:  importing.Document{Format: importing.GPX, Data: strings.NewReader(`<gpx><wpt lat="1" lon="1"></wpt></gpx>`)},
+			err:  "point 0: payload value must not be blank",
+		},
+		{
+			name: "document without points",
+			doc:  importing.Document{Format: importing.GPX, Data: strings.NewReader(`<gpx></gpx>`)},
+			err:  "the document must contain at least one valid point",
+		},
+	}
+
+	s := importing.NewService(&mockMultiCaptureService{})
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := s.ImportCaptures(repo, tc.doc)
+			assert.EqualError(t, err, tc.err)
+			invalid, ok := errors.Cause(err).(invalidErr)
+			assert.True(t, ok)
+			assert.True(t, invalid.IsInvalid())
+		})
+	}
+}
+
+func TestServiceImportCapturesErrWhenAdding(t *testing.T) {
+	t.Parallel()
+
+	s := importing.NewService(&mockMultiCaptureService{err: errors.New("test")})
+	repo := &domain.Repository{ID: kallax.NewULID()}
+
+	doc := importing.Document{Format: importing.GPX, Data: strings.NewReader(gpxDoc)}
+	_, err := s.ImportCaptures(repo, doc)
+	assert.EqualError(t, err, "could not import captures: test")
+}
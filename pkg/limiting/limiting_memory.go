@@ -0,0 +1,53 @@
+package limiting
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// MemoryStore keeps the token buckets in memory. The limits are enforced per instance,
+// so it's intended for single instance deployments, development and tests.
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*domain.RateBucket
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*domain.RateBucket)}
+}
+
+// TakeRateToken takes a token from the bucket of the key.
+func (m *MemoryStore) TakeRateToken(key string, l domain.RateLimit, now time.Time) (*domain.RateBucket, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b, ok := m.buckets[key]
+	if !ok {
+		b = domain.NewRateBucket(key, l, now)
+		m.buckets[key] = b
+	}
+	taken := b.Take(l, now)
+	result := *b
+	return &result, taken, nil
+}
+
+// PruneRateBuckets forgets the buckets not updated since the given time.
+func (m *MemoryStore) PruneRateBuckets(before time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key, b := range m.buckets {
+		if b.UpdatedAt.Before(before) {
+			delete(m.buckets, key)
+		}
+	}
+	return nil
+}
+
+// Len returns the number of buckets kept.
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets)
+}
@@ -0,0 +1,47 @@
+package limiting_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/limiting"
+)
+
+func TestMemoryStoreTakeRateToken(t *testing.T) {
+	t.Parallel()
+
+	m := limiting.NewMemoryStore()
+	l := domain.RateLimit{Requests: 1, Period: time.Minute}
+	now := time.Now()
+
+	b, ok, err := m.TakeRateToken("test", l, now)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, 0, b.Remaining())
+
+	_, ok, err = m.TakeRateToken("test", l, now)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	_, ok, err = m.TakeRateToken("test", l, now.Add(time.Minute))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+}
+
+func TestMemoryStorePruneRateBuckets(t *testing.T) {
+	t.Parallel()
+
+	m := limiting.NewMemoryStore()
+	l := domain.RateLimit{Requests: 1, Period: time.Minute}
+	now := time.Now()
+
+	_, _, _ = m.TakeRateToken("old", l, now.Add(-time.Hour))
+	_, _, _ = m.TakeRateToken("new", l, now)
+	assert.Equal(t, 2, m.Len())
+
+	assert.Nil(t, m.PruneRateBuckets(now.Add(-time.Minute)))
+	assert.Equal(t, 1, m.Len())
+}
@@ -0,0 +1,101 @@
+package limiting
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// Store provides access to the token buckets storage.
+type Store interface {
+	// TakeRateToken refills the bucket of a key, creating it full when missing, and
+	// takes a token from it. It returns the bucket after the attempt and whether a
+	// token was taken. The whole operation must be atomic for the key.
+	TakeRateToken(key string, l domain.RateLimit, now time.Time) (*domain.RateBucket, bool, error)
+	// PruneRateBuckets removes the buckets not updated since the given time.
+	PruneRateBuckets(time.Time) error
+}
+
+// Result represents the state of the limit of a key after a request.
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Service provides rate limiting operations.
+type Service interface {
+	// Allow reports whether a request of the key is allowed by the limit.
+	Allow(key string, l domain.RateLimit) (*Result, error)
+}
+
+type service struct {
+	s      Store
+	maxAge time.Duration
+
+	mu       sync.Mutex
+	prunedAt time.Time
+}
+
+// NewService creates a limiting service with the necessary dependencies. The buckets not
+// used for maxAge are pruned, so it must be at least the longest period of the limits,
+// the time a bucket takes to be full again, which behaves as a missing one.
+func NewService(s Store, maxAge time.Duration) Service {
+	return &service{s: s, maxAge: maxAge, prunedAt: time.Now()}
+}
+
+func (s *service) Allow(key string, l domain.RateLimit) (*Result, error) {
+	now := time.Now()
+	s.prune(now)
+	b, ok, err := s.s.TakeRateToken(key, l, now)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not take rate token")
+	}
+	return &Result{
+		Allowed:    ok,
+		Limit:      l.Requests,
+		Remaining:  b.Remaining(),
+		Reset:      b.Reset(l),
+		RetryAfter: b.RetryAfter(l),
+	}, nil
+}
+
+// prune removes the unused buckets in background at most once every maxAge.
+func (s *service) prune(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.prunedAt) < s.maxAge {
+		return
+	}
+	s.prunedAt = now
+	go func() {
+		if err := s.s.PruneRateBuckets(now.Add(-s.maxAge)); err != nil {
+			fmt.Fprintln(os.Stderr, errors.Wrap(err, "could not prune rate buckets"))
+		}
+	}()
+}
+
+// Limits represents the limits of the route groups.
+type Limits struct {
+	Auth          domain.RateLimit
+	CapturesWrite domain.RateLimit
+	Listings      domain.RateLimit
+}
+
+// MaxPeriod returns the longest period of the limits.
+func (l Limits) MaxPeriod() time.Duration {
+	max := l.Auth.Period
+	for _, limit := range []domain.RateLimit{l.CapturesWrite, l.Listings} {
+		if limit.Period > max {
+			max = limit.Period
+		}
+	}
+	return max
+}
@@ -0,0 +1,87 @@
+package limiting_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/limiting"
+)
+
+type mockStore struct {
+	err error
+}
+
+func (m *mockStore) TakeRateToken(string, domain.RateLimit, time.Time) (*domain.RateBucket, bool, error) {
+	return nil, false, m.err
+}
+func (m *mockStore) PruneRateBuckets(time.Time) error { return m.err }
+
+func TestServiceAllow(t *testing.T) {
+	t.Parallel()
+
+	s := limiting.NewService(limiting.NewMemoryStore(), time.Minute)
+	l := domain.RateLimit{Requests: 2, Period: time.Minute}
+
+	result, err := s.Allow("test", l)
+	assert.Nil(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 2, result.Limit)
+	assert.Equal(t, 1, result.Remaining)
+	assert.Equal(t, time.Duration(0), result.RetryAfter)
+
+	_, err = s.Allow("test", l)
+	assert.Nil(t, err)
+	result, err = s.Allow("test", l)
+	assert.Nil(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.InDelta(t, float64(30*time.Second), float64(result.RetryAfter), float64(time.Second))
+	assert.InDelta(t, float64(time.Minute), float64(result.Reset), float64(time.Second))
+
+	result, err = s.Allow("other", l)
+	assert.Nil(t, err)
+	assert.True(t, result.Allowed)
+}
+
+func TestServiceAllowFailsWhenTake(t *testing.T) {
+	t.Parallel()
+
+	s := limiting.NewService(&mockStore{err: errors.New("test")}, time.Minute)
+	_, err := s.Allow("test", domain.RateLimit{Requests: 2, Period: time.Minute})
+	assert.EqualError(t, err, "could not take rate token: test")
+}
+
+func TestServiceAllowPrunes(t *testing.T) {
+	t.Parallel()
+
+	store := limiting.NewMemoryStore()
+	s := limiting.NewService(store, time.Millisecond)
+	l := domain.RateLimit{Requests: 2, Period: time.Millisecond}
+
+	_, err := s.Allow("first", l)
+	assert.Nil(t, err)
+	time.Sleep(5 * time.Millisecond)
+	_, err = s.Allow("second", l)
+	assert.Nil(t, err)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for store.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Equal(t, 1, store.Len())
+}
+
+func TestLimitsMaxPeriod(t *testing.T) {
+	t.Parallel()
+
+	limits := limiting.Limits{
+		Auth:          domain.RateLimit{Requests: 1, Period: time.Minute},
+		CapturesWrite: domain.RateLimit{Requests: 1, Period: time.Hour},
+		Listings:      domain.RateLimit{Requests: 1, Period: time.Second},
+	}
+	assert.Equal(t, time.Hour, limits.MaxPeriod())
+}
@@ -13,10 +13,18 @@ type CaptureStore interface {
 	List(*domain.Listing) ([]domain.Capture, int64, error)
 }
 
+// LocationFilter restricts the listed captures by their location. Area keeps the captures
+// inside the polygon, Near the captures at most Radius meters away from the point.
+type LocationFilter struct {
+	Area   domain.Polygon
+	Near   *domain.Point
+	Radius float64
+}
+
 // CaptureService provides capture repository operations.
 type CaptureService interface {
-	// ListRepoCaptures list repo captures.
-	ListRepoCaptures(*domain.Repository, *listing.Listing) (*ListCaptureResponse, error)
+	// ListRepoCaptures list repo captures filtered by location.
+	ListRepoCaptures(*domain.Repository, *listing.Listing, LocationFilter) (*ListCaptureResponse, error)
 }
 
 type captureService struct {
@@ -28,9 +36,12 @@ func NewCaptureService(s CaptureStore) CaptureService {
 	return &captureService{s: s}
 }
 
-func (s *captureService) ListRepoCaptures(r *domain.Repository, l *listing.Listing) (*ListCaptureResponse, error) {
+func (s *captureService) ListRepoCaptures(r *domain.Repository, l *listing.Listing, f LocationFilter) (*ListCaptureResponse, error) {
 	lcapt := domain.NewListing(*l)
 	lcapt.Owner = &r.ID
+	lcapt.Area = f.Area
+	lcapt.Near = f.Near
+	lcapt.Radius = f.Radius
 	captures, total, err := s.s.List(lcapt)
 	if err != nil {
 		return nil, errors.Wrap(err, "err getting repo captures")
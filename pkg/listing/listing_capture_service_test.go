@@ -17,9 +17,11 @@ type mockCaptureStore struct {
 	captures []domain.Capture
 	count    int64
 	err      error
+	listing  *domain.Listing
 }
 
-func (m *mockCaptureStore) List(*domain.Listing) ([]domain.Capture, int64, error) {
+func (m *mockCaptureStore) List(l *domain.Listing) ([]domain.Capture, int64, error) {
+	m.listing = l
 	return m.captures, m.count, m.err
 }
 
@@ -39,7 +41,7 @@ func TestCaptureServiceListRepoCapturesOK(t *testing.T) {
 			Offset: 0,
 		},
 	}
-	captures, err := s.ListRepoCaptures(r, l)
+	captures, err := s.ListRepoCaptures(r, l, listing.LocationFilter{})
 	assert.Nil(t, err)
 	assert.NotNil(t, captures.Listing)
 	assert.Equal(t, 2, len(captures.Results))
@@ -57,7 +59,7 @@ func TestCaptureServiceListRepoCapturesOKWhenEmpty(t *testing.T) {
 			Offset: 0,
 		},
 	}
-	captures, err := s.ListRepoCaptures(r, l)
+	captures, err := s.ListRepoCaptures(r, l, listing.LocationFilter{})
 	assert.Nil(t, err)
 	assert.NotNil(t, captures.Listing)
 	assert.Equal(t, 0, len(captures.Results))
@@ -77,6 +79,23 @@ func TestCaptureServiceListRepoCapturesErrWhenList(t *testing.T) {
 			Offset: 0,
 		},
 	}
-	_, err := s.ListRepoCaptures(r, l)
+	_, err := s.ListRepoCaptures(r, l, listing.LocationFilter{})
 	assert.EqualError(t, err, "err getting repo captures: test")
 }
+
+func TestCaptureServiceListRepoCapturesByLocation(t *testing.T) {
+	t.Parallel()
+
+	store := &mockCaptureStore{}
+	s := listing.NewCaptureService(store)
+	r := &domain.Repository{Name: "test", ID: kallax.NewULID(), UserID: kallax.NewULID(), Visibility: domain.Public}
+	l := &listingBastion.Listing{Paging: paging.Paging{Limit: 50}}
+	lat, lng := 1.0, 2.0
+	f := listing.LocationFilter{Near: &domain.Point{LAT: &lat, LNG: &lng}, Radius: 100}
+
+	_, err := s.ListRepoCaptures(r, l, f)
+	assert.Nil(t, err)
+	assert.Equal(t, &r.ID, store.listing.Owner)
+	assert.Equal(t, f.Near, store.listing.Near)
+	assert.Equal(t, 100.0, store.listing.Radius)
+}
@@ -19,6 +19,9 @@ type RepoService interface {
 	GetUserRepos(*domain.User, *listing.Listing) (*ListRepositoryResponse, error)
 	// GetPublicRepos get all the public repos.
 	GetPublicRepos(*listing.Listing) (*ListRepositoryResponse, error)
+	// GetOrganizationRepos get the repositories of an organization. Only the public ones
+	// are listed when the role doesn't grant read access to the organization repositories.
+	GetOrganizationRepos(*domain.Organization, domain.Role, *listing.Listing) (*ListRepositoryResponse, error)
 }
 
 type repoService struct {
@@ -52,6 +55,20 @@ func (s *repoService) GetPublicRepos(l *listing.Listing) (*ListRepositoryRespons
 	return newListRepoResponse(repos, l), nil
 }
 
+func (s *repoService) GetOrganizationRepos(org *domain.Organization, role domain.Role, l *listing.Listing) (*ListRepositoryResponse, error) {
+	lrepo := domain.NewListing(*l)
+	lrepo.Organization = &org.ID
+	if !role.Includes(domain.ReadRole) {
+		lrepo.Visibility = &domain.Public
+	}
+	repos, total, err := s.s.List(lrepo)
+	if err != nil {
+		return nil, errors.Wrap(err, "err getting organization repos")
+	}
+	l.Paging.Total = total
+	return newListRepoResponse(repos, l), nil
+}
+
 type ListRepositoryResponse struct {
 	Results []domain.Repository `json:"results"`
 	Listing *listing.Listing    `json:"listing"`
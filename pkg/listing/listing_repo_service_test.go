@@ -14,12 +14,14 @@ import (
 )
 
 type mockRepoStore struct {
-	repos []domain.Repository
-	count int64
-	err   error
+	repos   []domain.Repository
+	count   int64
+	err     error
+	listing *domain.Listing
 }
 
-func (m *mockRepoStore) List(*domain.Listing) ([]domain.Repository, int64, error) {
+func (m *mockRepoStore) List(l *domain.Listing) ([]domain.Repository, int64, error) {
+	m.listing = l
 	return m.repos, m.count, m.err
 }
 
@@ -116,3 +118,36 @@ func TestServiceGetPublicReposErrWhenList(t *testing.T) {
 	_, err := s.GetPublicRepos(l)
 	assert.EqualError(t, err, "err getting public repos: test")
 }
+
+func TestServiceGetOrganizationRepos(t *testing.T) {
+	t.Parallel()
+
+	org := &domain.Organization{ID: kallax.NewULID()}
+	tt := []struct {
+		name       string
+		role       domain.Role
+		visibility *domain.Visibility
+	}{
+		{"without access", "", &domain.Public},
+		{"with read access", domain.ReadRole, nil},
+		{"owner", domain.OwnerRole, nil},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			store := &mockRepoStore{repos: []domain.Repository{{Name: "test1"}}, count: 1}
+			s := listing.NewRepoService(store)
+			l := &listingBastion.Listing{Paging: paging.Paging{Limit: 50}}
+			repos, err := s.GetOrganizationRepos(org, tc.role, l)
+			assert.Nil(t, err)
+			assert.Equal(t, 1, len(repos.Results))
+			assert.Equal(t, int64(1), repos.Listing.Paging.Total)
+			assert.Equal(t, org.ID, *store.listing.Organization)
+			assert.Equal(t, tc.visibility, store.listing.Visibility)
+		})
+	}
+
+	s := listing.NewRepoService(&mockRepoStore{err: errors.New("test")})
+	_, err := s.GetOrganizationRepos(org, domain.ReadRole, &listingBastion.Listing{})
+	assert.EqualError(t, err, "err getting organization repos: test")
+}
@@ -0,0 +1,9 @@
+package mailing
+
+import "net/smtp"
+
+// SetSendMail is a helper function only exported for test.
+// It's intended to be used for stub the smtp.SendMail function.
+func SetSendMail(m *SMTPMailer, send func(string, smtp.Auth, string, []string, []byte) error) {
+	m.send = send
+}
@@ -0,0 +1,14 @@
+package mailing
+
+// Message represents a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends emails.
+type Mailer interface {
+	// Send delivers a message to its recipient.
+	Send(Message) error
+}
@@ -0,0 +1,56 @@
+package mailing
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// SMTPMailer sends emails through a SMTP server.
+type SMTPMailer struct {
+	addr string
+	from string
+	auth smtp.Auth
+	send func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+}
+
+// NewSMTPMailer returns a mailer for the server at addr (host:port) sending from the
+// given address. When username is empty the server is used without authentication.
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	m := &SMTPMailer{addr: addr, from: from, send: smtp.SendMail}
+	if username != "" {
+		host, _, _ := net.SplitHostPort(addr)
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m
+}
+
+// Send delivers the message to the SMTP server.
+func (m *SMTPMailer) Send(msg Message) error {
+	if err := m.send(m.addr, m.auth, m.from, []string{msg.To}, m.format(msg, time.Now())); err != nil {
+		return errors.Wrapf(err, "could not send email to %v", msg.To)
+	}
+	return nil
+}
+
+func (m *SMTPMailer) format(msg Message, date time.Time) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", headerValue(m.from))
+	fmt.Fprintf(&b, "To: %s\r\n", headerValue(msg.To))
+	fmt.Fprintf(&b, "Subject: %s\r\n", headerValue(msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(strings.Replace(msg.Body, "\n", "\r\n", -1))
+	return b.Bytes()
+}
+
+// headerValue drops line breaks so values can't inject headers.
+func headerValue(v string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(v)
+}
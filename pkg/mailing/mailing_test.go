@@ -0,0 +1,73 @@
+package mailing_test
+
+import (
+	"net/smtp"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/mailing"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	t.Parallel()
+
+	m := mailing.NewMemoryMailer()
+	assert.Empty(t, m.Messages())
+	assert.Nil(t, m.Send(mailing.Message{To: "a@example.com", Subject: "first"}))
+	assert.Nil(t, m.Send(mailing.Message{To: "b@example.com", Subject: "second"}))
+
+	messages := m.Messages()
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "first", messages[0].Subject)
+	assert.Equal(t, "b@example.com", messages[1].To)
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	t.Parallel()
+
+	var gotAddr, gotFrom string
+	var gotTo []string
+	var gotMsg []byte
+	var gotAuth smtp.Auth
+	m := mailing.NewSMTPMailer("smtp.example.com:587", "capture@example.com", "user", "pass")
+	mailing.SetSendMail(m, func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAddr, gotAuth, gotFrom, gotTo, gotMsg = addr, a, from, to, msg
+		return nil
+	})
+
+	err := m.Send(mailing.Message{To: "user@example.com", Subject: "Hi\r\nBcc: evil@example.com", Body: "line 1\nline 2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "smtp.example.com:587", gotAddr)
+	assert.NotNil(t, gotAuth)
+	assert.Equal(t, "capture@example.com", gotFrom)
+	assert.Equal(t, []string{"user@example.com"}, gotTo)
+	assert.Contains(t, string(gotMsg), "To: user@example.com\r\n")
+	assert.Contains(t, string(gotMsg), "Subject: HiBcc: evil@example.com\r\n")
+	assert.Contains(t, string(gotMsg), "\r\n\r\nline 1\r\nline 2")
+}
+
+func TestSMTPMailerSendWithoutAuth(t *testing.T) {
+	t.Parallel()
+
+	var gotAuth smtp.Auth
+	m := mailing.NewSMTPMailer("localhost:25", "capture@example.com", "", "")
+	mailing.SetSendMail(m, func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotAuth = a
+		return nil
+	})
+	assert.Nil(t, m.Send(mailing.Message{To: "user@example.com"}))
+	assert.Nil(t, gotAuth)
+}
+
+func TestSMTPMailerSendErr(t *testing.T) {
+	t.Parallel()
+
+	m := mailing.NewSMTPMailer("localhost:25", "capture@example.com", "", "")
+	mailing.SetSendMail(m, func(string, smtp.Auth, string, []string, []byte) error {
+		return errors.New("test")
+	})
+	err := m.Send(mailing.Message{To: "user@example.com"})
+	assert.EqualError(t, err, "could not send email to user@example.com: test")
+}
@@ -0,0 +1,97 @@
+package organizing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gobuffalo/validate"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	errNameRequired  = "name must not be blank"
+	errEmailRequired = "email must not be blank"
+	errInvalidEmail  = "invalid email"
+	errRoleRequired  = "role must not be blank"
+)
+
+// Payload represents the data to create an organization.
+type Payload struct {
+	Name *string `json:"name"`
+}
+
+func (p *Payload) Validate() error {
+	e := validate.NewErrors()
+	if p.Name == nil || len(strings.TrimSpace(*p.Name)) == 0 {
+		e.Add("name", errNameRequired)
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// MemberPayload represents the data to add an user to an organization. The role
+// is optional, by default the user is added as a regular member.
+type MemberPayload struct {
+	Email *string `json:"email"`
+	Role  *string `json:"role"`
+}
+
+func (p *MemberPayload) Validate() error {
+	e := validate.NewErrors()
+	validateEmail(e, p.Email)
+	if p.Role != nil && !domain.AllowedMemberRole(*p.Role) {
+		e.Add("role", fmt.Sprintf("not allowed role %v. it could be one of %v", *p.Role, domain.MemberRoles))
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// TeamPayload represents the data to create a team.
+type TeamPayload struct {
+	Name *string `json:"name"`
+	Role *string `json:"role"`
+}
+
+func (p *TeamPayload) Validate() error {
+	e := validate.NewErrors()
+	if p.Name == nil || len(strings.TrimSpace(*p.Name)) == 0 {
+		e.Add("name", errNameRequired)
+	}
+	if p.Role == nil {
+		e.Add("role", errRoleRequired)
+	} else if !domain.AllowedRole(*p.Role) {
+		e.Add("role", fmt.Sprintf("not allowed role %v. it could be one of %v", *p.Role, domain.Roles))
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// TeamMemberPayload represents the data to add a member of the organization to a team.
+type TeamMemberPayload struct {
+	Email *string `json:"email"`
+}
+
+func (p *TeamMemberPayload) Validate() error {
+	e := validate.NewErrors()
+	validateEmail(e, p.Email)
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+func validateEmail(e *validate.Errors, email *string) {
+	if email == nil {
+		e.Add("email", errEmailRequired)
+	} else if !govalidator.IsEmail(*email) {
+		e.Add("email", errInvalidEmail)
+	}
+}
@@ -0,0 +1,391 @@
+package organizing
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const errLastOwner invalidErr = "the organization must have at least one owner"
+
+type notFoundErr interface {
+	// NotFound returns true when a resource is not found.
+	NotFound() bool
+}
+
+func isNotFound(err error) bool {
+	if e, ok := errors.Cause(err).(notFoundErr); ok {
+		return e.NotFound()
+	}
+	return false
+}
+
+type constraintErr interface {
+	UniqueConstraint() bool
+}
+
+func isConstraintErr(err error) bool {
+	if e, ok := errors.Cause(err).(constraintErr); ok {
+		return e.UniqueConstraint()
+	}
+	return false
+}
+
+type conflictErr string
+
+func (e conflictErr) Error() string  { return string(e) }
+func (e conflictErr) Conflict() bool { return true }
+
+type invalidErr string
+
+func (i invalidErr) Error() string   { return string(i) }
+func (i invalidErr) IsInvalid() bool { return true }
+
+type notFound string
+
+func (n notFound) Error() string  { return string(n) }
+func (n notFound) NotFound() bool { return true }
+
+// UserStore provides access to the user storage.
+type UserStore interface {
+	// GetUserByEmail get a user by email.
+	GetUserByEmail(string) (*domain.User, error)
+}
+
+// Store provides access to the organization storage.
+type Store interface {
+	// CreateOrganization stores a new organization with its first member.
+	CreateOrganization(*domain.Organization, *domain.Member) error
+	// ListUserOrganizations retrieve the organizations of an user.
+	ListUserOrganizations(userID kallax.ULID) ([]domain.Organization, error)
+	// GetOrganization retrieve an organization.
+	GetOrganization(id kallax.ULID) (*domain.Organization, error)
+	// CreateMember stores a new member of an organization.
+	CreateMember(*domain.Member) error
+	// ListMembers retrieve the members of an organization.
+	ListMembers(orgID kallax.ULID) ([]domain.Member, error)
+	// GetMember retrieve a member of an organization.
+	GetMember(id, orgID kallax.ULID) (*domain.Member, error)
+	// GetUserMember retrieve the member of an organization for an user.
+	GetUserMember(orgID, userID kallax.ULID) (*domain.Member, error)
+	// RemoveMember deletes a member and its memberships to the teams of the organization.
+	RemoveMember(*domain.Member) error
+	// CreateTeam stores a new team.
+	CreateTeam(*domain.Team) error
+	// ListTeams retrieve the teams of an organization.
+	ListTeams(orgID kallax.ULID) ([]domain.Team, error)
+	// GetTeam retrieve a team of an organization.
+	GetTeam(id, orgID kallax.ULID) (*domain.Team, error)
+	// RemoveTeam deletes a team and its memberships.
+	RemoveTeam(*domain.Team) error
+	// CreateTeamMember stores a new membership to a team.
+	CreateTeamMember(*domain.TeamMember) error
+	// RemoveTeamMember deletes the membership of an user to a team.
+	RemoveTeamMember(teamID, userID kallax.ULID) error
+	// ListUserTeams retrieve the teams of an organization an user belongs to.
+	ListUserTeams(orgID, userID kallax.ULID) ([]domain.Team, error)
+}
+
+// Service provides organization operations.
+type Service interface {
+	// CreateOrganization creates an organization owned by the user.
+	CreateOrganization(*domain.User, Payload) (*domain.Organization, error)
+	// ListOrganizations list the organizations an user belongs to.
+	ListOrganizations(*domain.User) ([]domain.Organization, error)
+	// GetOrganization retrieve an organization the user belongs to.
+	GetOrganization(kallax.ULID, *domain.User) (*domain.Organization, error)
+	// GetOrganizationRole returns the role of an user over the repositories of an organization.
+	GetOrganizationRole(*domain.Organization, *domain.User) (domain.Role, error)
+	// AddMember adds an user to an organization.
+	AddMember(*domain.Organization, MemberPayload) (*domain.Member, error)
+	// ListMembers list the members of an organization.
+	ListMembers(*domain.Organization) ([]domain.Member, error)
+	// RemoveMember removes a member from an organization and its teams.
+	RemoveMember(kallax.ULID, *domain.Organization) error
+	// CreateTeam creates a team in an organization.
+	CreateTeam(*domain.Organization, TeamPayload) (*domain.Team, error)
+	// ListTeams list the teams of an organization.
+	ListTeams(*domain.Organization) ([]domain.Team, error)
+	// RemoveTeam removes a team from an organization.
+	RemoveTeam(kallax.ULID, *domain.Organization) error
+	// AddTeamMember adds a member of the organization to a team.
+	AddTeamMember(kallax.ULID, *domain.Organization, TeamMemberPayload) (*domain.TeamMember, error)
+	// RemoveTeamMember removes an user from a team.
+	RemoveTeamMember(teamID, userID kallax.ULID, org *domain.Organization) error
+	// GetRole returns the role of an user over a repository owned by an organization,
+	// empty when the repository isn't owned by an organization or the user has no access.
+	GetRole(*domain.Repository, *domain.User) (domain.Role, error)
+}
+
+type service struct {
+	us UserStore
+	s  Store
+}
+
+// NewService creates an organizing service with the necessary dependencies
+func NewService(us UserStore, s Store) Service {
+	return &service{us: us, s: s}
+}
+
+func (s *service) CreateOrganization(u *domain.User, p Payload) (*domain.Organization, error) {
+	now := time.Now()
+	org := &domain.Organization{
+		ID:        kallax.NewULID(),
+		Name:      strings.TrimSpace(*p.Name),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	owner := &domain.Member{
+		ID:             kallax.NewULID(),
+		Role:           domain.OwnerMember,
+		Email:          u.Email,
+		CreatedAt:      now,
+		OrganizationID: org.ID,
+		UserID:         u.ID,
+	}
+	if err := s.s.CreateOrganization(org, owner); err != nil {
+		if isConstraintErr(err) {
+			return nil, errors.WithStack(conflictErr(fmt.Sprintf("organization %v already exist", org.Name)))
+		}
+		return nil, errors.Wrap(err, "could not create organization")
+	}
+	return org, nil
+}
+
+func (s *service) ListOrganizations(u *domain.User) ([]domain.Organization, error) {
+	orgs, err := s.s.ListUserOrganizations(u.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list organizations")
+	}
+	if orgs == nil {
+		orgs = make([]domain.Organization, 0)
+	}
+	return orgs, nil
+}
+
+func (s *service) GetOrganization(id kallax.ULID, u *domain.User) (*domain.Organization, error) {
+	if _, err := s.s.GetUserMember(id, u.ID); err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(notFound(fmt.Sprintf("organization %v not found", id)))
+		}
+		return nil, errors.Wrap(err, "could not get member")
+	}
+	org, err := s.s.GetOrganization(id)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get organization")
+	}
+	return org, nil
+}
+
+func (s *service) GetOrganizationRole(org *domain.Organization, u *domain.User) (domain.Role, error) {
+	m, err := s.s.GetUserMember(org.ID, u.ID)
+	if err != nil {
+		if isNotFound(err) {
+			return "", nil
+		}
+		return "", errors.Wrap(err, "could not get member")
+	}
+	if m.Role == domain.OwnerMember {
+		return domain.OwnerRole, nil
+	}
+
+	teams, err := s.s.ListUserTeams(org.ID, u.ID)
+	if err != nil {
+		return "", errors.Wrap(err, "could not list teams of member")
+	}
+	var role domain.Role
+	for _, t := range teams {
+		if !role.Includes(t.Role) {
+			role = t.Role
+		}
+	}
+	return role, nil
+}
+
+func (s *service) getUser(email string) (*domain.User, error) {
+	u, err := s.us.GetUserByEmail(email)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(notFound(fmt.Sprintf("user with email %v not found", email)))
+		}
+		return nil, errors.Wrap(err, "could not get user by email")
+	}
+	return u, nil
+}
+
+func (s *service) AddMember(org *domain.Organization, p MemberPayload) (*domain.Member, error) {
+	u, err := s.getUser(*p.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &domain.Member{
+		ID:             kallax.NewULID(),
+		Role:           domain.RegularMember,
+		Email:          u.Email,
+		CreatedAt:      time.Now(),
+		OrganizationID: org.ID,
+		UserID:         u.ID,
+	}
+	if p.Role != nil {
+		m.Role = domain.MemberRole(*p.Role)
+	}
+	if err := s.s.CreateMember(m); err != nil {
+		if isConstraintErr(err) {
+			return nil, errors.WithStack(conflictErr(fmt.Sprintf("%v is already a member of the organization", u.Email)))
+		}
+		return nil, errors.Wrap(err, "could not create member")
+	}
+	return m, nil
+}
+
+func (s *service) ListMembers(org *domain.Organization) ([]domain.Member, error) {
+	members, err := s.s.ListMembers(org.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list members")
+	}
+	if members == nil {
+		members = make([]domain.Member, 0)
+	}
+	return members, nil
+}
+
+func (s *service) RemoveMember(id kallax.ULID, org *domain.Organization) error {
+	m, err := s.s.GetMember(id, org.ID)
+	if err != nil {
+		if isNotFound(err) {
+			return errors.WithStack(notFound(fmt.Sprintf("member %v not found", id)))
+		}
+		return errors.Wrap(err, "could not get member")
+	}
+
+	if m.Role == domain.OwnerMember {
+		members, err := s.ListMembers(org)
+		if err != nil {
+			return err
+		}
+		owners := 0
+		for _, member := range members {
+			if member.Role == domain.OwnerMember {
+				owners++
+			}
+		}
+		if owners <= 1 {
+			return errors.WithStack(errLastOwner)
+		}
+	}
+
+	if err := s.s.RemoveMember(m); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not remove member %v", m.ID))
+	}
+	return nil
+}
+
+func (s *service) CreateTeam(org *domain.Organization, p TeamPayload) (*domain.Team, error) {
+	now := time.Now()
+	t := &domain.Team{
+		ID:             kallax.NewULID(),
+		Name:           strings.TrimSpace(*p.Name),
+		Role:           domain.Role(*p.Role),
+		CreatedAt:      now,
+		UpdatedAt:      now,
+		OrganizationID: org.ID,
+	}
+	if err := s.s.CreateTeam(t); err != nil {
+		if isConstraintErr(err) {
+			return nil, errors.WithStack(conflictErr(fmt.Sprintf("team %v already exist", t.Name)))
+		}
+		return nil, errors.Wrap(err, "could not create team")
+	}
+	return t, nil
+}
+
+func (s *service) ListTeams(org *domain.Organization) ([]domain.Team, error) {
+	teams, err := s.s.ListTeams(org.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list teams")
+	}
+	if teams == nil {
+		teams = make([]domain.Team, 0)
+	}
+	return teams, nil
+}
+
+func (s *service) getTeam(id kallax.ULID, org *domain.Organization) (*domain.Team, error) {
+	t, err := s.s.GetTeam(id, org.ID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(notFound(fmt.Sprintf("team %v not found", id)))
+		}
+		return nil, errors.Wrap(err, "could not get team")
+	}
+	return t, nil
+}
+
+func (s *service) RemoveTeam(id kallax.ULID, org *domain.Organization) error {
+	t, err := s.getTeam(id, org)
+	if err != nil {
+		return err
+	}
+	if err := s.s.RemoveTeam(t); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not remove team %v", t.ID))
+	}
+	return nil
+}
+
+func (s *service) AddTeamMember(teamID kallax.ULID, org *domain.Organization, p TeamMemberPayload) (*domain.TeamMember, error) {
+	t, err := s.getTeam(teamID, org)
+	if err != nil {
+		return nil, err
+	}
+	u, err := s.getUser(*p.Email)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.s.GetUserMember(org.ID, u.ID); err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(invalidErr(fmt.Sprintf("%v is not a member of the organization", u.Email)))
+		}
+		return nil, errors.Wrap(err, "could not get member")
+	}
+
+	tm := &domain.TeamMember{
+		ID:        kallax.NewULID(),
+		CreatedAt: time.Now(),
+		TeamID:    t.ID,
+		UserID:    u.ID,
+	}
+	if err := s.s.CreateTeamMember(tm); err != nil {
+		if isConstraintErr(err) {
+			return nil, errors.WithStack(conflictErr(fmt.Sprintf("%v is already a member of the team", u.Email)))
+		}
+		return nil, errors.Wrap(err, "could not create team member")
+	}
+	return tm, nil
+}
+
+func (s *service) RemoveTeamMember(teamID, userID kallax.ULID, org *domain.Organization) error {
+	t, err := s.getTeam(teamID, org)
+	if err != nil {
+		return err
+	}
+	if err := s.s.RemoveTeamMember(t.ID, userID); err != nil {
+		if isNotFound(err) {
+			return errors.WithStack(notFound(fmt.Sprintf("user %v is not a member of the team", userID)))
+		}
+		return errors.Wrap(err, "could not remove team member")
+	}
+	return nil
+}
+
+func (s *service) GetRole(r *domain.Repository, u *domain.User) (domain.Role, error) {
+	if r.OrganizationID == nil {
+		return "", nil
+	}
+	return s.GetOrganizationRole(&domain.Organization{ID: *r.OrganizationID}, u)
+}
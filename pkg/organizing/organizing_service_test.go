@@ -0,0 +1,282 @@
+package organizing_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/organizing"
+)
+
+type notFoundErr string
+
+func (n notFoundErr) Error() string  { return string(n) }
+func (n notFoundErr) NotFound() bool { return true }
+
+type uniqueConstraintErr string
+
+func (u uniqueConstraintErr) Error() string          { return string(u) }
+func (u uniqueConstraintErr) UniqueConstraint() bool { return true }
+
+type mockUserStore struct {
+	usr *domain.User
+	err error
+}
+
+func (m *mockUserStore) GetUserByEmail(string) (*domain.User, error) {
+	if m.usr == nil && m.err == nil {
+		return nil, notFoundErr("not found")
+	}
+	return m.usr, m.err
+}
+
+type mockStore struct {
+	org           *domain.Organization
+	members       []domain.Member
+	teams         []domain.Team
+	userTeams     []domain.Team
+	createErr     error
+	err           error
+	removedMember *domain.Member
+	removedTeam   *domain.Team
+}
+
+func (m *mockStore) CreateOrganization(*domain.Organization, *domain.Member) error {
+	return m.createErr
+}
+func (m *mockStore) ListUserOrganizations(kallax.ULID) ([]domain.Organization, error) {
+	return nil, m.err
+}
+func (m *mockStore) GetOrganization(kallax.ULID) (*domain.Organization, error) {
+	return m.org, m.err
+}
+func (m *mockStore) CreateMember(*domain.Member) error { return m.createErr }
+func (m *mockStore) ListMembers(kallax.ULID) ([]domain.Member, error) {
+	return m.members, m.err
+}
+func (m *mockStore) GetMember(id, _ kallax.ULID) (*domain.Member, error) {
+	for i := range m.members {
+		if m.members[i].ID == id {
+			return &m.members[i], nil
+		}
+	}
+	return nil, notFoundErr("not found")
+}
+func (m *mockStore) GetUserMember(_, userID kallax.ULID) (*domain.Member, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	for i := range m.members {
+		if m.members[i].UserID == userID {
+			return &m.members[i], nil
+		}
+	}
+	return nil, notFoundErr("not found")
+}
+func (m *mockStore) RemoveMember(member *domain.Member) error {
+	m.removedMember = member
+	return m.err
+}
+func (m *mockStore) CreateTeam(*domain.Team) error { return m.createErr }
+func (m *mockStore) ListTeams(kallax.ULID) ([]domain.Team, error) {
+	return m.teams, m.err
+}
+func (m *mockStore) GetTeam(id, _ kallax.ULID) (*domain.Team, error) {
+	for i := range m.teams {
+		if m.teams[i].ID == id {
+			return &m.teams[i], nil
+		}
+	}
+	return nil, notFoundErr("not found")
+}
+func (m *mockStore) RemoveTeam(t *domain.Team) error {
+	m.removedTeam = t
+	return m.err
+}
+func (m *mockStore) CreateTeamMember(*domain.TeamMember) error { return m.createErr }
+func (m *mockStore) RemoveTeamMember(kallax.ULID, kallax.ULID) error {
+	return m.createErr
+}
+func (m *mockStore) ListUserTeams(kallax.ULID, kallax.ULID) ([]domain.Team, error) {
+	return m.userTeams, nil
+}
+
+var (
+	owner  = &domain.User{ID: kallax.NewULID(), Email: "owner@example.com"}
+	member = &domain.User{ID: kallax.NewULID(), Email: "member@example.com"}
+	org    = &domain.Organization{ID: kallax.NewULID(), Name: "acme"}
+	team   = domain.Team{ID: kallax.NewULID(), Name: "devs", Role: domain.WriteRole, OrganizationID: org.ID}
+)
+
+func s2P(s string) *string { return &s }
+
+func defaultMembers() []domain.Member {
+	return []domain.Member{
+		{ID: kallax.NewULID(), Role: domain.OwnerMember, UserID: owner.ID, OrganizationID: org.ID},
+		{ID: kallax.NewULID(), Role: domain.RegularMember, UserID: member.ID, OrganizationID: org.ID},
+	}
+}
+
+func TestServiceCreateOrganization(t *testing.T) {
+	t.Parallel()
+
+	s := organizing.NewService(&mockUserStore{}, &mockStore{})
+	o, err := s.CreateOrganization(owner, organizing.Payload{Name: s2P(" acme ")})
+	assert.Nil(t, err)
+	assert.Equal(t, "acme", o.Name)
+
+	s = organizing.NewService(&mockUserStore{}, &mockStore{createErr: uniqueConstraintErr("test")})
+	_, err = s.CreateOrganization(owner, organizing.Payload{Name: s2P("acme")})
+	assert.EqualError(t, err, "organization acme already exist")
+
+	s = organizing.NewService(&mockUserStore{}, &mockStore{createErr: errors.New("test")})
+	_, err = s.CreateOrganization(owner, organizing.Payload{Name: s2P("acme")})
+	assert.EqualError(t, err, "could not create organization: test")
+}
+
+func TestServiceGetOrganization(t *testing.T) {
+	t.Parallel()
+
+	s := organizing.NewService(&mockUserStore{}, &mockStore{org: org, members: defaultMembers()})
+	o, err := s.GetOrganization(org.ID, member)
+	assert.Nil(t, err)
+	assert.Equal(t, org.ID, o.ID)
+
+	_, err = s.GetOrganization(org.ID, &domain.User{ID: kallax.NewULID()})
+	assert.EqualError(t, err, "organization "+org.ID.String()+" not found")
+
+	s = organizing.NewService(&mockUserStore{}, &mockStore{err: errors.New("test")})
+	_, err = s.GetOrganization(org.ID, member)
+	assert.EqualError(t, err, "could not get member: test")
+}
+
+func TestServiceGetRole(t *testing.T) {
+	t.Parallel()
+
+	admins := domain.Team{ID: kallax.NewULID(), Role: domain.AdminRole}
+	readers := domain.Team{ID: kallax.NewULID(), Role: domain.ReadRole}
+	orgRepo := &domain.Repository{ID: kallax.NewULID(), OrganizationID: &org.ID}
+	userRepo := &domain.Repository{ID: kallax.NewULID(), UserID: member.ID}
+
+	tt := []struct {
+		name     string
+		store    *mockStore
+		repo     *domain.Repository
+		user     *domain.User
+		expected domain.Role
+	}{
+		{"repo without organization", &mockStore{members: defaultMembers()}, userRepo, owner, ""},
+		{"not a member", &mockStore{members: defaultMembers()}, orgRepo, &domain.User{ID: kallax.NewULID()}, ""},
+		{"owner", &mockStore{members: defaultMembers()}, orgRepo, owner, domain.OwnerRole},
+		{"member without teams", &mockStore{members: defaultMembers()}, orgRepo, member, ""},
+		{"member of teams", &mockStore{members: defaultMembers(), userTeams: []domain.Team{readers, admins, team}}, orgRepo, member, domain.AdminRole},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := organizing.NewService(&mockUserStore{}, tc.store)
+			role, err := s.GetRole(tc.repo, tc.user)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, role)
+		})
+	}
+
+	s := organizing.NewService(&mockUserStore{}, &mockStore{err: errors.New("test")})
+	_, err := s.GetRole(orgRepo, member)
+	assert.EqualError(t, err, "could not get member: test")
+}
+
+func TestServiceAddMember(t *testing.T) {
+	t.Parallel()
+
+	s := organizing.NewService(&mockUserStore{usr: member}, &mockStore{})
+	m, err := s.AddMember(org, organizing.MemberPayload{Email: s2P(member.Email)})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.RegularMember, m.Role)
+	assert.Equal(t, member.ID, m.UserID)
+	assert.Equal(t, org.ID, m.OrganizationID)
+
+	m, err = s.AddMember(org, organizing.MemberPayload{Email: s2P(member.Email), Role: s2P("owner")})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.OwnerMember, m.Role)
+
+	tt := []struct {
+		name  string
+		us    *mockUserStore
+		store *mockStore
+		err   string
+	}{
+		{"unknown user", &mockUserStore{}, &mockStore{}, "user with email member@example.com not found"},
+		{"get user err", &mockUserStore{err: errors.New("test")}, &mockStore{}, "could not get user by email: test"},
+		{"duplicated", &mockUserStore{usr: member}, &mockStore{createErr: uniqueConstraintErr("test")}, "member@example.com is already a member of the organization"},
+		{"create err", &mockUserStore{usr: member}, &mockStore{createErr: errors.New("test")}, "could not create member: test"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := organizing.NewService(tc.us, tc.store)
+			_, err := s.AddMember(org, organizing.MemberPayload{Email: s2P("member@example.com")})
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestServiceRemoveMember(t *testing.T) {
+	t.Parallel()
+
+	members := defaultMembers()
+	store := &mockStore{members: members}
+	s := organizing.NewService(&mockUserStore{}, store)
+	assert.Nil(t, s.RemoveMember(members[1].ID, org))
+	assert.Equal(t, members[1].ID, store.removedMember.ID)
+
+	err := s.RemoveMember(members[0].ID, org)
+	assert.EqualError(t, err, "the organization must have at least one owner")
+
+	err = s.RemoveMember(kallax.NewULID(), org)
+	assert.Contains(t, err.Error(), "not found")
+}
+
+func TestServiceTeams(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{members: defaultMembers(), teams: []domain.Team{team}}
+	s := organizing.NewService(&mockUserStore{usr: member}, store)
+
+	created, err := s.CreateTeam(org, organizing.TeamPayload{Name: s2P("ops"), Role: s2P("admin")})
+	assert.Nil(t, err)
+	assert.Equal(t, domain.AdminRole, created.Role)
+	assert.Equal(t, org.ID, created.OrganizationID)
+
+	tm, err := s.AddTeamMember(team.ID, org, organizing.TeamMemberPayload{Email: s2P(member.Email)})
+	assert.Nil(t, err)
+	assert.Equal(t, team.ID, tm.TeamID)
+	assert.Equal(t, member.ID, tm.UserID)
+
+	assert.Nil(t, s.RemoveTeamMember(team.ID, member.ID, org))
+	assert.Nil(t, s.RemoveTeam(team.ID, org))
+	assert.Equal(t, team.ID, store.removedTeam.ID)
+
+	_, err = s.AddTeamMember(kallax.NewULID(), org, organizing.TeamMemberPayload{Email: s2P(member.Email)})
+	assert.Contains(t, err.Error(), "not found")
+
+	outsider := &domain.User{ID: kallax.NewULID(), Email: "outsider@example.com"}
+	s = organizing.NewService(&mockUserStore{usr: outsider}, store)
+	_, err = s.AddTeamMember(team.ID, org, organizing.TeamMemberPayload{Email: s2P(outsider.Email)})
+	assert.EqualError(t, err, "outsider@example.com is not a member of the organization")
+
+	store = &mockStore{members: defaultMembers(), teams: []domain.Team{team}, createErr: uniqueConstraintErr("test")}
+	s = organizing.NewService(&mockUserStore{usr: member}, store)
+	_, err = s.CreateTeam(org, organizing.TeamPayload{Name: s2P("devs"), Role: s2P("read")})
+	assert.EqualError(t, err, "team devs already exist")
+	_, err = s.AddTeamMember(team.ID, org, organizing.TeamMemberPayload{Email: s2P(member.Email)})
+	assert.EqualError(t, err, "member@example.com is already a member of the team")
+
+	store = &mockStore{teams: []domain.Team{team}, createErr: notFoundErr("test")}
+	s = organizing.NewService(&mockUserStore{}, store)
+	err = s.RemoveTeamMember(team.ID, member.ID, org)
+	assert.EqualError(t, err, "user "+member.ID.String()+" is not a member of the team")
+}
@@ -0,0 +1,102 @@
+package organizing_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/organizing"
+)
+
+func TestValidatePayloadOK(t *testing.T) {
+	t.Parallel()
+
+	r, _ := http.NewRequest("POST", "/", strings.NewReader(`{"name":"acme"}`))
+	var p organizing.Payload
+	err := binder.JSON.FromReq(r, &p)
+	assert.Nil(t, err)
+	assert.Equal(t, "acme", *p.Name)
+}
+
+func TestValidatePayloadError(t *testing.T) {
+	t.Parallel()
+
+	for _, body := range []string{`{}`, `{"name":"  "}`} {
+		r, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+		var p organizing.Payload
+		err := binder.JSON.FromReq(r, &p)
+		assert.EqualError(t, err, "name must not be blank")
+	}
+}
+
+func TestValidateMemberPayload(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"without role", `{"email":"test@example.com"}`, ""},
+		{"with role", `{"email":"test@example.com","role":"owner"}`, ""},
+		{"missing email", `{"role":"member"}`, "email must not be blank"},
+		{"invalid email", `{"email":"test"}`, "invalid email"},
+		{"invalid role", `{"email":"test@example.com","role":"admin"}`, "not allowed role admin. it could be one of [owner member]"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+			var p organizing.MemberPayload
+			err := binder.JSON.FromReq(r, &p)
+			if tc.err == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestValidateTeamPayload(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		body string
+		err  string
+	}{
+		{"valid", `{"name":"devs","role":"write"}`, ""},
+		{"missing name", `{"role":"write"}`, "name must not be blank"},
+		{"missing role", `{"name":"devs"}`, "role must not be blank"},
+		{"invalid role", `{"name":"devs","role":"owner"}`, "not allowed role owner. it could be one of [read write admin]"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+			var p organizing.TeamPayload
+			err := binder.JSON.FromReq(r, &p)
+			if tc.err == "" {
+				assert.Nil(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestValidateTeamMemberPayload(t *testing.T) {
+	t.Parallel()
+
+	r, _ := http.NewRequest("POST", "/", strings.NewReader(`{"email":"test@example.com"}`))
+	var p organizing.TeamMemberPayload
+	assert.Nil(t, binder.JSON.FromReq(r, &p))
+
+	r, _ = http.NewRequest("POST", "/", strings.NewReader(`{}`))
+	p = organizing.TeamMemberPayload{}
+	assert.EqualError(t, binder.JSON.FromReq(r, &p), "email must not be blank")
+}
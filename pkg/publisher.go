@@ -0,0 +1,18 @@
+package pkg
+
+import "github.com/ifreddyrondon/capture/pkg/domain"
+
+// Publisher sends events to the interested subscribers.
+type Publisher interface {
+	Publish(...domain.Event)
+}
+
+// Publishers fans out the events to several publishers.
+type Publishers []Publisher
+
+// Publish sends the events to every publisher in order.
+func (p Publishers) Publish(events ...domain.Event) {
+	for _, publisher := range p {
+		publisher.Publish(events...)
+	}
+}
@@ -0,0 +1,153 @@
+package quotas
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type quotaExceededErr string
+
+func (e quotaExceededErr) Error() string       { return string(e) }
+func (e quotaExceededErr) QuotaExceeded() bool { return true }
+
+// Store provides access to the usage storage.
+type Store interface {
+	// GetRepoUsage retrieve the usage of a repository, empty when there isn't one.
+	GetRepoUsage(repoID kallax.ULID) (*domain.Usage, error)
+	// GetUserUsage retrieve the sum of the usages of the repositories of an user.
+	GetUserUsage(userID kallax.ULID) (*domain.Usage, error)
+	// ReserveUsage adds the delta to the usage of its repository when check, called with
+	// the current usages of the repository and of its user, returns nil. Otherwise the
+	// error of check is returned. It must be atomic for the user.
+	ReserveUsage(delta domain.Usage, check func(repo, user domain.Usage) error) error
+	// ReleaseUsage subtracts the delta from the usage of its repository.
+	ReleaseUsage(delta domain.Usage) error
+}
+
+// Quotas represents the quotas of every repository and of every user.
+type Quotas struct {
+	Repository domain.Quota
+	User       domain.Quota
+}
+
+// Usage represents the current usage of a repository or an user with its quota.
+type Usage struct {
+	domain.Usage
+	Quota domain.Quota `json:"quota"`
+}
+
+// Service provides storage quotas operations.
+type Service interface {
+	// Reserve accounts new captures of a repository, or returns a quota exceeded error
+	// when they don't fit in the quotas of the repository or of its owner.
+	Reserve(*domain.Repository, ...domain.Capture) error
+	// Release frees the usage of removed captures, or of the reserved ones not stored.
+	Release(...domain.Capture) error
+	// Resize accounts the change of size of an updated capture of a repository, or returns
+	// a quota exceeded error when it grows beyond the quotas.
+	Resize(r *domain.Repository, old, updated domain.Capture) error
+	// RepoUsage retrieve the usage of a repository.
+	RepoUsage(*domain.Repository) (*Usage, error)
+	// UserUsage retrieve the usage of an user.
+	UserUsage(*domain.User) (*Usage, error)
+}
+
+type service struct {
+	s Store
+	q Quotas
+}
+
+// NewService creates a quotas service with the necessary dependencies.
+func NewService(s Store, q Quotas) Service {
+	return &service{s: s, q: q}
+}
+
+func (s *service) Reserve(r *domain.Repository, captures ...domain.Capture) error {
+	if len(captures) == 0 {
+		return nil
+	}
+	return s.reserve(domain.NewUsage(r, captures...))
+}
+
+func (s *service) reserve(delta domain.Usage) error {
+	err := s.s.ReserveUsage(delta, func(repo, user domain.Usage) error {
+		if err := check("repository", s.q.Repository, repo.Add(delta)); err != nil {
+			return err
+		}
+		return check("user", s.q.User, user.Add(delta))
+	})
+	if err != nil {
+		if _, ok := errors.Cause(err).(quotaExceededErr); ok {
+			return err
+		}
+		return errors.Wrap(err, "could not reserve usage")
+	}
+	return nil
+}
+
+func check(owner string, q domain.Quota, u domain.Usage) error {
+	if !q.AllowsCaptures(u) {
+		return errors.WithStack(quotaExceededErr(fmt.Sprintf("%s quota exceeded, it allows up to %d captures", owner, q.Captures)))
+	}
+	if !q.AllowsBytes(u) {
+		return errors.WithStack(quotaExceededErr(fmt.Sprintf("%s quota exceeded, it allows up to %d bytes", owner, q.Bytes)))
+	}
+	return nil
+}
+
+func (s *service) Release(captures ...domain.Capture) error {
+	deltas := make(map[kallax.ULID]*domain.Usage)
+	var order []kallax.ULID
+	for _, c := range captures {
+		delta, ok := deltas[c.RepositoryID]
+		if !ok {
+			delta = &domain.Usage{RepositoryID: c.RepositoryID}
+			deltas[c.RepositoryID] = delta
+			order = append(order, c.RepositoryID)
+		}
+		delta.Captures++
+		delta.Bytes += domain.CaptureSize(c)
+	}
+	for _, id := range order {
+		if err := s.s.ReleaseUsage(*deltas[id]); err != nil {
+			return errors.Wrap(err, "could not release usage")
+		}
+	}
+	return nil
+}
+
+func (s *service) Resize(r *domain.Repository, old, updated domain.Capture) error {
+	delta := domain.Usage{RepositoryID: r.ID, UserID: r.UserID}
+	delta.Bytes = domain.CaptureSize(updated) - domain.CaptureSize(old)
+	if delta.Bytes >= 0 {
+		if delta.Bytes == 0 {
+			return nil
+		}
+		return s.reserve(delta)
+	}
+	delta.Bytes = -delta.Bytes
+	if err := s.s.ReleaseUsage(delta); err != nil {
+		return errors.Wrap(err, "could not release usage")
+	}
+	return nil
+}
+
+func (s *service) RepoUsage(r *domain.Repository) (*Usage, error) {
+	u, err := s.s.GetRepoUsage(r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get repository usage")
+	}
+	return &Usage{Usage: *u, Quota: s.q.Repository}, nil
+}
+
+func (s *service) UserUsage(usr *domain.User) (*Usage, error) {
+	u, err := s.s.GetUserUsage(usr.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get user usage")
+	}
+	return &Usage{Usage: *u, Quota: s.q.User}, nil
+}
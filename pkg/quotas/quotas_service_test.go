@@ -0,0 +1,190 @@
+package quotas_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/quotas"
+)
+
+type mockStore struct {
+	repo     domain.Usage
+	user     domain.Usage
+	reserved []domain.Usage
+	released []domain.Usage
+	err      error
+}
+
+func (m *mockStore) GetRepoUsage(kallax.ULID) (*domain.Usage, error) { return &m.repo, m.err }
+func (m *mockStore) GetUserUsage(kallax.ULID) (*domain.Usage, error) { return &m.user, m.err }
+func (m *mockStore) ReserveUsage(delta domain.Usage, check func(repo, user domain.Usage) error) error {
+	if m.err != nil {
+		return m.err
+	}
+	if err := check(m.repo, m.user); err != nil {
+		return err
+	}
+	m.reserved = append(m.reserved, delta)
+	return nil
+}
+func (m *mockStore) ReleaseUsage(delta domain.Usage) error {
+	m.released = append(m.released, delta)
+	return m.err
+}
+
+type quotaExceeded interface {
+	QuotaExceeded() bool
+}
+
+var defaultRepo = &domain.Repository{ID: kallax.NewULID(), UserID: kallax.NewULID()}
+
+func TestServiceReserve(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{repo: domain.Usage{Captures: 8}, user: domain.Usage{Captures: 8}}
+	s := quotas.NewService(store, quotas.Quotas{Repository: domain.Quota{Captures: 10}})
+
+	assert.Nil(t, s.Reserve(defaultRepo, domain.Capture{}, domain.Capture{}))
+	assert.Len(t, store.reserved, 1)
+	assert.Equal(t, int64(2), store.reserved[0].Captures)
+	assert.Equal(t, defaultRepo.ID, store.reserved[0].RepositoryID)
+	assert.Equal(t, defaultRepo.UserID, store.reserved[0].UserID)
+
+	assert.Nil(t, s.Reserve(defaultRepo))
+	assert.Len(t, store.reserved, 1)
+}
+
+func TestServiceReserveQuotaExceeded(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name   string
+		store  *mockStore
+		quotas quotas.Quotas
+		err    string
+	}{
+		{
+			"repository captures",
+			&mockStore{repo: domain.Usage{Captures: 10}},
+			quotas.Quotas{Repository: domain.Quota{Captures: 10}},
+			"repository quota exceeded, it allows up to 10 captures",
+		},
+		{
+			"user captures",
+			&mockStore{repo: domain.Usage{Captures: 1}, user: domain.Usage{Captures: 5}},
+			quotas.Quotas{Repository: domain.Quota{Captures: 10}, User: domain.Quota{Captures: 5}},
+			"user quota exceeded, it allows up to 5 captures",
+		},
+		{
+			"user bytes",
+			&mockStore{user: domain.Usage{Bytes: 100}},
+			quotas.Quotas{User: domain.Quota{Bytes: 100}},
+			"user quota exceeded, it allows up to 100 bytes",
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := quotas.NewService(tc.store, tc.quotas).Reserve(defaultRepo, domain.Capture{})
+			assert.EqualError(t, err, tc.err)
+			e, ok := errors.Cause(err).(quotaExceeded)
+			assert.True(t, ok)
+			assert.True(t, e.QuotaExceeded())
+			assert.Len(t, tc.store.reserved, 0)
+		})
+	}
+}
+
+func TestServiceReserveFailsWhenStore(t *testing.T) {
+	t.Parallel()
+
+	s := quotas.NewService(&mockStore{err: errors.New("test")}, quotas.Quotas{})
+	err := s.Reserve(defaultRepo, domain.Capture{})
+	assert.EqualError(t, err, "could not reserve usage: test")
+	_, ok := errors.Cause(err).(quotaExceeded)
+	assert.False(t, ok)
+}
+
+func TestServiceRelease(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{}
+	s := quotas.NewService(store, quotas.Quotas{})
+	other := kallax.NewULID()
+	captures := []domain.Capture{
+		{RepositoryID: defaultRepo.ID},
+		{RepositoryID: other},
+		{RepositoryID: defaultRepo.ID},
+	}
+
+	assert.Nil(t, s.Release(captures...))
+	assert.Len(t, store.released, 2)
+	assert.Equal(t, defaultRepo.ID, store.released[0].RepositoryID)
+	assert.Equal(t, int64(2), store.released[0].Captures)
+	assert.Equal(t, 2*domain.CaptureSize(captures[0]), store.released[0].Bytes)
+	assert.Equal(t, other, store.released[1].RepositoryID)
+	assert.Equal(t, int64(1), store.released[1].Captures)
+
+	store.err = errors.New("test")
+	assert.EqualError(t, s.Release(captures...), "could not release usage: test")
+}
+
+func TestServiceUsage(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{repo: domain.Usage{Captures: 1, Bytes: 10}, user: domain.Usage{Captures: 3, Bytes: 30}}
+	q := quotas.Quotas{Repository: domain.Quota{Captures: 10}, User: domain.Quota{Bytes: 1000}}
+	s := quotas.NewService(store, q)
+
+	u, err := s.RepoUsage(defaultRepo)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1), u.Captures)
+	assert.Equal(t, q.Repository, u.Quota)
+
+	u, err = s.UserUsage(&domain.User{ID: defaultRepo.UserID})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(30), u.Bytes)
+	assert.Equal(t, q.User, u.Quota)
+
+	store.err = errors.New("test")
+	_, err = s.RepoUsage(defaultRepo)
+	assert.EqualError(t, err, "could not get repository usage: test")
+	_, err = s.UserUsage(&domain.User{})
+	assert.EqualError(t, err, "could not get user usage: test")
+}
+
+func TestServiceResize(t *testing.T) {
+	t.Parallel()
+
+	small := domain.Capture{Tags: []string{}}
+	large := domain.Capture{Tags: []string{"a long tag to grow the capture"}}
+	grow := domain.CaptureSize(large) - domain.CaptureSize(small)
+
+	store := &mockStore{}
+	s := quotas.NewService(store, quotas.Quotas{})
+	assert.Nil(t, s.Resize(defaultRepo, small, large))
+	assert.Equal(t, []domain.Usage{{RepositoryID: defaultRepo.ID, UserID: defaultRepo.UserID, Bytes: grow}}, store.reserved)
+
+	assert.Nil(t, s.Resize(defaultRepo, large, small))
+	assert.Equal(t, []domain.Usage{{RepositoryID: defaultRepo.ID, UserID: defaultRepo.UserID, Bytes: grow}}, store.released)
+
+	assert.Nil(t, s.Resize(defaultRepo, small, small))
+	assert.Len(t, store.reserved, 1)
+	assert.Len(t, store.released, 1)
+}
+
+func TestServiceResizeQuotaExceeded(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{repo: domain.Usage{Bytes: 10}}
+	s := quotas.NewService(store, quotas.Quotas{Repository: domain.Quota{Bytes: 10}})
+	err := s.Resize(defaultRepo, domain.Capture{}, domain.Capture{Tags: []string{"tag"}})
+	assert.EqualError(t, err, "repository quota exceeded, it allows up to 10 bytes")
+	_, ok := errors.Cause(err).(quotaExceeded)
+	assert.True(t, ok)
+	assert.Empty(t, store.reserved)
+}
@@ -15,6 +15,18 @@ type CaptureStore interface {
 	Save(*domain.Capture) error
 }
 
+// Publisher notifies the changes of the repositories captures.
+type Publisher interface {
+	// Publish sends the events to the interested subscribers.
+	Publish(...domain.Event)
+}
+
+// Quota releases the usage of the removed captures.
+type Quota interface {
+	// Release frees the usage of removed captures.
+	Release(...domain.Capture) error
+}
+
 // CaptureService provides removing capture operations.
 type CaptureService interface {
 	// Remove a repo capture from a repo.
@@ -23,11 +35,13 @@ type CaptureService interface {
 
 type captureService struct {
 	s CaptureStore
+	p Publisher
+	q Quota
 }
 
 // NewCaptureService creates a getting service with the necessary dependencies
-func NewCaptureService(s CaptureStore) CaptureService {
-	return &captureService{s: s}
+func NewCaptureService(s CaptureStore, p Publisher, q Quota) CaptureService {
+	return &captureService{s: s, p: p, q: q}
 }
 
 func (s *captureService) Remove(c *domain.Capture) error {
@@ -38,5 +52,9 @@ func (s *captureService) Remove(c *domain.Capture) error {
 		errStr := fmt.Sprintf("could not remove capture %v", c.ID)
 		return errors.Wrap(err, errStr)
 	}
+	if err := s.q.Release(*c); err != nil {
+		return errors.Wrapf(err, "could not release usage of capture %v", c.ID)
+	}
+	s.p.Publish(domain.NewCaptureEvent(domain.CaptureRemoved, *c))
 	return nil
 }
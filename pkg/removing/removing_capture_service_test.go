@@ -21,11 +21,27 @@ func (m *mockCaptureStore) Save(*domain.Capture) error {
 	return m.err
 }
 
+type mockPublisher struct {
+	published []domain.Event
+}
+
+func (m *mockPublisher) Publish(events ...domain.Event) { m.published = append(m.published, events...) }
+
+type mockQuota struct {
+	released []domain.Capture
+	err      error
+}
+
+func (m *mockQuota) Release(captures ...domain.Capture) error {
+	m.released = append(m.released, captures...)
+	return m.err
+}
+
 func TestServiceRemoveCaptureOK(t *testing.T) {
 	t.Parallel()
 
 	store := &mockCaptureStore{}
-	s := removing.NewCaptureService(store)
+	s := removing.NewCaptureService(store, &mockPublisher{}, &mockQuota{})
 	capt := &domain.Capture{ID: kallax.NewULID()}
 
 	timeBeforeDelete := time.Now()
@@ -39,10 +55,46 @@ func TestServiceRemoveCaptureFailsWhenSave(t *testing.T) {
 	t.Parallel()
 
 	store := &mockCaptureStore{err: errors.New("test")}
-	s := removing.NewCaptureService(store)
+	s := removing.NewCaptureService(store, &mockPublisher{}, &mockQuota{})
 	captID := kallax.NewULID()
 	capt := &domain.Capture{ID: captID}
 
 	err := s.Remove(capt)
 	assert.EqualError(t, err, fmt.Sprintf("could not remove capture %v: test", captID))
 }
+
+func TestServiceRemoveCapturePublishEvent(t *testing.T) {
+	t.Parallel()
+
+	p := &mockPublisher{}
+	s := removing.NewCaptureService(&mockCaptureStore{}, p, &mockQuota{})
+	capt := &domain.Capture{ID: kallax.NewULID()}
+
+	err := s.Remove(capt)
+	assert.Nil(t, err)
+	assert.Len(t, p.published, 1)
+	assert.Equal(t, domain.CaptureRemoved, p.published[0].Type)
+	assert.Equal(t, capt.ID, p.published[0].Capture.ID)
+}
+
+func TestServiceRemoveCaptureReleasesUsage(t *testing.T) {
+	t.Parallel()
+
+	q := &mockQuota{}
+	s := removing.NewCaptureService(&mockCaptureStore{}, &mockPublisher{}, q)
+	capt := &domain.Capture{ID: kallax.NewULID()}
+
+	assert.Nil(t, s.Remove(capt))
+	assert.Len(t, q.released, 1)
+	assert.Equal(t, capt.ID, q.released[0].ID)
+}
+
+func TestServiceRemoveCaptureFailsWhenRelease(t *testing.T) {
+	t.Parallel()
+
+	s := removing.NewCaptureService(&mockCaptureStore{}, &mockPublisher{}, &mockQuota{err: errors.New("test")})
+	captID := kallax.NewULID()
+
+	err := s.Remove(&domain.Capture{ID: captID})
+	assert.EqualError(t, err, fmt.Sprintf("could not release usage of capture %v: test", captID))
+}
@@ -0,0 +1,61 @@
+package removing
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// UserStore provides access to the user storage.
+type UserStore interface {
+	// RemoveUser soft deletes a user.
+	RemoveUser(*domain.User) error
+}
+
+// UserRepoStore provides access to the repositories of the users.
+type UserRepoStore interface {
+	// RemoveUserRepos soft deletes every repository of an user.
+	RemoveUserRepos(kallax.ULID, time.Time) error
+}
+
+// SessionStore provides access to the sessions of the users.
+type SessionStore interface {
+	// RevokeUserRefreshTokens revokes every active refresh token of an user.
+	RevokeUserRefreshTokens(kallax.ULID, time.Time) error
+}
+
+// UserService provides removing user operations.
+type UserService interface {
+	// Remove deletes the account of an user along with its repositories and sessions.
+	Remove(*domain.User) error
+}
+
+type userService struct {
+	s  UserStore
+	rs UserRepoStore
+	ss SessionStore
+}
+
+// NewUserService creates a removing user service with the necessary dependencies
+func NewUserService(s UserStore, rs UserRepoStore, ss SessionStore) UserService {
+	return &userService{s: s, rs: rs, ss: ss}
+}
+
+func (s *userService) Remove(u *domain.User) error {
+	t := time.Now()
+	if err := s.rs.RemoveUserRepos(u.ID, t); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not remove repos of user %v", u.ID))
+	}
+	u.DeletedAt = &t
+	if err := s.s.RemoveUser(u); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not remove user %v", u.ID))
+	}
+	if err := s.ss.RevokeUserRefreshTokens(u.ID, t); err != nil {
+		return errors.Wrap(err, "could not revoke refresh tokens")
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package removing_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/removing"
+)
+
+type mockUserStore struct {
+	removed      *domain.User
+	reposRemoved kallax.ULID
+	revoked      kallax.ULID
+	err          error
+	reposErr     error
+}
+
+func (m *mockUserStore) RemoveUser(u *domain.User) error {
+	m.removed = u
+	return m.err
+}
+func (m *mockUserStore) RemoveUserRepos(id kallax.ULID, at time.Time) error {
+	m.reposRemoved = id
+	return m.reposErr
+}
+func (m *mockUserStore) RevokeUserRefreshTokens(id kallax.ULID, at time.Time) error {
+	m.revoked = id
+	return nil
+}
+
+func TestServiceRemoveUserOK(t *testing.T) {
+	t.Parallel()
+
+	store := &mockUserStore{}
+	s := removing.NewUserService(store, store, store)
+	u := &domain.User{ID: kallax.NewULID()}
+
+	timeBeforeDelete := time.Now()
+	assert.Nil(t, s.Remove(u))
+	assert.NotNil(t, u.DeletedAt)
+	assert.True(t, u.DeletedAt.After(timeBeforeDelete))
+	assert.Equal(t, u, store.removed)
+	assert.Equal(t, u.ID, store.reposRemoved)
+	assert.Equal(t, u.ID, store.revoked)
+}
+
+func TestServiceRemoveUserFails(t *testing.T) {
+	t.Parallel()
+
+	u := &domain.User{ID: kallax.NewULID()}
+
+	store := &mockUserStore{reposErr: errors.New("test")}
+	err := removing.NewUserService(store, store, store).Remove(u)
+	assert.EqualError(t, err, fmt.Sprintf("could not remove repos of user %v: test", u.ID))
+	assert.Nil(t, store.removed)
+
+	store = &mockUserStore{err: errors.New("test")}
+	err = removing.NewUserService(store, store, store).Remove(u)
+	assert.EqualError(t, err, fmt.Sprintf("could not remove user %v: test", u.ID))
+}
@@ -0,0 +1,81 @@
+package retention
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const archiveTimeFormat = "20060102T150405.000Z"
+
+// Archiver keeps the expired captures out of the storage before they are removed.
+type Archiver interface {
+	// Create starts the archive of the captures of a repository expired at the time.
+	Create(repoID kallax.ULID, at time.Time) (Archive, error)
+}
+
+// Archive receives the expired captures of a repository.
+type Archive interface {
+	// Write appends the captures to the archive.
+	Write(...domain.Capture) error
+	// Location identifies the archive in the reports.
+	Location() string
+	// Close flushes the archive.
+	Close() error
+}
+
+type fileArchiver string
+
+// NewFileArchiver returns an Archiver writing a gzipped JSON lines file by repository and
+// run into dir.
+func NewFileArchiver(dir string) Archiver { return fileArchiver(dir) }
+
+func (dir fileArchiver) Create(repoID kallax.ULID, at time.Time) (Archive, error) {
+	path := filepath.Join(string(dir), repoID.String(), at.UTC().Format(archiveTimeFormat)+".jsonl.gz")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrap(err, "creating archive directory")
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating archive")
+	}
+	gz := gzip.NewWriter(f)
+	return &fileArchive{path: path, f: f, gz: gz, enc: json.NewEncoder(gz)}, nil
+}
+
+type fileArchive struct {
+	path string
+	f    *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+}
+
+func (a *fileArchive) Write(captures ...domain.Capture) error {
+	for _, c := range captures {
+		if err := a.enc.Encode(c); err != nil {
+			return errors.Wrapf(err, "archiving capture %v", c.ID)
+		}
+	}
+	return nil
+}
+
+func (a *fileArchive) Location() string { return a.path }
+
+func (a *fileArchive) Close() error {
+	if err := a.gz.Close(); err != nil {
+		a.f.Close()
+		return errors.Wrap(err, "closing archive")
+	}
+	if err := a.f.Sync(); err != nil {
+		a.f.Close()
+		return errors.Wrap(err, "syncing archive")
+	}
+	return a.f.Close()
+}
@@ -0,0 +1,51 @@
+package retention_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/retention"
+)
+
+func TestFileArchiver(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "archive")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	repoID := kallax.NewULID()
+	archive, err := retention.NewFileArchiver(dir).Create(repoID, time.Now())
+	require.Nil(t, err)
+	first := domain.Capture{ID: kallax.NewULID(), Payload: domain.Payload{{Name: "power", Value: 1.5}}, RepositoryID: repoID}
+	second := domain.Capture{ID: kallax.NewULID(), RepositoryID: repoID}
+	require.Nil(t, archive.Write(first))
+	require.Nil(t, archive.Write(second))
+	require.Nil(t, archive.Close())
+	assert.Equal(t, filepath.Join(dir, repoID.String()), filepath.Dir(archive.Location()))
+
+	f, err := os.Open(archive.Location())
+	require.Nil(t, err)
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	require.Nil(t, err)
+	var ids []kallax.ULID
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var c domain.Capture
+		require.Nil(t, json.Unmarshal(scanner.Bytes(), &c))
+		ids = append(ids, c.ID)
+	}
+	assert.Equal(t, []kallax.ULID{first.ID, second.ID}, ids)
+}
@@ -0,0 +1,42 @@
+package retention
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/validate"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const errDaysRequired = "days must be a positive number of days"
+
+// Payload represents the data to set the retention policy of a repository.
+type Payload struct {
+	Days *int `json:"days"`
+	// Action could be drop or archive, drop when missing.
+	Action *string `json:"action"`
+}
+
+func (p *Payload) Validate() error {
+	e := validate.NewErrors()
+	if p.Days == nil || *p.Days <= 0 {
+		e.Add("days", errDaysRequired)
+	}
+	if p.Action != nil && !allowedAction(*p.Action) {
+		e.Add("action", fmt.Sprintf("not allowed action %v. it could be one of %v", *p.Action, domain.RetentionActions))
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+func allowedAction(action string) bool {
+	for _, a := range domain.RetentionActions {
+		if string(a) == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,47 @@
+package retention_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/retention"
+)
+
+func s2P(v string) *string {
+	return &v
+}
+
+func i2P(v int) *int {
+	return &v
+}
+
+func TestValidatePayloadOK(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, (&retention.Payload{Days: i2P(30)}).Validate())
+	assert.Nil(t, (&retention.Payload{Days: i2P(30), Action: s2P("archive")}).Validate())
+}
+
+func TestValidatePayloadFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		payload retention.Payload
+		errs    []string
+	}{
+		{"missing days", retention.Payload{}, []string{"days must be a positive number of days"}},
+		{"zero days", retention.Payload{Days: i2P(0)}, []string{"days must be a positive number of days"}},
+		{"unknown action", retention.Payload{Days: i2P(1), Action: s2P("move")}, []string{"not allowed action move"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.payload.Validate()
+			for _, e := range tc.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package retention
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// batchSize is the number of expired captures removed at once.
+const batchSize = 1000
+
+// Store provides access to the retention policies and reports storage.
+type Store interface {
+	// GetRetentionPolicy retrieve the retention policy of a repository.
+	GetRetentionPolicy(repoID kallax.ULID) (*domain.RetentionPolicy, error)
+	// SaveRetentionPolicy creates or replaces the retention policy of its repository.
+	SaveRetentionPolicy(*domain.RetentionPolicy) error
+	// RemoveRetentionPolicy deletes the retention policy of a repository.
+	RemoveRetentionPolicy(repoID kallax.ULID) error
+	// ListRetentionPolicies retrieve the retention policies of every repository.
+	ListRetentionPolicies() ([]domain.RetentionPolicy, error)
+	// CreateRetentionReport stores the report of a run.
+	CreateRetentionReport(*domain.RetentionReport) error
+	// ListRetentionReports retrieve the reports of a repository with domain.Listing attrs.
+	ListRetentionReports(*domain.Listing) ([]domain.RetentionReport, int64, error)
+}
+
+// CaptureStore provides access to the expired captures.
+type CaptureStore interface {
+	// ExpiredCaptures retrieve up to limit captures of a repository taken before the time.
+	ExpiredCaptures(repoID kallax.ULID, before time.Time, limit int) ([]domain.Capture, error)
+	// DeleteCaptures removes permanently captures of a repository taken before the time,
+	// returning how many were deleted.
+	DeleteCaptures(repoID kallax.ULID, before time.Time, ids ...kallax.ULID) (int64, error)
+	// PurgeCaptures removes permanently the removed captures of a repository taken before
+	// the time, returning how many were purged.
+	PurgeCaptures(repoID kallax.ULID, before time.Time) (int64, error)
+}
+
+// Quota frees the usage of the expired captures.
+type Quota interface {
+	Release(...domain.Capture) error
+}
+
+// Service provides retention operations.
+type Service interface {
+	// GetPolicy retrieve the retention policy of a repository.
+	GetPolicy(*domain.Repository) (*domain.RetentionPolicy, error)
+	// SetPolicy creates or replaces the retention policy of a repository.
+	SetPolicy(*domain.Repository, Payload) (*domain.RetentionPolicy, error)
+	// RemovePolicy removes the retention policy of a repository, keeping its captures.
+	RemovePolicy(*domain.Repository) error
+	// ListReports list what the retention runs removed from a repository.
+	ListReports(*domain.Repository, *listing.Listing) (*ListReportResponse, error)
+	// Run applies every retention policy at now, returning a report by repository.
+	Run(now time.Time) ([]domain.RetentionReport, error)
+}
+
+type service struct {
+	s  Store
+	cs CaptureStore
+	a  Archiver
+	q  Quota
+}
+
+// NewService creates a retention service with the necessary dependencies.
+func NewService(s Store, cs CaptureStore, a Archiver, q Quota) Service {
+	return &service{s: s, cs: cs, a: a, q: q}
+}
+
+func (s *service) GetPolicy(r *domain.Repository) (*domain.RetentionPolicy, error) {
+	p, err := s.s.GetRetentionPolicy(r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get retention policy")
+	}
+	return p, nil
+}
+
+func (s *service) SetPolicy(r *domain.Repository, payload Payload) (*domain.RetentionPolicy, error) {
+	now := time.Now()
+	p := &domain.RetentionPolicy{
+		RepositoryID: r.ID,
+		Days:         *payload.Days,
+		Action:       domain.DropCaptures,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if payload.Action != nil {
+		p.Action = domain.RetentionAction(*payload.Action)
+	}
+	if current, err := s.s.GetRetentionPolicy(r.ID); err == nil {
+		p.CreatedAt = current.CreatedAt
+	}
+	if err := s.s.SaveRetentionPolicy(p); err != nil {
+		return nil, errors.Wrap(err, "could not save retention policy")
+	}
+	return p, nil
+}
+
+func (s *service) RemovePolicy(r *domain.Repository) error {
+	if err := s.s.RemoveRetentionPolicy(r.ID); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not remove retention policy of repo %v", r.ID))
+	}
+	return nil
+}
+
+func (s *service) ListReports(r *domain.Repository, l *listing.Listing) (*ListReportResponse, error) {
+	lreports := domain.NewListing(*l)
+	lreports.Owner = &r.ID
+	reports, total, err := s.s.ListRetentionReports(lreports)
+	if err != nil {
+		return nil, errors.Wrap(err, "err getting retention reports")
+	}
+	l.Paging.Total = total
+	return newListReportResponse(reports, l), nil
+}
+
+func (s *service) Run(now time.Time) ([]domain.RetentionReport, error) {
+	policies, err := s.s.ListRetentionPolicies()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list retention policies")
+	}
+	reports := make([]domain.RetentionReport, 0, len(policies))
+	for _, p := range policies {
+		report := s.apply(p, now)
+		if err := s.s.CreateRetentionReport(&report); err != nil {
+			return reports, errors.Wrap(err, "could not save retention report")
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// apply removes the captures expired by the policy. A failure stops the policy and is
+// kept in the report along with what was removed until then.
+func (s *service) apply(p domain.RetentionPolicy, now time.Time) domain.RetentionReport {
+	report := domain.RetentionReport{
+		ID:           kallax.NewULID(),
+		Action:       p.Action,
+		Before:       p.Cutoff(now),
+		StartedAt:    time.Now(),
+		RepositoryID: p.RepositoryID,
+	}
+	if err := s.remove(&report); err != nil {
+		report.Error = err.Error()
+	}
+	report.FinishedAt = time.Now()
+	report.CreatedAt = report.FinishedAt
+	return report
+}
+
+func (s *service) remove(report *domain.RetentionReport) error {
+	var archive Archive
+	if report.Action == domain.ArchiveCaptures {
+		var err error
+		archive, err = s.a.Create(report.RepositoryID, report.StartedAt)
+		if err != nil {
+			return errors.Wrap(err, "could not create archive")
+		}
+		report.Archive = archive.Location()
+	}
+
+	err := s.removeExpired(report, archive)
+	if archive != nil {
+		if cerr := archive.Close(); cerr != nil && err == nil {
+			err = errors.Wrap(cerr, "could not close archive")
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	// the removed captures were already released.
+	if _, err := s.cs.PurgeCaptures(report.RepositoryID, report.Before); err != nil {
+		return errors.Wrap(err, "could not purge removed captures")
+	}
+	return nil
+}
+
+func (s *service) removeExpired(report *domain.RetentionReport, archive Archive) error {
+	for {
+		captures, err := s.cs.ExpiredCaptures(report.RepositoryID, report.Before, batchSize)
+		if err != nil {
+			return errors.Wrap(err, "could not get expired captures")
+		}
+		if len(captures) == 0 {
+			return nil
+		}
+		if archive != nil {
+			if err := archive.Write(captures...); err != nil {
+				return errors.Wrap(err, "could not archive captures")
+			}
+		}
+		ids := make([]kallax.ULID, len(captures))
+		for i, c := range captures {
+			ids[i] = c.ID
+		}
+		deleted, err := s.cs.DeleteCaptures(report.RepositoryID, report.Before, ids...)
+		if err != nil {
+			return errors.Wrap(err, "could not delete expired captures")
+		}
+		// the same captures would be returned again and again.
+		if deleted == 0 {
+			return errors.New("could not delete expired captures, none was deleted")
+		}
+		if err := s.q.Release(captures...); err != nil {
+			return errors.Wrap(err, "could not release expired captures usage")
+		}
+		report.Captures += deleted
+	}
+}
+
+type ListReportResponse struct {
+	Results []domain.RetentionReport `json:"results"`
+	Listing *listing.Listing         `json:"listing"`
+}
+
+func newListReportResponse(reports []domain.RetentionReport, l *listing.Listing) *ListReportResponse {
+	if reports == nil {
+		reports = make([]domain.RetentionReport, 0)
+	}
+	return &ListReportResponse{Results: reports, Listing: l}
+}
@@ -0,0 +1,213 @@
+package retention_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/ifreddyrondon/bastion/middleware/listing/paging"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/retention"
+)
+
+type mockStore struct {
+	policy   *domain.RetentionPolicy
+	policies []domain.RetentionPolicy
+	reports  []domain.RetentionReport
+	listing  *domain.Listing
+	err      error
+}
+
+func (m *mockStore) GetRetentionPolicy(kallax.ULID) (*domain.RetentionPolicy, error) {
+	if m.policy == nil {
+		return nil, errors.New("not found")
+	}
+	return m.policy, m.err
+}
+func (m *mockStore) SaveRetentionPolicy(p *domain.RetentionPolicy) error {
+	m.policy = p
+	return m.err
+}
+func (m *mockStore) RemoveRetentionPolicy(kallax.ULID) error { return m.err }
+func (m *mockStore) ListRetentionPolicies() ([]domain.RetentionPolicy, error) {
+	return m.policies, m.err
+}
+func (m *mockStore) CreateRetentionReport(r *domain.RetentionReport) error {
+	m.reports = append(m.reports, *r)
+	return m.err
+}
+func (m *mockStore) ListRetentionReports(l *domain.Listing) ([]domain.RetentionReport, int64, error) {
+	m.listing = l
+	return m.reports, int64(len(m.reports)), m.err
+}
+
+type mockCaptureStore struct {
+	captures []domain.Capture
+	purged   int64
+	// stuck keeps the captures when they are deleted.
+	stuck bool
+	err   error
+}
+
+func (m *mockCaptureStore) ExpiredCaptures(repoID kallax.ULID, before time.Time, limit int) ([]domain.Capture, error) {
+	var expired []domain.Capture
+	for _, c := range m.captures {
+		if c.RepositoryID == repoID && c.Timestamp.Before(before) && len(expired) < limit {
+			expired = append(expired, c)
+		}
+	}
+	return expired, m.err
+}
+func (m *mockCaptureStore) DeleteCaptures(repoID kallax.ULID, before time.Time, ids ...kallax.ULID) (int64, error) {
+	if m.stuck {
+		return 0, m.err
+	}
+	deleted := make(map[kallax.ULID]bool)
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	var kept []domain.Capture
+	for _, c := range m.captures {
+		if !deleted[c.ID] {
+			kept = append(kept, c)
+		}
+	}
+	n := int64(len(m.captures) - len(kept))
+	m.captures = kept
+	return n, m.err
+}
+func (m *mockCaptureStore) PurgeCaptures(kallax.ULID, time.Time) (int64, error) {
+	return m.purged, m.err
+}
+
+type mockArchive struct{ captures []domain.Capture }
+
+func (m *mockArchive) Create(kallax.ULID, time.Time) (retention.Archive, error) { return m, nil }
+func (m *mockArchive) Write(captures ...domain.Capture) error {
+	m.captures = append(m.captures, captures...)
+	return nil
+}
+func (m *mockArchive) Location() string { return "archive" }
+func (m *mockArchive) Close() error     { return nil }
+
+type mockQuota struct{ released []domain.Capture }
+
+func (m *mockQuota) Release(captures ...domain.Capture) error {
+	m.released = append(m.released, captures...)
+	return nil
+}
+
+func TestServiceSetPolicy(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{}
+	s := retention.NewService(store, &mockCaptureStore{}, &mockArchive{}, &mockQuota{})
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	p, err := s.SetPolicy(repo, retention.Payload{Days: i2P(30)})
+	require.Nil(t, err)
+	assert.Equal(t, repo.ID, p.RepositoryID)
+	assert.Equal(t, 30, p.Days)
+	assert.Equal(t, domain.DropCaptures, p.Action)
+
+	created := p.CreatedAt
+	p, err = s.SetPolicy(repo, retention.Payload{Days: i2P(7), Action: s2P("archive")})
+	require.Nil(t, err)
+	assert.Equal(t, 7, p.Days)
+	assert.Equal(t, domain.ArchiveCaptures, p.Action)
+	assert.Equal(t, created, p.CreatedAt)
+}
+
+func TestServiceSetPolicyErr(t *testing.T) {
+	t.Parallel()
+
+	s := retention.NewService(&mockStore{err: errors.New("test")}, &mockCaptureStore{}, &mockArchive{}, &mockQuota{})
+	_, err := s.SetPolicy(&domain.Repository{ID: kallax.NewULID()}, retention.Payload{Days: i2P(30)})
+	assert.EqualError(t, err, "could not save retention policy: test")
+}
+
+func TestServiceRun(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	dropped, archived := kallax.NewULID(), kallax.NewULID()
+	old := now.AddDate(0, 0, -40)
+	captures := []domain.Capture{
+		{ID: kallax.NewULID(), Timestamp: old, RepositoryID: dropped},
+		{ID: kallax.NewULID(), Timestamp: now, RepositoryID: dropped},
+		{ID: kallax.NewULID(), Timestamp: old, RepositoryID: archived},
+		{ID: kallax.NewULID(), Timestamp: now.AddDate(0, 0, -20), RepositoryID: archived},
+	}
+	store := &mockStore{policies: []domain.RetentionPolicy{
+		{RepositoryID: dropped, Days: 30, Action: domain.DropCaptures},
+		{RepositoryID: archived, Days: 10, Action: domain.ArchiveCaptures},
+	}}
+	captureStore := &mockCaptureStore{captures: captures}
+	archive, quota := &mockArchive{}, &mockQuota{}
+	s := retention.NewService(store, captureStore, archive, quota)
+
+	reports, err := s.Run(now)
+	require.Nil(t, err)
+	require.Len(t, reports, 2)
+	assert.Equal(t, dropped, reports[0].RepositoryID)
+	assert.Equal(t, int64(1), reports[0].Captures)
+	assert.Empty(t, reports[0].Archive)
+	assert.Equal(t, now.AddDate(0, 0, -30), reports[0].Before)
+	assert.Equal(t, archived, reports[1].RepositoryID)
+	assert.Equal(t, int64(2), reports[1].Captures)
+	assert.Equal(t, "archive", reports[1].Archive)
+	assert.Equal(t, reports, store.reports)
+
+	assert.Equal(t, []domain.Capture{captures[1]}, captureStore.captures)
+	assert.Equal(t, []domain.Capture{captures[2], captures[3]}, archive.captures)
+	assert.Len(t, quota.released, 3)
+}
+
+func TestServiceRunReportsErr(t *testing.T) {
+	t.Parallel()
+
+	repoID := kallax.NewULID()
+	store := &mockStore{policies: []domain.RetentionPolicy{{RepositoryID: repoID, Days: 1, Action: domain.DropCaptures}}}
+	s := retention.NewService(store, &mockCaptureStore{err: errors.New("test")}, &mockArchive{}, &mockQuota{})
+
+	reports, err := s.Run(time.Now())
+	require.Nil(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "could not get expired captures: test", reports[0].Error)
+	assert.Equal(t, int64(0), reports[0].Captures)
+}
+
+func TestServiceRunStopsWhenNothingIsDeleted(t *testing.T) {
+	t.Parallel()
+
+	repoID := kallax.NewULID()
+	store := &mockStore{policies: []domain.RetentionPolicy{{RepositoryID: repoID, Days: 1, Action: domain.DropCaptures}}}
+	captures := []domain.Capture{{ID: kallax.NewULID(), Timestamp: time.Now().AddDate(0, 0, -2), RepositoryID: repoID}}
+	quota := &mockQuota{}
+	s := retention.NewService(store, &mockCaptureStore{captures: captures, stuck: true}, &mockArchive{}, quota)
+
+	reports, err := s.Run(time.Now())
+	require.Nil(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "could not delete expired captures, none was deleted", reports[0].Error)
+	assert.Equal(t, int64(0), reports[0].Captures)
+	assert.Empty(t, quota.released)
+}
+
+func TestServiceListReports(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{reports: []domain.RetentionReport{{ID: kallax.NewULID()}}}
+	s := retention.NewService(store, &mockCaptureStore{}, &mockArchive{}, &mockQuota{})
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	l := &listing.Listing{Paging: paging.Paging{Limit: 10}}
+	res, err := s.ListReports(repo, l)
+	assert.Nil(t, err)
+	assert.Len(t, res.Results, 1)
+	assert.Equal(t, int64(1), res.Listing.Paging.Total)
+	assert.Equal(t, &repo.ID, store.listing.Owner)
+}
@@ -0,0 +1,165 @@
+package rollups
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// Aggregate returns the rollups of the captures by bucket of the rule resolution, metric
+// and tag set. The captures could be rollups of a lower resolution, they are weighted by
+// their samples. Metrics without a numeric value are left out.
+func Aggregate(rule domain.RollupRule, captures []domain.Capture, now time.Time) []domain.Capture {
+	buckets := make(map[string]*bucket)
+	var order []string
+	for _, c := range captures {
+		tags := tagSet(c.Tags)
+		start := c.Timestamp.Truncate(rule.Window())
+		key := start.UTC().Format(time.RFC3339Nano) + "\x00" + strings.Join(tags, "\x00")
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{timestamp: start, tags: tags, metrics: make(map[string]*accumulator)}
+			buckets[key] = b
+			order = append(order, key)
+		}
+		b.add(rule.Aggregate, c)
+	}
+
+	rollups := make([]domain.Capture, len(order))
+	for i, key := range order {
+		rollups[i] = buckets[key].capture(rule, now)
+	}
+	return rollups
+}
+
+func tagSet(tags []string) []string {
+	set := make([]string, 0, len(tags))
+	seen := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		if !seen[t] {
+			seen[t] = true
+			set = append(set, t)
+		}
+	}
+	sort.Strings(set)
+	return set
+}
+
+type accumulator struct {
+	value, weight float64
+	set           bool
+}
+
+func (a *accumulator) add(aggregate domain.Aggregate, v, w float64) {
+	switch aggregate {
+	case domain.MinAggregate:
+		if !a.set || v < a.value {
+			a.value = v
+		}
+	case domain.MaxAggregate:
+		if !a.set || v > a.value {
+			a.value = v
+		}
+	case domain.SumAggregate:
+		a.value += v
+	case domain.CountAggregate:
+		a.value += w
+	default:
+		a.value += v * w
+		a.weight += w
+	}
+	a.set = true
+}
+
+func (a *accumulator) result(aggregate domain.Aggregate) float64 {
+	if aggregate == domain.AvgAggregate && a.weight > 0 {
+		return a.value / a.weight
+	}
+	return a.value
+}
+
+type bucket struct {
+	timestamp time.Time
+	tags      []string
+	samples   int64
+	metrics   map[string]*accumulator
+	names     []string
+	// lat, lng and elevation are the weighted sums of the locations.
+	lat, lng, located          float64
+	elevation, elevationWeight float64
+}
+
+func (b *bucket) add(aggregate domain.Aggregate, c domain.Capture) {
+	w := float64(c.Samples())
+	b.samples += c.Samples()
+	for _, m := range c.Payload {
+		v, ok := number(m.Value)
+		if !ok {
+			continue
+		}
+		acc, ok := b.metrics[m.Name]
+		if !ok {
+			acc = &accumulator{}
+			b.metrics[m.Name] = acc
+			b.names = append(b.names, m.Name)
+		}
+		acc.add(aggregate, v, w)
+	}
+	if c.Location != nil && c.Location.LAT != nil && c.Location.LNG != nil {
+		b.lat += *c.Location.LAT * w
+		b.lng += *c.Location.LNG * w
+		b.located += w
+		if c.Location.Elevation != nil {
+			b.elevation += *c.Location.Elevation * w
+			b.elevationWeight += w
+		}
+	}
+}
+
+func (b *bucket) capture(rule domain.RollupRule, now time.Time) domain.Capture {
+	payload := make(domain.Payload, len(b.names))
+	for i, name := range b.names {
+		payload[i] = domain.Metric{Name: name, Value: b.metrics[name].result(rule.Aggregate)}
+	}
+	c := domain.Capture{
+		ID:           kallax.NewULID(),
+		Payload:      payload,
+		Tags:         b.tags,
+		Timestamp:    b.timestamp,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		RepositoryID: rule.RepositoryID,
+		Rollup:       &domain.Rollup{Resolution: rule.Resolution, Aggregate: rule.Aggregate, Samples: b.samples},
+	}
+	if b.located > 0 {
+		lat, lng := b.lat/b.located, b.lng/b.located
+		c.Location = &domain.Point{LAT: &lat, LNG: &lng}
+		if b.elevationWeight > 0 {
+			elevation := b.elevation / b.elevationWeight
+			c.Location.Elevation = &elevation
+		}
+	}
+	return c
+}
+
+func number(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,85 @@
+package rollups_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/rollups"
+)
+
+func f2P(v float64) *float64 {
+	return &v
+}
+
+func newCapture(timestamp time.Time, power float64, tags ...string) domain.Capture {
+	return domain.Capture{
+		ID:        kallax.NewULID(),
+		Payload:   domain.Payload{{Name: "power", Value: power}, {Name: "label", Value: "a"}},
+		Location:  &domain.Point{LAT: f2P(power), LNG: f2P(-power)},
+		Tags:      tags,
+		Timestamp: timestamp,
+	}
+}
+
+func TestAggregate(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	captures := []domain.Capture{
+		newCapture(start, 1, "b", "a"),
+		newCapture(start.Add(10*time.Second), 3, "a", "b"),
+		newCapture(start.Add(20*time.Second), 10),
+		newCapture(start.Add(time.Minute), 5, "a", "b"),
+	}
+	rule := domain.RollupRule{RepositoryID: kallax.NewULID(), Resolution: 60, Aggregate: domain.AvgAggregate}
+	now := time.Now()
+
+	result := rollups.Aggregate(rule, captures, now)
+	require.Len(t, result, 3)
+	assert.Equal(t, start, result[0].Timestamp)
+	assert.Equal(t, []string{"a", "b"}, result[0].Tags)
+	assert.Equal(t, domain.Payload{{Name: "power", Value: 2.0}}, result[0].Payload)
+	assert.Equal(t, 2.0, *result[0].Location.LAT)
+	assert.Equal(t, &domain.Rollup{Resolution: 60, Aggregate: domain.AvgAggregate, Samples: 2}, result[0].Rollup)
+	assert.Equal(t, rule.RepositoryID, result[0].RepositoryID)
+	assert.Equal(t, now, result[0].CreatedAt)
+	assert.Equal(t, []string{}, result[1].Tags)
+	assert.Equal(t, domain.Payload{{Name: "power", Value: 10.0}}, result[1].Payload)
+	assert.Equal(t, start.Add(time.Minute), result[2].Timestamp)
+}
+
+func TestAggregateRollups(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	minute := newCapture(start, 2)
+	minute.Rollup = &domain.Rollup{Resolution: 60, Aggregate: domain.AvgAggregate, Samples: 3}
+	captures := []domain.Capture{minute, newCapture(start.Add(time.Minute), 6)}
+
+	tt := []struct {
+		aggregate domain.Aggregate
+		expected  float64
+	}{
+		{domain.AvgAggregate, 3.0},
+		{domain.MinAggregate, 2.0},
+		{domain.MaxAggregate, 6.0},
+		{domain.SumAggregate, 8.0},
+		{domain.CountAggregate, 4.0},
+	}
+
+	for _, tc := range tt {
+		t.Run(string(tc.aggregate), func(t *testing.T) {
+			rule := domain.RollupRule{Resolution: 3600, Aggregate: tc.aggregate}
+			result := rollups.Aggregate(rule, captures, time.Now())
+			require.Len(t, result, 1)
+			assert.Equal(t, domain.Payload{{Name: "power", Value: tc.expected}}, result[0].Payload)
+			assert.Equal(t, int64(4), result[0].Rollup.Samples)
+			assert.Equal(t, int64(3600), result[0].Resolution())
+		})
+	}
+}
@@ -0,0 +1,49 @@
+package rollups
+
+import (
+	"fmt"
+
+	"github.com/gobuffalo/validate"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	errAfterDaysRequired  = "afterDays must be a positive number of days"
+	errResolutionRequired = "resolution must be a positive number of seconds"
+)
+
+// Payload represents the data to create a rollup rule.
+type Payload struct {
+	AfterDays  *int   `json:"afterDays"`
+	Resolution *int64 `json:"resolution"`
+	// Aggregate could be avg, min, max, sum or count, avg when missing.
+	Aggregate *string `json:"aggregate"`
+}
+
+func (p *Payload) Validate() error {
+	e := validate.NewErrors()
+	if p.AfterDays == nil || *p.AfterDays <= 0 {
+		e.Add("afterDays", errAfterDaysRequired)
+	}
+	if p.Resolution == nil || *p.Resolution <= 0 {
+		e.Add("resolution", errResolutionRequired)
+	}
+	if p.Aggregate != nil && !allowedAggregate(*p.Aggregate) {
+		e.Add("aggregate", fmt.Sprintf("not allowed aggregate %v. it could be one of %v", *p.Aggregate, domain.Aggregates))
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+func allowedAggregate(aggregate string) bool {
+	for _, a := range domain.Aggregates {
+		if string(a) == aggregate {
+			return true
+		}
+	}
+	return false
+}
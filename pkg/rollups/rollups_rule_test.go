@@ -0,0 +1,51 @@
+package rollups_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/rollups"
+)
+
+func s2P(v string) *string {
+	return &v
+}
+
+func i2P(v int) *int {
+	return &v
+}
+
+func i64P(v int64) *int64 {
+	return &v
+}
+
+func TestValidatePayloadOK(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, (&rollups.Payload{AfterDays: i2P(30), Resolution: i64P(60)}).Validate())
+	assert.Nil(t, (&rollups.Payload{AfterDays: i2P(30), Resolution: i64P(60), Aggregate: s2P("max")}).Validate())
+}
+
+func TestValidatePayloadFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		payload rollups.Payload
+		errs    []string
+	}{
+		{"missing fields", rollups.Payload{}, []string{"afterDays must be a positive number of days", "resolution must be a positive number of seconds"}},
+		{"negative resolution", rollups.Payload{AfterDays: i2P(1), Resolution: i64P(-1)}, []string{"resolution must be a positive number of seconds"}},
+		{"unknown aggregate", rollups.Payload{AfterDays: i2P(1), Resolution: i64P(60), Aggregate: s2P("median")}, []string{"not allowed aggregate median"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.payload.Validate()
+			for _, e := range tc.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
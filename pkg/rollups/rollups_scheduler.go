@@ -0,0 +1,62 @@
+package rollups
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Scheduler runs the rollup rules in background.
+type Scheduler struct {
+	s    Service
+	mu   sync.Mutex
+	stop chan struct{}
+}
+
+// NewScheduler creates a scheduler of the rollups service runs.
+func NewScheduler(s Service) *Scheduler {
+	return &Scheduler{s: s}
+}
+
+// Start runs the rollup rules every interval until Stop, logging the reports.
+func (sc *Scheduler) Start(interval time.Duration) {
+	sc.mu.Lock()
+	sc.stop = make(chan struct{})
+	stop := sc.stop
+	sc.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				reports, err := sc.s.Run(now)
+				for _, r := range reports {
+					if r.Captures > 0 || r.Error != "" {
+						fmt.Fprintf(os.Stderr, "rollup rule %v of repo %v replaced %d captures by %d rollups %v\n",
+							r.RuleID, r.RepositoryID, r.Captures, r.Rollups, r.Error)
+					}
+				}
+				if err != nil {
+					fmt.Fprintln(os.Stderr, errors.Wrap(err, "could not run rollup rules"))
+				}
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduled runs.
+func (sc *Scheduler) Stop() {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	if sc.stop != nil {
+		close(sc.stop)
+		sc.stop = nil
+	}
+}
@@ -0,0 +1,208 @@
+package rollups
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// batchSize is the number of captures rolled up at once.
+const batchSize = 5000
+
+// Store provides access to the rollup rules storage.
+type Store interface {
+	// CreateRollupRule stores a new rollup rule.
+	CreateRollupRule(*domain.RollupRule) error
+	// ListRollupRules retrieve all the rollup rules of a repository.
+	ListRollupRules(repoID kallax.ULID) ([]domain.RollupRule, error)
+	// GetRollupRule retrieve a rollup rule of a repository.
+	GetRollupRule(ruleID, repoID kallax.ULID) (*domain.RollupRule, error)
+	// SaveRollupRule the rollup rule state into the storage.
+	SaveRollupRule(*domain.RollupRule) error
+	// AllRollupRules retrieve the rollup rules of every repository.
+	AllRollupRules() ([]domain.RollupRule, error)
+}
+
+// CaptureStore provides access to the captures to roll up.
+type CaptureStore interface {
+	// RollupCandidates retrieve up to limit captures of a repository taken before the time
+	// with a resolution lower than resolution seconds, ordered by timestamp.
+	RollupCandidates(repoID kallax.ULID, before time.Time, resolution int64, limit int) ([]domain.Capture, error)
+	// ReplaceCaptures stores the rollups and removes permanently the captures they
+	// aggregate, all or nothing. It fails when any of the captures was already removed.
+	ReplaceCaptures(captures []domain.Capture, rollups []domain.Capture) error
+}
+
+// RepoStore provides access to the repositories of the rules.
+type RepoStore interface {
+	// Get retrieve a repository from storage.
+	Get(kallax.ULID) (*domain.Repository, error)
+}
+
+// Quota moves the usage of the aggregated captures to their rollups.
+type Quota interface {
+	Reserve(*domain.Repository, ...domain.Capture) error
+	Release(...domain.Capture) error
+}
+
+// Report represents what a run of a rollup rule aggregated.
+type Report struct {
+	RuleID       kallax.ULID `json:"ruleId"`
+	RepositoryID kallax.ULID `json:"repoId"`
+	// Captures is the number of captures replaced by Rollups captures.
+	Captures int64  `json:"captures"`
+	Rollups  int64  `json:"rollups"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Service provides rollup operations.
+type Service interface {
+	// CreateRule registers a new rollup rule in a repository.
+	CreateRule(*domain.Repository, Payload) (*domain.RollupRule, error)
+	// ListRules list the repo rollup rules.
+	ListRules(*domain.Repository) ([]domain.RollupRule, error)
+	// GetRule retrieve a repo rollup rule.
+	GetRule(kallax.ULID, *domain.Repository) (*domain.RollupRule, error)
+	// RemoveRule removes a rollup rule from a repo, keeping the rollups already written.
+	RemoveRule(*domain.RollupRule) error
+	// Run applies every rollup rule at now, returning a report by rule.
+	Run(now time.Time) ([]Report, error)
+}
+
+type service struct {
+	s     Store
+	cs    CaptureStore
+	repos RepoStore
+	q     Quota
+}
+
+// NewService creates a rollups service with the necessary dependencies.
+func NewService(s Store, cs CaptureStore, repos RepoStore, q Quota) Service {
+	return &service{s: s, cs: cs, repos: repos, q: q}
+}
+
+func (s *service) CreateRule(r *domain.Repository, p Payload) (*domain.RollupRule, error) {
+	now := time.Now()
+	rule := &domain.RollupRule{
+		ID:           kallax.NewULID(),
+		AfterDays:    *p.AfterDays,
+		Resolution:   *p.Resolution,
+		Aggregate:    domain.AvgAggregate,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		RepositoryID: r.ID,
+	}
+	if p.Aggregate != nil {
+		rule.Aggregate = domain.Aggregate(*p.Aggregate)
+	}
+	if err := s.s.CreateRollupRule(rule); err != nil {
+		return nil, errors.Wrap(err, "could not create rollup rule")
+	}
+	return rule, nil
+}
+
+func (s *service) ListRules(r *domain.Repository) ([]domain.RollupRule, error) {
+	rules, err := s.s.ListRollupRules(r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list rollup rules")
+	}
+	if rules == nil {
+		rules = make([]domain.RollupRule, 0)
+	}
+	return rules, nil
+}
+
+func (s *service) GetRule(id kallax.ULID, r *domain.Repository) (*domain.RollupRule, error) {
+	rule, err := s.s.GetRollupRule(id, r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get rollup rule")
+	}
+	return rule, nil
+}
+
+func (s *service) RemoveRule(rule *domain.RollupRule) error {
+	t := time.Now()
+	rule.DeletedAt = &t
+	if err := s.s.SaveRollupRule(rule); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not remove rollup rule %v", rule.ID))
+	}
+	return nil
+}
+
+func (s *service) Run(now time.Time) ([]Report, error) {
+	rules, err := s.s.AllRollupRules()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list rollup rules")
+	}
+	reports := make([]Report, len(rules))
+	for i, rule := range rules {
+		reports[i] = Report{RuleID: rule.ID, RepositoryID: rule.RepositoryID}
+		if err := s.apply(rule, now, &reports[i]); err != nil {
+			reports[i].Error = err.Error()
+		}
+	}
+	return reports, nil
+}
+
+// apply replaces the captures of the rule repository older than its cutoff by their
+// rollups, one batch at a time.
+func (s *service) apply(rule domain.RollupRule, now time.Time, report *Report) error {
+	repo, err := s.repos.Get(rule.RepositoryID)
+	if err != nil {
+		return errors.Wrap(err, "could not get rollup rule repository")
+	}
+	before := rule.Cutoff(now)
+	var replaced map[kallax.ULID]bool
+	for {
+		captures, err := s.cs.RollupCandidates(rule.RepositoryID, before, rule.Resolution, batchSize)
+		if err != nil {
+			return errors.Wrap(err, "could not get captures to roll up")
+		}
+		if len(captures) == batchSize {
+			captures = completeBuckets(rule, captures)
+		}
+		if len(captures) == 0 {
+			return nil
+		}
+		// a capture of the previous batch still there would be rolled up again and again.
+		for _, c := range captures {
+			if replaced[c.ID] {
+				return errors.New("could not roll up captures, the previous batch was not replaced")
+			}
+		}
+		rollups := Aggregate(rule, captures, now)
+		if err := s.cs.ReplaceCaptures(captures, rollups); err != nil {
+			return errors.Wrap(err, "could not replace captures by rollups")
+		}
+		replaced = make(map[kallax.ULID]bool, len(captures))
+		for _, c := range captures {
+			replaced[c.ID] = true
+		}
+		report.Captures += int64(len(captures))
+		report.Rollups += int64(len(rollups))
+		if err := s.q.Release(captures...); err != nil {
+			return errors.Wrap(err, "could not release rolled up captures usage")
+		}
+		if err := s.q.Reserve(repo, rollups...); err != nil {
+			return errors.Wrap(err, "could not reserve rollups usage")
+		}
+	}
+}
+
+// completeBuckets leaves out the captures of the last bucket of a full batch, the next
+// batch could have more of them. A bucket bigger than a batch is rolled up by parts.
+func completeBuckets(rule domain.RollupRule, captures []domain.Capture) []domain.Capture {
+	last := captures[len(captures)-1].Timestamp.Truncate(rule.Window())
+	i := len(captures)
+	for i > 0 && captures[i-1].Timestamp.Truncate(rule.Window()).Equal(last) {
+		i--
+	}
+	if i == 0 {
+		return captures
+	}
+	return captures[:i]
+}
@@ -0,0 +1,203 @@
+package rollups_test
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/rollups"
+)
+
+type mockStore struct {
+	rule  *domain.RollupRule
+	rules []domain.RollupRule
+	err   error
+}
+
+func (m *mockStore) CreateRollupRule(*domain.RollupRule) error { return m.err }
+func (m *mockStore) ListRollupRules(kallax.ULID) ([]domain.RollupRule, error) {
+	return m.rules, m.err
+}
+func (m *mockStore) GetRollupRule(kallax.ULID, kallax.ULID) (*domain.RollupRule, error) {
+	return m.rule, m.err
+}
+func (m *mockStore) SaveRollupRule(*domain.RollupRule) error { return m.err }
+func (m *mockStore) AllRollupRules() ([]domain.RollupRule, error) {
+	return m.rules, m.err
+}
+
+type mockCaptureStore struct {
+	captures []domain.Capture
+	// stuck keeps the captures when they are replaced.
+	stuck bool
+	err   error
+}
+
+func (m *mockCaptureStore) RollupCandidates(repoID kallax.ULID, before time.Time, resolution int64, limit int) ([]domain.Capture, error) {
+	var candidates []domain.Capture
+	for _, c := range m.captures {
+		if c.RepositoryID == repoID && c.Timestamp.Before(before) && c.Resolution() < resolution {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].Timestamp.Before(candidates[j].Timestamp) })
+	if len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates, m.err
+}
+func (m *mockCaptureStore) ReplaceCaptures(captures []domain.Capture, rollups []domain.Capture) error {
+	if m.stuck {
+		return m.err
+	}
+	replaced := make(map[kallax.ULID]bool)
+	for _, c := range captures {
+		replaced[c.ID] = true
+	}
+	var kept []domain.Capture
+	for _, c := range m.captures {
+		if !replaced[c.ID] {
+			kept = append(kept, c)
+		}
+	}
+	m.captures = append(kept, rollups...)
+	return m.err
+}
+
+type mockRepoStore struct{ err error }
+
+func (m *mockRepoStore) Get(id kallax.ULID) (*domain.Repository, error) {
+	return &domain.Repository{ID: id}, m.err
+}
+
+type mockQuota struct{ reserved, released int }
+
+func (m *mockQuota) Reserve(_ *domain.Repository, captures ...domain.Capture) error {
+	m.reserved += len(captures)
+	return nil
+}
+func (m *mockQuota) Release(captures ...domain.Capture) error {
+	m.released += len(captures)
+	return nil
+}
+
+func TestServiceCreateRule(t *testing.T) {
+	t.Parallel()
+
+	s := rollups.NewService(&mockStore{}, &mockCaptureStore{}, &mockRepoStore{}, &mockQuota{})
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	rule, err := s.CreateRule(repo, rollups.Payload{AfterDays: i2P(30), Resolution: i64P(60)})
+	require.Nil(t, err)
+	assert.Equal(t, repo.ID, rule.RepositoryID)
+	assert.Equal(t, 30, rule.AfterDays)
+	assert.Equal(t, int64(60), rule.Resolution)
+	assert.Equal(t, domain.AvgAggregate, rule.Aggregate)
+
+	rule, err = s.CreateRule(repo, rollups.Payload{AfterDays: i2P(30), Resolution: i64P(60), Aggregate: s2P("max")})
+	require.Nil(t, err)
+	assert.Equal(t, domain.MaxAggregate, rule.Aggregate)
+}
+
+func TestServiceCreateRuleErr(t *testing.T) {
+	t.Parallel()
+
+	s := rollups.NewService(&mockStore{err: errors.New("test")}, &mockCaptureStore{}, &mockRepoStore{}, &mockQuota{})
+	_, err := s.CreateRule(&domain.Repository{ID: kallax.NewULID()}, rollups.Payload{AfterDays: i2P(30), Resolution: i64P(60)})
+	assert.EqualError(t, err, "could not create rollup rule: test")
+}
+
+func TestServiceListRules(t *testing.T) {
+	t.Parallel()
+
+	s := rollups.NewService(&mockStore{}, &mockCaptureStore{}, &mockRepoStore{}, &mockQuota{})
+	rules, err := s.ListRules(&domain.Repository{ID: kallax.NewULID()})
+	assert.Nil(t, err)
+	assert.NotNil(t, rules)
+	assert.Len(t, rules, 0)
+}
+
+func TestServiceRemoveRule(t *testing.T) {
+	t.Parallel()
+
+	s := rollups.NewService(&mockStore{}, &mockCaptureStore{}, &mockRepoStore{}, &mockQuota{})
+	rule := &domain.RollupRule{ID: kallax.NewULID()}
+	assert.Nil(t, s.RemoveRule(rule))
+	assert.NotNil(t, rule.DeletedAt)
+}
+
+func TestServiceRun(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 3, 1, 12, 0, 30, 0, time.UTC)
+	repoID := kallax.NewULID()
+	old := now.AddDate(0, 0, -40).Truncate(time.Minute)
+	var captures []domain.Capture
+	for i := 0; i < 6; i++ {
+		c := newCapture(old.Add(time.Duration(i)*20*time.Second), float64(i))
+		c.RepositoryID = repoID
+		captures = append(captures, c)
+	}
+	recent := newCapture(now, 1)
+	recent.RepositoryID = repoID
+	captures = append(captures, recent)
+
+	store := &mockStore{rules: []domain.RollupRule{
+		{ID: kallax.NewULID(), RepositoryID: repoID, AfterDays: 30, Resolution: 60, Aggregate: domain.AvgAggregate},
+	}}
+	captureStore := &mockCaptureStore{captures: captures}
+	quota := &mockQuota{}
+	s := rollups.NewService(store, captureStore, &mockRepoStore{}, quota)
+
+	reports, err := s.Run(now)
+	require.Nil(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, int64(6), reports[0].Captures)
+	assert.Equal(t, int64(2), reports[0].Rollups)
+	assert.Empty(t, reports[0].Error)
+	assert.Equal(t, 6, quota.released)
+	assert.Equal(t, 2, quota.reserved)
+
+	require.Len(t, captureStore.captures, 3)
+	assert.Equal(t, recent.ID, captureStore.captures[0].ID)
+	assert.Equal(t, domain.Payload{{Name: "power", Value: 1.0}}, captureStore.captures[1].Payload)
+	assert.Equal(t, domain.Payload{{Name: "power", Value: 4.0}}, captureStore.captures[2].Payload)
+
+	reports, err = s.Run(now)
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), reports[0].Captures)
+}
+
+func TestServiceRunReportsErr(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{rules: []domain.RollupRule{{ID: kallax.NewULID(), RepositoryID: kallax.NewULID(), AfterDays: 1, Resolution: 60}}}
+	s := rollups.NewService(store, &mockCaptureStore{err: errors.New("test")}, &mockRepoStore{}, &mockQuota{})
+
+	reports, err := s.Run(time.Now())
+	require.Nil(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "could not get captures to roll up: test", reports[0].Error)
+}
+
+func TestServiceRunStopsWhenNothingIsReplaced(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	repoID := kallax.NewULID()
+	c := newCapture(now.AddDate(0, 0, -2), 1)
+	c.RepositoryID = repoID
+	store := &mockStore{rules: []domain.RollupRule{{ID: kallax.NewULID(), RepositoryID: repoID, AfterDays: 1, Resolution: 60}}}
+	s := rollups.NewService(store, &mockCaptureStore{captures: []domain.Capture{c}, stuck: true}, &mockRepoStore{}, &mockQuota{})
+
+	reports, err := s.Run(now)
+	require.Nil(t, err)
+	require.Len(t, reports, 1)
+	assert.Equal(t, "could not roll up captures, the previous batch was not replaced", reports[0].Error)
+	assert.Equal(t, int64(1), reports[0].Captures)
+}
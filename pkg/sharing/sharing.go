@@ -0,0 +1,97 @@
+package sharing
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/validate"
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+const (
+	// DefaultExpiration is the lifetime of a link created without expiresIn.
+	DefaultExpiration = 7 * 24 * time.Hour
+	// MaxExpiration is the longest lifetime allowed to a link.
+	MaxExpiration = 90 * 24 * time.Hour
+
+	errNameRequired      = "name must not be blank"
+	errExpiresInPositive = "expiresIn must be greater than zero"
+
+	tokenPrefix = "shr_"
+	idSize      = 16
+	expSize     = 8
+)
+
+// Payload represents the data to create a share link.
+type Payload struct {
+	Name *string `json:"name"`
+	// ExpiresIn is the lifetime of the link in seconds.
+	ExpiresIn *int `json:"expiresIn"`
+}
+
+func (p *Payload) Validate() error {
+	e := validate.NewErrors()
+	if p.Name == nil || len(strings.TrimSpace(*p.Name)) == 0 {
+		e.Add("name", errNameRequired)
+	}
+
+	if p.ExpiresIn != nil {
+		if *p.ExpiresIn <= 0 {
+			e.Add("expiresIn", errExpiresInPositive)
+		} else if time.Duration(*p.ExpiresIn)*time.Second > MaxExpiration {
+			e.Add("expiresIn", fmt.Sprintf("expiresIn must not exceed %v seconds", int(MaxExpiration.Seconds())))
+		}
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+func (p *Payload) expiration() time.Duration {
+	if p.ExpiresIn == nil {
+		return DefaultExpiration
+	}
+	return time.Duration(*p.ExpiresIn) * time.Second
+}
+
+// Sign creates a share token with the format shr_<payload>.<signature>, where the
+// payload holds the link id and its expiration and the signature is its HMAC-SHA256.
+func Sign(secret []byte, id kallax.ULID, expiresAt time.Time) string {
+	raw := make([]byte, idSize+expSize)
+	copy(raw, id[:])
+	binary.BigEndian.PutUint64(raw[idSize:], uint64(expiresAt.Unix()))
+	payload := base64.RawURLEncoding.EncodeToString(raw)
+	return tokenPrefix + payload + "." + signature(secret, payload)
+}
+
+// Verify checks the signature of a share token, returning the link id and its expiration.
+func Verify(secret []byte, token string) (kallax.ULID, time.Time, bool) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return kallax.ULID{}, time.Time{}, false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(token, tokenPrefix), ".", 2)
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(signature(secret, parts[0]))) {
+		return kallax.ULID{}, time.Time{}, false
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil || len(raw) != idSize+expSize {
+		return kallax.ULID{}, time.Time{}, false
+	}
+	var id kallax.ULID
+	copy(id[:], raw[:idSize])
+	exp := time.Unix(int64(binary.BigEndian.Uint64(raw[idSize:])), 0)
+	return id, exp, true
+}
+
+func signature(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(tokenPrefix + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
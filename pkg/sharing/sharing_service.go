@@ -0,0 +1,146 @@
+package sharing
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type invalidLinkErr string
+
+func (i invalidLinkErr) Error() string         { return string(i) }
+func (i invalidLinkErr) IsNotAuthorized() bool { return true }
+
+const errInvalidLink invalidLinkErr = "invalid, expired or revoked share link"
+
+type notFoundErr interface {
+	// NotFound returns true when a resource is not found.
+	NotFound() bool
+}
+
+func isNotFound(err error) bool {
+	if e, ok := errors.Cause(err).(notFoundErr); ok {
+		return e.NotFound()
+	}
+	return false
+}
+
+// Store provides access to the share link storage.
+type Store interface {
+	// CreateShareLink stores a new share link.
+	CreateShareLink(*domain.ShareLink) error
+	// ListShareLinks retrieve all the share links of a repository.
+	ListShareLinks(repoID kallax.ULID) ([]domain.ShareLink, error)
+	// GetShareLink retrieve a share link of a repository.
+	GetShareLink(linkID, repoID kallax.ULID) (*domain.ShareLink, error)
+	// GetShareLinkByID retrieve a share link by its id.
+	GetShareLinkByID(kallax.ULID) (*domain.ShareLink, error)
+	// SaveShareLink the share link state into the storage.
+	SaveShareLink(*domain.ShareLink) error
+}
+
+// Link represents a just created share link. The token is only visible at creation time.
+type Link struct {
+	domain.ShareLink
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// Service provides share links operations.
+type Service interface {
+	// CreateLink creates a new share link for a repository.
+	CreateLink(*domain.User, *domain.Repository, Payload) (*Link, error)
+	// ListLinks list the repo share links.
+	ListLinks(*domain.Repository) ([]domain.ShareLink, error)
+	// GetLink retrieve a repo share link.
+	GetLink(kallax.ULID, *domain.Repository) (*domain.ShareLink, error)
+	// RevokeLink revokes a share link.
+	RevokeLink(*domain.ShareLink) error
+	// AuthorizeLink checks a share token, returning its link when it's active.
+	AuthorizeLink(string) (*domain.ShareLink, error)
+}
+
+type service struct {
+	s      Store
+	secret []byte
+	appURL string
+}
+
+// NewService creates a share links service with the necessary dependencies.
+// The tokens are signed with secret and the links point to appURL.
+func NewService(s Store, secret []byte, appURL string) Service {
+	return &service{s: s, secret: secret, appURL: appURL}
+}
+
+func (s *service) CreateLink(u *domain.User, r *domain.Repository, p Payload) (*Link, error) {
+	now := time.Now()
+	l := domain.ShareLink{
+		ID:           kallax.NewULID(),
+		Name:         *p.Name,
+		ExpiresAt:    now.Add(p.expiration()).Truncate(time.Second),
+		CreatedAt:    now,
+		RepositoryID: r.ID,
+		UserID:       u.ID,
+	}
+	if err := s.s.CreateShareLink(&l); err != nil {
+		return nil, errors.Wrap(err, "could not create share link")
+	}
+	t := Sign(s.secret, l.ID, l.ExpiresAt)
+	link := fmt.Sprintf("%s/repositories/%s?share=%s", s.appURL, r.ID, url.QueryEscape(t))
+	return &Link{ShareLink: l, Token: t, URL: link}, nil
+}
+
+func (s *service) ListLinks(r *domain.Repository) ([]domain.ShareLink, error) {
+	links, err := s.s.ListShareLinks(r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list share links")
+	}
+	if links == nil {
+		links = make([]domain.ShareLink, 0)
+	}
+	return links, nil
+}
+
+func (s *service) GetLink(id kallax.ULID, r *domain.Repository) (*domain.ShareLink, error) {
+	l, err := s.s.GetShareLink(id, r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get share link")
+	}
+	return l, nil
+}
+
+func (s *service) RevokeLink(l *domain.ShareLink) error {
+	if l.RevokedAt != nil {
+		return nil
+	}
+	t := time.Now()
+	l.RevokedAt = &t
+	if err := s.s.SaveShareLink(l); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not revoke share link %v", l.ID))
+	}
+	return nil
+}
+
+func (s *service) AuthorizeLink(token string) (*domain.ShareLink, error) {
+	id, exp, ok := Verify(s.secret, token)
+	now := time.Now()
+	if !ok || !now.Before(exp) {
+		return nil, errors.WithStack(errInvalidLink)
+	}
+	l, err := s.s.GetShareLinkByID(id)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(errInvalidLink)
+		}
+		return nil, errors.Wrap(err, "could not authorize share link")
+	}
+	if !l.Allows(l.RepositoryID, now) {
+		return nil, errors.WithStack(errInvalidLink)
+	}
+	return l, nil
+}
@@ -0,0 +1,156 @@
+package sharing_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/sharing"
+)
+
+var secret = []byte("secret")
+
+type notFoundErr string
+
+func (e notFoundErr) Error() string  { return string(e) }
+func (e notFoundErr) NotFound() bool { return true }
+
+type mockStore struct {
+	link  *domain.ShareLink
+	links []domain.ShareLink
+	err   error
+}
+
+func (m *mockStore) CreateShareLink(l *domain.ShareLink) error {
+	m.link = l
+	return m.err
+}
+func (m *mockStore) ListShareLinks(kallax.ULID) ([]domain.ShareLink, error) { return m.links, m.err }
+func (m *mockStore) GetShareLink(kallax.ULID, kallax.ULID) (*domain.ShareLink, error) {
+	return m.link, m.err
+}
+func (m *mockStore) GetShareLinkByID(kallax.ULID) (*domain.ShareLink, error) {
+	return m.link, m.err
+}
+func (m *mockStore) SaveShareLink(*domain.ShareLink) error { return m.err }
+
+func TestServiceCreateLink(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{}
+	s := sharing.NewService(store, secret, "http://localhost")
+	u := &domain.User{ID: kallax.NewULID()}
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	before := time.Now()
+	l, err := s.CreateLink(u, repo, sharing.Payload{Name: s2P("partner"), ExpiresIn: i2P(3600)})
+	assert.Nil(t, err)
+	assert.Equal(t, "partner", l.Name)
+	assert.Equal(t, repo.ID, l.RepositoryID)
+	assert.Equal(t, u.ID, l.UserID)
+	assert.WithinDuration(t, before.Add(time.Hour), l.ExpiresAt, time.Second)
+	assert.True(t, strings.HasPrefix(l.URL, "http://localhost/repositories/"+repo.ID.String()+"?share="))
+
+	id, exp, ok := sharing.Verify(secret, l.Token)
+	assert.True(t, ok)
+	assert.Equal(t, store.link.ID, id)
+	assert.True(t, store.link.ExpiresAt.Equal(exp))
+}
+
+func TestServiceCreateLinkDefaultExpiration(t *testing.T) {
+	t.Parallel()
+
+	s := sharing.NewService(&mockStore{}, secret, "")
+	before := time.Now()
+	l, err := s.CreateLink(&domain.User{}, &domain.Repository{}, sharing.Payload{Name: s2P("partner")})
+	assert.Nil(t, err)
+	assert.WithinDuration(t, before.Add(sharing.DefaultExpiration), l.ExpiresAt, time.Second)
+}
+
+func TestServiceListLinks(t *testing.T) {
+	t.Parallel()
+
+	s := sharing.NewService(&mockStore{}, secret, "")
+	links, err := s.ListLinks(&domain.Repository{ID: kallax.NewULID()})
+	assert.Nil(t, err)
+	assert.NotNil(t, links)
+	assert.Len(t, links, 0)
+}
+
+func TestServiceRevokeLink(t *testing.T) {
+	t.Parallel()
+
+	s := sharing.NewService(&mockStore{}, secret, "")
+	l := &domain.ShareLink{ID: kallax.NewULID()}
+	assert.Nil(t, s.RevokeLink(l))
+	assert.NotNil(t, l.RevokedAt)
+
+	revokedAt := *l.RevokedAt
+	time.Sleep(time.Millisecond)
+	assert.Nil(t, s.RevokeLink(l))
+	assert.Equal(t, revokedAt, *l.RevokedAt)
+}
+
+func TestServiceAuthorizeLink(t *testing.T) {
+	t.Parallel()
+
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+	link := &domain.ShareLink{ID: kallax.NewULID(), RepositoryID: kallax.NewULID(), ExpiresAt: exp}
+	s := sharing.NewService(&mockStore{link: link}, secret, "")
+
+	l, err := s.AuthorizeLink(sharing.Sign(secret, link.ID, exp))
+	assert.Nil(t, err)
+	assert.Equal(t, link, l)
+}
+
+func TestServiceAuthorizeLinkFails(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	exp := now.Add(time.Hour).Truncate(time.Second)
+	id := kallax.NewULID()
+	active := &domain.ShareLink{ID: id, ExpiresAt: exp}
+	revoked := &domain.ShareLink{ID: id, ExpiresAt: exp, RevokedAt: &now}
+
+	tt := []struct {
+		name  string
+		store *mockStore
+		token string
+		err   string
+	}{
+		{"invalid signature", &mockStore{link: active}, sharing.Sign([]byte("other"), id, exp), "invalid, expired or revoked share link"},
+		{"expired", &mockStore{link: active}, sharing.Sign(secret, id, now.Add(-time.Hour)), "invalid, expired or revoked share link"},
+		{"not found", &mockStore{err: notFoundErr("test")}, sharing.Sign(secret, id, exp), "invalid, expired or revoked share link"},
+		{"revoked", &mockStore{link: revoked}, sharing.Sign(secret, id, exp), "invalid, expired or revoked share link"},
+		{"store err", &mockStore{err: errors.New("test")}, sharing.Sign(secret, id, exp), "could not authorize share link: test"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := sharing.NewService(tc.store, secret, "")
+			_, err := s.AuthorizeLink(tc.token)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestServiceShareLinksErrors(t *testing.T) {
+	t.Parallel()
+
+	s := sharing.NewService(&mockStore{err: errors.New("test")}, secret, "")
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	l := &domain.ShareLink{ID: kallax.NewULID()}
+
+	_, err := s.CreateLink(&domain.User{}, repo, sharing.Payload{Name: s2P("partner")})
+	assert.EqualError(t, err, "could not create share link: test")
+	_, err = s.ListLinks(repo)
+	assert.EqualError(t, err, "could not list share links: test")
+	_, err = s.GetLink(l.ID, repo)
+	assert.EqualError(t, err, "could not get share link: test")
+	err = s.RevokeLink(l)
+	assert.EqualError(t, err, "could not revoke share link "+l.ID.String()+": test")
+}
@@ -0,0 +1,72 @@
+package sharing_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/sharing"
+)
+
+func s2P(v string) *string {
+	return &v
+}
+
+func i2P(v int) *int {
+	return &v
+}
+
+func TestValidatePayloadOK(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, (&sharing.Payload{Name: s2P("partner")}).Validate())
+	assert.Nil(t, (&sharing.Payload{Name: s2P("partner"), ExpiresIn: i2P(3600)}).Validate())
+}
+
+func TestValidatePayloadFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		payload sharing.Payload
+		errs    []string
+	}{
+		{"missing name", sharing.Payload{}, []string{"name must not be blank"}},
+		{"blank name", sharing.Payload{Name: s2P(" ")}, []string{"name must not be blank"}},
+		{"zero expiration", sharing.Payload{Name: s2P("partner"), ExpiresIn: i2P(0)}, []string{"expiresIn must be greater than zero"}},
+		{"too long expiration", sharing.Payload{Name: s2P("partner"), ExpiresIn: i2P(91 * 24 * 60 * 60)}, []string{"expiresIn must not exceed 7776000 seconds"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.payload.Validate()
+			for _, e := range tc.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	t.Parallel()
+
+	secret := []byte("secret")
+	id := kallax.NewULID()
+	exp := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	token := sharing.Sign(secret, id, exp)
+	gotID, gotExp, ok := sharing.Verify(secret, token)
+	assert.True(t, ok)
+	assert.Equal(t, id, gotID)
+	assert.True(t, exp.Equal(gotExp))
+
+	_, _, ok = sharing.Verify([]byte("other"), token)
+	assert.False(t, ok)
+
+	for _, invalid := range []string{"", "shr_", "shr_abc", "cap_abc.def", token + "x", "shr_x" + token[4:]} {
+		_, _, ok := sharing.Verify(secret, invalid)
+		assert.False(t, ok, invalid)
+	}
+}
@@ -2,6 +2,7 @@ package signup
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/pkg/errors"
@@ -33,19 +34,26 @@ type Store interface {
 	SaveUser(user *domain.User) error
 }
 
+// Verifier provides the email verification of new users.
+type Verifier interface {
+	// SendVerification emails a verification link to the user.
+	SendVerification(*domain.User) error
+}
+
 // Service provides sign-up operations.
 type Service interface {
-	// EnrollUser register a new user
+	// EnrollUser register a new user and sends the verification email
 	EnrollUser(Payload) (*User, error)
 }
 
 type service struct {
 	s Store
+	v Verifier
 }
 
 // NewService creates an sign-up service with the necessary dependencies
-func NewService(s Store) Service {
-	return &service{s: s}
+func NewService(s Store, v Verifier) Service {
+	return &service{s: s, v: v}
 }
 
 func (s *service) EnrollUser(p Payload) (*User, error) {
@@ -60,6 +68,10 @@ func (s *service) EnrollUser(p Payload) (*User, error) {
 		}
 		return nil, errors.Wrap(err, "could not save user")
 	}
+	// the user is already enrolled and could ask for a new verification email.
+	if err := s.v.SendVerification(u); err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "could not send verification to user %v", u.ID))
+	}
 	return getUser(*u), nil
 }
 
@@ -91,10 +103,11 @@ func getDomainUser(p Payload) (*domain.User, error) {
 
 func getUser(u domain.User) *User {
 	return &User{
-		ID:        u.ID.String(),
-		Email:     u.Email,
-		CreatedAt: u.CreatedAt,
-		UpdatedAt: u.UpdatedAt,
+		ID:            u.ID.String(),
+		Email:         u.Email,
+		EmailVerified: u.EmailVerified(),
+		CreatedAt:     u.CreatedAt,
+		UpdatedAt:     u.UpdatedAt,
 	}
 }
 
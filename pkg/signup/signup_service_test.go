@@ -17,6 +17,16 @@ type mockStore struct {
 
 func (m *mockStore) SaveUser(user *domain.User) error { return m.err }
 
+type mockVerifier struct {
+	sent []*domain.User
+	err  error
+}
+
+func (m *mockVerifier) SendVerification(u *domain.User) error {
+	m.sent = append(m.sent, u)
+	return m.err
+}
+
 func string2pointer(v string) *string { return &v }
 
 func TestServiceEnrollUserOK(t *testing.T) {
@@ -39,7 +49,7 @@ func TestServiceEnrollUserOK(t *testing.T) {
 		},
 	}
 
-	s := signup.NewService(&mockStore{})
+	s := signup.NewService(&mockStore{}, &mockVerifier{})
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			u, err := s.EnrollUser(tc.payl)
@@ -63,7 +73,7 @@ type conflictErr interface {
 
 func TestServiceEnrollUserErrWhenDuplicatedEmail(t *testing.T) {
 	t.Parallel()
-	s := signup.NewService(&mockStore{err: uniqueConstraintErr("duplicated email")})
+	s := signup.NewService(&mockStore{err: uniqueConstraintErr("duplicated email")}, &mockVerifier{})
 	payl := signup.Payload{Email: string2pointer("ifreddyrondon@gmail.com")}
 	_, err := s.EnrollUser(payl)
 	assert.EqualError(t, err, "email ifreddyrondon@gmail.com already exist")
@@ -74,8 +84,29 @@ func TestServiceEnrollUserErrWhenDuplicatedEmail(t *testing.T) {
 
 func TestServiceEnrollUserErrWhenSaving(t *testing.T) {
 	t.Parallel()
-	s := signup.NewService(&mockStore{err: errors.New("test")})
+	s := signup.NewService(&mockStore{err: errors.New("test")}, &mockVerifier{})
 	payl := signup.Payload{Email: string2pointer("ifreddyrondon@gmail.com")}
 	_, err := s.EnrollUser(payl)
 	assert.EqualError(t, err, "could not save user: test")
 }
+
+func TestServiceEnrollUserSendsVerification(t *testing.T) {
+	t.Parallel()
+
+	v := &mockVerifier{}
+	s := signup.NewService(&mockStore{}, v)
+	u, err := s.EnrollUser(signup.Payload{Email: string2pointer("ifreddyrondon@gmail.com")})
+	assert.Nil(t, err)
+	assert.False(t, u.EmailVerified)
+	assert.Len(t, v.sent, 1)
+	assert.Equal(t, "ifreddyrondon@gmail.com", v.sent[0].Email)
+}
+
+func TestServiceEnrollUserOKWhenVerificationFails(t *testing.T) {
+	t.Parallel()
+
+	s := signup.NewService(&mockStore{}, &mockVerifier{err: errors.New("test")})
+	u, err := s.EnrollUser(signup.Payload{Email: string2pointer("ifreddyrondon@gmail.com")})
+	assert.Nil(t, err)
+	assert.Equal(t, "ifreddyrondon@gmail.com", u.Email)
+}
@@ -0,0 +1,138 @@
+package capture
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/file"
+	memcapture "github.com/ifreddyrondon/capture/pkg/storage/memory/capture"
+)
+
+const (
+	createOp  = "create"
+	saveOp    = "save"
+	deleteOp  = "delete"
+	purgeOp   = "purge"
+	replaceOp = "replace"
+)
+
+type entry struct {
+	Op       string
+	Captures []domain.Capture
+	// RepositoryID, Before and IDs select the captures deleted permanently. The replaced
+	// captures are in IDs and their rollups in Captures.
+	RepositoryID kallax.ULID
+	Before       time.Time
+	IDs          []kallax.ULID
+}
+
+// FileStorage file storage layer. Reads are served from memory.
+type FileStorage struct {
+	*memcapture.MemStorage
+	mu      sync.Mutex
+	journal *file.Journal
+}
+
+// NewFileStorage opens the captures journal at path and loads its captures.
+func NewFileStorage(path string) (*FileStorage, error) {
+	s := &FileStorage{MemStorage: memcapture.NewMemStorage()}
+	journal, err := file.OpenJournal(path, func() interface{} { return &entry{} }, file.SkipRejected(s.apply))
+	if err != nil {
+		return nil, errors.Wrap(err, "opening capture filestorage")
+	}
+	s.journal = journal
+	return s, nil
+}
+
+func (f *FileStorage) apply(v interface{}) error {
+	e := v.(*entry)
+	switch e.Op {
+	case createOp:
+		return f.MemStorage.CreateCaptures(e.Captures...)
+	case saveOp:
+		for i := range e.Captures {
+			if err := f.MemStorage.Save(&e.Captures[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case deleteOp:
+		_, err := f.MemStorage.DeleteCaptures(e.RepositoryID, e.Before, e.IDs...)
+		return err
+	case purgeOp:
+		_, err := f.MemStorage.PurgeCaptures(e.RepositoryID, e.Before)
+		return err
+	case replaceOp:
+		replaced := make([]domain.Capture, len(e.IDs))
+		for i, id := range e.IDs {
+			replaced[i].ID = id
+		}
+		return f.MemStorage.ReplaceCaptures(replaced, e.Captures)
+	}
+	return file.UnknownOp("capture", e.Op)
+}
+
+// write appends the change to the journal and then applies it in memory, in the same
+// order, so a change that isn't in the journal is never served.
+func (f *FileStorage) write(e entry, apply func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.journal.Append(&e); err != nil {
+		return err
+	}
+	return apply()
+}
+
+func (f *FileStorage) CreateCapture(c *domain.Capture) error {
+	return f.CreateCaptures(*c)
+}
+
+func (f *FileStorage) CreateCaptures(captures ...domain.Capture) error {
+	return f.write(entry{Op: createOp, Captures: captures}, func() error {
+		return f.MemStorage.CreateCaptures(captures...)
+	})
+}
+
+func (f *FileStorage) Save(capt *domain.Capture) error {
+	return f.write(entry{Op: saveOp, Captures: []domain.Capture{*capt}}, func() error {
+		return f.MemStorage.Save(capt)
+	})
+}
+
+func (f *FileStorage) DeleteCaptures(repoID kallax.ULID, before time.Time, ids ...kallax.ULID) (int64, error) {
+	var deleted int64
+	e := entry{Op: deleteOp, RepositoryID: repoID, Before: before, IDs: ids}
+	err := f.write(e, func() error {
+		var err error
+		deleted, err = f.MemStorage.DeleteCaptures(repoID, before, ids...)
+		return err
+	})
+	return deleted, err
+}
+
+func (f *FileStorage) PurgeCaptures(repoID kallax.ULID, before time.Time) (int64, error) {
+	var purged int64
+	err := f.write(entry{Op: purgeOp, RepositoryID: repoID, Before: before}, func() error {
+		var err error
+		purged, err = f.MemStorage.PurgeCaptures(repoID, before)
+		return err
+	})
+	return purged, err
+}
+
+func (f *FileStorage) ReplaceCaptures(captures []domain.Capture, rollups []domain.Capture) error {
+	ids := make([]kallax.ULID, len(captures))
+	for i, c := range captures {
+		ids[i] = c.ID
+	}
+	return f.write(entry{Op: replaceOp, Captures: rollups, IDs: ids}, func() error {
+		return f.MemStorage.ReplaceCaptures(captures, rollups)
+	})
+}
+
+// Close closes the journal.
+func (f *FileStorage) Close() error { return f.journal.Close() }
@@ -0,0 +1,138 @@
+package capture_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/file/capture"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestFileStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "captures")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	s, err := capture.NewFileStorage(filepath.Join(dir, "captures.journal"))
+	require.Nil(t, err)
+	defer s.Close()
+
+	storagetest.RunCaptureStore(t, s)
+}
+
+func TestFileStorageReopen(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "captures")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "captures.journal")
+
+	s, err := capture.NewFileStorage(path)
+	require.Nil(t, err)
+	repoID := kallax.NewULID()
+	lat, lng := 1.0, 2.0
+	now := time.Now().UTC()
+	kept := domain.Capture{
+		ID:           kallax.NewULID(),
+		Payload:      domain.Payload{{Name: "power", Value: 10.5}, {Name: "flags", Value: []interface{}{"a"}}},
+		Location:     &domain.Point{LAT: &lat, LNG: &lng},
+		Tags:         []string{"tag"},
+		Timestamp:    now,
+		RepositoryID: repoID,
+	}
+	removed := domain.Capture{ID: kallax.NewULID(), Timestamp: now, RepositoryID: repoID}
+	expired := domain.Capture{ID: kallax.NewULID(), Timestamp: now.Add(-time.Hour), RepositoryID: repoID}
+	require.Nil(t, s.CreateCaptures(kept, removed, expired))
+	removed.DeletedAt = &now
+	require.Nil(t, s.Save(&removed))
+	_, err = s.DeleteCaptures(repoID, now, expired.ID)
+	require.Nil(t, err)
+	require.Nil(t, s.Close())
+
+	s, err = capture.NewFileStorage(path)
+	require.Nil(t, err)
+	defer s.Close()
+
+	got, err := s.Get(kept.ID, repoID)
+	require.Nil(t, err)
+	assert.Equal(t, kept.Payload, got.Payload)
+	assert.Equal(t, kept.Location, got.Location)
+	assert.Equal(t, kept.Tags, got.Tags)
+	assert.True(t, kept.Timestamp.Equal(got.Timestamp))
+	_, err = s.Get(removed.ID, repoID)
+	assert.Error(t, err)
+	_, err = s.Get(expired.ID, repoID)
+	assert.Error(t, err)
+
+	captures, total, err := s.List(&domain.Listing{Owner: &repoID, SortKey: "timestamp DESC", Limit: 10})
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, captures, 1)
+	assert.Equal(t, kept.ID, captures[0].ID)
+}
+
+func TestFileStorageReopenReplacedCaptures(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "captures")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "captures.journal")
+
+	s, err := capture.NewFileStorage(path)
+	require.Nil(t, err)
+	repoID := kallax.NewULID()
+	now := time.Now().UTC()
+	raw := domain.Capture{ID: kallax.NewULID(), Payload: domain.Payload{{Name: "power", Value: 1.5}}, Timestamp: now, RepositoryID: repoID}
+	rollup := raw
+	rollup.ID = kallax.NewULID()
+	rollup.Rollup = &domain.Rollup{Resolution: 60, Aggregate: domain.AvgAggregate, Samples: 1}
+	require.Nil(t, s.CreateCaptures(raw))
+	require.Nil(t, s.ReplaceCaptures([]domain.Capture{raw}, []domain.Capture{rollup}))
+	require.Nil(t, s.Close())
+
+	s, err = capture.NewFileStorage(path)
+	require.Nil(t, err)
+	defer s.Close()
+
+	_, err = s.Get(raw.ID, repoID)
+	assert.Error(t, err)
+	got, err := s.Get(rollup.ID, repoID)
+	require.Nil(t, err)
+	assert.Equal(t, rollup.Rollup, got.Rollup)
+}
+
+func TestFileStorageReopenSkipsRejectedEntries(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "captures")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "captures.journal")
+
+	s, err := capture.NewFileStorage(path)
+	require.Nil(t, err)
+	repoID := kallax.NewULID()
+	c := domain.Capture{ID: kallax.NewULID(), Timestamp: time.Now().UTC(), RepositoryID: repoID}
+	require.Nil(t, s.CreateCaptures(c))
+	assert.Error(t, s.CreateCaptures(c))
+	require.Nil(t, s.Close())
+
+	s, err = capture.NewFileStorage(path)
+	require.Nil(t, err)
+	defer s.Close()
+
+	_, total, err := s.List(&domain.Listing{Owner: &repoID, SortKey: "timestamp DESC", Limit: 10})
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+}
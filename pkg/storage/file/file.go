@@ -0,0 +1,140 @@
+// Package file holds the journal shared by the file storages. They keep the records in
+// memory like the memory storages and append every change to a journal file, replayed
+// when the storage is opened, so single node deployments run without a database.
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	// payload values come from decoded json.
+	gob.Register([]interface{}{})
+	gob.Register(map[string]interface{}{})
+}
+
+// Journal is an append only file of gob encoded entries. Every entry is written with
+// its length ahead, so an entry cut by a crash is detected and dropped on open.
+type Journal struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+// OpenJournal opens or creates the journal at path. Each entry in the file is decoded
+// into a new value from newEntry and passed to replay.
+func OpenJournal(path string, newEntry func() interface{}, replay func(interface{}) error) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, errors.Wrapf(err, "creating the directory of journal %s", path)
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening journal %s", path)
+	}
+	size, err := readEntries(f, newEntry, replay)
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "replaying journal %s", path)
+	}
+	// drops an incomplete entry at the end and appends after the last complete one.
+	if err := f.Truncate(size); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "truncating journal %s", path)
+	}
+	if _, err := f.Seek(size, io.SeekStart); err != nil {
+		f.Close()
+		return nil, errors.Wrapf(err, "seeking journal %s", path)
+	}
+	return &Journal{f: f}, nil
+}
+
+// readEntries replays the complete entries and returns the size they take in the file.
+func readEntries(r io.Reader, newEntry func() interface{}, replay func(interface{}) error) (int64, error) {
+	br := bufio.NewReader(r)
+	var size int64
+	for {
+		var n uint32
+		if err := binary.Read(br, binary.BigEndian, &n); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return size, nil
+			}
+			return 0, err
+		}
+		data := make([]byte, n)
+		if _, err := io.ReadFull(br, data); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return size, nil
+			}
+			return 0, err
+		}
+		v := newEntry()
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(v); err != nil {
+			return 0, errors.Wrap(err, "decoding journal entry")
+		}
+		if err := replay(v); err != nil {
+			return 0, err
+		}
+		size += int64(4 + n)
+	}
+}
+
+type unknownOpErr string
+
+func (e unknownOpErr) Error() string { return string(e) }
+
+// UnknownOp returns the error of a journal entry with an operation the storage doesn't
+// know, the journal can't be replayed.
+func UnknownOp(journal, op string) error {
+	return errors.WithStack(unknownOpErr(fmt.Sprintf("unknown %s journal operation %s", journal, op)))
+}
+
+// SkipRejected wraps the replay function of a storage so the entries it rejects are
+// skipped. The storages append an entry before applying it, an entry rejected when it
+// was written is in the journal too and it's rejected again when replayed. Entries with
+// an unknown operation still fail the replay.
+func SkipRejected(replay func(interface{}) error) func(interface{}) error {
+	return func(v interface{}) error {
+		err := replay(v)
+		if _, ok := errors.Cause(err).(unknownOpErr); ok {
+			return err
+		}
+		return nil
+	}
+}
+
+// Append writes the entry at the end of the journal and syncs it to disk.
+func (j *Journal) Append(v interface{}) error {
+	var buf bytes.Buffer
+	buf.Write(make([]byte, 4))
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return errors.Wrap(err, "encoding journal entry")
+	}
+	data := buf.Bytes()
+	binary.BigEndian.PutUint32(data, uint32(len(data)-4))
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.f.Write(data); err != nil {
+		return errors.Wrap(err, "writing journal entry")
+	}
+	if err := j.f.Sync(); err != nil {
+		return errors.Wrap(err, "syncing journal")
+	}
+	return nil
+}
+
+// Close closes the journal file.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
@@ -0,0 +1,74 @@
+package file_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/file"
+)
+
+type entry struct {
+	Name  string
+	Value interface{}
+}
+
+func openJournal(t *testing.T, path string) (*file.Journal, []entry) {
+	var entries []entry
+	j, err := file.OpenJournal(path, func() interface{} { return &entry{} }, func(v interface{}) error {
+		entries = append(entries, *v.(*entry))
+		return nil
+	})
+	require.Nil(t, err)
+	return j, entries
+}
+
+func TestJournalReplay(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "journal")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "data", "test.journal")
+
+	j, entries := openJournal(t, path)
+	assert.Len(t, entries, 0)
+	require.Nil(t, j.Append(&entry{Name: "a", Value: 1.5}))
+	require.Nil(t, j.Append(&entry{Name: "b", Value: map[string]interface{}{"x": "y"}}))
+	require.Nil(t, j.Close())
+
+	j, entries = openJournal(t, path)
+	require.Nil(t, j.Close())
+	expected := []entry{{Name: "a", Value: 1.5}, {Name: "b", Value: map[string]interface{}{"x": "y"}}}
+	assert.Equal(t, expected, entries)
+}
+
+func TestJournalDropsIncompleteEntry(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "journal")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "test.journal")
+
+	j, _ := openJournal(t, path)
+	require.Nil(t, j.Append(&entry{Name: "a"}))
+	require.Nil(t, j.Append(&entry{Name: "b"}))
+	require.Nil(t, j.Close())
+	info, err := os.Stat(path)
+	require.Nil(t, err)
+	require.Nil(t, os.Truncate(path, info.Size()-3))
+
+	j, entries := openJournal(t, path)
+	assert.Equal(t, []entry{{Name: "a"}}, entries)
+	require.Nil(t, j.Append(&entry{Name: "c"}))
+	require.Nil(t, j.Close())
+
+	j, entries = openJournal(t, path)
+	require.Nil(t, j.Close())
+	assert.Equal(t, []entry{{Name: "a"}, {Name: "c"}}, entries)
+}
@@ -0,0 +1,102 @@
+package repo
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/file"
+	memrepo "github.com/ifreddyrondon/capture/pkg/storage/memory/repo"
+)
+
+const (
+	saveOp       = "save"
+	updateOp     = "update"
+	transferOp   = "transfer"
+	removeUserOp = "remove_user"
+)
+
+type entry struct {
+	Op     string
+	Repo   domain.Repository
+	UserID kallax.ULID
+	At     time.Time
+}
+
+// FileStorage file storage layer. Reads are served from memory.
+type FileStorage struct {
+	*memrepo.MemStorage
+	mu      sync.Mutex
+	journal *file.Journal
+	usages  memrepo.UsageTransferer
+}
+
+// NewFileStorage opens the repositories journal at path and loads its repositories,
+// usages could be nil. The replayed repositories take the usage already in usages.
+func NewFileStorage(path string, usages memrepo.UsageTransferer) (*FileStorage, error) {
+	s := &FileStorage{MemStorage: memrepo.NewMemStorage(usages), usages: usages}
+	journal, err := file.OpenJournal(path, func() interface{} { return &entry{} }, file.SkipRejected(s.apply))
+	if err != nil {
+		return nil, errors.Wrap(err, "opening repo filestorage")
+	}
+	s.journal = journal
+	return s, nil
+}
+
+func (f *FileStorage) apply(v interface{}) error {
+	e := v.(*entry)
+	switch e.Op {
+	case saveOp:
+		if err := f.MemStorage.SaveRepo(&e.Repo); err != nil {
+			return err
+		}
+		// the usage loaded from the captures has no owner, it's given by the repository.
+		if f.usages != nil {
+			f.usages.TransferUsage(e.Repo.ID, e.Repo.UserID)
+		}
+		return nil
+	case updateOp:
+		return f.MemStorage.UpdateRepo(&e.Repo)
+	case transferOp:
+		return f.MemStorage.TransferRepo(&e.Repo)
+	case removeUserOp:
+		return f.MemStorage.RemoveUserRepos(e.UserID, e.At)
+	}
+	return file.UnknownOp("repo", e.Op)
+}
+
+// write appends the change to the journal and then applies it in memory, in the same
+// order, so a change that isn't in the journal is never served.
+func (f *FileStorage) write(e entry, apply func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.journal.Append(&e); err != nil {
+		return err
+	}
+	return apply()
+}
+
+func (f *FileStorage) SaveRepo(repo *domain.Repository) error {
+	return f.write(entry{Op: saveOp, Repo: *repo}, func() error { return f.MemStorage.SaveRepo(repo) })
+}
+
+func (f *FileStorage) UpdateRepo(repo *domain.Repository) error {
+	return f.write(entry{Op: updateOp, Repo: *repo}, func() error { return f.MemStorage.UpdateRepo(repo) })
+}
+
+// TransferRepo saves the owner of a repository, moving its usage to the new owner.
+func (f *FileStorage) TransferRepo(repo *domain.Repository) error {
+	return f.write(entry{Op: transferOp, Repo: *repo}, func() error { return f.MemStorage.TransferRepo(repo) })
+}
+
+func (f *FileStorage) RemoveUserRepos(userID kallax.ULID, at time.Time) error {
+	return f.write(entry{Op: removeUserOp, UserID: userID, At: at}, func() error {
+		return f.MemStorage.RemoveUserRepos(userID, at)
+	})
+}
+
+// Close closes the journal.
+func (f *FileStorage) Close() error { return f.journal.Close() }
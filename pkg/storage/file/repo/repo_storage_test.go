@@ -0,0 +1,63 @@
+package repo_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/file/repo"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/usage"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestFileStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "repositories")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	s, err := repo.NewFileStorage(filepath.Join(dir, "repositories.journal"), nil)
+	require.Nil(t, err)
+	defer s.Close()
+
+	storagetest.RunRepoStore(t, s)
+}
+
+func TestFileStorageReopenGivesUsageToOwners(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "repositories")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "repositories.journal")
+
+	s, err := repo.NewFileStorage(path, nil)
+	require.Nil(t, err)
+	owner, other := kallax.NewULID(), kallax.NewULID()
+	kept := &domain.Repository{ID: kallax.NewULID(), Name: "kept", UserID: owner}
+	transferred := &domain.Repository{ID: kallax.NewULID(), Name: "transferred", UserID: owner}
+	require.Nil(t, s.SaveRepo(kept))
+	require.Nil(t, s.SaveRepo(transferred))
+	transferred.UserID = other
+	require.Nil(t, s.TransferRepo(transferred))
+	require.Nil(t, s.Close())
+
+	usages := usage.NewMemStorage()
+	usages.LoadUsage(domain.Usage{RepositoryID: kept.ID, Captures: 1}, domain.Usage{RepositoryID: transferred.ID, Captures: 2})
+	s, err = repo.NewFileStorage(path, usages)
+	require.Nil(t, err)
+	defer s.Close()
+
+	u, err := usages.GetUserUsage(owner)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), u.Captures)
+	u, err = usages.GetUserUsage(other)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), u.Captures)
+}
@@ -0,0 +1,103 @@
+package user
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/file"
+	memuser "github.com/ifreddyrondon/capture/pkg/storage/memory/user"
+)
+
+const (
+	saveOp   = "save"
+	updateOp = "update"
+	removeOp = "remove"
+	stepOp   = "step"
+)
+
+type entry struct {
+	Op   string
+	User domain.User
+}
+
+// FileStorage file storage layer. Reads are served from memory.
+type FileStorage struct {
+	*memuser.MemStorage
+	mu      sync.Mutex
+	journal *file.Journal
+}
+
+// NewFileStorage opens the users journal at path and loads its users.
+func NewFileStorage(path string) (*FileStorage, error) {
+	s := &FileStorage{MemStorage: memuser.NewMemStorage()}
+	journal, err := file.OpenJournal(path, func() interface{} { return &entry{} }, file.SkipRejected(s.apply))
+	if err != nil {
+		return nil, errors.Wrap(err, "opening user filestorage")
+	}
+	s.journal = journal
+	return s, nil
+}
+
+func (f *FileStorage) apply(v interface{}) error {
+	e := v.(*entry)
+	switch e.Op {
+	case saveOp:
+		return f.MemStorage.SaveUser(&e.User)
+	case updateOp:
+		return f.MemStorage.UpdateUser(&e.User)
+	case removeOp:
+		return f.MemStorage.RemoveUser(&e.User)
+	case stepOp:
+		_, err := f.MemStorage.AdvanceTwoFactorStep(e.User.ID, e.User.TwoFactorLastStep)
+		return err
+	}
+	return file.UnknownOp("user", e.Op)
+}
+
+// write appends the change to the journal and then applies it in memory, in the same
+// order, so a change that isn't in the journal is never served.
+func (f *FileStorage) write(e entry, apply func() error) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err := f.journal.Append(&e); err != nil {
+		return err
+	}
+	return apply()
+}
+
+func (f *FileStorage) SaveUser(u *domain.User) error {
+	return f.write(entry{Op: saveOp, User: *u}, func() error { return f.MemStorage.SaveUser(u) })
+}
+
+// UpdateUser saves the changes of a user.
+func (f *FileStorage) UpdateUser(u *domain.User) error {
+	return f.write(entry{Op: updateOp, User: *u}, func() error { return f.MemStorage.UpdateUser(u) })
+}
+
+// AdvanceTwoFactorStep saves the last TOTP step used by a user when it's after the stored one.
+func (f *FileStorage) AdvanceTwoFactorStep(id kallax.ULID, step int64) (bool, error) {
+	var advanced bool
+	e := entry{Op: stepOp, User: domain.User{ID: id, TwoFactorLastStep: step}}
+	err := f.write(e, func() error {
+		var err error
+		advanced, err = f.MemStorage.AdvanceTwoFactorStep(id, step)
+		return err
+	})
+	return advanced, err
+}
+
+// RemoveUser soft deletes a user.
+func (f *FileStorage) RemoveUser(u *domain.User) error {
+	if u.DeletedAt == nil {
+		t := time.Now()
+		u.DeletedAt = &t
+	}
+	return f.write(entry{Op: removeOp, User: *u}, func() error { return f.MemStorage.RemoveUser(u) })
+}
+
+// Close closes the journal.
+func (f *FileStorage) Close() error { return f.journal.Close() }
@@ -0,0 +1,61 @@
+package user_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/file/user"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestFileStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "users")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	s, err := user.NewFileStorage(filepath.Join(dir, "users.journal"))
+	require.Nil(t, err)
+	defer s.Close()
+
+	storagetest.RunUserStore(t, s)
+}
+
+func TestFileStorageReopen(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "users")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "users.journal")
+
+	s, err := user.NewFileStorage(path)
+	require.Nil(t, err)
+	u := &domain.User{ID: kallax.NewULID(), Email: "test@example.com", Password: []byte("hash")}
+	removed := &domain.User{ID: kallax.NewULID(), Email: "removed@example.com"}
+	require.Nil(t, s.SaveUser(u))
+	require.Nil(t, s.SaveUser(removed))
+	u.Name = "test"
+	require.Nil(t, s.UpdateUser(u))
+	require.Nil(t, s.RemoveUser(removed))
+	require.Nil(t, s.Close())
+
+	s, err = user.NewFileStorage(path)
+	require.Nil(t, err)
+	defer s.Close()
+
+	got, err := s.GetUserByEmail("test@example.com")
+	require.Nil(t, err)
+	assert.Equal(t, "test", got.Name)
+	assert.Equal(t, []byte("hash"), got.Password)
+	_, err = s.GetUserByID(removed.ID)
+	assert.Error(t, err)
+	assert.Error(t, s.SaveUser(&domain.User{ID: kallax.NewULID(), Email: "removed@example.com"}))
+}
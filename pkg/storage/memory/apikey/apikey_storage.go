@@ -0,0 +1,97 @@
+package apikey
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type keyNotFound string
+
+func (u keyNotFound) Error() string  { return string(u) }
+func (u keyNotFound) NotFound() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu   sync.RWMutex
+	keys []domain.APIKey
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+func (m *MemStorage) CreateAPIKey(k *domain.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range m.keys {
+		if key.Prefix == k.Prefix {
+			return errors.Errorf("err saving api key with memstorage, duplicated prefix %s", k.Prefix)
+		}
+	}
+	m.keys = append(m.keys, *k)
+	return nil
+}
+
+func (m *MemStorage) ListAPIKeys(repoID kallax.ULID) ([]domain.APIKey, error) {
+	m.mu.RLock()
+	var keys []domain.APIKey
+	for _, k := range m.keys {
+		if k.RepositoryID == repoID {
+			keys = append(keys, k)
+		}
+	}
+	m.mu.RUnlock()
+	memory.SortBy("created_at ASC", keys, func(i int, _ string) time.Time { return keys[i].CreatedAt })
+	return keys, nil
+}
+
+func (m *MemStorage) GetAPIKey(keyID, repoID kallax.ULID) (*domain.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, k := range m.keys {
+		if k.ID == keyID && k.RepositoryID == repoID {
+			return &k, nil
+		}
+	}
+	errStr := fmt.Sprintf("api key with id %s not found in repo %v", keyID, repoID)
+	return nil, errors.WithStack(keyNotFound(errStr))
+}
+
+func (m *MemStorage) GetAPIKeyByPrefix(prefix string) (*domain.APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, k := range m.keys {
+		if k.Prefix == prefix {
+			return &k, nil
+		}
+	}
+	return nil, errors.WithStack(keyNotFound(fmt.Sprintf("api key with prefix %s not found", prefix)))
+}
+
+func (m *MemStorage) SaveAPIKey(k *domain.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, key := range m.keys {
+		if key.ID == k.ID {
+			m.keys[i] = *k
+		}
+	}
+	return nil
+}
+
+func (m *MemStorage) TouchAPIKey(k *domain.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, key := range m.keys {
+		if key.ID == k.ID {
+			m.keys[i].LastUsedAt = k.LastUsedAt
+		}
+	}
+	return nil
+}
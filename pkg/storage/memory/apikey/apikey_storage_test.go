@@ -0,0 +1,14 @@
+package apikey_test
+
+import (
+	"testing"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/apikey"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestMemStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunAPIKeyStore(t, apikey.NewMemStorage())
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+// MemStorage in memory storage layer. Entries are append only, there is no way to
+// update or delete them.
+type MemStorage struct {
+	mu      sync.RWMutex
+	entries []domain.AuditEntry
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+func (m *MemStorage) CreateAuditEntry(e *domain.AuditEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, *e)
+	return nil
+}
+
+func (m *MemStorage) ListAuditEntries(l *domain.Listing) ([]domain.AuditEntry, int64, error) {
+	m.mu.RLock()
+	var entries []domain.AuditEntry
+	for _, e := range m.entries {
+		if l.Repository != nil && (e.RepositoryID == nil || *e.RepositoryID != *l.Repository) {
+			continue
+		}
+		if l.Actor != nil && (e.ActorID == nil || *e.ActorID != *l.Actor) {
+			continue
+		}
+		if l.Action != nil && e.Action != *l.Action {
+			continue
+		}
+		if l.TargetType != nil && e.TargetType != *l.TargetType {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	m.mu.RUnlock()
+
+	memory.SortBy(l.SortKey, entries, func(i int, name string) time.Time {
+		if name == "created_at" {
+			return entries[i].CreatedAt
+		}
+		return time.Time{}
+	})
+	start, end := memory.Page(len(entries), l.Offset, l.Limit)
+	return entries[start:end], int64(len(entries)), nil
+}
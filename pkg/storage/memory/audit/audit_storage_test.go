@@ -0,0 +1,14 @@
+package audit_test
+
+import (
+	"testing"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/audit"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestMemStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunAuditStore(t, audit.NewMemStorage())
+}
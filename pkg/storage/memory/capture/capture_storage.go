@@ -0,0 +1,221 @@
+package capture
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type captureNotFound string
+
+func (u captureNotFound) Error() string  { return string(u) }
+func (u captureNotFound) NotFound() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu       sync.RWMutex
+	captures []domain.Capture
+	index    map[kallax.ULID]int
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage {
+	return &MemStorage{index: make(map[kallax.ULID]int)}
+}
+
+func (m *MemStorage) CreateCapture(c *domain.Capture) error {
+	return m.CreateCaptures(*c)
+}
+
+func (m *MemStorage) CreateCaptures(captures ...domain.Capture) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range captures {
+		if _, ok := m.index[c.ID]; ok {
+			return errors.Errorf("err saving captures with memstorage, duplicated id %s", c.ID)
+		}
+	}
+	for _, c := range captures {
+		m.index[c.ID] = len(m.captures)
+		m.captures = append(m.captures, c)
+	}
+	return nil
+}
+
+func (m *MemStorage) List(l *domain.Listing) ([]domain.Capture, int64, error) {
+	m.mu.RLock()
+	var captures []domain.Capture
+	for _, c := range m.captures {
+		if c.DeletedAt != nil {
+			continue
+		}
+		if l.Owner != nil && c.RepositoryID != *l.Owner {
+			continue
+		}
+		if len(l.Area) > 0 && !l.Area.Contains(c.Location) {
+			continue
+		}
+		if l.Near != nil && !c.Location.Within(*l.Near, l.Radius) {
+			continue
+		}
+		captures = append(captures, c)
+	}
+	m.mu.RUnlock()
+
+	memory.SortBy(l.SortKey, captures, func(i int, name string) time.Time {
+		switch name {
+		case "timestamp":
+			return captures[i].Timestamp
+		case "created_at":
+			return captures[i].CreatedAt
+		case "updated_at":
+			return captures[i].UpdatedAt
+		}
+		return time.Time{}
+	})
+	start, end := memory.Page(len(captures), l.Offset, l.Limit)
+	return captures[start:end], int64(len(captures)), nil
+}
+
+func (m *MemStorage) Get(captureID, repoID kallax.ULID) (*domain.Capture, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if i, ok := m.index[captureID]; ok {
+		c := m.captures[i]
+		if c.RepositoryID == repoID && c.DeletedAt == nil {
+			return &c, nil
+		}
+	}
+	errStr := fmt.Sprintf("capture with id %s not found in repo %v", captureID, repoID)
+	return nil, errors.WithStack(captureNotFound(errStr))
+}
+
+func (m *MemStorage) Save(capt *domain.Capture) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	i, ok := m.index[capt.ID]
+	if !ok || m.captures[i].DeletedAt != nil {
+		errStr := fmt.Sprintf("capture with id %s not found in repo %v", capt.ID, capt.RepositoryID)
+		return errors.WithStack(captureNotFound(errStr))
+	}
+	m.captures[i] = *capt
+	return nil
+}
+
+func (m *MemStorage) ExpiredCaptures(repoID kallax.ULID, before time.Time, limit int) ([]domain.Capture, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var captures []domain.Capture
+	for _, c := range m.captures {
+		if len(captures) == limit {
+			break
+		}
+		if c.DeletedAt == nil && c.RepositoryID == repoID && c.Timestamp.Before(before) {
+			captures = append(captures, c)
+		}
+	}
+	return captures, nil
+}
+
+func (m *MemStorage) DeleteCaptures(repoID kallax.ULID, before time.Time, ids ...kallax.ULID) (int64, error) {
+	deleted := make(map[kallax.ULID]bool, len(ids))
+	for _, id := range ids {
+		deleted[id] = true
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.remove(func(c domain.Capture) bool {
+		return deleted[c.ID] && c.RepositoryID == repoID && c.Timestamp.Before(before)
+	}), nil
+}
+
+func (m *MemStorage) PurgeCaptures(repoID kallax.ULID, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.remove(func(c domain.Capture) bool {
+		return c.DeletedAt != nil && c.RepositoryID == repoID && c.Timestamp.Before(before)
+	}), nil
+}
+
+func (m *MemStorage) RollupCandidates(repoID kallax.ULID, before time.Time, resolution int64, limit int) ([]domain.Capture, error) {
+	m.mu.RLock()
+	var captures []domain.Capture
+	for _, c := range m.captures {
+		if c.DeletedAt == nil && c.RepositoryID == repoID && c.Timestamp.Before(before) && c.Resolution() < resolution {
+			captures = append(captures, c)
+		}
+	}
+	m.mu.RUnlock()
+
+	memory.SortBy("timestamp ASC", captures, func(i int, _ string) time.Time { return captures[i].Timestamp })
+	start, end := memory.Page(len(captures), 0, limit)
+	return captures[start:end], nil
+}
+
+func (m *MemStorage) ReplaceCaptures(captures []domain.Capture, rollups []domain.Capture) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, c := range rollups {
+		if _, ok := m.index[c.ID]; ok {
+			return errors.Errorf("err saving captures with memstorage, duplicated id %s", c.ID)
+		}
+	}
+	replaced := make(map[kallax.ULID]bool, len(captures))
+	for _, c := range captures {
+		if _, ok := m.index[c.ID]; !ok {
+			return errors.WithStack(captureNotFound(fmt.Sprintf("err replacing captures with memstorage, capture %s not found", c.ID)))
+		}
+		replaced[c.ID] = true
+	}
+	m.remove(func(c domain.Capture) bool { return replaced[c.ID] })
+	for _, c := range rollups {
+		m.index[c.ID] = len(m.captures)
+		m.captures = append(m.captures, c)
+	}
+	return nil
+}
+
+// Usages returns the usage of the captures of each repository, without their owners.
+func (m *MemStorage) Usages() []domain.Usage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var usages []domain.Usage
+	index := make(map[kallax.ULID]int)
+	for _, c := range m.captures {
+		if c.DeletedAt != nil {
+			continue
+		}
+		i, ok := index[c.RepositoryID]
+		if !ok {
+			i = len(usages)
+			index[c.RepositoryID] = i
+			usages = append(usages, domain.Usage{RepositoryID: c.RepositoryID})
+		}
+		usages[i].Captures++
+		usages[i].Bytes += domain.CaptureSize(c)
+	}
+	return usages
+}
+
+// remove deletes permanently the captures matching fn, returning how many were deleted.
+// It must be called holding the lock.
+func (m *MemStorage) remove(fn func(domain.Capture) bool) int64 {
+	kept := m.captures[:0]
+	for _, c := range m.captures {
+		if fn(c) {
+			delete(m.index, c.ID)
+			continue
+		}
+		m.index[c.ID] = len(kept)
+		kept = append(kept, c)
+	}
+	removed := int64(len(m.captures) - len(kept))
+	m.captures = kept
+	return removed
+}
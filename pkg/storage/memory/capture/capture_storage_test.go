@@ -0,0 +1,249 @@
+package capture_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/capture"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func newCapture(repoID kallax.ULID, timestamp time.Time) domain.Capture {
+	return domain.Capture{
+		ID:           kallax.NewULID(),
+		Timestamp:    timestamp,
+		CreatedAt:    timestamp,
+		UpdatedAt:    timestamp,
+		RepositoryID: repoID,
+	}
+}
+
+func TestMemStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunCaptureStore(t, capture.NewMemStorage())
+}
+
+func TestMemStorageListCaptures(t *testing.T) {
+	t.Parallel()
+
+	s := capture.NewMemStorage()
+	repoID, otherRepoID := kallax.NewULID(), kallax.NewULID()
+	now := time.Now()
+	c1, c2, c3 := newCapture(repoID, now), newCapture(repoID, now.Add(time.Minute)), newCapture(repoID, now.Add(time.Hour))
+	require.Nil(t, s.CreateCaptures(c1, c2, c3, newCapture(otherRepoID, now)))
+
+	tt := []struct {
+		name     string
+		listing  domain.Listing
+		expected []kallax.ULID
+	}{
+		{
+			"timestamp descending",
+			domain.Listing{Owner: &repoID, SortKey: "timestamp DESC", Limit: 50},
+			[]kallax.ULID{c3.ID, c2.ID, c1.ID},
+		},
+		{
+			"timestamp ascendant paged",
+			domain.Listing{Owner: &repoID, SortKey: "timestamp ASC", Offset: 1, Limit: 1},
+			[]kallax.ULID{c2.ID},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			captures, total, err := s.List(&tc.listing)
+			require.Nil(t, err)
+			assert.Equal(t, int64(3), total)
+			var ids []kallax.ULID
+			for _, c := range captures {
+				ids = append(ids, c.ID)
+			}
+			assert.Equal(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestMemStorageRemovedCaptureNotFound(t *testing.T) {
+	t.Parallel()
+
+	s := capture.NewMemStorage()
+	repoID := kallax.NewULID()
+	c := newCapture(repoID, time.Now())
+	require.Nil(t, s.CreateCapture(&c))
+
+	got, err := s.Get(c.ID, repoID)
+	require.Nil(t, err)
+	deletedAt := time.Now()
+	got.DeletedAt = &deletedAt
+	require.Nil(t, s.Save(got))
+
+	_, err = s.Get(c.ID, repoID)
+	assert.EqualError(t, err, "capture with id "+c.ID.String()+" not found in repo "+repoID.String())
+	notFound, ok := errors.Cause(err).(interface{ NotFound() bool })
+	require.True(t, ok)
+	assert.True(t, notFound.NotFound())
+
+	_, total, err := s.List(&domain.Listing{Owner: &repoID})
+	require.Nil(t, err)
+	assert.Equal(t, int64(0), total)
+}
+
+func TestMemStorageGetCaptureOfOtherRepo(t *testing.T) {
+	t.Parallel()
+
+	s := capture.NewMemStorage()
+	c := newCapture(kallax.NewULID(), time.Now())
+	require.Nil(t, s.CreateCapture(&c))
+
+	_, err := s.Get(c.ID, kallax.NewULID())
+	assert.Error(t, err)
+}
+
+func TestMemStorageListCapturesByLocation(t *testing.T) {
+	t.Parallel()
+
+	s := capture.NewMemStorage()
+	repoID := kallax.NewULID()
+	at := func(lat, lng float64) domain.Capture {
+		c := newCapture(repoID, time.Now())
+		c.Location = &domain.Point{LAT: &lat, LNG: &lng}
+		return c
+	}
+	inside, outside, missing := at(1, 1), at(20, 20), newCapture(repoID, time.Now())
+	require.Nil(t, s.CreateCaptures(inside, outside, missing))
+	zero, ten := 0.0, 10.0
+	area := domain.Polygon{{LAT: &zero, LNG: &zero}, {LAT: &zero, LNG: &ten}, {LAT: &ten, LNG: &ten}, {LAT: &ten, LNG: &zero}}
+
+	tt := []struct {
+		name    string
+		listing domain.Listing
+	}{
+		{"inside area", domain.Listing{Owner: &repoID, Area: area}},
+		{"near point", domain.Listing{Owner: &repoID, Near: &domain.Point{LAT: &zero, LNG: &zero}, Radius: 200000}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			captures, total, err := s.List(&tc.listing)
+			require.Nil(t, err)
+			assert.Equal(t, int64(1), total)
+			require.Len(t, captures, 1)
+			assert.Equal(t, inside.ID, captures[0].ID)
+		})
+	}
+}
+
+func TestMemStorageDeleteExpiredCaptures(t *testing.T) {
+	t.Parallel()
+
+	s := capture.NewMemStorage()
+	repoID, otherRepoID := kallax.NewULID(), kallax.NewULID()
+	now := time.Now()
+	old, removed, recent := newCapture(repoID, now.Add(-time.Hour)), newCapture(repoID, now.Add(-time.Hour)), newCapture(repoID, now)
+	other := newCapture(otherRepoID, now.Add(-time.Hour))
+	require.Nil(t, s.CreateCaptures(old, removed, recent, other))
+	removed.DeletedAt = &now
+	require.Nil(t, s.Save(&removed))
+
+	expired, err := s.ExpiredCaptures(repoID, now.Add(-time.Minute), 10)
+	require.Nil(t, err)
+	require.Len(t, expired, 1)
+	assert.Equal(t, old.ID, expired[0].ID)
+
+	deleted, err := s.DeleteCaptures(repoID, now.Add(-time.Minute), old.ID, recent.ID, other.ID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+	purged, err := s.PurgeCaptures(repoID, now.Add(-time.Minute))
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	_, err = s.Get(old.ID, repoID)
+	assert.Error(t, err)
+	_, err = s.Get(recent.ID, repoID)
+	assert.Nil(t, err)
+	_, err = s.Get(other.ID, otherRepoID)
+	assert.Nil(t, err)
+	_, total, err := s.List(&domain.Listing{})
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+}
+
+func TestMemStorageReplaceCapturesByRollups(t *testing.T) {
+	t.Parallel()
+
+	s := capture.NewMemStorage()
+	repoID := kallax.NewULID()
+	now := time.Now()
+	first, second, recent := newCapture(repoID, now.Add(-2*time.Hour)), newCapture(repoID, now.Add(-time.Hour)), newCapture(repoID, now)
+	require.Nil(t, s.CreateCaptures(second, recent, first))
+
+	candidates, err := s.RollupCandidates(repoID, now.Add(-time.Minute), 60, 10)
+	require.Nil(t, err)
+	require.Len(t, candidates, 2)
+	assert.Equal(t, first.ID, candidates[0].ID)
+	assert.Equal(t, second.ID, candidates[1].ID)
+
+	rollup := newCapture(repoID, now.Add(-2*time.Hour))
+	rollup.Rollup = &domain.Rollup{Resolution: 60, Aggregate: domain.AvgAggregate, Samples: 2}
+	require.Nil(t, s.ReplaceCaptures(candidates, []domain.Capture{rollup}))
+
+	candidates, err = s.RollupCandidates(repoID, now.Add(-time.Minute), 60, 10)
+	require.Nil(t, err)
+	assert.Len(t, candidates, 0)
+	candidates, err = s.RollupCandidates(repoID, now.Add(-time.Minute), 3600, 10)
+	require.Nil(t, err)
+	assert.Len(t, candidates, 1)
+
+	captures, total, err := s.List(&domain.Listing{SortKey: "timestamp ASC"})
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), total)
+	assert.Equal(t, rollup.ID, captures[0].ID)
+	assert.Equal(t, recent.ID, captures[1].ID)
+}
+
+func TestMemStorageReplaceRemovedCaptures(t *testing.T) {
+	t.Parallel()
+
+	s := capture.NewMemStorage()
+	repoID := kallax.NewULID()
+	now := time.Now()
+	c1, c2 := newCapture(repoID, now), newCapture(repoID, now)
+	require.Nil(t, s.CreateCaptures(c1, c2))
+	require.Nil(t, s.ReplaceCaptures([]domain.Capture{c1}, nil))
+
+	rollup := newCapture(repoID, now)
+	err := s.ReplaceCaptures([]domain.Capture{c1, c2}, []domain.Capture{rollup})
+	notFound, ok := errors.Cause(err).(interface{ NotFound() bool })
+	require.True(t, ok)
+	assert.True(t, notFound.NotFound())
+
+	_, err = s.Get(c2.ID, repoID)
+	assert.Nil(t, err)
+	_, err = s.Get(rollup.ID, repoID)
+	assert.Error(t, err)
+}
+
+func TestMemStorageUsages(t *testing.T) {
+	t.Parallel()
+
+	s := capture.NewMemStorage()
+	repo1, repo2 := kallax.NewULID(), kallax.NewULID()
+	now := time.Now()
+	c1, c2, c3 := newCapture(repo1, now), newCapture(repo1, now), newCapture(repo2, now)
+	removed := newCapture(repo2, now)
+	removed.DeletedAt = &now
+	require.Nil(t, s.CreateCaptures(c1, c2, c3, removed))
+
+	expected := []domain.Usage{
+		{RepositoryID: repo1, Captures: 2, Bytes: domain.CaptureSize(c1) + domain.CaptureSize(c2)},
+		{RepositoryID: repo2, Captures: 1, Bytes: domain.CaptureSize(c3)},
+	}
+	assert.Equal(t, expected, s.Usages())
+}
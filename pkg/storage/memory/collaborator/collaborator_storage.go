@@ -0,0 +1,116 @@
+package collaborator
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type collaboratorNotFound string
+
+func (u collaboratorNotFound) Error() string  { return string(u) }
+func (u collaboratorNotFound) NotFound() bool { return true }
+
+type uniqueConstraintErr string
+
+func (u uniqueConstraintErr) Error() string          { return string(u) }
+func (u uniqueConstraintErr) UniqueConstraint() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu            sync.RWMutex
+	collaborators []domain.Collaborator
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+func (m *MemStorage) CreateCollaborator(c *domain.Collaborator) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, collaborator := range m.collaborators {
+		if collaborator.RepositoryID == c.RepositoryID && collaborator.UserID == c.UserID {
+			errStr := fmt.Sprintf("duplicate key value violates unique constraint, user %s in repo %v", c.UserID, c.RepositoryID)
+			return errors.WithStack(uniqueConstraintErr(errStr))
+		}
+	}
+	m.collaborators = append(m.collaborators, *c)
+	return nil
+}
+
+func (m *MemStorage) list(match func(domain.Collaborator) bool) []domain.Collaborator {
+	m.mu.RLock()
+	var collaborators []domain.Collaborator
+	for _, c := range m.collaborators {
+		if match(c) {
+			collaborators = append(collaborators, c)
+		}
+	}
+	m.mu.RUnlock()
+	memory.SortBy("created_at ASC", collaborators, func(i int, _ string) time.Time {
+		return collaborators[i].CreatedAt
+	})
+	return collaborators
+}
+
+func (m *MemStorage) ListCollaborators(repoID kallax.ULID) ([]domain.Collaborator, error) {
+	return m.list(func(c domain.Collaborator) bool { return c.RepositoryID == repoID }), nil
+}
+
+func (m *MemStorage) GetCollaborator(id, repoID kallax.ULID) (*domain.Collaborator, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, c := range m.collaborators {
+		if c.ID == id && c.RepositoryID == repoID {
+			return &c, nil
+		}
+	}
+	errStr := fmt.Sprintf("collaborator with id %s not found in repo %v", id, repoID)
+	return nil, errors.WithStack(collaboratorNotFound(errStr))
+}
+
+func (m *MemStorage) GetRepoCollaborator(repoID, userID kallax.ULID) (*domain.Collaborator, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, c := range m.collaborators {
+		if c.RepositoryID == repoID && c.UserID == userID {
+			return &c, nil
+		}
+	}
+	errStr := fmt.Sprintf("user %s is not a collaborator of repo %v", userID, repoID)
+	return nil, errors.WithStack(collaboratorNotFound(errStr))
+}
+
+func (m *MemStorage) ListInvitations(userID kallax.ULID) ([]domain.Collaborator, error) {
+	return m.list(func(c domain.Collaborator) bool { return c.UserID == userID && c.AcceptedAt == nil }), nil
+}
+
+func (m *MemStorage) SaveCollaborator(c *domain.Collaborator) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, collaborator := range m.collaborators {
+		if collaborator.ID == c.ID {
+			m.collaborators[i] = *c
+		}
+	}
+	return nil
+}
+
+func (m *MemStorage) RemoveCollaborator(c *domain.Collaborator) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	collaborators := m.collaborators[:0]
+	for _, collaborator := range m.collaborators {
+		if collaborator.ID != c.ID {
+			collaborators = append(collaborators, collaborator)
+		}
+	}
+	m.collaborators = collaborators
+	return nil
+}
@@ -0,0 +1,14 @@
+package collaborator_test
+
+import (
+	"testing"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/collaborator"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestMemStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunCollaboratorStore(t, collaborator.NewMemStorage())
+}
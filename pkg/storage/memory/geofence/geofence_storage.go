@@ -0,0 +1,108 @@
+package geofence
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type geofenceNotFound string
+
+func (u geofenceNotFound) Error() string  { return string(u) }
+func (u geofenceNotFound) NotFound() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu        sync.RWMutex
+	geofences []domain.Geofence
+	events    []domain.GeofenceEvent
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+func (m *MemStorage) CreateGeofence(g *domain.Geofence) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.geofences = append(m.geofences, *g)
+	return nil
+}
+
+func (m *MemStorage) ListGeofences(repoID kallax.ULID) ([]domain.Geofence, error) {
+	m.mu.RLock()
+	var geofences []domain.Geofence
+	for _, g := range m.geofences {
+		if g.RepositoryID == repoID && g.DeletedAt == nil {
+			geofences = append(geofences, g)
+		}
+	}
+	m.mu.RUnlock()
+	memory.SortBy("created_at ASC", geofences, func(i int, _ string) time.Time { return geofences[i].CreatedAt })
+	return geofences, nil
+}
+
+func (m *MemStorage) GetGeofence(geofenceID, repoID kallax.ULID) (*domain.Geofence, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, g := range m.geofences {
+		if g.ID == geofenceID && g.RepositoryID == repoID && g.DeletedAt == nil {
+			return &g, nil
+		}
+	}
+	errStr := fmt.Sprintf("geofence with id %s not found in repo %v", geofenceID, repoID)
+	return nil, errors.WithStack(geofenceNotFound(errStr))
+}
+
+func (m *MemStorage) SaveGeofence(g *domain.Geofence) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, geofence := range m.geofences {
+		if geofence.ID == g.ID && geofence.DeletedAt == nil {
+			m.geofences[i] = *g
+		}
+	}
+	return nil
+}
+
+func (m *MemStorage) RecordGeofenceEvent(e domain.GeofenceEvent) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	inside := e.Type == domain.Enter
+	for i, geofence := range m.geofences {
+		if geofence.ID == e.GeofenceID && geofence.DeletedAt == nil && geofence.Inside != inside {
+			m.geofences[i].Inside = inside
+			m.events = append(m.events, e)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemStorage) ListGeofenceEvents(l *domain.Listing) ([]domain.GeofenceEvent, int64, error) {
+	m.mu.RLock()
+	var events []domain.GeofenceEvent
+	for _, e := range m.events {
+		if l.Owner == nil || e.GeofenceID == *l.Owner {
+			events = append(events, e)
+		}
+	}
+	m.mu.RUnlock()
+
+	memory.SortBy(l.SortKey, events, func(i int, name string) time.Time {
+		switch name {
+		case "timestamp":
+			return events[i].Timestamp
+		case "created_at":
+			return events[i].CreatedAt
+		}
+		return time.Time{}
+	})
+	start, end := memory.Page(len(events), l.Offset, l.Limit)
+	return events[start:end], int64(len(events)), nil
+}
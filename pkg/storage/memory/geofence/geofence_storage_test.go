@@ -0,0 +1,14 @@
+package geofence_test
+
+import (
+	"testing"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/geofence"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestMemStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunGeofenceStore(t, geofence.NewMemStorage())
+}
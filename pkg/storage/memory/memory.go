@@ -0,0 +1,50 @@
+// Package memory holds the helpers shared by the in memory storages. They mirror the
+// postgres storages, keeping the records in the instance, so the server runs without a
+// database in development and tests.
+package memory
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+// SortBy sorts the records following a listing SortKey with the form "column DIR".
+// column returns the value of the named column of the i record. The sort is stable, so
+// records with the same value keep the insertion order.
+func SortBy(sortKey string, records interface{}, column func(i int, name string) time.Time) {
+	fields := strings.Fields(sortKey)
+	if len(fields) == 0 {
+		return
+	}
+	name := fields[0]
+	desc := len(fields) > 1 && strings.EqualFold(fields[1], "DESC")
+	sort.SliceStable(records, func(i, j int) bool {
+		if desc {
+			return column(i, name).After(column(j, name))
+		}
+		return column(i, name).Before(column(j, name))
+	})
+}
+
+// Page returns the bounds of the page of n records given the offset and limit of a
+// listing. A limit of 0 takes every record after the offset.
+func Page(n int, offset int64, limit int) (int, int) {
+	start := int(offset)
+	if start < 0 {
+		start = 0
+	}
+	if start > n {
+		start = n
+	}
+	end := n
+	if limit > 0 && start+limit < n {
+		end = start + limit
+	}
+	return start, end
+}
+
+// Contains reports whether the text contains the substring ignoring the case, like ILIKE.
+func Contains(text, substr string) bool {
+	return strings.Contains(strings.ToLower(text), strings.ToLower(substr))
+}
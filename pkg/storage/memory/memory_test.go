@@ -0,0 +1,71 @@
+package memory_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+func TestSortBy(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tt := []struct {
+		name     string
+		sortKey  string
+		expected []int
+	}{
+		{"no sort key keeps the order", "", []int{2, 0, 1}},
+		{"ascendant", "created_at ASC", []int{0, 1, 2}},
+		{"descending", "created_at DESC", []int{2, 1, 0}},
+		{"without direction is ascendant", "created_at", []int{0, 1, 2}},
+		{"unknown column keeps the order", "name ASC", []int{2, 0, 1}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			records := []int{2, 0, 1}
+			memory.SortBy(tc.sortKey, records, func(i int, name string) time.Time {
+				if name != "created_at" {
+					return time.Time{}
+				}
+				return now.Add(time.Duration(records[i]) * time.Second)
+			})
+			assert.Equal(t, tc.expected, records)
+		})
+	}
+}
+
+func TestPage(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name       string
+		offset     int64
+		limit      int
+		start, end int
+	}{
+		{"first page", 0, 2, 0, 2},
+		{"last page", 4, 2, 4, 5},
+		{"offset out of range", 10, 2, 5, 5},
+		{"without limit", 1, 0, 1, 5},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end := memory.Page(5, tc.offset, tc.limit)
+			assert.Equal(t, tc.start, start)
+			assert.Equal(t, tc.end, end)
+		})
+	}
+}
+
+func TestContains(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, memory.Contains("Test@Example.com", "example"))
+	assert.False(t, memory.Contains("test@example.com", "other"))
+}
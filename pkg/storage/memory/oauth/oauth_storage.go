@@ -0,0 +1,163 @@
+package oauth
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type oauthNotFound string
+
+func (u oauthNotFound) Error() string  { return string(u) }
+func (u oauthNotFound) NotFound() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu       sync.RWMutex
+	clients  []domain.OAuthClient
+	codes    []domain.OAuthCode
+	consents []domain.OAuthConsent
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+func (m *MemStorage) CreateOAuthClient(c *domain.OAuthClient) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.clients = append(m.clients, *c)
+	return nil
+}
+
+func (m *MemStorage) ListOAuthClients(userID kallax.ULID) ([]domain.OAuthClient, error) {
+	m.mu.RLock()
+	var clients []domain.OAuthClient
+	for _, c := range m.clients {
+		if c.UserID == userID {
+			clients = append(clients, c)
+		}
+	}
+	m.mu.RUnlock()
+	memory.SortBy("created_at ASC", clients, func(i int, _ string) time.Time { return clients[i].CreatedAt })
+	return clients, nil
+}
+
+func (m *MemStorage) GetOAuthClient(id kallax.ULID) (*domain.OAuthClient, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, c := range m.clients {
+		if c.ID == id {
+			return &c, nil
+		}
+	}
+	return nil, errors.WithStack(oauthNotFound(fmt.Sprintf("oauth client with id %s not found", id)))
+}
+
+func (m *MemStorage) RemoveOAuthClient(c *domain.OAuthClient) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	codes := m.codes[:0]
+	for _, code := range m.codes {
+		if code.ClientID != c.ID {
+			codes = append(codes, code)
+		}
+	}
+	m.codes = codes
+	consents := m.consents[:0]
+	for _, consent := range m.consents {
+		if consent.ClientID != c.ID {
+			consents = append(consents, consent)
+		}
+	}
+	m.consents = consents
+	clients := m.clients[:0]
+	for _, client := range m.clients {
+		if client.ID != c.ID {
+			clients = append(clients, client)
+		}
+	}
+	m.clients = clients
+	return nil
+}
+
+func (m *MemStorage) CreateOAuthCode(c *domain.OAuthCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, code := range m.codes {
+		if code.Hash == c.Hash {
+			return errors.New("err saving oauth code with memstorage, duplicated hash")
+		}
+	}
+	m.codes = append(m.codes, *c)
+	return nil
+}
+
+func (m *MemStorage) ConsumeOAuthCode(hash string, at time.Time) (*domain.OAuthCode, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, c := range m.codes {
+		if c.Hash == hash && c.UsedAt == nil && c.ExpiresAt.After(at) {
+			m.codes[i].UsedAt = &at
+			c.UsedAt = &at
+			return &c, nil
+		}
+	}
+	return nil, errors.WithStack(oauthNotFound("oauth code not found"))
+}
+
+func (m *MemStorage) GetOAuthConsent(userID, clientID kallax.ULID) (*domain.OAuthConsent, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, c := range m.consents {
+		if c.UserID == userID && c.ClientID == clientID {
+			return &c, nil
+		}
+	}
+	return nil, errors.WithStack(oauthNotFound(fmt.Sprintf("oauth consent to client %s not found", clientID)))
+}
+
+func (m *MemStorage) SaveOAuthConsent(c *domain.OAuthConsent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, consent := range m.consents {
+		if consent.UserID == c.UserID && consent.ClientID == c.ClientID {
+			m.consents[i].Scopes = c.Scopes
+			m.consents[i].UpdatedAt = c.UpdatedAt
+			return nil
+		}
+	}
+	m.consents = append(m.consents, *c)
+	return nil
+}
+
+func (m *MemStorage) ListOAuthConsents(userID kallax.ULID) ([]domain.OAuthConsent, error) {
+	m.mu.RLock()
+	var consents []domain.OAuthConsent
+	for _, c := range m.consents {
+		if c.UserID == userID {
+			consents = append(consents, c)
+		}
+	}
+	m.mu.RUnlock()
+	memory.SortBy("created_at ASC", consents, func(i int, _ string) time.Time { return consents[i].CreatedAt })
+	return consents, nil
+}
+
+func (m *MemStorage) RemoveOAuthConsent(userID, clientID kallax.ULID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	consents := m.consents[:0]
+	for _, c := range m.consents {
+		if c.UserID != userID || c.ClientID != clientID {
+			consents = append(consents, c)
+		}
+	}
+	m.consents = consents
+	return nil
+}
@@ -0,0 +1,14 @@
+package oauth_test
+
+import (
+	"testing"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/oauth"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestMemStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunOAuthStore(t, oauth.NewMemStorage())
+}
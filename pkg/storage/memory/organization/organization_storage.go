@@ -0,0 +1,259 @@
+package organization
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type organizationNotFound string
+
+func (u organizationNotFound) Error() string  { return string(u) }
+func (u organizationNotFound) NotFound() bool { return true }
+
+type uniqueConstraintErr string
+
+func (u uniqueConstraintErr) Error() string          { return string(u) }
+func (u uniqueConstraintErr) UniqueConstraint() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu          sync.RWMutex
+	orgs        []domain.Organization
+	members     []domain.Member
+	teams       []domain.Team
+	teamMembers []domain.TeamMember
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+func (m *MemStorage) memberExists(orgID, userID kallax.ULID) bool {
+	for _, member := range m.members {
+		if member.OrganizationID == orgID && member.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemStorage) CreateOrganization(org *domain.Organization, owner *domain.Member) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, o := range m.orgs {
+		if o.Name == org.Name {
+			errStr := fmt.Sprintf("duplicate key value violates unique constraint, organization %s", org.Name)
+			return errors.WithStack(uniqueConstraintErr(errStr))
+		}
+	}
+	m.orgs = append(m.orgs, *org)
+	m.members = append(m.members, *owner)
+	return nil
+}
+
+func (m *MemStorage) ListUserOrganizations(userID kallax.ULID) ([]domain.Organization, error) {
+	m.mu.RLock()
+	var orgs []domain.Organization
+	for _, o := range m.orgs {
+		if m.memberExists(o.ID, userID) {
+			orgs = append(orgs, o)
+		}
+	}
+	m.mu.RUnlock()
+	sort.SliceStable(orgs, func(i, j int) bool { return orgs[i].Name < orgs[j].Name })
+	return orgs, nil
+}
+
+func (m *MemStorage) GetOrganization(id kallax.ULID) (*domain.Organization, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, o := range m.orgs {
+		if o.ID == id {
+			return &o, nil
+		}
+	}
+	return nil, errors.WithStack(organizationNotFound(fmt.Sprintf("organization with id %s not found", id)))
+}
+
+func (m *MemStorage) CreateMember(member *domain.Member) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.memberExists(member.OrganizationID, member.UserID) {
+		errStr := fmt.Sprintf("duplicate key value violates unique constraint, user %s in organization %v", member.UserID, member.OrganizationID)
+		return errors.WithStack(uniqueConstraintErr(errStr))
+	}
+	m.members = append(m.members, *member)
+	return nil
+}
+
+func (m *MemStorage) ListMembers(orgID kallax.ULID) ([]domain.Member, error) {
+	m.mu.RLock()
+	var members []domain.Member
+	for _, member := range m.members {
+		if member.OrganizationID == orgID {
+			members = append(members, member)
+		}
+	}
+	m.mu.RUnlock()
+	memory.SortBy("created_at ASC", members, func(i int, _ string) time.Time { return members[i].CreatedAt })
+	return members, nil
+}
+
+func (m *MemStorage) GetMember(id, orgID kallax.ULID) (*domain.Member, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, member := range m.members {
+		if member.ID == id && member.OrganizationID == orgID {
+			return &member, nil
+		}
+	}
+	errStr := fmt.Sprintf("member with id %s not found in organization %v", id, orgID)
+	return nil, errors.WithStack(organizationNotFound(errStr))
+}
+
+func (m *MemStorage) GetUserMember(orgID, userID kallax.ULID) (*domain.Member, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, member := range m.members {
+		if member.OrganizationID == orgID && member.UserID == userID {
+			return &member, nil
+		}
+	}
+	errStr := fmt.Sprintf("user %s is not a member of organization %v", userID, orgID)
+	return nil, errors.WithStack(organizationNotFound(errStr))
+}
+
+func (m *MemStorage) RemoveMember(member *domain.Member) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	teams := make(map[kallax.ULID]bool)
+	for _, t := range m.teams {
+		if t.OrganizationID == member.OrganizationID {
+			teams[t.ID] = true
+		}
+	}
+	teamMembers := m.teamMembers[:0]
+	for _, tm := range m.teamMembers {
+		if tm.UserID != member.UserID || !teams[tm.TeamID] {
+			teamMembers = append(teamMembers, tm)
+		}
+	}
+	m.teamMembers = teamMembers
+	members := m.members[:0]
+	for _, mm := range m.members {
+		if mm.ID != member.ID {
+			members = append(members, mm)
+		}
+	}
+	m.members = members
+	return nil
+}
+
+func (m *MemStorage) CreateTeam(t *domain.Team) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, team := range m.teams {
+		if team.OrganizationID == t.OrganizationID && team.Name == t.Name {
+			errStr := fmt.Sprintf("duplicate key value violates unique constraint, team %s in organization %v", t.Name, t.OrganizationID)
+			return errors.WithStack(uniqueConstraintErr(errStr))
+		}
+	}
+	m.teams = append(m.teams, *t)
+	return nil
+}
+
+func (m *MemStorage) ListTeams(orgID kallax.ULID) ([]domain.Team, error) {
+	m.mu.RLock()
+	var teams []domain.Team
+	for _, t := range m.teams {
+		if t.OrganizationID == orgID {
+			teams = append(teams, t)
+		}
+	}
+	m.mu.RUnlock()
+	sort.SliceStable(teams, func(i, j int) bool { return teams[i].Name < teams[j].Name })
+	return teams, nil
+}
+
+func (m *MemStorage) GetTeam(id, orgID kallax.ULID) (*domain.Team, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, t := range m.teams {
+		if t.ID == id && t.OrganizationID == orgID {
+			return &t, nil
+		}
+	}
+	errStr := fmt.Sprintf("team with id %s not found in organization %v", id, orgID)
+	return nil, errors.WithStack(organizationNotFound(errStr))
+}
+
+func (m *MemStorage) RemoveTeam(t *domain.Team) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	teamMembers := m.teamMembers[:0]
+	for _, tm := range m.teamMembers {
+		if tm.TeamID != t.ID {
+			teamMembers = append(teamMembers, tm)
+		}
+	}
+	m.teamMembers = teamMembers
+	teams := m.teams[:0]
+	for _, team := range m.teams {
+		if team.ID != t.ID {
+			teams = append(teams, team)
+		}
+	}
+	m.teams = teams
+	return nil
+}
+
+func (m *MemStorage) CreateTeamMember(tm *domain.TeamMember) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, member := range m.teamMembers {
+		if member.TeamID == tm.TeamID && member.UserID == tm.UserID {
+			errStr := fmt.Sprintf("duplicate key value violates unique constraint, user %s in team %v", tm.UserID, tm.TeamID)
+			return errors.WithStack(uniqueConstraintErr(errStr))
+		}
+	}
+	m.teamMembers = append(m.teamMembers, *tm)
+	return nil
+}
+
+func (m *MemStorage) RemoveTeamMember(teamID, userID kallax.ULID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, tm := range m.teamMembers {
+		if tm.TeamID == teamID && tm.UserID == userID {
+			m.teamMembers = append(m.teamMembers[:i], m.teamMembers[i+1:]...)
+			return nil
+		}
+	}
+	errStr := fmt.Sprintf("user %s is not a member of team %v", userID, teamID)
+	return errors.WithStack(organizationNotFound(errStr))
+}
+
+func (m *MemStorage) ListUserTeams(orgID, userID kallax.ULID) ([]domain.Team, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var teams []domain.Team
+	for _, t := range m.teams {
+		if t.OrganizationID != orgID {
+			continue
+		}
+		for _, tm := range m.teamMembers {
+			if tm.TeamID == t.ID && tm.UserID == userID {
+				teams = append(teams, t)
+				break
+			}
+		}
+	}
+	return teams, nil
+}
@@ -0,0 +1,119 @@
+package repo
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type repoNotFound string
+
+func (u repoNotFound) Error() string  { return string(u) }
+func (u repoNotFound) NotFound() bool { return true }
+
+// UsageTransferer moves the usage of a repository when it's transferred.
+type UsageTransferer interface {
+	TransferUsage(repoID, userID kallax.ULID)
+}
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu     sync.RWMutex
+	repos  []domain.Repository
+	usages UsageTransferer
+}
+
+// NewMemStorage creates a new instance of MemStorage, usages could be nil.
+func NewMemStorage(usages UsageTransferer) *MemStorage { return &MemStorage{usages: usages} }
+
+func (m *MemStorage) SaveRepo(repo *domain.Repository) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.repos = append(m.repos, *repo)
+	return nil
+}
+
+func (m *MemStorage) List(l *domain.Listing) ([]domain.Repository, int64, error) {
+	m.mu.RLock()
+	var repos []domain.Repository
+	for _, r := range m.repos {
+		if r.DeletedAt != nil {
+			continue
+		}
+		if l.Owner != nil && (r.UserID != *l.Owner || r.OrganizationID != nil) {
+			continue
+		}
+		if l.Organization != nil && (r.OrganizationID == nil || *r.OrganizationID != *l.Organization) {
+			continue
+		}
+		if l.Visibility != nil && r.Visibility != *l.Visibility {
+			continue
+		}
+		repos = append(repos, r)
+	}
+	m.mu.RUnlock()
+
+	memory.SortBy(l.SortKey, repos, func(i int, name string) time.Time {
+		switch name {
+		case "created_at":
+			return repos[i].CreatedAt
+		case "updated_at":
+			return repos[i].UpdatedAt
+		}
+		return time.Time{}
+	})
+	start, end := memory.Page(len(repos), l.Offset, l.Limit)
+	return repos[start:end], int64(len(repos)), nil
+}
+
+func (m *MemStorage) Get(id kallax.ULID) (*domain.Repository, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, r := range m.repos {
+		if r.ID == id && r.DeletedAt == nil {
+			return &r, nil
+		}
+	}
+	return nil, errors.WithStack(repoNotFound(fmt.Sprintf("repo with id %s not found", id)))
+}
+
+func (m *MemStorage) UpdateRepo(repo *domain.Repository) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, r := range m.repos {
+		if r.ID == repo.ID && r.DeletedAt == nil {
+			m.repos[i] = *repo
+			return nil
+		}
+	}
+	return errors.WithStack(repoNotFound(fmt.Sprintf("repo with id %s not found", repo.ID)))
+}
+
+// TransferRepo saves the owner of a repository, moving its usage to the new owner.
+func (m *MemStorage) TransferRepo(repo *domain.Repository) error {
+	if err := m.UpdateRepo(repo); err != nil {
+		return errors.Wrapf(err, "err transferring repo %s with memstorage", repo.ID)
+	}
+	if m.usages != nil {
+		m.usages.TransferUsage(repo.ID, repo.UserID)
+	}
+	return nil
+}
+
+func (m *MemStorage) RemoveUserRepos(userID kallax.ULID, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, r := range m.repos {
+		if r.UserID == userID && r.OrganizationID == nil && r.DeletedAt == nil {
+			t := at
+			m.repos[i].DeletedAt = &t
+		}
+	}
+	return nil
+}
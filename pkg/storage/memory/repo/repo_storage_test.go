@@ -0,0 +1,100 @@
+package repo_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/repo"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/usage"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestMemStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunRepoStore(t, repo.NewMemStorage(nil))
+}
+
+func TestMemStorageListRepos(t *testing.T) {
+	t.Parallel()
+
+	s := repo.NewMemStorage(nil)
+	userID, orgID := kallax.NewULID(), kallax.NewULID()
+	public, private := domain.Public, domain.Private
+	now := time.Now()
+	r1 := domain.Repository{ID: kallax.NewULID(), Visibility: public, UserID: userID, UpdatedAt: now}
+	r2 := domain.Repository{ID: kallax.NewULID(), Visibility: private, UserID: userID, UpdatedAt: now.Add(time.Minute)}
+	r3 := domain.Repository{ID: kallax.NewULID(), Visibility: public, UserID: userID, OrganizationID: &orgID}
+	for _, r := range []domain.Repository{r1, r2, r3} {
+		r := r
+		require.Nil(t, s.SaveRepo(&r))
+	}
+
+	tt := []struct {
+		name     string
+		listing  domain.Listing
+		expected []kallax.ULID
+	}{
+		{"personal repos of the owner", domain.Listing{Owner: &userID, SortKey: "updated_at DESC"}, []kallax.ULID{r2.ID, r1.ID}},
+		{"repos of the organization", domain.Listing{Organization: &orgID}, []kallax.ULID{r3.ID}},
+		{"public repos", domain.Listing{Visibility: &public, SortKey: "updated_at ASC"}, []kallax.ULID{r3.ID, r1.ID}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			repos, total, err := s.List(&tc.listing)
+			require.Nil(t, err)
+			assert.Equal(t, int64(len(tc.expected)), total)
+			var ids []kallax.ULID
+			for _, r := range repos {
+				ids = append(ids, r.ID)
+			}
+			assert.Equal(t, tc.expected, ids)
+		})
+	}
+}
+
+func TestMemStorageRemoveUserRepos(t *testing.T) {
+	t.Parallel()
+
+	s := repo.NewMemStorage(nil)
+	userID, orgID := kallax.NewULID(), kallax.NewULID()
+	personal := domain.Repository{ID: kallax.NewULID(), UserID: userID}
+	org := domain.Repository{ID: kallax.NewULID(), UserID: userID, OrganizationID: &orgID}
+	require.Nil(t, s.SaveRepo(&personal))
+	require.Nil(t, s.SaveRepo(&org))
+
+	require.Nil(t, s.RemoveUserRepos(userID, time.Now()))
+
+	_, err := s.Get(personal.ID)
+	assert.EqualError(t, err, "repo with id "+personal.ID.String()+" not found")
+	_, err = s.Get(org.ID)
+	assert.Nil(t, err)
+}
+
+func TestMemStorageTransferRepo(t *testing.T) {
+	t.Parallel()
+
+	usages := usage.NewMemStorage()
+	s := repo.NewMemStorage(usages)
+	ownerID, newOwnerID := kallax.NewULID(), kallax.NewULID()
+	r := domain.Repository{ID: kallax.NewULID(), UserID: ownerID}
+	require.Nil(t, s.SaveRepo(&r))
+	delta := domain.Usage{RepositoryID: r.ID, UserID: ownerID, Captures: 2}
+	require.Nil(t, usages.ReserveUsage(delta, func(repo, user domain.Usage) error { return nil }))
+
+	r.UserID = newOwnerID
+	require.Nil(t, s.TransferRepo(&r))
+
+	got, err := s.Get(r.ID)
+	require.Nil(t, err)
+	assert.Equal(t, newOwnerID, got.UserID)
+	u, err := usages.GetUserUsage(newOwnerID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), u.Captures)
+}
@@ -0,0 +1,99 @@
+package retention
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type policyNotFound string
+
+func (u policyNotFound) Error() string  { return string(u) }
+func (u policyNotFound) NotFound() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu       sync.RWMutex
+	policies []domain.RetentionPolicy
+	reports  []domain.RetentionReport
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+func (m *MemStorage) GetRetentionPolicy(repoID kallax.ULID) (*domain.RetentionPolicy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, p := range m.policies {
+		if p.RepositoryID == repoID {
+			return &p, nil
+		}
+	}
+	errStr := fmt.Sprintf("retention policy not found in repo %v", repoID)
+	return nil, errors.WithStack(policyNotFound(errStr))
+}
+
+func (m *MemStorage) SaveRetentionPolicy(policy *domain.RetentionPolicy) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, p := range m.policies {
+		if p.RepositoryID == policy.RepositoryID {
+			policy.CreatedAt = p.CreatedAt
+			m.policies[i] = *policy
+			return nil
+		}
+	}
+	m.policies = append(m.policies, *policy)
+	return nil
+}
+
+func (m *MemStorage) RemoveRetentionPolicy(repoID kallax.ULID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, p := range m.policies {
+		if p.RepositoryID == repoID {
+			m.policies = append(m.policies[:i], m.policies[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (m *MemStorage) ListRetentionPolicies() ([]domain.RetentionPolicy, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]domain.RetentionPolicy(nil), m.policies...), nil
+}
+
+func (m *MemStorage) CreateRetentionReport(r *domain.RetentionReport) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reports = append(m.reports, *r)
+	return nil
+}
+
+func (m *MemStorage) ListRetentionReports(l *domain.Listing) ([]domain.RetentionReport, int64, error) {
+	m.mu.RLock()
+	var reports []domain.RetentionReport
+	for _, r := range m.reports {
+		if l.Owner == nil || r.RepositoryID == *l.Owner {
+			reports = append(reports, r)
+		}
+	}
+	m.mu.RUnlock()
+
+	memory.SortBy(l.SortKey, reports, func(i int, name string) time.Time {
+		if name == "created_at" {
+			return reports[i].CreatedAt
+		}
+		return time.Time{}
+	})
+	start, end := memory.Page(len(reports), l.Offset, l.Limit)
+	return reports[start:end], int64(len(reports)), nil
+}
@@ -0,0 +1,83 @@
+package rollup
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type ruleNotFound string
+
+func (u ruleNotFound) Error() string  { return string(u) }
+func (u ruleNotFound) NotFound() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu    sync.RWMutex
+	rules []domain.RollupRule
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+func (m *MemStorage) CreateRollupRule(r *domain.RollupRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = append(m.rules, *r)
+	return nil
+}
+
+func (m *MemStorage) ListRollupRules(repoID kallax.ULID) ([]domain.RollupRule, error) {
+	m.mu.RLock()
+	var rules []domain.RollupRule
+	for _, r := range m.rules {
+		if r.RepositoryID == repoID && r.DeletedAt == nil {
+			rules = append(rules, r)
+		}
+	}
+	m.mu.RUnlock()
+	memory.SortBy("created_at ASC", rules, func(i int, _ string) time.Time { return rules[i].CreatedAt })
+	return rules, nil
+}
+
+func (m *MemStorage) GetRollupRule(ruleID, repoID kallax.ULID) (*domain.RollupRule, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, r := range m.rules {
+		if r.ID == ruleID && r.RepositoryID == repoID && r.DeletedAt == nil {
+			return &r, nil
+		}
+	}
+	errStr := fmt.Sprintf("rollup rule with id %s not found in repo %v", ruleID, repoID)
+	return nil, errors.WithStack(ruleNotFound(errStr))
+}
+
+func (m *MemStorage) SaveRollupRule(r *domain.RollupRule) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, rule := range m.rules {
+		if rule.ID == r.ID && rule.DeletedAt == nil {
+			m.rules[i] = *r
+		}
+	}
+	return nil
+}
+
+func (m *MemStorage) AllRollupRules() ([]domain.RollupRule, error) {
+	m.mu.RLock()
+	var rules []domain.RollupRule
+	for _, r := range m.rules {
+		if r.DeletedAt == nil {
+			rules = append(rules, r)
+		}
+	}
+	m.mu.RUnlock()
+	memory.SortBy("created_at ASC", rules, func(i int, _ string) time.Time { return rules[i].CreatedAt })
+	return rules, nil
+}
@@ -0,0 +1,234 @@
+package session
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type tokenNotFound string
+
+func (u tokenNotFound) Error() string  { return string(u) }
+func (u tokenNotFound) NotFound() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu            sync.RWMutex
+	refreshTokens []domain.RefreshToken
+	denylist      map[string]time.Time
+	userTokens    []domain.UserToken
+	throttles     map[string]domain.LoginThrottle
+	recoveryCodes []domain.RecoveryCode
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage {
+	return &MemStorage{
+		denylist:  make(map[string]time.Time),
+		throttles: make(map[string]domain.LoginThrottle),
+	}
+}
+
+// purgeExpired drops the refresh tokens and the denied tokens expired at now. It must be
+// called holding the lock.
+func (m *MemStorage) purgeExpired(now time.Time) {
+	kept := m.refreshTokens[:0]
+	for _, t := range m.refreshTokens {
+		if t.ExpiresAt.After(now) {
+			kept = append(kept, t)
+		}
+	}
+	m.refreshTokens = kept
+	for jti, expiresAt := range m.denylist {
+		if !expiresAt.After(now) {
+			delete(m.denylist, jti)
+		}
+	}
+}
+
+func (m *MemStorage) CreateRefreshToken(t *domain.RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purgeExpired(time.Now())
+	m.refreshTokens = append(m.refreshTokens, *t)
+	return nil
+}
+
+func (m *MemStorage) GetRefreshToken(hash string) (*domain.RefreshToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, t := range m.refreshTokens {
+		if t.Hash == hash {
+			return &t, nil
+		}
+	}
+	return nil, errors.WithStack(tokenNotFound("refresh token not found"))
+}
+
+func (m *MemStorage) RevokeRefreshToken(hash string, at time.Time, replacedBy *kallax.ULID) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, t := range m.refreshTokens {
+		if t.Hash == hash && t.RevokedAt == nil {
+			m.refreshTokens[i].RevokedAt = &at
+			m.refreshTokens[i].ReplacedBy = replacedBy
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (m *MemStorage) RevokeUserRefreshTokens(userID kallax.ULID, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, t := range m.refreshTokens {
+		if t.UserID == userID && t.RevokedAt == nil {
+			m.refreshTokens[i].RevokedAt = &at
+		}
+	}
+	return nil
+}
+
+func (m *MemStorage) DenyToken(jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.purgeExpired(time.Now())
+	if _, ok := m.denylist[jti]; !ok {
+		m.denylist[jti] = expiresAt
+	}
+	return nil
+}
+
+func (m *MemStorage) IsTokenDenied(jti string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	expiresAt, ok := m.denylist[jti]
+	return ok && expiresAt.After(time.Now()), nil
+}
+
+func (m *MemStorage) CreateUserToken(t *domain.UserToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.userTokens = append(m.userTokens, *t)
+	return nil
+}
+
+func (m *MemStorage) ConsumeUserToken(hash string, purpose domain.UserTokenPurpose, at time.Time) (*domain.UserToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, t := range m.userTokens {
+		if t.Hash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(at) {
+			m.userTokens[i].UsedAt = &at
+			t.UsedAt = &at
+			return &t, nil
+		}
+	}
+	return nil, errors.WithStack(tokenNotFound("user token not found"))
+}
+
+func (m *MemStorage) GetActiveUserToken(hash string, purpose domain.UserTokenPurpose, at time.Time) (*domain.UserToken, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, t := range m.userTokens {
+		if t.Hash == hash && t.Purpose == purpose && t.UsedAt == nil && t.ExpiresAt.After(at) {
+			return &t, nil
+		}
+	}
+	return nil, errors.WithStack(tokenNotFound("user token not found"))
+}
+
+func (m *MemStorage) UseUserTokens(userID kallax.ULID, purpose domain.UserTokenPurpose, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, t := range m.userTokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			m.userTokens[i].UsedAt = &at
+		}
+	}
+	return nil
+}
+
+func (m *MemStorage) GetLoginThrottles(keys ...string) ([]domain.LoginThrottle, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var throttles []domain.LoginThrottle
+	for _, key := range keys {
+		if t, ok := m.throttles[key]; ok {
+			throttles = append(throttles, t)
+		}
+	}
+	return throttles, nil
+}
+
+func (m *MemStorage) FailLoginThrottle(key string, at, windowStart time.Time) (*domain.LoginThrottle, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.throttles[key]
+	if !ok || t.LastFailureAt.Before(windowStart) || (t.LockedUntil != nil && !t.Locked(at)) {
+		t = domain.LoginThrottle{Key: key}
+	}
+	t.Failures++
+	t.LastFailureAt = at
+	m.throttles[key] = t
+	return &t, nil
+}
+
+func (m *MemStorage) LockLoginThrottle(key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if t, ok := m.throttles[key]; ok {
+		t.LockedUntil = &until
+		m.throttles[key] = t
+	}
+	return nil
+}
+
+func (m *MemStorage) RemoveLoginThrottles(keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, key := range keys {
+		delete(m.throttles, key)
+	}
+	return nil
+}
+
+func (m *MemStorage) ReplaceRecoveryCodes(userID kallax.ULID, codes []domain.RecoveryCode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeRecoveryCodes(userID)
+	m.recoveryCodes = append(m.recoveryCodes, codes...)
+	return nil
+}
+
+func (m *MemStorage) UseRecoveryCode(userID kallax.ULID, hash string, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, c := range m.recoveryCodes {
+		if c.UserID == userID && c.Hash == hash && c.UsedAt == nil {
+			m.recoveryCodes[i].UsedAt = &at
+			return nil
+		}
+	}
+	return errors.WithStack(tokenNotFound("recovery code not found"))
+}
+
+func (m *MemStorage) RemoveRecoveryCodes(userID kallax.ULID) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeRecoveryCodes(userID)
+	return nil
+}
+
+func (m *MemStorage) removeRecoveryCodes(userID kallax.ULID) {
+	codes := m.recoveryCodes[:0]
+	for _, c := range m.recoveryCodes {
+		if c.UserID != userID {
+			codes = append(codes, c)
+		}
+	}
+	m.recoveryCodes = codes
+}
@@ -0,0 +1,81 @@
+package sharelink
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type linkNotFound string
+
+func (u linkNotFound) Error() string  { return string(u) }
+func (u linkNotFound) NotFound() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu    sync.RWMutex
+	links []domain.ShareLink
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+func (m *MemStorage) CreateShareLink(l *domain.ShareLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.links = append(m.links, *l)
+	return nil
+}
+
+func (m *MemStorage) ListShareLinks(repoID kallax.ULID) ([]domain.ShareLink, error) {
+	m.mu.RLock()
+	var links []domain.ShareLink
+	for _, l := range m.links {
+		if l.RepositoryID == repoID {
+			links = append(links, l)
+		}
+	}
+	m.mu.RUnlock()
+	memory.SortBy("created_at ASC", links, func(i int, _ string) time.Time { return links[i].CreatedAt })
+	return links, nil
+}
+
+func (m *MemStorage) GetShareLink(linkID, repoID kallax.ULID) (*domain.ShareLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, l := range m.links {
+		if l.ID == linkID && l.RepositoryID == repoID {
+			return &l, nil
+		}
+	}
+	errStr := fmt.Sprintf("share link with id %s not found in repo %v", linkID, repoID)
+	return nil, errors.WithStack(linkNotFound(errStr))
+}
+
+func (m *MemStorage) GetShareLinkByID(linkID kallax.ULID) (*domain.ShareLink, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, l := range m.links {
+		if l.ID == linkID {
+			return &l, nil
+		}
+	}
+	return nil, errors.WithStack(linkNotFound(fmt.Sprintf("share link with id %s not found", linkID)))
+}
+
+func (m *MemStorage) SaveShareLink(l *domain.ShareLink) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, link := range m.links {
+		if link.ID == l.ID {
+			m.links[i] = *l
+		}
+	}
+	return nil
+}
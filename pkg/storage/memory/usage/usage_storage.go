@@ -0,0 +1,103 @@
+package usage
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu     sync.Mutex
+	usages map[kallax.ULID]domain.Usage
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage {
+	return &MemStorage{usages: make(map[kallax.ULID]domain.Usage)}
+}
+
+func (m *MemStorage) GetRepoUsage(repoID kallax.ULID) (*domain.Usage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.usages[repoID]
+	if !ok {
+		u = domain.Usage{RepositoryID: repoID}
+	}
+	return &u, nil
+}
+
+func (m *MemStorage) GetUserUsage(userID kallax.ULID) (*domain.Usage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u := domain.Usage{UserID: userID}
+	for _, repo := range m.usages {
+		if repo.UserID == userID {
+			u = u.Add(repo)
+		}
+	}
+	return &u, nil
+}
+
+// ReserveUsage holds the lock of the storage while the quotas are checked, so concurrent
+// reservations are serialized.
+func (m *MemStorage) ReserveUsage(delta domain.Usage, check func(repo, user domain.Usage) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	repo, ok := m.usages[delta.RepositoryID]
+	if !ok {
+		repo = domain.Usage{RepositoryID: delta.RepositoryID, UserID: delta.UserID}
+	}
+	user := domain.Usage{UserID: delta.UserID}
+	for _, u := range m.usages {
+		if u.UserID == delta.UserID {
+			user = user.Add(u)
+		}
+	}
+	if err := check(repo, user); err != nil {
+		return errors.Wrap(err, "err reserving usage with memstorage")
+	}
+	m.usages[delta.RepositoryID] = repo.Add(delta)
+	return nil
+}
+
+func (m *MemStorage) ReleaseUsage(delta domain.Usage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	u, ok := m.usages[delta.RepositoryID]
+	if !ok {
+		return nil
+	}
+	u.Captures -= delta.Captures
+	if u.Captures < 0 {
+		u.Captures = 0
+	}
+	u.Bytes -= delta.Bytes
+	if u.Bytes < 0 {
+		u.Bytes = 0
+	}
+	m.usages[delta.RepositoryID] = u
+	return nil
+}
+
+// LoadUsage sets the usage of the repositories, replacing the one they had.
+func (m *MemStorage) LoadUsage(usages ...domain.Usage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range usages {
+		m.usages[u.RepositoryID] = u
+	}
+}
+
+// TransferUsage moves the usage of a repository to other user.
+func (m *MemStorage) TransferUsage(repoID, userID kallax.ULID) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.usages[repoID]; ok {
+		u.UserID = userID
+		m.usages[repoID] = u
+	}
+}
@@ -0,0 +1,33 @@
+package usage_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/usage"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestMemStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunUsageStore(t, usage.NewMemStorage())
+}
+
+func TestMemStorageLoadUsage(t *testing.T) {
+	t.Parallel()
+
+	s := usage.NewMemStorage()
+	repoID := kallax.NewULID()
+	require.Nil(t, s.ReserveUsage(domain.Usage{RepositoryID: repoID, Captures: 5}, func(repo, user domain.Usage) error { return nil }))
+	s.LoadUsage(domain.Usage{RepositoryID: repoID, Captures: 2, Bytes: 10})
+
+	u, err := s.GetRepoUsage(repoID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(2), u.Captures)
+	assert.Equal(t, int64(10), u.Bytes)
+}
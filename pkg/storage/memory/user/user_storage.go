@@ -0,0 +1,156 @@
+package user
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type userNotFound string
+
+func (u userNotFound) Error() string  { return string(u) }
+func (u userNotFound) NotFound() bool { return true }
+
+type uniqueConstraintErr string
+
+func (u uniqueConstraintErr) Error() string          { return string(u) }
+func (u uniqueConstraintErr) UniqueConstraint() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu    sync.RWMutex
+	users []domain.User
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+// emailTaken reports whether other user has the email, removed users keep it like
+// the unique constraint of postgres.
+func (m *MemStorage) emailTaken(email string, id kallax.ULID) bool {
+	for _, u := range m.users {
+		if u.Email == email && u.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemStorage) SaveUser(user *domain.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.emailTaken(user.Email, user.ID) {
+		errStr := fmt.Sprintf("duplicate key value violates unique constraint, email %s", user.Email)
+		return errors.WithStack(uniqueConstraintErr(errStr))
+	}
+	m.users = append(m.users, *user)
+	return nil
+}
+
+// GetByEmail a user by email, if not found returns an error
+func (m *MemStorage) GetUserByEmail(email string) (*domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, u := range m.users {
+		if u.Email == email && u.DeletedAt == nil {
+			return &u, nil
+		}
+	}
+	return nil, errors.WithStack(userNotFound(fmt.Sprintf("user with email %s not found", email)))
+}
+
+// GetByEmail a user by id, if not found returns an error
+func (m *MemStorage) GetUserByID(id kallax.ULID) (*domain.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, u := range m.users {
+		if u.ID == id && u.DeletedAt == nil {
+			return &u, nil
+		}
+	}
+	return nil, errors.WithStack(userNotFound(fmt.Sprintf("user with id %v not found", id)))
+}
+
+// ListUsers retrieve users with domain.Listing attrs.
+func (m *MemStorage) ListUsers(l *domain.Listing) ([]domain.User, int64, error) {
+	m.mu.RLock()
+	var users []domain.User
+	for _, u := range m.users {
+		if u.DeletedAt != nil {
+			continue
+		}
+		if l.Search != nil && !memory.Contains(u.Email, *l.Search) && !memory.Contains(u.Name, *l.Search) {
+			continue
+		}
+		if l.Disabled != nil && *l.Disabled != (u.DisabledAt != nil) {
+			continue
+		}
+		users = append(users, u)
+	}
+	m.mu.RUnlock()
+
+	memory.SortBy(l.SortKey, users, func(i int, name string) time.Time {
+		switch name {
+		case "created_at":
+			return users[i].CreatedAt
+		case "updated_at":
+			return users[i].UpdatedAt
+		}
+		return time.Time{}
+	})
+	start, end := memory.Page(len(users), l.Offset, l.Limit)
+	return users[start:end], int64(len(users)), nil
+}
+
+// UpdateUser saves the changes of a user.
+func (m *MemStorage) UpdateUser(user *domain.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.emailTaken(user.Email, user.ID) {
+		errStr := fmt.Sprintf("duplicate key value violates unique constraint, email %s", user.Email)
+		return errors.WithStack(uniqueConstraintErr(errStr))
+	}
+	for i, u := range m.users {
+		if u.ID == user.ID && u.DeletedAt == nil {
+			m.users[i] = *user
+			return nil
+		}
+	}
+	return errors.WithStack(userNotFound(fmt.Sprintf("user with id %v not found", user.ID)))
+}
+
+// AdvanceTwoFactorStep saves the last TOTP step used by a user when it's after the stored one.
+func (m *MemStorage) AdvanceTwoFactorStep(id kallax.ULID, step int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, u := range m.users {
+		if u.ID == id && u.DeletedAt == nil && u.TwoFactorLastStep < step {
+			m.users[i].TwoFactorLastStep = step
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RemoveUser soft deletes a user.
+func (m *MemStorage) RemoveUser(user *domain.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, u := range m.users {
+		if u.ID == user.ID && u.DeletedAt == nil {
+			t := time.Now()
+			if user.DeletedAt != nil {
+				t = *user.DeletedAt
+			}
+			m.users[i].DeletedAt = &t
+			return nil
+		}
+	}
+	return errors.WithStack(userNotFound(fmt.Sprintf("user with id %v not found", user.ID)))
+}
@@ -0,0 +1,61 @@
+package user_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory/user"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestMemStorageConformance(t *testing.T) {
+	t.Parallel()
+
+	storagetest.RunUserStore(t, user.NewMemStorage())
+}
+
+func TestMemStorageSaveUserUniqueEmail(t *testing.T) {
+	t.Parallel()
+
+	s := user.NewMemStorage()
+	require.Nil(t, s.SaveUser(&domain.User{ID: kallax.NewULID(), Email: "test@example.com"}))
+
+	err := s.SaveUser(&domain.User{ID: kallax.NewULID(), Email: "test@example.com"})
+	unique, ok := errors.Cause(err).(interface{ UniqueConstraint() bool })
+	require.True(t, ok)
+	assert.True(t, unique.UniqueConstraint())
+}
+
+func TestMemStorageRemoveUser(t *testing.T) {
+	t.Parallel()
+
+	s := user.NewMemStorage()
+	u := &domain.User{ID: kallax.NewULID(), Email: "test@example.com"}
+	require.Nil(t, s.SaveUser(u))
+	require.Nil(t, s.RemoveUser(u))
+
+	_, err := s.GetUserByID(u.ID)
+	assert.EqualError(t, err, "user with id "+u.ID.String()+" not found")
+	_, err = s.GetUserByEmail(u.Email)
+	assert.EqualError(t, err, "user with email test@example.com not found")
+}
+
+func TestMemStorageListUsers(t *testing.T) {
+	t.Parallel()
+
+	s := user.NewMemStorage()
+	require.Nil(t, s.SaveUser(&domain.User{ID: kallax.NewULID(), Email: "alice@example.com", Name: "Alice"}))
+	require.Nil(t, s.SaveUser(&domain.User{ID: kallax.NewULID(), Email: "bob@example.com", Name: "Bob"}))
+	search, disabled := "ALI", false
+
+	users, total, err := s.ListUsers(&domain.Listing{Search: &search, Disabled: &disabled, Limit: 10})
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), total)
+	require.Len(t, users, 1)
+	assert.Equal(t, "alice@example.com", users[0].Email)
+}
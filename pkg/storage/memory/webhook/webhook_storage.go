@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/memory"
+)
+
+type webhookNotFound string
+
+func (u webhookNotFound) Error() string  { return string(u) }
+func (u webhookNotFound) NotFound() bool { return true }
+
+// MemStorage in memory storage layer
+type MemStorage struct {
+	mu         sync.RWMutex
+	hooks      []domain.Webhook
+	deliveries []domain.WebhookDelivery
+}
+
+// NewMemStorage creates a new instance of MemStorage
+func NewMemStorage() *MemStorage { return &MemStorage{} }
+
+func (m *MemStorage) CreateWebhook(w *domain.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hooks = append(m.hooks, *w)
+	return nil
+}
+
+func (m *MemStorage) ListWebhooks(repoID kallax.ULID) ([]domain.Webhook, error) {
+	m.mu.RLock()
+	var hooks []domain.Webhook
+	for _, w := range m.hooks {
+		if w.RepositoryID == repoID && w.DeletedAt == nil {
+			hooks = append(hooks, w)
+		}
+	}
+	m.mu.RUnlock()
+	memory.SortBy("created_at ASC", hooks, func(i int, _ string) time.Time { return hooks[i].CreatedAt })
+	return hooks, nil
+}
+
+func (m *MemStorage) GetWebhook(webhookID, repoID kallax.ULID) (*domain.Webhook, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, w := range m.hooks {
+		if w.ID == webhookID && w.RepositoryID == repoID && w.DeletedAt == nil {
+			return &w, nil
+		}
+	}
+	errStr := fmt.Sprintf("webhook with id %s not found in repo %v", webhookID, repoID)
+	return nil, errors.WithStack(webhookNotFound(errStr))
+}
+
+func (m *MemStorage) SaveWebhook(w *domain.Webhook) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, hook := range m.hooks {
+		if hook.ID == w.ID && hook.DeletedAt == nil {
+			m.hooks[i] = *w
+		}
+	}
+	return nil
+}
+
+func (m *MemStorage) CreateWebhookDelivery(d *domain.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, *d)
+	return nil
+}
+
+func (m *MemStorage) ListWebhookDeliveries(l *domain.Listing) ([]domain.WebhookDelivery, int64, error) {
+	m.mu.RLock()
+	var deliveries []domain.WebhookDelivery
+	for _, d := range m.deliveries {
+		if l.Owner == nil || d.WebhookID == *l.Owner {
+			deliveries = append(deliveries, d)
+		}
+	}
+	m.mu.RUnlock()
+
+	memory.SortBy(l.SortKey, deliveries, func(i int, name string) time.Time {
+		if name == "created_at" {
+			return deliveries[i].CreatedAt
+		}
+		return time.Time{}
+	})
+	start, end := memory.Page(len(deliveries), l.Offset, l.Limit)
+	return deliveries[start:end], int64(len(deliveries)), nil
+}
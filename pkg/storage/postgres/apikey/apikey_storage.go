@@ -0,0 +1,97 @@
+package apikey
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type keyNotFound string
+
+func (u keyNotFound) Error() string  { return string(u) }
+func (u keyNotFound) NotFound() bool { return true }
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	if err := p.db.CreateTable(&domain.APIKey{}, opts); err != nil {
+		return errors.Wrap(err, "creating api key schema")
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	if err := p.db.DropTable(&domain.APIKey{}, opts); err != nil {
+		return errors.Wrap(err, "dropping api key schema")
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateAPIKey(k *domain.APIKey) error {
+	if err := p.db.Insert(k); err != nil {
+		return errors.Wrap(err, "err saving api key with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListAPIKeys(repoID kallax.ULID) ([]domain.APIKey, error) {
+	var keys []domain.APIKey
+	err := p.db.Model(&keys).
+		Where("repository_id = ?", repoID).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing api keys with pgstorage")
+	}
+	return keys, nil
+}
+
+func (p *PGStorage) GetAPIKey(keyID, repoID kallax.ULID) (*domain.APIKey, error) {
+	var k domain.APIKey
+	err := p.db.Model(&k).
+		Where("id = ?", keyID).
+		Where("repository_id = ?", repoID).
+		First()
+	if err != nil {
+		errStr := fmt.Sprintf("api key with id %s not found in repo %v", keyID, repoID)
+		return nil, errors.WithStack(keyNotFound(errStr))
+	}
+	return &k, nil
+}
+
+func (p *PGStorage) GetAPIKeyByPrefix(prefix string) (*domain.APIKey, error) {
+	var k domain.APIKey
+	if err := p.db.Model(&k).Where("prefix = ?", prefix).First(); err != nil {
+		return nil, errors.WithStack(keyNotFound(fmt.Sprintf("api key with prefix %s not found", prefix)))
+	}
+	return &k, nil
+}
+
+func (p *PGStorage) SaveAPIKey(k *domain.APIKey) error {
+	if err := p.db.Update(k); err != nil {
+		errStr := fmt.Sprintf("error saving the api key %s in repo %v", k.ID, k.RepositoryID)
+		return errors.Wrap(err, errStr)
+	}
+	return nil
+}
+
+func (p *PGStorage) TouchAPIKey(k *domain.APIKey) error {
+	_, err := p.db.Model(k).Column("last_used_at").WherePK().Update()
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("error updating the last use of api key %s", k.ID))
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package apikey_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/apikey"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return apikey.NewPGStorage(db) })
+
+	storagetest.RunAPIKeyStore(t, apikey.NewPGStorage(db))
+}
@@ -0,0 +1,28 @@
+package audit
+
+import (
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type filter domain.Listing
+
+func (f *filter) Filter(q *orm.Query) (*orm.Query, error) {
+	if f.Repository != nil {
+		q = q.Where("repository_id = ?", *f.Repository)
+	}
+	if f.Actor != nil {
+		q = q.Where("actor_id = ?", *f.Actor)
+	}
+	if f.Action != nil {
+		q = q.Where("action = ?", *f.Action)
+	}
+	if f.TargetType != nil {
+		q = q.Where("target_type = ?", *f.TargetType)
+	}
+
+	return q.Order(f.SortKey).
+		Offset(int(f.Offset)).
+		Limit(f.Limit), nil
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// migrations add the columns of the entries recorded before they existed.
+var migrations = []string{
+	"ALTER TABLE audit_entries ADD COLUMN IF NOT EXISTS impersonator_id uuid",
+}
+
+// appendOnlyRules discard the updates and deletes of the audit trail entries.
+var appendOnlyRules = []string{
+	"CREATE OR REPLACE RULE audit_entries_no_update AS ON UPDATE TO audit_entries DO INSTEAD NOTHING",
+	"CREATE OR REPLACE RULE audit_entries_no_delete AS ON DELETE TO audit_entries DO INSTEAD NOTHING",
+}
+
+// CreateSchema runs schema migration. The table is append only.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	if err := p.db.CreateTable(&domain.AuditEntry{}, opts); err != nil {
+		return errors.Wrap(err, "creating audit schema")
+	}
+	for _, m := range migrations {
+		if _, err := p.db.Exec(m); err != nil {
+			return errors.Wrap(err, "migrating audit schema")
+		}
+	}
+	for _, rule := range appendOnlyRules {
+		if _, err := p.db.Exec(rule); err != nil {
+			return errors.Wrap(err, "creating audit append only rules")
+		}
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	if err := p.db.DropTable(&domain.AuditEntry{}, opts); err != nil {
+		return errors.Wrap(err, "dropping audit schema")
+	}
+	return nil
+}
+
+// CreateAuditEntry appends an entry to the audit trail.
+func (p *PGStorage) CreateAuditEntry(e *domain.AuditEntry) error {
+	if err := p.db.Insert(e); err != nil {
+		return errors.Wrap(err, "err saving audit entry with pgstorage")
+	}
+	return nil
+}
+
+// ListAuditEntries retrieve the audit trail entries with domain.Listing attrs.
+func (p *PGStorage) ListAuditEntries(l *domain.Listing) ([]domain.AuditEntry, int64, error) {
+	var entries []domain.AuditEntry
+	f := filter(*l)
+	total, err := p.db.Model(&entries).Apply(f.Filter).SelectAndCount()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "err listing audit entries with pgstorage")
+	}
+	return entries, int64(total), nil
+}
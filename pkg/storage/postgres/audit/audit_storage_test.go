@@ -0,0 +1,16 @@
+package audit_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/audit"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return audit.NewPGStorage(db) })
+
+	storagetest.RunAuditStore(t, audit.NewPGStorage(db))
+}
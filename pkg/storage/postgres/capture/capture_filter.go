@@ -1,11 +1,24 @@
 package capture
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/go-pg/pg/orm"
 
 	"github.com/ifreddyrondon/capture/pkg/domain"
 )
 
+const (
+	jsonLAT = "(location->>'lat')::float8"
+	jsonLNG = "(location->>'lng')::float8"
+	// haversine is the great-circle distance in meters between the location and a point
+	// with the params lat, lat and lng.
+	haversine = "2 * ? * asin(sqrt(power(sin(radians((" + jsonLAT + " - ?) / 2)), 2) + " +
+		"cos(radians(?)) * cos(radians(" + jsonLAT + ")) * power(sin(radians((" + jsonLNG + " - ?) / 2)), 2)))"
+	geographyPoint = "ST_SetSRID(ST_MakePoint(?, ?), 4326)::geography"
+)
+
 type filter domain.Listing
 
 func (f *filter) Filter(q *orm.Query) (*orm.Query, error) {
@@ -16,3 +29,45 @@ func (f *filter) Filter(q *orm.Query) (*orm.Query, error) {
 		Offset(int(f.Offset)).
 		Limit(f.Limit), nil
 }
+
+// Location filters the area and distance over the location json.
+func (f *filter) Location(q *orm.Query) (*orm.Query, error) {
+	if len(f.Area) > 0 {
+		q = q.Where("point("+jsonLNG+", "+jsonLAT+") <@ ?::polygon", polygonText(f.Area))
+	}
+	if f.Near != nil {
+		lat, lng := *f.Near.LAT, *f.Near.LNG
+		q = q.Where(haversine+" <= ?", domain.EarthRadius, lat, lat, lng, f.Radius)
+	}
+	return q, nil
+}
+
+// GeographyLocation filters the area and distance over the geography column, using its index.
+func (f *filter) GeographyLocation(q *orm.Query) (*orm.Query, error) {
+	if len(f.Area) > 0 {
+		q = q.Where("ST_Covers(ST_GeogFromText(?), geog)", polygonWKT(f.Area))
+	}
+	if f.Near != nil {
+		q = q.Where("ST_DWithin(geog, "+geographyPoint+", ?)", *f.Near.LNG, *f.Near.LAT, f.Radius)
+	}
+	return q, nil
+}
+
+// polygonText returns the polygon in the postgres text form ((lng,lat),...).
+func polygonText(p domain.Polygon) string {
+	points := make([]string, len(p))
+	for i, v := range p {
+		points[i] = fmt.Sprintf("(%v,%v)", *v.LNG, *v.LAT)
+	}
+	return "(" + strings.Join(points, ",") + ")"
+}
+
+// polygonWKT returns the polygon as a closed ring in well-known text.
+func polygonWKT(p domain.Polygon) string {
+	points := make([]string, len(p)+1)
+	for i, v := range p {
+		points[i] = fmt.Sprintf("%v %v", *v.LNG, *v.LAT)
+	}
+	points[len(p)] = points[0]
+	return "SRID=4326;POLYGON((" + strings.Join(points, ",") + "))"
+}
@@ -0,0 +1,48 @@
+package capture
+
+import "time"
+
+// Partition is the period of time of each partition of the captures table.
+type Partition string
+
+const (
+	// Daily partitions the captures by day.
+	Daily Partition = "day"
+	// Weekly partitions the captures by week, starting on monday.
+	Weekly Partition = "week"
+	// Monthly partitions the captures by month.
+	Monthly Partition = "month"
+	// Yearly partitions the captures by year.
+	Yearly Partition = "year"
+)
+
+func (p Partition) valid() bool {
+	switch p {
+	case Daily, Weekly, Monthly, Yearly:
+		return true
+	}
+	return false
+}
+
+// bounds returns the range [from, to) in UTC of the partition including t.
+func (p Partition) bounds(t time.Time) (time.Time, time.Time) {
+	t = t.UTC()
+	switch p {
+	case Daily:
+		from := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(0, 0, 1)
+	case Weekly:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		from := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return from, from.AddDate(0, 0, 7)
+	case Yearly:
+		from := time.Date(t.Year(), 1, 1, 0, 0, 0, 0, time.UTC)
+		return from, from.AddDate(1, 0, 0)
+	}
+	from := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	return from, from.AddDate(0, 1, 0)
+}
+
+func partitionName(from time.Time) string {
+	return "captures_p" + from.Format("20060102")
+}
@@ -2,6 +2,8 @@ package capture
 
 import (
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/go-pg/pg"
 	"github.com/go-pg/pg/orm"
@@ -16,22 +18,110 @@ type captureNotFound string
 func (u captureNotFound) Error() string  { return string(u) }
 func (u captureNotFound) NotFound() bool { return true }
 
+// postGISSchema keeps the location in a geography point column, indexed with GiST and
+// set from the location json by a trigger, so the model and its JSON shape don't change.
+var postGISSchema = []string{
+	`CREATE EXTENSION IF NOT EXISTS postgis`,
+	`ALTER TABLE captures ADD COLUMN IF NOT EXISTS geog geography(Point, 4326)`,
+	`CREATE INDEX IF NOT EXISTS captures_geog_idx ON captures USING GIST (geog)`,
+	`CREATE OR REPLACE FUNCTION captures_set_geog() RETURNS trigger AS $$
+	BEGIN
+		IF NEW.location->>'lat' IS NULL OR NEW.location->>'lng' IS NULL THEN
+			NEW.geog := NULL;
+		ELSE
+			NEW.geog := ST_SetSRID(ST_MakePoint((NEW.location->>'lng')::float8, (NEW.location->>'lat')::float8), 4326)::geography;
+		END IF;
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS captures_set_geog ON captures`,
+	`CREATE TRIGGER captures_set_geog BEFORE INSERT OR UPDATE OF location ON captures
+	FOR EACH ROW EXECUTE PROCEDURE captures_set_geog()`,
+	// fills the captures stored before the column existed.
+	`UPDATE captures SET location = location WHERE geog IS NULL AND location IS NOT NULL`,
+}
+
+// partitionedSchema creates the captures table partitioned by range of timestamp. The
+// primary key must include the partition key.
+const partitionedSchema = `CREATE TABLE IF NOT EXISTS captures (
+	id uuid,
+	payload jsonb NOT NULL,
+	location jsonb,
+	tags text[] NOT NULL,
+	timestamp timestamptz NOT NULL,
+	created_at timestamptz NOT NULL,
+	updated_at timestamptz NOT NULL,
+	deleted_at timestamptz,
+	repository_id uuid,
+	rollup jsonb,
+	PRIMARY KEY (id, timestamp)
+) PARTITION BY RANGE (timestamp)`
+
+// Option configures a PGStorage.
+type Option func(*PGStorage)
+
+// PostGIS keeps the locations as PostGIS geography points, the area and distance filters
+// use its spatial index.
+func PostGIS() Option {
+	return func(p *PGStorage) { p.postgis = true }
+}
+
+// Partitioned splits the captures table in a partition by period of timestamp. The
+// partitions are created when the first capture of its period is stored.
+func Partitioned(period Partition) Option {
+	return func(p *PGStorage) { p.partition = period }
+}
+
 // PGStorage postgres storage layer
-type PGStorage struct{ db *pg.DB }
+type PGStorage struct {
+	db        *pg.DB
+	postgis   bool
+	partition Partition
+
+	mu         sync.Mutex
+	partitions map[string]bool
+}
 
 // NewPGStorage creates a new instance of PGStorage
-func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+func NewPGStorage(db *pg.DB, opts ...Option) *PGStorage {
+	p := &PGStorage{db: db, partitions: make(map[string]bool)}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
 
 // CreateSchema runs schema migration.
 func (p *PGStorage) CreateSchema() error {
-	opts := &orm.CreateTableOptions{IfNotExists: true}
-	err := p.db.CreateTable(&domain.Capture{}, opts)
-	if err != nil {
+	if err := p.createTable(); err != nil {
 		return errors.Wrap(err, "creating capture schema")
 	}
+	if !p.postgis {
+		return nil
+	}
+	for _, stmt := range postGISSchema {
+		if _, err := p.db.Exec(stmt); err != nil {
+			return errors.Wrap(err, "creating capture postgis schema")
+		}
+	}
 	return nil
 }
 
+func (p *PGStorage) createTable() error {
+	if p.partition == "" {
+		opts := &orm.CreateTableOptions{IfNotExists: true}
+		return p.db.CreateTable(&domain.Capture{}, opts)
+	}
+	if !p.partition.valid() {
+		return errors.Errorf("unknown partition %v", p.partition)
+	}
+	if _, err := p.db.Exec(partitionedSchema); err != nil {
+		return err
+	}
+	_, err := p.db.Exec(`CREATE INDEX IF NOT EXISTS captures_repository_id_timestamp_idx ON captures (repository_id, timestamp)`)
+	return err
+}
+
 // Drop delete schema.
 func (p *PGStorage) Drop() error {
 	opts := &orm.DropTableOptions{IfExists: true}
@@ -39,10 +129,38 @@ func (p *PGStorage) Drop() error {
 	if err != nil {
 		return errors.Wrap(err, "dropping capture schema")
 	}
+	p.mu.Lock()
+	p.partitions = make(map[string]bool)
+	p.mu.Unlock()
+	return nil
+}
+
+// ensurePartitions creates the missing partitions of the captures timestamps.
+func (p *PGStorage) ensurePartitions(captures ...domain.Capture) error {
+	if p.partition == "" {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range captures {
+		from, to := p.partition.bounds(c.Timestamp)
+		name := partitionName(from)
+		if p.partitions[name] {
+			continue
+		}
+		stmt := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF captures FOR VALUES FROM (?) TO (?)`, name)
+		if _, err := p.db.Exec(stmt, from, to); err != nil {
+			return errors.Wrapf(err, "creating capture partition %s", name)
+		}
+		p.partitions[name] = true
+	}
 	return nil
 }
 
 func (p *PGStorage) CreateCapture(c *domain.Capture) error {
+	if err := p.ensurePartitions(*c); err != nil {
+		return err
+	}
 	if err := p.db.Insert(c); err != nil {
 		return errors.Wrap(err, "err saving capture with pgstorage")
 	}
@@ -50,6 +168,9 @@ func (p *PGStorage) CreateCapture(c *domain.Capture) error {
 }
 
 func (p *PGStorage) CreateCaptures(captures ...domain.Capture) error {
+	if err := p.ensurePartitions(captures...); err != nil {
+		return err
+	}
 	if err := p.db.Insert(&captures); err != nil {
 		return errors.Wrap(err, "err saving captures with pgstorage")
 	}
@@ -59,7 +180,11 @@ func (p *PGStorage) CreateCaptures(captures ...domain.Capture) error {
 func (p *PGStorage) List(l *domain.Listing) ([]domain.Capture, int64, error) {
 	var captures []domain.Capture
 	f := filter(*l)
-	total, err := p.db.Model(&captures).Apply(f.Filter).SelectAndCount()
+	location := f.Location
+	if p.postgis {
+		location = f.GeographyLocation
+	}
+	total, err := p.db.Model(&captures).Apply(location).Apply(f.Filter).SelectAndCount()
 	if err != nil {
 		return nil, 0, errors.Wrap(err, "err listing captures with pgstorage")
 	}
@@ -80,9 +205,110 @@ func (p *PGStorage) Get(captureID, repoID kallax.ULID) (*domain.Capture, error)
 }
 
 func (p *PGStorage) Save(capt *domain.Capture) error {
+	if err := p.ensurePartitions(*capt); err != nil {
+		return err
+	}
 	if err := p.db.Update(capt); err != nil {
+		if err == pg.ErrNoRows {
+			errStr := fmt.Sprintf("capture with id %s not found in repo %v", capt.ID, capt.RepositoryID)
+			return errors.WithStack(captureNotFound(errStr))
+		}
 		errStr := fmt.Sprintf("error saving the capture %s in repo %v", capt.ID, capt.RepositoryID)
 		return errors.Wrap(err, errStr)
 	}
 	return nil
 }
+
+func (p *PGStorage) ExpiredCaptures(repoID kallax.ULID, before time.Time, limit int) ([]domain.Capture, error) {
+	var captures []domain.Capture
+	err := p.db.Model(&captures).
+		Where("repository_id = ?", repoID).
+		Where("timestamp < ?", before).
+		Limit(limit).
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err getting expired captures with pgstorage")
+	}
+	return captures, nil
+}
+
+// DeleteCaptures removes permanently the captures, with or without deleted_at, so it's a
+// plain delete. ForceDelete only matches the soft deleted ones.
+func (p *PGStorage) DeleteCaptures(repoID kallax.ULID, before time.Time, ids ...kallax.ULID) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	res, err := p.db.Exec(`DELETE FROM captures WHERE repository_id = ? AND timestamp < ? AND id IN (?)`, repoID, before, pg.In(ids))
+	if err != nil {
+		return 0, errors.Wrap(err, "err deleting captures with pgstorage")
+	}
+	return int64(res.RowsAffected()), nil
+}
+
+func (p *PGStorage) PurgeCaptures(repoID kallax.ULID, before time.Time) (int64, error) {
+	res, err := p.db.Model((*domain.Capture)(nil)).
+		Where("repository_id = ?", repoID).
+		Where("timestamp < ?", before).
+		Where("deleted_at IS NOT NULL").
+		ForceDelete()
+	if err != nil {
+		return 0, errors.Wrap(err, "err purging removed captures with pgstorage")
+	}
+	return int64(res.RowsAffected()), nil
+}
+
+func (p *PGStorage) RollupCandidates(repoID kallax.ULID, before time.Time, resolution int64, limit int) ([]domain.Capture, error) {
+	var captures []domain.Capture
+	err := p.db.Model(&captures).
+		Where("repository_id = ?", repoID).
+		Where("timestamp < ?", before).
+		Where("COALESCE((rollup->>'resolution')::bigint, 0) < ?", resolution).
+		Order("timestamp ASC").
+		Limit(limit).
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err getting captures to roll up with pgstorage")
+	}
+	return captures, nil
+}
+
+func (p *PGStorage) ReplaceCaptures(captures []domain.Capture, rollups []domain.Capture) error {
+	if len(captures) == 0 {
+		return nil
+	}
+	if err := p.ensurePartitions(rollups...); err != nil {
+		return err
+	}
+	ids := make([]kallax.ULID, len(captures))
+	from, to := captures[0].Timestamp, captures[0].Timestamp
+	for i, c := range captures {
+		ids[i] = c.ID
+		if c.Timestamp.Before(from) {
+			from = c.Timestamp
+		}
+		if c.Timestamp.After(to) {
+			to = c.Timestamp
+		}
+	}
+	err := p.db.RunInTransaction(func(tx *pg.Tx) error {
+		if len(rollups) > 0 {
+			if err := tx.Insert(&rollups); err != nil {
+				return err
+			}
+		}
+		// the timestamp range keeps the delete in the partitions of the captures.
+		res, err := tx.Exec(`DELETE FROM captures WHERE repository_id = ? AND timestamp BETWEEN ? AND ? AND id IN (?)`,
+			captures[0].RepositoryID, from, to, pg.In(ids))
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() != len(captures) {
+			return errors.Errorf("%d of %d captures deleted", res.RowsAffected(), len(captures))
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Wrap(err, "err replacing captures by rollups with pgstorage")
+	}
+	return nil
+}
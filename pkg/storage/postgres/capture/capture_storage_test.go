@@ -0,0 +1,93 @@
+package capture_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/capture"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func newCapture(repoID kallax.ULID, timestamp time.Time) domain.Capture {
+	return domain.Capture{
+		ID:           kallax.NewULID(),
+		Payload:      domain.Payload{{Name: "power", Value: 1.5}},
+		Tags:         []string{},
+		Timestamp:    timestamp,
+		CreatedAt:    timestamp,
+		UpdatedAt:    timestamp,
+		RepositoryID: repoID,
+	}
+}
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return capture.NewPGStorage(db) })
+
+	storagetest.RunCaptureStore(t, capture.NewPGStorage(db))
+}
+
+func TestPGStorageDeleteExpiredCaptures(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return capture.NewPGStorage(db) })
+	s := capture.NewPGStorage(db)
+
+	repoID, otherRepoID := kallax.NewULID(), kallax.NewULID()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	old, removed, recent := newCapture(repoID, now.Add(-time.Hour)), newCapture(repoID, now.Add(-time.Hour)), newCapture(repoID, now)
+	other := newCapture(otherRepoID, now.Add(-time.Hour))
+	require.Nil(t, s.CreateCaptures(old, removed, recent, other))
+	removed.DeletedAt = &now
+	require.Nil(t, s.Save(&removed))
+
+	expired, err := s.ExpiredCaptures(repoID, now.Add(-time.Minute), 10)
+	require.Nil(t, err)
+	require.Len(t, expired, 1)
+	assert.Equal(t, old.ID, expired[0].ID)
+
+	deleted, err := s.DeleteCaptures(repoID, now.Add(-time.Minute), old.ID, recent.ID, other.ID)
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), deleted)
+	expired, err = s.ExpiredCaptures(repoID, now.Add(-time.Minute), 10)
+	require.Nil(t, err)
+	assert.Empty(t, expired)
+
+	purged, err := s.PurgeCaptures(repoID, now.Add(-time.Minute))
+	require.Nil(t, err)
+	assert.Equal(t, int64(1), purged)
+	_, err = s.Get(recent.ID, repoID)
+	assert.Nil(t, err)
+	_, err = s.Get(other.ID, otherRepoID)
+	assert.Nil(t, err)
+}
+
+func TestPGStorageReplaceCapturesByRollups(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return capture.NewPGStorage(db) })
+	s := capture.NewPGStorage(db)
+
+	repoID := kallax.NewULID()
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	c1, c2 := newCapture(repoID, now.Add(-time.Hour)), newCapture(repoID, now.Add(-time.Hour))
+	require.Nil(t, s.CreateCaptures(c1, c2))
+
+	rollup := newCapture(repoID, now.Add(-time.Hour))
+	rollup.Rollup = &domain.Rollup{Resolution: 60, Aggregate: domain.AvgAggregate, Samples: 1}
+	require.Nil(t, s.ReplaceCaptures([]domain.Capture{c1}, []domain.Capture{rollup}))
+	_, err := s.Get(c1.ID, repoID)
+	assert.Error(t, err)
+	got, err := s.Get(rollup.ID, repoID)
+	require.Nil(t, err)
+	assert.Equal(t, rollup.Rollup, got.Rollup)
+
+	// c1 was already replaced, nothing is changed.
+	other := newCapture(repoID, now.Add(-time.Hour))
+	assert.Error(t, s.ReplaceCaptures([]domain.Capture{c1, c2}, []domain.Capture{other}))
+	_, err = s.Get(c2.ID, repoID)
+	assert.Nil(t, err)
+	_, err = s.Get(other.ID, repoID)
+	assert.Error(t, err)
+}
@@ -0,0 +1,128 @@
+package collaborator
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type collaboratorNotFound string
+
+func (u collaboratorNotFound) Error() string  { return string(u) }
+func (u collaboratorNotFound) NotFound() bool { return true }
+
+type uniqueConstraintErr string
+
+func (u uniqueConstraintErr) Error() string          { return string(u) }
+func (u uniqueConstraintErr) UniqueConstraint() bool { return true }
+
+func isUniqueConstraintError(err error) bool {
+	if pqErr, ok := err.(pg.Error); ok {
+		return pqErr.IntegrityViolation()
+	}
+	return false
+}
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	if err := p.db.CreateTable(&domain.Collaborator{}, opts); err != nil {
+		return errors.Wrap(err, "creating collaborator schema")
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	if err := p.db.DropTable(&domain.Collaborator{}, opts); err != nil {
+		return errors.Wrap(err, "dropping collaborator schema")
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateCollaborator(c *domain.Collaborator) error {
+	if err := p.db.Insert(c); err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithStack(uniqueConstraintErr(err.Error()))
+		}
+		return errors.Wrap(err, "err saving collaborator with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListCollaborators(repoID kallax.ULID) ([]domain.Collaborator, error) {
+	var collaborators []domain.Collaborator
+	err := p.db.Model(&collaborators).
+		Where("repository_id = ?", repoID).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing collaborators with pgstorage")
+	}
+	return collaborators, nil
+}
+
+func (p *PGStorage) GetCollaborator(id, repoID kallax.ULID) (*domain.Collaborator, error) {
+	var c domain.Collaborator
+	err := p.db.Model(&c).
+		Where("id = ?", id).
+		Where("repository_id = ?", repoID).
+		First()
+	if err != nil {
+		errStr := fmt.Sprintf("collaborator with id %s not found in repo %v", id, repoID)
+		return nil, errors.WithStack(collaboratorNotFound(errStr))
+	}
+	return &c, nil
+}
+
+func (p *PGStorage) GetRepoCollaborator(repoID, userID kallax.ULID) (*domain.Collaborator, error) {
+	var c domain.Collaborator
+	err := p.db.Model(&c).
+		Where("repository_id = ?", repoID).
+		Where("user_id = ?", userID).
+		First()
+	if err != nil {
+		errStr := fmt.Sprintf("user %s is not a collaborator of repo %v", userID, repoID)
+		return nil, errors.WithStack(collaboratorNotFound(errStr))
+	}
+	return &c, nil
+}
+
+func (p *PGStorage) ListInvitations(userID kallax.ULID) ([]domain.Collaborator, error) {
+	var invitations []domain.Collaborator
+	err := p.db.Model(&invitations).
+		Where("user_id = ?", userID).
+		Where("accepted_at IS NULL").
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing invitations with pgstorage")
+	}
+	return invitations, nil
+}
+
+func (p *PGStorage) SaveCollaborator(c *domain.Collaborator) error {
+	if err := p.db.Update(c); err != nil {
+		return errors.Wrapf(err, "err updating collaborator %s with pgstorage", c.ID)
+	}
+	return nil
+}
+
+func (p *PGStorage) RemoveCollaborator(c *domain.Collaborator) error {
+	if err := p.db.Delete(c); err != nil {
+		return errors.Wrapf(err, "err removing collaborator %s with pgstorage", c.ID)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package collaborator_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/collaborator"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return collaborator.NewPGStorage(db) })
+
+	storagetest.RunCollaboratorStore(t, collaborator.NewPGStorage(db))
+}
@@ -0,0 +1,18 @@
+package geofence
+
+import (
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type eventFilter domain.Listing
+
+func (f *eventFilter) Filter(q *orm.Query) (*orm.Query, error) {
+	if f.Owner != nil {
+		q = q.Where("geofence_id = ?", *f.Owner)
+	}
+	return q.Order(f.SortKey).
+		Offset(int(f.Offset)).
+		Limit(f.Limit), nil
+}
@@ -0,0 +1,119 @@
+package geofence
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type geofenceNotFound string
+
+func (u geofenceNotFound) Error() string  { return string(u) }
+func (u geofenceNotFound) NotFound() bool { return true }
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	for _, model := range []interface{}{&domain.Geofence{}, &domain.GeofenceEvent{}} {
+		if err := p.db.CreateTable(model, opts); err != nil {
+			return errors.Wrap(err, "creating geofence schema")
+		}
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	for _, model := range []interface{}{&domain.Geofence{}, &domain.GeofenceEvent{}} {
+		if err := p.db.DropTable(model, opts); err != nil {
+			return errors.Wrap(err, "dropping geofence schema")
+		}
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateGeofence(g *domain.Geofence) error {
+	if err := p.db.Insert(g); err != nil {
+		return errors.Wrap(err, "err saving geofence with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListGeofences(repoID kallax.ULID) ([]domain.Geofence, error) {
+	var geofences []domain.Geofence
+	err := p.db.Model(&geofences).
+		Where("repository_id = ?", repoID).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing geofences with pgstorage")
+	}
+	return geofences, nil
+}
+
+func (p *PGStorage) GetGeofence(geofenceID, repoID kallax.ULID) (*domain.Geofence, error) {
+	var g domain.Geofence
+	err := p.db.Model(&g).
+		Where("id = ?", geofenceID).
+		Where("repository_id = ?", repoID).
+		First()
+	if err != nil {
+		errStr := fmt.Sprintf("geofence with id %s not found in repo %v", geofenceID, repoID)
+		return nil, errors.WithStack(geofenceNotFound(errStr))
+	}
+	return &g, nil
+}
+
+func (p *PGStorage) SaveGeofence(g *domain.Geofence) error {
+	if err := p.db.Update(g); err != nil {
+		errStr := fmt.Sprintf("error saving the geofence %s in repo %v", g.ID, g.RepositoryID)
+		return errors.Wrap(err, errStr)
+	}
+	return nil
+}
+
+func (p *PGStorage) RecordGeofenceEvent(e domain.GeofenceEvent) (bool, error) {
+	var recorded bool
+	err := p.db.RunInTransaction(func(tx *pg.Tx) error {
+		inside := e.Type == domain.Enter
+		res, err := tx.Model(&domain.Geofence{}).
+			Set("inside = ?", inside).
+			Where("id = ?", e.GeofenceID).
+			Where("inside <> ?", inside).
+			Update()
+		if err != nil {
+			return errors.Wrapf(err, "error updating the geofence %s", e.GeofenceID)
+		}
+		if res.RowsAffected() == 0 {
+			return nil
+		}
+		if err := tx.Insert(&e); err != nil {
+			return errors.Wrap(err, "err saving geofence event with pgstorage")
+		}
+		recorded = true
+		return nil
+	})
+	return recorded, err
+}
+
+func (p *PGStorage) ListGeofenceEvents(l *domain.Listing) ([]domain.GeofenceEvent, int64, error) {
+	var events []domain.GeofenceEvent
+	f := eventFilter(*l)
+	total, err := p.db.Model(&events).Apply(f.Filter).SelectAndCount()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "err listing geofence events with pgstorage")
+	}
+	return events, int64(total), nil
+}
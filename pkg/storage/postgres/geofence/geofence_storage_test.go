@@ -0,0 +1,16 @@
+package geofence_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/geofence"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return geofence.NewPGStorage(db) })
+
+	storagetest.RunGeofenceStore(t, geofence.NewPGStorage(db))
+}
@@ -0,0 +1,163 @@
+package oauth
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type oauthNotFound string
+
+func (u oauthNotFound) Error() string  { return string(u) }
+func (u oauthNotFound) NotFound() bool { return true }
+
+var models = []interface{}{&domain.OAuthClient{}, &domain.OAuthCode{}, &domain.OAuthConsent{}}
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	for _, model := range models {
+		if err := p.db.CreateTable(model, opts); err != nil {
+			return errors.Wrap(err, "creating oauth schema")
+		}
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	for _, model := range models {
+		if err := p.db.DropTable(model, opts); err != nil {
+			return errors.Wrap(err, "dropping oauth schema")
+		}
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateOAuthClient(c *domain.OAuthClient) error {
+	if err := p.db.Insert(c); err != nil {
+		return errors.Wrap(err, "err saving oauth client with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListOAuthClients(userID kallax.ULID) ([]domain.OAuthClient, error) {
+	var clients []domain.OAuthClient
+	err := p.db.Model(&clients).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing oauth clients with pgstorage")
+	}
+	return clients, nil
+}
+
+func (p *PGStorage) GetOAuthClient(id kallax.ULID) (*domain.OAuthClient, error) {
+	var c domain.OAuthClient
+	if err := p.db.Model(&c).Where("id = ?", id).First(); err != nil {
+		return nil, errors.WithStack(oauthNotFound(fmt.Sprintf("oauth client with id %s not found", id)))
+	}
+	return &c, nil
+}
+
+func (p *PGStorage) RemoveOAuthClient(c *domain.OAuthClient) error {
+	err := p.db.RunInTransaction(func(tx *pg.Tx) error {
+		if _, err := tx.Model(&domain.OAuthCode{}).Where("client_id = ?", c.ID).Delete(); err != nil {
+			return err
+		}
+		if _, err := tx.Model(&domain.OAuthConsent{}).Where("client_id = ?", c.ID).Delete(); err != nil {
+			return err
+		}
+		return tx.Delete(c)
+	})
+	if err != nil {
+		return errors.Wrap(err, "err removing oauth client with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateOAuthCode(c *domain.OAuthCode) error {
+	if err := p.db.Insert(c); err != nil {
+		return errors.Wrap(err, "err saving oauth code with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ConsumeOAuthCode(hash string, at time.Time) (*domain.OAuthCode, error) {
+	var c domain.OAuthCode
+	res, err := p.db.Model(&c).
+		Set("used_at = ?", at).
+		Where("hash = ?", hash).
+		Where("used_at IS NULL").
+		Where("expires_at > ?", at).
+		Returning("*").
+		Update()
+	if err != nil {
+		return nil, errors.Wrap(err, "err consuming oauth code with pgstorage")
+	}
+	if res.RowsAffected() == 0 {
+		return nil, errors.WithStack(oauthNotFound("oauth code not found"))
+	}
+	return &c, nil
+}
+
+func (p *PGStorage) GetOAuthConsent(userID, clientID kallax.ULID) (*domain.OAuthConsent, error) {
+	var c domain.OAuthConsent
+	err := p.db.Model(&c).
+		Where("user_id = ?", userID).
+		Where("client_id = ?", clientID).
+		First()
+	if err != nil {
+		return nil, errors.WithStack(oauthNotFound(fmt.Sprintf("oauth consent to client %s not found", clientID)))
+	}
+	return &c, nil
+}
+
+func (p *PGStorage) SaveOAuthConsent(c *domain.OAuthConsent) error {
+	_, err := p.db.Model(c).
+		OnConflict("(user_id, client_id) DO UPDATE").
+		Set("scopes = EXCLUDED.scopes").
+		Set("updated_at = EXCLUDED.updated_at").
+		Insert()
+	if err != nil {
+		return errors.Wrap(err, "err saving oauth consent with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListOAuthConsents(userID kallax.ULID) ([]domain.OAuthConsent, error) {
+	var consents []domain.OAuthConsent
+	err := p.db.Model(&consents).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing oauth consents with pgstorage")
+	}
+	return consents, nil
+}
+
+func (p *PGStorage) RemoveOAuthConsent(userID, clientID kallax.ULID) error {
+	_, err := p.db.Model(&domain.OAuthConsent{}).
+		Where("user_id = ?", userID).
+		Where("client_id = ?", clientID).
+		Delete()
+	if err != nil {
+		return errors.Wrap(err, "err removing oauth consent with pgstorage")
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package oauth_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/oauth"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return oauth.NewPGStorage(db) })
+
+	storagetest.RunOAuthStore(t, oauth.NewPGStorage(db))
+}
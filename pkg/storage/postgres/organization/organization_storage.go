@@ -0,0 +1,249 @@
+package organization
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type organizationNotFound string
+
+func (u organizationNotFound) Error() string  { return string(u) }
+func (u organizationNotFound) NotFound() bool { return true }
+
+type uniqueConstraintErr string
+
+func (u uniqueConstraintErr) Error() string          { return string(u) }
+func (u uniqueConstraintErr) UniqueConstraint() bool { return true }
+
+func isUniqueConstraintError(err error) bool {
+	if pqErr, ok := err.(pg.Error); ok {
+		return pqErr.IntegrityViolation()
+	}
+	return false
+}
+
+func tables() []interface{} {
+	return []interface{}{&domain.Organization{}, &domain.Member{}, &domain.Team{}, &domain.TeamMember{}}
+}
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	for _, model := range tables() {
+		if err := p.db.CreateTable(model, opts); err != nil {
+			return errors.Wrap(err, "creating organization schema")
+		}
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	for _, model := range tables() {
+		if err := p.db.DropTable(model, opts); err != nil {
+			return errors.Wrap(err, "dropping organization schema")
+		}
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateOrganization(org *domain.Organization, owner *domain.Member) error {
+	err := p.db.RunInTransaction(func(tx *pg.Tx) error {
+		if err := tx.Insert(org); err != nil {
+			return err
+		}
+		return tx.Insert(owner)
+	})
+	if err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithStack(uniqueConstraintErr(err.Error()))
+		}
+		return errors.Wrap(err, "err saving organization with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListUserOrganizations(userID kallax.ULID) ([]domain.Organization, error) {
+	var orgs []domain.Organization
+	err := p.db.Model(&orgs).
+		Join("JOIN members AS m ON m.organization_id = organization.id").
+		Where("m.user_id = ?", userID).
+		Order("organization.name ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing organizations with pgstorage")
+	}
+	return orgs, nil
+}
+
+func (p *PGStorage) GetOrganization(id kallax.ULID) (*domain.Organization, error) {
+	var org domain.Organization
+	if err := p.db.Model(&org).Where("id = ?", id).First(); err != nil {
+		return nil, errors.WithStack(organizationNotFound(fmt.Sprintf("organization with id %s not found", id)))
+	}
+	return &org, nil
+}
+
+func (p *PGStorage) CreateMember(m *domain.Member) error {
+	if err := p.db.Insert(m); err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithStack(uniqueConstraintErr(err.Error()))
+		}
+		return errors.Wrap(err, "err saving member with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListMembers(orgID kallax.ULID) ([]domain.Member, error) {
+	var members []domain.Member
+	err := p.db.Model(&members).
+		Where("organization_id = ?", orgID).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing members with pgstorage")
+	}
+	return members, nil
+}
+
+func (p *PGStorage) GetMember(id, orgID kallax.ULID) (*domain.Member, error) {
+	var m domain.Member
+	err := p.db.Model(&m).
+		Where("id = ?", id).
+		Where("organization_id = ?", orgID).
+		First()
+	if err != nil {
+		errStr := fmt.Sprintf("member with id %s not found in organization %v", id, orgID)
+		return nil, errors.WithStack(organizationNotFound(errStr))
+	}
+	return &m, nil
+}
+
+func (p *PGStorage) GetUserMember(orgID, userID kallax.ULID) (*domain.Member, error) {
+	var m domain.Member
+	err := p.db.Model(&m).
+		Where("organization_id = ?", orgID).
+		Where("user_id = ?", userID).
+		First()
+	if err != nil {
+		errStr := fmt.Sprintf("user %s is not a member of organization %v", userID, orgID)
+		return nil, errors.WithStack(organizationNotFound(errStr))
+	}
+	return &m, nil
+}
+
+func (p *PGStorage) RemoveMember(m *domain.Member) error {
+	err := p.db.RunInTransaction(func(tx *pg.Tx) error {
+		_, err := tx.Model(&domain.TeamMember{}).
+			Where("user_id = ?", m.UserID).
+			Where("team_id IN (SELECT id FROM teams WHERE organization_id = ?)", m.OrganizationID).
+			Delete()
+		if err != nil {
+			return err
+		}
+		return tx.Delete(m)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "err removing member %s with pgstorage", m.ID)
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateTeam(t *domain.Team) error {
+	if err := p.db.Insert(t); err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithStack(uniqueConstraintErr(err.Error()))
+		}
+		return errors.Wrap(err, "err saving team with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListTeams(orgID kallax.ULID) ([]domain.Team, error) {
+	var teams []domain.Team
+	err := p.db.Model(&teams).
+		Where("organization_id = ?", orgID).
+		Order("name ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing teams with pgstorage")
+	}
+	return teams, nil
+}
+
+func (p *PGStorage) GetTeam(id, orgID kallax.ULID) (*domain.Team, error) {
+	var t domain.Team
+	err := p.db.Model(&t).
+		Where("id = ?", id).
+		Where("organization_id = ?", orgID).
+		First()
+	if err != nil {
+		errStr := fmt.Sprintf("team with id %s not found in organization %v", id, orgID)
+		return nil, errors.WithStack(organizationNotFound(errStr))
+	}
+	return &t, nil
+}
+
+func (p *PGStorage) RemoveTeam(t *domain.Team) error {
+	err := p.db.RunInTransaction(func(tx *pg.Tx) error {
+		if _, err := tx.Model(&domain.TeamMember{}).Where("team_id = ?", t.ID).Delete(); err != nil {
+			return err
+		}
+		return tx.Delete(t)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "err removing team %s with pgstorage", t.ID)
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateTeamMember(tm *domain.TeamMember) error {
+	if err := p.db.Insert(tm); err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithStack(uniqueConstraintErr(err.Error()))
+		}
+		return errors.Wrap(err, "err saving team member with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) RemoveTeamMember(teamID, userID kallax.ULID) error {
+	res, err := p.db.Model(&domain.TeamMember{}).
+		Where("team_id = ?", teamID).
+		Where("user_id = ?", userID).
+		Delete()
+	if err != nil {
+		return errors.Wrapf(err, "err removing member of team %s with pgstorage", teamID)
+	}
+	if res.RowsAffected() == 0 {
+		errStr := fmt.Sprintf("user %s is not a member of team %v", userID, teamID)
+		return errors.WithStack(organizationNotFound(errStr))
+	}
+	return nil
+}
+
+func (p *PGStorage) ListUserTeams(orgID, userID kallax.ULID) ([]domain.Team, error) {
+	var teams []domain.Team
+	err := p.db.Model(&teams).
+		Join("JOIN team_members AS tm ON tm.team_id = team.id").
+		Where("team.organization_id = ?", orgID).
+		Where("tm.user_id = ?", userID).
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing teams of user with pgstorage")
+	}
+	return teams, nil
+}
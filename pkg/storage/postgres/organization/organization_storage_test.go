@@ -0,0 +1,16 @@
+package organization_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/organization"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return organization.NewPGStorage(db) })
+
+	storagetest.RunOrganizationStore(t, organization.NewPGStorage(db))
+}
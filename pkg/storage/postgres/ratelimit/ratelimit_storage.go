@@ -0,0 +1,65 @@
+package ratelimit
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	if err := p.db.CreateTable(&domain.RateBucket{}, opts); err != nil {
+		return errors.Wrap(err, "creating rate bucket schema")
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	if err := p.db.DropTable(&domain.RateBucket{}, opts); err != nil {
+		return errors.Wrap(err, "dropping rate bucket schema")
+	}
+	return nil
+}
+
+// TakeRateToken takes a token from the bucket of the key. The row of the bucket is locked
+// while the token is taken, so every instance sharing the database agrees on the limit.
+func (p *PGStorage) TakeRateToken(key string, l domain.RateLimit, now time.Time) (*domain.RateBucket, bool, error) {
+	b := domain.NewRateBucket(key, l, now)
+	var taken bool
+	err := p.db.RunInTransaction(func(tx *pg.Tx) error {
+		if _, err := tx.Model(b).OnConflict("(key) DO NOTHING").Insert(); err != nil {
+			return err
+		}
+		if err := tx.Model(b).WherePK().For("UPDATE").Select(); err != nil {
+			return err
+		}
+		taken = b.Take(l, now)
+		_, err := tx.Model(b).WherePK().Update()
+		return err
+	})
+	if err != nil {
+		return nil, false, errors.Wrap(err, "err taking rate token with pgstorage")
+	}
+	return b, taken, nil
+}
+
+func (p *PGStorage) PruneRateBuckets(before time.Time) error {
+	_, err := p.db.Model(&domain.RateBucket{}).Where("updated_at < ?", before).Delete()
+	if err != nil {
+		return errors.Wrap(err, "err pruning rate buckets with pgstorage")
+	}
+	return nil
+}
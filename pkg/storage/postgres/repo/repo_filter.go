@@ -10,7 +10,10 @@ type filter domain.Listing
 
 func (f *filter) Filter(q *orm.Query) (*orm.Query, error) {
 	if f.Owner != nil {
-		q = q.Where("user_id = ?", *f.Owner)
+		q = q.Where("user_id = ?", *f.Owner).Where("organization_id IS NULL")
+	}
+	if f.Organization != nil {
+		q = q.Where("organization_id = ?", *f.Organization)
 	}
 	if f.Visibility != nil {
 		q = q.Where("visibility = ?", *f.Visibility)
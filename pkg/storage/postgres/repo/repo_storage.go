@@ -2,6 +2,7 @@ package repo
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/go-pg/pg"
 	"github.com/go-pg/pg/orm"
@@ -68,3 +69,46 @@ func (p *PGStorage) Get(id kallax.ULID) (*domain.Repository, error) {
 	}
 	return &repo, nil
 }
+
+func (p *PGStorage) UpdateRepo(repo *domain.Repository) error {
+	if err := p.db.Update(repo); err != nil {
+		if err == pg.ErrNoRows {
+			return errors.WithStack(repoNotFound(fmt.Sprintf("repo with id %s not found", repo.ID)))
+		}
+		return errors.Wrapf(err, "err updating repo %s with pgstorage", repo.ID)
+	}
+	return nil
+}
+
+// TransferRepo saves the owner of a repository, moving its usage to the new owner.
+func (p *PGStorage) TransferRepo(repo *domain.Repository) error {
+	err := p.db.RunInTransaction(func(tx *pg.Tx) error {
+		if err := tx.Update(repo); err != nil {
+			return err
+		}
+		_, err := tx.Model((*domain.Usage)(nil)).
+			Set("user_id = ?", repo.UserID).
+			Where("repository_id = ?", repo.ID).
+			Update()
+		return err
+	})
+	if err == pg.ErrNoRows {
+		return errors.WithStack(repoNotFound(fmt.Sprintf("repo with id %s not found", repo.ID)))
+	}
+	if err != nil {
+		return errors.Wrapf(err, "err transferring repo %s with pgstorage", repo.ID)
+	}
+	return nil
+}
+
+func (p *PGStorage) RemoveUserRepos(userID kallax.ULID, at time.Time) error {
+	_, err := p.db.Model(&domain.Repository{}).
+		Set("deleted_at = ?", at).
+		Where("user_id = ?", userID).
+		Where("organization_id IS NULL").
+		Update()
+	if err != nil {
+		return errors.Wrapf(err, "err removing repos of user %s with pgstorage", userID)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/repo"
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/usage"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t,
+		func(db *pg.DB) storagetest.Schema { return repo.NewPGStorage(db) },
+		func(db *pg.DB) storagetest.Schema { return usage.NewPGStorage(db) })
+
+	storagetest.RunRepoStore(t, repo.NewPGStorage(db))
+}
@@ -0,0 +1,18 @@
+package retention
+
+import (
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type reportFilter domain.Listing
+
+func (f *reportFilter) Filter(q *orm.Query) (*orm.Query, error) {
+	if f.Owner != nil {
+		q = q.Where("repository_id = ?", *f.Owner)
+	}
+	return q.Order(f.SortKey).
+		Offset(int(f.Offset)).
+		Limit(f.Limit), nil
+}
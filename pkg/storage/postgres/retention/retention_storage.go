@@ -0,0 +1,100 @@
+package retention
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type policyNotFound string
+
+func (u policyNotFound) Error() string  { return string(u) }
+func (u policyNotFound) NotFound() bool { return true }
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	for _, model := range []interface{}{&domain.RetentionPolicy{}, &domain.RetentionReport{}} {
+		if err := p.db.CreateTable(model, opts); err != nil {
+			return errors.Wrap(err, "creating retention schema")
+		}
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	for _, model := range []interface{}{&domain.RetentionPolicy{}, &domain.RetentionReport{}} {
+		if err := p.db.DropTable(model, opts); err != nil {
+			return errors.Wrap(err, "dropping retention schema")
+		}
+	}
+	return nil
+}
+
+func (p *PGStorage) GetRetentionPolicy(repoID kallax.ULID) (*domain.RetentionPolicy, error) {
+	policy := domain.RetentionPolicy{RepositoryID: repoID}
+	if err := p.db.Model(&policy).WherePK().Select(); err != nil {
+		errStr := fmt.Sprintf("retention policy not found in repo %v", repoID)
+		return nil, errors.WithStack(policyNotFound(errStr))
+	}
+	return &policy, nil
+}
+
+func (p *PGStorage) SaveRetentionPolicy(policy *domain.RetentionPolicy) error {
+	_, err := p.db.Model(policy).
+		OnConflict("(repository_id) DO UPDATE").
+		Set("days = EXCLUDED.days").
+		Set("action = EXCLUDED.action").
+		Set("updated_at = EXCLUDED.updated_at").
+		Insert()
+	if err != nil {
+		return errors.Wrap(err, "err saving retention policy with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) RemoveRetentionPolicy(repoID kallax.ULID) error {
+	_, err := p.db.Model((*domain.RetentionPolicy)(nil)).Where("repository_id = ?", repoID).Delete()
+	if err != nil {
+		return errors.Wrap(err, "err removing retention policy with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListRetentionPolicies() ([]domain.RetentionPolicy, error) {
+	var policies []domain.RetentionPolicy
+	if err := p.db.Model(&policies).Order("created_at ASC").Select(); err != nil {
+		return nil, errors.Wrap(err, "err listing retention policies with pgstorage")
+	}
+	return policies, nil
+}
+
+func (p *PGStorage) CreateRetentionReport(r *domain.RetentionReport) error {
+	if err := p.db.Insert(r); err != nil {
+		return errors.Wrap(err, "err saving retention report with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListRetentionReports(l *domain.Listing) ([]domain.RetentionReport, int64, error) {
+	var reports []domain.RetentionReport
+	f := reportFilter(*l)
+	total, err := p.db.Model(&reports).Apply(f.Filter).SelectAndCount()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "err listing retention reports with pgstorage")
+	}
+	return reports, int64(total), nil
+}
@@ -0,0 +1,16 @@
+package retention_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/retention"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return retention.NewPGStorage(db) })
+
+	storagetest.RunRetentionStore(t, retention.NewPGStorage(db))
+}
@@ -0,0 +1,89 @@
+package rollup
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type ruleNotFound string
+
+func (u ruleNotFound) Error() string  { return string(u) }
+func (u ruleNotFound) NotFound() bool { return true }
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	if err := p.db.CreateTable(&domain.RollupRule{}, opts); err != nil {
+		return errors.Wrap(err, "creating rollup schema")
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	if err := p.db.DropTable(&domain.RollupRule{}, opts); err != nil {
+		return errors.Wrap(err, "dropping rollup schema")
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateRollupRule(r *domain.RollupRule) error {
+	if err := p.db.Insert(r); err != nil {
+		return errors.Wrap(err, "err saving rollup rule with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListRollupRules(repoID kallax.ULID) ([]domain.RollupRule, error) {
+	var rules []domain.RollupRule
+	err := p.db.Model(&rules).
+		Where("repository_id = ?", repoID).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing rollup rules with pgstorage")
+	}
+	return rules, nil
+}
+
+func (p *PGStorage) GetRollupRule(ruleID, repoID kallax.ULID) (*domain.RollupRule, error) {
+	var r domain.RollupRule
+	err := p.db.Model(&r).
+		Where("id = ?", ruleID).
+		Where("repository_id = ?", repoID).
+		First()
+	if err != nil {
+		errStr := fmt.Sprintf("rollup rule with id %s not found in repo %v", ruleID, repoID)
+		return nil, errors.WithStack(ruleNotFound(errStr))
+	}
+	return &r, nil
+}
+
+func (p *PGStorage) SaveRollupRule(r *domain.RollupRule) error {
+	if err := p.db.Update(r); err != nil {
+		errStr := fmt.Sprintf("error saving the rollup rule %s in repo %v", r.ID, r.RepositoryID)
+		return errors.Wrap(err, errStr)
+	}
+	return nil
+}
+
+func (p *PGStorage) AllRollupRules() ([]domain.RollupRule, error) {
+	var rules []domain.RollupRule
+	if err := p.db.Model(&rules).Order("created_at ASC").Select(); err != nil {
+		return nil, errors.Wrap(err, "err listing rollup rules with pgstorage")
+	}
+	return rules, nil
+}
@@ -0,0 +1,16 @@
+package rollup_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/rollup"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return rollup.NewPGStorage(db) })
+
+	storagetest.RunRollupStore(t, rollup.NewPGStorage(db))
+}
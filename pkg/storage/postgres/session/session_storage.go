@@ -0,0 +1,264 @@
+package session
+
+import (
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type tokenNotFound string
+
+func (u tokenNotFound) Error() string  { return string(u) }
+func (u tokenNotFound) NotFound() bool { return true }
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	for _, model := range []interface{}{&domain.RefreshToken{}, &domain.RevokedToken{}, &domain.UserToken{}, &domain.LoginThrottle{}, &domain.RecoveryCode{}} {
+		if err := p.db.CreateTable(model, opts); err != nil {
+			return errors.Wrap(err, "creating session schema")
+		}
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	for _, model := range []interface{}{&domain.RefreshToken{}, &domain.RevokedToken{}, &domain.UserToken{}, &domain.LoginThrottle{}, &domain.RecoveryCode{}} {
+		if err := p.db.DropTable(model, opts); err != nil {
+			return errors.Wrap(err, "dropping session schema")
+		}
+	}
+	return nil
+}
+
+// purgeExpired deletes the refresh tokens and the denied tokens already expired.
+func (p *PGStorage) purgeExpired() error {
+	now := time.Now()
+	if _, err := p.db.Model((*domain.RefreshToken)(nil)).Where("expires_at <= ?", now).Delete(); err != nil {
+		return errors.Wrap(err, "err purging expired refresh tokens with pgstorage")
+	}
+	if _, err := p.db.Model((*domain.RevokedToken)(nil)).Where("expires_at <= ?", now).Delete(); err != nil {
+		return errors.Wrap(err, "err purging expired denied tokens with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateRefreshToken(t *domain.RefreshToken) error {
+	if err := p.purgeExpired(); err != nil {
+		return err
+	}
+	if err := p.db.Insert(t); err != nil {
+		return errors.Wrap(err, "err saving refresh token with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) GetRefreshToken(hash string) (*domain.RefreshToken, error) {
+	var t domain.RefreshToken
+	err := p.db.Model(&t).Where("hash = ?", hash).First()
+	if err != nil {
+		return nil, errors.WithStack(tokenNotFound("refresh token not found"))
+	}
+	return &t, nil
+}
+
+func (p *PGStorage) RevokeRefreshToken(hash string, at time.Time, replacedBy *kallax.ULID) (bool, error) {
+	res, err := p.db.Model(&domain.RefreshToken{}).
+		Set("revoked_at = ?", at).
+		Set("replaced_by = ?", replacedBy).
+		Where("hash = ?", hash).
+		Where("revoked_at IS NULL").
+		Update()
+	if err != nil {
+		return false, errors.Wrap(err, "err revoking refresh token with pgstorage")
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+func (p *PGStorage) RevokeUserRefreshTokens(userID kallax.ULID, at time.Time) error {
+	_, err := p.db.Model(&domain.RefreshToken{}).
+		Set("revoked_at = ?", at).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Update()
+	if err != nil {
+		return errors.Wrap(err, "err revoking refresh tokens with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) DenyToken(jti string, expiresAt time.Time) error {
+	if err := p.purgeExpired(); err != nil {
+		return err
+	}
+	t := &domain.RevokedToken{JTI: jti, ExpiresAt: expiresAt}
+	_, err := p.db.Model(t).OnConflict("DO NOTHING").Insert()
+	if err != nil {
+		return errors.Wrap(err, "err denying token with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) IsTokenDenied(jti string) (bool, error) {
+	count, err := p.db.Model(&domain.RevokedToken{}).
+		Where("jti = ?", jti).
+		Where("expires_at > ?", time.Now()).
+		Count()
+	if err != nil {
+		return false, errors.Wrap(err, "err checking denied token with pgstorage")
+	}
+	return count > 0, nil
+}
+
+func (p *PGStorage) CreateUserToken(t *domain.UserToken) error {
+	if err := p.db.Insert(t); err != nil {
+		return errors.Wrap(err, "err saving user token with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ConsumeUserToken(hash string, purpose domain.UserTokenPurpose, at time.Time) (*domain.UserToken, error) {
+	var t domain.UserToken
+	res, err := p.db.Model(&t).
+		Set("used_at = ?", at).
+		Where("hash = ?", hash).
+		Where("purpose = ?", purpose).
+		Where("used_at IS NULL").
+		Where("expires_at > ?", at).
+		Returning("*").
+		Update()
+	if err != nil {
+		return nil, errors.Wrap(err, "err consuming user token with pgstorage")
+	}
+	if res.RowsAffected() == 0 {
+		return nil, errors.WithStack(tokenNotFound("user token not found"))
+	}
+	return &t, nil
+}
+
+func (p *PGStorage) GetActiveUserToken(hash string, purpose domain.UserTokenPurpose, at time.Time) (*domain.UserToken, error) {
+	var t domain.UserToken
+	err := p.db.Model(&t).
+		Where("hash = ?", hash).
+		Where("purpose = ?", purpose).
+		Where("used_at IS NULL").
+		Where("expires_at > ?", at).
+		First()
+	if err != nil {
+		if err == pg.ErrNoRows {
+			return nil, errors.WithStack(tokenNotFound("user token not found"))
+		}
+		return nil, errors.Wrap(err, "err getting user token with pgstorage")
+	}
+	return &t, nil
+}
+
+func (p *PGStorage) UseUserTokens(userID kallax.ULID, purpose domain.UserTokenPurpose, at time.Time) error {
+	_, err := p.db.Model(&domain.UserToken{}).
+		Set("used_at = ?", at).
+		Where("user_id = ?", userID).
+		Where("purpose = ?", purpose).
+		Where("used_at IS NULL").
+		Update()
+	if err != nil {
+		return errors.Wrap(err, "err using user tokens with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) GetLoginThrottles(keys ...string) ([]domain.LoginThrottle, error) {
+	var throttles []domain.LoginThrottle
+	err := p.db.Model(&throttles).Where("key IN (?)", pg.In(keys)).Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err getting login throttles with pgstorage")
+	}
+	return throttles, nil
+}
+
+func (p *PGStorage) FailLoginThrottle(key string, at, windowStart time.Time) (*domain.LoginThrottle, error) {
+	t := &domain.LoginThrottle{Key: key, Failures: 1, LastFailureAt: at}
+	expired := "?TableAlias.last_failure_at < ? OR ?TableAlias.locked_until <= ?"
+	_, err := p.db.Model(t).
+		OnConflict("(key) DO UPDATE").
+		Set("failures = CASE WHEN "+expired+" THEN 1 ELSE ?TableAlias.failures + 1 END", windowStart, at).
+		Set("last_failure_at = EXCLUDED.last_failure_at").
+		Set("locked_until = CASE WHEN "+expired+" THEN NULL ELSE ?TableAlias.locked_until END", windowStart, at).
+		Returning("*").
+		Insert()
+	if err != nil {
+		return nil, errors.Wrap(err, "err saving login throttle with pgstorage")
+	}
+	return t, nil
+}
+
+func (p *PGStorage) LockLoginThrottle(key string, until time.Time) error {
+	_, err := p.db.Model(&domain.LoginThrottle{}).
+		Set("locked_until = ?", until).
+		Where("key = ?", key).
+		Update()
+	if err != nil {
+		return errors.Wrap(err, "err locking login throttle with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) RemoveLoginThrottles(keys ...string) error {
+	_, err := p.db.Model(&domain.LoginThrottle{}).Where("key IN (?)", pg.In(keys)).Delete()
+	if err != nil {
+		return errors.Wrap(err, "err removing login throttles with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ReplaceRecoveryCodes(userID kallax.ULID, codes []domain.RecoveryCode) error {
+	err := p.db.RunInTransaction(func(tx *pg.Tx) error {
+		if _, err := tx.Model(&domain.RecoveryCode{}).Where("user_id = ?", userID).Delete(); err != nil {
+			return err
+		}
+		if len(codes) == 0 {
+			return nil
+		}
+		return tx.Insert(&codes)
+	})
+	if err != nil {
+		return errors.Wrap(err, "err replacing recovery codes with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) UseRecoveryCode(userID kallax.ULID, hash string, at time.Time) error {
+	res, err := p.db.Model(&domain.RecoveryCode{}).
+		Set("used_at = ?", at).
+		Where("user_id = ?", userID).
+		Where("hash = ?", hash).
+		Where("used_at IS NULL").
+		Update()
+	if err != nil {
+		return errors.Wrap(err, "err using recovery code with pgstorage")
+	}
+	if res.RowsAffected() == 0 {
+		return errors.WithStack(tokenNotFound("recovery code not found"))
+	}
+	return nil
+}
+
+func (p *PGStorage) RemoveRecoveryCodes(userID kallax.ULID) error {
+	_, err := p.db.Model(&domain.RecoveryCode{}).Where("user_id = ?", userID).Delete()
+	if err != nil {
+		return errors.Wrap(err, "err removing recovery codes with pgstorage")
+	}
+	return nil
+}
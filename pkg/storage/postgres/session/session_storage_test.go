@@ -0,0 +1,16 @@
+package session_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/session"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return session.NewPGStorage(db) })
+
+	storagetest.RunSessionStore(t, session.NewPGStorage(db))
+}
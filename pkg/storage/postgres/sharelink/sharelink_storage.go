@@ -0,0 +1,89 @@
+package sharelink
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type linkNotFound string
+
+func (u linkNotFound) Error() string  { return string(u) }
+func (u linkNotFound) NotFound() bool { return true }
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	if err := p.db.CreateTable(&domain.ShareLink{}, opts); err != nil {
+		return errors.Wrap(err, "creating share link schema")
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	if err := p.db.DropTable(&domain.ShareLink{}, opts); err != nil {
+		return errors.Wrap(err, "dropping share link schema")
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateShareLink(l *domain.ShareLink) error {
+	if err := p.db.Insert(l); err != nil {
+		return errors.Wrap(err, "err saving share link with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListShareLinks(repoID kallax.ULID) ([]domain.ShareLink, error) {
+	var links []domain.ShareLink
+	err := p.db.Model(&links).
+		Where("repository_id = ?", repoID).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing share links with pgstorage")
+	}
+	return links, nil
+}
+
+func (p *PGStorage) GetShareLink(linkID, repoID kallax.ULID) (*domain.ShareLink, error) {
+	var l domain.ShareLink
+	err := p.db.Model(&l).
+		Where("id = ?", linkID).
+		Where("repository_id = ?", repoID).
+		First()
+	if err != nil {
+		errStr := fmt.Sprintf("share link with id %s not found in repo %v", linkID, repoID)
+		return nil, errors.WithStack(linkNotFound(errStr))
+	}
+	return &l, nil
+}
+
+func (p *PGStorage) GetShareLinkByID(linkID kallax.ULID) (*domain.ShareLink, error) {
+	var l domain.ShareLink
+	if err := p.db.Model(&l).Where("id = ?", linkID).First(); err != nil {
+		return nil, errors.WithStack(linkNotFound(fmt.Sprintf("share link with id %s not found", linkID)))
+	}
+	return &l, nil
+}
+
+func (p *PGStorage) SaveShareLink(l *domain.ShareLink) error {
+	if err := p.db.Update(l); err != nil {
+		errStr := fmt.Sprintf("error saving the share link %s in repo %v", l.ID, l.RepositoryID)
+		return errors.Wrap(err, errStr)
+	}
+	return nil
+}
@@ -0,0 +1,16 @@
+package sharelink_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/sharelink"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return sharelink.NewPGStorage(db) })
+
+	storagetest.RunShareLinkStore(t, sharelink.NewPGStorage(db))
+}
@@ -0,0 +1,103 @@
+package usage
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	if err := p.db.CreateTable(&domain.Usage{}, opts); err != nil {
+		return errors.Wrap(err, "creating usage schema")
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	if err := p.db.DropTable(&domain.Usage{}, opts); err != nil {
+		return errors.Wrap(err, "dropping usage schema")
+	}
+	return nil
+}
+
+func (p *PGStorage) GetRepoUsage(repoID kallax.ULID) (*domain.Usage, error) {
+	u := domain.Usage{RepositoryID: repoID}
+	err := p.db.Model(&u).WherePK().Select()
+	if err != nil && err != pg.ErrNoRows {
+		return nil, errors.Wrap(err, "err getting repository usage with pgstorage")
+	}
+	return &u, nil
+}
+
+func (p *PGStorage) GetUserUsage(userID kallax.ULID) (*domain.Usage, error) {
+	u := domain.Usage{UserID: userID}
+	err := p.db.Model((*domain.Usage)(nil)).
+		ColumnExpr("COALESCE(SUM(captures), 0), COALESCE(SUM(bytes), 0)").
+		Where("user_id = ?", userID).
+		Select(&u.Captures, &u.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "err getting user usage with pgstorage")
+	}
+	return &u, nil
+}
+
+// ReserveUsage locks the usages of every repository of the user while the quotas are
+// checked, so concurrent reservations of the user are serialized.
+func (p *PGStorage) ReserveUsage(delta domain.Usage, check func(repo, user domain.Usage) error) error {
+	err := p.db.RunInTransaction(func(tx *pg.Tx) error {
+		row := &domain.Usage{RepositoryID: delta.RepositoryID, UserID: delta.UserID}
+		if _, err := tx.Model(row).OnConflict("(repository_id) DO NOTHING").Insert(); err != nil {
+			return err
+		}
+		var usages []domain.Usage
+		if err := tx.Model(&usages).Where("user_id = ?", delta.UserID).For("UPDATE").Select(); err != nil {
+			return err
+		}
+		repo := domain.Usage{RepositoryID: delta.RepositoryID, UserID: delta.UserID}
+		user := domain.Usage{UserID: delta.UserID}
+		for _, u := range usages {
+			user = user.Add(u)
+			if u.RepositoryID == delta.RepositoryID {
+				repo = u
+			}
+		}
+		if err := check(repo, user); err != nil {
+			return err
+		}
+		_, err := tx.Model((*domain.Usage)(nil)).
+			Set("captures = captures + ?", delta.Captures).
+			Set("bytes = bytes + ?", delta.Bytes).
+			Where("repository_id = ?", delta.RepositoryID).
+			Update()
+		return err
+	})
+	if err != nil {
+		return errors.Wrap(err, "err reserving usage with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ReleaseUsage(delta domain.Usage) error {
+	_, err := p.db.Model((*domain.Usage)(nil)).
+		Set("captures = GREATEST(captures - ?, 0)", delta.Captures).
+		Set("bytes = GREATEST(bytes - ?, 0)", delta.Bytes).
+		Where("repository_id = ?", delta.RepositoryID).
+		Update()
+	if err != nil {
+		return errors.Wrap(err, "err releasing usage with pgstorage")
+	}
+	return nil
+}
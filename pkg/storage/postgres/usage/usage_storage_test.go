@@ -0,0 +1,16 @@
+package usage_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/usage"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return usage.NewPGStorage(db) })
+
+	storagetest.RunUsageStore(t, usage.NewPGStorage(db))
+}
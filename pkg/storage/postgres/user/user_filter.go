@@ -0,0 +1,29 @@
+package user
+
+import (
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type filter domain.Listing
+
+func (f *filter) Filter(q *orm.Query) (*orm.Query, error) {
+	if f.Search != nil {
+		search := "%" + *f.Search + "%"
+		q = q.WhereGroup(func(q *orm.Query) (*orm.Query, error) {
+			return q.Where("email ILIKE ?", search).WhereOr("name ILIKE ?", search), nil
+		})
+	}
+	if f.Disabled != nil {
+		if *f.Disabled {
+			q = q.Where("disabled_at IS NOT NULL")
+		} else {
+			q = q.Where("disabled_at IS NULL")
+		}
+	}
+
+	return q.Order(f.SortKey).
+		Offset(int(f.Offset)).
+		Limit(f.Limit), nil
+}
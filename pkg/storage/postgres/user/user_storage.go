@@ -84,3 +84,52 @@ func (p *PGStorage) GetUserByID(id kallax.ULID) (*domain.User, error) {
 	}
 	return &u, nil
 }
+
+// ListUsers retrieve users with domain.Listing attrs.
+func (p *PGStorage) ListUsers(l *domain.Listing) ([]domain.User, int64, error) {
+	var users []domain.User
+	f := filter(*l)
+	total, err := p.db.Model(&users).Apply(f.Filter).SelectAndCount()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "err listing users with pgstorage")
+	}
+	return users, int64(total), nil
+}
+
+// UpdateUser saves the changes of a user.
+func (p *PGStorage) UpdateUser(user *domain.User) error {
+	if err := p.db.Update(user); err != nil {
+		if isUniqueConstraintError(err) {
+			return errors.WithStack(uniqueConstraintErr(err.Error()))
+		}
+		if err == pg.ErrNoRows {
+			return errors.WithStack(userNotFound(fmt.Sprintf("user with id %v not found", user.ID)))
+		}
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// AdvanceTwoFactorStep saves the last TOTP step used by a user when it's after the stored one.
+func (p *PGStorage) AdvanceTwoFactorStep(id kallax.ULID, step int64) (bool, error) {
+	res, err := p.db.Model(&domain.User{}).
+		Set("two_factor_last_step = ?", step).
+		Where("id = ?", id).
+		Where("two_factor_last_step < ?", step).
+		Update()
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return res.RowsAffected() == 1, nil
+}
+
+// RemoveUser soft deletes a user.
+func (p *PGStorage) RemoveUser(user *domain.User) error {
+	if err := p.db.Delete(user); err != nil {
+		if err == pg.ErrNoRows {
+			return errors.WithStack(userNotFound(fmt.Sprintf("user with id %v not found", user.ID)))
+		}
+		return errors.WithStack(err)
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+package webhook
+
+import (
+	"github.com/go-pg/pg/orm"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type deliveryFilter domain.Listing
+
+func (f *deliveryFilter) Filter(q *orm.Query) (*orm.Query, error) {
+	if f.Owner != nil {
+		q = q.Where("webhook_id = ?", *f.Owner)
+	}
+	return q.Order(f.SortKey).
+		Offset(int(f.Offset)).
+		Limit(f.Limit), nil
+}
@@ -0,0 +1,102 @@
+package webhook
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+type webhookNotFound string
+
+func (u webhookNotFound) Error() string  { return string(u) }
+func (u webhookNotFound) NotFound() bool { return true }
+
+// PGStorage postgres storage layer
+type PGStorage struct{ db *pg.DB }
+
+// NewPGStorage creates a new instance of PGStorage
+func NewPGStorage(db *pg.DB) *PGStorage { return &PGStorage{db: db} }
+
+// CreateSchema runs schema migration.
+func (p *PGStorage) CreateSchema() error {
+	opts := &orm.CreateTableOptions{IfNotExists: true}
+	for _, model := range []interface{}{&domain.Webhook{}, &domain.WebhookDelivery{}} {
+		if err := p.db.CreateTable(model, opts); err != nil {
+			return errors.Wrap(err, "creating webhook schema")
+		}
+	}
+	return nil
+}
+
+// Drop delete schema.
+func (p *PGStorage) Drop() error {
+	opts := &orm.DropTableOptions{IfExists: true}
+	for _, model := range []interface{}{&domain.Webhook{}, &domain.WebhookDelivery{}} {
+		if err := p.db.DropTable(model, opts); err != nil {
+			return errors.Wrap(err, "dropping webhook schema")
+		}
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateWebhook(w *domain.Webhook) error {
+	if err := p.db.Insert(w); err != nil {
+		return errors.Wrap(err, "err saving webhook with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListWebhooks(repoID kallax.ULID) ([]domain.Webhook, error) {
+	var hooks []domain.Webhook
+	err := p.db.Model(&hooks).
+		Where("repository_id = ?", repoID).
+		Order("created_at ASC").
+		Select()
+	if err != nil {
+		return nil, errors.Wrap(err, "err listing webhooks with pgstorage")
+	}
+	return hooks, nil
+}
+
+func (p *PGStorage) GetWebhook(webhookID, repoID kallax.ULID) (*domain.Webhook, error) {
+	var w domain.Webhook
+	err := p.db.Model(&w).
+		Where("id = ?", webhookID).
+		Where("repository_id = ?", repoID).
+		First()
+	if err != nil {
+		errStr := fmt.Sprintf("webhook with id %s not found in repo %v", webhookID, repoID)
+		return nil, errors.WithStack(webhookNotFound(errStr))
+	}
+	return &w, nil
+}
+
+func (p *PGStorage) SaveWebhook(w *domain.Webhook) error {
+	if err := p.db.Update(w); err != nil {
+		errStr := fmt.Sprintf("error saving the webhook %s in repo %v", w.ID, w.RepositoryID)
+		return errors.Wrap(err, errStr)
+	}
+	return nil
+}
+
+func (p *PGStorage) CreateWebhookDelivery(d *domain.WebhookDelivery) error {
+	if err := p.db.Insert(d); err != nil {
+		return errors.Wrap(err, "err saving webhook delivery with pgstorage")
+	}
+	return nil
+}
+
+func (p *PGStorage) ListWebhookDeliveries(l *domain.Listing) ([]domain.WebhookDelivery, int64, error) {
+	var deliveries []domain.WebhookDelivery
+	f := deliveryFilter(*l)
+	total, err := p.db.Model(&deliveries).Apply(f.Filter).SelectAndCount()
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "err listing webhook deliveries with pgstorage")
+	}
+	return deliveries, int64(total), nil
+}
@@ -0,0 +1,16 @@
+package webhook_test
+
+import (
+	"testing"
+
+	"github.com/go-pg/pg"
+
+	"github.com/ifreddyrondon/capture/pkg/storage/postgres/webhook"
+	"github.com/ifreddyrondon/capture/pkg/storage/storagetest"
+)
+
+func TestPGStorageConformance(t *testing.T) {
+	db := storagetest.PG(t, func(db *pg.DB) storagetest.Schema { return webhook.NewPGStorage(db) })
+
+	storagetest.RunWebhookStore(t, webhook.NewPGStorage(db))
+}
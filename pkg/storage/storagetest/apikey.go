@@ -0,0 +1,106 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// APIKeyStore is the storage of the repository API keys.
+type APIKeyStore interface {
+	CreateAPIKey(*domain.APIKey) error
+	ListAPIKeys(kallax.ULID) ([]domain.APIKey, error)
+	GetAPIKey(keyID, repoID kallax.ULID) (*domain.APIKey, error)
+	GetAPIKeyByPrefix(string) (*domain.APIKey, error)
+	SaveAPIKey(*domain.APIKey) error
+	TouchAPIKey(*domain.APIKey) error
+}
+
+func newAPIKey(repoID kallax.ULID, createdAt time.Time) domain.APIKey {
+	id := kallax.NewULID()
+	return domain.APIKey{
+		ID:           id,
+		Name:         "test",
+		Prefix:       id.String(),
+		Hash:         []byte("hash"),
+		Scopes:       []domain.APIKeyScope{domain.CapturesRead},
+		CreatedAt:    createdAt,
+		RepositoryID: repoID,
+		UserID:       kallax.NewULID(),
+	}
+}
+
+// RunAPIKeyStore runs the conformance tests of an APIKeyStore.
+func RunAPIKeyStore(t *testing.T, s APIKeyStore) {
+	t.Run("create, list and get", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		k1, k2 := newAPIKey(repoID, now()), newAPIKey(repoID, now().Add(-time.Minute))
+		other := newAPIKey(kallax.NewULID(), now())
+		for _, k := range []domain.APIKey{k1, k2, other} {
+			k := k
+			require.Nil(t, s.CreateAPIKey(&k))
+		}
+
+		keys, err := s.ListAPIKeys(repoID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{k2.ID, k1.ID}, ulids(len(keys), func(i int) kallax.ULID { return keys[i].ID }))
+
+		k, err := s.GetAPIKey(k1.ID, repoID)
+		require.Nil(t, err)
+		assert.Equal(t, k1.Prefix, k.Prefix)
+		assert.Equal(t, k1.Hash, k.Hash)
+		assert.Equal(t, k1.Scopes, k.Scopes)
+		assert.Equal(t, k1.UserID, k.UserID)
+
+		k, err = s.GetAPIKeyByPrefix(k2.Prefix)
+		require.Nil(t, err)
+		assert.Equal(t, k2.ID, k.ID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		k := newAPIKey(kallax.NewULID(), now())
+		require.Nil(t, s.CreateAPIKey(&k))
+
+		_, err := s.GetAPIKey(k.ID, kallax.NewULID())
+		assert.True(t, isNotFound(err))
+		_, err = s.GetAPIKey(kallax.NewULID(), k.RepositoryID)
+		assert.True(t, isNotFound(err))
+		_, err = s.GetAPIKeyByPrefix("missing")
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("duplicated prefix", func(t *testing.T) {
+		k := newAPIKey(kallax.NewULID(), now())
+		require.Nil(t, s.CreateAPIKey(&k))
+		dup := newAPIKey(k.RepositoryID, now())
+		dup.Prefix = k.Prefix
+		assert.Error(t, s.CreateAPIKey(&dup))
+	})
+
+	t.Run("save and touch", func(t *testing.T) {
+		k := newAPIKey(kallax.NewULID(), now())
+		require.Nil(t, s.CreateAPIKey(&k))
+
+		revokedAt := now()
+		k.RevokedAt = &revokedAt
+		require.Nil(t, s.SaveAPIKey(&k))
+		usedAt := now().Add(time.Second)
+		touched := k
+		touched.Name = "not saved by touch"
+		touched.LastUsedAt = &usedAt
+		require.Nil(t, s.TouchAPIKey(&touched))
+
+		result, err := s.GetAPIKey(k.ID, k.RepositoryID)
+		require.Nil(t, err)
+		require.NotNil(t, result.RevokedAt)
+		assert.True(t, revokedAt.Equal(*result.RevokedAt))
+		require.NotNil(t, result.LastUsedAt)
+		assert.True(t, usedAt.Equal(*result.LastUsedAt))
+		assert.Equal(t, "test", result.Name)
+	})
+}
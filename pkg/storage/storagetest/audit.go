@@ -0,0 +1,74 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// AuditStore is the storage of the audit trail.
+type AuditStore interface {
+	CreateAuditEntry(*domain.AuditEntry) error
+	ListAuditEntries(*domain.Listing) ([]domain.AuditEntry, int64, error)
+}
+
+// RunAuditStore runs the conformance tests of an AuditStore.
+func RunAuditStore(t *testing.T, s AuditStore) {
+	t.Run("create and list filtered", func(t *testing.T) {
+		repoID, actorID, impersonatorID := kallax.NewULID(), kallax.NewULID(), kallax.NewULID()
+		actor := domain.Actor{User: &domain.User{ID: actorID}, Impersonator: &impersonatorID, IP: "127.0.0.1", UserAgent: "test"}
+		e1 := domain.NewAuditEntry(actor, domain.RepoCreated, domain.RepositoryTarget, repoID.String())
+		e1.RepositoryID = &repoID
+		e1.CreatedAt = now().Add(-time.Minute)
+		e1.Detail("name", "test")
+		e2 := domain.NewAuditEntry(domain.Actor{}, domain.CaptureAdded, domain.CaptureTarget, kallax.NewULID().String())
+		e2.RepositoryID = &repoID
+		e2.CreatedAt = now()
+		e3 := domain.NewAuditEntry(actor, domain.CaptureAdded, domain.CaptureTarget, kallax.NewULID().String())
+		e3.CreatedAt = now()
+		for _, e := range []*domain.AuditEntry{e1, e2, e3} {
+			require.Nil(t, s.CreateAuditEntry(e))
+		}
+
+		entries, total, err := s.ListAuditEntries(&domain.Listing{Repository: &repoID, SortKey: "created_at DESC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, []kallax.ULID{e2.ID, e1.ID}, ulids(len(entries), func(i int) kallax.ULID { return entries[i].ID }))
+		e := entries[1]
+		assert.Equal(t, domain.RepoCreated, e.Action)
+		assert.Equal(t, actorID, *e.ActorID)
+		assert.Equal(t, impersonatorID, *e.ImpersonatorID)
+		assert.Equal(t, "127.0.0.1", e.IP)
+		assert.Equal(t, repoID.String(), e.TargetID)
+		assert.Equal(t, map[string]interface{}{"name": "test"}, e.Details)
+		assert.Nil(t, entries[0].ActorID)
+
+		action, target := domain.CaptureAdded, domain.CaptureTarget
+		entries, total, err = s.ListAuditEntries(&domain.Listing{Actor: &actorID, Action: &action, TargetType: &target, SortKey: "created_at ASC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []kallax.ULID{e3.ID}, ulids(len(entries), func(i int) kallax.ULID { return entries[i].ID }))
+	})
+
+	t.Run("paginate", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		var ids []kallax.ULID
+		for i := 0; i < 3; i++ {
+			e := domain.NewAuditEntry(domain.Actor{}, domain.RepoCreated, domain.RepositoryTarget, repoID.String())
+			e.RepositoryID = &repoID
+			e.CreatedAt = now().Add(time.Duration(i) * time.Second)
+			require.Nil(t, s.CreateAuditEntry(e))
+			ids = append(ids, e.ID)
+		}
+
+		entries, total, err := s.ListAuditEntries(&domain.Listing{Repository: &repoID, SortKey: "created_at ASC", Offset: 1, Limit: 1})
+		require.Nil(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, ids[1:2], ulids(len(entries), func(i int) kallax.ULID { return entries[i].ID }))
+	})
+}
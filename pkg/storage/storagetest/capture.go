@@ -0,0 +1,138 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// CaptureStore is the storage of the captures.
+type CaptureStore interface {
+	CreateCapture(*domain.Capture) error
+	CreateCaptures(...domain.Capture) error
+	List(*domain.Listing) ([]domain.Capture, int64, error)
+	Get(captureID, repoID kallax.ULID) (*domain.Capture, error)
+	Save(*domain.Capture) error
+}
+
+func point(lat, lng float64) *domain.Point {
+	return &domain.Point{LAT: &lat, LNG: &lng}
+}
+
+func newCapture(repoID kallax.ULID, location *domain.Point, timestamp time.Time) domain.Capture {
+	return domain.Capture{
+		ID:           kallax.NewULID(),
+		Payload:      domain.Payload{{Name: "power", Value: 1.5}},
+		Location:     location,
+		Tags:         []string{},
+		Timestamp:    timestamp,
+		CreatedAt:    timestamp,
+		UpdatedAt:    timestamp,
+		RepositoryID: repoID,
+	}
+}
+
+// RunCaptureStore runs the conformance tests of a CaptureStore.
+func RunCaptureStore(t *testing.T, s CaptureStore) {
+	t.Run("create and get", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		c := newCapture(repoID, point(1, 2), now())
+		require.Nil(t, s.CreateCapture(&c))
+
+		result, err := s.Get(c.ID, repoID)
+		require.Nil(t, err)
+		assert.Equal(t, c.Payload, result.Payload)
+		assert.Equal(t, c.Location, result.Location)
+		assert.True(t, c.Timestamp.Equal(result.Timestamp))
+
+		_, err = s.Get(c.ID, kallax.NewULID())
+		assert.True(t, isNotFound(err), "the captures of other repositories aren't found")
+		_, err = s.Get(kallax.NewULID(), repoID)
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		c1, c2 := newCapture(repoID, nil, now().Add(-time.Minute)), newCapture(repoID, nil, now())
+		c3 := newCapture(repoID, nil, now().Add(-time.Hour))
+		// the timestamp of a capture could be other than its creation.
+		c3.CreatedAt = now().Add(time.Minute)
+		require.Nil(t, s.CreateCaptures(c1, c2, c3, newCapture(kallax.NewULID(), nil, now())))
+
+		captures, total, err := s.List(&domain.Listing{Owner: &repoID, SortKey: "timestamp DESC", Limit: 2})
+		require.Nil(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, []kallax.ULID{c2.ID, c1.ID}, ulids(len(captures), func(i int) kallax.ULID { return captures[i].ID }))
+
+		captures, _, err = s.List(&domain.Listing{Owner: &repoID, SortKey: "timestamp ASC", Offset: 1, Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{c1.ID, c2.ID}, ulids(len(captures), func(i int) kallax.ULID { return captures[i].ID }))
+
+		captures, _, err = s.List(&domain.Listing{Owner: &repoID, SortKey: "created_at DESC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{c3.ID, c2.ID, c1.ID}, ulids(len(captures), func(i int) kallax.ULID { return captures[i].ID }))
+	})
+
+	t.Run("list by location", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		inside, near := newCapture(repoID, point(10.5, 10.5), now()), newCapture(repoID, point(20.001, 20), now())
+		outside, unknown := newCapture(repoID, point(30, 30), now()), newCapture(repoID, nil, now())
+		require.Nil(t, s.CreateCaptures(inside, near, outside, unknown))
+
+		area := domain.Polygon{*point(10, 10), *point(10, 11), *point(11, 11), *point(11, 10)}
+		captures, total, err := s.List(&domain.Listing{Owner: &repoID, Area: area, SortKey: "timestamp ASC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []kallax.ULID{inside.ID}, ulids(len(captures), func(i int) kallax.ULID { return captures[i].ID }))
+
+		// 0.001 degrees of latitude are about 111 meters.
+		captures, total, err = s.List(&domain.Listing{Owner: &repoID, Near: point(20, 20), Radius: 500, SortKey: "timestamp ASC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []kallax.ULID{near.ID}, ulids(len(captures), func(i int) kallax.ULID { return captures[i].ID }))
+
+		_, total, err = s.List(&domain.Listing{Owner: &repoID, Near: point(20, 20), Radius: 50, SortKey: "timestamp ASC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(0), total)
+	})
+
+	t.Run("save", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		c := newCapture(repoID, nil, now())
+		require.Nil(t, s.CreateCapture(&c))
+
+		c.Tags = []string{"updated"}
+		c.Location = point(1, 2)
+		require.Nil(t, s.Save(&c))
+		result, err := s.Get(c.ID, repoID)
+		require.Nil(t, err)
+		assert.Equal(t, []string{"updated"}, result.Tags)
+		assert.Equal(t, c.Location, result.Location)
+
+		missing := newCapture(repoID, nil, now())
+		assert.True(t, isNotFound(s.Save(&missing)))
+	})
+
+	t.Run("soft delete", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		c, kept := newCapture(repoID, nil, now()), newCapture(repoID, nil, now())
+		require.Nil(t, s.CreateCaptures(c, kept))
+
+		deletedAt := now()
+		c.DeletedAt = &deletedAt
+		require.Nil(t, s.Save(&c))
+		_, err := s.Get(c.ID, repoID)
+		assert.True(t, isNotFound(err))
+		captures, total, err := s.List(&domain.Listing{Owner: &repoID, SortKey: "timestamp ASC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []kallax.ULID{kept.ID}, ulids(len(captures), func(i int) kallax.ULID { return captures[i].ID }))
+
+		assert.True(t, isNotFound(s.Save(&c)), "removed captures aren't saved")
+	})
+}
@@ -0,0 +1,107 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// CollaboratorStore is the storage of the repository collaborators.
+type CollaboratorStore interface {
+	CreateCollaborator(*domain.Collaborator) error
+	ListCollaborators(kallax.ULID) ([]domain.Collaborator, error)
+	GetCollaborator(id, repoID kallax.ULID) (*domain.Collaborator, error)
+	GetRepoCollaborator(repoID, userID kallax.ULID) (*domain.Collaborator, error)
+	ListInvitations(kallax.ULID) ([]domain.Collaborator, error)
+	SaveCollaborator(*domain.Collaborator) error
+	RemoveCollaborator(*domain.Collaborator) error
+}
+
+func newCollaborator(repoID, userID kallax.ULID, createdAt time.Time) domain.Collaborator {
+	return domain.Collaborator{
+		ID:           kallax.NewULID(),
+		Role:         domain.ReadRole,
+		Email:        "test@example.com",
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
+		RepositoryID: repoID,
+		UserID:       userID,
+		InvitedBy:    kallax.NewULID(),
+	}
+}
+
+// RunCollaboratorStore runs the conformance tests of a CollaboratorStore.
+func RunCollaboratorStore(t *testing.T, s CollaboratorStore) {
+	t.Run("create, list and get", func(t *testing.T) {
+		repoID, userID := kallax.NewULID(), kallax.NewULID()
+		c1 := newCollaborator(repoID, userID, now())
+		c2 := newCollaborator(repoID, kallax.NewULID(), now().Add(-time.Minute))
+		acceptedAt := now()
+		c2.AcceptedAt = &acceptedAt
+		c3 := newCollaborator(kallax.NewULID(), userID, now().Add(time.Minute))
+		for _, c := range []domain.Collaborator{c1, c2, c3} {
+			c := c
+			require.Nil(t, s.CreateCollaborator(&c))
+		}
+
+		collaborators, err := s.ListCollaborators(repoID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{c2.ID, c1.ID}, ulids(len(collaborators), func(i int) kallax.ULID { return collaborators[i].ID }))
+
+		c, err := s.GetCollaborator(c1.ID, repoID)
+		require.Nil(t, err)
+		assert.Equal(t, domain.ReadRole, c.Role)
+		assert.Equal(t, userID, c.UserID)
+		assert.Nil(t, c.AcceptedAt)
+
+		c, err = s.GetRepoCollaborator(repoID, c2.UserID)
+		require.Nil(t, err)
+		assert.Equal(t, c2.ID, c.ID)
+
+		invitations, err := s.ListInvitations(userID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{c1.ID, c3.ID}, ulids(len(invitations), func(i int) kallax.ULID { return invitations[i].ID }))
+	})
+
+	t.Run("duplicated user in repo", func(t *testing.T) {
+		c := newCollaborator(kallax.NewULID(), kallax.NewULID(), now())
+		require.Nil(t, s.CreateCollaborator(&c))
+		dup := newCollaborator(c.RepositoryID, c.UserID, now())
+		assert.True(t, isUniqueConstraint(s.CreateCollaborator(&dup)))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		c := newCollaborator(kallax.NewULID(), kallax.NewULID(), now())
+		require.Nil(t, s.CreateCollaborator(&c))
+
+		_, err := s.GetCollaborator(c.ID, kallax.NewULID())
+		assert.True(t, isNotFound(err))
+		_, err = s.GetRepoCollaborator(c.RepositoryID, kallax.NewULID())
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("save and remove", func(t *testing.T) {
+		c := newCollaborator(kallax.NewULID(), kallax.NewULID(), now())
+		require.Nil(t, s.CreateCollaborator(&c))
+
+		acceptedAt := now()
+		c.Role, c.AcceptedAt = domain.WriteRole, &acceptedAt
+		require.Nil(t, s.SaveCollaborator(&c))
+		result, err := s.GetCollaborator(c.ID, c.RepositoryID)
+		require.Nil(t, err)
+		assert.Equal(t, domain.WriteRole, result.Role)
+		require.NotNil(t, result.AcceptedAt)
+		invitations, err := s.ListInvitations(c.UserID)
+		require.Nil(t, err)
+		assert.Empty(t, invitations)
+
+		require.Nil(t, s.RemoveCollaborator(&c))
+		_, err = s.GetCollaborator(c.ID, c.RepositoryID)
+		assert.True(t, isNotFound(err))
+	})
+}
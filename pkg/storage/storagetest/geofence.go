@@ -0,0 +1,118 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// GeofenceStore is the storage of the repository geofences and their events.
+type GeofenceStore interface {
+	CreateGeofence(*domain.Geofence) error
+	ListGeofences(kallax.ULID) ([]domain.Geofence, error)
+	GetGeofence(geofenceID, repoID kallax.ULID) (*domain.Geofence, error)
+	SaveGeofence(*domain.Geofence) error
+	RecordGeofenceEvent(domain.GeofenceEvent) (bool, error)
+	ListGeofenceEvents(*domain.Listing) ([]domain.GeofenceEvent, int64, error)
+}
+
+func newGeofence(repoID kallax.ULID, createdAt time.Time) domain.Geofence {
+	lat, lng := 1.0, 2.0
+	return domain.Geofence{
+		ID:           kallax.NewULID(),
+		Name:         "test",
+		Polygon:      domain.Polygon{{LAT: &lat, LNG: &lng}, {LAT: &lng, LNG: &lat}, {LAT: &lat, LNG: &lat}},
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
+		RepositoryID: repoID,
+	}
+}
+
+func newGeofenceEvent(g domain.Geofence, typ domain.GeofenceEventType, at time.Time) domain.GeofenceEvent {
+	return domain.GeofenceEvent{
+		ID:           kallax.NewULID(),
+		Type:         typ,
+		Timestamp:    at,
+		CreatedAt:    at,
+		GeofenceID:   g.ID,
+		CaptureID:    kallax.NewULID(),
+		RepositoryID: g.RepositoryID,
+	}
+}
+
+// RunGeofenceStore runs the conformance tests of a GeofenceStore.
+func RunGeofenceStore(t *testing.T, s GeofenceStore) {
+	t.Run("create, list and get", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		g1, g2 := newGeofence(repoID, now()), newGeofence(repoID, now().Add(-time.Minute))
+		other := newGeofence(kallax.NewULID(), now())
+		for _, g := range []domain.Geofence{g1, g2, other} {
+			g := g
+			require.Nil(t, s.CreateGeofence(&g))
+		}
+
+		geofences, err := s.ListGeofences(repoID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{g2.ID, g1.ID}, ulids(len(geofences), func(i int) kallax.ULID { return geofences[i].ID }))
+
+		g, err := s.GetGeofence(g1.ID, repoID)
+		require.Nil(t, err)
+		assert.Equal(t, g1.Polygon, g.Polygon)
+		assert.False(t, g.Inside)
+
+		_, err = s.GetGeofence(g1.ID, other.RepositoryID)
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("save and soft delete", func(t *testing.T) {
+		g := newGeofence(kallax.NewULID(), now())
+		require.Nil(t, s.CreateGeofence(&g))
+
+		g.Name = "updated"
+		require.Nil(t, s.SaveGeofence(&g))
+		result, err := s.GetGeofence(g.ID, g.RepositoryID)
+		require.Nil(t, err)
+		assert.Equal(t, "updated", result.Name)
+
+		deletedAt := now()
+		g.DeletedAt = &deletedAt
+		require.Nil(t, s.SaveGeofence(&g))
+		_, err = s.GetGeofence(g.ID, g.RepositoryID)
+		assert.True(t, isNotFound(err))
+		geofences, err := s.ListGeofences(g.RepositoryID)
+		require.Nil(t, err)
+		assert.Empty(t, geofences)
+	})
+
+	t.Run("record crossings", func(t *testing.T) {
+		g := newGeofence(kallax.NewULID(), now())
+		require.Nil(t, s.CreateGeofence(&g))
+
+		enter := newGeofenceEvent(g, domain.Enter, now())
+		recorded, err := s.RecordGeofenceEvent(enter)
+		require.Nil(t, err)
+		assert.True(t, recorded)
+		recorded, err = s.RecordGeofenceEvent(newGeofenceEvent(g, domain.Enter, now()))
+		require.Nil(t, err)
+		assert.False(t, recorded, "entering twice is not a crossing")
+		exit := newGeofenceEvent(g, domain.Exit, now().Add(time.Second))
+		recorded, err = s.RecordGeofenceEvent(exit)
+		require.Nil(t, err)
+		assert.True(t, recorded)
+
+		result, err := s.GetGeofence(g.ID, g.RepositoryID)
+		require.Nil(t, err)
+		assert.False(t, result.Inside)
+
+		events, total, err := s.ListGeofenceEvents(&domain.Listing{Owner: &g.ID, SortKey: "timestamp DESC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, []kallax.ULID{exit.ID, enter.ID}, ulids(len(events), func(i int) kallax.ULID { return events[i].ID }))
+		assert.Equal(t, domain.Exit, events[0].Type)
+	})
+}
@@ -0,0 +1,159 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// OAuthStore is the storage of the OAuth2 clients, their authorization codes and the
+// consents of the users.
+type OAuthStore interface {
+	CreateOAuthClient(*domain.OAuthClient) error
+	ListOAuthClients(kallax.ULID) ([]domain.OAuthClient, error)
+	GetOAuthClient(kallax.ULID) (*domain.OAuthClient, error)
+	RemoveOAuthClient(*domain.OAuthClient) error
+	CreateOAuthCode(*domain.OAuthCode) error
+	ConsumeOAuthCode(hash string, at time.Time) (*domain.OAuthCode, error)
+	GetOAuthConsent(userID, clientID kallax.ULID) (*domain.OAuthConsent, error)
+	SaveOAuthConsent(*domain.OAuthConsent) error
+	ListOAuthConsents(kallax.ULID) ([]domain.OAuthConsent, error)
+	RemoveOAuthConsent(userID, clientID kallax.ULID) error
+}
+
+func newOAuthClient(userID kallax.ULID, createdAt time.Time) domain.OAuthClient {
+	return domain.OAuthClient{
+		ID:           kallax.NewULID(),
+		Name:         "test",
+		SecretHash:   "hash",
+		RedirectURIs: []string{"https://example.com/callback"},
+		Scopes:       []domain.APIKeyScope{domain.CapturesRead},
+		Confidential: true,
+		CreatedAt:    createdAt,
+		UserID:       userID,
+	}
+}
+
+func newOAuthCode(c domain.OAuthClient, expiresAt time.Time) domain.OAuthCode {
+	id := kallax.NewULID()
+	return domain.OAuthCode{
+		ID:            id,
+		Hash:          id.String(),
+		RedirectURI:   c.RedirectURIs[0],
+		Scopes:        c.Scopes,
+		CodeChallenge: "challenge",
+		ExpiresAt:     expiresAt,
+		CreatedAt:     now(),
+		ClientID:      c.ID,
+		UserID:        c.UserID,
+	}
+}
+
+func newOAuthConsent(userID, clientID kallax.ULID, createdAt time.Time) domain.OAuthConsent {
+	return domain.OAuthConsent{
+		UserID:    userID,
+		ClientID:  clientID,
+		Scopes:    []domain.APIKeyScope{domain.CapturesRead},
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+}
+
+// RunOAuthStore runs the conformance tests of an OAuthStore.
+func RunOAuthStore(t *testing.T, s OAuthStore) {
+	t.Run("clients", func(t *testing.T) {
+		userID := kallax.NewULID()
+		c1, c2 := newOAuthClient(userID, now()), newOAuthClient(userID, now().Add(-time.Minute))
+		other := newOAuthClient(kallax.NewULID(), now())
+		for _, c := range []domain.OAuthClient{c1, c2, other} {
+			c := c
+			require.Nil(t, s.CreateOAuthClient(&c))
+		}
+
+		clients, err := s.ListOAuthClients(userID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{c2.ID, c1.ID}, ulids(len(clients), func(i int) kallax.ULID { return clients[i].ID }))
+
+		c, err := s.GetOAuthClient(c1.ID)
+		require.Nil(t, err)
+		assert.Equal(t, c1.RedirectURIs, c.RedirectURIs)
+		assert.Equal(t, c1.Scopes, c.Scopes)
+		assert.True(t, c.Confidential)
+
+		_, err = s.GetOAuthClient(kallax.NewULID())
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("consume codes once", func(t *testing.T) {
+		c := newOAuthClient(kallax.NewULID(), now())
+		require.Nil(t, s.CreateOAuthClient(&c))
+		code, expired := newOAuthCode(c, now().Add(time.Minute)), newOAuthCode(c, now().Add(-time.Minute))
+		require.Nil(t, s.CreateOAuthCode(&code))
+		require.Nil(t, s.CreateOAuthCode(&expired))
+		dup := newOAuthCode(c, now().Add(time.Minute))
+		dup.Hash = code.Hash
+		assert.Error(t, s.CreateOAuthCode(&dup))
+
+		at := now()
+		result, err := s.ConsumeOAuthCode(code.Hash, at)
+		require.Nil(t, err)
+		assert.Equal(t, code.ID, result.ID)
+		assert.Equal(t, code.CodeChallenge, result.CodeChallenge)
+		require.NotNil(t, result.UsedAt)
+		assert.True(t, at.Equal(*result.UsedAt))
+
+		_, err = s.ConsumeOAuthCode(code.Hash, at)
+		assert.True(t, isNotFound(err))
+		_, err = s.ConsumeOAuthCode(expired.Hash, at)
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("consents", func(t *testing.T) {
+		userID := kallax.NewULID()
+		c1, c2 := newOAuthClient(kallax.NewULID(), now()), newOAuthClient(kallax.NewULID(), now())
+		require.Nil(t, s.CreateOAuthClient(&c1))
+		require.Nil(t, s.CreateOAuthClient(&c2))
+		consent := newOAuthConsent(userID, c1.ID, now().Add(-time.Minute))
+		require.Nil(t, s.SaveOAuthConsent(&consent))
+		second := newOAuthConsent(userID, c2.ID, now())
+		require.Nil(t, s.SaveOAuthConsent(&second))
+
+		updated := newOAuthConsent(userID, c1.ID, now())
+		updated.Scopes = []domain.APIKeyScope{domain.CapturesRead, domain.CapturesWrite}
+		require.Nil(t, s.SaveOAuthConsent(&updated))
+		result, err := s.GetOAuthConsent(userID, c1.ID)
+		require.Nil(t, err)
+		assert.Equal(t, updated.Scopes, result.Scopes)
+		assert.True(t, consent.CreatedAt.Equal(result.CreatedAt), "saving keeps the creation time")
+
+		consents, err := s.ListOAuthConsents(userID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{c1.ID, c2.ID}, ulids(len(consents), func(i int) kallax.ULID { return consents[i].ClientID }))
+
+		require.Nil(t, s.RemoveOAuthConsent(userID, c2.ID))
+		_, err = s.GetOAuthConsent(userID, c2.ID)
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("remove client with its codes and consents", func(t *testing.T) {
+		c := newOAuthClient(kallax.NewULID(), now())
+		require.Nil(t, s.CreateOAuthClient(&c))
+		code := newOAuthCode(c, now().Add(time.Minute))
+		require.Nil(t, s.CreateOAuthCode(&code))
+		consent := newOAuthConsent(kallax.NewULID(), c.ID, now())
+		require.Nil(t, s.SaveOAuthConsent(&consent))
+
+		require.Nil(t, s.RemoveOAuthClient(&c))
+		_, err := s.GetOAuthClient(c.ID)
+		assert.True(t, isNotFound(err))
+		_, err = s.ConsumeOAuthCode(code.Hash, now())
+		assert.True(t, isNotFound(err))
+		_, err = s.GetOAuthConsent(consent.UserID, c.ID)
+		assert.True(t, isNotFound(err))
+	})
+}
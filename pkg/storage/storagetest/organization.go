@@ -0,0 +1,199 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// OrganizationStore is the storage of the organizations, their members and teams.
+type OrganizationStore interface {
+	CreateOrganization(*domain.Organization, *domain.Member) error
+	ListUserOrganizations(kallax.ULID) ([]domain.Organization, error)
+	GetOrganization(kallax.ULID) (*domain.Organization, error)
+	CreateMember(*domain.Member) error
+	ListMembers(kallax.ULID) ([]domain.Member, error)
+	GetMember(id, orgID kallax.ULID) (*domain.Member, error)
+	GetUserMember(orgID, userID kallax.ULID) (*domain.Member, error)
+	RemoveMember(*domain.Member) error
+	CreateTeam(*domain.Team) error
+	ListTeams(kallax.ULID) ([]domain.Team, error)
+	GetTeam(id, orgID kallax.ULID) (*domain.Team, error)
+	RemoveTeam(*domain.Team) error
+	CreateTeamMember(*domain.TeamMember) error
+	RemoveTeamMember(teamID, userID kallax.ULID) error
+	ListUserTeams(orgID, userID kallax.ULID) ([]domain.Team, error)
+}
+
+func newOrganization(name string) domain.Organization {
+	id := kallax.NewULID()
+	return domain.Organization{ID: id, Name: name + "-" + id.String(), CreatedAt: now(), UpdatedAt: now()}
+}
+
+func newMember(orgID, userID kallax.ULID, role domain.MemberRole, createdAt time.Time) domain.Member {
+	return domain.Member{
+		ID:             kallax.NewULID(),
+		Role:           role,
+		Email:          "test@example.com",
+		CreatedAt:      createdAt,
+		OrganizationID: orgID,
+		UserID:         userID,
+	}
+}
+
+func newTeam(orgID kallax.ULID, name string) domain.Team {
+	return domain.Team{
+		ID:             kallax.NewULID(),
+		Name:           name,
+		Role:           domain.ReadRole,
+		CreatedAt:      now(),
+		UpdatedAt:      now(),
+		OrganizationID: orgID,
+	}
+}
+
+func newTeamMember(teamID, userID kallax.ULID) domain.TeamMember {
+	return domain.TeamMember{ID: kallax.NewULID(), CreatedAt: now(), TeamID: teamID, UserID: userID}
+}
+
+// RunOrganizationStore runs the conformance tests of an OrganizationStore.
+func RunOrganizationStore(t *testing.T, s OrganizationStore) {
+	t.Run("organizations and members", func(t *testing.T) {
+		ownerID, userID := kallax.NewULID(), kallax.NewULID()
+		b, a := newOrganization("b"), newOrganization("a")
+		bOwner := newMember(b.ID, ownerID, domain.OwnerMember, now())
+		aOwner := newMember(a.ID, ownerID, domain.OwnerMember, now())
+		require.Nil(t, s.CreateOrganization(&b, &bOwner))
+		require.Nil(t, s.CreateOrganization(&a, &aOwner))
+		member := newMember(b.ID, userID, domain.RegularMember, now().Add(time.Minute))
+		require.Nil(t, s.CreateMember(&member))
+
+		orgs, err := s.ListUserOrganizations(ownerID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{a.ID, b.ID}, ulids(len(orgs), func(i int) kallax.ULID { return orgs[i].ID }))
+		orgs, err = s.ListUserOrganizations(userID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{b.ID}, ulids(len(orgs), func(i int) kallax.ULID { return orgs[i].ID }))
+
+		org, err := s.GetOrganization(b.ID)
+		require.Nil(t, err)
+		assert.Equal(t, b.Name, org.Name)
+
+		members, err := s.ListMembers(b.ID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{bOwner.ID, member.ID}, ulids(len(members), func(i int) kallax.ULID { return members[i].ID }))
+		m, err := s.GetMember(member.ID, b.ID)
+		require.Nil(t, err)
+		assert.Equal(t, domain.RegularMember, m.Role)
+		m, err = s.GetUserMember(b.ID, ownerID)
+		require.Nil(t, err)
+		assert.Equal(t, bOwner.ID, m.ID)
+	})
+
+	t.Run("duplicates", func(t *testing.T) {
+		org := newOrganization("dup")
+		owner := newMember(org.ID, kallax.NewULID(), domain.OwnerMember, now())
+		require.Nil(t, s.CreateOrganization(&org, &owner))
+
+		dup := newOrganization("dup")
+		dup.Name = org.Name
+		dupOwner := newMember(dup.ID, kallax.NewULID(), domain.OwnerMember, now())
+		assert.True(t, isUniqueConstraint(s.CreateOrganization(&dup, &dupOwner)))
+		_, err := s.GetOrganization(dup.ID)
+		assert.True(t, isNotFound(err))
+
+		dupMember := newMember(org.ID, owner.UserID, domain.RegularMember, now())
+		assert.True(t, isUniqueConstraint(s.CreateMember(&dupMember)))
+
+		team := newTeam(org.ID, "team")
+		require.Nil(t, s.CreateTeam(&team))
+		dupTeam := newTeam(org.ID, "team")
+		assert.True(t, isUniqueConstraint(s.CreateTeam(&dupTeam)))
+
+		tm := newTeamMember(team.ID, owner.UserID)
+		require.Nil(t, s.CreateTeamMember(&tm))
+		dupTM := newTeamMember(team.ID, owner.UserID)
+		assert.True(t, isUniqueConstraint(s.CreateTeamMember(&dupTM)))
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		org := newOrganization("missing")
+		owner := newMember(org.ID, kallax.NewULID(), domain.OwnerMember, now())
+		require.Nil(t, s.CreateOrganization(&org, &owner))
+
+		_, err := s.GetOrganization(kallax.NewULID())
+		assert.True(t, isNotFound(err))
+		_, err = s.GetMember(owner.ID, kallax.NewULID())
+		assert.True(t, isNotFound(err))
+		_, err = s.GetUserMember(org.ID, kallax.NewULID())
+		assert.True(t, isNotFound(err))
+		_, err = s.GetTeam(kallax.NewULID(), org.ID)
+		assert.True(t, isNotFound(err))
+		assert.True(t, isNotFound(s.RemoveTeamMember(kallax.NewULID(), owner.UserID)))
+	})
+
+	t.Run("teams", func(t *testing.T) {
+		org := newOrganization("teams")
+		owner := newMember(org.ID, kallax.NewULID(), domain.OwnerMember, now())
+		require.Nil(t, s.CreateOrganization(&org, &owner))
+		b, a := newTeam(org.ID, "b"), newTeam(org.ID, "a")
+		require.Nil(t, s.CreateTeam(&b))
+		require.Nil(t, s.CreateTeam(&a))
+
+		teams, err := s.ListTeams(org.ID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{a.ID, b.ID}, ulids(len(teams), func(i int) kallax.ULID { return teams[i].ID }))
+		team, err := s.GetTeam(b.ID, org.ID)
+		require.Nil(t, err)
+		assert.Equal(t, domain.ReadRole, team.Role)
+
+		for _, teamID := range []kallax.ULID{a.ID, b.ID} {
+			tm := newTeamMember(teamID, owner.UserID)
+			require.Nil(t, s.CreateTeamMember(&tm))
+		}
+		teams, err = s.ListUserTeams(org.ID, owner.UserID)
+		require.Nil(t, err)
+		assert.ElementsMatch(t, []kallax.ULID{a.ID, b.ID}, ulids(len(teams), func(i int) kallax.ULID { return teams[i].ID }))
+
+		require.Nil(t, s.RemoveTeamMember(a.ID, owner.UserID))
+		teams, err = s.ListUserTeams(org.ID, owner.UserID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{b.ID}, ulids(len(teams), func(i int) kallax.ULID { return teams[i].ID }))
+
+		require.Nil(t, s.RemoveTeam(&b))
+		_, err = s.GetTeam(b.ID, org.ID)
+		assert.True(t, isNotFound(err))
+		teams, err = s.ListUserTeams(org.ID, owner.UserID)
+		require.Nil(t, err)
+		assert.Empty(t, teams)
+	})
+
+	t.Run("remove member with its teams", func(t *testing.T) {
+		org := newOrganization("remove")
+		owner := newMember(org.ID, kallax.NewULID(), domain.OwnerMember, now())
+		require.Nil(t, s.CreateOrganization(&org, &owner))
+		member := newMember(org.ID, kallax.NewULID(), domain.RegularMember, now())
+		require.Nil(t, s.CreateMember(&member))
+		team := newTeam(org.ID, "team")
+		require.Nil(t, s.CreateTeam(&team))
+		for _, userID := range []kallax.ULID{owner.UserID, member.UserID} {
+			tm := newTeamMember(team.ID, userID)
+			require.Nil(t, s.CreateTeamMember(&tm))
+		}
+
+		require.Nil(t, s.RemoveMember(&member))
+		_, err := s.GetMember(member.ID, org.ID)
+		assert.True(t, isNotFound(err))
+		teams, err := s.ListUserTeams(org.ID, member.UserID)
+		require.Nil(t, err)
+		assert.Empty(t, teams)
+		teams, err = s.ListUserTeams(org.ID, owner.UserID)
+		require.Nil(t, err)
+		assert.Len(t, teams, 1)
+	})
+}
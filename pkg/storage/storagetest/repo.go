@@ -0,0 +1,133 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// RepoStore is the storage of the repositories.
+type RepoStore interface {
+	SaveRepo(*domain.Repository) error
+	List(*domain.Listing) ([]domain.Repository, int64, error)
+	Get(kallax.ULID) (*domain.Repository, error)
+	UpdateRepo(*domain.Repository) error
+	TransferRepo(*domain.Repository) error
+	RemoveUserRepos(userID kallax.ULID, at time.Time) error
+}
+
+func newRepo(userID kallax.ULID, visibility domain.Visibility, createdAt time.Time) domain.Repository {
+	return domain.Repository{
+		ID:            kallax.NewULID(),
+		Name:          "test",
+		CurrentBranch: "master",
+		Visibility:    visibility,
+		CreatedAt:     createdAt,
+		UpdatedAt:     createdAt,
+		UserID:        userID,
+	}
+}
+
+// RunRepoStore runs the conformance tests of a RepoStore.
+func RunRepoStore(t *testing.T, s RepoStore) {
+	t.Run("save and get", func(t *testing.T) {
+		r := newRepo(kallax.NewULID(), domain.Public, now())
+		require.Nil(t, s.SaveRepo(&r))
+
+		result, err := s.Get(r.ID)
+		require.Nil(t, err)
+		assert.Equal(t, r.Name, result.Name)
+		assert.Equal(t, r.UserID, result.UserID)
+		assert.Equal(t, domain.Public, result.Visibility)
+		assert.True(t, r.CreatedAt.Equal(result.CreatedAt))
+
+		_, err = s.Get(kallax.NewULID())
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("list", func(t *testing.T) {
+		userID, orgID := kallax.NewULID(), kallax.NewULID()
+		r1, r2 := newRepo(userID, domain.Public, now().Add(-time.Minute)), newRepo(userID, domain.Private, now())
+		r3 := newRepo(userID, domain.Public, now().Add(-time.Hour))
+		org := newRepo(userID, domain.Public, now())
+		org.OrganizationID = &orgID
+		for _, r := range []domain.Repository{r1, r2, r3, org, newRepo(kallax.NewULID(), domain.Public, now())} {
+			r := r
+			require.Nil(t, s.SaveRepo(&r))
+		}
+
+		repos, total, err := s.List(&domain.Listing{Owner: &userID, SortKey: "created_at DESC", Limit: 2})
+		require.Nil(t, err)
+		assert.Equal(t, int64(3), total, "the organization repositories aren't listed with the user ones")
+		assert.Equal(t, []kallax.ULID{r2.ID, r1.ID}, ulids(len(repos), func(i int) kallax.ULID { return repos[i].ID }))
+
+		repos, _, err = s.List(&domain.Listing{Owner: &userID, SortKey: "created_at ASC", Offset: 1, Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{r1.ID, r2.ID}, ulids(len(repos), func(i int) kallax.ULID { return repos[i].ID }))
+
+		public := domain.Public
+		repos, total, err = s.List(&domain.Listing{Owner: &userID, Visibility: &public, SortKey: "updated_at ASC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(2), total)
+		assert.Equal(t, []kallax.ULID{r3.ID, r1.ID}, ulids(len(repos), func(i int) kallax.ULID { return repos[i].ID }))
+
+		repos, total, err = s.List(&domain.Listing{Organization: &orgID, SortKey: "created_at ASC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []kallax.ULID{org.ID}, ulids(len(repos), func(i int) kallax.ULID { return repos[i].ID }))
+	})
+
+	t.Run("update and transfer", func(t *testing.T) {
+		r := newRepo(kallax.NewULID(), domain.Public, now())
+		require.Nil(t, s.SaveRepo(&r))
+
+		r.Name = "updated"
+		r.Visibility = domain.Private
+		require.Nil(t, s.UpdateRepo(&r))
+		result, err := s.Get(r.ID)
+		require.Nil(t, err)
+		assert.Equal(t, "updated", result.Name)
+		assert.Equal(t, domain.Private, result.Visibility)
+
+		r.UserID = kallax.NewULID()
+		require.Nil(t, s.TransferRepo(&r))
+		result, err = s.Get(r.ID)
+		require.Nil(t, err)
+		assert.Equal(t, r.UserID, result.UserID)
+
+		missing := newRepo(kallax.NewULID(), domain.Public, now())
+		assert.True(t, isNotFound(s.UpdateRepo(&missing)))
+		assert.True(t, isNotFound(s.TransferRepo(&missing)))
+	})
+
+	t.Run("remove user repositories", func(t *testing.T) {
+		userID, orgID := kallax.NewULID(), kallax.NewULID()
+		r := newRepo(userID, domain.Public, now())
+		org := newRepo(userID, domain.Public, now())
+		org.OrganizationID = &orgID
+		other := newRepo(kallax.NewULID(), domain.Public, now())
+		for _, r := range []domain.Repository{r, org, other} {
+			r := r
+			require.Nil(t, s.SaveRepo(&r))
+		}
+
+		require.Nil(t, s.RemoveUserRepos(userID, now()))
+		_, err := s.Get(r.ID)
+		assert.True(t, isNotFound(err))
+		repos, total, err := s.List(&domain.Listing{Owner: &userID, SortKey: "created_at ASC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(0), total)
+		assert.Empty(t, repos)
+		assert.True(t, isNotFound(s.UpdateRepo(&r)), "removed repositories aren't updated")
+
+		_, err = s.Get(org.ID)
+		assert.Nil(t, err, "the organization repositories are kept")
+		_, err = s.Get(other.ID)
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,87 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// RetentionStore is the storage of the repository retention policies and the reports of
+// their runs.
+type RetentionStore interface {
+	GetRetentionPolicy(kallax.ULID) (*domain.RetentionPolicy, error)
+	SaveRetentionPolicy(*domain.RetentionPolicy) error
+	RemoveRetentionPolicy(kallax.ULID) error
+	ListRetentionPolicies() ([]domain.RetentionPolicy, error)
+	CreateRetentionReport(*domain.RetentionReport) error
+	ListRetentionReports(*domain.Listing) ([]domain.RetentionReport, int64, error)
+}
+
+func newRetentionReport(repoID kallax.ULID, createdAt time.Time) domain.RetentionReport {
+	return domain.RetentionReport{
+		ID:           kallax.NewULID(),
+		Action:       domain.DropCaptures,
+		Before:       createdAt.Add(-24 * time.Hour),
+		Captures:     10,
+		StartedAt:    createdAt,
+		FinishedAt:   createdAt,
+		CreatedAt:    createdAt,
+		RepositoryID: repoID,
+	}
+}
+
+// RunRetentionStore runs the conformance tests of a RetentionStore.
+func RunRetentionStore(t *testing.T, s RetentionStore) {
+	t.Run("save, get and remove policies", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		createdAt := now().Add(-time.Minute)
+		policy := domain.RetentionPolicy{RepositoryID: repoID, Days: 30, Action: domain.DropCaptures, CreatedAt: createdAt, UpdatedAt: createdAt}
+		require.Nil(t, s.SaveRetentionPolicy(&policy))
+
+		updated := domain.RetentionPolicy{RepositoryID: repoID, Days: 7, Action: domain.ArchiveCaptures, CreatedAt: now(), UpdatedAt: now()}
+		require.Nil(t, s.SaveRetentionPolicy(&updated))
+		result, err := s.GetRetentionPolicy(repoID)
+		require.Nil(t, err)
+		assert.Equal(t, 7, result.Days)
+		assert.Equal(t, domain.ArchiveCaptures, result.Action)
+		assert.True(t, createdAt.Equal(result.CreatedAt), "saving keeps the creation time")
+
+		policies, err := s.ListRetentionPolicies()
+		require.Nil(t, err)
+		var found int
+		for _, p := range policies {
+			if p.RepositoryID == repoID {
+				found++
+			}
+		}
+		assert.Equal(t, 1, found)
+
+		require.Nil(t, s.RemoveRetentionPolicy(repoID))
+		_, err = s.GetRetentionPolicy(repoID)
+		assert.True(t, isNotFound(err))
+		assert.Nil(t, s.RemoveRetentionPolicy(repoID))
+	})
+
+	t.Run("reports", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		var ids []kallax.ULID
+		for i := 0; i < 3; i++ {
+			r := newRetentionReport(repoID, now().Add(time.Duration(i)*time.Second))
+			require.Nil(t, s.CreateRetentionReport(&r))
+			ids = append(ids, r.ID)
+		}
+		other := newRetentionReport(kallax.NewULID(), now())
+		require.Nil(t, s.CreateRetentionReport(&other))
+
+		reports, total, err := s.ListRetentionReports(&domain.Listing{Owner: &repoID, SortKey: "created_at DESC", Limit: 2})
+		require.Nil(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, []kallax.ULID{ids[2], ids[1]}, ulids(len(reports), func(i int) kallax.ULID { return reports[i].ID }))
+		assert.Equal(t, int64(10), reports[0].Captures)
+	})
+}
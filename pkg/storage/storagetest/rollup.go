@@ -0,0 +1,86 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// RollupStore is the storage of the repository rollup rules.
+type RollupStore interface {
+	CreateRollupRule(*domain.RollupRule) error
+	ListRollupRules(kallax.ULID) ([]domain.RollupRule, error)
+	GetRollupRule(ruleID, repoID kallax.ULID) (*domain.RollupRule, error)
+	SaveRollupRule(*domain.RollupRule) error
+	AllRollupRules() ([]domain.RollupRule, error)
+}
+
+func newRollupRule(repoID kallax.ULID, createdAt time.Time) domain.RollupRule {
+	return domain.RollupRule{
+		ID:           kallax.NewULID(),
+		AfterDays:    30,
+		Resolution:   3600,
+		Aggregate:    domain.AvgAggregate,
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
+		RepositoryID: repoID,
+	}
+}
+
+// RunRollupStore runs the conformance tests of a RollupStore.
+func RunRollupStore(t *testing.T, s RollupStore) {
+	t.Run("create, list and get", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		r1, r2 := newRollupRule(repoID, now()), newRollupRule(repoID, now().Add(-time.Minute))
+		other := newRollupRule(kallax.NewULID(), now())
+		for _, r := range []domain.RollupRule{r1, r2, other} {
+			r := r
+			require.Nil(t, s.CreateRollupRule(&r))
+		}
+
+		rules, err := s.ListRollupRules(repoID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{r2.ID, r1.ID}, ulids(len(rules), func(i int) kallax.ULID { return rules[i].ID }))
+
+		r, err := s.GetRollupRule(r1.ID, repoID)
+		require.Nil(t, err)
+		assert.Equal(t, int64(3600), r.Resolution)
+		assert.Equal(t, domain.AvgAggregate, r.Aggregate)
+
+		_, err = s.GetRollupRule(r1.ID, other.RepositoryID)
+		assert.True(t, isNotFound(err))
+
+		all, err := s.AllRollupRules()
+		require.Nil(t, err)
+		ids := ulids(len(all), func(i int) kallax.ULID { return all[i].ID })
+		assert.Subset(t, ids, []kallax.ULID{r1.ID, r2.ID, other.ID})
+	})
+
+	t.Run("save and soft delete", func(t *testing.T) {
+		r := newRollupRule(kallax.NewULID(), now())
+		require.Nil(t, s.CreateRollupRule(&r))
+
+		r.Aggregate = domain.MaxAggregate
+		require.Nil(t, s.SaveRollupRule(&r))
+		result, err := s.GetRollupRule(r.ID, r.RepositoryID)
+		require.Nil(t, err)
+		assert.Equal(t, domain.MaxAggregate, result.Aggregate)
+
+		deletedAt := now()
+		r.DeletedAt = &deletedAt
+		require.Nil(t, s.SaveRollupRule(&r))
+		_, err = s.GetRollupRule(r.ID, r.RepositoryID)
+		assert.True(t, isNotFound(err))
+		rules, err := s.ListRollupRules(r.RepositoryID)
+		require.Nil(t, err)
+		assert.Empty(t, rules)
+		all, err := s.AllRollupRules()
+		require.Nil(t, err)
+		assert.NotContains(t, ulids(len(all), func(i int) kallax.ULID { return all[i].ID }), r.ID)
+	})
+}
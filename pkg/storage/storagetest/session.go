@@ -0,0 +1,238 @@
+package storagetest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// SessionStore is the storage of the refresh tokens, the denied access tokens, the
+// single use user tokens, the failed login attempts and the recovery codes.
+type SessionStore interface {
+	CreateRefreshToken(*domain.RefreshToken) error
+	GetRefreshToken(string) (*domain.RefreshToken, error)
+	RevokeRefreshToken(hash string, at time.Time, replacedBy *kallax.ULID) (bool, error)
+	RevokeUserRefreshTokens(kallax.ULID, time.Time) error
+	DenyToken(jti string, expiresAt time.Time) error
+	IsTokenDenied(string) (bool, error)
+	CreateUserToken(*domain.UserToken) error
+	ConsumeUserToken(string, domain.UserTokenPurpose, time.Time) (*domain.UserToken, error)
+	GetActiveUserToken(string, domain.UserTokenPurpose, time.Time) (*domain.UserToken, error)
+	UseUserTokens(kallax.ULID, domain.UserTokenPurpose, time.Time) error
+	GetLoginThrottles(...string) ([]domain.LoginThrottle, error)
+	FailLoginThrottle(key string, at, windowStart time.Time) (*domain.LoginThrottle, error)
+	LockLoginThrottle(key string, until time.Time) error
+	RemoveLoginThrottles(...string) error
+	ReplaceRecoveryCodes(kallax.ULID, []domain.RecoveryCode) error
+	UseRecoveryCode(userID kallax.ULID, hash string, at time.Time) error
+	RemoveRecoveryCodes(kallax.ULID) error
+}
+
+func newRefreshToken(userID kallax.ULID) domain.RefreshToken {
+	id := kallax.NewULID()
+	return domain.RefreshToken{ID: id, Hash: id.String(), ExpiresAt: now().Add(time.Hour), CreatedAt: now(), UserID: userID}
+}
+
+func newUserToken(userID kallax.ULID, purpose domain.UserTokenPurpose, expiresAt time.Time) domain.UserToken {
+	id := kallax.NewULID()
+	return domain.UserToken{ID: id, Hash: id.String(), Purpose: purpose, ExpiresAt: expiresAt, CreatedAt: now(), UserID: userID}
+}
+
+func newRecoveryCode(userID kallax.ULID) domain.RecoveryCode {
+	id := kallax.NewULID()
+	return domain.RecoveryCode{ID: id, Hash: id.String(), CreatedAt: now(), UserID: userID}
+}
+
+// RunSessionStore runs the conformance tests of a SessionStore.
+func RunSessionStore(t *testing.T, s SessionStore) {
+	t.Run("refresh tokens", func(t *testing.T) {
+		userID := kallax.NewULID()
+		t1, t2 := newRefreshToken(userID), newRefreshToken(userID)
+		require.Nil(t, s.CreateRefreshToken(&t1))
+		require.Nil(t, s.CreateRefreshToken(&t2))
+
+		result, err := s.GetRefreshToken(t1.Hash)
+		require.Nil(t, err)
+		assert.Equal(t, t1.ID, result.ID)
+		assert.Nil(t, result.RevokedAt)
+		_, err = s.GetRefreshToken("missing")
+		assert.True(t, isNotFound(err))
+
+		revoked, err := s.RevokeRefreshToken(t1.Hash, now(), &t2.ID)
+		require.Nil(t, err)
+		assert.True(t, revoked)
+		revoked, err = s.RevokeRefreshToken(t1.Hash, now(), nil)
+		require.Nil(t, err)
+		assert.False(t, revoked, "a token is revoked once")
+		result, err = s.GetRefreshToken(t1.Hash)
+		require.Nil(t, err)
+		require.NotNil(t, result.ReplacedBy)
+		assert.Equal(t, t2.ID, *result.ReplacedBy)
+
+		require.Nil(t, s.RevokeUserRefreshTokens(userID, now()))
+		result, err = s.GetRefreshToken(t2.Hash)
+		require.Nil(t, err)
+		assert.NotNil(t, result.RevokedAt)
+	})
+
+	t.Run("expired refresh tokens are purged", func(t *testing.T) {
+		expired, active := newRefreshToken(kallax.NewULID()), newRefreshToken(kallax.NewULID())
+		expired.ExpiresAt = now().Add(-time.Hour)
+		require.Nil(t, s.CreateRefreshToken(&expired))
+		require.Nil(t, s.CreateRefreshToken(&active))
+
+		_, err := s.GetRefreshToken(expired.Hash)
+		assert.True(t, isNotFound(err))
+		_, err = s.GetRefreshToken(active.Hash)
+		assert.Nil(t, err)
+	})
+
+	t.Run("denied tokens", func(t *testing.T) {
+		jti, expired := kallax.NewULID().String(), kallax.NewULID().String()
+		require.Nil(t, s.DenyToken(jti, now().Add(time.Hour)))
+		require.Nil(t, s.DenyToken(jti, now().Add(time.Hour)))
+		require.Nil(t, s.DenyToken(expired, now().Add(-time.Hour)))
+
+		denied, err := s.IsTokenDenied(jti)
+		require.Nil(t, err)
+		assert.True(t, denied)
+		denied, err = s.IsTokenDenied(expired)
+		require.Nil(t, err)
+		assert.False(t, denied)
+		denied, err = s.IsTokenDenied("missing")
+		require.Nil(t, err)
+		assert.False(t, denied)
+	})
+
+	t.Run("user tokens", func(t *testing.T) {
+		userID := kallax.NewULID()
+		active := newUserToken(userID, domain.VerifyEmailPurpose, now().Add(time.Hour))
+		expired := newUserToken(userID, domain.VerifyEmailPurpose, now().Add(-time.Hour))
+		other := newUserToken(userID, domain.ResetPasswordPurpose, now().Add(time.Hour))
+		for _, ut := range []domain.UserToken{active, expired, other} {
+			ut := ut
+			require.Nil(t, s.CreateUserToken(&ut))
+		}
+
+		result, err := s.GetActiveUserToken(active.Hash, domain.VerifyEmailPurpose, now())
+		require.Nil(t, err)
+		assert.Equal(t, active.ID, result.ID)
+		_, err = s.GetActiveUserToken(active.Hash, domain.ResetPasswordPurpose, now())
+		assert.True(t, isNotFound(err))
+		_, err = s.GetActiveUserToken(expired.Hash, domain.VerifyEmailPurpose, now())
+		assert.True(t, isNotFound(err))
+
+		result, err = s.ConsumeUserToken(active.Hash, domain.VerifyEmailPurpose, now())
+		require.Nil(t, err)
+		assert.Equal(t, active.ID, result.ID)
+		assert.NotNil(t, result.UsedAt)
+		_, err = s.ConsumeUserToken(active.Hash, domain.VerifyEmailPurpose, now())
+		assert.True(t, isNotFound(err))
+
+		require.Nil(t, s.UseUserTokens(userID, domain.ResetPasswordPurpose, now()))
+		_, err = s.GetActiveUserToken(other.Hash, domain.ResetPasswordPurpose, now())
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("consume a user token once", func(t *testing.T) {
+		ut := newUserToken(kallax.NewULID(), domain.TwoFactorPurpose, now().Add(time.Hour))
+		require.Nil(t, s.CreateUserToken(&ut))
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		var consumed int
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.ConsumeUserToken(ut.Hash, domain.TwoFactorPurpose, now()); err == nil {
+					mu.Lock()
+					consumed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, 1, consumed)
+	})
+
+	t.Run("login throttles", func(t *testing.T) {
+		key, other := "email:"+kallax.NewULID().String(), "ip:"+kallax.NewULID().String()
+		at := now()
+		tr, err := s.FailLoginThrottle(key, at, at.Add(-time.Hour))
+		require.Nil(t, err)
+		assert.Equal(t, 1, tr.Failures)
+		tr, err = s.FailLoginThrottle(key, at.Add(time.Second), at.Add(-time.Hour))
+		require.Nil(t, err)
+		assert.Equal(t, 2, tr.Failures)
+		assert.True(t, at.Add(time.Second).Equal(tr.LastFailureAt))
+		_, err = s.FailLoginThrottle(other, at, at.Add(-time.Hour))
+		require.Nil(t, err)
+
+		until := at.Add(time.Minute)
+		require.Nil(t, s.LockLoginThrottle(key, until))
+		throttles, err := s.GetLoginThrottles(key, other, "missing")
+		require.Nil(t, err)
+		assert.Len(t, throttles, 2)
+		for _, tr := range throttles {
+			if tr.Key == key {
+				require.NotNil(t, tr.LockedUntil)
+				assert.True(t, until.Equal(*tr.LockedUntil))
+			}
+		}
+
+		tr, err = s.FailLoginThrottle(key, until.Add(time.Second), at.Add(-time.Hour))
+		require.Nil(t, err)
+		assert.Equal(t, 1, tr.Failures, "the count restarts once the lock expires")
+		assert.Nil(t, tr.LockedUntil)
+		tr, err = s.FailLoginThrottle(key, until.Add(2*time.Hour), until.Add(time.Hour))
+		require.Nil(t, err)
+		assert.Equal(t, 1, tr.Failures, "the count restarts after the window")
+
+		require.Nil(t, s.RemoveLoginThrottles(key, other))
+		throttles, err = s.GetLoginThrottles(key, other)
+		require.Nil(t, err)
+		assert.Empty(t, throttles)
+	})
+
+	t.Run("concurrent failed logins are all counted", func(t *testing.T) {
+		key := "email:" + kallax.NewULID().String()
+		at := now()
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.FailLoginThrottle(key, at, at.Add(-time.Hour))
+			}()
+		}
+		wg.Wait()
+
+		throttles, err := s.GetLoginThrottles(key)
+		require.Nil(t, err)
+		require.Len(t, throttles, 1)
+		assert.Equal(t, 10, throttles[0].Failures)
+	})
+
+	t.Run("recovery codes", func(t *testing.T) {
+		userID := kallax.NewULID()
+		old := newRecoveryCode(userID)
+		require.Nil(t, s.ReplaceRecoveryCodes(userID, []domain.RecoveryCode{old}))
+		c1, c2 := newRecoveryCode(userID), newRecoveryCode(userID)
+		require.Nil(t, s.ReplaceRecoveryCodes(userID, []domain.RecoveryCode{c1, c2}))
+
+		assert.True(t, isNotFound(s.UseRecoveryCode(userID, old.Hash, now())), "replaced codes are removed")
+		require.Nil(t, s.UseRecoveryCode(userID, c1.Hash, now()))
+		assert.True(t, isNotFound(s.UseRecoveryCode(userID, c1.Hash, now())), "a code is used once")
+		assert.True(t, isNotFound(s.UseRecoveryCode(kallax.NewULID(), c2.Hash, now())))
+
+		require.Nil(t, s.RemoveRecoveryCodes(userID))
+		assert.True(t, isNotFound(s.UseRecoveryCode(userID, c2.Hash, now())))
+	})
+}
@@ -0,0 +1,80 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// ShareLinkStore is the storage of the repository share links.
+type ShareLinkStore interface {
+	CreateShareLink(*domain.ShareLink) error
+	ListShareLinks(kallax.ULID) ([]domain.ShareLink, error)
+	GetShareLink(linkID, repoID kallax.ULID) (*domain.ShareLink, error)
+	GetShareLinkByID(kallax.ULID) (*domain.ShareLink, error)
+	SaveShareLink(*domain.ShareLink) error
+}
+
+func newShareLink(repoID kallax.ULID, createdAt time.Time) domain.ShareLink {
+	return domain.ShareLink{
+		ID:           kallax.NewULID(),
+		Name:         "test",
+		ExpiresAt:    createdAt.Add(time.Hour),
+		CreatedAt:    createdAt,
+		RepositoryID: repoID,
+		UserID:       kallax.NewULID(),
+	}
+}
+
+// RunShareLinkStore runs the conformance tests of a ShareLinkStore.
+func RunShareLinkStore(t *testing.T, s ShareLinkStore) {
+	t.Run("create, list and get", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		l1, l2 := newShareLink(repoID, now()), newShareLink(repoID, now().Add(-time.Minute))
+		other := newShareLink(kallax.NewULID(), now())
+		for _, l := range []domain.ShareLink{l1, l2, other} {
+			l := l
+			require.Nil(t, s.CreateShareLink(&l))
+		}
+
+		links, err := s.ListShareLinks(repoID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{l2.ID, l1.ID}, ulids(len(links), func(i int) kallax.ULID { return links[i].ID }))
+
+		l, err := s.GetShareLink(l1.ID, repoID)
+		require.Nil(t, err)
+		assert.True(t, l1.ExpiresAt.Equal(l.ExpiresAt))
+		assert.Equal(t, l1.UserID, l.UserID)
+		l, err = s.GetShareLinkByID(other.ID)
+		require.Nil(t, err)
+		assert.Equal(t, other.RepositoryID, l.RepositoryID)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		l := newShareLink(kallax.NewULID(), now())
+		require.Nil(t, s.CreateShareLink(&l))
+
+		_, err := s.GetShareLink(l.ID, kallax.NewULID())
+		assert.True(t, isNotFound(err))
+		_, err = s.GetShareLinkByID(kallax.NewULID())
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("save", func(t *testing.T) {
+		l := newShareLink(kallax.NewULID(), now())
+		require.Nil(t, s.CreateShareLink(&l))
+
+		revokedAt := now()
+		l.RevokedAt = &revokedAt
+		require.Nil(t, s.SaveShareLink(&l))
+		result, err := s.GetShareLinkByID(l.ID)
+		require.Nil(t, err)
+		require.NotNil(t, result.RevokedAt)
+		assert.True(t, revokedAt.Equal(*result.RevokedAt))
+	})
+}
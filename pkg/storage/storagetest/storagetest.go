@@ -0,0 +1,84 @@
+// Package storagetest holds the conformance tests shared by the storages. The memory and
+// the postgres storages of an interface run the same tests, so both behave alike. The
+// postgres tests run against the database at the PGEnv url, they are skipped without it.
+package storagetest
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+)
+
+// PGEnv is the environment variable with the url of the database of the postgres tests.
+const PGEnv = "CAPTURE_TEST_PG"
+
+// Schema is a postgres storage owning its tables.
+type Schema interface {
+	CreateSchema() error
+	Drop() error
+}
+
+// PG connects to the database at the PGEnv url and creates the schemas, which are dropped
+// when the test finishes. The test is skipped when PGEnv is not set.
+func PG(t *testing.T, schemas ...func(*pg.DB) Schema) *pg.DB {
+	url := os.Getenv(PGEnv)
+	if url == "" {
+		t.Skipf("%s not set, skipping postgres storage tests", PGEnv)
+	}
+	opts, err := pg.ParseURL(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := pg.Connect(opts)
+	t.Cleanup(func() { db.Close() })
+	for _, schema := range schemas {
+		s := schema(db)
+		if err := s.CreateSchema(); err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			if err := s.Drop(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	return db
+}
+
+type notFoundErr interface {
+	NotFound() bool
+}
+
+// isNotFound reports whether err is the not found error of a storage.
+func isNotFound(err error) bool {
+	e, ok := errors.Cause(err).(notFoundErr)
+	return ok && e.NotFound()
+}
+
+type uniqueConstraintErr interface {
+	UniqueConstraint() bool
+}
+
+// isUniqueConstraint reports whether err is the duplicated record error of a storage.
+func isUniqueConstraint(err error) bool {
+	e, ok := errors.Cause(err).(uniqueConstraintErr)
+	return ok && e.UniqueConstraint()
+}
+
+// now returns the current time rounded to the microseconds kept by postgres.
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// ulids returns the ids of n records.
+func ulids(n int, id func(i int) kallax.ULID) []kallax.ULID {
+	ids := make([]kallax.ULID, n)
+	for i := range ids {
+		ids[i] = id(i)
+	}
+	return ids
+}
@@ -0,0 +1,96 @@
+package storagetest
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// UsageStore is the storage of the captures and bytes used by the repositories.
+type UsageStore interface {
+	GetRepoUsage(kallax.ULID) (*domain.Usage, error)
+	GetUserUsage(kallax.ULID) (*domain.Usage, error)
+	ReserveUsage(delta domain.Usage, check func(repo, user domain.Usage) error) error
+	ReleaseUsage(domain.Usage) error
+}
+
+// RunUsageStore runs the conformance tests of an UsageStore.
+func RunUsageStore(t *testing.T, s UsageStore) {
+	allow := func(repo, user domain.Usage) error { return nil }
+
+	t.Run("reserve and release", func(t *testing.T) {
+		userID, repo1, repo2 := kallax.NewULID(), kallax.NewULID(), kallax.NewULID()
+		u, err := s.GetRepoUsage(repo1)
+		require.Nil(t, err)
+		assert.Equal(t, int64(0), u.Captures)
+
+		require.Nil(t, s.ReserveUsage(domain.Usage{RepositoryID: repo1, UserID: userID, Captures: 2, Bytes: 20}, allow))
+		require.Nil(t, s.ReserveUsage(domain.Usage{RepositoryID: repo2, UserID: userID, Captures: 1, Bytes: 10}, allow))
+
+		var checked domain.Usage
+		check := func(repo, user domain.Usage) error {
+			checked = user
+			assert.Equal(t, int64(2), repo.Captures)
+			return nil
+		}
+		require.Nil(t, s.ReserveUsage(domain.Usage{RepositoryID: repo1, UserID: userID, Captures: 1, Bytes: 10}, check))
+		assert.Equal(t, int64(3), checked.Captures)
+		assert.Equal(t, int64(30), checked.Bytes)
+
+		u, err = s.GetRepoUsage(repo1)
+		require.Nil(t, err)
+		assert.Equal(t, int64(3), u.Captures)
+		assert.Equal(t, int64(30), u.Bytes)
+		u, err = s.GetUserUsage(userID)
+		require.Nil(t, err)
+		assert.Equal(t, int64(4), u.Captures)
+		assert.Equal(t, int64(40), u.Bytes)
+
+		require.Nil(t, s.ReleaseUsage(domain.Usage{RepositoryID: repo1, UserID: userID, Captures: 5, Bytes: 10}))
+		u, err = s.GetRepoUsage(repo1)
+		require.Nil(t, err)
+		assert.Equal(t, int64(0), u.Captures, "the usage doesn't go below zero")
+		assert.Equal(t, int64(20), u.Bytes)
+	})
+
+	t.Run("reject reservation", func(t *testing.T) {
+		userID, repoID := kallax.NewULID(), kallax.NewULID()
+		require.Nil(t, s.ReserveUsage(domain.Usage{RepositoryID: repoID, UserID: userID, Captures: 1}, allow))
+
+		reject := func(repo, user domain.Usage) error { return errors.New("quota exceeded") }
+		err := s.ReserveUsage(domain.Usage{RepositoryID: repoID, UserID: userID, Captures: 1}, reject)
+		assert.Contains(t, err.Error(), "quota exceeded")
+		u, err := s.GetRepoUsage(repoID)
+		require.Nil(t, err)
+		assert.Equal(t, int64(1), u.Captures)
+	})
+
+	t.Run("concurrent reservations are serialized", func(t *testing.T) {
+		userID, repoID := kallax.NewULID(), kallax.NewULID()
+		limit := func(repo, user domain.Usage) error {
+			if user.Captures+1 > 5 {
+				return errors.New("quota exceeded")
+			}
+			return nil
+		}
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.ReserveUsage(domain.Usage{RepositoryID: repoID, UserID: userID, Captures: 1}, limit)
+			}()
+		}
+		wg.Wait()
+
+		u, err := s.GetUserUsage(userID)
+		require.Nil(t, err)
+		assert.Equal(t, int64(5), u.Captures)
+	})
+}
@@ -0,0 +1,158 @@
+package storagetest
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// UserStore is the storage of the user accounts.
+type UserStore interface {
+	SaveUser(*domain.User) error
+	GetUserByEmail(string) (*domain.User, error)
+	GetUserByID(kallax.ULID) (*domain.User, error)
+	ListUsers(*domain.Listing) ([]domain.User, int64, error)
+	UpdateUser(*domain.User) error
+	AdvanceTwoFactorStep(id kallax.ULID, step int64) (bool, error)
+	RemoveUser(*domain.User) error
+}
+
+// newUser returns a user named name, the email is unique.
+func newUser(name string, createdAt time.Time) domain.User {
+	id := kallax.NewULID()
+	return domain.User{
+		ID:        id,
+		Email:     id.String() + "@example.com",
+		Name:      name,
+		Password:  []byte("hash"),
+		CreatedAt: createdAt,
+		UpdatedAt: createdAt,
+	}
+}
+
+// RunUserStore runs the conformance tests of an UserStore.
+func RunUserStore(t *testing.T, s UserStore) {
+	t.Run("save and get", func(t *testing.T) {
+		u := newUser("test", now())
+		require.Nil(t, s.SaveUser(&u))
+
+		result, err := s.GetUserByID(u.ID)
+		require.Nil(t, err)
+		assert.Equal(t, u.Email, result.Email)
+		assert.Equal(t, u.Password, result.Password)
+		assert.True(t, u.CreatedAt.Equal(result.CreatedAt))
+		result, err = s.GetUserByEmail(u.Email)
+		require.Nil(t, err)
+		assert.Equal(t, u.ID, result.ID)
+
+		_, err = s.GetUserByID(kallax.NewULID())
+		assert.True(t, isNotFound(err))
+		_, err = s.GetUserByEmail("missing@example.com")
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("unique email", func(t *testing.T) {
+		u, other := newUser("test", now()), newUser("test", now())
+		require.Nil(t, s.SaveUser(&u))
+		require.Nil(t, s.SaveUser(&other))
+
+		duplicated := newUser("test", now())
+		duplicated.Email = u.Email
+		assert.True(t, isUniqueConstraint(s.SaveUser(&duplicated)))
+		other.Email = u.Email
+		assert.True(t, isUniqueConstraint(s.UpdateUser(&other)))
+
+		require.Nil(t, s.RemoveUser(&u))
+		assert.True(t, isUniqueConstraint(s.SaveUser(&duplicated)), "removed users keep their email")
+	})
+
+	t.Run("list", func(t *testing.T) {
+		// the name scopes the listing to the users of the test.
+		name := kallax.NewULID().String()
+		u1, u2, u3 := newUser(name, now().Add(-time.Minute)), newUser(name, now()), newUser(name, now().Add(-time.Hour))
+		disabledAt := now()
+		u3.DisabledAt = &disabledAt
+		removed := newUser(name, now())
+		for _, u := range []domain.User{u1, u2, u3, removed, newUser("other", now())} {
+			u := u
+			require.Nil(t, s.SaveUser(&u))
+		}
+		require.Nil(t, s.RemoveUser(&removed))
+
+		users, total, err := s.ListUsers(&domain.Listing{Search: &name, SortKey: "created_at DESC", Limit: 2})
+		require.Nil(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, []kallax.ULID{u2.ID, u1.ID}, ulids(len(users), func(i int) kallax.ULID { return users[i].ID }))
+
+		users, _, err = s.ListUsers(&domain.Listing{Search: &name, SortKey: "created_at ASC", Offset: 1, Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{u1.ID, u2.ID}, ulids(len(users), func(i int) kallax.ULID { return users[i].ID }))
+
+		upper := strings.ToUpper(u1.ID.String())
+		_, total, err = s.ListUsers(&domain.Listing{Search: &upper, SortKey: "created_at ASC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(1), total, "the search ignores the case")
+
+		disabled := true
+		users, total, err = s.ListUsers(&domain.Listing{Search: &name, Disabled: &disabled, SortKey: "created_at ASC", Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(1), total)
+		assert.Equal(t, []kallax.ULID{u3.ID}, ulids(len(users), func(i int) kallax.ULID { return users[i].ID }))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		u := newUser("test", now())
+		require.Nil(t, s.SaveUser(&u))
+
+		u.Name = "updated"
+		u.Admin = true
+		require.Nil(t, s.UpdateUser(&u))
+		result, err := s.GetUserByID(u.ID)
+		require.Nil(t, err)
+		assert.Equal(t, "updated", result.Name)
+		assert.True(t, result.Admin)
+
+		missing := newUser("test", now())
+		assert.True(t, isNotFound(s.UpdateUser(&missing)))
+	})
+
+	t.Run("advance two factor step", func(t *testing.T) {
+		u := newUser("test", now())
+		require.Nil(t, s.SaveUser(&u))
+
+		advanced, err := s.AdvanceTwoFactorStep(u.ID, 10)
+		require.Nil(t, err)
+		assert.True(t, advanced)
+		advanced, err = s.AdvanceTwoFactorStep(u.ID, 10)
+		require.Nil(t, err)
+		assert.False(t, advanced, "a step is used once")
+		advanced, err = s.AdvanceTwoFactorStep(u.ID, 9)
+		require.Nil(t, err)
+		assert.False(t, advanced)
+		result, err := s.GetUserByID(u.ID)
+		require.Nil(t, err)
+		assert.Equal(t, int64(10), result.TwoFactorLastStep)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		u := newUser("test", now())
+		require.Nil(t, s.SaveUser(&u))
+
+		require.Nil(t, s.RemoveUser(&u))
+		_, err := s.GetUserByID(u.ID)
+		assert.True(t, isNotFound(err))
+		_, err = s.GetUserByEmail(u.Email)
+		assert.True(t, isNotFound(err))
+		assert.True(t, isNotFound(s.UpdateUser(&u)), "removed users aren't updated")
+		assert.True(t, isNotFound(s.RemoveUser(&u)))
+		advanced, err := s.AdvanceTwoFactorStep(u.ID, 1)
+		require.Nil(t, err)
+		assert.False(t, advanced)
+	})
+}
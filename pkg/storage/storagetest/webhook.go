@@ -0,0 +1,110 @@
+package storagetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// WebhookStore is the storage of the repository webhooks and their deliveries.
+type WebhookStore interface {
+	CreateWebhook(*domain.Webhook) error
+	ListWebhooks(kallax.ULID) ([]domain.Webhook, error)
+	GetWebhook(webhookID, repoID kallax.ULID) (*domain.Webhook, error)
+	SaveWebhook(*domain.Webhook) error
+	CreateWebhookDelivery(*domain.WebhookDelivery) error
+	ListWebhookDeliveries(*domain.Listing) ([]domain.WebhookDelivery, int64, error)
+}
+
+func newWebhook(repoID kallax.ULID, createdAt time.Time) domain.Webhook {
+	return domain.Webhook{
+		ID:           kallax.NewULID(),
+		URL:          "https://example.com/hook",
+		Secret:       "secret",
+		Events:       []domain.EventType{domain.CaptureCreated},
+		CreatedAt:    createdAt,
+		UpdatedAt:    createdAt,
+		RepositoryID: repoID,
+	}
+}
+
+func newWebhookDelivery(webhookID kallax.ULID, createdAt time.Time) domain.WebhookDelivery {
+	return domain.WebhookDelivery{
+		ID:         kallax.NewULID(),
+		EventID:    kallax.NewULID(),
+		EventType:  domain.CaptureCreated,
+		Attempt:    1,
+		StatusCode: 200,
+		Duration:   10,
+		CreatedAt:  createdAt,
+		WebhookID:  webhookID,
+	}
+}
+
+// RunWebhookStore runs the conformance tests of a WebhookStore.
+func RunWebhookStore(t *testing.T, s WebhookStore) {
+	t.Run("create, list and get", func(t *testing.T) {
+		repoID := kallax.NewULID()
+		w1, w2 := newWebhook(repoID, now()), newWebhook(repoID, now().Add(-time.Minute))
+		other := newWebhook(kallax.NewULID(), now())
+		for _, w := range []domain.Webhook{w1, w2, other} {
+			w := w
+			require.Nil(t, s.CreateWebhook(&w))
+		}
+
+		hooks, err := s.ListWebhooks(repoID)
+		require.Nil(t, err)
+		assert.Equal(t, []kallax.ULID{w2.ID, w1.ID}, ulids(len(hooks), func(i int) kallax.ULID { return hooks[i].ID }))
+
+		w, err := s.GetWebhook(w1.ID, repoID)
+		require.Nil(t, err)
+		assert.Equal(t, w1.URL, w.URL)
+		assert.Equal(t, w1.Events, w.Events)
+
+		_, err = s.GetWebhook(w1.ID, other.RepositoryID)
+		assert.True(t, isNotFound(err))
+	})
+
+	t.Run("save and soft delete", func(t *testing.T) {
+		w := newWebhook(kallax.NewULID(), now())
+		require.Nil(t, s.CreateWebhook(&w))
+
+		w.Events = []domain.EventType{domain.CaptureCreated, domain.CaptureRemoved}
+		require.Nil(t, s.SaveWebhook(&w))
+		result, err := s.GetWebhook(w.ID, w.RepositoryID)
+		require.Nil(t, err)
+		assert.Equal(t, w.Events, result.Events)
+
+		deletedAt := now()
+		w.DeletedAt = &deletedAt
+		require.Nil(t, s.SaveWebhook(&w))
+		_, err = s.GetWebhook(w.ID, w.RepositoryID)
+		assert.True(t, isNotFound(err))
+		hooks, err := s.ListWebhooks(w.RepositoryID)
+		require.Nil(t, err)
+		assert.Empty(t, hooks)
+	})
+
+	t.Run("deliveries", func(t *testing.T) {
+		webhookID := kallax.NewULID()
+		var ids []kallax.ULID
+		for i := 0; i < 3; i++ {
+			d := newWebhookDelivery(webhookID, now().Add(time.Duration(i)*time.Second))
+			require.Nil(t, s.CreateWebhookDelivery(&d))
+			ids = append(ids, d.ID)
+		}
+		other := newWebhookDelivery(kallax.NewULID(), now())
+		require.Nil(t, s.CreateWebhookDelivery(&other))
+
+		deliveries, total, err := s.ListWebhookDeliveries(&domain.Listing{Owner: &webhookID, SortKey: "created_at DESC", Offset: 1, Limit: 10})
+		require.Nil(t, err)
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, []kallax.ULID{ids[1], ids[0]}, ulids(len(deliveries), func(i int) kallax.ULID { return deliveries[i].ID }))
+		assert.Equal(t, 200, deliveries[0].StatusCode)
+	})
+}
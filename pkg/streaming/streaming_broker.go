@@ -0,0 +1,149 @@
+package streaming
+
+import (
+	"bytes"
+	"sync"
+
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	// DefaultHistorySize is the amount of events kept by repository to resume the streams.
+	DefaultHistorySize = 1000
+	subscriptionBuffer = 64
+)
+
+// Subscription receives the events of a repository that match a filter.
+type Subscription struct {
+	// Replay holds the events published since the last event seen by the subscriber.
+	Replay []domain.Event
+	events chan domain.Event
+	filter Filter
+	repoID kallax.ULID
+	broker *Broker
+	once   sync.Once
+}
+
+// Events returns the channel where the new events are sent. The channel is
+// closed when the subscription is closed or when the subscriber falls too far behind.
+func (s *Subscription) Events() <-chan domain.Event { return s.events }
+
+// Close stops receiving events.
+func (s *Subscription) Close() { s.broker.unsubscribe(s) }
+
+func (s *Subscription) close() { s.once.Do(func() { close(s.events) }) }
+
+// Service provides streaming operations.
+type Service interface {
+	// Subscribe to the events of a repository. When lastEventID is given the
+	// events published after it are returned within the subscription replay.
+	Subscribe(r *domain.Repository, f Filter, lastEventID *kallax.ULID) *Subscription
+}
+
+// Broker keeps the recent events of every repository and fans them out to the subscribers.
+type Broker struct {
+	mu          sync.Mutex
+	historySize int
+	history     map[kallax.ULID][]domain.Event
+	subscribers map[kallax.ULID]map[*Subscription]struct{}
+}
+
+// NewBroker creates a broker keeping up to historySize events by repository.
+func NewBroker(historySize int) *Broker {
+	return &Broker{
+		historySize: historySize,
+		history:     make(map[kallax.ULID][]domain.Event),
+		subscribers: make(map[kallax.ULID]map[*Subscription]struct{}),
+	}
+}
+
+// Publish sends the capture events to the subscribers of their repositories. Subscribers
+// not able to keep up are disconnected instead of blocking the publisher.
+func (b *Broker) Publish(events ...domain.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, e := range events {
+		if e.Capture == nil {
+			continue
+		}
+		b.remember(e)
+		for sub := range b.subscribers[e.RepositoryID] {
+			if !sub.filter.Match(e) {
+				continue
+			}
+			select {
+			case sub.events <- e:
+			default:
+				b.remove(sub)
+			}
+		}
+	}
+}
+
+func (b *Broker) Subscribe(r *domain.Repository, f Filter, lastEventID *kallax.ULID) *Subscription {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	sub := &Subscription{
+		events: make(chan domain.Event, subscriptionBuffer),
+		filter: f,
+		repoID: r.ID,
+		broker: b,
+	}
+	if lastEventID != nil {
+		for _, e := range eventsAfter(b.history[r.ID], *lastEventID) {
+			if f.Match(e) {
+				sub.Replay = append(sub.Replay, e)
+			}
+		}
+	}
+	if b.subscribers[r.ID] == nil {
+		b.subscribers[r.ID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[r.ID][sub] = struct{}{}
+	return sub
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remove(sub)
+}
+
+func (b *Broker) remove(sub *Subscription) {
+	if subs, ok := b.subscribers[sub.repoID]; ok {
+		delete(subs, sub)
+		if len(subs) == 0 {
+			delete(b.subscribers, sub.repoID)
+		}
+	}
+	sub.close()
+}
+
+func (b *Broker) remember(e domain.Event) {
+	if b.historySize <= 0 {
+		return
+	}
+	h := append(b.history[e.RepositoryID], e)
+	if len(h) > b.historySize {
+		h = append([]domain.Event(nil), h[len(h)-b.historySize:]...)
+	}
+	b.history[e.RepositoryID] = h
+}
+
+// eventsAfter returns the events following the given id. Event ids are ULIDs so when
+// the id is no longer in the history the events are compared by their ordering.
+func eventsAfter(history []domain.Event, id kallax.ULID) []domain.Event {
+	for i, e := range history {
+		if e.ID == id {
+			return history[i+1:]
+		}
+	}
+	for i, e := range history {
+		if bytes.Compare(e.ID[:], id[:]) > 0 {
+			return history[i:]
+		}
+	}
+	return nil
+}
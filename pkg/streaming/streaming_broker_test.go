@@ -0,0 +1,114 @@
+package streaming_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/streaming"
+)
+
+func newEvent(r *domain.Repository, tags ...string) domain.Event {
+	return domain.NewCaptureEvent(domain.CaptureCreated, domain.Capture{
+		ID:           kallax.NewULID(),
+		Tags:         tags,
+		RepositoryID: r.ID,
+	})
+}
+
+func TestBrokerPublishToRepoSubscribers(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	other := &domain.Repository{ID: kallax.NewULID()}
+	b := streaming.NewBroker(10)
+	sub := b.Subscribe(repo, streaming.Filter{}, nil)
+	defer sub.Close()
+
+	e := newEvent(repo)
+	b.Publish(newEvent(other), e)
+
+	assert.Equal(t, e, <-sub.Events())
+	assert.Len(t, sub.Events(), 0)
+}
+
+func TestBrokerPublishFiltered(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	b := streaming.NewBroker(10)
+	sub := b.Subscribe(repo, streaming.Filter{Tags: []string{"a"}}, nil)
+	defer sub.Close()
+
+	e := newEvent(repo, "a")
+	b.Publish(newEvent(repo, "b"), e)
+
+	assert.Equal(t, e, <-sub.Events())
+	assert.Len(t, sub.Events(), 0)
+}
+
+func TestBrokerSubscribeReplay(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	b := streaming.NewBroker(10)
+	e1, e2, e3 := newEvent(repo), newEvent(repo), newEvent(repo)
+	b.Publish(e1, e2, e3)
+
+	sub := b.Subscribe(repo, streaming.Filter{}, &e1.ID)
+	defer sub.Close()
+	assert.Equal(t, []domain.Event{e2, e3}, sub.Replay)
+
+	sub2 := b.Subscribe(repo, streaming.Filter{}, nil)
+	defer sub2.Close()
+	assert.Len(t, sub2.Replay, 0)
+}
+
+func TestBrokerSubscribeReplayMissingEvent(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	b := streaming.NewBroker(2)
+	e1, e2, e3 := newEvent(repo), newEvent(repo), newEvent(repo)
+	// ids generated within the same millisecond are not ordered
+	e1.ID, e2.ID, e3.ID = kallax.ULID{1}, kallax.ULID{2}, kallax.ULID{3}
+	b.Publish(e1, e2, e3)
+
+	sub := b.Subscribe(repo, streaming.Filter{}, &e1.ID)
+	defer sub.Close()
+	assert.Equal(t, []domain.Event{e2, e3}, sub.Replay)
+}
+
+func TestBrokerDropSlowSubscriber(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	b := streaming.NewBroker(0)
+	sub := b.Subscribe(repo, streaming.Filter{}, nil)
+	for i := 0; i < 100; i++ {
+		b.Publish(newEvent(repo))
+	}
+
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+	assert.True(t, received < 100)
+	sub.Close()
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	t.Parallel()
+
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	b := streaming.NewBroker(10)
+	sub := b.Subscribe(repo, streaming.Filter{}, nil)
+	sub.Close()
+	sub.Close()
+	b.Publish(newEvent(repo))
+
+	_, ok := <-sub.Events()
+	assert.False(t, ok)
+}
@@ -0,0 +1,80 @@
+package streaming
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const errInvalidArea = "area must have the form minLat,minLng,maxLat,maxLng"
+
+type invalidFilterErr string
+
+func (i invalidFilterErr) Error() string   { return string(i) }
+func (i invalidFilterErr) IsInvalid() bool { return true }
+
+// Filter restricts the events sent to a subscriber.
+type Filter struct {
+	// Tags keeps only the captures with at least one of the tags.
+	Tags []string
+	// Area keeps only the captures located inside the polygon.
+	Area domain.Polygon
+}
+
+// ParseArea returns the polygon of a bounding box with the form minLat,minLng,maxLat,maxLng.
+func ParseArea(bbox string) (domain.Polygon, error) {
+	parts := strings.Split(bbox, ",")
+	if len(parts) != 4 {
+		return nil, errors.WithStack(invalidFilterErr(errInvalidArea))
+	}
+	var v [4]float64
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, errors.WithStack(invalidFilterErr(errInvalidArea))
+		}
+		v[i] = f
+	}
+	minLat, minLng, maxLat, maxLng := v[0], v[1], v[2], v[3]
+	if minLat < -90 || maxLat > 90 || minLng < -180 || maxLng > 180 {
+		return nil, errors.WithStack(invalidFilterErr(fmt.Sprintf("area %v out of bounds", bbox)))
+	}
+	if minLat >= maxLat || minLng >= maxLng {
+		return nil, errors.WithStack(invalidFilterErr("area min values must be lower than max values"))
+	}
+	return domain.Polygon{
+		{LAT: &minLat, LNG: &minLng},
+		{LAT: &minLat, LNG: &maxLng},
+		{LAT: &maxLat, LNG: &maxLng},
+		{LAT: &maxLat, LNG: &minLng},
+	}, nil
+}
+
+// Match reports whether the event satisfies the filter.
+func (f Filter) Match(e domain.Event) bool {
+	if e.Capture == nil {
+		return len(f.Tags) == 0 && len(f.Area) == 0
+	}
+	if len(f.Tags) > 0 && !hasAnyTag(e.Capture.Tags, f.Tags) {
+		return false
+	}
+	if len(f.Area) > 0 && !f.Area.Contains(e.Capture.Location) {
+		return false
+	}
+	return true
+}
+
+func hasAnyTag(tags, wanted []string) bool {
+	for _, t := range tags {
+		for _, w := range wanted {
+			if t == w {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,74 @@
+package streaming_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/streaming"
+)
+
+func f2P(v float64) *float64 { return &v }
+
+func TestParseAreaOK(t *testing.T) {
+	t.Parallel()
+
+	area, err := streaming.ParseArea("0,0,10,20")
+	assert.Nil(t, err)
+	assert.Len(t, area, 4)
+	assert.True(t, area.Contains(&domain.Point{LAT: f2P(5), LNG: f2P(15)}))
+	assert.False(t, area.Contains(&domain.Point{LAT: f2P(15), LNG: f2P(5)}))
+}
+
+func TestParseAreaFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		bbox string
+		err  string
+	}{
+		{"missing values", "0,0,10", "area must have the form minLat,minLng,maxLat,maxLng"},
+		{"not numbers", "0,a,10,20", "area must have the form minLat,minLng,maxLat,maxLng"},
+		{"out of bounds", "0,0,100,20", "area 0,0,100,20 out of bounds"},
+		{"min greater than max", "10,0,0,20", "area min values must be lower than max values"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := streaming.ParseArea(tc.bbox)
+			assert.EqualError(t, err, tc.err)
+			assert.True(t, errors.Cause(err).(interface{ IsInvalid() bool }).IsInvalid())
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	t.Parallel()
+
+	area, _ := streaming.ParseArea("0,0,10,10")
+	inside := &domain.Capture{Tags: []string{"a"}, Location: &domain.Point{LAT: f2P(5), LNG: f2P(5)}}
+	outside := &domain.Capture{Tags: []string{"b"}, Location: &domain.Point{LAT: f2P(50), LNG: f2P(5)}}
+
+	tt := []struct {
+		name     string
+		filter   streaming.Filter
+		capt     *domain.Capture
+		expected bool
+	}{
+		{"empty filter", streaming.Filter{}, outside, true},
+		{"matching tag", streaming.Filter{Tags: []string{"x", "a"}}, inside, true},
+		{"not matching tag", streaming.Filter{Tags: []string{"a"}}, outside, false},
+		{"inside area", streaming.Filter{Area: area}, inside, true},
+		{"outside area", streaming.Filter{Area: area}, outside, false},
+		{"matching tag outside area", streaming.Filter{Tags: []string{"b"}, Area: area}, outside, false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.filter.Match(domain.Event{Capture: tc.capt}))
+		})
+	}
+}
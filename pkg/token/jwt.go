@@ -1,9 +1,11 @@
 package token
 
 import (
+	"strings"
 	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"gopkg.in/src-d/go-kallax.v1"
 
 	"github.com/ifreddyrondon/capture/pkg"
 )
@@ -11,10 +13,24 @@ import (
 // DefaultJWTExpirationDelta is the delta added to time.Now() when a jwt claims is created
 const DefaultJWTExpirationDelta = time.Hour
 
+// ImpersonationExpirationDelta is the lifetime of the tokens issued to impersonate an user.
+const ImpersonationExpirationDelta = 15 * time.Minute
+
 // JWTClaims Section of JWT. Referenced at https://tools.ietf.org/html/rfc7519#section-4.1
+// The tokens issued to OAuth2 clients carry the client_id and the space-delimited scope
+// claims. The tokens issued to an administrator impersonating an user carry the act claim.
+// Referenced at https://tools.ietf.org/html/rfc8693#section-4
 type JWTClaims struct {
 	jwt.StandardClaims
-	clock *pkg.Clock
+	ClientID string    `json:"client_id,omitempty"`
+	Scope    string    `json:"scope,omitempty"`
+	Act      *ActClaim `json:"act,omitempty"`
+	clock    *pkg.Clock
+}
+
+// ActClaim identifies the acting party to whom authority has been delegated.
+type ActClaim struct {
+	Subject string `json:"sub"`
 }
 
 // IssueIt marks the claims with iat (IssuedAt).
@@ -37,14 +53,38 @@ func (c *JWTClaims) Valid() error {
 }
 
 // NewJWTClaims returns a new filled jwt claims.
+// Id (jti) filled with an unique id, used to revoke the token.
 // Subject filled with user id.
 // IssuedAt filled with time.Now()
 // ExpiresAt filled with a future exp date provided by arg expirationDelta or DefaultJWTExpirationDelta
 func NewJWTClaims(userID string, delta time.Duration) jwt.Claims {
 	c := new(JWTClaims)
+	c.Id = kallax.NewULID().String()
 	c.Subject = userID
 	c.IssueIt()
 	c.SetExpirationDate(delta)
 
 	return c
 }
+
+// NewClientJWTClaims returns new filled jwt claims for a token issued to an OAuth2 client
+// on behalf of an user, restricted to scopes.
+func NewClientJWTClaims(userID, clientID string, scopes []string, delta time.Duration) jwt.Claims {
+	c := NewJWTClaims(userID, delta).(*JWTClaims)
+	c.ClientID = clientID
+	c.Scope = strings.Join(scopes, " ")
+	return c
+}
+
+// NewImpersonationJWTClaims returns new filled jwt claims for a token issued to the
+// actor to act as the user.
+func NewImpersonationJWTClaims(userID, actorID string, delta time.Duration) jwt.Claims {
+	c := NewJWTClaims(userID, delta).(*JWTClaims)
+	c.Act = &ActClaim{Subject: actorID}
+	return c
+}
+
+// Scopes returns the scopes of the claims.
+func (c *JWTClaims) Scopes() []string {
+	return strings.Fields(c.Scope)
+}
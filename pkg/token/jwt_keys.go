@@ -0,0 +1,314 @@
+package token
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/pkg/errors"
+)
+
+const rsaKeySize = 2048
+
+var (
+	errUnsupportedKey    = errors.New("unsupported key, it could be a RSA or a P-256 EC private key")
+	errUnsupportedMethod = errors.New("unsupported signing method, it could be one of HS256, RS256 or ES256")
+	errEmptyKeySet       = errors.New("key set must contain at least one key")
+)
+
+// Key represents a key used to sign and verify tokens, identified by its kid.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signing   interface{}
+	verifying interface{}
+}
+
+// NewHMACKey returns a symmetric HS256 key. Symmetric keys are never published.
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, signing: secret, verifying: secret}
+}
+
+// NewKey returns a RS256 or ES256 key from a private key. The kid is the RFC 7638
+// thumbprint of the public key, so every instance loading the same key agrees on it.
+func NewKey(private crypto.Signer) (*Key, error) {
+	k := &Key{signing: private, verifying: private.Public()}
+	switch p := private.(type) {
+	case *rsa.PrivateKey:
+		k.Method = jwt.SigningMethodRS256
+	case *ecdsa.PrivateKey:
+		if p.Curve != elliptic.P256() {
+			return nil, errUnsupportedKey
+		}
+		k.Method = jwt.SigningMethodES256
+	default:
+		return nil, errUnsupportedKey
+	}
+	k.ID = k.jwk().thumbprint()
+	return k, nil
+}
+
+// GenerateKey creates a new key for a RS256 or ES256 signing method.
+func GenerateKey(method string) (*Key, error) {
+	var private crypto.Signer
+	var err error
+	switch method {
+	case jwt.SigningMethodRS256.Alg():
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeySize)
+	case jwt.SigningMethodES256.Alg():
+		private, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		return nil, errUnsupportedMethod
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate key")
+	}
+	return NewKey(private)
+}
+
+// LoadPEMKey reads a RSA or EC private key from a PEM file.
+func LoadPEMKey(path string) (*Key, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read key file")
+	}
+	if rsaKey, err := jwt.ParseRSAPrivateKeyFromPEM(b); err == nil {
+		return NewKey(rsaKey)
+	}
+	if ecKey, err := jwt.ParseECPrivateKeyFromPEM(b); err == nil {
+		return NewKey(ecKey)
+	}
+	return nil, errors.Wrap(errUnsupportedKey, path)
+}
+
+func (k *Key) jwk() JWK {
+	jwk := JWK{Kid: k.ID, Use: "sig", Alg: k.Method.Alg()}
+	switch pub := k.verifying.(type) {
+	case *rsa.PublicKey:
+		jwk.Kty = "RSA"
+		jwk.N = encodeBase64(pub.N.Bytes())
+		jwk.E = encodeBase64(big.NewInt(int64(pub.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		jwk.Kty = "EC"
+		jwk.Crv = pub.Curve.Params().Name
+		jwk.X = encodeBase64(padBytes(pub.X.Bytes(), size))
+		jwk.Y = encodeBase64(padBytes(pub.Y.Bytes(), size))
+	}
+	return jwk
+}
+
+// JWK represents a public key as a JSON Web Key. Referenced at https://tools.ietf.org/html/rfc7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// thumbprint computes the RFC 7638 thumbprint with the required members in lexicographic order.
+func (j JWK) thumbprint() string {
+	var members string
+	switch j.Kty {
+	case "RSA":
+		members = fmt.Sprintf(`{"e":"%s","kty":"RSA","n":"%s"}`, j.E, j.N)
+	case "EC":
+		members = fmt.Sprintf(`{"crv":"%s","kty":"EC","x":"%s","y":"%s"}`, j.Crv, j.X, j.Y)
+	}
+	sum := sha256.Sum256([]byte(members))
+	return encodeBase64(sum[:])
+}
+
+// JWKS represents a JSON Web Key Set.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// KeyService provides the public keys to verify the tokens.
+type KeyService interface {
+	JWKS() JWKS
+}
+
+type keyEntry struct {
+	key       *Key
+	retiredAt time.Time
+}
+
+// KeySet holds the keys of a JwtService. Only one key signs at a time while every
+// key in the set verifies. On rotation the pending key, already published, starts
+// signing and the previous signing key is kept for verification during the retention.
+type KeySet struct {
+	mu      sync.RWMutex
+	entries []*keyEntry
+	signing *keyEntry
+	pending *keyEntry
+	stop    chan struct{}
+}
+
+// NewKeySet returns a set where the last key signs and all of them verify.
+func NewKeySet(keys ...*Key) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errEmptyKeySet
+	}
+	s := &KeySet{}
+	for _, k := range keys {
+		s.entries = append(s.entries, &keyEntry{key: k})
+	}
+	s.signing = s.entries[len(s.entries)-1]
+	return s, nil
+}
+
+// LoadKeySet loads a set from PEM files, the last file signs. Rotating keys across
+// several instances is done adding a new file and removing the old one once its
+// tokens expired.
+func LoadKeySet(paths ...string) (*KeySet, error) {
+	keys := make([]*Key, len(paths))
+	for i, p := range paths {
+		k, err := LoadPEMKey(p)
+		if err != nil {
+			return nil, err
+		}
+		keys[i] = k
+	}
+	return NewKeySet(keys...)
+}
+
+// GenerateKeySet returns a set with a new signing key and a pending one for the next rotation.
+func GenerateKeySet(method string) (*KeySet, error) {
+	current, err := GenerateKey(method)
+	if err != nil {
+		return nil, err
+	}
+	s, err := NewKeySet(current)
+	if err != nil {
+		return nil, err
+	}
+	next, err := GenerateKey(method)
+	if err != nil {
+		return nil, err
+	}
+	s.Rotate(next, time.Now(), 0)
+	return s, nil
+}
+
+// Signing returns the key used to sign new tokens.
+func (s *KeySet) Signing() *Key {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.signing.key
+}
+
+// Verifying returns the key identified by kid.
+func (s *KeySet) Verifying(kid string) (*Key, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, e := range s.entries {
+		if e.key.ID == kid {
+			return e.key, true
+		}
+	}
+	return nil, false
+}
+
+// Rotate promotes the pending key to signing key, adds next as pending key and drops
+// the keys retired for longer than retention. The first rotation of a set without
+// pending key only publishes next.
+func (s *KeySet) Rotate(next *Key, now time.Time, retention time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.pending != nil {
+		s.signing.retiredAt = now
+		s.signing = s.pending
+	}
+	s.pending = &keyEntry{key: next}
+	s.entries = append(s.entries, s.pending)
+
+	entries := s.entries[:0]
+	for _, e := range s.entries {
+		if !e.retiredAt.IsZero() && now.Sub(e.retiredAt) > retention {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	s.entries = entries
+}
+
+// StartRotation rotates the keys of the set every interval with new keys of method until Stop.
+func (s *KeySet) StartRotation(method string, interval, retention time.Duration) {
+	s.mu.Lock()
+	s.stop = make(chan struct{})
+	stop := s.stop
+	s.mu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				next, err := GenerateKey(method)
+				if err != nil {
+					fmt.Fprintln(os.Stderr, errors.Wrap(err, "could not rotate jwt keys"))
+					continue
+				}
+				s.Rotate(next, now, retention)
+			}
+		}
+	}()
+}
+
+// Stop ends the scheduled rotation.
+func (s *KeySet) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stop != nil {
+		close(s.stop)
+		s.stop = nil
+	}
+}
+
+// JWKS returns the public keys of the set. Symmetric keys are left out.
+func (s *KeySet) JWKS() JWKS {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	jwks := JWKS{Keys: make([]JWK, 0, len(s.entries))}
+	for _, e := range s.entries {
+		if _, ok := e.key.Method.(*jwt.SigningMethodHMAC); ok {
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, e.key.jwk())
+	}
+	return jwks
+}
+
+func encodeBase64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func padBytes(b []byte, size int) []byte {
+	if len(b) >= size {
+		return b
+	}
+	padded := make([]byte, size)
+	copy(padded[size-len(b):], b)
+	return padded
+}
@@ -0,0 +1,156 @@
+package token_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/token"
+)
+
+func TestGenerateKey(t *testing.T) {
+	t.Parallel()
+
+	for _, method := range []string{"RS256", "ES256"} {
+		t.Run(method, func(t *testing.T) {
+			k, err := token.GenerateKey(method)
+			assert.Nil(t, err)
+			assert.Equal(t, method, k.Method.Alg())
+			assert.NotEmpty(t, k.ID)
+		})
+	}
+
+	_, err := token.GenerateKey("HS256")
+	assert.EqualError(t, err, "unsupported signing method, it could be one of HS256, RS256 or ES256")
+}
+
+func TestNewKeyThumbprint(t *testing.T) {
+	t.Parallel()
+
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	k1, err := token.NewKey(private)
+	assert.Nil(t, err)
+	k2, err := token.NewKey(private)
+	assert.Nil(t, err)
+	assert.Equal(t, k1.ID, k2.ID)
+
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	k3, _ := token.NewKey(other)
+	assert.NotEqual(t, k1.ID, k3.ID)
+
+	p384, _ := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	_, err = token.NewKey(p384)
+	assert.EqualError(t, err, "unsupported key, it could be a RSA or a P-256 EC private key")
+}
+
+func TestLoadPEMKey(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "keys")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	rsaPath := filepath.Join(dir, "rsa.pem")
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)})
+	assert.Nil(t, ioutil.WriteFile(rsaPath, rsaPEM, 0600))
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	ecBytes, _ := x509.MarshalECPrivateKey(ecKey)
+	ecPath := filepath.Join(dir, "ec.pem")
+	assert.Nil(t, ioutil.WriteFile(ecPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: ecBytes}), 0600))
+
+	invalidPath := filepath.Join(dir, "invalid.pem")
+	assert.Nil(t, ioutil.WriteFile(invalidPath, []byte("test"), 0600))
+
+	k, err := token.LoadPEMKey(rsaPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "RS256", k.Method.Alg())
+
+	keys, err := token.LoadKeySet(rsaPath, ecPath)
+	assert.Nil(t, err)
+	assert.Equal(t, "ES256", keys.Signing().Method.Alg())
+	_, ok := keys.Verifying(k.ID)
+	assert.True(t, ok)
+	assert.Len(t, keys.JWKS().Keys, 2)
+
+	_, err = token.LoadPEMKey(invalidPath)
+	assert.Error(t, err)
+	_, err = token.LoadKeySet(filepath.Join(dir, "missing.pem"))
+	assert.Error(t, err)
+	_, err = token.LoadKeySet()
+	assert.EqualError(t, err, "key set must contain at least one key")
+}
+
+func TestKeySetRotate(t *testing.T) {
+	t.Parallel()
+
+	keys, err := token.GenerateKeySet("ES256")
+	assert.Nil(t, err)
+	first := keys.Signing()
+	jwks := keys.JWKS()
+	assert.Len(t, jwks.Keys, 2)
+	pending := jwks.Keys[1].Kid
+	assert.NotEqual(t, first.ID, pending)
+
+	now := time.Now()
+	next, _ := token.GenerateKey("ES256")
+	keys.Rotate(next, now, time.Hour)
+	assert.Equal(t, pending, keys.Signing().ID)
+	_, ok := keys.Verifying(first.ID)
+	assert.True(t, ok)
+	assert.Len(t, keys.JWKS().Keys, 3)
+
+	other, _ := token.GenerateKey("ES256")
+	keys.Rotate(other, now.Add(2*time.Hour), time.Hour)
+	assert.Equal(t, next.ID, keys.Signing().ID)
+	_, ok = keys.Verifying(first.ID)
+	assert.False(t, ok)
+	_, ok = keys.Verifying(pending)
+	assert.True(t, ok)
+	assert.Len(t, keys.JWKS().Keys, 3)
+}
+
+func TestKeySetStartRotation(t *testing.T) {
+	t.Parallel()
+
+	keys, err := token.GenerateKeySet("ES256")
+	assert.Nil(t, err)
+	first := keys.Signing()
+	keys.StartRotation("ES256", 10*time.Millisecond, time.Hour)
+	defer keys.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for keys.Signing().ID == first.ID && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.NotEqual(t, first.ID, keys.Signing().ID)
+}
+
+func TestKeySetJWKSSkipsHMAC(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, _ := token.GenerateKey("RS256")
+	keys, err := token.NewKeySet(token.NewHMACKey("", []byte("secret")), rsaKey)
+	assert.Nil(t, err)
+	jwks := keys.JWKS()
+	assert.Len(t, jwks.Keys, 1)
+	jwk := jwks.Keys[0]
+	assert.Equal(t, "RSA", jwk.Kty)
+	assert.Equal(t, rsaKey.ID, jwk.Kid)
+	assert.Equal(t, "RS256", jwk.Alg)
+	assert.Equal(t, "sig", jwk.Use)
+	assert.Equal(t, "AQAB", jwk.E)
+	assert.NotEmpty(t, jwk.N)
+}
@@ -8,63 +8,192 @@ import (
 	"github.com/dgrijalva/jwt-go"
 	"github.com/dgrijalva/jwt-go/request"
 	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
 )
 
 var (
 	errSigningMethod = errors.New("unexpected signing method")
 	errInvalidClaims = errors.New("error invalid jwt claims")
+	errUnknownKey    = errors.New("unknown signing key")
 )
 
+const errRevokedToken notAllowedErr = "token revoked"
+
 type notAllowedErr string
 
 func (i notAllowedErr) Error() string         { return fmt.Sprintf(string(i)) }
 func (i notAllowedErr) IsNotAuthorized() bool { return true }
 
+// Denylist provides access to the revoked tokens storage.
+type Denylist interface {
+	// DenyToken revokes a token id until the token expires.
+	DenyToken(jti string, expiresAt time.Time) error
+	// IsTokenDenied checks if a token id was revoked.
+	IsTokenDenied(jti string) (bool, error)
+}
+
 type JwtService struct {
 	expirationDelta time.Duration
-	signingKey      []byte
+	keys            *KeySet
+	denylist        Denylist
 }
 
 // NewJWTService is a helper constructor to create a new service with signing key.
-func NewJWTService(signingKey string, delta time.Duration) *JwtService {
+func NewJWTService(signingKey string, delta time.Duration, denylist Denylist) *JwtService {
+	keys, _ := NewKeySet(NewHMACKey("", []byte(signingKey)))
+	return NewJWTServiceWithKeys(keys, delta, denylist)
+}
+
+// NewJWTServiceWithKeys creates a new service signing with the keys of a KeySet.
+func NewJWTServiceWithKeys(keys *KeySet, delta time.Duration, denylist Denylist) *JwtService {
 	return &JwtService{
 		expirationDelta: delta,
-		signingKey:      []byte(signingKey),
+		keys:            keys,
+		denylist:        denylist,
 	}
 }
 
 // GenerateToken creates a new JWT
 func (s *JwtService) GenerateToken(userID string) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, NewJWTClaims(userID, s.expirationDelta))
-
-	tokenString, err := token.SignedString(s.signingKey)
+	tokenString, err := s.sign(NewJWTClaims(userID, s.expirationDelta))
 	if err != nil {
 		return "", errors.Wrap(err, "GenerateToken")
 	}
+	return tokenString, nil
+}
 
+// GenerateClientToken creates a new JWT for an OAuth2 client acting on behalf of an user.
+func (s *JwtService) GenerateClientToken(userID, clientID string, scopes []string) (string, error) {
+	tokenString, err := s.sign(NewClientJWTClaims(userID, clientID, scopes, s.expirationDelta))
+	if err != nil {
+		return "", errors.Wrap(err, "GenerateClientToken")
+	}
 	return tokenString, nil
 }
 
+// GenerateImpersonationToken creates a new JWT for an administrator acting as an user.
+// It expires after ImpersonationExpirationDelta or the regular delta, whichever is shorter.
+func (s *JwtService) GenerateImpersonationToken(userID, actorID string) (string, error) {
+	delta := s.ExpirationDelta()
+	if delta > ImpersonationExpirationDelta {
+		delta = ImpersonationExpirationDelta
+	}
+	tokenString, err := s.sign(NewImpersonationJWTClaims(userID, actorID, delta))
+	if err != nil {
+		return "", errors.Wrap(err, "GenerateImpersonationToken")
+	}
+	return tokenString, nil
+}
+
+// ExpirationDelta returns the lifetime of the generated tokens.
+func (s *JwtService) ExpirationDelta() time.Duration {
+	if s.expirationDelta == 0 {
+		return DefaultJWTExpirationDelta
+	}
+	return s.expirationDelta
+}
+
+func (s *JwtService) sign(claims jwt.Claims) (string, error) {
+	k := s.keys.Signing()
+	token := jwt.NewWithClaims(k.Method, claims)
+	if k.ID != "" {
+		token.Header["kid"] = k.ID
+	}
+	return token.SignedString(k.signing)
+}
+
+// JWKS returns the public keys used to verify the tokens.
+func (s *JwtService) JWKS() JWKS {
+	return s.keys.JWKS()
+}
+
 func (s *JwtService) IsRequestAuthorized(r *http.Request) (string, error) {
+	subject, _, err := s.RequestGrant(r)
+	return subject, err
+}
+
+// RequestGrant validates the token of a request returning its subject and, when the
+// token was issued to an OAuth2 client, the access granted to the client.
+func (s *JwtService) RequestGrant(r *http.Request) (string, *domain.AccessGrant, error) {
+	claims, err := s.requestClaims(r)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if claims.Id != "" {
+		denied, err := s.denylist.IsTokenDenied(claims.Id)
+		if err != nil {
+			return "", nil, errors.Wrap(err, "could not check token denylist")
+		}
+		if denied {
+			return "", nil, errors.WithStack(errRevokedToken)
+		}
+	}
+
+	if claims.ClientID == "" {
+		return claims.Subject, nil, nil
+	}
+	grant := &domain.AccessGrant{ClientID: claims.ClientID}
+	for _, scope := range claims.Scopes() {
+		grant.Scopes = append(grant.Scopes, domain.APIKeyScope(scope))
+	}
+	return claims.Subject, grant, nil
+}
+
+// RequestImpersonator returns the administrator the token of a request was issued to act
+// as its subject, empty when the token isn't an impersonation one.
+func (s *JwtService) RequestImpersonator(r *http.Request) (string, error) {
+	claims, err := s.requestClaims(r)
+	if err != nil {
+		return "", err
+	}
+	if claims.Act == nil {
+		return "", nil
+	}
+	return claims.Act.Subject, nil
+}
+
+// RevokeRequestToken denies the token of a request until it expires.
+// Tokens issued without id can't be revoked and are left to expire.
+func (s *JwtService) RevokeRequestToken(r *http.Request) error {
+	claims, err := s.requestClaims(r)
+	if err != nil {
+		return err
+	}
+	if claims.Id == "" {
+		return nil
+	}
+	if err := s.denylist.DenyToken(claims.Id, time.Unix(claims.ExpiresAt, 0)); err != nil {
+		return errors.Wrap(err, "could not deny token")
+	}
+	return nil
+}
+
+func (s *JwtService) requestClaims(r *http.Request) (*JWTClaims, error) {
 	token, err := request.ParseFromRequest(
 		r, request.OAuth2Extractor, s.validateMethod, request.WithClaims(&JWTClaims{}))
 
 	if err != nil || !token.Valid {
-		return "", errors.WithStack(notAllowedErr(err.Error()))
+		return nil, errors.WithStack(notAllowedErr(err.Error()))
 	}
 
 	claims, ok := token.Claims.(*JWTClaims)
 	if !ok {
-		return "", errInvalidClaims
+		return nil, errInvalidClaims
 	}
-
-	return claims.Subject, nil
+	return claims, nil
 }
 
 // validateMethod will receive the parsed token and should return the key for validating
 func (s *JwtService) validateMethod(token *jwt.Token) (interface{}, error) {
-	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+	kid, _ := token.Header["kid"].(string)
+	k, ok := s.keys.Verifying(kid)
+	if !ok {
+		return nil, errUnknownKey
+	}
+	if token.Method.Alg() != k.Method.Alg() {
 		return nil, errSigningMethod
 	}
-	return s.signingKey, nil
+	return k.verifying, nil
 }
@@ -1,23 +1,50 @@
 package token_test
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/ifreddyrondon/capture/pkg/domain"
 	"github.com/ifreddyrondon/capture/pkg/token"
 )
 
+type mockDenylist struct {
+	mu     sync.Mutex
+	denied map[string]time.Time
+	err    error
+}
+
+func (m *mockDenylist) DenyToken(jti string, expiresAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.denied == nil {
+		m.denied = make(map[string]time.Time)
+	}
+	m.denied[jti] = expiresAt
+	return m.err
+}
+
+func (m *mockDenylist) IsTokenDenied(jti string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.denied[jti]
+	return ok, m.err
+}
+
 // setup a valid service
 func getValidService() *token.JwtService {
-	return token.NewJWTService("secret", time.Minute)
+	return token.NewJWTService("secret", time.Minute, &mockDenylist{})
 }
 
 type authorizationErr interface{ IsNotAuthorized() bool }
@@ -103,3 +130,168 @@ func TestAuthorizingFailInvalidSignedMethod(t *testing.T) {
 	assert.True(t, ok)
 	assert.True(t, authErr.IsNotAuthorized())
 }
+
+func TestAuthorizingFailRevokedToken(t *testing.T) {
+	t.Parallel()
+
+	denylist := &mockDenylist{}
+	s := token.NewJWTService("secret", time.Minute, denylist)
+	tok, err := s.GenerateToken("test_123")
+	assert.Nil(t, err)
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", tok))
+	assert.Nil(t, s.RevokeRequestToken(req))
+	assert.Len(t, denylist.denied, 1)
+	for _, exp := range denylist.denied {
+		assert.True(t, exp.After(time.Now()))
+	}
+
+	_, err = s.IsRequestAuthorized(req)
+	assert.EqualError(t, err, "token revoked")
+	authErr, ok := errors.Cause(err).(authorizationErr)
+	assert.True(t, ok)
+	assert.True(t, authErr.IsNotAuthorized())
+
+	other, _ := s.GenerateToken("test_123")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", other))
+	subj, err := s.IsRequestAuthorized(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "test_123", subj)
+}
+
+func TestAuthorizingFailDenylistErr(t *testing.T) {
+	t.Parallel()
+
+	s := token.NewJWTService("secret", time.Minute, &mockDenylist{err: errors.New("test")})
+	tok, _ := s.GenerateToken("test_123")
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", tok))
+
+	_, err := s.IsRequestAuthorized(req)
+	assert.EqualError(t, err, "could not check token denylist: test")
+	assert.EqualError(t, s.RevokeRequestToken(req), "could not deny token: test")
+}
+
+func TestAsymmetricService(t *testing.T) {
+	t.Parallel()
+
+	for _, method := range []string{"RS256", "ES256"} {
+		t.Run(method, func(t *testing.T) {
+			keys, err := token.GenerateKeySet(method)
+			assert.Nil(t, err)
+			s := token.NewJWTServiceWithKeys(keys, time.Minute, &mockDenylist{})
+			tok, err := s.GenerateToken("test_123")
+			assert.Nil(t, err)
+
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", tok))
+			subj, err := s.IsRequestAuthorized(req)
+			assert.Nil(t, err)
+			assert.Equal(t, "test_123", subj)
+
+			// tokens signed with a rotated key are still valid during the retention.
+			next, _ := token.GenerateKey(method)
+			keys.Rotate(next, time.Now(), time.Minute)
+			subj, err = s.IsRequestAuthorized(req)
+			assert.Nil(t, err)
+			assert.Equal(t, "test_123", subj)
+
+			other, _ := token.GenerateKey(method)
+			keys.Rotate(other, time.Now().Add(time.Hour), time.Minute)
+			_, err = s.IsRequestAuthorized(req)
+			assert.EqualError(t, err, "unknown signing key")
+			authErr, ok := errors.Cause(err).(authorizationErr)
+			assert.True(t, ok)
+			assert.True(t, authErr.IsNotAuthorized())
+		})
+	}
+}
+
+func TestAsymmetricServiceFailAlgorithmMismatch(t *testing.T) {
+	t.Parallel()
+
+	rsaKey, _ := token.GenerateKey("RS256")
+	keys, _ := token.NewKeySet(rsaKey)
+	s := token.NewJWTServiceWithKeys(keys, time.Minute, &mockDenylist{})
+
+	// a HS256 token signed with the public key as secret must not be accepted.
+	hmac := token.NewJWTService("secret", time.Minute, &mockDenylist{})
+	tok, _ := hmac.GenerateToken("test_123")
+	parts := strings.Split(tok, ".")
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","kid":"` + rsaKey.ID + `","typ":"JWT"}`))
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v.%v.%v", header, parts[1], parts[2]))
+
+	_, err := s.IsRequestAuthorized(req)
+	assert.EqualError(t, err, "unexpected signing method")
+}
+
+func TestServiceJWKS(t *testing.T) {
+	t.Parallel()
+
+	assert.Len(t, getValidService().JWKS().Keys, 0)
+
+	keys, _ := token.GenerateKeySet("ES256")
+	s := token.NewJWTServiceWithKeys(keys, time.Minute, &mockDenylist{})
+	jwks := s.JWKS()
+	assert.Len(t, jwks.Keys, 2)
+	assert.Equal(t, keys.Signing().ID, jwks.Keys[0].Kid)
+	assert.Equal(t, "EC", jwks.Keys[0].Kty)
+	assert.Equal(t, "P-256", jwks.Keys[0].Crv)
+}
+
+func TestServiceGenerateClientToken(t *testing.T) {
+	t.Parallel()
+
+	s := getValidService()
+	tok, err := s.GenerateClientToken("test_123", "client_123", []string{"repos:read", "captures:read"})
+	assert.Nil(t, err)
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", tok))
+	subj, grant, err := s.RequestGrant(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "test_123", subj)
+	assert.Equal(t, "client_123", grant.ClientID)
+	assert.Equal(t, []domain.APIKeyScope{domain.ReposRead, domain.CapturesRead}, grant.Scopes)
+
+	userTok, _ := s.GenerateToken("test_123")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", userTok))
+	subj, grant, err = s.RequestGrant(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "test_123", subj)
+	assert.Nil(t, grant)
+	assert.Equal(t, time.Minute, s.ExpirationDelta())
+}
+
+func TestServiceGenerateImpersonationToken(t *testing.T) {
+	t.Parallel()
+
+	s := token.NewJWTService("secret", time.Hour, &mockDenylist{})
+	tok, err := s.GenerateImpersonationToken("test_123", "admin_123")
+	assert.Nil(t, err)
+
+	var claims token.JWTClaims
+	_, err = jwt.ParseWithClaims(tok, &claims, func(*jwt.Token) (interface{}, error) { return []byte("secret"), nil })
+	assert.Nil(t, err)
+	assert.Equal(t, "test_123", claims.Subject)
+	assert.Equal(t, "admin_123", claims.Act.Subject)
+	assert.Equal(t, int64(token.ImpersonationExpirationDelta.Seconds()), claims.ExpiresAt-claims.IssuedAt)
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", tok))
+	subj, grant, err := s.RequestGrant(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "test_123", subj)
+	assert.Nil(t, grant)
+	impersonator, err := s.RequestImpersonator(req)
+	assert.Nil(t, err)
+	assert.Equal(t, "admin_123", impersonator)
+
+	userTok, _ := s.GenerateToken("test_123")
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %v", userTok))
+	impersonator, err = s.RequestImpersonator(req)
+	assert.Nil(t, err)
+	assert.Empty(t, impersonator)
+}
@@ -0,0 +1,141 @@
+package twofactor
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gobuffalo/validate"
+)
+
+const (
+	// Issuer identifies the app in the authenticator apps.
+	Issuer = "Capture"
+	// Period is the lifetime of a TOTP code.
+	Period = 30 * time.Second
+	// Digits is the length of a TOTP code.
+	Digits = 6
+	// RecoveryCodes is the number of recovery codes generated for an user.
+	RecoveryCodes = 10
+
+	errCodeRequired = "code must not be blank"
+
+	secretSize       = 20
+	recoveryCodeSize = 10
+	// skew is the number of time steps accepted before and after the current one.
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// CodePayload represents a TOTP or recovery code sent to confirm an operation.
+type CodePayload struct {
+	Code *string `json:"code"`
+}
+
+func (p *CodePayload) Validate() error {
+	e := validate.NewErrors()
+	if p.Code == nil || len(strings.TrimSpace(*p.Code)) == 0 {
+		e.Add("code", errCodeRequired)
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// GenerateSecret creates a new base32 encoded TOTP secret.
+func GenerateSecret() (string, error) {
+	raw := make([]byte, secretSize)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(raw), nil
+}
+
+// URI returns the otpauth URI of a secret used to enroll it in an authenticator app.
+// Referenced at https://github.com/google/google-authenticator/wiki/Key-Uri-Format
+func URI(account, secret string) string {
+	v := url.Values{}
+	v.Set("secret", secret)
+	v.Set("issuer", Issuer)
+	v.Set("algorithm", "SHA1")
+	v.Set("digits", fmt.Sprint(Digits))
+	v.Set("period", fmt.Sprint(int(Period.Seconds())))
+	label := url.PathEscape(Issuer + ":" + account)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, v.Encode())
+}
+
+// Code returns the TOTP code of a secret at the given time. Referenced at https://tools.ietf.org/html/rfc6238
+func Code(secret string, t time.Time) (string, error) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, step(t)), nil
+}
+
+// Check compares a code with the codes of the secret around the given time, skipping the
+// steps up to lastStep. It returns the step of the matching code.
+func Check(secret, code string, t time.Time, lastStep int64) (int64, bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != Digits {
+		return 0, false
+	}
+	current := step(t)
+	for s := current - skew; s <= current+skew; s++ {
+		if s <= lastStep {
+			continue
+		}
+		if hmac.Equal([]byte(hotp(key, s)), []byte(code)) {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+func step(t time.Time) int64 {
+	return t.Unix() / int64(Period.Seconds())
+}
+
+// hotp computes the code of a counter. Referenced at https://tools.ietf.org/html/rfc4226
+func hotp(key []byte, counter int64) string {
+	msg := make([]byte, 8)
+	binary.BigEndian.PutUint64(msg, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", Digits, value%mod)
+}
+
+// GenerateRecoveryCodes creates n recovery codes with the format xxxxx-xxxxx.
+func GenerateRecoveryCodes(n int) ([]string, error) {
+	codes := make([]string, n)
+	for i := range codes {
+		raw := make([]byte, recoveryCodeSize)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		c := strings.ToLower(encoding.EncodeToString(raw))[:recoveryCodeSize]
+		codes[i] = c[:recoveryCodeSize/2] + "-" + c[recoveryCodeSize/2:]
+	}
+	return codes, nil
+}
+
+// normalizeRecoveryCode removes the formatting of a recovery code typed by an user.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.Replace(strings.TrimSpace(code), "-", "", -1))
+}
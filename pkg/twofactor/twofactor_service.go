@@ -0,0 +1,223 @@
+package twofactor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	errAlreadyEnabled conflictErr = "two-factor authentication already enabled"
+	errNotEnrolled    conflictErr = "two-factor authentication not enrolled"
+	errNotEnabled     conflictErr = "two-factor authentication not enabled"
+	errInvalidCode    invalidErr  = "invalid two-factor code"
+)
+
+type notFoundErr interface {
+	// NotFound returns true when a resource is not found.
+	NotFound() bool
+}
+
+func isNotFound(err error) bool {
+	if e, ok := errors.Cause(err).(notFoundErr); ok {
+		return e.NotFound()
+	}
+	return false
+}
+
+type conflictErr string
+
+func (e conflictErr) Error() string  { return string(e) }
+func (e conflictErr) Conflict() bool { return true }
+
+type invalidErr string
+
+func (i invalidErr) Error() string   { return string(i) }
+func (i invalidErr) IsInvalid() bool { return true }
+
+// UserStore provides access to the user storage.
+type UserStore interface {
+	// UpdateUser saves the changes of a user.
+	UpdateUser(*domain.User) error
+	// AdvanceTwoFactorStep saves the last TOTP step used by a user when it's after the
+	// stored one, reporting whether it was saved. Only one caller can use a step.
+	AdvanceTwoFactorStep(kallax.ULID, int64) (bool, error)
+}
+
+// RecoveryStore provides access to the recovery codes storage.
+type RecoveryStore interface {
+	// ReplaceRecoveryCodes removes the recovery codes of an user and stores the given ones.
+	ReplaceRecoveryCodes(kallax.ULID, []domain.RecoveryCode) error
+	// UseRecoveryCode marks as used the unused recovery code of an user with the given hash.
+	UseRecoveryCode(kallax.ULID, string, time.Time) error
+	// RemoveRecoveryCodes removes every recovery code of an user.
+	RemoveRecoveryCodes(kallax.ULID) error
+}
+
+// Enrollment represents a pending TOTP secret to be added to an authenticator app.
+type Enrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCodesResponse represents the recovery codes of an user, only visible when they are generated.
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recoveryCodes"`
+}
+
+// Service provides two-factor authentication operations.
+type Service interface {
+	// Enroll generates a new pending TOTP secret for the user.
+	Enroll(*domain.User) (*Enrollment, error)
+	// Confirm enables the two-factor authentication with a code of the pending secret,
+	// returning the recovery codes.
+	Confirm(*domain.User, CodePayload) (*RecoveryCodesResponse, error)
+	// Disable turns off the two-factor authentication with a valid code.
+	Disable(*domain.User, CodePayload) error
+	// RegenerateRecoveryCodes replaces the recovery codes with a valid code.
+	RegenerateRecoveryCodes(*domain.User, CodePayload) (*RecoveryCodesResponse, error)
+	// CheckCode reports whether a TOTP or a recovery code is valid for the user. Both are single use.
+	CheckCode(*domain.User, string) (bool, error)
+}
+
+type service struct {
+	us UserStore
+	rs RecoveryStore
+}
+
+// NewService creates a two-factor service with the necessary dependencies.
+func NewService(us UserStore, rs RecoveryStore) Service {
+	return &service{us: us, rs: rs}
+}
+
+func (s *service) Enroll(u *domain.User) (*Enrollment, error) {
+	if u.TwoFactorEnabled() {
+		return nil, errors.WithStack(errAlreadyEnabled)
+	}
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate two-factor secret")
+	}
+	u.TwoFactorSecret = secret
+	u.TwoFactorLastStep = 0
+	u.UpdatedAt = time.Now()
+	if err := s.us.UpdateUser(u); err != nil {
+		return nil, errors.Wrap(err, "could not enroll two-factor authentication")
+	}
+	return &Enrollment{Secret: secret, URI: URI(u.Email, secret)}, nil
+}
+
+func (s *service) Confirm(u *domain.User, p CodePayload) (*RecoveryCodesResponse, error) {
+	if u.TwoFactorEnabled() {
+		return nil, errors.WithStack(errAlreadyEnabled)
+	}
+	if u.TwoFactorSecret == "" {
+		return nil, errors.WithStack(errNotEnrolled)
+	}
+	now := time.Now()
+	st, ok := Check(u.TwoFactorSecret, *p.Code, now, u.TwoFactorLastStep)
+	if !ok {
+		return nil, errors.WithStack(errInvalidCode)
+	}
+	u.TwoFactorLastStep = st
+	u.TwoFactorEnabledAt = &now
+	u.UpdatedAt = now
+	if err := s.us.UpdateUser(u); err != nil {
+		return nil, errors.Wrap(err, "could not enable two-factor authentication")
+	}
+	return s.replaceRecoveryCodes(u, now)
+}
+
+func (s *service) Disable(u *domain.User, p CodePayload) error {
+	if err := s.verify(u, *p.Code); err != nil {
+		return err
+	}
+	u.TwoFactorSecret = ""
+	u.TwoFactorEnabledAt = nil
+	u.TwoFactorLastStep = 0
+	u.UpdatedAt = time.Now()
+	if err := s.us.UpdateUser(u); err != nil {
+		return errors.Wrap(err, "could not disable two-factor authentication")
+	}
+	if err := s.rs.RemoveRecoveryCodes(u.ID); err != nil {
+		return errors.Wrap(err, "could not remove recovery codes")
+	}
+	return nil
+}
+
+func (s *service) RegenerateRecoveryCodes(u *domain.User, p CodePayload) (*RecoveryCodesResponse, error) {
+	if err := s.verify(u, *p.Code); err != nil {
+		return nil, err
+	}
+	return s.replaceRecoveryCodes(u, time.Now())
+}
+
+func (s *service) CheckCode(u *domain.User, code string) (bool, error) {
+	if !u.TwoFactorEnabled() {
+		return false, nil
+	}
+	now := time.Now()
+	if st, ok := Check(u.TwoFactorSecret, code, now, u.TwoFactorLastStep); ok {
+		advanced, err := s.us.AdvanceTwoFactorStep(u.ID, st)
+		if err != nil {
+			return false, errors.Wrap(err, "could not save two-factor code")
+		}
+		if advanced {
+			u.TwoFactorLastStep = st
+		}
+		return advanced, nil
+	}
+	err := s.rs.UseRecoveryCode(u.ID, hashRecoveryCode(code), now)
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, errors.Wrap(err, "could not use recovery code")
+	}
+	return true, nil
+}
+
+// verify checks a code of an user with the two-factor authentication enabled.
+func (s *service) verify(u *domain.User, code string) error {
+	if !u.TwoFactorEnabled() {
+		return errors.WithStack(errNotEnabled)
+	}
+	ok, err := s.CheckCode(u, code)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.WithStack(errInvalidCode)
+	}
+	return nil
+}
+
+func (s *service) replaceRecoveryCodes(u *domain.User, now time.Time) (*RecoveryCodesResponse, error) {
+	codes, err := GenerateRecoveryCodes(RecoveryCodes)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate recovery codes")
+	}
+	stored := make([]domain.RecoveryCode, len(codes))
+	for i, c := range codes {
+		stored[i] = domain.RecoveryCode{
+			ID:        kallax.NewULID(),
+			Hash:      hashRecoveryCode(c),
+			CreatedAt: now,
+			UserID:    u.ID,
+		}
+	}
+	if err := s.rs.ReplaceRecoveryCodes(u.ID, stored); err != nil {
+		return nil, errors.Wrap(err, "could not save recovery codes")
+	}
+	return &RecoveryCodesResponse{RecoveryCodes: codes}, nil
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeRecoveryCode(code)))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,235 @@
+package twofactor_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/twofactor"
+)
+
+type notFoundErr string
+
+func (e notFoundErr) Error() string  { return string(e) }
+func (e notFoundErr) NotFound() bool { return true }
+
+type mockUserStore struct {
+	updated  int
+	lastStep int64
+	err      error
+}
+
+func (m *mockUserStore) UpdateUser(*domain.User) error {
+	m.updated++
+	return m.err
+}
+func (m *mockUserStore) AdvanceTwoFactorStep(_ kallax.ULID, step int64) (bool, error) {
+	if m.err != nil || step <= m.lastStep {
+		return false, m.err
+	}
+	m.lastStep = step
+	return true, nil
+}
+
+type mockRecoveryStore struct {
+	codes   []domain.RecoveryCode
+	used    string
+	removed bool
+	useErr  error
+	err     error
+}
+
+func (m *mockRecoveryStore) ReplaceRecoveryCodes(_ kallax.ULID, codes []domain.RecoveryCode) error {
+	m.codes = codes
+	return m.err
+}
+func (m *mockRecoveryStore) UseRecoveryCode(_ kallax.ULID, hash string, _ time.Time) error {
+	m.used = hash
+	return m.useErr
+}
+func (m *mockRecoveryStore) RemoveRecoveryCodes(kallax.ULID) error {
+	m.removed = true
+	return m.err
+}
+
+func enabledUser() *domain.User {
+	enabled := time.Now()
+	return &domain.User{ID: kallax.NewULID(), Email: "bob@example.com", TwoFactorSecret: rfcSecret, TwoFactorEnabledAt: &enabled}
+}
+
+func currentCode() *string {
+	c, _ := twofactor.Code(rfcSecret, time.Now())
+	return &c
+}
+
+func TestServiceEnroll(t *testing.T) {
+	t.Parallel()
+
+	us := &mockUserStore{}
+	s := twofactor.NewService(us, &mockRecoveryStore{})
+	u := &domain.User{Email: "bob@example.com"}
+	e, err := s.Enroll(u)
+	assert.Nil(t, err)
+	assert.Equal(t, u.TwoFactorSecret, e.Secret)
+	assert.True(t, strings.HasPrefix(e.URI, "otpauth://totp/Capture:bob@example.com?"))
+	assert.False(t, u.TwoFactorEnabled())
+	assert.Equal(t, 1, us.updated)
+}
+
+func TestServiceEnrollFails(t *testing.T) {
+	t.Parallel()
+
+	s := twofactor.NewService(&mockUserStore{}, &mockRecoveryStore{})
+	_, err := s.Enroll(enabledUser())
+	assert.EqualError(t, err, "two-factor authentication already enabled")
+
+	s = twofactor.NewService(&mockUserStore{err: errors.New("test")}, &mockRecoveryStore{})
+	_, err = s.Enroll(&domain.User{})
+	assert.EqualError(t, err, "could not enroll two-factor authentication: test")
+}
+
+func TestServiceConfirm(t *testing.T) {
+	t.Parallel()
+
+	rs := &mockRecoveryStore{}
+	s := twofactor.NewService(&mockUserStore{}, rs)
+	u := &domain.User{ID: kallax.NewULID(), TwoFactorSecret: rfcSecret}
+	res, err := s.Confirm(u, twofactor.CodePayload{Code: currentCode()})
+	assert.Nil(t, err)
+	assert.True(t, u.TwoFactorEnabled())
+	assert.NotZero(t, u.TwoFactorLastStep)
+	assert.Len(t, res.RecoveryCodes, twofactor.RecoveryCodes)
+	assert.Len(t, rs.codes, twofactor.RecoveryCodes)
+	assert.NotEqual(t, res.RecoveryCodes[0], rs.codes[0].Hash)
+	assert.Equal(t, u.ID, rs.codes[0].UserID)
+}
+
+func TestServiceConfirmFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		user *domain.User
+		code *string
+		us   *mockUserStore
+		rs   *mockRecoveryStore
+		err  string
+	}{
+		{"already enabled", enabledUser(), currentCode(), &mockUserStore{}, &mockRecoveryStore{}, "two-factor authentication already enabled"},
+		{"not enrolled", &domain.User{}, currentCode(), &mockUserStore{}, &mockRecoveryStore{}, "two-factor authentication not enrolled"},
+		{"invalid code", &domain.User{TwoFactorSecret: rfcSecret}, s2P("000000x"), &mockUserStore{}, &mockRecoveryStore{}, "invalid two-factor code"},
+		{"user store err", &domain.User{TwoFactorSecret: rfcSecret}, currentCode(), &mockUserStore{err: errors.New("test")}, &mockRecoveryStore{}, "could not enable two-factor authentication: test"},
+		{"recovery store err", &domain.User{TwoFactorSecret: rfcSecret}, currentCode(), &mockUserStore{}, &mockRecoveryStore{err: errors.New("test")}, "could not save recovery codes: test"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s := twofactor.NewService(tc.us, tc.rs)
+			_, err := s.Confirm(tc.user, twofactor.CodePayload{Code: tc.code})
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestServiceDisable(t *testing.T) {
+	t.Parallel()
+
+	rs := &mockRecoveryStore{}
+	s := twofactor.NewService(&mockUserStore{}, rs)
+	u := enabledUser()
+	assert.Nil(t, s.Disable(u, twofactor.CodePayload{Code: currentCode()}))
+	assert.False(t, u.TwoFactorEnabled())
+	assert.Empty(t, u.TwoFactorSecret)
+	assert.True(t, rs.removed)
+}
+
+func TestServiceDisableFails(t *testing.T) {
+	t.Parallel()
+
+	s := twofactor.NewService(&mockUserStore{}, &mockRecoveryStore{})
+	err := s.Disable(&domain.User{}, twofactor.CodePayload{Code: currentCode()})
+	assert.EqualError(t, err, "two-factor authentication not enabled")
+
+	s = twofactor.NewService(&mockUserStore{}, &mockRecoveryStore{useErr: notFoundErr("not found")})
+	err = s.Disable(enabledUser(), twofactor.CodePayload{Code: s2P("aaaaa-bbbbb")})
+	assert.EqualError(t, err, "invalid two-factor code")
+}
+
+func TestServiceRegenerateRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	rs := &mockRecoveryStore{}
+	s := twofactor.NewService(&mockUserStore{}, rs)
+	res, err := s.RegenerateRecoveryCodes(enabledUser(), twofactor.CodePayload{Code: s2P("AAAAA-bbbbb")})
+	assert.Nil(t, err)
+	assert.Len(t, res.RecoveryCodes, twofactor.RecoveryCodes)
+	assert.Len(t, rs.codes, twofactor.RecoveryCodes)
+	assert.NotEmpty(t, rs.used)
+}
+
+func TestServiceCheckCode(t *testing.T) {
+	t.Parallel()
+
+	s := twofactor.NewService(&mockUserStore{}, &mockRecoveryStore{useErr: notFoundErr("not found")})
+	u := enabledUser()
+	ok, err := s.CheckCode(u, *currentCode())
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	// the same code can't be used twice.
+	ok, err = s.CheckCode(u, *currentCode())
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	ok, err = s.CheckCode(&domain.User{}, *currentCode())
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestServiceCheckCodeUsedByConcurrentLogin(t *testing.T) {
+	t.Parallel()
+
+	us := &mockUserStore{}
+	s := twofactor.NewService(us, &mockRecoveryStore{useErr: notFoundErr("not found")})
+	// both logins loaded the user before any of them used the code.
+	first, second := enabledUser(), enabledUser()
+	ok, err := s.CheckCode(first, *currentCode())
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = s.CheckCode(second, *currentCode())
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 0, us.updated)
+}
+
+func TestServiceCheckCodeFails(t *testing.T) {
+	t.Parallel()
+
+	s := twofactor.NewService(&mockUserStore{err: errors.New("test")}, &mockRecoveryStore{})
+	_, err := s.CheckCode(enabledUser(), *currentCode())
+	assert.EqualError(t, err, "could not save two-factor code: test")
+}
+
+func TestServiceCheckRecoveryCode(t *testing.T) {
+	t.Parallel()
+
+	rs := &mockRecoveryStore{}
+	s := twofactor.NewService(&mockUserStore{}, rs)
+	ok, err := s.CheckCode(enabledUser(), "aaaaa-bbbbb")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	used := rs.used
+
+	_, _ = s.CheckCode(enabledUser(), " AAAAABBBBB ")
+	assert.Equal(t, used, rs.used)
+
+	s = twofactor.NewService(&mockUserStore{}, &mockRecoveryStore{useErr: errors.New("test")})
+	_, err = s.CheckCode(enabledUser(), "aaaaa-bbbbb")
+	assert.EqualError(t, err, "could not use recovery code: test")
+}
@@ -0,0 +1,120 @@
+package twofactor_test
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/twofactor"
+)
+
+// rfcSecret is the base32 of the RFC 6238 SHA1 test secret "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func s2P(v string) *string {
+	return &v
+}
+
+func TestValidateCodePayload(t *testing.T) {
+	t.Parallel()
+
+	assert.Nil(t, (&twofactor.CodePayload{Code: s2P("123456")}).Validate())
+	assert.EqualError(t, (&twofactor.CodePayload{}).Validate(), "code must not be blank")
+	assert.EqualError(t, (&twofactor.CodePayload{Code: s2P(" ")}).Validate(), "code must not be blank")
+}
+
+func TestCodeRFCVectors(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tc := range tt {
+		code, err := twofactor.Code(rfcSecret, time.Unix(tc.unix, 0))
+		assert.Nil(t, err)
+		assert.Equal(t, tc.code, code)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1111111109, 0)
+	current := now.Unix() / 30
+
+	st, ok := twofactor.Check(rfcSecret, "081804", now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, current, st)
+
+	previous, _ := twofactor.Code(rfcSecret, now.Add(-30*time.Second))
+	st, ok = twofactor.Check(rfcSecret, previous, now, 0)
+	assert.True(t, ok)
+	assert.Equal(t, current-1, st)
+
+	old, _ := twofactor.Code(rfcSecret, now.Add(-90*time.Second))
+	_, ok = twofactor.Check(rfcSecret, old, now, 0)
+	assert.False(t, ok)
+
+	// replayed codes are rejected.
+	_, ok = twofactor.Check(rfcSecret, "081804", now, current)
+	assert.False(t, ok)
+
+	_, ok = twofactor.Check(rfcSecret, "12345", now, 0)
+	assert.False(t, ok)
+	_, ok = twofactor.Check("invalid!", "081804", now, 0)
+	assert.False(t, ok)
+}
+
+func TestGenerateSecret(t *testing.T) {
+	t.Parallel()
+
+	s1, err := twofactor.GenerateSecret()
+	assert.Nil(t, err)
+	s2, _ := twofactor.GenerateSecret()
+	assert.Len(t, s1, 32)
+	assert.NotEqual(t, s1, s2)
+	_, err = twofactor.Code(s1, time.Now())
+	assert.Nil(t, err)
+}
+
+func TestURI(t *testing.T) {
+	t.Parallel()
+
+	uri, err := url.Parse(twofactor.URI("bob@example.com", rfcSecret))
+	assert.Nil(t, err)
+	assert.Equal(t, "otpauth", uri.Scheme)
+	assert.Equal(t, "totp", uri.Host)
+	assert.Equal(t, "/Capture:bob@example.com", uri.Path)
+	q := uri.Query()
+	assert.Equal(t, rfcSecret, q.Get("secret"))
+	assert.Equal(t, "Capture", q.Get("issuer"))
+	assert.Equal(t, "SHA1", q.Get("algorithm"))
+	assert.Equal(t, "6", q.Get("digits"))
+	assert.Equal(t, "30", q.Get("period"))
+}
+
+func TestGenerateRecoveryCodes(t *testing.T) {
+	t.Parallel()
+
+	codes, err := twofactor.GenerateRecoveryCodes(twofactor.RecoveryCodes)
+	assert.Nil(t, err)
+	assert.Len(t, codes, 10)
+	seen := map[string]bool{}
+	for _, c := range codes {
+		assert.Len(t, c, 11)
+		assert.Equal(t, 5, strings.Index(c, "-"))
+		assert.False(t, seen[c])
+		seen[c] = true
+	}
+}
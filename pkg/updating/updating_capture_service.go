@@ -2,6 +2,7 @@ package updating
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/pkg/errors"
@@ -15,27 +16,51 @@ type CaptureStore interface {
 	Save(*domain.Capture) error
 }
 
+// Publisher notifies the changes of the repositories captures.
+type Publisher interface {
+	// Publish sends the events to the interested subscribers.
+	Publish(...domain.Event)
+}
+
+// Quota accounts the storage used by the captures.
+type Quota interface {
+	// Resize accounts the change of size of an updated capture of a repository, or returns
+	// a quota exceeded error when it grows beyond the quotas.
+	Resize(r *domain.Repository, old, updated domain.Capture) error
+}
+
 // CaptureService provides updating capture operations.
 type CaptureService interface {
 	// Update a repo capture.
-	Update(Capture, *domain.Capture) error
+	Update(*domain.Repository, Capture, *domain.Capture) error
 }
 
 type captureService struct {
 	s CaptureStore
+	p Publisher
+	q Quota
 }
 
 // NewCaptureService creates a getting service with the necessary dependencies
-func NewCaptureService(s CaptureStore) CaptureService {
-	return &captureService{s: s}
+func NewCaptureService(s CaptureStore, p Publisher, q Quota) CaptureService {
+	return &captureService{s: s, p: p, q: q}
 }
 
-func (s *captureService) Update(data Capture, c *domain.Capture) error {
-	updateCapture(data, c)
-	if err := s.s.Save(c); err != nil {
+func (s *captureService) Update(r *domain.Repository, data Capture, c *domain.Capture) error {
+	updated := *c
+	updateCapture(data, &updated)
+	if err := s.q.Resize(r, *c, updated); err != nil {
+		return err
+	}
+	if err := s.s.Save(&updated); err != nil {
+		if resizeErr := s.q.Resize(r, updated, *c); resizeErr != nil {
+			fmt.Fprintln(os.Stderr, resizeErr)
+		}
 		errStr := fmt.Sprintf("could not update capture %v", c.ID)
 		return errors.Wrap(err, errStr)
 	}
+	*c = updated
+	s.p.Publish(domain.NewCaptureEvent(domain.CaptureUpdated, *c))
 	return nil
 }
 
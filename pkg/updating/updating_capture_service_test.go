@@ -20,7 +20,27 @@ type mockStore struct {
 
 func (m *mockStore) Save(*domain.Capture) error { return m.err }
 
+type mockQuota struct {
+	resized [][2]domain.Capture
+	err     error
+}
+
+func (m *mockQuota) Resize(_ *domain.Repository, old, updated domain.Capture) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.resized = append(m.resized, [2]domain.Capture{old, updated})
+	return nil
+}
+
+type mockPublisher struct {
+	published []domain.Event
+}
+
+func (m *mockPublisher) Publish(events ...domain.Event) { m.published = append(m.published, events...) }
+
 var (
+	defaultRepo      = &domain.Repository{ID: kallax.NewULID(), UserID: kallax.NewULID()}
 	defaultCaptureID = kallax.NewULID()
 	defaultCapture   = domain.Capture{
 		ID: defaultCaptureID,
@@ -117,12 +137,12 @@ func TestServiceUpdateCaptureOK(t *testing.T) {
 		},
 	}
 
-	s := updating.NewCaptureService(&mockStore{})
+	s := updating.NewCaptureService(&mockStore{}, &mockPublisher{}, &mockQuota{})
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			crrTime := time.Now()
 			capt := defaultCapture
-			err := s.Update(tc.payl, &capt)
+			err := s.Update(defaultRepo, tc.payl, &capt)
 			assert.Nil(t, err)
 
 			assert.Equal(t, tc.expected.ID, capt.ID)
@@ -138,7 +158,7 @@ func TestServiceUpdateCaptureOK(t *testing.T) {
 
 func TestServiceUpdateCaptureErrWhenSaving(t *testing.T) {
 	t.Parallel()
-	s := updating.NewCaptureService(&mockStore{err: errors.New("test")})
+	s := updating.NewCaptureService(&mockStore{err: errors.New("test")}, &mockPublisher{}, &mockQuota{})
 	data := updating.Capture{
 		Payload: &validator.Payload{
 			Payload: []domain.Metric{
@@ -147,6 +167,57 @@ func TestServiceUpdateCaptureErrWhenSaving(t *testing.T) {
 		},
 	}
 	capt := defaultCapture
-	err := s.Update(data, &capt)
+	err := s.Update(defaultRepo, data, &capt)
 	assert.EqualError(t, err, fmt.Sprintf("could not update capture %v: test", defaultCaptureID))
 }
+
+func TestServiceUpdateCapturePublishEvent(t *testing.T) {
+	t.Parallel()
+
+	p := &mockPublisher{}
+	s := updating.NewCaptureService(&mockStore{}, p, &mockQuota{})
+	capt := defaultCapture
+	err := s.Update(defaultRepo, updating.Capture{Tags: []string{"updated"}}, &capt)
+	assert.Nil(t, err)
+	assert.Len(t, p.published, 1)
+	assert.Equal(t, domain.CaptureUpdated, p.published[0].Type)
+	assert.Equal(t, []string{"updated"}, p.published[0].Capture.Tags)
+}
+
+func TestServiceUpdateCaptureResizeUsage(t *testing.T) {
+	t.Parallel()
+
+	q := &mockQuota{}
+	s := updating.NewCaptureService(&mockStore{}, &mockPublisher{}, q)
+	capt := defaultCapture
+	err := s.Update(defaultRepo, updating.Capture{Tags: []string{"updated"}}, &capt)
+	assert.Nil(t, err)
+	assert.Len(t, q.resized, 1)
+	assert.Equal(t, defaultCapture.Tags, q.resized[0][0].Tags)
+	assert.Equal(t, []string{"updated"}, q.resized[0][1].Tags)
+}
+
+func TestServiceUpdateCaptureQuotaExceeded(t *testing.T) {
+	t.Parallel()
+
+	p := &mockPublisher{}
+	s := updating.NewCaptureService(&mockStore{}, p, &mockQuota{err: errors.New("quota exceeded")})
+	capt := defaultCapture
+	err := s.Update(defaultRepo, updating.Capture{Tags: []string{"updated"}}, &capt)
+	assert.EqualError(t, err, "quota exceeded")
+	assert.Equal(t, defaultCapture.Tags, capt.Tags)
+	assert.Empty(t, p.published)
+}
+
+func TestServiceUpdateCaptureRestoreUsageWhenSavingFails(t *testing.T) {
+	t.Parallel()
+
+	q := &mockQuota{}
+	s := updating.NewCaptureService(&mockStore{err: errors.New("test")}, &mockPublisher{}, q)
+	capt := defaultCapture
+	err := s.Update(defaultRepo, updating.Capture{Tags: []string{"updated"}}, &capt)
+	assert.Error(t, err)
+	assert.Len(t, q.resized, 2)
+	assert.Equal(t, q.resized[0][0], q.resized[1][1])
+	assert.Equal(t, q.resized[0][1], q.resized[1][0])
+}
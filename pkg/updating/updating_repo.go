@@ -0,0 +1,36 @@
+package updating
+
+import (
+	"strings"
+
+	"github.com/gobuffalo/validate"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	errNameRequired         = "name must not be blank"
+	errVisibilityNotAllowed = "not allowed visibility type. it Could be one of public, or private"
+)
+
+// Repo represents the fields of a repository that can be updated.
+type Repo struct {
+	Name       *string `json:"name"`
+	Visibility *string `json:"visibility"`
+}
+
+func (p *Repo) Validate() error {
+	e := validate.NewErrors()
+	if p.Name != nil && len(strings.TrimSpace(*p.Name)) == 0 {
+		e.Add("name", errNameRequired)
+	}
+	if p.Visibility != nil {
+		if !domain.AllowedVisibility(*p.Visibility) {
+			e.Add("visibility", errVisibilityNotAllowed)
+		}
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package updating
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+// RepoStore provides access to the repository storage.
+type RepoStore interface {
+	// UpdateRepo saves the repository state into the storage.
+	UpdateRepo(*domain.Repository) error
+}
+
+// RepoService provides updating repository operations.
+type RepoService interface {
+	// Update a repository.
+	Update(Repo, *domain.Repository) error
+}
+
+type repoService struct {
+	s RepoStore
+	p Publisher
+}
+
+// NewRepoService creates an updating repository service with the necessary dependencies
+func NewRepoService(s RepoStore, p Publisher) RepoService {
+	return &repoService{s: s, p: p}
+}
+
+func (s *repoService) Update(data Repo, r *domain.Repository) error {
+	updateRepo(data, r)
+	if err := s.s.UpdateRepo(r); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not update repo %v", r.ID))
+	}
+	s.p.Publish(domain.NewRepoEvent(domain.RepoUpdated, *r))
+	return nil
+}
+
+func updateRepo(data Repo, r *domain.Repository) {
+	r.UpdatedAt = time.Now()
+	if data.Name != nil {
+		r.Name = *data.Name
+	}
+	if data.Visibility != nil {
+		r.Visibility = domain.Visibility(*data.Visibility)
+	}
+}
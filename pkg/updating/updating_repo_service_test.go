@@ -0,0 +1,51 @@
+package updating_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/updating"
+)
+
+type mockRepoStore struct {
+	err error
+}
+
+func (m *mockRepoStore) UpdateRepo(*domain.Repository) error { return m.err }
+
+func TestServiceUpdateRepoOK(t *testing.T) {
+	t.Parallel()
+	name, private := "updated", "private"
+
+	p := &mockPublisher{}
+	s := updating.NewRepoService(&mockRepoStore{}, p)
+	repo := &domain.Repository{ID: kallax.NewULID(), Name: "test", Visibility: domain.Public}
+
+	crrTime := time.Now()
+	err := s.Update(updating.Repo{Name: &name, Visibility: &private}, repo)
+	assert.Nil(t, err)
+	assert.Equal(t, "updated", repo.Name)
+	assert.Equal(t, domain.Private, repo.Visibility)
+	assert.True(t, repo.UpdatedAt.After(crrTime))
+	assert.Len(t, p.published, 1)
+	assert.Equal(t, domain.RepoUpdated, p.published[0].Type)
+	assert.Equal(t, "updated", p.published[0].Repository.Name)
+}
+
+func TestServiceUpdateRepoErrWhenSaving(t *testing.T) {
+	t.Parallel()
+
+	p := &mockPublisher{}
+	s := updating.NewRepoService(&mockRepoStore{err: errors.New("test")}, p)
+	repo := &domain.Repository{ID: kallax.NewULID()}
+
+	err := s.Update(updating.Repo{}, repo)
+	assert.EqualError(t, err, fmt.Sprintf("could not update repo %v: test", repo.ID))
+	assert.Len(t, p.published, 0)
+}
@@ -0,0 +1,63 @@
+package updating_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/updating"
+)
+
+func TestValidateRepoOK(t *testing.T) {
+	t.Parallel()
+	name, private := "test", "private"
+
+	tt := []struct {
+		name     string
+		body     string
+		expected updating.Repo
+	}{
+		{"decode empty repo", `{}`, updating.Repo{}},
+		{"decode repo with name", `{"name":"test"}`, updating.Repo{Name: &name}},
+		{"decode repo with visibility", `{"visibility":"private"}`, updating.Repo{Visibility: &private}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("PUT", "/", strings.NewReader(tc.body))
+
+			var repo updating.Repo
+			err := binder.JSON.FromReq(r, &repo)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, repo)
+		})
+	}
+}
+
+func TestValidationRepoFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name string
+		body string
+		errs []string
+	}{
+		{"decode repo when blank name", `{"name":" "}`, []string{"name must not be blank"}},
+		{"decode repo when invalid visibility", `{"visibility":"shared"}`, []string{"not allowed visibility type"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("PUT", "/", strings.NewReader(tc.body))
+
+			var repo updating.Repo
+			err := binder.JSON.FromReq(r, &repo)
+			for _, e := range tc.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
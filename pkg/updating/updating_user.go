@@ -0,0 +1,60 @@
+package updating
+
+import (
+	"strings"
+
+	"github.com/asaskevich/govalidator"
+	"github.com/gobuffalo/validate"
+)
+
+const (
+	errInvalidEmail        = "invalid email"
+	errCurrentPassRequired = "current password must not be blank"
+	errNewPassRequired     = "new password must not be blank"
+	errInvalidPasswordLen  = "new password must have at least four characters"
+
+	minPasswordLen = 4
+)
+
+// User represents the fields of the user profile that can be updated.
+// A new email is pending until it's confirmed.
+type User struct {
+	Name  *string `json:"name"`
+	Email *string `json:"email"`
+}
+
+func (p *User) Validate() error {
+	e := validate.NewErrors()
+	if p.Name != nil && len(strings.TrimSpace(*p.Name)) == 0 {
+		e.Add("name", errNameRequired)
+	}
+	if p.Email != nil && !govalidator.IsEmail(*p.Email) {
+		e.Add("email", errInvalidEmail)
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// Password represents the request body to change the password of the user.
+type Password struct {
+	Current *string `json:"currentPassword"`
+	New     *string `json:"newPassword"`
+}
+
+func (p *Password) Validate() error {
+	e := validate.NewErrors()
+	if p.Current == nil {
+		e.Add("currentPassword", errCurrentPassRequired)
+	}
+	if p.New == nil {
+		e.Add("newPassword", errNewPassRequired)
+	} else if len(*p.New) < minPasswordLen {
+		e.Add("newPassword", errInvalidPasswordLen)
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
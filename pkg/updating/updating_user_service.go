@@ -0,0 +1,135 @@
+package updating
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const errInvalidCurrentPassword invalidPasswordErr = "invalid current password"
+
+type notFoundErr interface {
+	// NotFound returns true when a resource is not found.
+	NotFound() bool
+}
+
+func isNotFound(err error) bool {
+	if e, ok := errors.Cause(err).(notFoundErr); ok {
+		return e.NotFound()
+	}
+	return false
+}
+
+type conflictErr string
+
+func (e conflictErr) Error() string  { return string(e) }
+func (e conflictErr) Conflict() bool { return true }
+
+type invalidPasswordErr string
+
+func (i invalidPasswordErr) Error() string   { return string(i) }
+func (i invalidPasswordErr) IsInvalid() bool { return true }
+
+// UserStore provides access to the user storage.
+type UserStore interface {
+	// GetUserByEmail get a user by email.
+	GetUserByEmail(string) (*domain.User, error)
+	// UpdateUser saves the changes of a user.
+	UpdateUser(*domain.User) error
+}
+
+// SessionStore provides access to the sessions of the users.
+type SessionStore interface {
+	// RevokeUserRefreshTokens revokes every active refresh token of an user.
+	RevokeUserRefreshTokens(kallax.ULID, time.Time) error
+}
+
+// EmailVerifier confirms the ownership of a new email.
+type EmailVerifier interface {
+	// SendEmailChange emails a confirmation link to the pending email of the user.
+	SendEmailChange(*domain.User) error
+}
+
+// UserService provides updating user operations.
+type UserService interface {
+	// Update the profile of an user. A new email is kept as pending until the user confirms it.
+	Update(User, *domain.User) error
+	// ChangePassword sets a new password when the current one matches and closes the sessions.
+	ChangePassword(Password, *domain.User) error
+}
+
+type userService struct {
+	s  UserStore
+	ss SessionStore
+	v  EmailVerifier
+}
+
+// NewUserService creates an updating user service with the necessary dependencies
+func NewUserService(s UserStore, ss SessionStore, v EmailVerifier) UserService {
+	return &userService{s: s, ss: ss, v: v}
+}
+
+func (s *userService) Update(data User, u *domain.User) error {
+	changeEmail := data.Email != nil && *data.Email != u.Email
+	if changeEmail {
+		if _, err := s.s.GetUserByEmail(*data.Email); err == nil {
+			return errors.WithStack(conflictErr(fmt.Sprintf("email %v already exist", *data.Email)))
+		} else if !isNotFound(err) {
+			return errors.Wrap(err, "could not get user by email")
+		}
+	}
+
+	updateUser(data, u)
+	if err := s.s.UpdateUser(u); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not update user %v", u.ID))
+	}
+	if changeEmail {
+		if err := s.v.SendEmailChange(u); err != nil {
+			return errors.Wrap(err, "could not send email change confirmation")
+		}
+	}
+	return nil
+}
+
+func updateUser(data User, u *domain.User) {
+	u.UpdatedAt = time.Now()
+	if data.Name != nil {
+		u.Name = strings.TrimSpace(*data.Name)
+	}
+	if data.Email != nil {
+		if *data.Email == u.Email {
+			// asking for the current email cancels a pending change.
+			u.PendingEmail = nil
+		} else {
+			email := *data.Email
+			u.PendingEmail = &email
+		}
+	}
+}
+
+func (s *userService) ChangePassword(data Password, u *domain.User) error {
+	if err := bcrypt.CompareHashAndPassword(u.Password, []byte(*data.Current)); err != nil {
+		return errors.WithStack(errInvalidCurrentPassword)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(*data.New), 10)
+	if err != nil {
+		return errors.Wrap(err, "could not hash password")
+	}
+
+	now := time.Now()
+	u.Password = hash
+	u.UpdatedAt = now
+	if err := s.s.UpdateUser(u); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not update user %v", u.ID))
+	}
+	if err := s.ss.RevokeUserRefreshTokens(u.ID, now); err != nil {
+		return errors.Wrap(err, "could not revoke refresh tokens")
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package updating_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/updating"
+)
+
+type notFoundErr string
+
+func (n notFoundErr) Error() string  { return string(n) }
+func (n notFoundErr) NotFound() bool { return true }
+
+type mockUserStore struct {
+	existing  *domain.User
+	getErr    error
+	err       error
+	revokedAt *time.Time
+}
+
+func (m *mockUserStore) GetUserByEmail(string) (*domain.User, error) {
+	if m.existing != nil {
+		return m.existing, nil
+	}
+	if m.getErr != nil {
+		return nil, m.getErr
+	}
+	return nil, notFoundErr("user not found")
+}
+func (m *mockUserStore) UpdateUser(*domain.User) error { return m.err }
+func (m *mockUserStore) RevokeUserRefreshTokens(userID kallax.ULID, at time.Time) error {
+	m.revokedAt = &at
+	return nil
+}
+
+type mockEmailVerifier struct {
+	sent []string
+	err  error
+}
+
+func (m *mockEmailVerifier) SendEmailChange(u *domain.User) error {
+	m.sent = append(m.sent, *u.PendingEmail)
+	return m.err
+}
+
+func newUser(password string) *domain.User {
+	hash, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	return &domain.User{ID: kallax.NewULID(), Email: "test@example.com", Password: hash}
+}
+
+func TestServiceUpdateUserName(t *testing.T) {
+	t.Parallel()
+	name := " Freddy "
+
+	v := &mockEmailVerifier{}
+	s := updating.NewUserService(&mockUserStore{}, &mockUserStore{}, v)
+	u := newUser("1234")
+
+	crrTime := time.Now()
+	assert.Nil(t, s.Update(updating.User{Name: &name}, u))
+	assert.Equal(t, "Freddy", u.Name)
+	assert.True(t, u.UpdatedAt.After(crrTime))
+	assert.Empty(t, v.sent)
+}
+
+func TestServiceUpdateUserEmail(t *testing.T) {
+	t.Parallel()
+	email := "new@example.com"
+
+	v := &mockEmailVerifier{}
+	s := updating.NewUserService(&mockUserStore{}, &mockUserStore{}, v)
+	u := newUser("1234")
+
+	assert.Nil(t, s.Update(updating.User{Email: &email}, u))
+	assert.Equal(t, "test@example.com", u.Email)
+	assert.Equal(t, "new@example.com", *u.PendingEmail)
+	assert.Equal(t, []string{"new@example.com"}, v.sent)
+
+	current := "test@example.com"
+	assert.Nil(t, s.Update(updating.User{Email: &current}, u))
+	assert.Nil(t, u.PendingEmail)
+	assert.Len(t, v.sent, 1)
+}
+
+func TestServiceUpdateUserErrors(t *testing.T) {
+	t.Parallel()
+	email := "new@example.com"
+
+	s := updating.NewUserService(&mockUserStore{existing: &domain.User{}}, &mockUserStore{}, &mockEmailVerifier{})
+	err := s.Update(updating.User{Email: &email}, newUser("1234"))
+	assert.EqualError(t, err, "email new@example.com already exist")
+
+	s = updating.NewUserService(&mockUserStore{getErr: errors.New("test")}, &mockUserStore{}, &mockEmailVerifier{})
+	err = s.Update(updating.User{Email: &email}, newUser("1234"))
+	assert.EqualError(t, err, "could not get user by email: test")
+
+	u := newUser("1234")
+	s = updating.NewUserService(&mockUserStore{err: errors.New("test")}, &mockUserStore{}, &mockEmailVerifier{})
+	err = s.Update(updating.User{}, u)
+	assert.EqualError(t, err, fmt.Sprintf("could not update user %v: test", u.ID))
+
+	s = updating.NewUserService(&mockUserStore{}, &mockUserStore{}, &mockEmailVerifier{err: errors.New("test")})
+	err = s.Update(updating.User{Email: &email}, newUser("1234"))
+	assert.EqualError(t, err, "could not send email change confirmation: test")
+}
+
+func TestServiceChangePassword(t *testing.T) {
+	t.Parallel()
+	current, next := "1234", "abcd"
+
+	sessions := &mockUserStore{}
+	s := updating.NewUserService(&mockUserStore{}, sessions, &mockEmailVerifier{})
+	u := newUser("1234")
+
+	assert.Nil(t, s.ChangePassword(updating.Password{Current: &current, New: &next}, u))
+	assert.Nil(t, bcrypt.CompareHashAndPassword(u.Password, []byte("abcd")))
+	assert.NotNil(t, sessions.revokedAt)
+
+	err := s.ChangePassword(updating.Password{Current: &current, New: &next}, u)
+	assert.EqualError(t, err, "invalid current password")
+}
+
+func TestServiceChangePasswordErrWhenSaving(t *testing.T) {
+	t.Parallel()
+	current, next := "1234", "abcd"
+
+	s := updating.NewUserService(&mockUserStore{err: errors.New("test")}, &mockUserStore{}, &mockEmailVerifier{})
+	u := newUser("1234")
+	err := s.ChangePassword(updating.Password{Current: &current, New: &next}, u)
+	assert.EqualError(t, err, fmt.Sprintf("could not update user %v: test", u.ID))
+}
@@ -0,0 +1,63 @@
+package updating_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/updating"
+)
+
+func TestValidateUserOK(t *testing.T) {
+	t.Parallel()
+	name, email := "Freddy", "test@example.com"
+
+	tt := []struct {
+		name     string
+		body     string
+		expected updating.User
+	}{
+		{"decode empty user", `{}`, updating.User{}},
+		{"decode user with name", `{"name":"Freddy"}`, updating.User{Name: &name}},
+		{"decode user with email", `{"email":"test@example.com"}`, updating.User{Email: &email}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("PATCH", "/", strings.NewReader(tc.body))
+
+			var u updating.User
+			err := binder.JSON.FromReq(r, &u)
+			assert.Nil(t, err)
+			assert.Equal(t, tc.expected, u)
+		})
+	}
+}
+
+func TestValidationUserAndPasswordFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		body    string
+		payload interface{}
+		err     string
+	}{
+		{"decode user when blank name", `{"name":" "}`, &updating.User{}, "name must not be blank"},
+		{"decode user when invalid email", `{"email":"test"}`, &updating.User{}, "invalid email"},
+		{"decode password when missing current", `{"newPassword":"1234"}`, &updating.Password{}, "current password must not be blank"},
+		{"decode password when missing new", `{"currentPassword":"1234"}`, &updating.Password{}, "new password must not be blank"},
+		{"decode password when short new", `{"currentPassword":"1234","newPassword":"1"}`, &updating.Password{}, "new password must have at least four characters"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("PUT", "/", strings.NewReader(tc.body))
+			err := binder.JSON.FromReq(r, tc.payload)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
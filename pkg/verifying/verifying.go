@@ -0,0 +1,75 @@
+package verifying
+
+import (
+	"github.com/asaskevich/govalidator"
+	"github.com/gobuffalo/validate"
+)
+
+const (
+	errEmailRequired      = "email must not be blank"
+	errInvalidEmail       = "invalid email"
+	errTokenRequired      = "token must not be blank"
+	errPasswordRequired   = "password must not be blank"
+	errInvalidPasswordLen = "password must have at least four characters"
+
+	minPasswordLen = 4
+)
+
+// EmailPayload represents the request body to ask for a verification or reset email.
+type EmailPayload struct {
+	Email *string `json:"email"`
+}
+
+// OK implementation of validator.OK
+func (p *EmailPayload) Validate() error {
+	e := validate.NewErrors()
+	if p.Email == nil {
+		e.Add("email", errEmailRequired)
+	} else if !govalidator.IsEmail(*p.Email) {
+		e.Add("email", errInvalidEmail)
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// TokenPayload represents the request body to verify an email.
+type TokenPayload struct {
+	Token *string `json:"token"`
+}
+
+// OK implementation of validator.OK
+func (p *TokenPayload) Validate() error {
+	e := validate.NewErrors()
+	if p.Token == nil || *p.Token == "" {
+		e.Add("token", errTokenRequired)
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+// ResetPayload represents the request body to choose a new password.
+type ResetPayload struct {
+	Token    *string `json:"token"`
+	Password *string `json:"password"`
+}
+
+// OK implementation of validator.OK
+func (p *ResetPayload) Validate() error {
+	e := validate.NewErrors()
+	if p.Token == nil || *p.Token == "" {
+		e.Add("token", errTokenRequired)
+	}
+	if p.Password == nil {
+		e.Add("password", errPasswordRequired)
+	} else if len(*p.Password) < minPasswordLen {
+		e.Add("password", errInvalidPasswordLen)
+	}
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
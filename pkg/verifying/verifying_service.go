@@ -0,0 +1,315 @@
+package verifying
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/mailing"
+)
+
+const (
+	// VerificationExpiration is the lifetime of the email verification tokens.
+	VerificationExpiration = 24 * time.Hour
+	// ResetExpiration is the lifetime of the password reset tokens.
+	ResetExpiration = time.Hour
+
+	errInvalidToken invalidTokenErr = "invalid or expired token"
+)
+
+type notFoundErr interface {
+	// NotFound returns true when a resource is not found.
+	NotFound() bool
+}
+
+func isNotFound(err error) bool {
+	if e, ok := errors.Cause(err).(notFoundErr); ok {
+		return e.NotFound()
+	}
+	return false
+}
+
+type constraintErr interface {
+	UniqueConstraint() bool
+}
+
+func isConstraintErr(err error) bool {
+	if e, ok := errors.Cause(err).(constraintErr); ok {
+		return e.UniqueConstraint()
+	}
+	return false
+}
+
+type conflictErr string
+
+func (e conflictErr) Error() string  { return string(e) }
+func (e conflictErr) Conflict() bool { return true }
+
+type invalidTokenErr string
+
+func (i invalidTokenErr) Error() string   { return string(i) }
+func (i invalidTokenErr) IsInvalid() bool { return true }
+
+// UserStore provides access to the user storage.
+type UserStore interface {
+	// GetUserByEmail get a user by email.
+	GetUserByEmail(string) (*domain.User, error)
+	// GetUserByID get a user by id.
+	GetUserByID(kallax.ULID) (*domain.User, error)
+	// UpdateUser saves the changes of a user.
+	UpdateUser(*domain.User) error
+}
+
+// TokenStore provides access to the user token storage.
+type TokenStore interface {
+	// CreateUserToken stores a new user token.
+	CreateUserToken(*domain.UserToken) error
+	// ConsumeUserToken marks as used the unused and unexpired token with the given
+	// hash and purpose, returning it. Only one caller can consume a token.
+	ConsumeUserToken(string, domain.UserTokenPurpose, time.Time) (*domain.UserToken, error)
+	// UseUserTokens marks as used every pending token of an user for a purpose.
+	UseUserTokens(kallax.ULID, domain.UserTokenPurpose, time.Time) error
+	// RevokeUserRefreshTokens revokes every active refresh token of an user.
+	RevokeUserRefreshTokens(kallax.ULID, time.Time) error
+}
+
+// Service provides email verification and password reset operations.
+type Service interface {
+	// SendVerification emails a verification link to the user.
+	SendVerification(*domain.User) error
+	// ResendVerification emails a new verification link when the email belongs to an unverified user.
+	ResendVerification(EmailPayload) error
+	// VerifyEmail marks as verified the email of the token owner.
+	VerifyEmail(TokenPayload) error
+	// ForgotPassword emails a password reset link when the email belongs to an user.
+	ForgotPassword(EmailPayload) error
+	// ResetPassword sets a new password to the token owner and closes its sessions.
+	ResetPassword(ResetPayload) error
+	// SendEmailChange emails a confirmation link to the pending email of the user.
+	SendEmailChange(*domain.User) error
+	// ConfirmEmailChange replaces the email of the token owner with its pending email.
+	ConfirmEmailChange(TokenPayload) error
+}
+
+type service struct {
+	us     UserStore
+	ts     TokenStore
+	mailer mailing.Mailer
+	appURL string
+}
+
+// NewService creates a verifying service with the necessary dependencies.
+// The links sent by email point to appURL.
+func NewService(us UserStore, ts TokenStore, mailer mailing.Mailer, appURL string) Service {
+	return &service{us: us, ts: ts, mailer: mailer, appURL: appURL}
+}
+
+func (s *service) SendVerification(u *domain.User) error {
+	t, err := s.issue(u.ID, domain.VerifyEmailPurpose, VerificationExpiration)
+	if err != nil {
+		return err
+	}
+	msg := mailing.Message{
+		To:      u.Email,
+		Subject: "Verify your email",
+		Body: fmt.Sprintf("Confirm your email address following the link below, it expires in %v.\n\n%s\n",
+			VerificationExpiration, s.link("verify-email", t)),
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		return errors.Wrap(err, "could not send verification email")
+	}
+	return nil
+}
+
+func (s *service) ResendVerification(p EmailPayload) error {
+	u, err := s.us.GetUserByEmail(*p.Email)
+	if err != nil {
+		// unknown emails are ignored to not disclose which ones are registered.
+		if isNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "could not get user")
+	}
+	if u.EmailVerified() {
+		return nil
+	}
+	return s.SendVerification(u)
+}
+
+func (s *service) VerifyEmail(p TokenPayload) error {
+	now := time.Now()
+	u, err := s.consume(*p.Token, domain.VerifyEmailPurpose, now)
+	if err != nil {
+		return err
+	}
+	if u.EmailVerified() {
+		return nil
+	}
+	u.EmailVerifiedAt = &now
+	u.UpdatedAt = now
+	if err := s.us.UpdateUser(u); err != nil {
+		return errors.Wrap(err, "could not verify email")
+	}
+	return nil
+}
+
+func (s *service) ForgotPassword(p EmailPayload) error {
+	u, err := s.us.GetUserByEmail(*p.Email)
+	if err != nil {
+		// unknown emails are ignored to not disclose which ones are registered.
+		if isNotFound(err) {
+			return nil
+		}
+		return errors.Wrap(err, "could not get user")
+	}
+	t, err := s.issue(u.ID, domain.ResetPasswordPurpose, ResetExpiration)
+	if err != nil {
+		return err
+	}
+	msg := mailing.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Choose a new password following the link below, it expires in %v.\n\n%s\n\n"+
+			"If you didn't ask to reset your password you can ignore this email.\n",
+			ResetExpiration, s.link("reset-password", t)),
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		return errors.Wrap(err, "could not send reset password email")
+	}
+	return nil
+}
+
+func (s *service) ResetPassword(p ResetPayload) error {
+	now := time.Now()
+	u, err := s.consume(*p.Token, domain.ResetPasswordPurpose, now)
+	if err != nil {
+		return err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(*p.Password), 10)
+	if err != nil {
+		return errors.Wrap(err, "could not hash password")
+	}
+	u.Password = hash
+	// receiving the reset email proves the ownership of the email too.
+	if !u.EmailVerified() {
+		u.EmailVerifiedAt = &now
+	}
+	u.UpdatedAt = now
+	if err := s.us.UpdateUser(u); err != nil {
+		return errors.Wrap(err, "could not reset password")
+	}
+	if err := s.ts.RevokeUserRefreshTokens(u.ID, now); err != nil {
+		return errors.Wrap(err, "could not revoke refresh tokens")
+	}
+	return nil
+}
+
+func (s *service) SendEmailChange(u *domain.User) error {
+	if u.PendingEmail == nil {
+		return nil
+	}
+	// only the link of the last requested email is valid.
+	if err := s.ts.UseUserTokens(u.ID, domain.ChangeEmailPurpose, time.Now()); err != nil {
+		return errors.Wrap(err, "could not use tokens")
+	}
+	t, err := s.issue(u.ID, domain.ChangeEmailPurpose, VerificationExpiration)
+	if err != nil {
+		return err
+	}
+	msg := mailing.Message{
+		To:      *u.PendingEmail,
+		Subject: "Confirm your new email",
+		Body: fmt.Sprintf("Confirm your new email address following the link below, it expires in %v.\n\n%s\n",
+			VerificationExpiration, s.link("confirm-email", t)),
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		return errors.Wrap(err, "could not send email change confirmation")
+	}
+	return nil
+}
+
+func (s *service) ConfirmEmailChange(p TokenPayload) error {
+	now := time.Now()
+	u, err := s.consume(*p.Token, domain.ChangeEmailPurpose, now)
+	if err != nil {
+		return err
+	}
+	if u.PendingEmail == nil {
+		return errors.WithStack(errInvalidToken)
+	}
+	email := *u.PendingEmail
+	u.Email = email
+	u.PendingEmail = nil
+	u.EmailVerifiedAt = &now
+	u.UpdatedAt = now
+	if err := s.us.UpdateUser(u); err != nil {
+		if isConstraintErr(err) {
+			return errors.WithStack(conflictErr(fmt.Sprintf("email %v already exist", email)))
+		}
+		return errors.Wrap(err, "could not change email")
+	}
+	return nil
+}
+
+func (s *service) issue(userID kallax.ULID, purpose domain.UserTokenPurpose, expiration time.Duration) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", errors.Wrap(err, "could not generate token")
+	}
+	t := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	ut := &domain.UserToken{
+		ID:        kallax.NewULID(),
+		Hash:      hashToken(t),
+		Purpose:   purpose,
+		ExpiresAt: now.Add(expiration),
+		CreatedAt: now,
+		UserID:    userID,
+	}
+	if err := s.ts.CreateUserToken(ut); err != nil {
+		return "", errors.Wrap(err, "could not create token")
+	}
+	return t, nil
+}
+
+// consume uses the token and every other pending token of the same purpose,
+// returning its owner.
+func (s *service) consume(t string, purpose domain.UserTokenPurpose, now time.Time) (*domain.User, error) {
+	ut, err := s.ts.ConsumeUserToken(hashToken(t), purpose, now)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(errInvalidToken)
+		}
+		return nil, errors.Wrap(err, "could not consume token")
+	}
+	if err := s.ts.UseUserTokens(ut.UserID, purpose, now); err != nil {
+		return nil, errors.Wrap(err, "could not use tokens")
+	}
+	u, err := s.us.GetUserByID(ut.UserID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, errors.WithStack(errInvalidToken)
+		}
+		return nil, errors.Wrap(err, "could not get user")
+	}
+	return u, nil
+}
+
+func (s *service) link(path, t string) string {
+	return fmt.Sprintf("%s/%s?token=%s", s.appURL, path, url.QueryEscape(t))
+}
+
+func hashToken(t string) string {
+	sum := sha256.Sum256([]byte(t))
+	return hex.EncodeToString(sum[:])
+}
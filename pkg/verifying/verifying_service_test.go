@@ -0,0 +1,335 @@
+package verifying_test
+
+import (
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/mailing"
+	"github.com/ifreddyrondon/capture/pkg/verifying"
+)
+
+type notFound string
+
+func (n notFound) Error() string  { return string(n) }
+func (n notFound) NotFound() bool { return true }
+
+type isInvalid interface {
+	IsInvalid() bool
+}
+
+type mockStore struct {
+	mu        sync.Mutex
+	users     map[kallax.ULID]*domain.User
+	tokens    map[string]*domain.UserToken
+	revokedAt *time.Time
+	err       error
+	updateErr error
+}
+
+func newMockStore(users ...*domain.User) *mockStore {
+	m := &mockStore{users: map[kallax.ULID]*domain.User{}, tokens: map[string]*domain.UserToken{}}
+	for _, u := range users {
+		m.users[u.ID] = u
+	}
+	return m
+}
+
+func (m *mockStore) GetUserByEmail(email string) (*domain.User, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, notFound("user not found")
+}
+
+func (m *mockStore) GetUserByID(id kallax.ULID) (*domain.User, error) {
+	if u, ok := m.users[id]; ok {
+		return u, nil
+	}
+	return nil, notFound("user not found")
+}
+
+func (m *mockStore) UpdateUser(u *domain.User) error { return m.updateErr }
+
+func (m *mockStore) CreateUserToken(t *domain.UserToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.tokens[t.Hash] = t
+	return nil
+}
+
+func (m *mockStore) ConsumeUserToken(hash string, purpose domain.UserTokenPurpose, at time.Time) (*domain.UserToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.tokens[hash]
+	if !ok || t.Purpose != purpose || t.UsedAt != nil || !at.Before(t.ExpiresAt) {
+		return nil, notFound("token not found")
+	}
+	t.UsedAt = &at
+	return t, nil
+}
+
+func (m *mockStore) UseUserTokens(userID kallax.ULID, purpose domain.UserTokenPurpose, at time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, t := range m.tokens {
+		if t.UserID == userID && t.Purpose == purpose && t.UsedAt == nil {
+			t.UsedAt = &at
+		}
+	}
+	return nil
+}
+
+func (m *mockStore) RevokeUserRefreshTokens(userID kallax.ULID, at time.Time) error {
+	m.revokedAt = &at
+	return nil
+}
+
+type failingMailer struct{}
+
+func (failingMailer) Send(mailing.Message) error { return errors.New("test") }
+
+func s2P(s string) *string { return &s }
+
+func newUser() *domain.User {
+	return &domain.User{ID: kallax.NewULID(), Email: "test@example.com"}
+}
+
+// tokenFrom extracts the token of the link in the body of the last sent email.
+func tokenFrom(t *testing.T, m *mailing.MemoryMailer) string {
+	messages := m.Messages()
+	if !assert.NotEmpty(t, messages) {
+		return ""
+	}
+	body := messages[len(messages)-1].Body
+	start := strings.Index(body, "http://")
+	link := strings.Fields(body[start:])[0]
+	u, err := url.Parse(link)
+	assert.Nil(t, err)
+	return u.Query().Get("token")
+}
+
+func TestServiceVerifyEmail(t *testing.T) {
+	t.Parallel()
+
+	u := newUser()
+	m := mailing.NewMemoryMailer()
+	store := newMockStore(u)
+	s := verifying.NewService(store, store, m, "http://localhost")
+
+	assert.Nil(t, s.SendVerification(u))
+	messages := m.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "test@example.com", messages[0].To)
+	assert.Contains(t, messages[0].Body, "http://localhost/verify-email?token=")
+
+	token := tokenFrom(t, m)
+	assert.Nil(t, s.VerifyEmail(verifying.TokenPayload{Token: &token}))
+	assert.True(t, u.EmailVerified())
+
+	err := s.VerifyEmail(verifying.TokenPayload{Token: &token})
+	assert.EqualError(t, err, "invalid or expired token")
+	invalidErr, ok := errors.Cause(err).(isInvalid)
+	assert.True(t, ok)
+	assert.True(t, invalidErr.IsInvalid())
+}
+
+func TestServiceVerifyEmailUsesPendingTokens(t *testing.T) {
+	t.Parallel()
+
+	u := newUser()
+	m := mailing.NewMemoryMailer()
+	store := newMockStore(u)
+	s := verifying.NewService(store, store, m, "http://localhost")
+
+	assert.Nil(t, s.SendVerification(u))
+	first := tokenFrom(t, m)
+	assert.Nil(t, s.SendVerification(u))
+	second := tokenFrom(t, m)
+
+	assert.Nil(t, s.VerifyEmail(verifying.TokenPayload{Token: &second}))
+	assert.EqualError(t, s.VerifyEmail(verifying.TokenPayload{Token: &first}), "invalid or expired token")
+}
+
+func TestServiceVerifyEmailExpiredToken(t *testing.T) {
+	t.Parallel()
+
+	u := newUser()
+	m := mailing.NewMemoryMailer()
+	store := newMockStore(u)
+	s := verifying.NewService(store, store, m, "http://localhost")
+
+	assert.Nil(t, s.SendVerification(u))
+	for _, tok := range store.tokens {
+		tok.ExpiresAt = time.Now().Add(-time.Minute)
+	}
+	token := tokenFrom(t, m)
+	assert.EqualError(t, s.VerifyEmail(verifying.TokenPayload{Token: &token}), "invalid or expired token")
+	assert.False(t, u.EmailVerified())
+}
+
+func TestServiceVerifyEmailWrongPurpose(t *testing.T) {
+	t.Parallel()
+
+	u := newUser()
+	m := mailing.NewMemoryMailer()
+	store := newMockStore(u)
+	s := verifying.NewService(store, store, m, "http://localhost")
+
+	assert.Nil(t, s.ForgotPassword(verifying.EmailPayload{Email: s2P(u.Email)}))
+	token := tokenFrom(t, m)
+	assert.EqualError(t, s.VerifyEmail(verifying.TokenPayload{Token: &token}), "invalid or expired token")
+}
+
+func TestServiceResendVerification(t *testing.T) {
+	t.Parallel()
+
+	u := newUser()
+	m := mailing.NewMemoryMailer()
+	store := newMockStore(u)
+	s := verifying.NewService(store, store, m, "http://localhost")
+
+	assert.Nil(t, s.ResendVerification(verifying.EmailPayload{Email: s2P("unknown@example.com")}))
+	assert.Empty(t, m.Messages())
+
+	assert.Nil(t, s.ResendVerification(verifying.EmailPayload{Email: s2P(u.Email)}))
+	assert.Len(t, m.Messages(), 1)
+
+	now := time.Now()
+	u.EmailVerifiedAt = &now
+	assert.Nil(t, s.ResendVerification(verifying.EmailPayload{Email: s2P(u.Email)}))
+	assert.Len(t, m.Messages(), 1)
+}
+
+func TestServiceResetPassword(t *testing.T) {
+	t.Parallel()
+
+	u := newUser()
+	m := mailing.NewMemoryMailer()
+	store := newMockStore(u)
+	s := verifying.NewService(store, store, m, "http://localhost")
+
+	assert.Nil(t, s.ForgotPassword(verifying.EmailPayload{Email: s2P(u.Email)}))
+	assert.Contains(t, m.Messages()[0].Body, "http://localhost/reset-password?token=")
+	token := tokenFrom(t, m)
+
+	err := s.ResetPassword(verifying.ResetPayload{Token: &token, Password: s2P("new-password")})
+	assert.Nil(t, err)
+	assert.Nil(t, bcrypt.CompareHashAndPassword(u.Password, []byte("new-password")))
+	assert.True(t, u.EmailVerified())
+	assert.NotNil(t, store.revokedAt)
+
+	err = s.ResetPassword(verifying.ResetPayload{Token: &token, Password: s2P("other-password")})
+	assert.EqualError(t, err, "invalid or expired token")
+}
+
+func TestServiceForgotPasswordUnknownEmail(t *testing.T) {
+	t.Parallel()
+
+	m := mailing.NewMemoryMailer()
+	store := newMockStore()
+	s := verifying.NewService(store, store, m, "http://localhost")
+
+	assert.Nil(t, s.ForgotPassword(verifying.EmailPayload{Email: s2P("unknown@example.com")}))
+	assert.Empty(t, m.Messages())
+}
+
+func TestServiceErrors(t *testing.T) {
+	t.Parallel()
+
+	u := newUser()
+	store := newMockStore(u)
+	s := verifying.NewService(store, store, failingMailer{}, "http://localhost")
+	assert.EqualError(t, s.SendVerification(u), "could not send verification email: test")
+	assert.EqualError(t, s.ForgotPassword(verifying.EmailPayload{Email: s2P(u.Email)}), "could not send reset password email: test")
+
+	failing := newMockStore(u)
+	failing.err = errors.New("test")
+	s = verifying.NewService(failing, failing, mailing.NewMemoryMailer(), "http://localhost")
+	assert.EqualError(t, s.ForgotPassword(verifying.EmailPayload{Email: s2P(u.Email)}), "could not get user: test")
+	assert.EqualError(t, s.ResendVerification(verifying.EmailPayload{Email: s2P(u.Email)}), "could not get user: test")
+
+	m := mailing.NewMemoryMailer()
+	failing = newMockStore(u)
+	failing.updateErr = errors.New("test")
+	s = verifying.NewService(failing, failing, m, "http://localhost")
+	assert.Nil(t, s.SendVerification(u))
+	token := tokenFrom(t, m)
+	assert.EqualError(t, s.VerifyEmail(verifying.TokenPayload{Token: &token}), "could not verify email: test")
+}
+
+type uniqueConstraintErr string
+
+func (u uniqueConstraintErr) Error() string          { return string(u) }
+func (u uniqueConstraintErr) UniqueConstraint() bool { return true }
+
+type conflictErr interface {
+	Conflict() bool
+}
+
+func TestServiceConfirmEmailChange(t *testing.T) {
+	t.Parallel()
+
+	u := newUser()
+	u.PendingEmail = s2P("new@example.com")
+	m := mailing.NewMemoryMailer()
+	store := newMockStore(u)
+	s := verifying.NewService(store, store, m, "http://localhost")
+
+	assert.Nil(t, s.SendEmailChange(u))
+	first := tokenFrom(t, m)
+	assert.Nil(t, s.SendEmailChange(u))
+	messages := m.Messages()
+	assert.Len(t, messages, 2)
+	assert.Equal(t, "new@example.com", messages[1].To)
+	assert.Contains(t, messages[1].Body, "http://localhost/confirm-email?token=")
+	token := tokenFrom(t, m)
+
+	assert.EqualError(t, s.ConfirmEmailChange(verifying.TokenPayload{Token: &first}), "invalid or expired token")
+	assert.Nil(t, s.ConfirmEmailChange(verifying.TokenPayload{Token: &token}))
+	assert.Equal(t, "new@example.com", u.Email)
+	assert.Nil(t, u.PendingEmail)
+	assert.True(t, u.EmailVerified())
+}
+
+func TestServiceSendEmailChangeWithoutPendingEmail(t *testing.T) {
+	t.Parallel()
+
+	m := mailing.NewMemoryMailer()
+	store := newMockStore()
+	s := verifying.NewService(store, store, m, "http://localhost")
+	assert.Nil(t, s.SendEmailChange(newUser()))
+	assert.Empty(t, m.Messages())
+}
+
+func TestServiceConfirmEmailChangeConflict(t *testing.T) {
+	t.Parallel()
+
+	u := newUser()
+	u.PendingEmail = s2P("taken@example.com")
+	m := mailing.NewMemoryMailer()
+	store := newMockStore(u)
+	store.updateErr = uniqueConstraintErr("duplicated email")
+	s := verifying.NewService(store, store, m, "http://localhost")
+
+	assert.Nil(t, s.SendEmailChange(u))
+	token := tokenFrom(t, m)
+	err := s.ConfirmEmailChange(verifying.TokenPayload{Token: &token})
+	assert.EqualError(t, err, "email taken@example.com already exist")
+	confErr, ok := errors.Cause(err).(conflictErr)
+	assert.True(t, ok)
+	assert.True(t, confErr.Conflict())
+}
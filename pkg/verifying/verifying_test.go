@@ -0,0 +1,58 @@
+package verifying_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/ifreddyrondon/bastion/binder"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/verifying"
+)
+
+func TestValidatePayloadsOK(t *testing.T) {
+	t.Parallel()
+
+	r, _ := http.NewRequest("POST", "/", strings.NewReader(`{"email":"test@example.com"}`))
+	var ep verifying.EmailPayload
+	assert.Nil(t, binder.JSON.FromReq(r, &ep))
+	assert.Equal(t, "test@example.com", *ep.Email)
+
+	r, _ = http.NewRequest("POST", "/", strings.NewReader(`{"token":"abc"}`))
+	var tp verifying.TokenPayload
+	assert.Nil(t, binder.JSON.FromReq(r, &tp))
+	assert.Equal(t, "abc", *tp.Token)
+
+	r, _ = http.NewRequest("POST", "/", strings.NewReader(`{"token":"abc","password":"1234"}`))
+	var rp verifying.ResetPayload
+	assert.Nil(t, binder.JSON.FromReq(r, &rp))
+	assert.Equal(t, "1234", *rp.Password)
+}
+
+func TestValidatePayloadsError(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		body    string
+		payload interface{}
+		err     string
+	}{
+		{"email missing", `{}`, &verifying.EmailPayload{}, "email must not be blank"},
+		{"invalid email", `{"email":"test"}`, &verifying.EmailPayload{}, "invalid email"},
+		{"token missing", `{}`, &verifying.TokenPayload{}, "token must not be blank"},
+		{"empty token", `{"token":""}`, &verifying.TokenPayload{}, "token must not be blank"},
+		{"reset token missing", `{"password":"1234"}`, &verifying.ResetPayload{}, "token must not be blank"},
+		{"reset password missing", `{"token":"abc"}`, &verifying.ResetPayload{}, "password must not be blank"},
+		{"reset short password", `{"token":"abc","password":"1"}`, &verifying.ResetPayload{}, "password must have at least four characters"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			r, _ := http.NewRequest("POST", "/", strings.NewReader(tc.body))
+			err := binder.JSON.FromReq(r, tc.payload)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
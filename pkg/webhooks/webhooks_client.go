@@ -0,0 +1,108 @@
+package webhooks
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// reservedNetworks are not public networks missed by the net.IP checks.
+var reservedNetworks = mustParseNetworks(
+	"0.0.0.0/8",
+	"100.64.0.0/10",
+	"192.0.0.0/24",
+	"198.18.0.0/15",
+	"240.0.0.0/4",
+	"64:ff9b::/96",
+)
+
+// ParseNetworks parses CIDR networks, a single ip is taken as a network of one address.
+func ParseNetworks(networks ...string) ([]*net.IPNet, error) {
+	result := make([]*net.IPNet, 0, len(networks))
+	for _, n := range networks {
+		if !strings.Contains(n, "/") {
+			ip := net.ParseIP(n)
+			if ip == nil {
+				return nil, errors.Errorf("invalid network address %v", n)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			result = append(result, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipnet, err := net.ParseCIDR(n)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid network %v", n)
+		}
+		result = append(result, ipnet)
+	}
+	return result, nil
+}
+
+func mustParseNetworks(networks ...string) []*net.IPNet {
+	result, err := ParseNetworks(networks...)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+// publicIP returns true when the ip is a global unicast address of a public network.
+func publicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() {
+		return false
+	}
+	return !contains(reservedNetworks, ip)
+}
+
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	for _, n := range networks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewClient returns the client delivering the webhooks. It only connects to public addresses,
+// checked after the names are resolved, and to the allowed networks, and it doesn't follow
+// redirects, so the webhooks can't be used to reach the internal network of the instance.
+func NewClient(allowed ...*net.IPNet) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   defaultTimeout,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || (!publicIP(ip) && !contains(allowed, ip)) {
+				return fmt.Errorf("webhook address %v not allowed", host)
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		DialContext:           dialer.DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   defaultTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+	return &http.Client{
+		Transport: transport,
+		Timeout:   defaultTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
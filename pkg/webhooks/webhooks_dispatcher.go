@@ -0,0 +1,202 @@
+package webhooks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	// DefaultWorkers and DefaultQueueSize are the default workers and queue size of a Dispatcher.
+	DefaultWorkers   = 8
+	DefaultQueueSize = 10000
+
+	defaultMaxAttempts = 5
+	defaultBackoff     = time.Second
+	defaultTimeout     = 10 * time.Second
+)
+
+// DispatcherStore provides access to the webhook storage needed to deliver the events.
+type DispatcherStore interface {
+	// ListWebhooks retrieve all the webhooks of a repository.
+	ListWebhooks(repoID kallax.ULID) ([]domain.Webhook, error)
+	// CreateWebhookDelivery stores the result of a delivery attempt.
+	CreateWebhookDelivery(*domain.WebhookDelivery) error
+}
+
+// job is a pending delivery. Without hook the event is fanned out to the webhooks
+// of its repository.
+type job struct {
+	event   domain.Event
+	hook    *domain.Webhook
+	body    []byte
+	attempt int
+	backoff time.Duration
+}
+
+// Dispatcher delivers the events to the webhooks of their repositories with a fixed
+// number of workers fed from a bounded queue.
+type Dispatcher struct {
+	s DispatcherStore
+	// Client sends the deliveries.
+	Client *http.Client
+	// MaxAttempts is the number of times a delivery is tried before giving up.
+	MaxAttempts int
+	// Backoff is the delay before the first retry, it's doubled on every next retry.
+	Backoff time.Duration
+
+	queue chan job
+	quit  chan struct{}
+	once  sync.Once
+	// wg counts the queued and scheduled jobs.
+	wg sync.WaitGroup
+}
+
+// NewDispatcher creates a Dispatcher with the necessary dependencies and starts its workers.
+// The deliveries are queued up to queueSize, the ones beyond are dropped.
+func NewDispatcher(s DispatcherStore, workers, queueSize int) *Dispatcher {
+	d := &Dispatcher{
+		s:           s,
+		Client:      NewClient(),
+		MaxAttempts: defaultMaxAttempts,
+		Backoff:     defaultBackoff,
+		queue:       make(chan job, queueSize),
+		quit:        make(chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		go d.work()
+	}
+	return d
+}
+
+// Publish queues the events to be delivered in background. Every attempt is recorded
+// in the delivery log of the webhook.
+func (d *Dispatcher) Publish(events ...domain.Event) {
+	for _, e := range events {
+		d.enqueue(job{event: e})
+	}
+}
+
+// Wait blocks until all the pending deliveries finish.
+func (d *Dispatcher) Wait() { d.wg.Wait() }
+
+// Close stops the workers, the pending deliveries are dropped.
+func (d *Dispatcher) Close() error {
+	d.once.Do(func() { close(d.quit) })
+	return nil
+}
+
+func (d *Dispatcher) enqueue(j job) {
+	d.wg.Add(1)
+	select {
+	case d.queue <- j:
+	default:
+		d.wg.Done()
+		fmt.Fprintf(os.Stderr, "webhooks queue full, dropping delivery of event %v\n", j.event.ID)
+	}
+}
+
+func (d *Dispatcher) work() {
+	for {
+		select {
+		case <-d.quit:
+			return
+		case j := <-d.queue:
+			if j.hook == nil {
+				if err := d.dispatch(j.event); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			} else {
+				d.deliver(j)
+			}
+			d.wg.Done()
+		}
+	}
+}
+
+func (d *Dispatcher) dispatch(e domain.Event) error {
+	hooks, err := d.s.ListWebhooks(e.RepositoryID)
+	if err != nil {
+		return errors.Wrapf(err, "could not list webhooks for event %v", e.ID)
+	}
+	var body []byte
+	for i := range hooks {
+		if !hooks[i].Subscribed(e.Type) {
+			continue
+		}
+		if body == nil {
+			if body, err = json.Marshal(e); err != nil {
+				return errors.Wrapf(err, "could not encode event %v", e.ID)
+			}
+		}
+		d.enqueue(job{event: e, hook: &hooks[i], body: body, attempt: 1, backoff: d.Backoff})
+	}
+	return nil
+}
+
+// deliver sends an attempt and schedules the retry when it fails.
+func (d *Dispatcher) deliver(j job) {
+	delivery := d.send(*j.hook, j.event, j.body)
+	delivery.Attempt = j.attempt
+	if err := d.s.CreateWebhookDelivery(delivery); err != nil {
+		fmt.Fprintln(os.Stderr, errors.Wrapf(err, "could not record delivery of event %v", j.event.ID))
+	}
+	if delivery.Succeeded() || j.attempt >= d.MaxAttempts {
+		return
+	}
+
+	retry := j
+	retry.attempt++
+	retry.backoff *= 2
+	d.wg.Add(1)
+	time.AfterFunc(j.backoff, func() {
+		defer d.wg.Done()
+		select {
+		case <-d.quit:
+		default:
+			d.enqueue(retry)
+		}
+	})
+}
+
+func (d *Dispatcher) send(hook domain.Webhook, e domain.Event, body []byte) *domain.WebhookDelivery {
+	delivery := &domain.WebhookDelivery{
+		ID:        kallax.NewULID(),
+		EventID:   e.ID,
+		EventType: e.Type,
+		CreatedAt: time.Now(),
+		WebhookID: hook.ID,
+	}
+	defer func() { delivery.Duration = int64(time.Since(delivery.CreatedAt) / time.Millisecond) }()
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(e.Type))
+	req.Header.Set(DeliveryHeader, e.ID.String())
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, body))
+
+	res, err := d.Client.Do(req)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+	delivery.StatusCode = res.StatusCode
+	return delivery
+}
@@ -0,0 +1,208 @@
+package webhooks_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/webhooks"
+)
+
+type mockDispatcherStore struct {
+	mu         sync.Mutex
+	webhooks   []domain.Webhook
+	deliveries []domain.WebhookDelivery
+	err        error
+}
+
+func (m *mockDispatcherStore) ListWebhooks(kallax.ULID) ([]domain.Webhook, error) {
+	return m.webhooks, m.err
+}
+func (m *mockDispatcherStore) CreateWebhookDelivery(d *domain.WebhookDelivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deliveries = append(m.deliveries, *d)
+	return nil
+}
+
+// standIn records the requests received and replies with the given status codes in order.
+type standIn struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (s *standIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	body, _ := ioutil.ReadAll(r.Body)
+	s.requests = append(s.requests, r)
+	s.bodies = append(s.bodies, body)
+	status := http.StatusOK
+	if len(s.statuses) > 0 {
+		status, s.statuses = s.statuses[0], s.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+// loopback allows the dispatcher to deliver to the test servers.
+var loopback, _ = webhooks.ParseNetworks("127.0.0.0/8")
+
+func newDispatcher(store *mockDispatcherStore) *webhooks.Dispatcher {
+	d := webhooks.NewDispatcher(store, 2, 100)
+	d.Client = webhooks.NewClient(loopback...)
+	d.Backoff = time.Millisecond
+	d.MaxAttempts = 3
+	return d
+}
+
+func TestDispatcherDeliverSignedEvent(t *testing.T) {
+	t.Parallel()
+
+	server := &standIn{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	repoID := kallax.NewULID()
+	hook := domain.Webhook{ID: kallax.NewULID(), URL: ts.URL, Secret: "secret", Events: []domain.EventType{domain.CaptureCreated}}
+	store := &mockDispatcherStore{webhooks: []domain.Webhook{hook}}
+	d := newDispatcher(store)
+	defer d.Close()
+
+	e := domain.NewCaptureEvent(domain.CaptureCreated, domain.Capture{ID: kallax.NewULID(), RepositoryID: repoID})
+	d.Publish(e, domain.NewCaptureEvent(domain.CaptureRemoved, domain.Capture{RepositoryID: repoID}))
+	d.Wait()
+
+	assert.Len(t, server.requests, 1)
+	req := server.requests[0]
+	assert.Equal(t, "application/json", req.Header.Get("Content-Type"))
+	assert.Equal(t, "capture.created", req.Header.Get(webhooks.EventHeader))
+	assert.Equal(t, e.ID.String(), req.Header.Get(webhooks.DeliveryHeader))
+	assert.True(t, webhooks.Verify("secret", server.bodies[0], req.Header.Get(webhooks.SignatureHeader)))
+
+	assert.Len(t, store.deliveries, 1)
+	assert.Equal(t, hook.ID, store.deliveries[0].WebhookID)
+	assert.Equal(t, e.ID, store.deliveries[0].EventID)
+	assert.Equal(t, http.StatusOK, store.deliveries[0].StatusCode)
+	assert.Equal(t, 1, store.deliveries[0].Attempt)
+}
+
+func TestDispatcherRetryUntilSuccess(t *testing.T) {
+	t.Parallel()
+
+	server := &standIn{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway}}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	hook := domain.Webhook{ID: kallax.NewULID(), URL: ts.URL, Events: []domain.EventType{domain.RepoUpdated}}
+	store := &mockDispatcherStore{webhooks: []domain.Webhook{hook}}
+	d := newDispatcher(store)
+	defer d.Close()
+
+	d.Publish(domain.NewRepoEvent(domain.RepoUpdated, domain.Repository{ID: kallax.NewULID()}))
+	d.Wait()
+
+	assert.Len(t, server.requests, 3)
+	assert.Len(t, store.deliveries, 3)
+	for i, status := range []int{500, 502, 200} {
+		assert.Equal(t, i+1, store.deliveries[i].Attempt)
+		assert.Equal(t, status, store.deliveries[i].StatusCode)
+	}
+}
+
+func TestDispatcherGiveUpAfterMaxAttempts(t *testing.T) {
+	t.Parallel()
+
+	hook := domain.Webhook{ID: kallax.NewULID(), URL: "http://127.0.0.1:1", Events: []domain.EventType{domain.CaptureCreated}}
+	store := &mockDispatcherStore{webhooks: []domain.Webhook{hook}}
+	d := newDispatcher(store)
+	defer d.Close()
+
+	d.Publish(domain.NewCaptureEvent(domain.CaptureCreated, domain.Capture{}))
+	d.Wait()
+
+	assert.Len(t, store.deliveries, 3)
+	for _, delivery := range store.deliveries {
+		assert.False(t, delivery.Succeeded())
+		assert.NotEmpty(t, delivery.Error)
+	}
+}
+
+func TestDispatcherErrListingWebhooks(t *testing.T) {
+	t.Parallel()
+
+	store := &mockDispatcherStore{err: errors.New("test")}
+	d := newDispatcher(store)
+	defer d.Close()
+
+	d.Publish(domain.NewCaptureEvent(domain.CaptureCreated, domain.Capture{}))
+	d.Wait()
+	assert.Len(t, store.deliveries, 0)
+}
+
+func TestDispatcherRefusePrivateAddresses(t *testing.T) {
+	t.Parallel()
+
+	server := &standIn{}
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	hook := domain.Webhook{ID: kallax.NewULID(), URL: ts.URL, Events: []domain.EventType{domain.CaptureCreated}}
+	store := &mockDispatcherStore{webhooks: []domain.Webhook{hook}}
+	d := webhooks.NewDispatcher(store, 1, 10)
+	defer d.Close()
+	d.MaxAttempts = 1
+
+	d.Publish(domain.NewCaptureEvent(domain.CaptureCreated, domain.Capture{}))
+	d.Wait()
+
+	assert.Len(t, server.requests, 0)
+	assert.Len(t, store.deliveries, 1)
+	assert.Contains(t, store.deliveries[0].Error, "webhook address 127.0.0.1 not allowed")
+}
+
+func TestDispatcherNotFollowRedirects(t *testing.T) {
+	t.Parallel()
+
+	target := &standIn{}
+	ts := httptest.NewServer(target)
+	defer ts.Close()
+	redirect := httptest.NewServer(http.RedirectHandler(ts.URL, http.StatusFound))
+	defer redirect.Close()
+
+	hook := domain.Webhook{ID: kallax.NewULID(), URL: redirect.URL, Events: []domain.EventType{domain.CaptureCreated}}
+	store := &mockDispatcherStore{webhooks: []domain.Webhook{hook}}
+	d := newDispatcher(store)
+	defer d.Close()
+	d.MaxAttempts = 1
+
+	d.Publish(domain.NewCaptureEvent(domain.CaptureCreated, domain.Capture{}))
+	d.Wait()
+
+	assert.Len(t, target.requests, 0)
+	assert.Len(t, store.deliveries, 1)
+	assert.Equal(t, http.StatusFound, store.deliveries[0].StatusCode)
+	assert.False(t, store.deliveries[0].Succeeded())
+}
+
+func TestParseNetworks(t *testing.T) {
+	t.Parallel()
+
+	networks, err := webhooks.ParseNetworks("10.0.0.0/8", "192.168.1.10", "::1")
+	assert.Nil(t, err)
+	assert.Len(t, networks, 3)
+	assert.Equal(t, "192.168.1.10/32", networks[1].String())
+	assert.Equal(t, "::1/128", networks[2].String())
+
+	_, err = webhooks.ParseNetworks("localhost")
+	assert.EqualError(t, err, "invalid network address localhost")
+}
@@ -0,0 +1,140 @@
+package webhooks
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/pkg/errors"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const secretSize = 32
+
+// Store provides access to the webhook storage.
+type Store interface {
+	// CreateWebhook stores a new webhook.
+	CreateWebhook(*domain.Webhook) error
+	// ListWebhooks retrieve all the webhooks of a repository.
+	ListWebhooks(repoID kallax.ULID) ([]domain.Webhook, error)
+	// GetWebhook retrieve a webhook of a repository.
+	GetWebhook(webhookID, repoID kallax.ULID) (*domain.Webhook, error)
+	// SaveWebhook the webhook state into the storage.
+	SaveWebhook(*domain.Webhook) error
+	// ListWebhookDeliveries retrieve the deliveries of a webhook with domain.Listing attrs.
+	ListWebhookDeliveries(*domain.Listing) ([]domain.WebhookDelivery, int64, error)
+}
+
+// Service provides webhooks operations.
+type Service interface {
+	// CreateWebhook registers a new webhook in a repository.
+	CreateWebhook(*domain.Repository, Payload) (*domain.Webhook, error)
+	// ListWebhooks list the repo webhooks.
+	ListWebhooks(*domain.Repository) ([]domain.Webhook, error)
+	// GetWebhook retrieve a repo webhook.
+	GetWebhook(kallax.ULID, *domain.Repository) (*domain.Webhook, error)
+	// RemoveWebhook removes a webhook from a repo.
+	RemoveWebhook(*domain.Webhook) error
+	// ListDeliveries list the delivery log of a webhook.
+	ListDeliveries(*domain.Webhook, *listing.Listing) (*ListDeliveryResponse, error)
+}
+
+type service struct {
+	s Store
+}
+
+// NewService creates a webhooks service with the necessary dependencies
+func NewService(s Store) Service {
+	return &service{s: s}
+}
+
+func (s *service) CreateWebhook(r *domain.Repository, p Payload) (*domain.Webhook, error) {
+	w, err := getDomainWebhook(r, p)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate webhook secret")
+	}
+	if err := s.s.CreateWebhook(w); err != nil {
+		return nil, errors.Wrap(err, "could not create webhook")
+	}
+	return w, nil
+}
+
+func (s *service) ListWebhooks(r *domain.Repository) ([]domain.Webhook, error) {
+	hooks, err := s.s.ListWebhooks(r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list webhooks")
+	}
+	if hooks == nil {
+		hooks = make([]domain.Webhook, 0)
+	}
+	return hooks, nil
+}
+
+func (s *service) GetWebhook(id kallax.ULID, r *domain.Repository) (*domain.Webhook, error) {
+	w, err := s.s.GetWebhook(id, r.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get webhook")
+	}
+	return w, nil
+}
+
+func (s *service) RemoveWebhook(w *domain.Webhook) error {
+	t := time.Now()
+	w.DeletedAt = &t
+	if err := s.s.SaveWebhook(w); err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not remove webhook %v", w.ID))
+	}
+	return nil
+}
+
+func (s *service) ListDeliveries(w *domain.Webhook, l *listing.Listing) (*ListDeliveryResponse, error) {
+	ldeliveries := domain.NewListing(*l)
+	ldeliveries.Owner = &w.ID
+	deliveries, total, err := s.s.ListWebhookDeliveries(ldeliveries)
+	if err != nil {
+		return nil, errors.Wrap(err, "err getting webhook deliveries")
+	}
+	l.Paging.Total = total
+	return newListDeliveryResponse(deliveries, l), nil
+}
+
+func getDomainWebhook(r *domain.Repository, p Payload) (*domain.Webhook, error) {
+	now := time.Now()
+	w := &domain.Webhook{
+		ID:           kallax.NewULID(),
+		URL:          *p.URL,
+		Events:       make([]domain.EventType, len(p.Events)),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+		RepositoryID: r.ID,
+	}
+	for i, e := range p.Events {
+		w.Events[i] = domain.EventType(e)
+	}
+	if p.Secret != nil {
+		w.Secret = *p.Secret
+		return w, nil
+	}
+	secret := make([]byte, secretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	w.Secret = hex.EncodeToString(secret)
+	return w, nil
+}
+
+type ListDeliveryResponse struct {
+	Results []domain.WebhookDelivery `json:"results"`
+	Listing *listing.Listing         `json:"listing"`
+}
+
+func newListDeliveryResponse(deliveries []domain.WebhookDelivery, l *listing.Listing) *ListDeliveryResponse {
+	if deliveries == nil {
+		deliveries = make([]domain.WebhookDelivery, 0)
+	}
+	return &ListDeliveryResponse{Results: deliveries, Listing: l}
+}
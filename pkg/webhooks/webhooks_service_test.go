@@ -0,0 +1,96 @@
+package webhooks_test
+
+import (
+	"testing"
+
+	"github.com/ifreddyrondon/bastion/middleware/listing"
+	"github.com/ifreddyrondon/bastion/middleware/listing/paging"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/src-d/go-kallax.v1"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+	"github.com/ifreddyrondon/capture/pkg/webhooks"
+)
+
+type mockStore struct {
+	webhook    *domain.Webhook
+	webhooks   []domain.Webhook
+	deliveries []domain.WebhookDelivery
+	listing    *domain.Listing
+	err        error
+}
+
+func (m *mockStore) CreateWebhook(*domain.Webhook) error { return m.err }
+func (m *mockStore) ListWebhooks(kallax.ULID) ([]domain.Webhook, error) {
+	return m.webhooks, m.err
+}
+func (m *mockStore) GetWebhook(kallax.ULID, kallax.ULID) (*domain.Webhook, error) {
+	return m.webhook, m.err
+}
+func (m *mockStore) SaveWebhook(*domain.Webhook) error { return m.err }
+func (m *mockStore) ListWebhookDeliveries(l *domain.Listing) ([]domain.WebhookDelivery, int64, error) {
+	m.listing = l
+	return m.deliveries, int64(len(m.deliveries)), m.err
+}
+
+func TestServiceCreateWebhook(t *testing.T) {
+	t.Parallel()
+
+	s := webhooks.NewService(&mockStore{})
+	repo := &domain.Repository{ID: kallax.NewULID()}
+	p := webhooks.Payload{URL: s2P("http://localhost/hook"), Events: []string{"capture.created"}}
+	h, err := s.CreateWebhook(repo, p)
+	assert.Nil(t, err)
+	assert.Equal(t, "http://localhost/hook", h.URL)
+	assert.Equal(t, []domain.EventType{domain.CaptureCreated}, h.Events)
+	assert.Equal(t, repo.ID, h.RepositoryID)
+	assert.Len(t, h.Secret, 64)
+
+	p.Secret = s2P("secret")
+	h, err = s.CreateWebhook(repo, p)
+	assert.Nil(t, err)
+	assert.Equal(t, "secret", h.Secret)
+}
+
+func TestServiceCreateWebhookErr(t *testing.T) {
+	t.Parallel()
+
+	s := webhooks.NewService(&mockStore{err: errors.New("test")})
+	p := webhooks.Payload{URL: s2P("http://localhost/hook"), Events: []string{"capture.created"}}
+	_, err := s.CreateWebhook(&domain.Repository{ID: kallax.NewULID()}, p)
+	assert.EqualError(t, err, "could not create webhook: test")
+}
+
+func TestServiceListWebhooks(t *testing.T) {
+	t.Parallel()
+
+	s := webhooks.NewService(&mockStore{})
+	hooks, err := s.ListWebhooks(&domain.Repository{ID: kallax.NewULID()})
+	assert.Nil(t, err)
+	assert.NotNil(t, hooks)
+	assert.Len(t, hooks, 0)
+}
+
+func TestServiceRemoveWebhook(t *testing.T) {
+	t.Parallel()
+
+	s := webhooks.NewService(&mockStore{})
+	h := &domain.Webhook{ID: kallax.NewULID()}
+	assert.Nil(t, s.RemoveWebhook(h))
+	assert.NotNil(t, h.DeletedAt)
+}
+
+func TestServiceListDeliveries(t *testing.T) {
+	t.Parallel()
+
+	store := &mockStore{deliveries: []domain.WebhookDelivery{{Attempt: 1}, {Attempt: 2}}}
+	s := webhooks.NewService(store)
+	h := &domain.Webhook{ID: kallax.NewULID()}
+	l := &listing.Listing{Paging: paging.Paging{Limit: 10}}
+	res, err := s.ListDeliveries(h, l)
+	assert.Nil(t, err)
+	assert.Len(t, res.Results, 2)
+	assert.Equal(t, int64(2), res.Listing.Paging.Total)
+	assert.Equal(t, h.ID, *store.listing.Owner)
+}
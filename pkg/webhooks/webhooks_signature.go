@@ -0,0 +1,29 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// Headers sent with every delivery.
+const (
+	SignatureHeader = "X-Capture-Signature"
+	EventHeader     = "X-Capture-Event"
+	DeliveryHeader  = "X-Capture-Delivery"
+)
+
+const signaturePrefix = "sha256="
+
+// Sign returns the signature of a delivery body: the hex encoded HMAC-SHA256 of the
+// body using the webhook secret, prefixed by sha256=.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the signature of a body is valid for the secret.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,62 @@
+package webhooks
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/gobuffalo/validate"
+
+	"github.com/ifreddyrondon/capture/pkg/domain"
+)
+
+const (
+	errURLRequired    = "url must not be blank"
+	errURLInvalid     = "url must be an absolute http or https url"
+	errEventsRequired = "events must contain at least one event"
+	errSecretBlank    = "secret must not be blank"
+)
+
+// Payload represents the data to register a webhook.
+type Payload struct {
+	URL    *string  `json:"url"`
+	Events []string `json:"events"`
+	// Secret used to sign the deliveries. A random one is generated when missing.
+	Secret *string `json:"secret"`
+}
+
+func (p *Payload) Validate() error {
+	e := validate.NewErrors()
+	if p.URL == nil || len(strings.TrimSpace(*p.URL)) == 0 {
+		e.Add("url", errURLRequired)
+	} else if u, err := url.Parse(*p.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		e.Add("url", errURLInvalid)
+	}
+
+	if len(p.Events) == 0 {
+		e.Add("events", errEventsRequired)
+	}
+	for _, event := range p.Events {
+		if !allowedEvent(event) {
+			e.Add("events", fmt.Sprintf("not allowed event %v. it could be one of %v", event, domain.EventTypes))
+		}
+	}
+
+	if p.Secret != nil && len(strings.TrimSpace(*p.Secret)) == 0 {
+		e.Add("secret", errSecretBlank)
+	}
+
+	if e.HasAny() {
+		return e
+	}
+	return nil
+}
+
+func allowedEvent(event string) bool {
+	for _, t := range domain.EventTypes {
+		if string(t) == event {
+			return true
+		}
+	}
+	return false
+}
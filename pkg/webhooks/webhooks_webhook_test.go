@@ -0,0 +1,56 @@
+package webhooks_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ifreddyrondon/capture/pkg/webhooks"
+)
+
+func s2P(v string) *string {
+	return &v
+}
+
+func TestValidatePayloadOK(t *testing.T) {
+	t.Parallel()
+
+	p := webhooks.Payload{URL: s2P("https://example.com/hook"), Events: []string{"capture.created", "repo.updated"}}
+	assert.Nil(t, p.Validate())
+}
+
+func TestValidatePayloadFails(t *testing.T) {
+	t.Parallel()
+
+	tt := []struct {
+		name    string
+		payload webhooks.Payload
+		errs    []string
+	}{
+		{"missing url and events", webhooks.Payload{}, []string{"url must not be blank", "events must contain at least one event"}},
+		{"relative url", webhooks.Payload{URL: s2P("/hook"), Events: []string{"capture.created"}}, []string{"url must be an absolute http or https url"}},
+		{"not http url", webhooks.Payload{URL: s2P("ftp://example.com"), Events: []string{"capture.created"}}, []string{"url must be an absolute http or https url"}},
+		{"unknown event", webhooks.Payload{URL: s2P("http://example.com"), Events: []string{"capture.moved"}}, []string{"not allowed event capture.moved"}},
+		{"blank secret", webhooks.Payload{URL: s2P("http://example.com"), Events: []string{"capture.created"}, Secret: s2P(" ")}, []string{"secret must not be blank"}},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.payload.Validate()
+			for _, e := range tc.errs {
+				assert.Contains(t, err.Error(), e)
+			}
+		})
+	}
+}
+
+func TestSignAndVerify(t *testing.T) {
+	t.Parallel()
+
+	body := []byte(`{"type":"capture.created"}`)
+	signature := webhooks.Sign("secret", body)
+	assert.Contains(t, signature, "sha256=")
+	assert.True(t, webhooks.Verify("secret", body, signature))
+	assert.False(t, webhooks.Verify("other", body, signature))
+	assert.False(t, webhooks.Verify("secret", []byte("{}"), signature))
+}